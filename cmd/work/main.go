@@ -13,6 +13,7 @@ import (
 	utilflag "k8s.io/component-base/cli/flag"
 	"k8s.io/component-base/logs"
 
+	"open-cluster-management.io/work/pkg/cmd/hub"
 	"open-cluster-management.io/work/pkg/cmd/spoke"
 	"open-cluster-management.io/work/pkg/cmd/webhook"
 	"open-cluster-management.io/work/pkg/version"
@@ -51,6 +52,7 @@ func newWorkCommand() *cobra.Command {
 	}
 
 	cmd.AddCommand(spoke.NewWorkloadAgent())
+	cmd.AddCommand(hub.NewHubManager())
 	cmd.AddCommand(webhook.NewAdmissionHook())
 
 	return cmd
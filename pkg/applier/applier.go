@@ -0,0 +1,470 @@
+// Package applier applies manifests to a cluster. It is the apply pipeline of the work agent, which decodes the
+// manifests, resolves their resources with a rest mapper, applies them with typed clients for the well known kinds
+// and with a dynamic client otherwise, and generates the Applied conditions of the manifests. It is independent of
+// ManifestWorks and the controller framework, so that it can be reused by other components applying manifests.
+//
+// Apply applies a list of manifests with the common options. The work agent applies the manifests of a ManifestWork
+// one by one with ResourceMeta, ApplyResource and ApplyResourceServerSide instead, since the manifests are applied
+// with the settings of each manifest and the state of the previous applies.
+package applier
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/resource/resourcemerge"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/helper"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+// Applier applies manifests to a cluster with the clients of the cluster.
+type Applier struct {
+	kubeClient         kubernetes.Interface
+	apiExtensionClient apiextensionsclient.Interface
+	dynamicClient      dynamic.Interface
+	restMapper         meta.RESTMapper
+}
+
+// NewApplier returns an Applier. The resources of the manifests are resolved with the restMapper, the manifests of
+// the kinds not known to the rest mapper fail to apply.
+func NewApplier(
+	kubeClient kubernetes.Interface,
+	apiExtensionClient apiextensionsclient.Interface,
+	dynamicClient dynamic.Interface,
+	restMapper meta.RESTMapper) *Applier {
+	return &Applier{
+		kubeClient:         kubeClient,
+		apiExtensionClient: apiExtensionClient,
+		dynamicClient:      dynamicClient,
+		restMapper:         restMapper,
+	}
+}
+
+// Options are the options of applying manifests.
+type Options struct {
+	// Owner is merged into the owner references of the applied resources, including those declared by the manifests,
+	// if it is not nil. The owner is removed from the owner references instead if its UID ends with "-".
+	Owner *metav1.OwnerReference
+	// Recorder records the events of applying the manifests, no event is recorded if it is nil.
+	Recorder events.Recorder
+}
+
+// Apply applies the manifests in order, a manifest failing to apply does not stop the others from being applied.
+// It returns the conditions of the manifests with the Applied condition, and the resources applied, whose UIDs
+// are set. The errors of the manifests failing to apply are aggregated into the returned error.
+func (a *Applier) Apply(
+	ctx context.Context,
+	manifests []workapiv1.Manifest,
+	options Options) ([]workapiv1.ManifestCondition, []workapiv1.AppliedManifestResourceMeta, error) {
+	recorder := options.Recorder
+	if recorder == nil {
+		recorder = events.NewInMemoryRecorder("applier")
+	}
+
+	conditions := []workapiv1.ManifestCondition{}
+	appliedResources := []workapiv1.AppliedManifestResourceMeta{}
+	var errs []error
+	for index, manifest := range manifests {
+		resourceMeta, gvr, err := a.ResourceMeta(index, manifest)
+		var obj runtime.Object
+		if err == nil {
+			obj, _, err = a.ApplyResource(ctx, manifest, gvr, options.Owner, "", recorder)
+		}
+
+		conditions = append(conditions, workapiv1.ManifestCondition{
+			ResourceMeta: resourceMeta,
+			Conditions:   []metav1.Condition{AppliedCondition(err, "")},
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("manifest %d: %w", index, err))
+			continue
+		}
+
+		appliedResource := workapiv1.AppliedManifestResourceMeta{
+			Group:     gvr.Group,
+			Version:   gvr.Version,
+			Resource:  gvr.Resource,
+			Namespace: resourceMeta.Namespace,
+			Name:      resourceMeta.Name,
+		}
+		if accessor, err := meta.Accessor(obj); err == nil {
+			appliedResource.UID = string(accessor.GetUID())
+		}
+		appliedResources = append(appliedResources, appliedResource)
+	}
+	return conditions, appliedResources, utilerrors.NewAggregate(errs)
+}
+
+// ResourceMeta returns the meta and the GVR of the resource of the manifest, the index is the ordinal of the
+// manifest. The resource of the meta is empty if it cannot be resolved by the rest mapper of the Applier.
+func (a *Applier) ResourceMeta(index int, manifest workapiv1.Manifest) (workapiv1.ManifestResourceMeta, schema.GroupVersionResource, error) {
+	var object runtime.Object
+	switch {
+	case manifest.Object != nil:
+		object = manifest.Object
+	default:
+		unstructuredObj := &unstructured.Unstructured{}
+		if err := unstructuredObj.UnmarshalJSON(manifest.Raw); err != nil {
+			return workapiv1.ManifestResourceMeta{Ordinal: int32(index)}, schema.GroupVersionResource{},
+				utilerrors.NewAggregate([]error{err})
+		}
+		object = unstructuredObj
+	}
+	return buildResourceMeta(index, object, a.restMapper)
+}
+
+func buildResourceMeta(
+	index int,
+	object runtime.Object,
+	restMapper meta.RESTMapper) (workapiv1.ManifestResourceMeta, schema.GroupVersionResource, error) {
+	resourceMeta := workapiv1.ManifestResourceMeta{
+		Ordinal: int32(index),
+	}
+
+	if object == nil || reflect.ValueOf(object).IsNil() {
+		return resourceMeta, schema.GroupVersionResource{}, nil
+	}
+
+	// set gvk
+	gvk, err := helper.GuessObjectGroupVersionKind(object)
+	if err != nil {
+		return resourceMeta, schema.GroupVersionResource{}, err
+	}
+	resourceMeta.Group = gvk.Group
+	resourceMeta.Version = gvk.Version
+	resourceMeta.Kind = gvk.Kind
+
+	// the errors of decoding the metadata are aggregated with the one of resolving the resource, so that neither is
+	// dropped
+	errs := []error{}
+
+	// set namespace/name
+	if accessor, err := meta.Accessor(object); err != nil {
+		errs = append(errs, fmt.Errorf("cannot access metadata of %v: %w", object, err))
+	} else {
+		resourceMeta.Namespace = accessor.GetNamespace()
+		resourceMeta.Name = accessor.GetName()
+	}
+
+	// set resource
+	if restMapper == nil {
+		return resourceMeta, schema.GroupVersionResource{}, utilerrors.Reduce(utilerrors.NewAggregate(errs))
+	}
+	mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		errs = append(errs, applyerrors.NewMappingNotFoundError(
+			schema.GroupVersionResource{Group: gvk.Group, Version: gvk.Version}, resourceMeta.Namespace, resourceMeta.Name,
+			fmt.Errorf("the server doesn't have a resource type %q: %w", gvk.Kind, err)))
+		return resourceMeta, schema.GroupVersionResource{}, utilerrors.Reduce(utilerrors.NewAggregate(errs))
+	}
+
+	resourceMeta.Resource = mapping.Resource.Resource
+	return resourceMeta, mapping.Resource, utilerrors.Reduce(utilerrors.NewAggregate(errs))
+}
+
+// Decode decodes the raw manifest into an unstructured object.
+func Decode(data []byte) (*unstructured.Unstructured, error) {
+	unstructuredObj := &unstructured.Unstructured{}
+	if err := unstructuredObj.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("Failed to decode object: %w", err)
+	}
+	return unstructuredObj, nil
+}
+
+// ApplyResource applies the raw manifest to the resource with the gvr, and returns the applied resource and whether
+// it is changed. The well known kinds are applied with typed clients, and the others with the dynamic client. The
+// owner is merged into the owner references of the resource if it is not nil, see Options. If expectedUID is not
+// empty, a conflict error is returned without writing the resource if the UID of the existing resource is different,
+// and it is the precondition of the update of a resource applied with the dynamic client. Secrets are applied by
+// applySecret. Services and ServiceAccounts are applied with the dynamic client, so that the fields populated on the
+// cluster are preserved, see preserveServerManagedFields.
+func (a *Applier) ApplyResource(
+	ctx context.Context,
+	manifest workapiv1.Manifest,
+	gvr schema.GroupVersionResource,
+	owner *metav1.OwnerReference,
+	expectedUID types.UID,
+	recorder events.Recorder) (runtime.Object, bool, error) {
+	// the immutable flag of secrets is handled explicitly, it is not applied with the typed client
+	if gvr.GroupResource() == secretGroupResource {
+		required, err := Decode(manifest.Raw)
+		if err != nil {
+			return nil, false, err
+		}
+		withOwner(required, owner)
+		actual, changed, err := a.applySecret(ctx, required, gvr, expectedUID, recorder)
+		if actual == nil {
+			return nil, changed, err
+		}
+		return actual, changed, err
+	}
+
+	// the typed clients overwrite the fields populated on the cluster, e.g. the node ports of a service
+	if gvr.GroupResource() == serviceGroupResource || gvr.GroupResource() == serviceAccountGroupResource {
+		return a.applyUnstructured(ctx, manifest.Raw, owner, gvr, expectedUID, recorder)
+	}
+
+	// the typed clients get the existing resource and update it without a precondition
+	if len(expectedUID) != 0 {
+		if err := a.checkExistingUID(ctx, manifest, gvr, expectedUID); err != nil {
+			return nil, false, err
+		}
+	}
+
+	clientHolder := resourceapply.NewClientHolder().
+		WithAPIExtensionsClient(a.apiExtensionClient).
+		WithKubernetes(a.kubeClient).
+		WithDynamicClient(a.dynamicClient)
+
+	results := resourceapply.ApplyDirectly(ctx, clientHolder, recorder, func(name string) ([]byte, error) {
+		unstructuredObj := &unstructured.Unstructured{}
+		err := unstructuredObj.UnmarshalJSON(manifest.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		withOwner(unstructuredObj, owner)
+		return unstructuredObj.MarshalJSON()
+	}, "manifest")
+
+	// Try apply with dynamic client if the manifest cannot be decoded by scheme or typed client is not found
+	// TODO we should check the certain error.
+	// Use dynamic client when scheme cannot decode manifest or typed client cannot handle the object
+	if isDecodeError(results[0].Error) || isUnhandledError(results[0].Error) || isUnsupportedError(results[0].Error) {
+		return a.applyUnstructured(ctx, manifest.Raw, owner, gvr, expectedUID, recorder)
+	}
+	return results[0].Result, results[0].Changed, results[0].Error
+}
+
+// checkExistingUID returns a conflict error if the resource of the manifest exists with a UID other than the
+// expectedUID, e.g. it was recreated by others since the apply decision was made. Only the kinds which may be applied
+// with the typed clients are checked, the others are applied with the dynamic client with the UID as a precondition.
+func (a *Applier) checkExistingUID(
+	ctx context.Context, manifest workapiv1.Manifest, gvr schema.GroupVersionResource, expectedUID types.UID) error {
+	required, err := Decode(manifest.Raw)
+	if err != nil || !kubescheme.Scheme.Recognizes(required.GroupVersionKind()) {
+		// the decode error is reported by the apply
+		return nil
+	}
+	existing, err := a.dynamicClient.Resource(gvr).Namespace(required.GetNamespace()).Get(ctx, required.GetName(), metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+	return verifyExistingUID(gvr, existing, expectedUID)
+}
+
+// verifyExistingUID returns a conflict error if the UID of the existing resource is not the expectedUID.
+func verifyExistingUID(gvr schema.GroupVersionResource, existing metav1.Object, expectedUID types.UID) error {
+	if len(expectedUID) == 0 || existing.GetUID() == expectedUID {
+		return nil
+	}
+	return applyerrors.NewResourceConflictError(gvr, existing.GetNamespace(), existing.GetName(),
+		errors.NewConflict(gvr.GroupResource(), existing.GetName(),
+			fmt.Errorf("the resource was recreated, expected uid %s but got %s", expectedUID, existing.GetUID())))
+}
+
+// ApplyResourceServerSide applies the raw manifest to the resource with the gvr with server side apply as the
+// fieldManager, the fields conflicting with the other managers are taken over. It returns the applied resource and
+// whether it is changed. The owner is merged into the owner references of the resource if it is not nil. If
+// expectedUID is not empty, it is the precondition of applying an existing resource.
+func (a *Applier) ApplyResourceServerSide(
+	ctx context.Context,
+	manifest workapiv1.Manifest,
+	gvr schema.GroupVersionResource,
+	owner *metav1.OwnerReference,
+	expectedUID types.UID,
+	fieldManager string,
+	recorder events.Recorder) (*unstructured.Unstructured, bool, error) {
+	required, err := Decode(manifest.Raw)
+	if err != nil {
+		return nil, false, err
+	}
+	withOwner(required, owner)
+
+	client := a.dynamicClient.Resource(gvr).Namespace(required.GetNamespace())
+	existing, err := client.Get(ctx, required.GetName(), metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		existing = nil
+	case err != nil:
+		return nil, false, err
+	default:
+		if err := verifyExistingUID(gvr, existing, expectedUID); err != nil {
+			return nil, false, err
+		}
+		// the uid works as a precondition of the apply, the apply fails if the resource is recreated
+		if len(expectedUID) != 0 {
+			required.SetUID(expectedUID)
+		}
+	}
+
+	data, err := required.MarshalJSON()
+	if err != nil {
+		return nil, false, err
+	}
+	force := true
+	actual, err := client.Patch(ctx, required.GetName(), types.ApplyPatchType, data,
+		metav1.PatchOptions{FieldManager: fieldManager, Force: &force})
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil && existing.GetResourceVersion() == actual.GetResourceVersion() {
+		return actual, false, nil
+	}
+	recorder.Eventf(fmt.Sprintf(
+		"%s Applied", required.GetKind()), "Applied %s/%s with server side apply", required.GetNamespace(), required.GetName())
+	return actual, true, nil
+}
+
+func (a *Applier) applyUnstructured(
+	ctx context.Context,
+	data []byte,
+	owner *metav1.OwnerReference,
+	gvr schema.GroupVersionResource,
+	expectedUID types.UID,
+	recorder events.Recorder) (*unstructured.Unstructured, bool, error) {
+
+	required, err := Decode(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	withOwner(required, owner)
+
+	existing, err := a.dynamicClient.
+		Resource(gvr).
+		Namespace(required.GetNamespace()).
+		Get(ctx, required.GetName(), metav1.GetOptions{})
+
+	switch {
+	case errors.IsNotFound(err):
+		actual, err := a.dynamicClient.Resource(gvr).Namespace(required.GetNamespace()).Create(
+			ctx, resourcemerge.WithCleanLabelsAndAnnotations(required).(*unstructured.Unstructured), metav1.CreateOptions{})
+		recorder.Eventf(fmt.Sprintf(
+			"%s Created", required.GetKind()), "Created %s/%s because it was missing", required.GetNamespace(), required.GetName())
+		return actual, true, err
+	case err != nil:
+		return nil, false, err
+	}
+
+	// Merge OwnerRefs.
+	existingOwners := existing.GetOwnerReferences()
+	modified := resourcemerge.BoolPtr(false)
+
+	resourcemerge.MergeOwnerRefs(modified, &existingOwners, required.GetOwnerReferences())
+
+	// Always overwrite required ownerrefs from existing, since ownerrefs of required has been merged to existing
+	required.SetOwnerReferences(existingOwners)
+
+	// Keep the fields populated on the managed cluster, e.g. the cluster ip of a service.
+	preserveServerManagedFields(gvr, required, existing)
+
+	// Compare and update the unstrcuctured.
+	if isSameUnstructured(required, existing) {
+		return existing, false, nil
+	}
+	required.SetResourceVersion(existing.GetResourceVersion())
+	// the uid works as a precondition of the update, the update fails if the resource is recreated
+	if len(expectedUID) != 0 {
+		required.SetUID(expectedUID)
+	}
+	actual, err := a.dynamicClient.Resource(gvr).Namespace(required.GetNamespace()).Update(
+		ctx, required, metav1.UpdateOptions{})
+	recorder.Eventf(fmt.Sprintf(
+		"%s Updated", required.GetKind()), "Updated %s/%s", required.GetNamespace(), required.GetName())
+	return actual, true, err
+}
+
+// withOwner merges the owner into the owner references declared by the manifest if it is not nil. The reference of
+// the manifest to the same object as the owner is replaced by the owner.
+func withOwner(obj *unstructured.Unstructured, owner *metav1.OwnerReference) {
+	if owner == nil {
+		return
+	}
+
+	owners := []metav1.OwnerReference{}
+	for _, ownerRef := range obj.GetOwnerReferences() {
+		if ownerRef.APIVersion == owner.APIVersion && ownerRef.Kind == owner.Kind && ownerRef.Name == owner.Name {
+			continue
+		}
+		owners = append(owners, ownerRef)
+	}
+	obj.SetOwnerReferences(append(owners, *owner))
+}
+
+// isDecodeError is to check if the error returned from resourceapply is due to that the object cannot
+// be decoded or no typed client can handle the object.
+func isDecodeError(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "cannot decode")
+}
+
+// isUnhandledError is to check if the error returned from resourceapply is due to that no typed
+// client can handle the object
+func isUnhandledError(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "unhandled type")
+}
+
+// isUnsupportedError is to check if the error returned from resourceapply is due to
+// the PR https://github.com/openshift/library-go/pull/1042
+func isUnsupportedError(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "unsupported object type")
+}
+
+// isSameUnstructured compares the two unstructured object.
+// The comparison ignores the metadata and status field, and check if the two objects are semantically equal.
+func isSameUnstructured(obj1, obj2 *unstructured.Unstructured) bool {
+	obj1Copy := obj1.DeepCopy()
+	obj2Copy := obj2.DeepCopy()
+
+	// Compare gvk, name, namespace at first
+	if obj1Copy.GroupVersionKind() != obj2Copy.GroupVersionKind() {
+		return false
+	}
+	if obj1Copy.GetName() != obj2Copy.GetName() {
+		return false
+	}
+	if obj1Copy.GetNamespace() != obj2Copy.GetNamespace() {
+		return false
+	}
+
+	// Compare label and annotations
+	if !equality.Semantic.DeepEqual(obj1Copy.GetLabels(), obj2Copy.GetLabels()) {
+		return false
+	}
+	if !equality.Semantic.DeepEqual(obj1Copy.GetAnnotations(), obj2Copy.GetAnnotations()) {
+		return false
+	}
+	if !equality.Semantic.DeepEqual(obj1Copy.GetOwnerReferences(), obj2Copy.GetOwnerReferences()) {
+		return false
+	}
+
+	// Compare semantically after removing metadata and status field
+	delete(obj1Copy.Object, "metadata")
+	delete(obj2Copy.Object, "metadata")
+	delete(obj1Copy.Object, "status")
+	delete(obj2Copy.Object, "status")
+
+	return equality.Semantic.DeepEqual(obj1Copy.Object, obj2Copy.Object)
+}
@@ -0,0 +1,591 @@
+package applier
+
+import (
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"testing"
+
+	eventstesting "github.com/openshift/library-go/pkg/operator/events/eventstesting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/diff"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+// Test unstructured compare
+func TestIsSameUnstructured(t *testing.T) {
+	cases := []struct {
+		name     string
+		obj1     *unstructured.Unstructured
+		obj2     *unstructured.Unstructured
+		expected bool
+	}{
+		{
+			name:     "different kind",
+			obj1:     spoketesting.NewUnstructured("v1", "Kind1", "ns1", "n1"),
+			obj2:     spoketesting.NewUnstructured("v1", "Kind2", "ns1", "n1"),
+			expected: false,
+		},
+		{
+			name:     "different namespace",
+			obj1:     spoketesting.NewUnstructured("v1", "Kind1", "ns1", "n1"),
+			obj2:     spoketesting.NewUnstructured("v1", "Kind1", "ns2", "n1"),
+			expected: false,
+		},
+		{
+			name:     "different name",
+			obj1:     spoketesting.NewUnstructured("v1", "Kind1", "ns1", "n1"),
+			obj2:     spoketesting.NewUnstructured("v1", "Kind1", "ns1", "n2"),
+			expected: false,
+		},
+		{
+			name:     "different spec",
+			obj1:     spoketesting.NewUnstructuredWithContent("v1", "Kind1", "ns1", "n1", map[string]interface{}{"spec": map[string]interface{}{"key1": "val1"}}),
+			obj2:     spoketesting.NewUnstructuredWithContent("v1", "Kind1", "ns1", "n1", map[string]interface{}{"spec": map[string]interface{}{"key1": "val2"}}),
+			expected: false,
+		},
+		{
+			name:     "same spec, different status",
+			obj1:     spoketesting.NewUnstructuredWithContent("v1", "Kind1", "ns1", "n1", map[string]interface{}{"spec": map[string]interface{}{"key1": "val1"}, "status": "status1"}),
+			obj2:     spoketesting.NewUnstructuredWithContent("v1", "Kind1", "ns1", "n1", map[string]interface{}{"spec": map[string]interface{}{"key1": "val1"}, "status": "status2"}),
+			expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := isSameUnstructured(c.obj1, c.obj2)
+			if c.expected != actual {
+				t.Errorf("expected %t, but %t", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestBuildResourceMeta(t *testing.T) {
+	var secret *corev1.Secret
+	var u *unstructured.Unstructured
+
+	cases := []struct {
+		name       string
+		object     runtime.Object
+		restMapper meta.RESTMapper
+		expected   workapiv1.ManifestResourceMeta
+	}{
+		{
+			name:     "build meta for non-unstructured object",
+			object:   spoketesting.NewSecret("test", "ns1", "value2"),
+			expected: workapiv1.ManifestResourceMeta{Version: "v1", Kind: "Secret", Namespace: "ns1", Name: "test"},
+		},
+		{
+			name:       "build meta for non-unstructured object with rest mapper",
+			object:     spoketesting.NewSecret("test", "ns1", "value2"),
+			restMapper: spoketesting.NewFakeRestMapper(),
+			expected:   workapiv1.ManifestResourceMeta{Version: "v1", Kind: "Secret", Resource: "secrets", Namespace: "ns1", Name: "test"},
+		},
+		{
+			name:     "build meta for non-unstructured nil",
+			object:   secret,
+			expected: workapiv1.ManifestResourceMeta{},
+		},
+		{
+			name:     "build meta for unstructured object",
+			object:   spoketesting.NewUnstructured("v1", "Kind1", "ns1", "n1"),
+			expected: workapiv1.ManifestResourceMeta{Version: "v1", Kind: "Kind1", Namespace: "ns1", Name: "n1"},
+		},
+		{
+			name:       "build meta for unstructured object with rest mapper",
+			object:     spoketesting.NewUnstructured("v1", "NewObject", "ns1", "n1"),
+			restMapper: spoketesting.NewFakeRestMapper(),
+			expected:   workapiv1.ManifestResourceMeta{Version: "v1", Kind: "NewObject", Resource: "newobjects", Namespace: "ns1", Name: "n1"},
+		},
+		{
+			name:     "build meta for unstructured nil",
+			object:   u,
+			expected: workapiv1.ManifestResourceMeta{},
+		},
+		{
+			name:     "build meta with nil",
+			object:   nil,
+			expected: workapiv1.ManifestResourceMeta{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, _, err := buildResourceMeta(0, c.object, c.restMapper)
+			if err != nil {
+				t.Errorf("Should be success with no err: %v", err)
+			}
+
+			actual.Ordinal = c.expected.Ordinal
+			if !equality.Semantic.DeepEqual(actual, c.expected) {
+				t.Errorf(diff.ObjectDiff(actual, c.expected))
+			}
+		})
+	}
+}
+
+func TestBuildManifestResourceMeta(t *testing.T) {
+	cases := []struct {
+		name           string
+		applyResult    runtime.Object
+		manifestObject runtime.Object
+		restMapper     meta.RESTMapper
+		expected       workapiv1.ManifestResourceMeta
+	}{
+		{
+			name:           "fall back to manifest",
+			manifestObject: spoketesting.NewSecret("test2", "ns2", "value2"),
+			restMapper:     spoketesting.NewFakeRestMapper(),
+			expected:       workapiv1.ManifestResourceMeta{Version: "v1", Kind: "Secret", Resource: "secrets", Namespace: "ns2", Name: "test2"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			manifest := workapiv1.Manifest{}
+			if c.manifestObject != nil {
+				manifest.Object = c.manifestObject
+			}
+			actual, _, err := NewApplier(nil, nil, nil, c.restMapper).ResourceMeta(0, manifest)
+			if err != nil {
+				t.Errorf("Should be success with no err: %v", err)
+			}
+
+			actual.Ordinal = c.expected.Ordinal
+			if !equality.Semantic.DeepEqual(actual, c.expected) {
+				t.Errorf(diff.ObjectDiff(actual, c.expected))
+			}
+		})
+	}
+}
+
+func TestBuildManifestResourceMetaDecodeError(t *testing.T) {
+	manifest := workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: []byte("{")}}
+	actual, _, err := NewApplier(nil, nil, nil, spoketesting.NewFakeRestMapper()).ResourceMeta(1, manifest)
+	var aggregate utilerrors.Aggregate
+	if !goerrors.As(err, &aggregate) || len(aggregate.Errors()) != 1 {
+		t.Errorf("expected the aggregated decode error, but got %v", err)
+	}
+	if actual.Ordinal != 1 {
+		t.Errorf("expected ordinal 1, but got %d", actual.Ordinal)
+	}
+}
+
+func TestApplyUnstructred(t *testing.T) {
+	cases := []struct {
+		name            string
+		owner           metav1.OwnerReference
+		existingObject  []runtime.Object
+		required        *unstructured.Unstructured
+		gvr             schema.GroupVersionResource
+		validateActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:           "create a new object with owner",
+			existingObject: []runtime.Object{},
+			owner:          metav1.OwnerReference{Name: "test", UID: "testowner"},
+			required:       spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
+			gvr:            schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 2 {
+					t.Errorf("Expect 2 actions, but have %d", len(actions))
+				}
+
+				spoketesting.AssertAction(t, actions[0], "get")
+				spoketesting.AssertAction(t, actions[1], "create")
+
+				obj := actions[1].(clienttesting.CreateActionImpl).Object.(*unstructured.Unstructured)
+				owners := obj.GetOwnerReferences()
+				if len(owners) != 1 {
+					t.Errorf("Expect 2 owners, but have %d", len(owners))
+				}
+
+				if owners[0].UID != "testowner" {
+					t.Errorf("Owner UId is not correct, got %s", owners[0].UID)
+				}
+			},
+		},
+		{
+			name:           "create a new object with the owners of the manifest",
+			existingObject: []runtime.Object{},
+			owner:          metav1.OwnerReference{Name: "test", UID: "testowner"},
+			required: spoketesting.NewUnstructured("v1", "Secret", "ns1", "test",
+				metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "parent", UID: "parent"},
+				metav1.OwnerReference{Name: "test", UID: "stale"}),
+			gvr: schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 2 {
+					t.Errorf("Expect 2 actions, but have %d", len(actions))
+				}
+
+				spoketesting.AssertAction(t, actions[0], "get")
+				spoketesting.AssertAction(t, actions[1], "create")
+
+				obj := actions[1].(clienttesting.CreateActionImpl).Object.(*unstructured.Unstructured)
+				owners := obj.GetOwnerReferences()
+				if len(owners) != 2 {
+					t.Fatalf("Expect 2 owners, but have %d", len(owners))
+				}
+				if owners[0].UID != "parent" {
+					t.Errorf("Owner UId is not correct, got %s", owners[0].UID)
+				}
+				if owners[1].UID != "testowner" {
+					t.Errorf("Owner UId is not correct, got %s", owners[1].UID)
+				}
+			},
+		},
+		{
+			name:           "create a new object without owner",
+			existingObject: []runtime.Object{},
+			owner:          metav1.OwnerReference{Name: "test", UID: "testowner-"},
+			required:       spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
+			gvr:            schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 2 {
+					t.Errorf("Expect 2 actions, but have %d", len(actions))
+				}
+
+				spoketesting.AssertAction(t, actions[0], "get")
+				spoketesting.AssertAction(t, actions[1], "create")
+
+				obj := actions[1].(clienttesting.CreateActionImpl).Object.(*unstructured.Unstructured)
+				owners := obj.GetOwnerReferences()
+				if len(owners) != 0 {
+					t.Errorf("Expect 1 owners, but have %d", len(owners))
+				}
+			},
+		},
+		{
+			name:           "update an object owner",
+			existingObject: []runtime.Object{spoketesting.NewUnstructured("v1", "Secret", "ns1", "test", metav1.OwnerReference{Name: "test1", UID: "testowner1"})},
+			owner:          metav1.OwnerReference{Name: "test", UID: "testowner"},
+			required:       spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
+			gvr:            schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 2 {
+					t.Errorf("Expect 2 actions, but have %d", len(actions))
+				}
+
+				spoketesting.AssertAction(t, actions[0], "get")
+				spoketesting.AssertAction(t, actions[1], "update")
+
+				obj := actions[1].(clienttesting.UpdateActionImpl).Object.(*unstructured.Unstructured)
+				owners := obj.GetOwnerReferences()
+				if len(owners) != 2 {
+					t.Errorf("Expect 2 owners, but have %d", len(owners))
+				}
+
+				if owners[0].UID != "testowner1" {
+					t.Errorf("Owner UId is not correct, got %s", owners[0].UID)
+				}
+				if owners[1].UID != "testowner" {
+					t.Errorf("Owner UId is not correct, got %s", owners[1].UID)
+				}
+			},
+		},
+		{
+			name:           "update an object without owner",
+			existingObject: []runtime.Object{spoketesting.NewUnstructured("v1", "Secret", "ns1", "test", metav1.OwnerReference{Name: "test1", UID: "testowner1"})},
+			owner:          metav1.OwnerReference{Name: "test", UID: "testowner-"},
+			required:       spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
+			gvr:            schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Errorf("Expect 1 actions, but have %d", len(actions))
+				}
+
+				spoketesting.AssertAction(t, actions[0], "get")
+			},
+		},
+		{
+			name:           "remove an object owner",
+			existingObject: []runtime.Object{spoketesting.NewUnstructured("v1", "Secret", "ns1", "test", metav1.OwnerReference{Name: "test", UID: "testowner"})},
+			owner:          metav1.OwnerReference{Name: "test", UID: "testowner-"},
+			required:       spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
+			gvr:            schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 2 {
+					t.Errorf("Expect 2 actions, but have %d", len(actions))
+				}
+
+				spoketesting.AssertAction(t, actions[0], "get")
+				spoketesting.AssertAction(t, actions[1], "update")
+
+				obj := actions[1].(clienttesting.UpdateActionImpl).Object.(*unstructured.Unstructured)
+				owners := obj.GetOwnerReferences()
+				if len(owners) != 0 {
+					t.Errorf("Expect 0 owner, but have %d", len(owners))
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), c.existingObject...)
+			applier := NewApplier(nil, nil, dynamicClient, spoketesting.NewFakeRestMapper())
+
+			data, _ := json.Marshal(c.required)
+			_, _, err := applier.applyUnstructured(
+				context.TODO(), data, &c.owner, c.gvr, "", eventstesting.NewTestingEventRecorder(t))
+
+			if err != nil {
+				t.Errorf("expect no error, but got %v", err)
+			}
+
+			c.validateActions(t, dynamicClient.Actions())
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	owner := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "testowner"}
+	manifests := []workapiv1.Manifest{
+		newManifest(t, spoketesting.NewUnstructuredSecret("ns1", "test", false, "")),
+		newManifest(t, spoketesting.NewUnstructured("v1", "NewObject", "ns1", "test")),
+		newManifest(t, spoketesting.NewUnstructured("v1", "UnknownKind", "ns1", "test")),
+		{RawExtension: runtime.RawExtension{Raw: []byte("invalid")}},
+	}
+
+	kubeClient := fakekube.NewSimpleClientset()
+	dynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme())
+	applier := NewApplier(kubeClient, nil, dynamicClient, spoketesting.NewFakeRestMapper())
+
+	conditions, appliedResources, err := applier.Apply(context.TODO(), manifests, Options{Owner: &owner})
+	if err == nil {
+		t.Errorf("expected the errors of the manifests failing to apply")
+	}
+	if len(conditions) != len(manifests) {
+		t.Fatalf("expected %d manifest conditions, but got %d", len(manifests), len(conditions))
+	}
+	for index, expected := range []metav1.ConditionStatus{metav1.ConditionTrue, metav1.ConditionTrue, metav1.ConditionFalse, metav1.ConditionFalse} {
+		if conditions[index].ResourceMeta.Ordinal != int32(index) {
+			t.Errorf("expected ordinal %d, but got %d", index, conditions[index].ResourceMeta.Ordinal)
+		}
+		if !meta.IsStatusConditionPresentAndEqual(conditions[index].Conditions, string(workapiv1.ManifestApplied), expected) {
+			t.Errorf("expected manifest %d applied %s, but got %v", index, expected, conditions[index].Conditions)
+		}
+	}
+
+	expectedResources := []workapiv1.AppliedManifestResourceMeta{
+		{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "test"},
+		{Version: "v1", Resource: "newobjects", Namespace: "ns1", Name: "test"},
+	}
+	for index := range appliedResources {
+		appliedResources[index].UID = ""
+	}
+	if !equality.Semantic.DeepEqual(appliedResources, expectedResources) {
+		t.Errorf(diff.ObjectDiff(appliedResources, expectedResources))
+	}
+
+	secret, err := kubeClient.CoreV1().Secrets("ns1").Get(context.TODO(), "test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].UID != owner.UID {
+		t.Errorf("expected the owner set on the secret, but got %v", secret.OwnerReferences)
+	}
+	spoketesting.AssertAction(t, dynamicClient.Actions()[len(dynamicClient.Actions())-1], "create")
+}
+
+func TestApplyResourceServerSide(t *testing.T) {
+	owner := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "testowner"}
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "newobjects"}
+	existing := spoketesting.NewUnstructured("v1", "NewObject", "ns1", "test")
+	existing.SetResourceVersion("1")
+
+	cases := []struct {
+		name            string
+		resourceVersion string
+		expectedChanged bool
+	}{
+		{
+			name:            "resource changed by the apply",
+			resourceVersion: "2",
+			expectedChanged: true,
+		},
+		{
+			name:            "resource unchanged by the apply",
+			resourceVersion: "1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), existing)
+			// the fake client does not support server side apply
+			dynamicClient.PrependReactor("patch", "newobjects", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				applied := &unstructured.Unstructured{}
+				if err := applied.UnmarshalJSON(action.(clienttesting.PatchAction).GetPatch()); err != nil {
+					return true, nil, err
+				}
+				applied.SetResourceVersion(c.resourceVersion)
+				return true, applied, nil
+			})
+			applier := NewApplier(nil, nil, dynamicClient, spoketesting.NewFakeRestMapper())
+
+			actual, changed, err := applier.ApplyResourceServerSide(context.TODO(), newManifest(t, existing), gvr, &owner, "",
+				"work-agent", eventstesting.NewTestingEventRecorder(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != c.expectedChanged {
+				t.Errorf("expected changed %t, but got %t", c.expectedChanged, changed)
+			}
+			if len(actual.GetOwnerReferences()) != 1 || actual.GetOwnerReferences()[0].UID != owner.UID {
+				t.Errorf("expected the owner applied, but got %v", actual.GetOwnerReferences())
+			}
+
+			patch := dynamicClient.Actions()[1].(clienttesting.PatchActionImpl)
+			if patch.GetPatchType() != types.ApplyPatchType {
+				t.Errorf("expected server side apply, but got patch type %s", patch.GetPatchType())
+			}
+		})
+	}
+}
+
+func newManifest(t *testing.T, obj *unstructured.Unstructured) workapiv1.Manifest {
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: data}}
+}
+
+func TestApplyResourceUIDPrecondition(t *testing.T) {
+	newConfigMap := func(uid string) *unstructured.Unstructured {
+		obj := spoketesting.NewUnstructuredWithContent("v1", "ConfigMap", "ns1", "test",
+			map[string]interface{}{"data": map[string]interface{}{"key": "value"}})
+		obj.SetUID(types.UID(uid))
+		return obj
+	}
+	newSecret := func(uid string) *unstructured.Unstructured {
+		obj := spoketesting.NewUnstructuredSecret("ns1", "test", false, uid)
+		obj.Object["data"] = map[string]interface{}{"key": "dmFsdWU="}
+		return obj
+	}
+	newObject := func(uid string) *unstructured.Unstructured {
+		obj := spoketesting.NewUnstructured("v1", "NewObject", "ns1", "test")
+		obj.SetUID(types.UID(uid))
+		return obj
+	}
+	toTyped := func(obj *unstructured.Unstructured, typed runtime.Object) runtime.Object {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, typed); err != nil {
+			t.Fatal(err)
+		}
+		return typed
+	}
+
+	cases := []struct {
+		name          string
+		gvr           schema.GroupVersionResource
+		existing      *unstructured.Unstructured
+		typed         runtime.Object
+		required      *unstructured.Unstructured
+		serverSide    bool
+		expectedWrite bool
+	}{
+		{
+			name:     "typed client with the recreated resource",
+			gvr:      schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			existing: newConfigMap("uid2"),
+			typed:    &corev1.ConfigMap{},
+			required: spoketesting.NewUnstructuredWithContent("v1", "ConfigMap", "ns1", "test",
+				map[string]interface{}{"data": map[string]interface{}{"key": "changed"}}),
+		},
+		{
+			name:     "typed client with the expected resource",
+			gvr:      schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			existing: newConfigMap("uid1"),
+			typed:    &corev1.ConfigMap{},
+			required: spoketesting.NewUnstructuredWithContent("v1", "ConfigMap", "ns1", "test",
+				map[string]interface{}{"data": map[string]interface{}{"key": "changed"}}),
+			expectedWrite: true,
+		},
+		{
+			name:     "secret recreated",
+			gvr:      schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			existing: newSecret("uid2"),
+			typed:    &corev1.Secret{},
+			required: spoketesting.NewUnstructuredSecret("ns1", "test", false, ""),
+		},
+		{
+			name:       "server side apply with the recreated resource",
+			gvr:        schema.GroupVersionResource{Version: "v1", Resource: "newobjects"},
+			existing:   newObject("uid2"),
+			required:   spoketesting.NewUnstructured("v1", "NewObject", "ns1", "test"),
+			serverSide: true,
+		},
+		{
+			name:          "server side apply with the expected resource",
+			gvr:           schema.GroupVersionResource{Version: "v1", Resource: "newobjects"},
+			existing:      newObject("uid1"),
+			required:      spoketesting.NewUnstructured("v1", "NewObject", "ns1", "test"),
+			serverSide:    true,
+			expectedWrite: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			existing := c.existing.DeepCopy()
+			kubeObjects := []runtime.Object{}
+			if c.typed != nil {
+				kubeObjects = append(kubeObjects, toTyped(existing, c.typed))
+			}
+			kubeClient := fakekube.NewSimpleClientset(kubeObjects...)
+			dynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), existing)
+			// the fake client does not support server side apply
+			dynamicClient.PrependReactor("patch", "newobjects", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				applied := &unstructured.Unstructured{}
+				if err := applied.UnmarshalJSON(action.(clienttesting.PatchAction).GetPatch()); err != nil {
+					return true, nil, err
+				}
+				if applied.GetUID() != "uid1" {
+					t.Errorf("expected the uid precondition applied, but got %q", applied.GetUID())
+				}
+				return true, applied, nil
+			})
+			applier := NewApplier(kubeClient, nil, dynamicClient, spoketesting.NewFakeRestMapper())
+			recorder := eventstesting.NewTestingEventRecorder(t)
+
+			var err error
+			if c.serverSide {
+				_, _, err = applier.ApplyResourceServerSide(context.TODO(), newManifest(t, c.required), c.gvr, nil, "uid1", "work-agent", recorder)
+			} else {
+				_, _, err = applier.ApplyResource(context.TODO(), newManifest(t, c.required), c.gvr, nil, "uid1", recorder)
+			}
+			if c.expectedWrite && err != nil {
+				t.Fatal(err)
+			}
+			if !c.expectedWrite && !goerrors.Is(err, applyerrors.ErrResourceConflict) {
+				t.Errorf("expected a resource conflict error, but got %v", err)
+			}
+
+			written := false
+			for _, action := range append(kubeClient.Actions(), dynamicClient.Actions()...) {
+				if action.GetVerb() != "get" && action.GetVerb() != "list" && action.GetVerb() != "watch" {
+					written = true
+				}
+			}
+			if written != c.expectedWrite {
+				t.Errorf("expected the resource written %t, but got actions %v %v", c.expectedWrite, kubeClient.Actions(), dynamicClient.Actions())
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package applier
+
+import (
+	goerrors "errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/helper"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+// AppliedCondition returns the Applied condition of a manifest applied with the error, a nil error means the
+// manifest is applied. The detail is appended to "manifest" in the message, e.g. the source of the manifest. The
+// reason of a failure tells whether the apply timed out, the reason of a applyerrors.ResourceError, whether the kind is not
+// served by the cluster yet, an immutable resource is changed, the request is denied by an admission webhook, or the class of the error returned by
+// helper.ClassifyApplyError. The name of the webhook denying the request is formatted in the message by
+// helper.FormatAdmissionWebhookDenial.
+func AppliedCondition(err error, detail string) metav1.Condition {
+	var timeoutErr *helper.ApplyTimeoutError
+	var immutableErr *helper.ImmutableResourceError
+	var resourceErr *applyerrors.ResourceError
+	switch {
+	case err == nil:
+		return metav1.Condition{
+			Type:    string(workapiv1.ManifestApplied),
+			Status:  metav1.ConditionTrue,
+			Reason:  "AppliedManifestComplete",
+			Message: fmt.Sprintf("Apply manifest complete%s", detail),
+		}
+	case goerrors.As(err, &timeoutErr):
+		return FailedAppliedCondition("ApplyTimedOut", err, detail)
+	case goerrors.As(err, &resourceErr):
+		return FailedAppliedCondition(resourceErr.Reason(), err, detail)
+	case helper.IsAPIVersionNotAvailableError(err):
+		return FailedAppliedCondition("APIVersionNotAvailable", err, detail)
+	case goerrors.As(err, &immutableErr):
+		return FailedAppliedCondition("ImmutableResourceChanged", err, detail)
+	}
+	if denial, ok := helper.AdmissionWebhookDenialOf(err); ok {
+		return metav1.Condition{
+			Type:    string(workapiv1.ManifestApplied),
+			Status:  metav1.ConditionFalse,
+			Reason:  helper.AdmissionWebhookDeniedReason,
+			Message: helper.FormatAdmissionWebhookDenial(fmt.Sprintf("Failed to apply manifest%s", detail), denial),
+		}
+	}
+	return FailedAppliedCondition(fmt.Sprintf("AppliedManifestFailed%s", helper.ClassifyApplyError(err)), err, detail)
+}
+
+// FailedAppliedCondition returns the Applied condition of a manifest failing to apply with the reason, the detail
+// is appended to "manifest" in the message.
+func FailedAppliedCondition(reason string, err error, detail string) metav1.Condition {
+	return metav1.Condition{
+		Type:    string(workapiv1.ManifestApplied),
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: fmt.Sprintf("Failed to apply manifest%s: %v", detail, err),
+	}
+}
@@ -0,0 +1,97 @@
+package applier
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"open-cluster-management.io/work/pkg/helper"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+func TestAppliedCondition(t *testing.T) {
+	webhookDenial := &errors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    403,
+		Reason:  metav1.StatusReasonForbidden,
+		Message: `admission webhook "validate.policy.io" denied the request: replicas must be less than 3`,
+	}}
+
+	cases := []struct {
+		name            string
+		err             error
+		expectedStatus  metav1.ConditionStatus
+		expectedReason  string
+		expectedMessage string
+	}{
+		{
+			name:            "applied",
+			expectedStatus:  metav1.ConditionTrue,
+			expectedReason:  "AppliedManifestComplete",
+			expectedMessage: "Apply manifest complete from configmap ns1/cm1",
+		},
+		{
+			name:           "timed out",
+			err:            &helper.ApplyTimeoutError{Err: fmt.Errorf("context deadline exceeded"), Timeout: time.Second},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "ApplyTimedOut",
+		},
+		{
+			name:           "api version not available",
+			err:            &meta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "test.io", Kind: "Foo"}},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "APIVersionNotAvailable",
+		},
+		{
+			name:           "immutable resource changed",
+			err:            &helper.ImmutableResourceError{Resource: "Secret ns1/test", Fields: []string{"data"}},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "ImmutableResourceChanged",
+		},
+		{
+			name: "resource conflict",
+			err: fmt.Errorf("failed to apply: %w", applyerrors.NewResourceConflictError(
+				schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, "ns1", "test",
+				errors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, "test", fmt.Errorf("recreated")))),
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "ResourceConflict",
+		},
+		{
+			name: "manifest invalid",
+			err: applyerrors.NewValidationFailedError(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, "ns1", "test",
+				errors.NewBadRequest("namespace conflicts")),
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "ManifestInvalid",
+		},
+		{
+			name:            "denied by webhook",
+			err:             fmt.Errorf("failed to update: %w", webhookDenial),
+			expectedStatus:  metav1.ConditionFalse,
+			expectedReason:  helper.AdmissionWebhookDeniedReason,
+			expectedMessage: "[webhook=validate.policy.io] Failed to apply manifest from configmap ns1/cm1: replicas must be less than 3",
+		},
+		{
+			name:           "forbidden",
+			err:            errors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "test", fmt.Errorf("not allowed")),
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "AppliedManifestFailedRetryable",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			condition := AppliedCondition(c.err, " from configmap ns1/cm1")
+			if condition.Status != c.expectedStatus || condition.Reason != c.expectedReason {
+				t.Errorf("expected condition %s/%s, but got %s/%s", c.expectedStatus, c.expectedReason, condition.Status, condition.Reason)
+			}
+			if len(c.expectedMessage) > 0 && condition.Message != c.expectedMessage {
+				t.Errorf("expected message %q, but got %q", c.expectedMessage, condition.Message)
+			}
+		})
+	}
+}
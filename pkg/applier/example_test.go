@@ -0,0 +1,52 @@
+package applier_test
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/applier"
+)
+
+func ExampleApplier_Apply() {
+	// the rest mapper resolves the resources of the manifests, it is usually built from the discovery of the cluster
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	a := applier.NewApplier(fakekube.NewSimpleClientset(), nil,
+		fakedynamic.NewSimpleDynamicClient(runtime.NewScheme()), restMapper)
+
+	manifests := []workapiv1.Manifest{
+		{RawExtension: runtime.RawExtension{Raw: []byte(
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"namespace": "default", "name": "config"}, "data": {"key": "value"}}`)}},
+		{RawExtension: runtime.RawExtension{Raw: []byte(
+			`{"apiVersion": "example.io/v1", "kind": "Unknown", "metadata": {"namespace": "default", "name": "unknown"}}`)}},
+	}
+
+	// the resources applied are owned by the owner, so that they are deleted by the garbage collector with the owner
+	owner := &metav1.OwnerReference{APIVersion: "v1", Kind: "Namespace", Name: "owner", UID: "owner-uid"}
+	conditions, appliedResources, err := a.Apply(context.TODO(), manifests, applier.Options{Owner: owner})
+
+	for _, condition := range conditions {
+		applied := meta.FindStatusCondition(condition.Conditions, string(workapiv1.ManifestApplied))
+		fmt.Printf("manifest %d %s/%s: %s %s\n", condition.ResourceMeta.Ordinal,
+			condition.ResourceMeta.Kind, condition.ResourceMeta.Name, applied.Status, applied.Reason)
+	}
+	for _, resource := range appliedResources {
+		fmt.Printf("applied %s %s/%s\n", resource.Resource, resource.Namespace, resource.Name)
+	}
+	// the errors of the manifests failing to apply are aggregated
+	fmt.Println(err)
+	// Output:
+	// manifest 0 ConfigMap/config: True AppliedManifestComplete
+	// manifest 1 Unknown/unknown: False APIVersionNotAvailable
+	// applied configmaps default/config
+	// manifest 1: the server doesn't have a resource type "Unknown": no matches for kind "Unknown" in version "example.io/v1"
+}
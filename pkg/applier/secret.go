@@ -0,0 +1,216 @@
+package applier
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	coreclientv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+// NormalizeSecret converts the stringData of the secret in the manifest into data, which is how the secret is stored
+// by the apiserver, so that a manifest with stringData is hashed and compared the same as the equivalent manifest
+// with data. The manifest is returned as is if it is not a secret with stringData, or it cannot be decoded. An
+// error is returned if a key of stringData conflicts with the same key of data.
+func NormalizeSecret(manifest workapiv1.Manifest) (workapiv1.Manifest, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+		return manifest, nil
+	}
+	normalized, err := normalizeSecret(obj)
+	if !normalized || err != nil {
+		return manifest, err
+	}
+
+	raw, err := obj.MarshalJSON()
+	if err != nil {
+		return manifest, fmt.Errorf("failed to encode the normalized secret: %w", err)
+	}
+	return workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}}, nil
+}
+
+// normalizeSecret converts the stringData of the secret into data, it returns false if the object is not a secret
+// with stringData.
+func normalizeSecret(obj *unstructured.Unstructured) (bool, error) {
+	if obj.GetAPIVersion() != "v1" || obj.GetKind() != "Secret" {
+		return false, nil
+	}
+	stringData, found, err := unstructured.NestedStringMap(obj.Object, "stringData")
+	if !found || err != nil {
+		return false, nil
+	}
+
+	data, _, err := unstructured.NestedStringMap(obj.Object, "data")
+	if err != nil {
+		return false, err
+	}
+	if data == nil {
+		data = map[string]string{}
+	}
+	for key, value := range stringData {
+		encoded := base64.StdEncoding.EncodeToString([]byte(value))
+		if existing, ok := data[key]; ok && existing != encoded {
+			return false, fmt.Errorf("Secret.stringData[%q] conflicts with Secret.data[%q]", key, key)
+		}
+		data[key] = encoded
+	}
+	unstructured.RemoveNestedField(obj.Object, "stringData")
+	return true, unstructured.SetNestedStringMap(obj.Object, data, "data")
+}
+
+// applySecret applies the required secret. The data and the immutable flag of an immutable secret cannot be
+// changed, so the secret is deleted and created again if it is required by the annotation UpdateStrategyAnnotationKey
+// of the manifest, otherwise an ImmutableResourceError is returned. A mutable secret is marked as immutable once it
+// is updated if it is required. The existing secret is not written if its UID is not the expectedUID.
+func (a *Applier) applySecret(
+	ctx context.Context,
+	required *unstructured.Unstructured,
+	gvr schema.GroupVersionResource,
+	expectedUID types.UID,
+	recorder events.Recorder) (*corev1.Secret, bool, error) {
+	// the stringData is converted into data beforehand, so that the data is compared with the existing secret
+	if _, err := normalizeSecret(required); err != nil {
+		return nil, false, err
+	}
+	secrets := a.kubeClient.CoreV1().Secrets(required.GetNamespace())
+	existing, err := secrets.Get(ctx, required.GetName(), metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, false, err
+	}
+	if err == nil {
+		// the secret fetched is updated with its resource version, so it is the one checked
+		if uidErr := verifyExistingUID(gvr, existing, expectedUID); uidErr != nil {
+			return nil, false, uidErr
+		}
+		// keep the type and the data injected on the cluster, e.g. into a service account token secret
+		existingObj, convertErr := runtime.DefaultUnstructuredConverter.ToUnstructured(existing)
+		if convertErr != nil {
+			return nil, false, convertErr
+		}
+		preserveServerManagedFields(gvr, required, &unstructured.Unstructured{Object: existingObj})
+	}
+
+	requiredSecret := &corev1.Secret{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(required.Object, requiredSecret); err != nil {
+		return nil, false, fmt.Errorf("cannot decode secret: %w", err)
+	}
+	// the secret fetched is reused by resourceapply.ApplySecret
+	client := &fetchedSecretGetter{SecretsGetter: a.kubeClient.CoreV1(), secret: existing, err: err}
+
+	if err == nil && isImmutable(existing.Immutable) {
+		// resourceapply.ApplySecret recreates the secret silently once the update is rejected as immutable
+		if fields := immutableSecretChanges(requiredSecret, existing); len(fields) > 0 {
+			if requiredSecret.Annotations[constants.UpdateStrategyAnnotationKey] != constants.UpdateStrategyRecreate {
+				return nil, false, &helper.ImmutableResourceError{
+					Resource: fmt.Sprintf("Secret %s/%s", existing.Namespace, existing.Name),
+					Fields:   fields,
+				}
+			}
+			return a.recreateSecret(ctx, requiredSecret, existing, recorder)
+		}
+	}
+
+	actual, changed, err := resourceapply.ApplySecret(ctx, client, recorder, requiredSecret)
+	if err != nil || isImmutable(actual.Immutable) || !isImmutable(requiredSecret.Immutable) {
+		return actual, changed, err
+	}
+
+	// the immutable flag is not applied to an existing secret by resourceapply.ApplySecret
+	actual = actual.DeepCopy()
+	actual.Immutable = requiredSecret.Immutable
+	actual, err = secrets.Update(ctx, actual, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	recorder.Eventf("SecretUpdated", "Marked Secret/%s -n %s immutable", actual.Name, actual.Namespace)
+	return actual, true, nil
+}
+
+// fetchedSecretGetter returns the secret fetched already, or the error fetching it, on the first Get of the secret,
+// so that the secret is not fetched twice.
+type fetchedSecretGetter struct {
+	coreclientv1.SecretsGetter
+	secret *corev1.Secret
+	err    error
+}
+
+func (g *fetchedSecretGetter) Secrets(namespace string) coreclientv1.SecretInterface {
+	return &fetchedSecretClient{SecretInterface: g.SecretsGetter.Secrets(namespace), getter: g}
+}
+
+type fetchedSecretClient struct {
+	coreclientv1.SecretInterface
+	getter *fetchedSecretGetter
+}
+
+func (c *fetchedSecretClient) Get(ctx context.Context, name string, options metav1.GetOptions) (*corev1.Secret, error) {
+	if c.getter.secret == nil && c.getter.err == nil {
+		return c.SecretInterface.Get(ctx, name, options)
+	}
+	secret, err := c.getter.secret, c.getter.err
+	c.getter.secret, c.getter.err = nil, nil
+	return secret, err
+}
+
+// recreateSecret deletes the existing secret and creates the required one. The uid of the existing secret is the
+// precondition of the deletion, so that a secret recreated by others is not deleted.
+func (a *Applier) recreateSecret(
+	ctx context.Context,
+	required, existing *corev1.Secret,
+	recorder events.Recorder) (*corev1.Secret, bool, error) {
+	err := a.kubeClient.CoreV1().Secrets(existing.Namespace).Delete(ctx, existing.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &existing.UID},
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, false, fmt.Errorf("failed to delete immutable Secret %s/%s to recreate it: %w", existing.Namespace, existing.Name, err)
+	}
+	recorder.Eventf("SecretRecreated", "Deleted immutable Secret/%s -n %s to recreate it", existing.Name, existing.Namespace)
+	return resourceapply.ApplySecret(ctx, a.kubeClient.CoreV1(), recorder, required)
+}
+
+// immutableSecretChanges returns the immutable fields of the existing secret changed by the required secret. The
+// keys of the data injected into a service account token secret are not required to be in the required secret.
+func immutableSecretChanges(required, existing *corev1.Secret) []string {
+	fields := []string{}
+
+	requiredType := required.Type
+	if len(requiredType) == 0 {
+		requiredType = corev1.SecretTypeOpaque
+	}
+	if requiredType != existing.Type {
+		fields = append(fields, "type")
+	}
+
+	dataChanged := len(required.Data) != len(existing.Data) && required.Type != corev1.SecretTypeServiceAccountToken
+	for key, value := range required.Data {
+		if existingValue, ok := existing.Data[key]; !ok || !bytes.Equal(value, existingValue) {
+			dataChanged = true
+		}
+	}
+	if dataChanged {
+		fields = append(fields, "data")
+	}
+
+	if !isImmutable(required.Immutable) {
+		fields = append(fields, "immutable")
+	}
+	return fields
+}
+
+func isImmutable(immutable *bool) bool {
+	return immutable != nil && *immutable
+}
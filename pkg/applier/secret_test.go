@@ -0,0 +1,230 @@
+package applier
+
+import (
+	"context"
+	goerrors "errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	eventstesting "github.com/openshift/library-go/pkg/operator/events/eventstesting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+func TestNormalizeSecret(t *testing.T) {
+	cases := []struct {
+		name        string
+		manifest    string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "stringData",
+			manifest: `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"ns1"},"stringData":{"key1":"value1"}}`,
+			expected: `{"apiVersion":"v1","data":{"key1":"dmFsdWUx"},"kind":"Secret","metadata":{"name":"test","namespace":"ns1"}}`,
+		},
+		{
+			name: "stringData and data",
+			manifest: `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"ns1"},` +
+				`"data":{"key1":"dmFsdWUx"},"stringData":{"key1":"value1","key2":"value2"}}`,
+			expected: `{"apiVersion":"v1","data":{"key1":"dmFsdWUx","key2":"dmFsdWUy"},"kind":"Secret","metadata":{"name":"test","namespace":"ns1"}}`,
+		},
+		{
+			name:     "data only",
+			manifest: `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"ns1"},"data":{"key1":"dmFsdWUx"}}`,
+			expected: `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"ns1"},"data":{"key1":"dmFsdWUx"}}`,
+		},
+		{
+			name:     "not a secret",
+			manifest: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","namespace":"ns1"},"stringData":{"key1":"value1"}}`,
+			expected: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","namespace":"ns1"},"stringData":{"key1":"value1"}}`,
+		},
+		{
+			name: "conflict",
+			manifest: `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"ns1"},` +
+				`"data":{"key1":"dmFsdWUx"},"stringData":{"key1":"value2"}}`,
+			expectedErr: `Secret.stringData["key1"] conflicts with Secret.data["key1"]`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			manifest, err := NormalizeSecret(workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: []byte(c.manifest)}})
+			switch {
+			case len(c.expectedErr) > 0:
+				if err == nil || err.Error() != c.expectedErr {
+					t.Errorf("expected error %q, but got %v", c.expectedErr, err)
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(string(manifest.Raw)) != c.expected {
+				t.Errorf("expected manifest %s, but got %s", c.expected, string(manifest.Raw))
+			}
+		})
+	}
+
+	// the manifests with stringData and with data are normalized into the same manifest
+	withStringData, _ := NormalizeSecret(workapiv1.Manifest{RawExtension: runtime.RawExtension{
+		Raw: []byte(`{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test"},"stringData":{"key1":"value1"}}`)}})
+	withData, _ := NormalizeSecret(workapiv1.Manifest{RawExtension: runtime.RawExtension{
+		Raw: []byte(`{"kind":"Secret","apiVersion":"v1","metadata":{"name":"test"},"data":{"key1":"dmFsdWUx"},"stringData":{}}`)}})
+	if string(withStringData.Raw) != string(withData.Raw) {
+		t.Errorf("expected the equivalent manifests normalized the same, but got %s and %s", withStringData.Raw, withData.Raw)
+	}
+}
+
+func newSecret(data string, immutable bool) *corev1.Secret {
+	secret := &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "test", UID: "test-uid"},
+		Data:       map[string][]byte{"key1": []byte(data)},
+		Type:       corev1.SecretTypeOpaque,
+	}
+	if immutable {
+		secret.Immutable = &immutable
+	}
+	return secret
+}
+
+func TestApplySecretImmutable(t *testing.T) {
+	secretsGVR := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+	cases := []struct {
+		name            string
+		existing        *corev1.Secret
+		required        *corev1.Secret
+		recreate        bool
+		expectedVerbs   []string
+		expectedChanged bool
+		expectedFields  []string
+	}{
+		{
+			name:            "mark immutable",
+			existing:        newSecret("value1", false),
+			required:        newSecret("value1", true),
+			expectedVerbs:   []string{"get", "update"},
+			expectedChanged: true,
+		},
+		{
+			name:          "immutable unchanged",
+			existing:      newSecret("value1", true),
+			required:      newSecret("value1", true),
+			expectedVerbs: []string{"get"},
+		},
+		{
+			name:           "data of immutable changed",
+			existing:       newSecret("value1", true),
+			required:       newSecret("value2", true),
+			expectedVerbs:  []string{"get"},
+			expectedFields: []string{"data"},
+		},
+		{
+			name:           "immutable unset",
+			existing:       newSecret("value1", true),
+			required:       newSecret("value1", false),
+			expectedVerbs:  []string{"get"},
+			expectedFields: []string{"immutable"},
+		},
+		{
+			name:     "type of immutable changed",
+			existing: newSecret("value1", true),
+			required: func() *corev1.Secret {
+				secret := newSecret("value1", true)
+				secret.Type = "example.com/token"
+				return secret
+			}(),
+			expectedVerbs:  []string{"get"},
+			expectedFields: []string{"type"},
+		},
+		{
+			name:            "data of immutable changed with recreate",
+			existing:        newSecret("value1", true),
+			required:        newSecret("value2", true),
+			recreate:        true,
+			expectedVerbs:   []string{"get", "delete", "get", "create"},
+			expectedChanged: true,
+		},
+		{
+			name:            "immutable unset with recreate",
+			existing:        newSecret("value1", true),
+			required:        newSecret("value1", false),
+			recreate:        true,
+			expectedVerbs:   []string{"get", "delete", "get", "create"},
+			expectedChanged: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := fakekube.NewSimpleClientset(c.existing)
+			// the apiserver rejects the changes of the data of immutable secrets
+			kubeClient.PrependReactor("update", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				existing, _ := kubeClient.Tracker().Get(secretsGVR, "ns1", "test")
+				secret := action.(clienttesting.UpdateAction).GetObject().(*corev1.Secret)
+				if isImmutable(existing.(*corev1.Secret).Immutable) && !reflect.DeepEqual(secret.Data, existing.(*corev1.Secret).Data) {
+					return true, nil, errors.NewInvalid(schema.GroupKind{Kind: "Secret"}, "test", field.ErrorList{
+						field.Forbidden(field.NewPath("data"), "field is immutable when `immutable` is set"),
+					})
+				}
+				return false, nil, nil
+			})
+
+			required := c.required.DeepCopy()
+			required.UID = ""
+			if c.recreate {
+				required.Annotations = map[string]string{constants.UpdateStrategyAnnotationKey: constants.UpdateStrategyRecreate}
+			}
+			data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(required)
+			if err != nil {
+				t.Fatal(err)
+			}
+			manifest := newManifest(t, &unstructured.Unstructured{Object: data})
+
+			applier := NewApplier(kubeClient, nil, nil, nil)
+			obj, changed, err := applier.ApplyResource(context.TODO(), manifest, secretsGVR, nil, "", eventstesting.NewTestingEventRecorder(t))
+
+			verbs := []string{}
+			for _, action := range kubeClient.Actions() {
+				verbs = append(verbs, action.GetVerb())
+			}
+			if !reflect.DeepEqual(verbs, c.expectedVerbs) {
+				t.Errorf("expected actions %v, but got %v", c.expectedVerbs, verbs)
+			}
+
+			if len(c.expectedFields) > 0 {
+				var immutableErr *helper.ImmutableResourceError
+				if !goerrors.As(err, &immutableErr) || !reflect.DeepEqual(immutableErr.Fields, c.expectedFields) {
+					t.Fatalf("expected immutable fields %v changed, but got %v", c.expectedFields, err)
+				}
+				if !strings.Contains(err.Error(), "Secret ns1/test is immutable") {
+					t.Errorf("unexpected error message %q", err.Error())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != c.expectedChanged {
+				t.Errorf("expected changed %t, but got %t", c.expectedChanged, changed)
+			}
+			actual := obj.(*corev1.Secret)
+			if !reflect.DeepEqual(actual.Data, c.required.Data) || isImmutable(actual.Immutable) != isImmutable(c.required.Immutable) {
+				t.Errorf("expected secret %v immutable %t, but got %v immutable %t",
+					c.required.Data, isImmutable(c.required.Immutable), actual.Data, isImmutable(actual.Immutable))
+			}
+		})
+	}
+}
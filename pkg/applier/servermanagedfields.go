@@ -0,0 +1,103 @@
+package applier
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	serviceGroupResource        = schema.GroupResource{Resource: "services"}
+	serviceAccountGroupResource = schema.GroupResource{Resource: "serviceaccounts"}
+	secretGroupResource         = schema.GroupResource{Resource: "secrets"}
+)
+
+// serverManagedServiceFields are the fields of the spec of a service allocated or defaulted by the apiserver.
+var serverManagedServiceFields = []string{
+	"clusterIP", "clusterIPs", "ipFamilies", "ipFamilyPolicy", "healthCheckNodePort",
+	"sessionAffinity", "internalTrafficPolicy",
+}
+
+// serverManagedServicePortFields are the fields of a port of a service allocated or defaulted by the apiserver.
+var serverManagedServicePortFields = []string{"nodePort", "protocol", "targetPort"}
+
+// serviceAccountTokenDataKeys are the keys of the data of a service account token secret injected by the
+// token controller.
+var serviceAccountTokenDataKeys = []string{"ca.crt", "namespace", "token"}
+
+// preserveServerManagedFields copies the fields populated by the apiserver or the controllers on the managed
+// cluster from the existing object into the required object if they are not set in the required object, so that
+// they are neither wiped on update nor reported as a difference. Only services, serviceaccounts and secrets are
+// handled, the type of a secret is immutable so it is copied as well.
+func preserveServerManagedFields(gvr schema.GroupVersionResource, required, existing *unstructured.Unstructured) {
+	switch gvr.GroupResource() {
+	case serviceGroupResource:
+		for _, field := range serverManagedServiceFields {
+			preserveField(required.Object, existing.Object, "spec", field)
+		}
+		preserveServicePorts(required, existing)
+	case serviceAccountGroupResource:
+		preserveField(required.Object, existing.Object, "secrets")
+	case secretGroupResource:
+		preserveField(required.Object, existing.Object, "type")
+		if secretType, _, _ := unstructured.NestedString(required.Object, "type"); secretType == "kubernetes.io/service-account-token" {
+			for _, key := range serviceAccountTokenDataKeys {
+				preserveField(required.Object, existing.Object, "data", key)
+			}
+		}
+	}
+}
+
+// preserveServicePorts copies the server managed fields of the ports of the existing service into the ports of
+// the required service with the same port number.
+func preserveServicePorts(required, existing *unstructured.Unstructured) {
+	requiredPorts, found, err := unstructured.NestedSlice(required.Object, "spec", "ports")
+	if !found || err != nil {
+		return
+	}
+	existingPorts, _, err := unstructured.NestedSlice(existing.Object, "spec", "ports")
+	if err != nil {
+		return
+	}
+
+	for _, requiredPort := range requiredPorts {
+		requiredPortMap, ok := requiredPort.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, existingPort := range existingPorts {
+			existingPortMap, ok := existingPort.(map[string]interface{})
+			if !ok || !isSameServicePort(requiredPortMap, existingPortMap) {
+				continue
+			}
+			for _, field := range serverManagedServicePortFields {
+				preserveField(requiredPortMap, existingPortMap, field)
+			}
+			break
+		}
+	}
+	_ = unstructured.SetNestedSlice(required.Object, requiredPorts, "spec", "ports")
+}
+
+// isSameServicePort checks if the two ports have the same port number and protocol, which defaults to TCP.
+func isSameServicePort(port1, port2 map[string]interface{}) bool {
+	protocol := func(port map[string]interface{}) interface{} {
+		if protocol, ok := port["protocol"]; ok {
+			return protocol
+		}
+		return "TCP"
+	}
+	return port1["port"] == port2["port"] && protocol(port1) == protocol(port2)
+}
+
+// preserveField copies the field from the existing object into the required object if it is not set in the
+// required object.
+func preserveField(required, existing map[string]interface{}, fields ...string) {
+	if _, found, _ := unstructured.NestedFieldNoCopy(required, fields...); found {
+		return
+	}
+	value, found, _ := unstructured.NestedFieldCopy(existing, fields...)
+	if !found {
+		return
+	}
+	_ = unstructured.SetNestedField(required, value, fields...)
+}
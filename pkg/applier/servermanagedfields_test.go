@@ -0,0 +1,137 @@
+package applier
+
+import (
+	"context"
+	"testing"
+
+	eventstesting "github.com/openshift/library-go/pkg/operator/events/eventstesting"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func newObjectFromJSON(t *testing.T, data string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestPreserveServerManagedFields(t *testing.T) {
+	owner := metav1.OwnerReference{Name: "test", UID: "testowner"}
+	cases := []struct {
+		name           string
+		gvr            schema.GroupVersionResource
+		required       string
+		existing       string
+		expectedUpdate bool
+	}{
+		{
+			name: "service",
+			gvr:  schema.GroupVersionResource{Version: "v1", Resource: "services"},
+			required: `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "svc1", "namespace": "ns1"},
+				"spec": {"type": "NodePort", "selector": {"app": "a"}, "ports": [{"port": 80}]}}`,
+			existing: `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "svc1", "namespace": "ns1"},
+				"spec": {"type": "NodePort", "selector": {"app": "a"}, "clusterIP": "10.0.0.1", "clusterIPs": ["10.0.0.1"],
+				"ipFamilies": ["IPv4"], "ipFamilyPolicy": "SingleStack", "sessionAffinity": "None",
+				"ports": [{"port": 80, "protocol": "TCP", "targetPort": 80, "nodePort": 30080}]}}`,
+		},
+		{
+			name: "service with port changed",
+			gvr:  schema.GroupVersionResource{Version: "v1", Resource: "services"},
+			required: `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "svc1", "namespace": "ns1"},
+				"spec": {"selector": {"app": "a"}, "ports": [{"port": 8080}]}}`,
+			existing: `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "svc1", "namespace": "ns1"},
+				"spec": {"selector": {"app": "a"}, "clusterIP": "10.0.0.1", "ports": [{"port": 80, "protocol": "TCP", "targetPort": 80}]}}`,
+			expectedUpdate: true,
+		},
+		{
+			name:     "serviceaccount",
+			gvr:      schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"},
+			required: `{"apiVersion": "v1", "kind": "ServiceAccount", "metadata": {"name": "sa1", "namespace": "ns1"}}`,
+			existing: `{"apiVersion": "v1", "kind": "ServiceAccount", "metadata": {"name": "sa1", "namespace": "ns1"},
+				"secrets": [{"name": "sa1-token-abcde"}]}`,
+		},
+		{
+			name:     "secret",
+			gvr:      schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			required: `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "s1", "namespace": "ns1"}, "data": {"a": "Yg=="}}`,
+			existing: `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "s1", "namespace": "ns1"},
+				"type": "Opaque", "data": {"a": "Yg=="}}`,
+		},
+		{
+			name: "service account token secret",
+			gvr:  schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			required: `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "s1", "namespace": "ns1"},
+				"type": "kubernetes.io/service-account-token"}`,
+			existing: `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "s1", "namespace": "ns1"},
+				"type": "kubernetes.io/service-account-token", "data": {"ca.crt": "Yw==", "namespace": "bnMx", "token": "dA=="}}`,
+		},
+		{
+			name: "service account token secret without type",
+			gvr:  schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			required: `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "s1", "namespace": "ns1",
+				"annotations": {"kubernetes.io/service-account.name": "sa1"}}}`,
+			existing: `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "s1", "namespace": "ns1",
+				"annotations": {"kubernetes.io/service-account.name": "sa1"}},
+				"type": "kubernetes.io/service-account-token", "data": {"ca.crt": "Yw==", "namespace": "bnMx", "token": "dA=="}}`,
+		},
+		{
+			name:     "secret with data changed",
+			gvr:      schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			required: `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "s1", "namespace": "ns1"}, "data": {"a": "Yw=="}}`,
+			existing: `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "s1", "namespace": "ns1"},
+				"type": "Opaque", "data": {"a": "Yg=="}}`,
+			expectedUpdate: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			existing := newObjectFromJSON(t, c.existing)
+			existing.SetOwnerReferences([]metav1.OwnerReference{owner})
+			// the secrets are applied with the typed client, the others with the dynamic client
+			var kubeObjects, dynamicObjects []runtime.Object
+			if c.gvr.GroupResource() == secretGroupResource {
+				secret := &corev1.Secret{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(existing.Object, secret); err != nil {
+					t.Fatal(err)
+				}
+				kubeObjects = append(kubeObjects, secret)
+			} else {
+				dynamicObjects = append(dynamicObjects, existing)
+			}
+			kubeClient := fakekube.NewSimpleClientset(kubeObjects...)
+			dynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), dynamicObjects...)
+			applier := NewApplier(kubeClient, nil, dynamicClient, spoketesting.NewFakeRestMapper())
+
+			manifest := newManifest(t, newObjectFromJSON(t, c.required))
+			_, changed, err := applier.ApplyResource(context.TODO(), manifest, c.gvr, &owner, "", eventstesting.NewTestingEventRecorder(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != c.expectedUpdate {
+				t.Errorf("expected changed %v, but got %v", c.expectedUpdate, changed)
+			}
+
+			actions := append(kubeClient.Actions(), dynamicClient.Actions()...)
+			if c.expectedUpdate {
+				if len(actions) != 2 {
+					t.Fatalf("expected 2 actions, but got %v", actions)
+				}
+				spoketesting.AssertAction(t, actions[1], "update")
+				return
+			}
+			if len(actions) != 1 {
+				t.Fatalf("expected no update, but got %v", actions)
+			}
+			spoketesting.AssertAction(t, actions[0], "get")
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package hub
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/library-go/pkg/controller/controllercmd"
+
+	"open-cluster-management.io/work/pkg/hub"
+	"open-cluster-management.io/work/pkg/version"
+)
+
+// NewHubManager generates a command to start the work hub manager
+func NewHubManager() *cobra.Command {
+	o := hub.NewManagerOptions()
+	cmd := controllercmd.
+		NewControllerCommandConfig("work-manager", version.Get(), o.RunManager).
+		NewCommand()
+	cmd.Use = "manager"
+	cmd.Short = "Start the Work Hub Manager"
+
+	o.AddFlags(cmd)
+	return cmd
+}
@@ -6,11 +6,13 @@ import (
 	admissionserver "github.com/openshift/generic-admission-server/pkg/cmd/server"
 	"github.com/spf13/cobra"
 	genericapiserver "k8s.io/apiserver/pkg/server"
+	"open-cluster-management.io/work/pkg/helper"
 	"open-cluster-management.io/work/pkg/webhook"
 )
 
 func NewAdmissionHook() *cobra.Command {
-	o := admissionserver.NewAdmissionServerOptions(os.Stdout, os.Stderr, &webhook.ManifestWorkAdmissionHook{})
+	admissionHook := &webhook.ManifestWorkAdmissionHook{MaxManifests: helper.DefaultMaxManifests}
+	o := admissionserver.NewAdmissionServerOptions(os.Stdout, os.Stderr, admissionHook)
 
 	cmd := &cobra.Command{
 		Use:   "webhook",
@@ -32,6 +34,9 @@ func NewAdmissionHook() *cobra.Command {
 	}
 
 	o.RecommendedOptions.AddFlags(cmd.Flags())
+	cmd.Flags().IntVar(&admissionHook.MaxManifests, "max-manifests", admissionHook.MaxManifests,
+		"Max number of manifests of a ManifestWork, the ManifestWorks with more manifests are rejected. It is not limited if "+
+			"it is not positive.")
 
 	return cmd
 }
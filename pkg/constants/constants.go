@@ -0,0 +1,247 @@
+// Package constants contains the finalizers, annotations, condition types and event reasons shared by the agent, the
+// hub controllers and the webhook. It imports nothing, so that it can be shared without importing the controllers.
+package constants
+
+const (
+	// ManifestWorkFinalizer is the name of the finalizer added to manifestworks. It is used to ensure
+	// related appliedmanifestwork of a manifestwork are deleted before the manifestwork itself is deleted
+	ManifestWorkFinalizer = "cluster.open-cluster-management.io/manifest-work-cleanup"
+	// AppliedManifestWorkFinalizer is the name of the finalizer added to appliedmanifestwork. It is to
+	// ensure all resource relates to appliedmanifestwork is deleted before appliedmanifestwork itself
+	// is deleted.
+	AppliedManifestWorkFinalizer = "cluster.open-cluster-management.io/applied-manifest-work-cleanup"
+	// ManifestWorkHubCleanupFinalizer is the name of the finalizer added to manifestworks by the hub manager. It is
+	// removed once the agent reports the cleanup of the manifestwork is completed with the condition
+	// WorkCleanupCompleted, or the cleanup times out, e.g. the agent is offline.
+	ManifestWorkHubCleanupFinalizer = "cluster.open-cluster-management.io/manifest-work-hub-cleanup"
+
+	// WorkObsoleteResourcesPending is the condition type of manifestwork which indicates that some resources
+	// removed from the manifestwork cannot be pruned on the managed cluster.
+	WorkObsoleteResourcesPending = "ObsoleteResourcesPending"
+	// WorkPruneRequiresConfirmation is the condition type of manifestwork which indicates that the resources removed
+	// from the manifestwork are not pruned, since pruning them exceeds the prune threshold of the agent, e.g. the
+	// manifests are emptied by mistake. The message tells how many resources would be pruned, they are pruned once
+	// the count is confirmed with the annotation PruneConfirmationAnnotationKey.
+	WorkPruneRequiresConfirmation = "PruneRequiresConfirmation"
+
+	// AgentIDAnnotationKey is the annotation key on appliedmanifestwork recording the id of the agent
+	// instance which owns the appliedmanifestwork.
+	AgentIDAnnotationKey = "work.open-cluster-management.io/agent-id"
+	// HeartbeatAnnotationKey is the annotation key on appliedmanifestwork recording the last time the owning
+	// agent instance confirmed it is still alive, in RFC3339 format.
+	HeartbeatAnnotationKey = "work.open-cluster-management.io/last-heartbeat-time"
+	// DeletionBlockedByDependentsAnnotationKey is the annotation key on a terminating appliedmanifestwork recording
+	// the crds whose deletion is held, because their custom resources are owned by other appliedmanifestworks and
+	// would be deleted by the apiserver as well, together with the names of those appliedmanifestworks. The deletion
+	// of the crds is not held if ForceDeletionAnnotationKey is set to "true" on the appliedmanifestwork.
+	DeletionBlockedByDependentsAnnotationKey = "work.open-cluster-management.io/deletion-blocked-by-dependents"
+	ForceDeletionAnnotationKey               = "work.open-cluster-management.io/force-deletion"
+	// DeletionDeniedByWebhookAnnotationKey is the annotation key on a terminating appliedmanifestwork recording the
+	// denial of the deletion of its applied resources by an admission webhook of the spoke apiserver. The value is
+	// formatted with the name of the webhook by helper.FormatAdmissionWebhookDenial.
+	DeletionDeniedByWebhookAnnotationKey = "work.open-cluster-management.io/deletion-denied-by-webhook"
+
+	// DefaultDeletePropagationPolicyAnnotationKey is the annotation key on appliedmanifestwork recording the default
+	// delete propagation policy of the agent when the appliedmanifestwork is created. It is the delete propagation
+	// policy of the manifestwork if the manifestwork does not specify a deleteOption, so that the deletion of the
+	// resources is not changed by the option of the agent after they are applied. The policy is Foreground if the
+	// annotation is not set.
+	DefaultDeletePropagationPolicyAnnotationKey = "work.open-cluster-management.io/default-delete-propagation-policy"
+
+	// AppliedResourceHealthAnnotationKey is the annotation key on appliedmanifestwork recording the health of
+	// each applied resource mirrored from the availability check, so that it can be inspected on the managed
+	// cluster without looking up the manifestwork on the hub. The value is a JSON list.
+	AppliedResourceHealthAnnotationKey = "work.open-cluster-management.io/applied-resource-health"
+
+	// AppliedSpecHashAnnotationKey is the annotation key on appliedmanifestwork recording the hash of the spec and
+	// annotations of the manifestwork once all its manifests are applied. It is used to skip the first reconcile of
+	// the unchanged manifestworks after the agent starts.
+	AppliedSpecHashAnnotationKey = "work.open-cluster-management.io/applied-spec-hash"
+
+	// AppliedManifestPatchesAnnotationKey is the annotation key on appliedmanifestwork recording the patches with a
+	// revertPatch applied by the manifestwork, so that they are reverted once they are dropped from the manifestwork.
+	// The value is a JSON list.
+	AppliedManifestPatchesAnnotationKey = "work.open-cluster-management.io/applied-manifest-patches"
+
+	// AppliedServerSideApplyResourcesAnnotationKey is the annotation key on appliedmanifestwork recording the resources
+	// applied with server side apply by the manifestwork, so that the field ownership of a resource is migrated once
+	// its update strategy is changed. The value is a JSON list.
+	AppliedServerSideApplyResourcesAnnotationKey = "work.open-cluster-management.io/applied-server-side-apply-resources"
+
+	// EventFingerprintAnnotationKey is the annotation key on appliedmanifestwork recording the fingerprint of the
+	// last apply events of the manifestwork. The events are emitted only if the fingerprint is changed, so that the
+	// same events are not emitted again once the agent restarts.
+	EventFingerprintAnnotationKey = "work.open-cluster-management.io/event-fingerprint"
+
+	// CompletionRulesAnnotationKey is the annotation key on manifestwork defining when the manifestwork is completed,
+	// it is used by run-to-completion payloads like jobs. The value is a JSON list of completion rules, e.g.
+	// [{"ordinal": 0, "conditionType": "Complete"}], the manifestwork is completed once the status conditions
+	// of the resources of all the listed manifests with the given types are true.
+	CompletionRulesAnnotationKey = "work.open-cluster-management.io/completion-rules"
+	// ResetCompletionOnUpdateAnnotationKey is the annotation key on manifestwork which allows to reset the completion
+	// of the manifestwork once its spec is changed if it is set to "true".
+	ResetCompletionOnUpdateAnnotationKey = "work.open-cluster-management.io/reset-completion-on-update"
+
+	// PruneConfirmationAnnotationKey is the annotation key on manifestwork confirming the prune of the resources
+	// removed from the manifestwork which exceeds the prune threshold of the agent. The value is the number of
+	// resources confirmed to be pruned, e.g. "2", a prune of more resources than confirmed still requires a new
+	// confirmation.
+	PruneConfirmationAnnotationKey = "work.open-cluster-management.io/prune-confirmation"
+
+	// OrphaningSelectorsAnnotationKey is the annotation key on manifestwork defining the orphaning rules selecting the
+	// resources by labels, which are evaluated against the live resources once they are deleted, in addition to the
+	// orphaning rules of the SelectivelyOrphan deleteOption. The value is a JSON list, e.g.
+	// [{"group": "", "resource": "configmaps", "namespace": "*", "selector": {"matchLabels": {"keep": "true"}}}].
+	// A rule cannot have both the name and the selector set, and the orphaning rules with names take precedence.
+	OrphaningSelectorsAnnotationKey = "work.open-cluster-management.io/orphaning-selectors"
+
+	// ManifestWorkLabelKey and ManifestWorkNamespaceLabelKey are the provenance labels on the applied resources
+	// recording the name and namespace of the manifestwork which applies the resource.
+	ManifestWorkLabelKey          = "work.open-cluster-management.io/manifestwork"
+	ManifestWorkNamespaceLabelKey = "work.open-cluster-management.io/manifestwork-namespace"
+	// HubHashAnnotationKey and ManifestWorkUIDAnnotationKey are the provenance annotations on the applied resources
+	// recording the hash of the hub and the uid of the manifestwork. The hub hash is longer than the max length of
+	// label values, so it is an annotation.
+	HubHashAnnotationKey         = "work.open-cluster-management.io/hub-hash"
+	ManifestWorkUIDAnnotationKey = "work.open-cluster-management.io/manifestwork-uid"
+	// DisableProvenanceAnnotationKey is the annotation key on manifestwork which disables the provenance labels and
+	// annotations on its applied resources if it is set to "true".
+	DisableProvenanceAnnotationKey = "work.open-cluster-management.io/disable-provenance"
+
+	// ManifestSubresourcesAnnotationKey is the annotation key on manifestwork defining the manifests which are applied
+	// to a subresource of an existing resource instead of the resource itself. The value is a JSON list, e.g.
+	// [{"ordinal": 0, "subresource": "scale"}], the supported subresources are scale and status. The resource is not
+	// created or owned by the manifestwork.
+	ManifestSubresourcesAnnotationKey = "work.open-cluster-management.io/manifest-subresources"
+
+	// ManifestConfigOptionsAnnotationKey is the annotation key on manifestwork defining how its manifests are applied.
+	// With the update strategy Patch, the manifest is the patch document of an existing resource identified by the
+	// option instead of an object. The value is a JSON list, e.g. [{"ordinal": 0, "resourceIdentifier": {"version":
+	// "v1", "resource": "configmaps", "namespace": "ns1", "name": "cm1"}, "updateStrategy": {"type": "Patch",
+	// "patchType": "MergePatch", "revertPatch": {...}}}], the supported patch types are JSONPatch, MergePatch and
+	// StrategicMergePatch. The patched resource is neither created nor owned by the manifestwork, it is only
+	// reverted with the revertPatch once the manifestwork is deleted or the patch is dropped from it if it is set.
+	// With the update strategy ServerSideApply, the manifest is applied with server side apply. The fields set by the
+	// agent are adopted by the apply once the update strategy is changed to it, and released once it is changed back.
+	ManifestConfigOptionsAnnotationKey = "work.open-cluster-management.io/manifest-config-options"
+
+	// ManifestApplyTimeoutsAnnotationKey is the annotation key on manifestwork overriding the apply timeout of its
+	// manifests, which defaults to the option of the agent. The value is a JSON list, e.g.
+	// [{"ordinal": 0, "timeout": "30s"}].
+	ManifestApplyTimeoutsAnnotationKey = "work.open-cluster-management.io/manifest-apply-timeouts"
+
+	// ManifestDependenciesAnnotationKey is the annotation key on manifestwork declaring the manifests which a manifest
+	// depends on, by ordinal or by the group, kind, namespace and name of the manifests. The value is a JSON list, e.g.
+	// [{"ordinal": 1, "dependsOn": [{"ordinal": 0}, {"group": "apps", "kind": "Deployment", "namespace": "ns1", "name": "web"}]}].
+	// A manifest is not applied with the reason WaitingForDependency until the manifests it depends on are available,
+	// and it is not gated any more once it is applied. The manifestwork is rejected if the dependencies have a cycle.
+	// The manifestwork is marked Degraded once the manifests wait for longer than the duration of
+	// DependencyDeadlineAnnotationKey, e.g. "10m", they are not marked if it is not set.
+	ManifestDependenciesAnnotationKey = "work.open-cluster-management.io/manifest-dependencies"
+	DependencyDeadlineAnnotationKey   = "work.open-cluster-management.io/dependency-deadline"
+
+	// NamespaceOverrideAnnotationKey is the annotation key on manifestwork which rewrites the namespace of all its
+	// namespaced manifests to the value before they are applied, the cluster scoped manifests are untouched. A
+	// manifest with a different namespace is reported as a conflict unless NamespaceOverrideForceAnnotationKey is
+	// set to "true". A manifest opts out of the override with the annotation KeepNamespaceAnnotationKey set to "true".
+	NamespaceOverrideAnnotationKey      = "work.open-cluster-management.io/namespace-override"
+	NamespaceOverrideForceAnnotationKey = "work.open-cluster-management.io/namespace-override-force"
+	KeepNamespaceAnnotationKey          = "work.open-cluster-management.io/keep-namespace"
+
+	// AllowedNamespacesAnnotationKey is the annotation key on manifestwork declaring the comma separated namespaces
+	// which its manifests are allowed to be applied to, within the allowed namespaces of the agent if they are set as
+	// well. The namespaced manifests without a namespace are applied to the first allowed namespace, and the manifests
+	// of the other namespaces are rejected with the reason NamespaceNotPermittedForExecutor. The cluster scoped
+	// manifests are rejected as well unless AllowClusterScopedAnnotationKey is set to "true".
+	AllowedNamespacesAnnotationKey  = "work.open-cluster-management.io/allowed-namespaces"
+	AllowClusterScopedAnnotationKey = "work.open-cluster-management.io/allow-cluster-scoped"
+
+	// PausedAnnotationKey is the annotation key on manifestwork which freezes the reconciliation of the manifestwork,
+	// e.g. during incident response. With the value PausedAnnotationValue, the manifests are not applied, pruned or
+	// checked for availability, while the manifestwork is still finalized once it is deleted. With the value
+	// PausedDeletionAnnotationValue, the finalization of the manifestwork is paused as well.
+	PausedAnnotationKey           = "work.open-cluster-management.io/paused"
+	PausedAnnotationValue         = "true"
+	PausedDeletionAnnotationValue = "paused-deletion"
+
+	// ResyncRequestAnnotationKey is the annotation key on manifestwork requesting the agent to apply all its
+	// manifests again, even if they are not changed since they were applied, e.g. once the RBAC of the agent is
+	// fixed. The value is opaque to the agent, e.g. a timestamp, a new value is a new request. The request handled
+	// is recorded in the condition WorkResyncRequestHandled of the manifestwork.
+	ResyncRequestAnnotationKey = "work.open-cluster-management.io/resync-request"
+
+	// DriftPolicyAnnotationKey is the annotation key on manifestwork defining how the modifications of its applied
+	// resources out of band are handled. With DriftPolicyReportOnly, which is the default, the drifted resources are
+	// reported with the condition Degraded. With DriftPolicyRemediate, the manifestwork is applied again as well.
+	DriftPolicyAnnotationKey = "work.open-cluster-management.io/drift-policy"
+	DriftPolicyReportOnly    = "ReportOnly"
+	DriftPolicyRemediate     = "Remediate"
+
+	// UpdateStrategyAnnotationKey is the annotation key on a manifest defining how its resource is changed if it
+	// cannot be updated in place, e.g. the data or the immutable flag of an immutable secret is changed. With
+	// UpdateStrategyRecreate, the resource is deleted and created again, otherwise the manifest fails to apply.
+	UpdateStrategyAnnotationKey = "work.open-cluster-management.io/update-strategy"
+	UpdateStrategyRecreate      = "Recreate"
+
+	// LastAppliedTimeAnnotationKey is the annotation key on manifestwork recording the last time all its manifests
+	// were applied by the agent, in RFC3339 format. LastApplyDurationAnnotationKey records how long that apply took
+	// in milliseconds. They are only updated once they change beyond the churn thresholds of the agent.
+	LastAppliedTimeAnnotationKey   = "work.open-cluster-management.io/last-applied-time"
+	LastApplyDurationAnnotationKey = "work.open-cluster-management.io/last-apply-duration-milliseconds"
+
+	// WorkCompleted is the condition type of manifestwork which indicates the completion rules of the manifestwork
+	// are met. The manifests of a completed manifestwork are not applied any more.
+	WorkCompleted = "Completed"
+	// WorkPaused is the condition type of manifestwork which indicates the reconciliation of the manifestwork is
+	// paused by the annotation PausedAnnotationKey.
+	WorkPaused = "Paused"
+	// WorkResyncRequestHandled is the condition type of manifestwork which records the last request of the annotation
+	// ResyncRequestAnnotationKey handled by the agent.
+	WorkResyncRequestHandled = "ResyncRequestHandled"
+	// WorkResourceQuotaExceededByAgentPolicy is the condition type of manifestwork which indicates some manifests of
+	// the manifestwork are not applied, since the agent has applied the max number of resources on the managed cluster.
+	WorkResourceQuotaExceededByAgentPolicy = "ResourceQuotaExceededByAgentPolicy"
+	// WorkStatusTruncated is the condition type of manifestwork which indicates the status of the manifestwork is
+	// truncated to fit in the max status size, the message of the condition explains what is dropped.
+	WorkStatusTruncated = "StatusTruncated"
+	// WorkCleanupCompleted is the condition type of a deleting manifestwork which indicates whether its applied
+	// resources are deleted on the managed cluster. It is reported by the agent if the manifestwork has the finalizer
+	// ManifestWorkHubCleanupFinalizer.
+	WorkCleanupCompleted = "CleanupCompleted"
+	// WorkOrphaningRuleUnmatched is the condition type of manifestwork which warns that some orphaning rules of the
+	// SelectivelyOrphan deleteOption match none of the resources of the manifestwork, e.g. the resource is not plural.
+	WorkOrphaningRuleUnmatched = "OrphaningRuleUnmatched"
+	// WorkSpecFeaturesNotSupported is the condition type of manifestwork which warns that the spec of the manifestwork
+	// has features not supported by the agent, e.g. the agent is older than the hub, they are ignored by the agent.
+	WorkSpecFeaturesNotSupported = "SpecFeaturesNotSupported"
+	// WorkTooManyManifests is the condition type of manifestwork which indicates the manifests of the manifestwork are
+	// not applied, since it has more manifests than the max allowed by the agent, e.g. it was created before the
+	// webhook limited the number of manifests.
+	WorkTooManyManifests = "TooManyManifests"
+	// WorkManifestDecodeError is the condition type of manifestwork which indicates some manifests of the manifestwork
+	// cannot be decoded, e.g. they are corrupted on the hub. The message names the ordinals of the manifests, the
+	// resources applied from them are kept instead of being pruned until the manifests are fixed.
+	WorkManifestDecodeError = "ManifestDecodeError"
+	// WorkProgressing is the condition type of manifestwork which indicates the manifests of the manifestwork are
+	// being applied by more than one reconcile, since applying them exceeds the apply deadline of the agent. The
+	// message tells how many manifests are applied so far, it is removed once all the manifests are applied.
+	WorkProgressing = "Progressing"
+
+	// ManifestAPIVersionDeprecated is the condition type of a manifest which warns that the apiVersion of the
+	// manifest is deprecated by the Kubernetes version of the managed cluster, with the version replacing it if
+	// known. The manifest is still applied until the apiVersion is removed, it then fails with the reason
+	// APIVersionRemovedInCluster.
+	ManifestAPIVersionDeprecated = "APIVersionDeprecated"
+)
+
+// The reasons of the events of the resources applied by manifestworks. The events are recorded with the component
+// suffixed with the hub hash and the name of the manifestwork. A resource is orphaned with EventReasonResourceOrphaned
+// once the manifestwork is deleted, and with EventReasonResourceOrphanedOnRemoval once its manifest is removed from
+// the manifestwork, by the same deleteOption.
+const (
+	EventReasonResourceApplied           = "ResourceApplied"
+	EventReasonResourceAppliedFailed     = "ResourceAppliedFailed"
+	EventReasonResourceDeleted           = "ResourceDeleted"
+	EventReasonResourceOrphaned          = "ResourceOrphaned"
+	EventReasonResourceOrphanedOnRemoval = "ResourceOrphanedOnRemoval"
+)
@@ -0,0 +1,74 @@
+package helper
+
+import (
+	goerrors "errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// AdmissionWebhookDeniedReason is the reason of the conditions reporting a request denied by an admission webhook
+// of the spoke apiserver.
+const AdmissionWebhookDeniedReason = "AdmissionWebhookDenied"
+
+// maxAdmissionWebhookMessageLength is the max length of the message of the webhook kept in the conditions, so
+// that a verbose webhook does not take the room of the other conditions of the manifestwork.
+const maxAdmissionWebhookMessageLength = 1024
+
+// admissionWebhookDenialPattern matches the message of the Status returned by the apiserver once an admission
+// webhook denies a request, with or without an explanation.
+var admissionWebhookDenialPattern = regexp.MustCompile(
+	`admission webhook "([^"]+)" denied the request(?:: (?s:(.*))| without explanation)`)
+
+// admissionWebhookMessagePattern matches the messages of the conditions reporting a webhook denial.
+var admissionWebhookMessagePattern = regexp.MustCompile(`^\[webhook=([^\]]+)\] `)
+
+// AdmissionWebhookDenial is a request denied by an admission webhook of the spoke apiserver.
+type AdmissionWebhookDenial struct {
+	// Webhook is the name of the webhook denying the request.
+	Webhook string
+	// Message is the explanation of the webhook, it is empty if the webhook does not explain the denial.
+	Message string
+}
+
+// AdmissionWebhookDenialOf returns the denial of an admission webhook parsed from the Status of the error, wrapped
+// errors are handled. False is returned if the error is not a denial of an admission webhook.
+func AdmissionWebhookDenialOf(err error) (AdmissionWebhookDenial, bool) {
+	var status errors.APIStatus
+	if !goerrors.As(err, &status) {
+		return AdmissionWebhookDenial{}, false
+	}
+	matches := admissionWebhookDenialPattern.FindStringSubmatch(status.Status().Message)
+	if matches == nil {
+		return AdmissionWebhookDenial{}, false
+	}
+	return AdmissionWebhookDenial{Webhook: matches[1], Message: matches[2]}, true
+}
+
+// FormatAdmissionWebhookDenial returns the message of a condition reporting the denial, e.g. "Failed to apply
+// manifest". The name of the webhook is put at the beginning of the message in the form of "[webhook=<name>] ",
+// so that it is kept by the consumers truncating the message and it can be parsed by AdmissionWebhookOfMessage.
+// The explanation of the webhook is truncated to a max length.
+func FormatAdmissionWebhookDenial(action string, denial AdmissionWebhookDenial) string {
+	explanation := denial.Message
+	if len(explanation) == 0 {
+		explanation = "denied without explanation"
+	}
+	if len(explanation) > maxAdmissionWebhookMessageLength {
+		// the rune cut at the max length is dropped
+		explanation = strings.ToValidUTF8(explanation[:maxAdmissionWebhookMessageLength], "") + "..."
+	}
+	return fmt.Sprintf("[webhook=%s] %s: %s", denial.Webhook, action, explanation)
+}
+
+// AdmissionWebhookOfMessage returns the name of the webhook in the message of a condition reporting the denial of
+// an admission webhook. False is returned if the message does not report a denial.
+func AdmissionWebhookOfMessage(message string) (string, bool) {
+	matches := admissionWebhookMessagePattern.FindStringSubmatch(message)
+	if matches == nil {
+		return "", false
+	}
+	return matches[1], true
+}
@@ -0,0 +1,114 @@
+package helper
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// newWebhookDenial returns the error of a request denied by an admission webhook as returned by the apiserver.
+func newWebhookDenial(code int32, message string) error {
+	return &errors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    code,
+		Reason:  metav1.StatusReasonForbidden,
+		Message: message,
+	}}
+}
+
+func TestAdmissionWebhookDenialOf(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		expected *AdmissionWebhookDenial
+	}{
+		{
+			name:     "denied with explanation",
+			err:      newWebhookDenial(403, `admission webhook "validate.policy.io" denied the request: replicas must be less than 3`),
+			expected: &AdmissionWebhookDenial{Webhook: "validate.policy.io", Message: "replicas must be less than 3"},
+		},
+		{
+			name:     "denied without explanation",
+			err:      newWebhookDenial(400, `admission webhook "validate.policy.io" denied the request without explanation`),
+			expected: &AdmissionWebhookDenial{Webhook: "validate.policy.io"},
+		},
+		{
+			name: "denied with multiline explanation",
+			err: newWebhookDenial(403,
+				"admission webhook \"validate.policy.io\" denied the request: \n[require-labels] label app is required"),
+			expected: &AdmissionWebhookDenial{Webhook: "validate.policy.io", Message: "\n[require-labels] label app is required"},
+		},
+		{
+			name: "wrapped denial",
+			err: fmt.Errorf("Failed to delete resource: %w",
+				newWebhookDenial(403, `admission webhook "deny.policy.io" denied the request: protected`)),
+			expected: &AdmissionWebhookDenial{Webhook: "deny.policy.io", Message: "protected"},
+		},
+		{
+			name: "forbidden by rbac",
+			err:  errors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "test", fmt.Errorf("not allowed")),
+		},
+		{
+			name: "not a status error",
+			err:  fmt.Errorf(`admission webhook "validate.policy.io" denied the request: not a status`),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			denial, ok := AdmissionWebhookDenialOf(c.err)
+			switch {
+			case c.expected == nil && ok:
+				t.Errorf("expected no webhook denial, but got %v", denial)
+			case c.expected != nil && !ok:
+				t.Errorf("expected webhook denial %v, but got none", *c.expected)
+			case c.expected != nil && denial != *c.expected:
+				t.Errorf("expected webhook denial %v, but got %v", *c.expected, denial)
+			}
+		})
+	}
+}
+
+func TestFormatAdmissionWebhookDenial(t *testing.T) {
+	cases := []struct {
+		name     string
+		denial   AdmissionWebhookDenial
+		expected string
+	}{
+		{
+			name:     "with explanation",
+			denial:   AdmissionWebhookDenial{Webhook: "validate.policy.io", Message: "replicas must be less than 3"},
+			expected: "[webhook=validate.policy.io] Failed to apply manifest: replicas must be less than 3",
+		},
+		{
+			name:     "without explanation",
+			denial:   AdmissionWebhookDenial{Webhook: "validate.policy.io"},
+			expected: "[webhook=validate.policy.io] Failed to apply manifest: denied without explanation",
+		},
+		{
+			name:     "truncate long explanation",
+			denial:   AdmissionWebhookDenial{Webhook: "validate.policy.io", Message: strings.Repeat("a", 2000)},
+			expected: "[webhook=validate.policy.io] Failed to apply manifest: " + strings.Repeat("a", maxAdmissionWebhookMessageLength) + "...",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			message := FormatAdmissionWebhookDenial("Failed to apply manifest", c.denial)
+			if message != c.expected {
+				t.Errorf("expected message %q, but got %q", c.expected, message)
+			}
+			if webhook, ok := AdmissionWebhookOfMessage(message); !ok || webhook != c.denial.Webhook {
+				t.Errorf("expected webhook %q parsed from the message, but got %q", c.denial.Webhook, webhook)
+			}
+		})
+	}
+
+	if webhook, ok := AdmissionWebhookOfMessage("Failed to apply manifest: conflict"); ok {
+		t.Errorf("expected no webhook parsed, but got %q", webhook)
+	}
+}
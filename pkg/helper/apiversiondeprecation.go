@@ -0,0 +1,176 @@
+package helper
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog/v2"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+// spokeVersionTTL is how long the Kubernetes version of the spoke is cached, so that an upgrade of the spoke is
+// picked up without restarting the agent.
+const spokeVersionTTL = 10 * time.Minute
+
+// APIVersionDeprecation is an apiVersion of a kind deprecated and removed by Kubernetes.
+type APIVersionDeprecation struct {
+	GroupVersion schema.GroupVersion
+	Kind         string
+	// DeprecatedIn and RemovedIn are the Kubernetes versions deprecating and removing the apiVersion, e.g. "1.21"
+	DeprecatedIn string
+	RemovedIn    string
+	// Replacement is the apiVersion replacing the removed one, it is empty if there is no replacement
+	Replacement string
+}
+
+// ReplacementMessage returns how to replace the apiVersion, e.g. "use policy/v1 instead".
+func (d APIVersionDeprecation) ReplacementMessage() string {
+	if len(d.Replacement) == 0 {
+		return "there is no replacement"
+	}
+	return fmt.Sprintf("use %s instead", d.Replacement)
+}
+
+// apiVersionDeprecations is the table of the apiVersions removed by Kubernetes which are still common in
+// manifests, see https://kubernetes.io/docs/reference/using-api/deprecation-guide/.
+var apiVersionDeprecations = []APIVersionDeprecation{
+	{GroupVersion: schema.GroupVersion{Group: "extensions", Version: "v1beta1"}, Kind: "Deployment", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "extensions", Version: "v1beta1"}, Kind: "DaemonSet", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "extensions", Version: "v1beta1"}, Kind: "ReplicaSet", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "apps", Version: "v1beta1"}, Kind: "Deployment", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "apps", Version: "v1beta2"}, Kind: "Deployment", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "apps", Version: "v1beta1"}, Kind: "StatefulSet", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "apps", Version: "v1beta2"}, Kind: "StatefulSet", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "extensions", Version: "v1beta1"}, Kind: "Ingress", DeprecatedIn: "1.14", RemovedIn: "1.22", Replacement: "networking.k8s.io/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "networking.k8s.io", Version: "v1beta1"}, Kind: "Ingress", DeprecatedIn: "1.19", RemovedIn: "1.22", Replacement: "networking.k8s.io/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "apiextensions.k8s.io", Version: "v1beta1"}, Kind: "CustomResourceDefinition", DeprecatedIn: "1.16", RemovedIn: "1.22", Replacement: "apiextensions.k8s.io/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "rbac.authorization.k8s.io", Version: "v1beta1"}, Kind: "ClusterRole", DeprecatedIn: "1.17", RemovedIn: "1.22", Replacement: "rbac.authorization.k8s.io/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "rbac.authorization.k8s.io", Version: "v1beta1"}, Kind: "ClusterRoleBinding", DeprecatedIn: "1.17", RemovedIn: "1.22", Replacement: "rbac.authorization.k8s.io/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "rbac.authorization.k8s.io", Version: "v1beta1"}, Kind: "Role", DeprecatedIn: "1.17", RemovedIn: "1.22", Replacement: "rbac.authorization.k8s.io/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "rbac.authorization.k8s.io", Version: "v1beta1"}, Kind: "RoleBinding", DeprecatedIn: "1.17", RemovedIn: "1.22", Replacement: "rbac.authorization.k8s.io/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "batch", Version: "v1beta1"}, Kind: "CronJob", DeprecatedIn: "1.21", RemovedIn: "1.25", Replacement: "batch/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "policy", Version: "v1beta1"}, Kind: "PodDisruptionBudget", DeprecatedIn: "1.21", RemovedIn: "1.25", Replacement: "policy/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "policy", Version: "v1beta1"}, Kind: "PodSecurityPolicy", DeprecatedIn: "1.21", RemovedIn: "1.25"},
+	{GroupVersion: schema.GroupVersion{Group: "discovery.k8s.io", Version: "v1beta1"}, Kind: "EndpointSlice", DeprecatedIn: "1.21", RemovedIn: "1.25", Replacement: "discovery.k8s.io/v1"},
+	{GroupVersion: schema.GroupVersion{Group: "autoscaling", Version: "v2beta1"}, Kind: "HorizontalPodAutoscaler", DeprecatedIn: "1.22", RemovedIn: "1.25", Replacement: "autoscaling/v2"},
+	{GroupVersion: schema.GroupVersion{Group: "autoscaling", Version: "v2beta2"}, Kind: "HorizontalPodAutoscaler", DeprecatedIn: "1.23", RemovedIn: "1.26", Replacement: "autoscaling/v2"},
+	{GroupVersion: schema.GroupVersion{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta1"}, Kind: "FlowSchema", DeprecatedIn: "1.23", RemovedIn: "1.26", Replacement: "flowcontrol.apiserver.k8s.io/v1beta3"},
+	{GroupVersion: schema.GroupVersion{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta1"}, Kind: "PriorityLevelConfiguration", DeprecatedIn: "1.23", RemovedIn: "1.26", Replacement: "flowcontrol.apiserver.k8s.io/v1beta3"},
+}
+
+// LookupAPIVersionDeprecation returns the deprecation of the apiVersion of the kind in the built-in table, false is
+// returned if the apiVersion is not known to be deprecated.
+func LookupAPIVersionDeprecation(gvk schema.GroupVersionKind) (APIVersionDeprecation, bool) {
+	for _, deprecation := range apiVersionDeprecations {
+		if deprecation.GroupVersion == gvk.GroupVersion() && deprecation.Kind == gvk.Kind {
+			return deprecation, true
+		}
+	}
+	return APIVersionDeprecation{}, false
+}
+
+// APIVersionChecker checks the apiVersions of the manifests against the Kubernetes version of the spoke with the
+// built-in table of deprecations, so that a manifest with an apiVersion removed by the spoke fails with a terminal
+// error instead of being retried as if the kind was not installed yet. The methods do nothing if the checker is nil.
+type APIVersionChecker struct {
+	lock            sync.Mutex
+	discoveryClient discovery.DiscoveryInterface
+	clock           clock.Clock
+	version         *utilversion.Version
+	expireAt        time.Time
+}
+
+// NewAPIVersionChecker returns an APIVersionChecker discovering the spoke with the discovery client.
+func NewAPIVersionChecker(discoveryClient discovery.DiscoveryInterface) *APIVersionChecker {
+	return &APIVersionChecker{
+		discoveryClient: discoveryClient,
+		clock:           clock.RealClock{},
+	}
+}
+
+// spokeVersion returns the Kubernetes version of the spoke, it is cached for spokeVersionTTL.
+func (c *APIVersionChecker) spokeVersion() (*utilversion.Version, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.version != nil && c.clock.Now().Before(c.expireAt) {
+		return c.version, nil
+	}
+	info, err := c.discoveryClient.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+	version, err := utilversion.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return nil, err
+	}
+	c.version, c.expireAt = version, c.clock.Now().Add(spokeVersionTTL)
+	return version, nil
+}
+
+// Removed returns a ResourceError of ErrAPIVersionRemoved wrapping the error of the mapping not found, if the
+// apiVersion of the kind is removed by the Kubernetes version of the spoke and is not served by the spoke. The
+// error is returned as is otherwise, e.g. the kind is a CR whose CRD is not installed yet.
+func (c *APIVersionChecker) Removed(gvk schema.GroupVersionKind, namespace, name string, err error) error {
+	if c == nil {
+		return err
+	}
+	deprecation, ok := LookupAPIVersionDeprecation(gvk)
+	if !ok {
+		return err
+	}
+	version, versionErr := c.spokeVersion()
+	if versionErr != nil {
+		klog.V(4).Infof("Failed to get the version of the spoke: %v", versionErr)
+		return err
+	}
+	if version.LessThan(utilversion.MustParseGeneric(deprecation.RemovedIn)) {
+		return err
+	}
+
+	// the apiVersion may still be served, e.g. by an aggregated apiserver, the mapping is retried then
+	groups, discoveryErr := c.discoveryClient.ServerGroups()
+	if discoveryErr != nil {
+		klog.V(4).Infof("Failed to discover the groups of the spoke: %v", discoveryErr)
+		return err
+	}
+	for _, group := range groups.Groups {
+		for _, groupVersion := range group.Versions {
+			if groupVersion.GroupVersion == deprecation.GroupVersion.String() {
+				return err
+			}
+		}
+	}
+
+	gvr := schema.GroupVersionResource{Group: gvk.Group, Version: gvk.Version}
+	return applyerrors.NewAPIVersionRemovedError(gvr, namespace, name,
+		fmt.Errorf("%s %s was removed in Kubernetes v%s and the spoke cluster runs v%s, %s: %w",
+			deprecation.GroupVersion, deprecation.Kind, deprecation.RemovedIn, version, deprecation.ReplacementMessage(), err))
+}
+
+// Deprecated returns the deprecation of the apiVersion of the kind if it is deprecated by the Kubernetes version of
+// the spoke, together with the version of the spoke. False is returned if the checker is nil, the apiVersion is not
+// deprecated by the spoke, or the version of the spoke is unknown.
+func (c *APIVersionChecker) Deprecated(gvk schema.GroupVersionKind) (APIVersionDeprecation, *utilversion.Version, bool) {
+	if c == nil {
+		return APIVersionDeprecation{}, nil, false
+	}
+	deprecation, ok := LookupAPIVersionDeprecation(gvk)
+	if !ok {
+		return APIVersionDeprecation{}, nil, false
+	}
+	version, err := c.spokeVersion()
+	if err != nil {
+		klog.V(4).Infof("Failed to get the version of the spoke: %v", err)
+		return APIVersionDeprecation{}, nil, false
+	}
+	if version.LessThan(utilversion.MustParseGeneric(deprecation.DeprecatedIn)) {
+		return APIVersionDeprecation{}, nil, false
+	}
+	return deprecation, version, true
+}
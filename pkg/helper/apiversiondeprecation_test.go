@@ -0,0 +1,173 @@
+package helper
+
+import (
+	goerrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+func newFakeVersionDiscovery(gitVersion string, groupVersions ...string) *fakediscovery.FakeDiscovery {
+	resources := []*metav1.APIResourceList{}
+	for _, groupVersion := range groupVersions {
+		resources = append(resources, &metav1.APIResourceList{GroupVersion: groupVersion})
+	}
+	return &fakediscovery.FakeDiscovery{
+		Fake:               &clienttesting.Fake{Resources: resources},
+		FakedServerVersion: &version.Info{GitVersion: gitVersion},
+	}
+}
+
+func TestAPIVersionCheckerRemoved(t *testing.T) {
+	psp := schema.GroupVersionKind{Group: "policy", Version: "v1beta1", Kind: "PodSecurityPolicy"}
+	pdb := schema.GroupVersionKind{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"}
+	cr := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Guestbook"}
+	mappingErr := applyerrors.NewMappingNotFoundError(schema.GroupVersionResource{Group: "policy", Version: "v1beta1"}, "ns1", "n1",
+		&meta.NoKindMatchError{GroupKind: psp.GroupKind(), SearchedVersions: []string{psp.Version}})
+
+	cases := []struct {
+		name            string
+		gvk             schema.GroupVersionKind
+		gitVersion      string
+		groupVersions   []string
+		expectedRemoved bool
+		expectedMessage string
+	}{
+		{
+			name:            "removed without replacement",
+			gvk:             psp,
+			gitVersion:      "v1.25.3+k3s1",
+			groupVersions:   []string{"v1", "policy/v1"},
+			expectedRemoved: true,
+			expectedMessage: "policy/v1beta1 PodSecurityPolicy was removed in Kubernetes v1.25 and the spoke cluster runs v1.25.3, there is no replacement",
+		},
+		{
+			name:            "removed with replacement",
+			gvk:             pdb,
+			gitVersion:      "v1.26.0",
+			groupVersions:   []string{"v1", "policy/v1"},
+			expectedRemoved: true,
+			expectedMessage: "use policy/v1 instead",
+		},
+		{
+			name:          "still served by the spoke",
+			gvk:           psp,
+			gitVersion:    "v1.25.0",
+			groupVersions: []string{"v1", "policy/v1", "policy/v1beta1"},
+		},
+		{
+			name:          "spoke older than the removal",
+			gvk:           psp,
+			gitVersion:    "v1.24.7",
+			groupVersions: []string{"v1", "policy/v1"},
+		},
+		{
+			name:          "not in the deprecation table",
+			gvk:           cr,
+			gitVersion:    "v1.25.0",
+			groupVersions: []string{"v1"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			checker := NewAPIVersionChecker(newFakeVersionDiscovery(c.gitVersion, c.groupVersions...))
+			err := checker.Removed(c.gvk, "ns1", "n1", mappingErr)
+			removed := goerrors.Is(err, applyerrors.ErrAPIVersionRemoved)
+			if removed != c.expectedRemoved {
+				t.Fatalf("expected removed %t, but got %v", c.expectedRemoved, err)
+			}
+			if !removed {
+				if err != mappingErr {
+					t.Errorf("expected the mapping error returned as is, but got %v", err)
+				}
+				return
+			}
+			if ClassifyApplyError(err) != ApplyErrorTerminal || IsAPIVersionNotAvailableError(err) {
+				t.Errorf("expected terminal error, but got %v", ClassifyApplyError(err))
+			}
+			if !strings.Contains(err.Error(), c.expectedMessage) {
+				t.Errorf("expected message %q, but got %q", c.expectedMessage, err.Error())
+			}
+		})
+	}
+
+	var checker *APIVersionChecker
+	if err := checker.Removed(psp, "ns1", "n1", mappingErr); err != mappingErr {
+		t.Errorf("expected the mapping error returned by nil checker, but got %v", err)
+	}
+}
+
+func TestAPIVersionCheckerDeprecated(t *testing.T) {
+	cronJob := schema.GroupVersionKind{Group: "batch", Version: "v1beta1", Kind: "CronJob"}
+
+	cases := []struct {
+		name               string
+		gvk                schema.GroupVersionKind
+		gitVersion         string
+		expectedDeprecated bool
+	}{
+		{
+			name:               "deprecated",
+			gvk:                cronJob,
+			gitVersion:         "v1.23.1",
+			expectedDeprecated: true,
+		},
+		{
+			name:       "spoke older than the deprecation",
+			gvk:        cronJob,
+			gitVersion: "v1.20.0",
+		},
+		{
+			name:       "not deprecated",
+			gvk:        schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"},
+			gitVersion: "v1.23.1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			checker := NewAPIVersionChecker(newFakeVersionDiscovery(c.gitVersion, "batch/v1", "batch/v1beta1"))
+			deprecation, version, deprecated := checker.Deprecated(c.gvk)
+			if deprecated != c.expectedDeprecated {
+				t.Fatalf("expected deprecated %t, but got %t", c.expectedDeprecated, deprecated)
+			}
+			if deprecated && (deprecation.Replacement != "batch/v1" || version.String() != "1.23.1") {
+				t.Errorf("unexpected deprecation %v of spoke version %v", deprecation, version)
+			}
+		})
+	}
+}
+
+func TestAPIVersionCheckerSpokeVersionCached(t *testing.T) {
+	discoveryClient := newFakeVersionDiscovery("v1.24.0")
+	fakeClock := clock.NewFakeClock(time.Now())
+	checker := NewAPIVersionChecker(discoveryClient)
+	checker.clock = fakeClock
+
+	cronJob := schema.GroupVersionKind{Group: "batch", Version: "v1beta1", Kind: "CronJob"}
+	for i := 0; i < 3; i++ {
+		if _, _, deprecated := checker.Deprecated(cronJob); !deprecated {
+			t.Fatalf("expected deprecated")
+		}
+	}
+	if len(discoveryClient.Actions()) != 1 {
+		t.Errorf("expected the spoke version discovered once, but got %d", len(discoveryClient.Actions()))
+	}
+
+	// the spoke is upgraded
+	discoveryClient.FakedServerVersion = &version.Info{GitVersion: "v1.25.0"}
+	fakeClock.Step(spokeVersionTTL + time.Second)
+	if _, version, _ := checker.Deprecated(cronJob); version.String() != "1.25.0" {
+		t.Errorf("expected the spoke version refreshed, but got %v", version)
+	}
+}
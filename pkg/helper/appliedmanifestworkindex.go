@@ -0,0 +1,134 @@
+package helper
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// AppliedManifestWorkByHubHashIndex indexes the appliedmanifestworks by the hash of their hubs.
+	AppliedManifestWorkByHubHashIndex = "appliedManifestWorkByHubHash"
+	// AppliedManifestWorkByManifestWorkIndex indexes the appliedmanifestworks by the hub hash and the name of
+	// their manifestworks, see ManifestWorkIndexKey.
+	AppliedManifestWorkByManifestWorkIndex = "appliedManifestWorkByManifestWork"
+	// AppliedManifestWorkByResourceUIDIndex indexes the appliedmanifestworks by the uids of their applied resources.
+	AppliedManifestWorkByResourceUIDIndex = "appliedManifestWorkByResourceUID"
+	// AppliedManifestWorkByResourceIndex indexes the appliedmanifestworks by the group, resource, namespace and name
+	// of their applied resources regardless of the versions, see ResourceIndexKey.
+	AppliedManifestWorkByResourceIndex = "appliedManifestWorkByResource"
+)
+
+// AppliedManifestWorkIndexers returns the indexers of the appliedmanifestworks.
+func AppliedManifestWorkIndexers() cache.Indexers {
+	return cache.Indexers{
+		AppliedManifestWorkByHubHashIndex:      IndexAppliedManifestWorkByHubHash,
+		AppliedManifestWorkByManifestWorkIndex: IndexAppliedManifestWorkByManifestWork,
+		AppliedManifestWorkByResourceUIDIndex:  IndexAppliedManifestWorkByResourceUID,
+		AppliedManifestWorkByResourceIndex:     IndexAppliedManifestWorkByResource,
+	}
+}
+
+// AddAppliedManifestWorkIndexers registers the indexers of the appliedmanifestworks which are not registered yet
+// with the informer. It must be called before the informer is started.
+func AddAppliedManifestWorkIndexers(informer cache.SharedIndexInformer) error {
+	existing := informer.GetIndexer().GetIndexers()
+	indexers := cache.Indexers{}
+	for name, indexFunc := range AppliedManifestWorkIndexers() {
+		if _, ok := existing[name]; !ok {
+			indexers[name] = indexFunc
+		}
+	}
+	if len(indexers) == 0 {
+		return nil
+	}
+	return informer.AddIndexers(indexers)
+}
+
+// ManifestWorkIndexKey returns the key of the manifestwork in AppliedManifestWorkByManifestWorkIndex.
+func ManifestWorkIndexKey(hubHash, manifestWorkName string) string {
+	return fmt.Sprintf("%s/%s", hubHash, manifestWorkName)
+}
+
+// ResourceIndexKey returns the key of the resource in AppliedManifestWorkByResourceIndex.
+func ResourceIndexKey(group, resource, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", group, resource, namespace, name)
+}
+
+// IndexAppliedManifestWorkByHubHash is the index func of AppliedManifestWorkByHubHashIndex.
+func IndexAppliedManifestWorkByHubHash(obj interface{}) ([]string, error) {
+	appliedManifestWork, ok := obj.(*workapiv1.AppliedManifestWork)
+	if !ok {
+		return []string{}, fmt.Errorf("obj %T is not an AppliedManifestWork", obj)
+	}
+	return []string{appliedManifestWork.Spec.HubHash}, nil
+}
+
+// IndexAppliedManifestWorkByManifestWork is the index func of AppliedManifestWorkByManifestWorkIndex.
+func IndexAppliedManifestWorkByManifestWork(obj interface{}) ([]string, error) {
+	appliedManifestWork, ok := obj.(*workapiv1.AppliedManifestWork)
+	if !ok {
+		return []string{}, fmt.Errorf("obj %T is not an AppliedManifestWork", obj)
+	}
+	return []string{ManifestWorkIndexKey(appliedManifestWork.Spec.HubHash, appliedManifestWork.Spec.ManifestWorkName)}, nil
+}
+
+// IndexAppliedManifestWorkByResourceUID is the index func of AppliedManifestWorkByResourceUIDIndex.
+func IndexAppliedManifestWorkByResourceUID(obj interface{}) ([]string, error) {
+	appliedManifestWork, ok := obj.(*workapiv1.AppliedManifestWork)
+	if !ok {
+		return []string{}, fmt.Errorf("obj %T is not an AppliedManifestWork", obj)
+	}
+	uids := []string{}
+	for _, resource := range appliedManifestWork.Status.AppliedResources {
+		if len(resource.UID) != 0 {
+			uids = append(uids, resource.UID)
+		}
+	}
+	return uids, nil
+}
+
+// IndexAppliedManifestWorkByResource is the index func of AppliedManifestWorkByResourceIndex.
+func IndexAppliedManifestWorkByResource(obj interface{}) ([]string, error) {
+	appliedManifestWork, ok := obj.(*workapiv1.AppliedManifestWork)
+	if !ok {
+		return []string{}, fmt.Errorf("obj %T is not an AppliedManifestWork", obj)
+	}
+	keys := []string{}
+	for _, resource := range appliedManifestWork.Status.AppliedResources {
+		keys = append(keys, ResourceIndexKey(resource.Group, resource.Resource, resource.Namespace, resource.Name))
+	}
+	return keys, nil
+}
+
+// GetAppliedManifestWorkByManifestWork returns the appliedmanifestwork of the manifestwork on the hub from the
+// indexer, a NotFound error is returned if it does not exist.
+func GetAppliedManifestWorkByManifestWork(indexer cache.Indexer, hubHash, manifestWorkName string) (*workapiv1.AppliedManifestWork, error) {
+	objs, err := indexer.ByIndex(AppliedManifestWorkByManifestWorkIndex, ManifestWorkIndexKey(hubHash, manifestWorkName))
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		if appliedManifestWork, ok := obj.(*workapiv1.AppliedManifestWork); ok {
+			return appliedManifestWork, nil
+		}
+	}
+	return nil, errors.NewNotFound(workapiv1.Resource("appliedmanifestworks"), fmt.Sprintf("%s-%s", hubHash, manifestWorkName))
+}
+
+// ListAppliedManifestWorksByHubHash returns the appliedmanifestworks of the hub from the indexer.
+func ListAppliedManifestWorksByHubHash(indexer cache.Indexer, hubHash string) ([]*workapiv1.AppliedManifestWork, error) {
+	objs, err := indexer.ByIndex(AppliedManifestWorkByHubHashIndex, hubHash)
+	if err != nil {
+		return nil, err
+	}
+	appliedManifestWorks := []*workapiv1.AppliedManifestWork{}
+	for _, obj := range objs {
+		if appliedManifestWork, ok := obj.(*workapiv1.AppliedManifestWork); ok {
+			appliedManifestWorks = append(appliedManifestWorks, appliedManifestWork)
+		}
+	}
+	return appliedManifestWorks, nil
+}
@@ -0,0 +1,126 @@
+package helper
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+func newAppliedManifestWork(hubHash, manifestWorkName string, uids ...string) *workapiv1.AppliedManifestWork {
+	appliedManifestWork := &workapiv1.AppliedManifestWork{
+		ObjectMeta: metav1.ObjectMeta{Name: hubHash + "-" + manifestWorkName},
+		Spec:       workapiv1.AppliedManifestWorkSpec{HubHash: hubHash, ManifestWorkName: manifestWorkName},
+	}
+	for _, uid := range uids {
+		appliedManifestWork.Status.AppliedResources = append(appliedManifestWork.Status.AppliedResources,
+			workapiv1.AppliedManifestResourceMeta{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: uid, UID: uid})
+	}
+	return appliedManifestWork
+}
+
+func TestAppliedManifestWorkIndexFuncs(t *testing.T) {
+	cases := []struct {
+		name      string
+		indexFunc cache.IndexFunc
+		obj       interface{}
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:      "by hub hash",
+			indexFunc: IndexAppliedManifestWorkByHubHash,
+			obj:       newAppliedManifestWork("hub1", "work1"),
+			expected:  []string{"hub1"},
+		},
+		{
+			name:      "by manifestwork",
+			indexFunc: IndexAppliedManifestWorkByManifestWork,
+			obj:       newAppliedManifestWork("hub1", "work1"),
+			expected:  []string{"hub1/work1"},
+		},
+		{
+			name:      "by resource uid",
+			indexFunc: IndexAppliedManifestWorkByResourceUID,
+			obj:       newAppliedManifestWork("hub1", "work1", "uid1", "", "uid2"),
+			expected:  []string{"uid1", "uid2"},
+		},
+		{
+			name:      "by resource uid without applied resources",
+			indexFunc: IndexAppliedManifestWorkByResourceUID,
+			obj:       newAppliedManifestWork("hub1", "work1"),
+			expected:  []string{},
+		},
+		{
+			name:      "by resource",
+			indexFunc: IndexAppliedManifestWorkByResource,
+			obj:       newAppliedManifestWork("hub1", "work1", "uid1", "uid2"),
+			expected:  []string{"/secrets/ns1/uid1", "/secrets/ns1/uid2"},
+		},
+		{
+			name:      "not an appliedmanifestwork",
+			indexFunc: IndexAppliedManifestWorkByHubHash,
+			obj:       &workapiv1.ManifestWork{},
+			expected:  []string{},
+			expectErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := c.indexFunc(c.obj)
+			if c.expectErr != (err != nil) {
+				t.Errorf("expected error %v, but got %v", c.expectErr, err)
+			}
+			if !reflect.DeepEqual(actual, c.expected) {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAppliedManifestWorkIndexer(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, AppliedManifestWorkIndexers())
+	for _, appliedManifestWork := range []*workapiv1.AppliedManifestWork{
+		newAppliedManifestWork("hub1", "work1", "uid1"),
+		newAppliedManifestWork("hub1", "work2", "uid2"),
+		newAppliedManifestWork("hub2", "work1", "uid1"),
+	} {
+		if err := indexer.Add(appliedManifestWork); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	appliedManifestWork, err := GetAppliedManifestWorkByManifestWork(indexer, "hub2", "work1")
+	if err != nil || appliedManifestWork.Name != "hub2-work1" {
+		t.Errorf("expected hub2-work1, but got %v, %v", appliedManifestWork, err)
+	}
+	if _, err := GetAppliedManifestWorkByManifestWork(indexer, "hub2", "work2"); !errors.IsNotFound(err) {
+		t.Errorf("expected not found, but got %v", err)
+	}
+
+	appliedManifestWorks, err := ListAppliedManifestWorksByHubHash(indexer, "hub1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, appliedManifestWork := range appliedManifestWorks {
+		names = append(names, appliedManifestWork.Name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"hub1-work1", "hub1-work2"}) {
+		t.Errorf("expected appliedmanifestworks of hub1, but got %v", names)
+	}
+
+	objs, err := indexer.ByIndex(AppliedManifestWorkByResourceUIDIndex, "uid1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 {
+		t.Errorf("expected 2 appliedmanifestworks applying uid1, but got %d", len(objs))
+	}
+}
@@ -0,0 +1,224 @@
+package helper
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+// ApplyErrorClass is the class of an error returned when applying a manifest.
+type ApplyErrorClass string
+
+const (
+	// ApplyErrorRetryable means the apply may succeed by retrying without changing the manifestwork,
+	// e.g. conflict, webhook timeout, the CRD of the manifest is not installed yet, or the request is forbidden
+	// since the namespace is terminating or the RBAC of the agent is not granted yet.
+	ApplyErrorRetryable ApplyErrorClass = "Retryable"
+	// ApplyErrorTerminal means the apply will never succeed until the manifestwork is changed,
+	// e.g. a field is invalid/immutable.
+	ApplyErrorTerminal ApplyErrorClass = "Terminal"
+	// ApplyErrorNotAllowed means the apply is not allowed for now, and it should be retried after the
+	// requeue time of the NotAllowedError.
+	ApplyErrorNotAllowed ApplyErrorClass = "NotAllowed"
+)
+
+// NotAllowedError is kept as an alias of the NotAllowedError of the apply errors.
+type NotAllowedError = applyerrors.NotAllowedError
+
+// RetryAfterError is returned when a retryable error should be retried after the requeue time instead of the rate
+// limited backoff of the controller factory, e.g. the spoke apiserver asks the agent to retry after a while.
+type RetryAfterError struct {
+	Err         error
+	RequeueTime time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// ImmutableResourceError is returned when the manifest changes the fields of a resource which cannot be changed
+// once the resource is created, e.g. the data of an immutable secret. It is terminal, the resource can only be
+// changed by recreating it.
+type ImmutableResourceError struct {
+	// Resource is the kind, namespace and name of the resource, e.g. Secret ns1/test
+	Resource string
+	// Fields are the immutable fields changed by the manifest
+	Fields []string
+}
+
+func (e *ImmutableResourceError) Error() string {
+	return fmt.Sprintf("%s is immutable, its %s cannot be changed unless it is recreated with the annotation %s=%s on the manifest",
+		e.Resource, strings.Join(e.Fields, ", "), constants.UpdateStrategyAnnotationKey, constants.UpdateStrategyRecreate)
+}
+
+// terminalErrorCheckers is the table of checks classifying an apply error as terminal.
+var terminalErrorCheckers = []func(err error) bool{
+	errors.IsInvalid,
+	errors.IsBadRequest,
+	errors.IsMethodNotSupported,
+	errors.IsRequestEntityTooLargeError,
+}
+
+// ClassifyApplyError returns the class of the apply error. Errors not known to be terminal are treated
+// as retryable, so that transient errors like conflict, timeout or webhook failures are retried.
+func ClassifyApplyError(err error) ApplyErrorClass {
+	var notAllowedErr *NotAllowedError
+	if goerrors.As(err, &notAllowedErr) {
+		return ApplyErrorNotAllowed
+	}
+	var timeoutErr *ApplyTimeoutError
+	if goerrors.As(err, &timeoutErr) {
+		return ApplyErrorRetryable
+	}
+	var immutableErr *ImmutableResourceError
+	if goerrors.As(err, &immutableErr) || goerrors.Is(err, applyerrors.ErrValidationFailed) ||
+		goerrors.Is(err, applyerrors.ErrNamespaceNotPermitted) || goerrors.Is(err, applyerrors.ErrResourceForbiddenByAgentPolicy) ||
+		goerrors.Is(err, applyerrors.ErrAPIVersionRemoved) || goerrors.Is(err, applyerrors.ErrManifestDecodeFailed) {
+		return ApplyErrorTerminal
+	}
+	if goerrors.Is(err, applyerrors.ErrResourceConflict) || goerrors.Is(err, applyerrors.ErrMappingNotFound) {
+		return ApplyErrorRetryable
+	}
+
+	for _, isTerminal := range terminalErrorCheckers {
+		if isTerminal(err) {
+			return ApplyErrorTerminal
+		}
+	}
+
+	return ApplyErrorRetryable
+}
+
+// IsAPIVersionNotAvailableError checks if the error is caused by that the kind/resource is not served by the
+// cluster, e.g. the CRD of the manifest is not installed yet. Unlike meta.IsNoMatchError, wrapped errors are
+// handled. The error is retryable, since the CRD may be installed later, e.g. by another manifestwork. The
+// apiVersions removed by the cluster are not available for good, see ErrAPIVersionRemoved of the apply errors.
+func IsAPIVersionNotAvailableError(err error) bool {
+	if goerrors.Is(err, applyerrors.ErrAPIVersionRemoved) {
+		return false
+	}
+	var noKindMatchErr *meta.NoKindMatchError
+	var noResourceMatchErr *meta.NoResourceMatchError
+	return goerrors.Is(err, applyerrors.ErrMappingNotFound) || goerrors.As(err, &noKindMatchErr) || goerrors.As(err, &noResourceMatchErr)
+}
+
+// AggregateManifestErrors aggregates the errors of applying the manifests of a manifestwork, the index of an
+// error is the ordinal of the manifest and a nil error means the manifest is applied. It returns
+//   - the Applied condition of the manifestwork, nil if there is no manifest;
+//   - the error of the retryable errors and the NotAllowedErrors, which is returned to the controller factory.
+//     The sync function wrapped by RequeueNotAllowedSync requeues the manifestwork after the requeue time of the
+//     NotAllowedErrors, and with rate limiting for the retryable errors. Terminal errors are not returned since
+//     they will not be resolved until the manifestwork is changed.
+func AggregateManifestErrors(generation int64, manifestErrors []error) (*metav1.Condition, error) {
+	if len(manifestErrors) == 0 {
+		return nil, nil
+	}
+
+	var errs []error
+	failed := map[ApplyErrorClass]int{}
+	for ordinal, err := range manifestErrors {
+		if err == nil {
+			continue
+		}
+
+		class := ClassifyApplyError(err)
+		failed[class]++
+		if class != ApplyErrorTerminal {
+			errs = append(errs, fmt.Errorf("manifest %d: %w", ordinal, err))
+		}
+	}
+
+	total := failed[ApplyErrorRetryable] + failed[ApplyErrorTerminal] + failed[ApplyErrorNotAllowed]
+	if total == 0 {
+		return &metav1.Condition{
+			Type:               workapiv1.WorkApplied,
+			Status:             metav1.ConditionTrue,
+			Reason:             "AppliedManifestWorkComplete",
+			ObservedGeneration: generation,
+			Message:            "Apply manifest work complete",
+		}, nil
+	}
+
+	condition := &metav1.Condition{
+		Type:               workapiv1.WorkApplied,
+		Status:             metav1.ConditionFalse,
+		Reason:             "AppliedManifestWorkFailed",
+		ObservedGeneration: generation,
+		Message: fmt.Sprintf("Failed to apply manifest work: %d of %d manifests failed (%d retryable, %d terminal, %d not allowed)",
+			total, len(manifestErrors), failed[ApplyErrorRetryable], failed[ApplyErrorTerminal], failed[ApplyErrorNotAllowed]),
+	}
+	return condition, utilerrors.NewAggregate(errs)
+}
+
+// SplitNotAllowedErrors splits the NotAllowedErrors, the RetryAfterErrors and the ResourceErrors with a requeue time
+// out of the error, aggregated errors are flattened and wrapped errors are unwrapped. It returns the min requeue time of the errors split, 0 if there is
+// none, and the aggregate of the other errors.
+func SplitNotAllowedErrors(err error) (time.Duration, error) {
+	if err == nil {
+		return 0, nil
+	}
+
+	var aggregate utilerrors.Aggregate
+	if goerrors.As(err, &aggregate) {
+		var requeueAfter time.Duration
+		var errs []error
+		for _, e := range aggregate.Errors() {
+			requeueTime, remaining := SplitNotAllowedErrors(e)
+			requeueAfter = minRequeueTime(requeueAfter, requeueTime)
+			if remaining != nil {
+				errs = append(errs, remaining)
+			}
+		}
+		return requeueAfter, utilerrors.NewAggregate(errs)
+	}
+
+	var notAllowedErr *NotAllowedError
+	if goerrors.As(err, &notAllowedErr) {
+		return notAllowedErr.RequeueTime, nil
+	}
+	var retryAfterErr *RetryAfterError
+	if goerrors.As(err, &retryAfterErr) {
+		return retryAfterErr.RequeueTime, nil
+	}
+	var resourceErr *applyerrors.ResourceError
+	if goerrors.As(err, &resourceErr) && resourceErr.RequeueTime > 0 {
+		return resourceErr.RequeueTime, nil
+	}
+	return 0, err
+}
+
+// minRequeueTime returns the min of the two requeue times, 0 is ignored.
+func minRequeueTime(a, b time.Duration) time.Duration {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// RequeueNotAllowedSync wraps the sync function, so that the queue key is requeued after the min requeue time of
+// the NotAllowedErrors and the RetryAfterErrors returned by the sync function. They are not returned to the
+// controller factory, so they do not increase the rate limited backoff of the queue key.
+func RequeueNotAllowedSync(sync factory.SyncFunc) factory.SyncFunc {
+	return func(ctx context.Context, syncCtx factory.SyncContext) error {
+		requeueAfter, err := SplitNotAllowedErrors(sync(ctx, syncCtx))
+		if requeueAfter > 0 {
+			syncCtx.Queue().AddAfter(syncCtx.QueueKey(), requeueAfter)
+		}
+		return err
+	}
+}
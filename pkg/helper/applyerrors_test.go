@@ -0,0 +1,287 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestClassifyApplyError(t *testing.T) {
+	gr := schema.GroupResource{Group: "apps", Resource: "deployments"}
+
+	cases := []struct {
+		name     string
+		err      error
+		expected ApplyErrorClass
+	}{
+		{
+			name: "crd not found",
+			err: fmt.Errorf("the server doesn't have a resource type %q: %w", "Foo",
+				&meta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "test.io", Kind: "Foo"}}),
+			expected: ApplyErrorRetryable,
+		},
+		{
+			name:     "forbidden",
+			err:      errors.NewForbidden(gr, "test", fmt.Errorf("not allowed")),
+			expected: ApplyErrorRetryable,
+		},
+		{
+			name: "invalid",
+			err: errors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "test", field.ErrorList{
+				field.Invalid(field.NewPath("spec", "selector"), "", "field is immutable"),
+			}),
+			expected: ApplyErrorTerminal,
+		},
+		{
+			name:     "conflict",
+			err:      errors.NewConflict(gr, "test", fmt.Errorf("object has been modified")),
+			expected: ApplyErrorRetryable,
+		},
+		{
+			name: "webhook timeout",
+			err: errors.NewInternalError(fmt.Errorf(
+				"failed calling webhook \"test.webhook.io\": Post \"https://webhook.svc:443/validate\": context deadline exceeded")),
+			expected: ApplyErrorRetryable,
+		},
+		{
+			name:     "server timeout",
+			err:      errors.NewTimeoutError("request timeout", 1),
+			expected: ApplyErrorRetryable,
+		},
+		{
+			name:     "namespace not found",
+			err:      errors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, "ns1"),
+			expected: ApplyErrorRetryable,
+		},
+		{
+			name:     "not allowed",
+			err:      &NotAllowedError{Err: fmt.Errorf("not allowed"), RequeueTime: time.Minute},
+			expected: ApplyErrorNotAllowed,
+		},
+		{
+			name:     "apply timed out",
+			err:      &ApplyTimeoutError{Err: errors.NewBadRequest("context deadline exceeded"), Timeout: time.Second},
+			expected: ApplyErrorRetryable,
+		},
+		{
+			name:     "immutable resource changed",
+			err:      fmt.Errorf("manifest 0: %w", &ImmutableResourceError{Resource: "Secret ns1/test", Fields: []string{"data"}}),
+			expected: ApplyErrorTerminal,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := ClassifyApplyError(c.err)
+			if actual != c.expected {
+				t.Errorf("expected %q, but got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAggregateManifestErrors(t *testing.T) {
+	gr := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	retryableErr := errors.NewConflict(gr, "test", fmt.Errorf("object has been modified"))
+	terminalErr := errors.NewBadRequest("bad request")
+	notAllowedErr := func(requeueTime time.Duration) error {
+		return &NotAllowedError{Err: fmt.Errorf("not allowed for now"), RequeueTime: requeueTime}
+	}
+
+	cases := []struct {
+		name                 string
+		manifestErrors       []error
+		expectedStatus       metav1.ConditionStatus
+		expectedMessage      string
+		expectedRequeueAfter time.Duration
+		expectedErr          string
+	}{
+		{
+			name: "no manifest",
+		},
+		{
+			name:            "all manifests are applied",
+			manifestErrors:  []error{nil, nil},
+			expectedStatus:  metav1.ConditionTrue,
+			expectedMessage: "Apply manifest work complete",
+		},
+		{
+			name:            "retryable errors",
+			manifestErrors:  []error{retryableErr, nil, retryableErr},
+			expectedStatus:  metav1.ConditionFalse,
+			expectedMessage: "Failed to apply manifest work: 2 of 3 manifests failed (2 retryable, 0 terminal, 0 not allowed)",
+			expectedErr: "[manifest 0: Operation cannot be fulfilled on deployments.apps \"test\": object has been modified, " +
+				"manifest 2: Operation cannot be fulfilled on deployments.apps \"test\": object has been modified]",
+		},
+		{
+			name:            "terminal errors are not returned",
+			manifestErrors:  []error{nil, terminalErr},
+			expectedStatus:  metav1.ConditionFalse,
+			expectedMessage: "Failed to apply manifest work: 1 of 2 manifests failed (0 retryable, 1 terminal, 0 not allowed)",
+		},
+		{
+			name:                 "min requeue time of not allowed errors",
+			manifestErrors:       []error{notAllowedErr(time.Minute), notAllowedErr(10 * time.Second), nil},
+			expectedStatus:       metav1.ConditionFalse,
+			expectedMessage:      "Failed to apply manifest work: 2 of 3 manifests failed (0 retryable, 0 terminal, 2 not allowed)",
+			expectedRequeueAfter: 10 * time.Second,
+			expectedErr:          "[manifest 0: not allowed for now, manifest 1: not allowed for now]",
+		},
+		{
+			name:                 "wrapped not allowed error",
+			manifestErrors:       []error{fmt.Errorf("failed to apply: %w", notAllowedErr(time.Minute))},
+			expectedStatus:       metav1.ConditionFalse,
+			expectedMessage:      "Failed to apply manifest work: 1 of 1 manifests failed (0 retryable, 0 terminal, 1 not allowed)",
+			expectedRequeueAfter: time.Minute,
+			expectedErr:          "manifest 0: failed to apply: not allowed for now",
+		},
+		{
+			name:                 "mixed errors",
+			manifestErrors:       []error{terminalErr, notAllowedErr(time.Minute), retryableErr, nil},
+			expectedStatus:       metav1.ConditionFalse,
+			expectedMessage:      "Failed to apply manifest work: 3 of 4 manifests failed (1 retryable, 1 terminal, 1 not allowed)",
+			expectedRequeueAfter: time.Minute,
+			expectedErr: "[manifest 1: not allowed for now, " +
+				"manifest 2: Operation cannot be fulfilled on deployments.apps \"test\": object has been modified]",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			condition, err := AggregateManifestErrors(2, c.manifestErrors)
+
+			switch {
+			case len(c.manifestErrors) == 0 && condition != nil:
+				t.Errorf("expected no condition, but got %v", condition)
+			case len(c.manifestErrors) != 0 && condition == nil:
+				t.Errorf("expected condition, but got nil")
+			case condition != nil:
+				if condition.Type != workapiv1.WorkApplied || condition.ObservedGeneration != 2 {
+					t.Errorf("unexpected condition %v", condition)
+				}
+				if condition.Status != c.expectedStatus {
+					t.Errorf("expected status %q, but got %q", c.expectedStatus, condition.Status)
+				}
+				if condition.Message != c.expectedMessage {
+					t.Errorf("expected message %q, but got %q", c.expectedMessage, condition.Message)
+				}
+			}
+
+			// the not allowed errors are returned to be requeued after the min requeue time
+			if requeueAfter, _ := SplitNotAllowedErrors(err); requeueAfter != c.expectedRequeueAfter {
+				t.Errorf("expected requeue after %v, but got %v", c.expectedRequeueAfter, requeueAfter)
+			}
+
+			switch {
+			case len(c.expectedErr) == 0 && err != nil:
+				t.Errorf("expected no error, but got %v", err)
+			case len(c.expectedErr) != 0 && (err == nil || err.Error() != c.expectedErr):
+				t.Errorf("expected error %q, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestSplitNotAllowedErrors(t *testing.T) {
+	retryableErr := fmt.Errorf("retryable")
+	notAllowedErr := func(requeueTime time.Duration) error {
+		return &NotAllowedError{Err: fmt.Errorf("not allowed for now"), RequeueTime: requeueTime}
+	}
+
+	cases := []struct {
+		name                 string
+		err                  error
+		expectedRequeueAfter time.Duration
+		expectedErr          string
+	}{
+		{
+			name: "no error",
+		},
+		{
+			name:        "no not allowed error",
+			err:         retryableErr,
+			expectedErr: "retryable",
+		},
+		{
+			name:                 "not allowed error",
+			err:                  notAllowedErr(time.Minute),
+			expectedRequeueAfter: time.Minute,
+		},
+		{
+			name:                 "wrapped not allowed error",
+			err:                  fmt.Errorf("manifest 0: %w", notAllowedErr(time.Minute)),
+			expectedRequeueAfter: time.Minute,
+		},
+		{
+			name:                 "aggregated not allowed errors",
+			err:                  utilerrors.NewAggregate([]error{notAllowedErr(time.Minute), notAllowedErr(10 * time.Second)}),
+			expectedRequeueAfter: 10 * time.Second,
+		},
+		{
+			name: "nested aggregated errors",
+			err: utilerrors.NewAggregate([]error{
+				fmt.Errorf("failed to apply: %w", utilerrors.NewAggregate([]error{notAllowedErr(time.Minute), retryableErr})),
+				notAllowedErr(30 * time.Second),
+				fmt.Errorf("failed to update status"),
+			}),
+			expectedRequeueAfter: 30 * time.Second,
+			expectedErr:          "[retryable, failed to update status]",
+		},
+		{
+			name: "retry after errors",
+			err: utilerrors.NewAggregate([]error{
+				notAllowedErr(time.Minute),
+				fmt.Errorf("manifest 1: %w", &RetryAfterError{Err: fmt.Errorf("throttled"), RequeueTime: 5 * time.Second}),
+			}),
+			expectedRequeueAfter: 5 * time.Second,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			requeueAfter, err := SplitNotAllowedErrors(c.err)
+			if requeueAfter != c.expectedRequeueAfter {
+				t.Errorf("expected requeue after %v, but got %v", c.expectedRequeueAfter, requeueAfter)
+			}
+			switch {
+			case len(c.expectedErr) == 0 && err != nil:
+				t.Errorf("expected no error, but got %v", err)
+			case len(c.expectedErr) != 0 && (err == nil || err.Error() != c.expectedErr):
+				t.Errorf("expected error %q, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestRequeueNotAllowedSync(t *testing.T) {
+	sync := RequeueNotAllowedSync(func(ctx context.Context, syncCtx factory.SyncContext) error {
+		return utilerrors.NewAggregate([]error{
+			&NotAllowedError{Err: fmt.Errorf("not allowed for now"), RequeueTime: 100 * time.Millisecond},
+		})
+	})
+
+	syncCtx := spoketesting.NewFakeSyncContext(t, "work1")
+	if err := sync(context.TODO(), syncCtx); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if syncCtx.Queue().NumRequeues("work1") != 0 {
+		t.Errorf("expected the backoff of the key is not increased")
+	}
+
+	key, _ := syncCtx.Queue().Get()
+	if key != "work1" {
+		t.Errorf("expected work1 requeued, but got %v", key)
+	}
+}
@@ -0,0 +1,61 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+// ManifestApplyTimeout overrides the apply timeout of a manifest.
+type ManifestApplyTimeout struct {
+	Ordinal int32           `json:"ordinal"`
+	Timeout metav1.Duration `json:"timeout"`
+}
+
+// ManifestApplyTimeouts returns the apply timeouts of the manifests of the manifestwork keyed by the ordinal of
+// the manifests. A bad request error is returned if the timeouts are invalid, so that it is not retried.
+func ManifestApplyTimeouts(manifestWork *workapiv1.ManifestWork) (map[int32]time.Duration, error) {
+	value, ok := manifestWork.Annotations[constants.ManifestApplyTimeoutsAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	timeouts := []ManifestApplyTimeout{}
+	if err := json.Unmarshal([]byte(value), &timeouts); err != nil {
+		return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest apply timeouts of manifestwork %s: %v", manifestWork.Name, err))
+	}
+
+	result := map[int32]time.Duration{}
+	for _, timeout := range timeouts {
+		switch {
+		case timeout.Timeout.Duration <= 0:
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest apply timeouts of manifestwork %s: timeout %s is not positive",
+				manifestWork.Name, timeout.Timeout.Duration))
+		case timeout.Ordinal < 0 || int(timeout.Ordinal) >= len(manifestWork.Spec.Workload.Manifests):
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest apply timeouts of manifestwork %s: ordinal %d is out of range",
+				manifestWork.Name, timeout.Ordinal))
+		}
+		result[timeout.Ordinal] = timeout.Timeout.Duration
+	}
+	return result, nil
+}
+
+// ApplyTimeoutError is returned when applying a manifest does not finish within the apply timeout, e.g. an
+// admission webhook hangs. It is retryable.
+type ApplyTimeoutError struct {
+	Err     error
+	Timeout time.Duration
+}
+
+func (e *ApplyTimeoutError) Error() string {
+	return fmt.Sprintf("apply timed out after %s: %v", e.Timeout, e.Err)
+}
+
+func (e *ApplyTimeoutError) Unwrap() error {
+	return e.Err
+}
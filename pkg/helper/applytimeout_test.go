@@ -0,0 +1,72 @@
+package helper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+func TestManifestApplyTimeouts(t *testing.T) {
+	cases := []struct {
+		name             string
+		annotations      map[string]string
+		expectedTimeouts map[int32]time.Duration
+		expectedErr      bool
+	}{
+		{
+			name: "no timeouts",
+		},
+		{
+			name:             "timeouts",
+			annotations:      map[string]string{constants.ManifestApplyTimeoutsAnnotationKey: `[{"ordinal": 1, "timeout": "30s"}]`},
+			expectedTimeouts: map[int32]time.Duration{1: 30 * time.Second},
+		},
+		{
+			name:        "invalid json",
+			annotations: map[string]string{constants.ManifestApplyTimeoutsAnnotationKey: `{`},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid timeout",
+			annotations: map[string]string{constants.ManifestApplyTimeoutsAnnotationKey: `[{"ordinal": 0, "timeout": "0s"}]`},
+			expectedErr: true,
+		},
+		{
+			name:        "ordinal out of range",
+			annotations: map[string]string{constants.ManifestApplyTimeoutsAnnotationKey: `[{"ordinal": 2, "timeout": "30s"}]`},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work := &workapiv1.ManifestWork{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: c.annotations},
+				Spec: workapiv1.ManifestWorkSpec{
+					Workload: workapiv1.ManifestsTemplate{Manifests: []workapiv1.Manifest{{}, {}}},
+				},
+			}
+
+			timeouts, err := ManifestApplyTimeouts(work)
+			if c.expectedErr {
+				if !errors.IsBadRequest(err) {
+					t.Errorf("expected bad request error, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(timeouts) != 0 || len(c.expectedTimeouts) != 0 {
+				if !reflect.DeepEqual(timeouts, c.expectedTimeouts) {
+					t.Errorf("expected %v, but got %v", c.expectedTimeouts, timeouts)
+				}
+			}
+		})
+	}
+}
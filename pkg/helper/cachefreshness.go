@@ -0,0 +1,72 @@
+package helper
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CacheFreshness tracks the last time the cache of an informer was known to be in sync with the apiserver. The
+// cache is considered stale once the watch of the informer fails, e.g. the hub is unreachable, until the informer
+// delivers an event again after it relists.
+type CacheFreshness struct {
+	lock       sync.Mutex
+	clock      clock.Clock
+	staleSince time.Time
+}
+
+// NewCacheFreshness returns a CacheFreshness tracking the informer. It must be called before the informer is
+// started, since the watch error handler of the informer cannot be set afterwards.
+func NewCacheFreshness(informer cache.SharedIndexInformer) (*CacheFreshness, error) {
+	f := &CacheFreshness{clock: clock.RealClock{}}
+	if err := informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		cache.DefaultWatchErrorHandler(r, err)
+		f.markStale()
+	}); err != nil {
+		return nil, err
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { f.markSynced() },
+		UpdateFunc: func(oldObj, newObj interface{}) { f.markSynced() },
+		DeleteFunc: func(obj interface{}) { f.markSynced() },
+	})
+	return f, nil
+}
+
+// LastSyncTime returns the last time the cache was known to be in sync, which is now if the cache is not stale.
+func (f *CacheFreshness) LastSyncTime() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.staleSince.IsZero() {
+		return f.clock.Now()
+	}
+	return f.staleSince
+}
+
+// IsStale returns true if the cache is not in sync for longer than the threshold. The cache is never stale if
+// the threshold is not positive or the freshness is not tracked.
+func (f *CacheFreshness) IsStale(threshold time.Duration) bool {
+	if f == nil || threshold <= 0 {
+		return false
+	}
+	return f.clock.Since(f.LastSyncTime()) > threshold
+}
+
+func (f *CacheFreshness) markStale() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.staleSince.IsZero() {
+		f.staleSince = f.clock.Now()
+	}
+}
+
+func (f *CacheFreshness) markSynced() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.staleSince = time.Time{}
+}
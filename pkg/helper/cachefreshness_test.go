@@ -0,0 +1,100 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clienttesting "k8s.io/client-go/testing"
+	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+func TestCacheFreshness(t *testing.T) {
+	fakeClient := fakeworkclient.NewSimpleClientset()
+	informer := workinformers.NewSharedInformerFactory(fakeClient, 5*time.Minute).Work().V1().ManifestWorks().Informer()
+	freshness, err := NewCacheFreshness(informer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	freshness.clock = fakeClock
+
+	var nilFreshness *CacheFreshness
+	if nilFreshness.IsStale(time.Minute) {
+		t.Errorf("expected not stale if the freshness is not tracked")
+	}
+	if freshness.IsStale(time.Minute) || !freshness.LastSyncTime().Equal(fakeClock.Now()) {
+		t.Errorf("expected not stale before the watch fails")
+	}
+
+	// the cache is stale since the watch fails the first time
+	syncTime := fakeClock.Now()
+	freshness.markStale()
+	fakeClock.Step(30 * time.Second)
+	freshness.markStale()
+	if !freshness.LastSyncTime().Equal(syncTime) {
+		t.Errorf("expected last sync time %v, but got %v", syncTime, freshness.LastSyncTime())
+	}
+	if freshness.IsStale(time.Minute) {
+		t.Errorf("expected not stale within the threshold")
+	}
+	fakeClock.Step(time.Minute)
+	if !freshness.IsStale(time.Minute) {
+		t.Errorf("expected stale after the threshold")
+	}
+	if freshness.IsStale(0) {
+		t.Errorf("expected not stale if the threshold is not positive")
+	}
+
+	// the cache is in sync again once an event is delivered
+	freshness.markSynced()
+	if freshness.IsStale(time.Minute) || !freshness.LastSyncTime().Equal(fakeClock.Now()) {
+		t.Errorf("expected not stale after synced")
+	}
+}
+
+func TestCacheFreshnessWithInformer(t *testing.T) {
+	fakeClient := fakeworkclient.NewSimpleClientset()
+	hubDown := int32(1)
+	fakeClient.PrependReactor("list", "manifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if atomic.LoadInt32(&hubDown) == 1 {
+			return true, nil, fmt.Errorf("hub is down")
+		}
+		return false, nil, nil
+	})
+	informer := workinformers.NewSharedInformerFactory(fakeClient, 5*time.Minute).Work().V1().ManifestWorks().Informer()
+	freshness, err := NewCacheFreshness(informer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go informer.Run(ctx.Done())
+
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return freshness.IsStale(time.Nanosecond), nil
+	}); err != nil {
+		t.Fatalf("expected stale once the list fails: %v", err)
+	}
+
+	staleSince := freshness.LastSyncTime()
+	atomic.StoreInt32(&hubDown, 0)
+	work := &workapiv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "work1", Namespace: "cluster1"}}
+	if _, err := fakeClient.WorkV1().ManifestWorks("cluster1").Create(ctx, work, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 30*time.Second, func() (bool, error) {
+		return freshness.LastSyncTime().After(staleSince), nil
+	}); err != nil {
+		t.Fatalf("expected synced once the informer relists: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package helper
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// ErrHubUnavailable is returned instead of writing to the hub while the hub circuit breaker is open.
+var ErrHubUnavailable = goerrors.New("hub is unavailable, status writes are paused")
+
+var hubDegraded = metrics.NewGaugeVec(
+	&metrics.GaugeOpts{
+		Name: "work_agent_hub_degraded",
+		Help: "Whether status writes to the hub are paused because the hub is unavailable (1) or not (0) by the hash of the hub.",
+	},
+	[]string{"hub_hash"},
+)
+
+func init() {
+	legacyregistry.MustRegister(hubDegraded)
+}
+
+// HubCircuitBreaker pauses status writes to the hub after a number of consecutive failures caused by
+// the hub being unreachable. While it is open, writes fail fast with ErrHubUnavailable until the backoff
+// window is passed and a probe against the hub succeeds. Applying manifests on the spoke is not affected.
+type HubCircuitBreaker struct {
+	lock                sync.Mutex
+	hubHash             string
+	failureThreshold    int
+	backoff             time.Duration
+	probe               func(ctx context.Context) error
+	clock               clock.Clock
+	consecutiveFailures int
+	openUntil           time.Time
+	// probing is true while a probe is running, the lock is not held by the probe so that the other writes fail
+	// fast instead of waiting for the probe
+	probing bool
+}
+
+// NewHubCircuitBreaker returns a HubCircuitBreaker of the hub with the hash. The probe should be a cheap read request
+// against the hub.
+func NewHubCircuitBreaker(
+	hubHash string, failureThreshold int, backoff time.Duration, probe func(ctx context.Context) error) *HubCircuitBreaker {
+	return &HubCircuitBreaker{
+		hubHash:          hubHash,
+		failureThreshold: failureThreshold,
+		backoff:          backoff,
+		probe:            probe,
+		clock:            clock.RealClock{},
+	}
+}
+
+// Allow returns ErrHubUnavailable if the breaker is open. Once the backoff window is passed, the hub is
+// probed by one of the callers and the breaker is closed if the probe succeeds, the others fail fast while
+// the hub is probed.
+func (b *HubCircuitBreaker) Allow(ctx context.Context) error {
+	b.lock.Lock()
+	if b.consecutiveFailures < b.failureThreshold {
+		b.lock.Unlock()
+		return nil
+	}
+	if b.probing || b.clock.Now().Before(b.openUntil) {
+		b.lock.Unlock()
+		return ErrHubUnavailable
+	}
+	b.probing = true
+	b.lock.Unlock()
+
+	err := b.probe(ctx)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.probing = false
+	if IsHubConnectionError(err) {
+		klog.V(4).Infof("Hub %s is still unavailable: %v", b.hubHash, err)
+		b.openUntil = b.clock.Now().Add(b.backoff)
+		return ErrHubUnavailable
+	}
+
+	klog.Infof("Hub %s is available again, resume status writes", b.hubHash)
+	b.reset()
+	return nil
+}
+
+// Record records the result of a write to the hub.
+func (b *HubCircuitBreaker) Record(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !IsHubConnectionError(err) {
+		b.reset()
+		return
+	}
+
+	b.consecutiveFailures++
+	if b.consecutiveFailures == b.failureThreshold {
+		klog.Warningf("Pause status writes to hub %s for %v after %d consecutive failures: %v",
+			b.hubHash, b.backoff, b.consecutiveFailures, err)
+		b.openUntil = b.clock.Now().Add(b.backoff)
+		hubDegraded.WithLabelValues(b.hubHash).Set(1)
+	}
+}
+
+// Degraded returns true if status writes to the hub are paused.
+func (b *HubCircuitBreaker) Degraded() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.consecutiveFailures >= b.failureThreshold
+}
+
+// Name implements healthz.HealthChecker, the breaker is registered as a readiness check of the agent.
+func (b *HubCircuitBreaker) Name() string {
+	return "hub-status-writes-" + b.hubHash
+}
+
+// Check implements healthz.HealthChecker, it fails while status writes to the hub are paused.
+func (b *HubCircuitBreaker) Check(_ *http.Request) error {
+	if b.Degraded() {
+		return ErrHubUnavailable
+	}
+	return nil
+}
+
+func (b *HubCircuitBreaker) reset() {
+	b.consecutiveFailures = 0
+	b.openUntil = time.Time{}
+	hubDegraded.WithLabelValues(b.hubHash).Set(0)
+}
+
+// IsHubConnectionError checks if the error is caused by that the hub apiserver is unreachable or does not respond.
+func IsHubConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if goerrors.Is(err, ErrHubUnavailable) || utilnet.IsConnectionRefused(err) || utilnet.IsConnectionReset(err) {
+		return true
+	}
+	if errors.IsTimeout(err) || errors.IsServerTimeout(err) || errors.IsServiceUnavailable(err) {
+		return true
+	}
+	var netErr net.Error
+	return goerrors.As(err, &netErr) && netErr.Timeout()
+}
+
+// circuitBreakingManifestWorkClient guards the status writes of a manifestwork client with a HubCircuitBreaker.
+type circuitBreakingManifestWorkClient struct {
+	workv1client.ManifestWorkInterface
+	breaker *HubCircuitBreaker
+}
+
+// NewCircuitBreakingManifestWorkClient returns a manifestwork client whose status writes are guarded by the breaker.
+func NewCircuitBreakingManifestWorkClient(
+	client workv1client.ManifestWorkInterface, breaker *HubCircuitBreaker) workv1client.ManifestWorkInterface {
+	return &circuitBreakingManifestWorkClient{ManifestWorkInterface: client, breaker: breaker}
+}
+
+func (c *circuitBreakingManifestWorkClient) UpdateStatus(
+	ctx context.Context, manifestWork *workapiv1.ManifestWork, opts metav1.UpdateOptions) (*workapiv1.ManifestWork, error) {
+	if err := c.breaker.Allow(ctx); err != nil {
+		return nil, fmt.Errorf("failed to update status of manifestwork %s: %w", manifestWork.Name, err)
+	}
+
+	updated, err := c.ManifestWorkInterface.UpdateStatus(ctx, manifestWork, opts)
+	c.breaker.Record(err)
+	return updated, err
+}
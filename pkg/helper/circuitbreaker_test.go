@@ -0,0 +1,147 @@
+package helper
+
+import (
+	"context"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/component-base/metrics/testutil"
+	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+func TestHubCircuitBreaker(t *testing.T) {
+	connectionRefused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+
+	work := &workapiv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "work1", Namespace: "cluster1"}}
+	fakeClient := fakeworkclient.NewSimpleClientset(work)
+	hubDown := true
+	fakeClient.PrependReactor("*", "manifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if hubDown {
+			return true, nil, connectionRefused
+		}
+		return false, nil, nil
+	})
+
+	fakeClock := clock.NewFakeClock(time.Now())
+	breaker := NewHubCircuitBreaker("hub1", 3, time.Minute, func(ctx context.Context) error {
+		_, err := fakeClient.WorkV1().ManifestWorks("cluster1").List(ctx, metav1.ListOptions{Limit: 1})
+		return err
+	})
+	breaker.clock = fakeClock
+	client := NewCircuitBreakingManifestWorkClient(fakeClient.WorkV1().ManifestWorks("cluster1"), breaker)
+
+	updateStatus := func() error {
+		_, err := client.UpdateStatus(context.TODO(), work, metav1.UpdateOptions{})
+		return err
+	}
+	assertRequests := func(expected int) {
+		if actual := len(fakeClient.Actions()); actual != expected {
+			t.Fatalf("expected %d requests to hub, but got %d", expected, actual)
+		}
+		fakeClient.ClearActions()
+	}
+
+	// writes go through until the failure threshold is reached
+	for i := 0; i < 3; i++ {
+		if err := updateStatus(); !IsHubConnectionError(err) {
+			t.Fatalf("expected connection error, but got %v", err)
+		}
+	}
+	assertRequests(3)
+	if !breaker.Degraded() || breaker.Check(nil) == nil {
+		t.Fatalf("expected hub degraded")
+	}
+
+	// writes fail fast within the backoff window
+	if err := updateStatus(); err == nil {
+		t.Fatalf("expected error")
+	}
+	assertRequests(0)
+
+	// the hub is probed after the backoff window, writes are still paused if the probe fails
+	fakeClock.Step(2 * time.Minute)
+	if err := updateStatus(); err == nil {
+		t.Fatalf("expected error")
+	}
+	assertRequests(1)
+	if !breaker.Degraded() {
+		t.Fatalf("expected hub degraded")
+	}
+
+	// writes resume once the probe succeeds
+	hubDown = false
+	fakeClock.Step(2 * time.Minute)
+	if err := updateStatus(); err != nil {
+		t.Fatal(err)
+	}
+	assertRequests(2)
+	if breaker.Degraded() || breaker.Check(nil) != nil {
+		t.Fatalf("expected hub not degraded")
+	}
+}
+
+func TestHubCircuitBreakerProbeWithoutLock(t *testing.T) {
+	connectionRefused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	probing, probed := make(chan struct{}), make(chan struct{})
+	breaker := NewHubCircuitBreaker("hub1", 1, time.Minute, func(ctx context.Context) error {
+		close(probing)
+		<-probed
+		return nil
+	})
+	fakeClock := clock.NewFakeClock(time.Now())
+	breaker.clock = fakeClock
+	breaker.Record(connectionRefused)
+	fakeClock.Step(2 * time.Minute)
+
+	done := make(chan error)
+	go func() {
+		done <- breaker.Allow(context.TODO())
+	}()
+	<-probing
+
+	// the other writes fail fast and the state is readable while the hub is probed
+	if err := breaker.Allow(context.TODO()); err != ErrHubUnavailable {
+		t.Errorf("expected hub unavailable while probing, but got %v", err)
+	}
+	if !breaker.Degraded() {
+		t.Errorf("expected hub degraded while probing")
+	}
+
+	close(probed)
+	if err := <-done; err != nil {
+		t.Errorf("expected writes resumed once the probe succeeds, but got %v", err)
+	}
+	if breaker.Degraded() {
+		t.Errorf("expected hub not degraded")
+	}
+}
+
+func TestHubCircuitBreakerDegradedByHub(t *testing.T) {
+	connectionRefused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	hubDegraded.Reset()
+	probe := func(ctx context.Context) error { return nil }
+	breaker1 := NewHubCircuitBreaker("hub1", 1, time.Minute, probe)
+	breaker2 := NewHubCircuitBreaker("hub2", 1, time.Minute, probe)
+
+	breaker1.Record(connectionRefused)
+	breaker2.Record(connectionRefused)
+	// one hub recovering does not hide the outage of the other
+	breaker1.Record(nil)
+	if value, _ := testutil.GetGaugeMetricValue(hubDegraded.WithLabelValues("hub1")); value != 0 {
+		t.Errorf("expected hub1 not degraded, but got %v", value)
+	}
+	if value, _ := testutil.GetGaugeMetricValue(hubDegraded.WithLabelValues("hub2")); value != 1 {
+		t.Errorf("expected hub2 degraded, but got %v", value)
+	}
+	if breaker1.Name() == breaker2.Name() {
+		t.Errorf("expected the readiness checks of the hubs named differently, but got %q", breaker1.Name())
+	}
+}
@@ -0,0 +1,51 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+// CompletionRule defines the status condition of the resource of a manifest indicating the manifest is completed.
+type CompletionRule struct {
+	Ordinal       int32  `json:"ordinal"`
+	ConditionType string `json:"conditionType"`
+}
+
+// CompletionRules returns the completion rules of the manifestwork. Nil is returned if the manifestwork is not
+// a run-to-completion payload.
+func CompletionRules(manifestWork *workapiv1.ManifestWork) ([]CompletionRule, error) {
+	value, ok := manifestWork.Annotations[constants.CompletionRulesAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	rules := []CompletionRule{}
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("invalid completion rules of manifestwork %s: %w", manifestWork.Name, err)
+	}
+	for _, rule := range rules {
+		if len(rule.ConditionType) == 0 {
+			return nil, fmt.Errorf("invalid completion rules of manifestwork %s: conditionType must be set", manifestWork.Name)
+		}
+	}
+	return rules, nil
+}
+
+// IsWorkCompleted checks if the manifestwork is completed. The completion of a manifestwork is kept after its
+// spec is changed, unless resetting the completion on update is allowed explicitly.
+func IsWorkCompleted(manifestWork *workapiv1.ManifestWork) bool {
+	condition := meta.FindStatusCondition(manifestWork.Status.Conditions, constants.WorkCompleted)
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		return false
+	}
+
+	if manifestWork.Annotations[constants.ResetCompletionOnUpdateAnnotationKey] == "true" {
+		return condition.ObservedGeneration == manifestWork.Generation
+	}
+	return true
+}
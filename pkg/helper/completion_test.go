@@ -0,0 +1,103 @@
+package helper
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+func TestIsWorkCompleted(t *testing.T) {
+	cases := []struct {
+		name               string
+		annotations        map[string]string
+		generation         int64
+		completedCondition *metav1.Condition
+		expected           bool
+	}{
+		{
+			name:     "no completed condition",
+			expected: false,
+		},
+		{
+			name:               "completion rules not met",
+			completedCondition: &metav1.Condition{Type: constants.WorkCompleted, Status: metav1.ConditionFalse},
+			expected:           false,
+		},
+		{
+			name:               "completed",
+			completedCondition: &metav1.Condition{Type: constants.WorkCompleted, Status: metav1.ConditionTrue},
+			expected:           true,
+		},
+		{
+			name:               "keep completed after spec changes",
+			generation:         2,
+			completedCondition: &metav1.Condition{Type: constants.WorkCompleted, Status: metav1.ConditionTrue, ObservedGeneration: 1},
+			expected:           true,
+		},
+		{
+			name:               "reset completion after spec changes",
+			annotations:        map[string]string{constants.ResetCompletionOnUpdateAnnotationKey: "true"},
+			generation:         2,
+			completedCondition: &metav1.Condition{Type: constants.WorkCompleted, Status: metav1.ConditionTrue, ObservedGeneration: 1},
+			expected:           false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work := &workapiv1.ManifestWork{
+				ObjectMeta: metav1.ObjectMeta{Name: "work", Annotations: c.annotations, Generation: c.generation},
+			}
+			if c.completedCondition != nil {
+				work.Status.Conditions = []metav1.Condition{*c.completedCondition}
+			}
+
+			if actual := IsWorkCompleted(work); actual != c.expected {
+				t.Errorf("expected %t, but got %t", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCompletionRules(t *testing.T) {
+	cases := []struct {
+		name          string
+		annotations   map[string]string
+		expectedRules int
+		expectedErr   bool
+	}{
+		{
+			name: "no completion rules",
+		},
+		{
+			name:          "valid completion rules",
+			annotations:   map[string]string{constants.CompletionRulesAnnotationKey: `[{"ordinal":0,"conditionType":"Complete"},{"ordinal":1,"conditionType":"Ready"}]`},
+			expectedRules: 2,
+		},
+		{
+			name:        "invalid json",
+			annotations: map[string]string{constants.CompletionRulesAnnotationKey: `{`},
+			expectedErr: true,
+		},
+		{
+			name:        "missing condition type",
+			annotations: map[string]string{constants.CompletionRulesAnnotationKey: `[{"ordinal":0}]`},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work := &workapiv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "work", Annotations: c.annotations}}
+			rules, err := CompletionRules(work)
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("expected error %t, but got %v", c.expectedErr, err)
+			}
+			if len(rules) != c.expectedRules {
+				t.Errorf("expected %d rules, but got %v", c.expectedRules, rules)
+			}
+		})
+	}
+}
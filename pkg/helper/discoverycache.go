@@ -0,0 +1,223 @@
+package helper
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+)
+
+const (
+	discoveryCacheHit         = "hit"
+	discoveryCacheNegativeHit = "negative_hit"
+	discoveryCacheMiss        = "miss"
+)
+
+var discoveryCacheLookups = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Name: "work_agent_discovery_cache_lookups_total",
+		Help: "Number of lookups of the resources of the spoke in the discovery cache by result. It is hit if the lookup is " +
+			"served by the cache, negative_hit if the resource was recently not found, and miss if the discovery is refreshed.",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	legacyregistry.MustRegister(discoveryCacheLookups)
+}
+
+// CachedRESTMapper is a RESTMapper of the resources discovered from the spoke, which caches the discovery for a TTL.
+// A lookup of a resource not found in the cache refreshes the discovery, e.g. a CRD is just installed, and a resource
+// still not found is cached for a shorter negative TTL, so that the manifestworks waiting on a CRD do not refresh the
+// discovery on every reconcile. The cache is invalidated by the changes of the CRDs, see InvalidationHandler.
+type CachedRESTMapper struct {
+	lock            sync.Mutex
+	discoveryClient discovery.DiscoveryInterface
+	ttl             time.Duration
+	negativeTTL     time.Duration
+	clock           clock.Clock
+	mapper          meta.RESTMapper
+	expireAt        time.Time
+	// misses are the expiry times of the lookups of the resources not found.
+	misses map[string]time.Time
+}
+
+// NewCachedRESTMapper returns a CachedRESTMapper. The discovery is never expired if the ttl is not positive, and the
+// resources not found are not cached if the negativeTTL is not positive.
+func NewCachedRESTMapper(discoveryClient discovery.DiscoveryInterface, ttl, negativeTTL time.Duration) *CachedRESTMapper {
+	return &CachedRESTMapper{
+		discoveryClient: discoveryClient,
+		ttl:             ttl,
+		negativeTTL:     negativeTTL,
+		clock:           clock.RealClock{},
+		misses:          map[string]time.Time{},
+	}
+}
+
+// Invalidate drops the cached discovery and the resources not found, the discovery is refreshed by the next lookup.
+func (m *CachedRESTMapper) Invalidate() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.mapper = nil
+	m.misses = map[string]time.Time{}
+}
+
+// InvalidationHandler returns the event handler of an informer of the CRDs on the spoke, which invalidates the cache
+// once a CRD is added, changed or deleted. Resyncs do not invalidate the cache.
+func (m *CachedRESTMapper) InvalidationHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			m.Invalidate()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldAccessor, err := meta.Accessor(oldObj)
+			if err != nil {
+				return
+			}
+			newAccessor, err := meta.Accessor(newObj)
+			if err != nil {
+				return
+			}
+			if oldAccessor.GetResourceVersion() != newAccessor.GetResourceVersion() {
+				m.Invalidate()
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			m.Invalidate()
+		},
+	}
+}
+
+// lookup runs the lookup against the cached discovery. The key identifies the lookup in the negative cache.
+func (m *CachedRESTMapper) lookup(key string, lookup func(mapper meta.RESTMapper) error) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.clock.Now()
+	refreshed := false
+	if m.mapper == nil || (m.ttl > 0 && !now.Before(m.expireAt)) {
+		if err := m.refresh(now); err != nil {
+			return err
+		}
+		refreshed = true
+	}
+
+	err := lookup(m.mapper)
+	switch {
+	case !meta.IsNoMatchError(err) && refreshed:
+		discoveryCacheLookups.WithLabelValues(discoveryCacheMiss).Inc()
+		return err
+	case !meta.IsNoMatchError(err):
+		discoveryCacheLookups.WithLabelValues(discoveryCacheHit).Inc()
+		return err
+	case !refreshed && now.Before(m.misses[key]):
+		discoveryCacheLookups.WithLabelValues(discoveryCacheNegativeHit).Inc()
+		return err
+	}
+
+	discoveryCacheLookups.WithLabelValues(discoveryCacheMiss).Inc()
+	if !refreshed {
+		if err := m.refresh(now); err != nil {
+			return err
+		}
+		err = lookup(m.mapper)
+	}
+	if meta.IsNoMatchError(err) && m.negativeTTL > 0 {
+		m.addMiss(key, now)
+	}
+	return err
+}
+
+func (m *CachedRESTMapper) refresh(now time.Time) error {
+	groupResources, err := restmapper.GetAPIGroupResources(m.discoveryClient)
+	if err != nil {
+		return fmt.Errorf("failed to discover the resources of the spoke: %w", err)
+	}
+	klog.V(4).Infof("Refreshed the discovery of the resources of the spoke")
+	m.mapper = restmapper.NewDiscoveryRESTMapper(groupResources)
+	m.expireAt = now.Add(m.ttl)
+	return nil
+}
+
+// addMiss caches the lookup not found, the expired misses are dropped to bound the size of the cache.
+func (m *CachedRESTMapper) addMiss(key string, now time.Time) {
+	for k, expireAt := range m.misses {
+		if !now.Before(expireAt) {
+			delete(m.misses, k)
+		}
+	}
+	m.misses[key] = now.Add(m.negativeTTL)
+}
+
+// KindFor implements meta.RESTMapper.
+func (m *CachedRESTMapper) KindFor(resource schema.GroupVersionResource) (gvk schema.GroupVersionKind, err error) {
+	err = m.lookup(fmt.Sprintf("resource %s", resource), func(mapper meta.RESTMapper) (err error) {
+		gvk, err = mapper.KindFor(resource)
+		return err
+	})
+	return gvk, err
+}
+
+// KindsFor implements meta.RESTMapper.
+func (m *CachedRESTMapper) KindsFor(resource schema.GroupVersionResource) (gvks []schema.GroupVersionKind, err error) {
+	err = m.lookup(fmt.Sprintf("resource %s", resource), func(mapper meta.RESTMapper) (err error) {
+		gvks, err = mapper.KindsFor(resource)
+		return err
+	})
+	return gvks, err
+}
+
+// ResourceFor implements meta.RESTMapper.
+func (m *CachedRESTMapper) ResourceFor(input schema.GroupVersionResource) (gvr schema.GroupVersionResource, err error) {
+	err = m.lookup(fmt.Sprintf("resource %s", input), func(mapper meta.RESTMapper) (err error) {
+		gvr, err = mapper.ResourceFor(input)
+		return err
+	})
+	return gvr, err
+}
+
+// ResourcesFor implements meta.RESTMapper.
+func (m *CachedRESTMapper) ResourcesFor(input schema.GroupVersionResource) (gvrs []schema.GroupVersionResource, err error) {
+	err = m.lookup(fmt.Sprintf("resource %s", input), func(mapper meta.RESTMapper) (err error) {
+		gvrs, err = mapper.ResourcesFor(input)
+		return err
+	})
+	return gvrs, err
+}
+
+// RESTMapping implements meta.RESTMapper.
+func (m *CachedRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (mapping *meta.RESTMapping, err error) {
+	err = m.lookup(fmt.Sprintf("kind %s %v", gk, versions), func(mapper meta.RESTMapper) (err error) {
+		mapping, err = mapper.RESTMapping(gk, versions...)
+		return err
+	})
+	return mapping, err
+}
+
+// RESTMappings implements meta.RESTMapper.
+func (m *CachedRESTMapper) RESTMappings(gk schema.GroupKind, versions ...string) (mappings []*meta.RESTMapping, err error) {
+	err = m.lookup(fmt.Sprintf("kind %s %v", gk, versions), func(mapper meta.RESTMapper) (err error) {
+		mappings, err = mapper.RESTMappings(gk, versions...)
+		return err
+	})
+	return mappings, err
+}
+
+// ResourceSingularizer implements meta.RESTMapper.
+func (m *CachedRESTMapper) ResourceSingularizer(resource string) (singular string, err error) {
+	err = m.lookup(fmt.Sprintf("singular %s", resource), func(mapper meta.RESTMapper) (err error) {
+		singular, err = mapper.ResourceSingularizer(resource)
+		return err
+	})
+	return singular, err
+}
@@ -0,0 +1,117 @@
+package helper
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// countingDiscovery counts the discoveries of the resources.
+type countingDiscovery struct {
+	*fakediscovery.FakeDiscovery
+	discoveries int
+}
+
+func (d *countingDiscovery) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
+	d.discoveries++
+	return d.FakeDiscovery.ServerGroupsAndResources()
+}
+
+func TestCachedRESTMapper(t *testing.T) {
+	discoveryClient := &countingDiscovery{
+		FakeDiscovery: &fakediscovery.FakeDiscovery{
+			Fake: &clienttesting.Fake{
+				Resources: []*metav1.APIResourceList{
+					{
+						GroupVersion: "v1",
+						APIResources: []metav1.APIResource{{Name: "secrets", Namespaced: true, Kind: "Secret"}},
+					},
+				},
+			},
+		},
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	mapper := NewCachedRESTMapper(discoveryClient, 10*time.Minute, 30*time.Second)
+	mapper.clock = fakeClock
+
+	secretKind := schema.GroupKind{Kind: "Secret"}
+	crKind := schema.GroupKind{Group: "test.io", Kind: "Foo"}
+	assertDiscoveries := func(expected int) {
+		t.Helper()
+		if discoveryClient.discoveries != expected {
+			t.Fatalf("expected %d discoveries, but got %d", expected, discoveryClient.discoveries)
+		}
+	}
+	assertMapping := func(gk schema.GroupKind, found bool) {
+		t.Helper()
+		_, err := mapper.RESTMapping(gk, "v1")
+		switch {
+		case found && err != nil:
+			t.Fatalf("expected %s found, but got %v", gk, err)
+		case !found && !meta.IsNoMatchError(err):
+			t.Fatalf("expected %s not found, but got %v", gk, err)
+		}
+	}
+
+	// the discovery is cached
+	for i := 0; i < 10; i++ {
+		assertMapping(secretKind, true)
+	}
+	assertDiscoveries(1)
+
+	// the first miss refreshes the discovery, the repeated misses are cached
+	for i := 0; i < 10; i++ {
+		assertMapping(crKind, false)
+	}
+	assertDiscoveries(2)
+
+	// the miss is refreshed once the negative cache is expired
+	fakeClock.Step(30 * time.Second)
+	for i := 0; i < 10; i++ {
+		assertMapping(crKind, false)
+	}
+	assertDiscoveries(3)
+
+	// the CRD is installed and the cache is invalidated by the CRD informer
+	discoveryClient.Resources = append(discoveryClient.Resources, &metav1.APIResourceList{
+		GroupVersion: "test.io/v1",
+		APIResources: []metav1.APIResource{{Name: "foos", Namespaced: true, Kind: "Foo"}},
+	})
+	mapper.InvalidationHandler().OnAdd(&metav1.ObjectMeta{Name: "foos.test.io", ResourceVersion: "1"})
+	for i := 0; i < 10; i++ {
+		assertMapping(crKind, true)
+	}
+	assertDiscoveries(4)
+
+	// resyncs do not invalidate the cache
+	crd := &metav1.ObjectMeta{Name: "foos.test.io", ResourceVersion: "1"}
+	mapper.InvalidationHandler().OnUpdate(crd, crd)
+	assertMapping(crKind, true)
+	assertDiscoveries(4)
+
+	// the cache is refreshed once it is expired
+	fakeClock.Step(10 * time.Minute)
+	assertMapping(secretKind, true)
+	assertMapping(crKind, true)
+	assertDiscoveries(5)
+}
+
+func TestCachedRESTMapperWithoutNegativeCache(t *testing.T) {
+	discoveryClient := &countingDiscovery{FakeDiscovery: &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}}
+	mapper := NewCachedRESTMapper(discoveryClient, 10*time.Minute, 0)
+
+	for i := 0; i < 3; i++ {
+		if _, err := mapper.RESTMapping(schema.GroupKind{Group: "test.io", Kind: "Foo"}, "v1"); !meta.IsNoMatchError(err) {
+			t.Fatalf("expected no match, but got %v", err)
+		}
+	}
+	if discoveryClient.discoveries != 3 {
+		t.Errorf("expected every miss to refresh the discovery, but got %d discoveries", discoveryClient.discoveries)
+	}
+}
@@ -0,0 +1,233 @@
+package helper
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+// DriftPolicy returns the drift policy of the manifestwork, which is DriftPolicyReportOnly unless the annotation
+// DriftPolicyAnnotationKey is set to DriftPolicyRemediate.
+func DriftPolicy(manifestWork *workapiv1.ManifestWork) string {
+	if manifestWork.Annotations[constants.DriftPolicyAnnotationKey] == constants.DriftPolicyRemediate {
+		return constants.DriftPolicyRemediate
+	}
+	return constants.DriftPolicyReportOnly
+}
+
+// HashAgentOwnedFields returns the hash of the fields of the object owned by the agent, which are the fields set
+// in the required object except the apiVersion, kind and status, and the metadata except the labels and
+// annotations. The values of the fields are taken from the object, lists are hashed as a whole. The hash is stable
+// across the ordering of the keys.
+func HashAgentOwnedFields(required *unstructured.Unstructured, obj runtime.Object) (string, error) {
+	actual, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return "", err
+	}
+
+	owned := map[string]interface{}{}
+	for key, value := range required.Object {
+		switch key {
+		case "apiVersion", "kind", "status":
+			continue
+		case "metadata":
+			requiredMetadata, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			actualMetadata, _ := actual["metadata"].(map[string]interface{})
+			metadata := map[string]interface{}{}
+			for _, field := range []string{"labels", "annotations"} {
+				if requiredValue, ok := requiredMetadata[field]; ok {
+					metadata[field] = projectFields(requiredValue, actualMetadata[field])
+				}
+			}
+			owned[key] = metadata
+		default:
+			owned[key] = projectFields(value, actual[key])
+		}
+	}
+
+	// the keys of maps are sorted when they are marshalled
+	data, err := json.Marshal(owned)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// projectFields returns the fields of the actual value which are set in the required value.
+func projectFields(required, actual interface{}) interface{} {
+	requiredMap, ok := required.(map[string]interface{})
+	if !ok {
+		return actual
+	}
+	actualMap, ok := actual.(map[string]interface{})
+	if !ok {
+		return actual
+	}
+
+	projected := map[string]interface{}{}
+	for key, value := range requiredMap {
+		if actualValue, ok := actualMap[key]; ok {
+			projected[key] = projectFields(value, actualValue)
+		}
+	}
+	return projected
+}
+
+// DriftBaseline is the state of an applied resource right after it is applied, it is compared with the live
+// resource to detect the modifications out of band.
+type DriftBaseline struct {
+	ResourceMeta workapiv1.ManifestResourceMeta
+	Generation   int64
+	Hash         string
+	// required is the raw manifest the resource is applied from, it is usually shared with the manifestwork in the
+	// informer store. It is only decoded once the live resource is compared with the baseline, so that the decoded
+	// objects of all the manifests are not retained between the reconciles.
+	required []byte
+}
+
+// NewDriftBaseline returns the baseline of the resource applied from the raw required manifest.
+func NewDriftBaseline(resourceMeta workapiv1.ManifestResourceMeta, required []byte, applied runtime.Object) (DriftBaseline, error) {
+	requiredObj := &unstructured.Unstructured{}
+	if err := requiredObj.UnmarshalJSON(required); err != nil {
+		return DriftBaseline{}, err
+	}
+	hash, err := HashAgentOwnedFields(requiredObj, applied)
+	if err != nil {
+		return DriftBaseline{}, err
+	}
+	generation := int64(0)
+	if obj, ok := applied.(interface{ GetGeneration() int64 }); ok {
+		generation = obj.GetGeneration()
+	}
+	return DriftBaseline{
+		ResourceMeta: resourceMeta,
+		Generation:   generation,
+		Hash:         hash,
+		required:     required,
+	}, nil
+}
+
+type driftKey struct {
+	group     string
+	resource  string
+	namespace string
+	name      string
+}
+
+func newDriftKey(resourceMeta workapiv1.ManifestResourceMeta) driftKey {
+	return driftKey{
+		group:     resourceMeta.Group,
+		resource:  resourceMeta.Resource,
+		namespace: resourceMeta.Namespace,
+		name:      resourceMeta.Name,
+	}
+}
+
+// DriftTracker tracks the baselines of the resources applied by the manifestworks in memory, so that the
+// modifications of the resources out of band are detected before the next apply. The baselines are recorded
+// again once the manifestworks are applied after the agent restarts.
+type DriftTracker struct {
+	lock      sync.Mutex
+	baselines map[string]map[driftKey]DriftBaseline
+	// remediate requests the manifestwork to be applied again
+	remediate func(manifestWorkName string)
+}
+
+// NewDriftTracker returns a DriftTracker.
+func NewDriftTracker() *DriftTracker {
+	return &DriftTracker{
+		baselines: map[string]map[driftKey]DriftBaseline{},
+	}
+}
+
+// SetBaselines replaces the baselines of the resources of the manifestwork. It does nothing if the tracker is nil.
+func (t *DriftTracker) SetBaselines(manifestWorkName string, baselines []DriftBaseline) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if len(baselines) == 0 {
+		delete(t.baselines, manifestWorkName)
+		return
+	}
+	workBaselines := map[driftKey]DriftBaseline{}
+	for _, baseline := range baselines {
+		workBaselines[newDriftKey(baseline.ResourceMeta)] = baseline
+	}
+	t.baselines[manifestWorkName] = workBaselines
+}
+
+// Baseline returns the baseline of the resource recorded for the manifestwork. False is returned if the tracker is nil
+// or the baseline is unknown.
+func (t *DriftTracker) Baseline(manifestWorkName string, resourceMeta workapiv1.ManifestResourceMeta) (DriftBaseline, bool) {
+	if t == nil {
+		return DriftBaseline{}, false
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	baseline, ok := t.baselines[manifestWorkName][newDriftKey(resourceMeta)]
+	return baseline, ok
+}
+
+// IsDrifted returns true if the agent owned fields of the live resource are modified since it was applied by the
+// manifestwork. The hash of the fields is only computed if the generation of the resource advanced, or the
+// resource does not track its generation. False is returned if the tracker is nil or the baseline is unknown.
+func (t *DriftTracker) IsDrifted(manifestWorkName string, resourceMeta workapiv1.ManifestResourceMeta, live *unstructured.Unstructured) bool {
+	if t == nil || live == nil {
+		return false
+	}
+	t.lock.Lock()
+	baseline, ok := t.baselines[manifestWorkName][newDriftKey(resourceMeta)]
+	t.lock.Unlock()
+	if !ok {
+		return false
+	}
+
+	if baseline.Generation != 0 && live.GetGeneration() == baseline.Generation {
+		return false
+	}
+	required := &unstructured.Unstructured{}
+	if err := required.UnmarshalJSON(baseline.required); err != nil {
+		return false
+	}
+	hash, err := HashAgentOwnedFields(required, live)
+	if err != nil {
+		return false
+	}
+	return hash != baseline.Hash
+}
+
+// SetRemediateFunc sets the function requesting a manifestwork to be applied again.
+func (t *DriftTracker) SetRemediateFunc(remediate func(manifestWorkName string)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.remediate = remediate
+}
+
+// Remediate requests the manifestwork with drifted resources to be applied again. It does nothing if the tracker
+// is nil or no function is set to apply the manifestwork.
+func (t *DriftTracker) Remediate(manifestWorkName string) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	remediate := t.remediate
+	t.lock.Unlock()
+
+	if remediate != nil {
+		remediate(manifestWorkName)
+	}
+}
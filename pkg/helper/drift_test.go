@@ -0,0 +1,222 @@
+package helper
+
+import (
+	"fmt"
+	goruntime "runtime"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+func newDriftTestObject(t *testing.T, data string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestHashAgentOwnedFields(t *testing.T) {
+	required := newDriftTestObject(t, `{"apiVersion": "v1", "kind": "ConfigMap",
+		"metadata": {"name": "cm1", "namespace": "ns1", "labels": {"a": "1", "b": "2"}},
+		"data": {"x": "1", "y": "2"}}`)
+	// the same object with the keys in another order
+	reordered := newDriftTestObject(t, `{"data": {"y": "2", "x": "1"},
+		"metadata": {"labels": {"b": "2", "a": "1"}, "namespace": "ns1", "name": "cm1"},
+		"kind": "ConfigMap", "apiVersion": "v1"}`)
+
+	cases := []struct {
+		name         string
+		obj          runtime.Object
+		expectedSame bool
+	}{
+		{
+			name:         "keys reordered",
+			obj:          reordered,
+			expectedSame: true,
+		},
+		{
+			name: "typed object with fields not owned by the agent",
+			obj: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cm1", Namespace: "ns1", ResourceVersion: "10", Generation: 2,
+					Labels:      map[string]string{"a": "1", "b": "2"},
+					Annotations: map[string]string{"other": "value"},
+				},
+				Data: map[string]string{"x": "1", "y": "2", "z": "3"},
+			},
+			expectedSame: true,
+		},
+		{
+			name:         "owned field modified",
+			obj:          newDriftTestObject(t, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "cm1", "labels": {"a": "1", "b": "2"}}, "data": {"x": "2", "y": "2"}}`),
+			expectedSame: false,
+		},
+		{
+			name:         "owned label removed",
+			obj:          newDriftTestObject(t, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "cm1", "labels": {"a": "1"}}, "data": {"x": "1", "y": "2"}}`),
+			expectedSame: false,
+		},
+	}
+
+	expected, err := HashAgentOwnedFields(required, required)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := HashAgentOwnedFields(required, c.obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (actual == expected) != c.expectedSame {
+				t.Errorf("expected same hash %v, but got %q and %q", c.expectedSame, expected, actual)
+			}
+		})
+	}
+}
+
+func TestDriftTracker(t *testing.T) {
+	required := newDriftTestObject(t, `{"apiVersion": "apps/v1", "kind": "Deployment",
+		"metadata": {"name": "d1", "namespace": "ns1"}, "spec": {"replicas": 1}}`)
+	applied := required.DeepCopy()
+	applied.SetGeneration(1)
+	_ = unstructured.SetNestedField(applied.Object, "RollingUpdate", "spec", "strategy", "type")
+	resourceMeta := workapiv1.ManifestResourceMeta{Group: "apps", Resource: "deployments", Namespace: "ns1", Name: "d1"}
+
+	requiredData, err := required.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := NewDriftTracker()
+	baseline, err := NewDriftBaseline(resourceMeta, requiredData, applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.SetBaselines("work1", []DriftBaseline{baseline})
+	if recorded, ok := tracker.Baseline("work1", resourceMeta); !ok || recorded.Hash != baseline.Hash {
+		t.Errorf("expected baseline %v recorded, but got %v", baseline, recorded)
+	}
+
+	// the fields not owned by the agent are modified
+	live := applied.DeepCopy()
+	live.SetGeneration(2)
+	_ = unstructured.SetNestedField(live.Object, "Recreate", "spec", "strategy", "type")
+	if tracker.IsDrifted("work1", resourceMeta, live) {
+		t.Errorf("expected resource not drifted")
+	}
+
+	// the fields owned by the agent are modified
+	_ = unstructured.SetNestedField(live.Object, int64(3), "spec", "replicas")
+	if !tracker.IsDrifted("work1", resourceMeta, live) {
+		t.Errorf("expected resource drifted")
+	}
+	if tracker.IsDrifted("work2", resourceMeta, live) {
+		t.Errorf("expected resource of another manifestwork not drifted")
+	}
+
+	// the hash is not computed if the generation does not advance
+	live.SetGeneration(1)
+	if tracker.IsDrifted("work1", resourceMeta, live) {
+		t.Errorf("expected resource not drifted with the same generation")
+	}
+
+	tracker.SetBaselines("work1", nil)
+	live.SetGeneration(2)
+	if tracker.IsDrifted("work1", resourceMeta, live) {
+		t.Errorf("expected resource not drifted without baseline")
+	}
+
+	remediated := []string{}
+	tracker.Remediate("work1")
+	tracker.SetRemediateFunc(func(name string) { remediated = append(remediated, name) })
+	tracker.Remediate("work1")
+	if len(remediated) != 1 || remediated[0] != "work1" {
+		t.Errorf("expected work1 remediated, but got %v", remediated)
+	}
+}
+
+func TestDriftPolicy(t *testing.T) {
+	work := &workapiv1.ManifestWork{}
+	if policy := DriftPolicy(work); policy != constants.DriftPolicyReportOnly {
+		t.Errorf("expected default policy %s, but got %s", constants.DriftPolicyReportOnly, policy)
+	}
+	work.Annotations = map[string]string{constants.DriftPolicyAnnotationKey: constants.DriftPolicyRemediate}
+	if policy := DriftPolicy(work); policy != constants.DriftPolicyRemediate {
+		t.Errorf("expected policy %s, but got %s", constants.DriftPolicyRemediate, policy)
+	}
+}
+
+// BenchmarkDriftBaselinesMemory compares the memory retained between the reconciles of 1,000 manifestworks by the
+// drift baselines keeping the raw manifests, with the decoded manifests retained in addition as they were before.
+// The raw manifests are allocated up front since they are held by the informer store anyway.
+func BenchmarkDriftBaselinesMemory(b *testing.B) {
+	const numOfWorks, numOfManifests = 1000, 5
+
+	manifests := make([][][]byte, numOfWorks)
+	for i := range manifests {
+		for j := 0; j < numOfManifests; j++ {
+			manifests[i] = append(manifests[i], []byte(fmt.Sprintf(`{"apiVersion": "apps/v1", "kind": "Deployment",
+				"metadata": {"name": "deploy%d", "namespace": "ns%d", "labels": {"app": "web"}},
+				"spec": {"replicas": 1, "selector": {"matchLabels": {"app": "web"}}, "template": {
+					"metadata": {"labels": {"app": "web"}},
+					"spec": {"containers": [{"name": "web", "image": "nginx:1.21", "ports": [{"containerPort": 80}],
+						"env": [{"name": "MODE", "value": "production"}, {"name": "LOG_LEVEL", "value": "info"}]}]}}}}`, j, i)))
+		}
+	}
+
+	modes := map[string]bool{"decoded": true, "raw": false}
+	for name, retainDecoded := range modes {
+		b.Run(name, func(b *testing.B) {
+			var retained uint64
+			for n := 0; n < b.N; n++ {
+				before := heapAlloc()
+
+				tracker := NewDriftTracker()
+				decoded := []*unstructured.Unstructured{}
+				for i, workManifests := range manifests {
+					baselines := []DriftBaseline{}
+					for j, raw := range workManifests {
+						required := &unstructured.Unstructured{}
+						if err := required.UnmarshalJSON(raw); err != nil {
+							b.Fatal(err)
+						}
+						resourceMeta := workapiv1.ManifestResourceMeta{
+							Ordinal: int32(j), Group: "apps", Resource: "deployments", Namespace: required.GetNamespace(), Name: required.GetName()}
+						baseline, err := NewDriftBaseline(resourceMeta, raw, required)
+						if err != nil {
+							b.Fatal(err)
+						}
+						baselines = append(baselines, baseline)
+						if retainDecoded {
+							decoded = append(decoded, required)
+						}
+					}
+					tracker.SetBaselines(fmt.Sprintf("work%d", i), baselines)
+				}
+
+				if after := heapAlloc(); after > before {
+					retained += after - before
+				}
+				goruntime.KeepAlive(tracker)
+				goruntime.KeepAlive(decoded)
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "retained-bytes/op")
+		})
+	}
+}
+
+func heapAlloc() uint64 {
+	goruntime.GC()
+	time.Sleep(10 * time.Millisecond)
+	var stats goruntime.MemStats
+	goruntime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
@@ -2,20 +2,27 @@ package helper
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/diff"
 	fakedynamic "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
 	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
 	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
 )
 
 func newCondition(name, status, reason, message string, lastTransition *metav1.Time) metav1.Condition {
@@ -97,8 +104,8 @@ func TestUpdateStatusCondition(t *testing.T) {
 			newCondition:    newCondition("one", "True", "my-reason", "my-message", nil),
 			expectedUpdated: true,
 			expectedConditions: []metav1.Condition{
-				newCondition("two", "True", "my-reason", "my-message", nil),
 				newCondition("one", "True", "my-reason", "my-message", nil),
+				newCondition("two", "True", "my-reason", "my-message", nil),
 			},
 		},
 		{
@@ -110,8 +117,8 @@ func TestUpdateStatusCondition(t *testing.T) {
 			newCondition:    newCondition("one", "False", "my-different-reason", "my-othermessage", nil),
 			expectedUpdated: true,
 			expectedConditions: []metav1.Condition{
-				newCondition("two", "True", "my-reason", "my-message", nil),
 				newCondition("one", "False", "my-different-reason", "my-othermessage", nil),
+				newCondition("two", "True", "my-reason", "my-message", nil),
 			},
 		},
 		{
@@ -123,8 +130,8 @@ func TestUpdateStatusCondition(t *testing.T) {
 			newCondition:    newCondition("one", "True", "my-reason", "my-message", &afterish),
 			expectedUpdated: false,
 			expectedConditions: []metav1.Condition{
-				newCondition("two", "True", "my-reason", "my-message", nil),
 				newCondition("one", "True", "my-reason", "my-message", &beforeish),
+				newCondition("two", "True", "my-reason", "my-message", nil),
 			},
 		},
 	}
@@ -165,6 +172,133 @@ func TestUpdateStatusCondition(t *testing.T) {
 	}
 }
 
+func TestCanonicalStatusConditions(t *testing.T) {
+	nowish := metav1.Now()
+	beforeish := metav1.Time{Time: nowish.Add(-10 * time.Second)}
+
+	cases := []struct {
+		name       string
+		conditions []metav1.Condition
+		expected   []metav1.Condition
+	}{
+		{
+			name:     "empty",
+			expected: []metav1.Condition{},
+		},
+		{
+			name: "sort by type",
+			conditions: []metav1.Condition{
+				newCondition("two", "True", "my-reason", "my-message", &nowish),
+				newCondition("one", "True", "my-reason", "my-message", &nowish),
+			},
+			expected: []metav1.Condition{
+				newCondition("one", "True", "my-reason", "my-message", &nowish),
+				newCondition("two", "True", "my-reason", "my-message", &nowish),
+			},
+		},
+		{
+			name: "keep the newest of the same type",
+			conditions: []metav1.Condition{
+				newCondition("one", "False", "my-reason", "my-message", &nowish),
+				newCondition("one", "True", "my-reason", "my-message", &beforeish),
+			},
+			expected: []metav1.Condition{
+				newCondition("one", "False", "my-reason", "my-message", &nowish),
+			},
+		},
+		{
+			name: "keep the later one with the same transition time",
+			conditions: []metav1.Condition{
+				newCondition("one", "False", "my-reason", "my-message", &nowish),
+				newCondition("one", "True", "my-reason", "my-message", &nowish),
+			},
+			expected: []metav1.Condition{
+				newCondition("one", "True", "my-reason", "my-message", &nowish),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := CanonicalStatusConditions(c.conditions)
+			if !equality.Semantic.DeepEqual(c.expected, actual) {
+				t.Errorf(diff.ObjectDiff(c.expected, actual))
+			}
+		})
+	}
+}
+
+// TestUpdateStatusWithReorderedConditions ensures that merging the same conditions in different orders does not
+// update the status again.
+func TestUpdateStatusWithReorderedConditions(t *testing.T) {
+	nowish := metav1.Now()
+	conditions := []metav1.Condition{
+		newCondition("Applied", "True", "my-reason", "my-message", &nowish),
+		newCondition("Available", "True", "my-reason", "my-message", &nowish),
+		newCondition("Degraded", "False", "my-reason", "my-message", &nowish),
+	}
+	orders := [][]int{{0, 1, 2}, {2, 1, 0}, {1, 0, 2}, {2, 0, 1}}
+
+	mergeFn := func(order []int) UpdateManifestWorkStatusFunc {
+		return func(status *workapiv1.ManifestWorkStatus) error {
+			newConditions := []metav1.Condition{}
+			for _, i := range order {
+				newConditions = append(newConditions, conditions[i])
+			}
+			// the writer rebuilds the conditions, e.g. removes and adds them again
+			for _, condition := range newConditions {
+				meta.RemoveStatusCondition(&status.Conditions, condition.Type)
+			}
+			status.Conditions = MergeStatusConditions(status.Conditions, newConditions)
+			status.ResourceStatus.Manifests = MergeManifestConditions(status.ResourceStatus.Manifests,
+				[]workapiv1.ManifestCondition{newManifestCondition(0, "resource0", newConditions...)})
+			return nil
+		}
+	}
+
+	manifestWork := &workapiv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{Name: "work1", Namespace: "cluster1"},
+	}
+	fakeWorkClient := fakeworkclient.NewSimpleClientset(manifestWork)
+	client := fakeWorkClient.WorkV1().ManifestWorks("cluster1")
+
+	for round := 0; round < 3; round++ {
+		for _, order := range orders {
+			manifestWork, err := client.Get(context.TODO(), "work1", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := UpdateManifestWorkStatus(context.TODO(), client, manifestWork, mergeFn(order)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	updates := 0
+	for _, action := range fakeWorkClient.Actions() {
+		if action.GetVerb() == "update" {
+			updates++
+		}
+	}
+	if updates != 1 {
+		t.Errorf("expected 1 update, but got %d", updates)
+	}
+
+	// the status is not updated even if the existing conditions are not in the canonical form
+	manifestWork, err := client.Get(context.TODO(), "work1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestWork.Status.Conditions = []metav1.Condition{conditions[2], conditions[0], conditions[1], conditions[0]}
+	_, updated, err := UpdateManifestWorkStatus(context.TODO(), client, manifestWork, mergeFn(orders[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated {
+		t.Errorf("expected no update of the reordered conditions")
+	}
+}
+
 // TestSetManifestCondition tests SetManifestCondition function
 func TestMergeManifestConditions(t *testing.T) {
 	transitionTime := metav1.Now()
@@ -265,6 +399,132 @@ func TestMergeManifestConditions(t *testing.T) {
 	}
 }
 
+func TestMergeManifestConditionsByIdentity(t *testing.T) {
+	transitionTime := metav1.NewTime(time.Now().Add(-time.Hour))
+	newResourceCondition := func(ordinal int32, version, name, status string) workapiv1.ManifestCondition {
+		return workapiv1.ManifestCondition{
+			ResourceMeta: workapiv1.ManifestResourceMeta{
+				Ordinal: ordinal, Group: "apps", Version: version, Kind: "Deployment", Resource: "deployments", Namespace: "ns1", Name: name,
+			},
+			Conditions: []metav1.Condition{newCondition("Applied", status, "my-reason", "my-message", &transitionTime)},
+		}
+	}
+
+	cases := []struct {
+		name               string
+		startingConditions []workapiv1.ManifestCondition
+		newConditions      []workapiv1.ManifestCondition
+		// the names of the resources whose condition history is kept
+		expectedKept []string
+	}{
+		{
+			name: "insert a manifest",
+			startingConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1", "a", "True"),
+				newResourceCondition(1, "v1", "b", "True"),
+			},
+			newConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1", "a", "True"),
+				newResourceCondition(1, "v1", "c", "True"),
+				newResourceCondition(2, "v1", "b", "True"),
+			},
+			expectedKept: []string{"a", "b"},
+		},
+		{
+			name: "reorder manifests",
+			startingConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1", "a", "True"),
+				newResourceCondition(1, "v1", "b", "True"),
+			},
+			newConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1", "b", "True"),
+				newResourceCondition(1, "v1", "a", "True"),
+			},
+			expectedKept: []string{"a", "b"},
+		},
+		{
+			name: "remove a manifest",
+			startingConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1", "a", "True"),
+				newResourceCondition(1, "v1", "b", "True"),
+				newResourceCondition(2, "v1", "c", "True"),
+			},
+			newConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1", "a", "True"),
+				newResourceCondition(1, "v1", "c", "True"),
+			},
+			expectedKept: []string{"a", "c"},
+		},
+		{
+			name: "change the version of a manifest",
+			startingConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1beta1", "a", "True"),
+			},
+			newConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1", "a", "True"),
+			},
+			expectedKept: []string{"a"},
+		},
+		{
+			name: "resolve the resource of a manifest",
+			startingConditions: []workapiv1.ManifestCondition{func() workapiv1.ManifestCondition {
+				// the resource is unknown until the CRD is installed
+				condition := newResourceCondition(1, "v1", "a", "True")
+				condition.ResourceMeta.Resource = ""
+				return condition
+			}()},
+			newConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1", "a", "True"),
+			},
+			expectedKept: []string{"a"},
+		},
+		{
+			name: "replace a manifest with another resource at the same ordinal",
+			startingConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1", "a", "False"),
+			},
+			newConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1", "b", "False"),
+			},
+		},
+		{
+			name: "manifests of the same resource are matched by ordinal",
+			startingConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1", "a", "True"),
+				newResourceCondition(1, "v1", "a", "False"),
+			},
+			newConditions: []workapiv1.ManifestCondition{
+				newResourceCondition(0, "v1", "a", "True"),
+				newResourceCondition(1, "v1", "a", "False"),
+			},
+			expectedKept: []string{"a", "a"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			merged := MergeManifestConditions(c.startingConditions, c.newConditions)
+			if len(merged) != len(c.newConditions) {
+				t.Fatalf("expected %d manifest conditions, but got %v", len(c.newConditions), merged)
+			}
+
+			kept := []string{}
+			for i, condition := range merged {
+				if !equality.Semantic.DeepEqual(condition.ResourceMeta, c.newConditions[i].ResourceMeta) {
+					t.Errorf("expected resource meta %v, but got %v", c.newConditions[i].ResourceMeta, condition.ResourceMeta)
+				}
+				if condition.Conditions[0].LastTransitionTime.Equal(&transitionTime) {
+					kept = append(kept, condition.ResourceMeta.Name)
+				}
+			}
+			sort.Strings(kept)
+			if !equality.Semantic.DeepEqual(kept, append([]string{}, c.expectedKept...)) {
+				t.Errorf("expected the condition history of %v kept, but got %v", c.expectedKept, kept)
+			}
+		})
+	}
+}
+
 func TestMergeStatusConditions(t *testing.T) {
 	transitionTime := metav1.Now()
 
@@ -409,6 +669,36 @@ func TestDeleteAppliedResourcess(t *testing.T) {
 			expectedResourcesPendingFinalization: []workapiv1.AppliedManifestResourceMeta{},
 			owner:                                metav1.OwnerReference{Name: "n1", UID: "a"},
 		},
+		{
+			name: "delete resources owned by siblings",
+			existingResources: []runtime.Object{
+				newSecret("ns1", "parent", false, "ns1-parent", metav1.OwnerReference{Name: "n1", UID: "a"}),
+				newSecret("ns1", "child", false, "ns1-child",
+					metav1.OwnerReference{Name: "parent", UID: "ns1-parent"}, metav1.OwnerReference{Name: "n1", UID: "a"}),
+			},
+			resourcesToRemove: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "parent", UID: "ns1-parent"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "child", UID: "ns1-child"},
+				// the resource deleted by the kube garbage collector already
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "collected", UID: "ns1-collected"},
+			},
+			expectedResourcesPendingFinalization: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "parent", UID: "ns1-parent"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "child", UID: "ns1-child"},
+			},
+			owner: metav1.OwnerReference{Name: "n1", UID: "a"},
+		},
+		{
+			name: "skip if owned by a resource not deleted",
+			existingResources: []runtime.Object{
+				newSecret("ns1", "child", false, "ns1-child",
+					metav1.OwnerReference{Name: "parent", UID: "ns1-parent"}, metav1.OwnerReference{Name: "n1", UID: "a"}),
+			},
+			resourcesToRemove: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "child", UID: "ns1-child"},
+			},
+			owner: metav1.OwnerReference{Name: "n1", UID: "a"},
+		},
 	}
 
 	scheme := runtime.NewScheme()
@@ -429,12 +719,122 @@ func TestDeleteAppliedResourcess(t *testing.T) {
 	}
 }
 
+func TestApplyOwnerReferences(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	ownerA := metav1.OwnerReference{Name: "n1", UID: "a"}
+	ownerB := metav1.OwnerReference{Name: "n2", UID: "b"}
+
+	cases := []struct {
+		name            string
+		existingOwners  []metav1.OwnerReference
+		requiredOwner   metav1.OwnerReference
+		lastWriterWins  bool
+		conflicts       int
+		expectedChanged bool
+		expectedOwners  []metav1.OwnerReference
+		expectedPatches int
+	}{
+		{
+			name:            "add owner",
+			existingOwners:  []metav1.OwnerReference{ownerB},
+			requiredOwner:   ownerA,
+			expectedChanged: true,
+			expectedOwners:  []metav1.OwnerReference{ownerB, ownerA},
+			expectedPatches: 1,
+		},
+		{
+			name:            "owner exists",
+			existingOwners:  []metav1.OwnerReference{ownerA},
+			requiredOwner:   ownerA,
+			expectedOwners:  []metav1.OwnerReference{ownerA},
+			expectedPatches: 0,
+		},
+		{
+			name:            "remove owner",
+			existingOwners:  []metav1.OwnerReference{ownerA, ownerB},
+			requiredOwner:   metav1.OwnerReference{Name: "n1", UID: "a-"},
+			expectedChanged: true,
+			expectedOwners:  []metav1.OwnerReference{ownerB},
+			expectedPatches: 1,
+		},
+		{
+			name:            "retry on conflict",
+			existingOwners:  []metav1.OwnerReference{ownerB},
+			requiredOwner:   ownerA,
+			conflicts:       1,
+			expectedChanged: true,
+			expectedOwners:  []metav1.OwnerReference{ownerB, ownerA},
+			expectedPatches: 2,
+		},
+		{
+			name:            "last writer wins",
+			existingOwners:  []metav1.OwnerReference{ownerB},
+			requiredOwner:   ownerA,
+			lastWriterWins:  true,
+			expectedChanged: true,
+			expectedOwners:  []metav1.OwnerReference{ownerB, ownerA},
+			expectedPatches: 1,
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			secret := newSecret("ns1", "n1", false, "ns1-n1", c.existingOwners...)
+			secret.ResourceVersion = "1"
+			fakeDynamicClient := fakedynamic.NewSimpleDynamicClient(scheme, secret)
+
+			conflicts := c.conflicts
+			var patches [][]byte
+			fakeDynamicClient.PrependReactor("patch", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				patches = append(patches, action.(clienttesting.PatchAction).GetPatch())
+				if conflicts > 0 {
+					conflicts--
+					return true, nil, errors.NewConflict(gvr.GroupResource(), "n1", fmt.Errorf("object has been modified"))
+				}
+				return false, nil, nil
+			})
+
+			existing, err := fakeDynamicClient.Resource(gvr).Namespace("ns1").Get(context.TODO(), "n1", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			changed, err := ApplyOwnerReferences(context.TODO(), fakeDynamicClient, gvr, existing, c.requiredOwner, c.lastWriterWins)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != c.expectedChanged {
+				t.Errorf("expected changed %t, but got %t", c.expectedChanged, changed)
+			}
+			if len(patches) != c.expectedPatches {
+				t.Errorf("expected %d patches, but got %d", c.expectedPatches, len(patches))
+			}
+			for _, patch := range patches {
+				if hasResourceVersion := strings.Contains(string(patch), "resourceVersion"); hasResourceVersion == c.lastWriterWins {
+					t.Errorf("unexpected resourceVersion in patch %s", string(patch))
+				}
+			}
+
+			actual, err := fakeDynamicClient.Resource(gvr).Namespace("ns1").Get(context.TODO(), "n1", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(actual.GetOwnerReferences(), c.expectedOwners) {
+				t.Errorf(diff.ObjectDiff(c.expectedOwners, actual.GetOwnerReferences()))
+			}
+		})
+	}
+}
+
 func TestRemoveFinalizer(t *testing.T) {
 	cases := []struct {
 		name               string
 		obj                runtime.Object
 		finalizerToRemove  string
 		expectedFinalizers []string
+		expectedRemoved    bool
 	}{
 		{
 			name:               "No finalizers in object",
@@ -442,23 +842,34 @@ func TestRemoveFinalizer(t *testing.T) {
 			finalizerToRemove:  "a",
 			expectedFinalizers: []string{},
 		},
+		{
+			name:               "finalizer not present",
+			obj:                &workapiv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Finalizers: []string{"b", "c"}}},
+			finalizerToRemove:  "a",
+			expectedFinalizers: []string{"b", "c"},
+		},
 		{
 			name:               "remove finalizer",
 			obj:                &workapiv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Finalizers: []string{"a"}}},
 			finalizerToRemove:  "a",
 			expectedFinalizers: []string{},
+			expectedRemoved:    true,
 		},
 		{
 			name:               "multiple finalizers",
 			obj:                &workapiv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Finalizers: []string{"b", "a", "c"}}},
 			finalizerToRemove:  "a",
 			expectedFinalizers: []string{"b", "c"},
+			expectedRemoved:    true,
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			RemoveFinalizer(c.obj, c.finalizerToRemove)
+			removed := RemoveFinalizer(c.obj, c.finalizerToRemove)
+			if removed != c.expectedRemoved {
+				t.Errorf("Expected removed %t, but got %t", c.expectedRemoved, removed)
+			}
 			accessor, _ := meta.Accessor(c.obj)
 			finalizers := accessor.GetFinalizers()
 			if !equality.Semantic.DeepEqual(finalizers, c.expectedFinalizers) {
@@ -468,6 +879,75 @@ func TestRemoveFinalizer(t *testing.T) {
 	}
 }
 
+func TestFinalizerPatch(t *testing.T) {
+	cases := []struct {
+		name               string
+		finalizers         []string
+		add                string
+		remove             []string
+		expectedFinalizers []string
+	}{
+		{
+			name:               "add the first finalizer",
+			add:                "a",
+			expectedFinalizers: []string{"a"},
+		},
+		{
+			name:               "append the finalizer",
+			finalizers:         []string{"b"},
+			add:                "a",
+			expectedFinalizers: []string{"b", "a"},
+		},
+		{
+			name:       "finalizer present",
+			finalizers: []string{"a"},
+			add:        "a",
+		},
+		{
+			name:               "remove multiple finalizers",
+			finalizers:         []string{"a", "b", "c", "d"},
+			remove:             []string{"a", "c"},
+			expectedFinalizers: []string{"b", "d"},
+		},
+		{
+			name:       "finalizers absent",
+			finalizers: []string{"b"},
+			remove:     []string{"a"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work := &workapiv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "work", Namespace: "cluster1", Finalizers: c.finalizers}}
+			var patch []byte
+			var err error
+			if len(c.add) > 0 {
+				patch, err = AddFinalizerPatch(c.finalizers, c.add)
+			} else {
+				patch, err = RemoveFinalizerPatch(c.finalizers, c.remove...)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.expectedFinalizers == nil {
+				if patch != nil {
+					t.Errorf("expected no patch, but got %s", patch)
+				}
+				return
+			}
+
+			client := fakeworkclient.NewSimpleClientset(work).WorkV1().ManifestWorks(work.Namespace)
+			patched, err := client.Patch(context.TODO(), work.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(patched.Finalizers, c.expectedFinalizers) {
+				t.Errorf("expected finalizers %v, but got %v", c.expectedFinalizers, patched.Finalizers)
+			}
+		})
+	}
+}
+
 func TestHubHash(t *testing.T) {
 	cases := []struct {
 		name  string
@@ -502,3 +982,351 @@ func TestHubHash(t *testing.T) {
 		})
 	}
 }
+
+func TestIsOrphaned(t *testing.T) {
+	rule := workapiv1.OrphaningRule{Group: "apps", Resource: "deployments", Namespace: "ns1", Name: "n1"}
+	cases := []struct {
+		name         string
+		deleteOption *workapiv1.DeleteOption
+		expected     bool
+	}{
+		{
+			name:     "no delete option",
+			expected: false,
+		},
+		{
+			name:         "foreground deletion",
+			deleteOption: &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeForeground},
+			expected:     false,
+		},
+		{
+			name:         "orphan all",
+			deleteOption: &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan},
+			expected:     true,
+		},
+		{
+			name: "matched orphaning rule",
+			deleteOption: &workapiv1.DeleteOption{
+				PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+				SelectivelyOrphan: &workapiv1.SelectivelyOrphan{OrphaningRules: []workapiv1.OrphaningRule{rule}},
+			},
+			expected: true,
+		},
+		{
+			name: "unmatched orphaning rule",
+			deleteOption: &workapiv1.DeleteOption{
+				PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+				SelectivelyOrphan: &workapiv1.SelectivelyOrphan{OrphaningRules: []workapiv1.OrphaningRule{
+					{Group: "apps", Resource: "deployments", Namespace: "ns1", Name: "n2"},
+				}},
+			},
+			expected: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := IsOrphaned(rule.Group, rule.Resource, rule.Namespace, rule.Name, c.deleteOption)
+			if actual != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMatchOrphaningRules(t *testing.T) {
+	rules := []workapiv1.OrphaningRule{
+		{Group: "apps", Resource: "deployments", Namespace: "ns1", Name: "n1"},
+		{Group: "apps", Resource: "deployments", Namespace: "*", Name: "n1"},
+		{Group: "apps", Resource: "deployments", Namespace: "*", Name: "n2"},
+		{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Name: "n1"},
+	}
+	cases := []struct {
+		name          string
+		resource      workapiv1.OrphaningRule
+		expectedIndex int
+		expected      bool
+	}{
+		{
+			name:          "the first of the overlapping rules",
+			resource:      workapiv1.OrphaningRule{Group: "apps", Resource: "deployments", Namespace: "ns1", Name: "n1"},
+			expectedIndex: 0,
+			expected:      true,
+		},
+		{
+			name:          "wildcard namespace",
+			resource:      workapiv1.OrphaningRule{Group: "apps", Resource: "deployments", Namespace: "ns2", Name: "n1"},
+			expectedIndex: 1,
+			expected:      true,
+		},
+		{
+			name:          "wildcard namespace with another name",
+			resource:      workapiv1.OrphaningRule{Group: "apps", Resource: "deployments", Namespace: "ns1", Name: "n2"},
+			expectedIndex: 2,
+			expected:      true,
+		},
+		{
+			name:          "wildcard namespace does not match cluster scoped resource",
+			resource:      workapiv1.OrphaningRule{Group: "apps", Resource: "deployments", Name: "n2"},
+			expectedIndex: -1,
+		},
+		{
+			name:          "cluster scoped resource",
+			resource:      workapiv1.OrphaningRule{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Name: "n1"},
+			expectedIndex: 3,
+			expected:      true,
+		},
+		{
+			name:          "another resource type",
+			resource:      workapiv1.OrphaningRule{Group: "apps", Resource: "statefulsets", Namespace: "ns1", Name: "n1"},
+			expectedIndex: -1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			index, matched := MatchOrphaningRules(rules, c.resource.Group, c.resource.Resource, c.resource.Namespace, c.resource.Name)
+			if index != c.expectedIndex || matched != c.expected {
+				t.Errorf("expected rule %d matched %t, but got rule %d matched %t", c.expectedIndex, c.expected, index, matched)
+			}
+		})
+	}
+
+	orphanAll := &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan}
+	if index, orphaned := OrphaningRuleIndex("apps", "deployments", "ns1", "n1", orphanAll); index != -1 || !orphaned {
+		t.Errorf("expected orphaned without a rule, but got rule %d orphaned %t", index, orphaned)
+	}
+}
+
+func TestEffectiveDeleteOption(t *testing.T) {
+	orphan := &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan}
+	foreground := &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeForeground}
+	cases := []struct {
+		name          string
+		deleteOption  *workapiv1.DeleteOption
+		defaultPolicy string
+		expected      *workapiv1.DeleteOption
+	}{
+		{
+			name: "no default policy recorded",
+		},
+		{
+			name:          "foreground by default",
+			defaultPolicy: string(workapiv1.DeletePropagationPolicyTypeForeground),
+		},
+		{
+			name:          "orphan by default",
+			defaultPolicy: string(workapiv1.DeletePropagationPolicyTypeOrphan),
+			expected:      orphan,
+		},
+		{
+			name:          "explicit foreground over orphan by default",
+			deleteOption:  foreground,
+			defaultPolicy: string(workapiv1.DeletePropagationPolicyTypeOrphan),
+			expected:      foreground,
+		},
+		{
+			name:          "explicit orphan over foreground by default",
+			deleteOption:  orphan,
+			defaultPolicy: string(workapiv1.DeletePropagationPolicyTypeForeground),
+			expected:      orphan,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work := &workapiv1.ManifestWork{Spec: workapiv1.ManifestWorkSpec{DeleteOption: c.deleteOption}}
+			appliedWork := &workapiv1.AppliedManifestWork{}
+			if len(c.defaultPolicy) > 0 {
+				appliedWork.Annotations = map[string]string{constants.DefaultDeletePropagationPolicyAnnotationKey: c.defaultPolicy}
+			}
+			if actual := EffectiveDeleteOption(work, appliedWork); !equality.Semantic.DeepEqual(actual, c.expected) {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+
+	if actual := EffectiveDeleteOption(&workapiv1.ManifestWork{}, nil); actual != nil {
+		t.Errorf("expected foreground deletion before the appliedmanifestwork is created, but got %v", actual)
+	}
+}
+
+func TestOtherAppliedManifestWorkOwners(t *testing.T) {
+	newOwner := func(name string, uid types.UID) metav1.OwnerReference {
+		return *NewAppliedManifestWorkOwner(&workapiv1.AppliedManifestWork{ObjectMeta: metav1.ObjectMeta{Name: name, UID: uid}})
+	}
+	myOwner := newOwner("hash1-work1", "a")
+
+	cases := []struct {
+		name           string
+		existingOwners []metav1.OwnerReference
+		expected       []string
+	}{
+		{
+			name:           "owned by me only",
+			existingOwners: []metav1.OwnerReference{myOwner},
+		},
+		{
+			name:           "owned by another work of the same hub",
+			existingOwners: []metav1.OwnerReference{myOwner, newOwner("hash1-work2", "b")},
+			expected:       []string{"hash1-work2"},
+		},
+		{
+			name:           "owned by a work of another hub",
+			existingOwners: []metav1.OwnerReference{myOwner, newOwner("hash2-work2", "b")},
+		},
+		{
+			name:           "owned by other kinds",
+			existingOwners: []metav1.OwnerReference{myOwner, {APIVersion: "v1", Kind: "Secret", Name: "hash1-work2", UID: "b"}},
+		},
+		{
+			name:           "removed owner of mine",
+			existingOwners: []metav1.OwnerReference{newOwner("hash1-work1", "a-")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := OtherAppliedManifestWorkOwners(myOwner, c.existingOwners)
+			if !equality.Semantic.DeepEqual(actual, c.expected) {
+				t.Errorf(diff.ObjectDiff(c.expected, actual))
+			}
+		})
+	}
+}
+
+func TestAppliedManifestWorkIdentity(t *testing.T) {
+	hubHash := HubHash("https://hub.example.com")
+	cases := []struct {
+		name             string
+		appliedWork      *workapiv1.AppliedManifestWork
+		expectedHubHash  string
+		expectedWorkName string
+		expectedRepaired bool
+	}{
+		{
+			name: "spec is intact",
+			appliedWork: &workapiv1.AppliedManifestWork{
+				ObjectMeta: metav1.ObjectMeta{Name: hubHash + "-work"},
+				Spec:       workapiv1.AppliedManifestWorkSpec{HubHash: hubHash, ManifestWorkName: "work"},
+			},
+			expectedHubHash:  hubHash,
+			expectedWorkName: "work",
+		},
+		{
+			name:             "spec is empty",
+			appliedWork:      &workapiv1.AppliedManifestWork{ObjectMeta: metav1.ObjectMeta{Name: hubHash + "-work-with-dash"}},
+			expectedHubHash:  hubHash,
+			expectedWorkName: "work-with-dash",
+			expectedRepaired: true,
+		},
+		{
+			name: "hub hash is empty",
+			appliedWork: &workapiv1.AppliedManifestWork{
+				ObjectMeta: metav1.ObjectMeta{Name: hubHash + "-work"},
+				Spec:       workapiv1.AppliedManifestWorkSpec{ManifestWorkName: "work"},
+			},
+			expectedHubHash:  hubHash,
+			expectedWorkName: "work",
+			expectedRepaired: true,
+		},
+		{
+			name:        "name without hub hash",
+			appliedWork: &workapiv1.AppliedManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "work"}},
+		},
+		{
+			name:        "name without hex hub hash",
+			appliedWork: &workapiv1.AppliedManifestWork{ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("z", 64) + "-work"}},
+		},
+		{
+			name:             "name with short hub hash",
+			appliedWork:      &workapiv1.AppliedManifestWork{ObjectMeta: metav1.ObjectMeta{Name: hubHash[:16] + "-work"}},
+			expectedHubHash:  hubHash[:16],
+			expectedWorkName: "work",
+			expectedRepaired: true,
+		},
+		{
+			name:        "name with dash leading work name",
+			appliedWork: &workapiv1.AppliedManifestWork{ObjectMeta: metav1.ObjectMeta{Name: hubHash + "--work"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hash, workName, repaired := AppliedManifestWorkIdentity(c.appliedWork)
+			if hash != c.expectedHubHash || workName != c.expectedWorkName || repaired != c.expectedRepaired {
+				t.Errorf("expected %q, %q, %t, but got %q, %q, %t",
+					c.expectedHubHash, c.expectedWorkName, c.expectedRepaired, hash, workName, repaired)
+			}
+		})
+	}
+}
+
+func TestAppliedManifestworkQueueKeyFunc(t *testing.T) {
+	hubHash := HubHash("https://hub.example.com")
+	cases := []struct {
+		name              string
+		hubHash           string
+		appliedWorkName   string
+		expectedKey       string
+		expectedMalformed bool
+	}{
+		{
+			name:            "appliedmanifestwork of the hub",
+			hubHash:         hubHash,
+			appliedWorkName: hubHash + "-work-with-dash",
+			expectedKey:     "work-with-dash",
+		},
+		{
+			name:            "appliedmanifestwork of the hub with short hash",
+			hubHash:         hubHash[:8],
+			appliedWorkName: hubHash[:8] + "-work",
+			expectedKey:     "work",
+		},
+		{
+			name:            "appliedmanifestwork of another hub",
+			hubHash:         hubHash,
+			appliedWorkName: HubHash("https://other.example.com") + "-work",
+		},
+		{
+			name:            "appliedmanifestwork of another hub sharing the short hash as prefix",
+			hubHash:         hubHash[:8],
+			appliedWorkName: hubHash + "-work",
+		},
+		{
+			name:              "name of the hub hash only",
+			hubHash:           hubHash,
+			appliedWorkName:   hubHash,
+			expectedMalformed: true,
+		},
+		{
+			name:              "name without work name",
+			hubHash:           hubHash,
+			appliedWorkName:   hubHash + "-",
+			expectedMalformed: true,
+		},
+		{
+			name:              "name with dash leading work name",
+			hubHash:           hubHash,
+			appliedWorkName:   hubHash + "--work",
+			expectedMalformed: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			appliedWork := &workapiv1.AppliedManifestWork{ObjectMeta: metav1.ObjectMeta{Name: c.appliedWorkName}}
+			key := AppliedManifestworkQueueKeyFunc(c.hubHash)(appliedWork)
+
+			name, malformed := MalformedAppliedManifestWorkName(key)
+			switch {
+			case malformed != c.expectedMalformed:
+				t.Fatalf("expected malformed %t, but got key %q", c.expectedMalformed, key)
+			case malformed && name != c.appliedWorkName:
+				t.Errorf("expected malformed appliedmanifestwork %q, but got %q", c.appliedWorkName, name)
+			case !malformed && key != c.expectedKey:
+				t.Errorf("expected key %q, but got %q", c.expectedKey, key)
+			}
+		})
+	}
+}
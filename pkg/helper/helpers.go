@@ -3,7 +3,9 @@ package helper
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -17,14 +19,19 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
 	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
 )
 
 const (
@@ -45,18 +52,30 @@ func init() {
 
 // MergeManifestConditions return a new ManifestCondition array which merges the existing manifest
 // conditions and the new manifest conditions. Rules to match ManifestCondition between two arrays:
-// 1. match the manifest condition with the whole ManifestResourceMeta;
-// 2. if not matched, try to match with properties other than ordinal in ManifestResourceMeta
+// 1. match the manifest condition with the identity of the manifest, see manifestIdentity;
+// 2. if not matched, match the manifest condition with the whole ManifestResourceMeta;
+// 3. if not matched, try to match with properties other than ordinal in ManifestResourceMeta
 // If no existing manifest condition is matched, the new manifest condition will be used.
+// The ordinals of the manifests are shifted once a manifest is inserted, removed or reordered, so they are not used
+// to match the manifests with an identity, the ordinal of the new manifest condition is kept for display.
 func MergeManifestConditions(conditions, newConditions []workapiv1.ManifestCondition) []workapiv1.ManifestCondition {
 	merged := []workapiv1.ManifestCondition{}
 
 	// build search indices
+	identityIndex := map[manifestIdentity]workapiv1.ManifestCondition{}
 	metaIndex := map[workapiv1.ManifestResourceMeta]workapiv1.ManifestCondition{}
 	metaWithoutOridinalIndex := map[workapiv1.ManifestResourceMeta]workapiv1.ManifestCondition{}
 
+	duplicatedIdentities := []manifestIdentity{}
 	duplicated := []workapiv1.ManifestResourceMeta{}
 	for _, condition := range conditions {
+		if identity, ok := manifestIdentityOf(condition.ResourceMeta); ok {
+			if _, exists := identityIndex[identity]; exists {
+				duplicatedIdentities = append(duplicatedIdentities, identity)
+			} else {
+				identityIndex[identity] = condition
+			}
+		}
 		metaIndex[condition.ResourceMeta] = condition
 		if metaWithoutOridinal := resetOrdinal(condition.ResourceMeta); metaWithoutOridinal != (workapiv1.ManifestResourceMeta{}) {
 			if _, exists := metaWithoutOridinalIndex[metaWithoutOridinal]; exists {
@@ -67,15 +86,28 @@ func MergeManifestConditions(conditions, newConditions []workapiv1.ManifestCondi
 		}
 	}
 
-	// remove metaWithoutOridinal from index if it is not unique
+	// remove identities and metaWithoutOridinal from index if they are not unique, e.g. the same resource is in
+	// more than one manifest
+	for _, identity := range duplicatedIdentities {
+		delete(identityIndex, identity)
+	}
 	for _, metaWithoutOridinal := range duplicated {
 		delete(metaWithoutOridinalIndex, metaWithoutOridinal)
 	}
 
 	// try to match and merge manifest conditions
 	for _, newCondition := range newConditions {
-		// match with ResourceMeta
-		condition, ok := metaIndex[newCondition.ResourceMeta]
+		// match with the identity of the manifest
+		var condition workapiv1.ManifestCondition
+		ok := false
+		if identity, hasIdentity := manifestIdentityOf(newCondition.ResourceMeta); hasIdentity {
+			condition, ok = identityIndex[identity]
+		}
+
+		// match with ResourceMeta if not found yet
+		if !ok {
+			condition, ok = metaIndex[newCondition.ResourceMeta]
+		}
 
 		// match with properties in ResourceMeta other than ordinal if not found yet
 		if !ok {
@@ -92,11 +124,51 @@ func MergeManifestConditions(conditions, newConditions []workapiv1.ManifestCondi
 		for i := range newCondition.Conditions {
 			newCondition.Conditions[i].LastTransitionTime = metav1.NewTime(time.Now())
 		}
+		newCondition.Conditions = CanonicalStatusConditions(newCondition.Conditions)
 
 		merged = append(merged, newCondition)
 	}
 
-	return merged
+	return dedupeManifestConditions(merged)
+}
+
+// dedupeManifestConditions removes the manifest conditions with the same resource meta as a later one.
+func dedupeManifestConditions(conditions []workapiv1.ManifestCondition) []workapiv1.ManifestCondition {
+	last := map[workapiv1.ManifestResourceMeta]int{}
+	for i, condition := range conditions {
+		last[condition.ResourceMeta] = i
+	}
+	if len(last) == len(conditions) {
+		return conditions
+	}
+
+	deduped := []workapiv1.ManifestCondition{}
+	for i, condition := range conditions {
+		if last[condition.ResourceMeta] == i {
+			deduped = append(deduped, condition)
+		}
+	}
+	return deduped
+}
+
+// manifestIdentity is the identity of a manifest, which is the group, kind, namespace and name of the resource
+// decoded from the manifest. Unlike the ordinal, it is stable once the manifests are inserted, removed or reordered.
+// The version is not a part of the identity, since the same resource is served with all the versions of its kind.
+type manifestIdentity struct {
+	group     string
+	kind      string
+	namespace string
+	name      string
+}
+
+// manifestIdentityOf returns the identity of the manifest with the resource meta. The manifest conditions written
+// by the older agents have the same resource meta, so their identities are the same. False is returned if the
+// manifest has no identity, e.g. it cannot be decoded.
+func manifestIdentityOf(meta workapiv1.ManifestResourceMeta) (manifestIdentity, bool) {
+	if len(meta.Kind) == 0 || len(meta.Name) == 0 {
+		return manifestIdentity{}, false
+	}
+	return manifestIdentity{group: meta.Group, kind: meta.Kind, namespace: meta.Namespace, name: meta.Name}, true
 }
 
 func resetOrdinal(meta workapiv1.ManifestResourceMeta) workapiv1.ManifestResourceMeta {
@@ -118,20 +190,47 @@ func mergeManifestCondition(condition, newCondition workapiv1.ManifestCondition)
 }
 
 // MergeStatusConditions returns a new status condition array with merged status conditions. It is based on newConditions,
-// and merges the corresponding existing conditions if exists.
+// and merges the corresponding existing conditions if exists. The merged conditions are in the canonical form, see
+// CanonicalStatusConditions.
 func MergeStatusConditions(conditions []metav1.Condition, newConditions []metav1.Condition) []metav1.Condition {
-	merged := []metav1.Condition{}
+	merged := CanonicalStatusConditions(conditions)
 
+	for _, condition := range CanonicalStatusConditions(newConditions) {
+		// merge two conditions if necessary
+		meta.SetStatusCondition(&merged, condition)
+	}
+
+	return CanonicalStatusConditions(merged)
+}
+
+// CanonicalStatusConditions returns a copy of the conditions with one condition per type, sorted by type, so that
+// the same conditions are always written in the same order. The condition with the latest transition time is kept
+// for a type, and the later one in the array if the transition times are the same.
+func CanonicalStatusConditions(conditions []metav1.Condition) []metav1.Condition {
+	newest := map[string]metav1.Condition{}
 	for _, condition := range conditions {
-		merged = append(merged, condition)
+		if existing, ok := newest[condition.Type]; ok && condition.LastTransitionTime.Before(&existing.LastTransitionTime) {
+			continue
+		}
+		newest[condition.Type] = condition
 	}
 
-	for _, condition := range newConditions {
-		// merge two conditions if necessary
-		meta.SetStatusCondition(&merged, condition)
+	canonical := []metav1.Condition{}
+	for _, condition := range newest {
+		canonical = append(canonical, condition)
 	}
+	sort.Slice(canonical, func(i, j int) bool {
+		return canonical[i].Type < canonical[j].Type
+	})
+	return canonical
+}
 
-	return merged
+// canonicalManifestWorkStatus puts the conditions of the status and its manifests into the canonical form.
+func canonicalManifestWorkStatus(status *workapiv1.ManifestWorkStatus) {
+	status.Conditions = CanonicalStatusConditions(status.Conditions)
+	for i := range status.ResourceStatus.Manifests {
+		status.ResourceStatus.Manifests[i].Conditions = CanonicalStatusConditions(status.ResourceStatus.Manifests[i].Conditions)
+	}
 }
 
 type UpdateManifestWorkStatusFunc func(status *workapiv1.ManifestWorkStatus) error
@@ -148,6 +247,11 @@ func UpdateManifestWorkStatus(
 		return updatedWorkStatus, updated, nil
 	}
 
+	// do not retry if the hub is unreachable, the work will be requeued anyway.
+	if IsHubConnectionError(err) {
+		return nil, false, err
+	}
+
 	// if the update failed, retry with the manifestwork resource fetched with work client.
 	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
 		manifestWork, err := client.Get(ctx, manifestWork.Name, metav1.GetOptions{})
@@ -174,7 +278,16 @@ func updateManifestWorkStatus(
 			return nil, false, err
 		}
 	}
-	if equality.Semantic.DeepEqual(oldStatus, newStatus) {
+	// truncate the status as it is going to be written, so that it is compared with the truncated status on the hub
+	if err := BudgetManifestWorkStatus(client, newStatus, manifestWork.Generation); err != nil {
+		return nil, false, err
+	}
+
+	// the conditions only reordered or duplicated are not updated
+	canonicalManifestWorkStatus(newStatus)
+	canonicalOldStatus := oldStatus.DeepCopy()
+	canonicalManifestWorkStatus(canonicalOldStatus)
+	if equality.Semantic.DeepEqual(canonicalOldStatus, newStatus) {
 		// We return the newStatus which is a deep copy of oldStatus but with all update funcs applied.
 		return newStatus, false, nil
 	}
@@ -187,8 +300,26 @@ func updateManifestWorkStatus(
 	return &updatedManifestWork.Status, true, nil
 }
 
+// PatchManifestWorkAnnotations sets the annotations of the manifestwork with a merge patch, the other annotations
+// of the manifestwork are kept.
+func PatchManifestWorkAnnotations(
+	ctx context.Context, client workv1client.ManifestWorkInterface, manifestWorkName string, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.Patch(ctx, manifestWorkName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
 // DeleteAppliedResources deletes all given applied resources and returns those pending for finalization
-// If the uid recorded in resources is different from what we get by client, ignore the deletion.
+// If the uid recorded in resources is different from what we get by client, ignore the deletion. The resources
+// owned by the other given resources as well, e.g. a child object tied to a parent delivered in the same
+// manifestwork, are deleted instead of being left to the kube garbage collector.
 func DeleteAppliedResources(
 	resources []workapiv1.AppliedManifestResourceMeta,
 	reason string,
@@ -198,6 +329,46 @@ func DeleteAppliedResources(
 	var resourcesPendingFinalization []workapiv1.AppliedManifestResourceMeta
 	var errs []error
 
+	siblings := sets.NewString()
+	for _, resource := range resources {
+		if len(resource.UID) != 0 {
+			siblings.Insert(resource.UID)
+		}
+	}
+	for _, resource := range resources {
+		pending, err := deleteAppliedResource(resource, reason, dynamicClient, recorder, owner, siblings)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if pending {
+			resourcesPendingFinalization = append(resourcesPendingFinalization, resource)
+		}
+	}
+
+	return resourcesPendingFinalization, errs
+}
+
+// DeleteAppliedResource deletes the given applied resource and returns true if the resource is pending
+// for finalization. If the uid recorded in resource is different from what we get by client, ignore the deletion.
+func DeleteAppliedResource(
+	resource workapiv1.AppliedManifestResourceMeta,
+	reason string,
+	dynamicClient dynamic.Interface,
+	recorder events.Recorder,
+	owner metav1.OwnerReference) (bool, error) {
+	return deleteAppliedResource(resource, reason, dynamicClient, recorder, owner, nil)
+}
+
+// deleteAppliedResource deletes the applied resource like DeleteAppliedResource, the owners whose UIDs are in
+// siblings are deleted along with the resource, so the resource is deleted even if it is owned by them.
+func deleteAppliedResource(
+	resource workapiv1.AppliedManifestResourceMeta,
+	reason string,
+	dynamicClient dynamic.Interface,
+	recorder events.Recorder,
+	owner metav1.OwnerReference,
+	siblings sets.String) (bool, error) {
 	// set owner to be removed
 	ownerCopy := owner.DeepCopy()
 	ownerCopy.UID = types.UID(fmt.Sprintf("%s-", owner.UID))
@@ -207,92 +378,296 @@ func DeleteAppliedResources(
 	// the manifestwork is removed, there is no way to track the orphaned resource any more.
 	deletePolicy := metav1.DeletePropagationBackground
 
-	for _, resource := range resources {
-		gvr := schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Resource}
-		u, err := dynamicClient.
-			Resource(gvr).
-			Namespace(resource.Namespace).
-			Get(context.TODO(), resource.Name, metav1.GetOptions{})
-		if errors.IsNotFound(err) {
-			klog.V(2).Infof("Resource %v with key %s/%s is removed Successfully", gvr, resource.Namespace, resource.Name)
-			continue
+	gvr := schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Resource}
+	u, err := dynamicClient.
+		Resource(gvr).
+		Namespace(resource.Namespace).
+		Get(context.TODO(), resource.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		klog.V(2).Infof("Resource %v with key %s/%s is removed Successfully", gvr, resource.Namespace, resource.Name)
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf(
+			"Failed to get resource %v with key %s/%s: %w",
+			gvr, resource.Namespace, resource.Name, err)
+	}
+
+	existingOwner := u.GetOwnerReferences()
+
+	// If it is not owned by us, skip
+	if !IsOwnedBy(owner, existingOwner) {
+		return false, nil
+	}
+
+	// Merge with the existing owners to move the owner.
+	modified := resourcemerge.BoolPtr(false)
+	resourcemerge.MergeOwnerRefs(modified, &existingOwner, []metav1.OwnerReference{*ownerCopy})
+
+	// If there are still any other existing owners (not only ManifestWorks), update ownerrefs only.
+	if hasOtherOwners(existingOwner, siblings) {
+		if !*modified {
+			return false, nil
 		}
 
+		_, err = ApplyOwnerReferences(context.TODO(), dynamicClient, gvr, u, *ownerCopy, false)
 		if err != nil {
-			errs = append(errs, fmt.Errorf(
-				"Failed to get resource %v with key %s/%s: %w",
-				gvr, resource.Namespace, resource.Name, err))
-			continue
+			return false, fmt.Errorf(
+				"Failed to remove owner from resource %v with key %s/%s: %w",
+				gvr, resource.Namespace, resource.Name, err)
 		}
 
-		existingOwner := u.GetOwnerReferences()
-
-		// If it is not owned by us, skip
-		if !IsOwnedBy(owner, existingOwner) {
-			continue
+		// the resource is moved to other manifestworks of the same hub, hand it over instead of deleting it
+		if others := OtherAppliedManifestWorkOwners(owner, existingOwner); len(others) > 0 {
+			recorder.Eventf("ResourceOwnershipTransferred",
+				"Resource %v with key %s/%s is not deleted because %s, it is still owned by %s.",
+				gvr, resource.Namespace, resource.Name, reason, strings.Join(others, ", "))
 		}
+		return false, nil
+	}
 
-		// Merge with the existing owners to move the owner.
-		modified := resourcemerge.BoolPtr(false)
-		resourcemerge.MergeOwnerRefs(modified, &existingOwner, []metav1.OwnerReference{*ownerCopy})
+	if resource.UID != string(u.GetUID()) {
+		// the traced instance has been deleted, and forget this item.
+		return false, nil
+	}
 
-		// If there are still any other existing owners (not only ManifestWorks), update ownerrefs only.
-		if len(existingOwner) > 0 {
-			if !*modified {
-				continue
-			}
+	if u.GetDeletionTimestamp() != nil && !u.GetDeletionTimestamp().IsZero() {
+		return true, nil
+	}
+
+	// delete the resource which is not deleted yet
+	uid := types.UID(resource.UID)
+	err = dynamicClient.
+		Resource(gvr).
+		Namespace(resource.Namespace).
+		Delete(context.TODO(), resource.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{
+				UID: &uid,
+			},
+			PropagationPolicy: &deletePolicy,
+		})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	// forget this item if the UID precondition check fails
+	if errors.IsConflict(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf(
+			"Failed to delete resource %v with key %s/%s: %w",
+			gvr, resource.Namespace, resource.Name, err)
+	}
 
-			u.SetOwnerReferences(existingOwner)
-			_, err = dynamicClient.Resource(gvr).Namespace(resource.Namespace).Update(context.TODO(), u, metav1.UpdateOptions{})
+	recorder.Eventf(constants.EventReasonResourceDeleted, "Deleted resource %v with key %s/%s because %s.", gvr, resource.Namespace, resource.Name, reason)
+	return true, nil
+}
+
+// OrphanAppliedResource removes the owner from the given applied resource so that the resource is left
+// on the cluster once it is no longer maintained by the manifestwork. The reason is recorded in the event of the
+// resource orphaned with the eventReason, e.g. constants.EventReasonResourceOrphaned once the manifestwork is
+// deleted, or constants.EventReasonResourceOrphanedOnRemoval once the manifest is removed from the manifestwork.
+func OrphanAppliedResource(
+	resource workapiv1.AppliedManifestResourceMeta,
+	eventReason, reason string,
+	dynamicClient dynamic.Interface,
+	recorder events.Recorder,
+	owner metav1.OwnerReference) error {
+	gvr := schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Resource}
+	u, err := dynamicClient.
+		Resource(gvr).
+		Namespace(resource.Namespace).
+		Get(context.TODO(), resource.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf(
+			"Failed to get resource %v with key %s/%s: %w",
+			gvr, resource.Namespace, resource.Name, err)
+	}
+
+	return orphanResource(gvr, u, eventReason, reason, dynamicClient, recorder, owner)
+}
+
+// orphanResource removes the owner from the live resource if it is owned by the owner.
+func orphanResource(
+	gvr schema.GroupVersionResource,
+	u *unstructured.Unstructured,
+	eventReason, reason string,
+	dynamicClient dynamic.Interface,
+	recorder events.Recorder,
+	owner metav1.OwnerReference) error {
+	if !IsOwnedBy(owner, u.GetOwnerReferences()) {
+		return nil
+	}
+
+	ownerCopy := owner.DeepCopy()
+	ownerCopy.UID = types.UID(fmt.Sprintf("%s-", owner.UID))
+	modified, err := ApplyOwnerReferences(context.TODO(), dynamicClient, gvr, u, *ownerCopy, false)
+	if err != nil {
+		return fmt.Errorf(
+			"Failed to remove owner from resource %v with key %s/%s: %w",
+			gvr, u.GetNamespace(), u.GetName(), err)
+	}
+	if modified {
+		recorder.Eventf(eventReason, "Orphaned resource %v with key %s/%s because %s.",
+			gvr, u.GetNamespace(), u.GetName(), reason)
+	}
+	return nil
+}
+
+// ownerReferencesBackoff is the backoff to retry applying owner references on conflict.
+var ownerReferencesBackoff = wait.Backoff{
+	Steps:    3,
+	Duration: 10 * time.Millisecond,
+	Factor:   1.0,
+	Jitter:   0.1,
+}
+
+// ApplyOwnerReferences merges the required owner into the owner references of the existing object with a merge
+// patch. The owner is removed instead if its uid has the suffix "-". The patch contains the uid of the object,
+// so it is never applied to a recreated object, and the resourceVersion of the object unless lastWriterWins is
+// set. On conflict, the object is fetched again and the owner references are merged again, with bounded attempts.
+// It returns true if the owner references are changed.
+func ApplyOwnerReferences(
+	ctx context.Context,
+	dynamicClient dynamic.Interface,
+	gvr schema.GroupVersionResource,
+	existing *unstructured.Unstructured,
+	requiredOwner metav1.OwnerReference,
+	lastWriterWins bool) (bool, error) {
+	changed := false
+	obj := existing
+	err := retry.RetryOnConflict(ownerReferencesBackoff, func() error {
+		if obj == nil {
+			var err error
+			obj, err = dynamicClient.Resource(gvr).Namespace(existing.GetNamespace()).Get(ctx, existing.GetName(), metav1.GetOptions{})
 			if err != nil {
-				errs = append(errs, fmt.Errorf(
-					"Failed to remove owner from resource %v with key %s/%s: %w",
-					gvr, resource.Namespace, resource.Name, err))
+				return err
 			}
-
-			continue
 		}
 
-		if resource.UID != string(u.GetUID()) {
-			// the traced instance has been deleted, and forget this item.
-			continue
+		owners := obj.GetOwnerReferences()
+		modified := resourcemerge.BoolPtr(false)
+		resourcemerge.MergeOwnerRefs(modified, &owners, []metav1.OwnerReference{requiredOwner})
+		if !*modified {
+			return nil
 		}
 
-		if u.GetDeletionTimestamp() != nil && !u.GetDeletionTimestamp().IsZero() {
-			resourcesPendingFinalization = append(resourcesPendingFinalization, resource)
-			continue
+		metadata := map[string]interface{}{
+			"uid":             obj.GetUID(),
+			"ownerReferences": owners,
 		}
-
-		// delete the resource which is not deleted yet
-		uid := types.UID(resource.UID)
-		err = dynamicClient.
-			Resource(gvr).
-			Namespace(resource.Namespace).
-			Delete(context.TODO(), resource.Name, metav1.DeleteOptions{
-				Preconditions: &metav1.Preconditions{
-					UID: &uid,
-				},
-				PropagationPolicy: &deletePolicy,
-			})
-		if errors.IsNotFound(err) {
-			continue
+		if !lastWriterWins {
+			metadata["resourceVersion"] = obj.GetResourceVersion()
 		}
-		// forget this item if the UID precondition check fails
-		if errors.IsConflict(err) {
-			continue
+		patch, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+		if err != nil {
+			return err
 		}
+
+		_, err = dynamicClient.Resource(gvr).Namespace(obj.GetNamespace()).Patch(
+			ctx, obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
 		if err != nil {
-			errs = append(errs, fmt.Errorf(
-				"Failed to delete resource %v with key %s/%s: %w",
-				gvr, resource.Namespace, resource.Name, err))
+			// fetch the object again on the next attempt
+			obj = nil
+			return err
+		}
+		changed = true
+		return nil
+	})
+	return changed, err
+}
+
+// IsOrphaned returns true if the resource with the given group/resource/namespace/name should be orphaned
+// according to the deleteOption of the manifestwork.
+func IsOrphaned(group, resource, namespace, name string, deleteOption *workapiv1.DeleteOption) bool {
+	_, orphaned := OrphaningRuleIndex(group, resource, namespace, name, deleteOption)
+	return orphaned
+}
+
+// OrphaningRuleIndex returns true if the resource with the given group/resource/namespace/name should be orphaned
+// according to the deleteOption of the manifestwork, together with the index of the orphaning rule matching the
+// resource. The index is -1 if the resource is not orphaned by an orphaning rule, e.g. all the resources are
+// orphaned with the propagation policy Orphan.
+func OrphaningRuleIndex(group, resource, namespace, name string, deleteOption *workapiv1.DeleteOption) (int, bool) {
+	// Be default, it is forground deletion.
+	if deleteOption == nil {
+		return -1, false
+	}
+
+	switch deleteOption.PropagationPolicy {
+	case workapiv1.DeletePropagationPolicyTypeForeground:
+		return -1, false
+	case workapiv1.DeletePropagationPolicyTypeOrphan:
+		return -1, true
+	}
+
+	// If there is none specified selectivelyOrphan, none of the manifests should be orphaned
+	if deleteOption.SelectivelyOrphan == nil {
+		return -1, false
+	}
+
+	return MatchOrphaningRules(deleteOption.SelectivelyOrphan.OrphaningRules, group, resource, namespace, name)
+}
+
+// MatchOrphaningRules returns the index of the first orphaning rule matching the resource with the given
+// group/resource/namespace/name, false is returned if none of the rules matches. The namespace "*" of a rule matches
+// the resources in any namespace, but not the cluster scoped resources.
+func MatchOrphaningRules(rules []workapiv1.OrphaningRule, group, resource, namespace, name string) (int, bool) {
+	for index, rule := range rules {
+		if rule.Group != group || rule.Resource != resource || rule.Name != name {
 			continue
 		}
+		if rule.Namespace == namespace || (rule.Namespace == "*" && len(namespace) > 0) {
+			return index, true
+		}
+	}
+	return -1, false
+}
 
-		resourcesPendingFinalization = append(resourcesPendingFinalization, resource)
-		recorder.Eventf("ResourceDeleted", "Deleted resource %v with key %s/%s because %s.", gvr, resource.Namespace, resource.Name, reason)
+// OrphaningReason returns the reason a resource of the manifestwork is orphaned, with the index of the orphaning rule
+// matching the resource returned by OrphaningRuleIndex.
+func OrphaningReason(manifestWorkName string, ruleIndex int) string {
+	if ruleIndex < 0 {
+		return fmt.Sprintf("manifestwork %s orphans all its resources", manifestWorkName)
 	}
+	return fmt.Sprintf("it matches orphaning rule %d of manifestwork %s", ruleIndex, manifestWorkName)
+}
 
-	return resourcesPendingFinalization, errs
+// EffectiveDeleteOption returns the deleteOption of the manifestwork, or the default delete option of the agent
+// recorded on the appliedmanifestwork if the manifestwork does not specify one. A nil deleteOption means the
+// resources are deleted in foreground. The appliedmanifestwork is nil if it is not created yet.
+func EffectiveDeleteOption(
+	manifestWork *workapiv1.ManifestWork, appliedManifestWork *workapiv1.AppliedManifestWork) *workapiv1.DeleteOption {
+	if manifestWork != nil && manifestWork.Spec.DeleteOption != nil {
+		return manifestWork.Spec.DeleteOption
+	}
+	if appliedManifestWork == nil {
+		return nil
+	}
+
+	policy := workapiv1.DeletePropagationPolicyType(appliedManifestWork.Annotations[constants.DefaultDeletePropagationPolicyAnnotationKey])
+	if policy != workapiv1.DeletePropagationPolicyTypeOrphan {
+		return nil
+	}
+	return &workapiv1.DeleteOption{PropagationPolicy: policy}
+}
+
+// ResourceContentHash returns a hash of the content of the object. Metadata and status are ignored so that
+// two objects with the same content but different namespace/name have the same hash.
+func ResourceContentHash(obj *unstructured.Unstructured) (string, error) {
+	objCopy := obj.DeepCopy()
+	delete(objCopy.Object, "metadata")
+	delete(objCopy.Object, "status")
+
+	data, err := json.Marshal(objCopy.Object)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
 }
 
 // GuessObjectGroupVersionKind returns GVK for the passed runtime object.
@@ -311,8 +686,9 @@ func GuessObjectGroupVersionKind(object runtime.Object) (*schema.GroupVersionKin
 	return nil, fmt.Errorf("cannot get gvk of %v", object)
 }
 
-// RemoveFinalizer removes a finalizer from the list.  It mutates its input.
-func RemoveFinalizer(object runtime.Object, finalizerName string) {
+// RemoveFinalizer removes a finalizer from the list.  It mutates its input and returns true if the finalizer
+// is found and removed.
+func RemoveFinalizer(object runtime.Object, finalizerName string) bool {
 	accessor, _ := meta.Accessor(object)
 	finalizers := accessor.GetFinalizers()
 	newFinalizers := []string{}
@@ -323,18 +699,106 @@ func RemoveFinalizer(object runtime.Object, finalizerName string) {
 		newFinalizers = append(newFinalizers, finalizers[i])
 	}
 	accessor.SetFinalizers(newFinalizers)
+	return len(newFinalizers) != len(finalizers)
 }
 
-// AppliedManifestworkQueueKeyFunc return manifestwork key from appliedmanifestwork
+// AddFinalizerPatch returns a json patch appending the finalizer, nil is returned if the finalizer is present. The
+// finalizers are only set as a whole if there is no finalizer, which is tested by the patch.
+func AddFinalizerPatch(finalizers []string, finalizer string) ([]byte, error) {
+	for i := range finalizers {
+		if finalizers[i] == finalizer {
+			return nil, nil
+		}
+	}
+	if len(finalizers) == 0 {
+		return json.Marshal([]map[string]interface{}{
+			{"op": "test", "path": "/metadata/finalizers", "value": nil},
+			{"op": "add", "path": "/metadata/finalizers", "value": []string{finalizer}},
+		})
+	}
+	return json.Marshal([]map[string]interface{}{
+		{"op": "add", "path": "/metadata/finalizers/-", "value": finalizer},
+	})
+}
+
+// RemoveFinalizerPatch returns a json patch removing the finalizers by value, nil is returned if none of them is
+// present. Each finalizer removed is tested by the patch, so that the patch fails if the finalizers are changed.
+func RemoveFinalizerPatch(finalizers []string, names ...string) ([]byte, error) {
+	removing := sets.NewString(names...)
+	operations := []map[string]interface{}{}
+	// the finalizers are removed from the last one, so that the indexes of the others are not changed
+	for i := len(finalizers) - 1; i >= 0; i-- {
+		if !removing.Has(finalizers[i]) {
+			continue
+		}
+		path := fmt.Sprintf("/metadata/finalizers/%d", i)
+		operations = append(operations,
+			map[string]interface{}{"op": "test", "path": path, "value": finalizers[i]},
+			map[string]interface{}{"op": "remove", "path": path},
+		)
+	}
+	if len(operations) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(operations)
+}
+
+// HasFinalizer checks if the object has the finalizer.
+func HasFinalizer(object runtime.Object, finalizerName string) bool {
+	accessor, _ := meta.Accessor(object)
+	for _, finalizer := range accessor.GetFinalizers() {
+		if finalizer == finalizerName {
+			return true
+		}
+	}
+	return false
+}
+
+// AppliedManifestworkQueueKeyFunc return manifestwork key from appliedmanifestwork. The key is empty if the
+// appliedmanifestwork belongs to another hub, including a hub whose longer hash starts with the hub hash. It is a
+// malformed key, see MalformedAppliedManifestWorkName, if the name of the appliedmanifestwork is the hub hash, or the
+// hub hash and "-" not followed by a valid manifestwork name.
 func AppliedManifestworkQueueKeyFunc(hubhash string) factory.ObjectQueueKeyFunc {
 	return func(obj runtime.Object) string {
 		accessor, _ := meta.Accessor(obj)
-		if !strings.HasPrefix(accessor.GetName(), hubhash) {
+		name := accessor.GetName()
+		if name != hubhash && !strings.HasPrefix(name, hubhash+"-") {
 			return ""
 		}
 
-		return strings.TrimPrefix(accessor.GetName(), hubhash+"-")
+		manifestWorkName, ok := ParseAppliedManifestWorkName(hubhash, name)
+		if !ok {
+			return malformedAppliedManifestWorkKeyPrefix + name
+		}
+		return manifestWorkName
+	}
+}
+
+// malformedAppliedManifestWorkKeyPrefix is the prefix of the queue keys of the appliedmanifestworks with malformed
+// names, it never collides with a manifestwork name since "/" is not allowed in names.
+const malformedAppliedManifestWorkKeyPrefix = "malformed-appliedmanifestwork/"
+
+// MalformedAppliedManifestWorkName returns the name of the appliedmanifestwork if the queue key returned by
+// AppliedManifestworkQueueKeyFunc is malformed, so that the controllers log it instead of reconciling a manifestwork.
+func MalformedAppliedManifestWorkName(queueKey string) (string, bool) {
+	if !strings.HasPrefix(queueKey, malformedAppliedManifestWorkKeyPrefix) {
+		return "", false
 	}
+	return strings.TrimPrefix(queueKey, malformedAppliedManifestWorkKeyPrefix), true
+}
+
+// ParseAppliedManifestWorkName returns the manifestwork name of the appliedmanifestwork of the hub if its name is the
+// hub hash and a valid manifestwork name joined with "-". The hub hash is not required to be of any length, so that
+// the hashes shorter than the sha256 of HubHash are supported as well.
+func ParseAppliedManifestWorkName(hubHash, name string) (string, bool) {
+	if len(hubHash) == 0 || !strings.HasPrefix(name, hubHash+"-") {
+		return "", false
+	}
+	manifestWorkName := name[len(hubHash)+1:]
+	if len(validation.IsDNS1123Subdomain(manifestWorkName)) != 0 {
+		return "", false
+	}
+	return manifestWorkName, true
 }
 
 // HubHash returns a hash of hubserver
@@ -343,6 +807,79 @@ func HubHash(hubServer string) string {
 	return fmt.Sprintf("%x", sha256.Sum256([]byte(hubServer)))
 }
 
+// AppliedManifestWorkIdentity returns the hub hash and the manifestwork name of the appliedmanifestwork. They are
+// parsed from the name of the appliedmanifestwork, which is the hub hash and the manifestwork name joined with "-",
+// if the spec is corrupted, e.g. the hub hash is empty on the appliedmanifestworks created by older agents. The
+// repaired is true if any of them is parsed from the name.
+func AppliedManifestWorkIdentity(appliedManifestWork *workapiv1.AppliedManifestWork) (hubHash, manifestWorkName string, repaired bool) {
+	hubHash, manifestWorkName = appliedManifestWork.Spec.HubHash, appliedManifestWork.Spec.ManifestWorkName
+	if len(hubHash) != 0 && len(manifestWorkName) != 0 {
+		return hubHash, manifestWorkName, false
+	}
+
+	parsedHubHash, parsedManifestWorkName, ok := splitAppliedManifestWorkName(appliedManifestWork.Name)
+	if !ok {
+		return hubHash, manifestWorkName, false
+	}
+	if len(hubHash) == 0 {
+		hubHash = parsedHubHash
+	}
+	if len(manifestWorkName) == 0 {
+		manifestWorkName = parsedManifestWorkName
+	}
+	return hubHash, manifestWorkName, true
+}
+
+// minHubHashLength is the min length of the hub hashes parsed from the names of the appliedmanifestworks, it allows
+// hashes shorter than the sha256 of HubHash.
+const minHubHashLength = 8
+
+// splitAppliedManifestWorkName parses the hub hash and the manifestwork name from the name of an appliedmanifestwork
+// without knowing the hub hash. The hub hash is the hex string before the first "-", since a hex string has no "-".
+func splitAppliedManifestWorkName(name string) (hubHash, manifestWorkName string, ok bool) {
+	index := strings.Index(name, "-")
+	if index < minHubHashLength || index > sha256.Size*2 {
+		return "", "", false
+	}
+	hubHash = name[:index]
+	for _, c := range hubHash {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return "", "", false
+		}
+	}
+	manifestWorkName, ok = ParseAppliedManifestWorkName(hubHash, name)
+	return hubHash, manifestWorkName, ok
+}
+
+// OtherAppliedManifestWorkOwners returns the names of the appliedmanifestworks of the same hub as myOwner, which
+// also own the resource with the existing owners. It happens when a manifest is moved from one manifestwork to
+// another.
+func OtherAppliedManifestWorkOwners(myOwner metav1.OwnerReference, existingOwners []metav1.OwnerReference) []string {
+	hubHash := strings.SplitN(myOwner.Name, "-", 2)[0]
+
+	var names []string
+	for _, owner := range existingOwners {
+		if owner.Kind != myOwner.Kind || owner.APIVersion != myOwner.APIVersion || owner.Name == myOwner.Name {
+			continue
+		}
+		if !strings.HasPrefix(owner.Name, hubHash+"-") {
+			continue
+		}
+		names = append(names, owner.Name)
+	}
+	return names
+}
+
+// hasOtherOwners returns true if any of the owners is not in the siblings.
+func hasOtherOwners(owners []metav1.OwnerReference, siblings sets.String) bool {
+	for _, owner := range owners {
+		if !siblings.Has(string(owner.UID)) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsOwnedBy check if owner exists in the ownerrefs.
 func IsOwnedBy(myOwner metav1.OwnerReference, existingOwners []metav1.OwnerReference) bool {
 	for _, owner := range existingOwners {
@@ -0,0 +1,192 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+)
+
+// maxHubProbeBackoffFactor caps the interval of the probes while the hub is unavailable at the factor of the interval.
+const maxHubProbeBackoffFactor = 8
+
+var hubRoundTripDuration = metrics.NewHistogramVec(
+	&metrics.HistogramOpts{
+		Name:    "work_agent_hub_round_trip_duration_seconds",
+		Help:    "Latency of the requests probing the hub by the hash of the hub.",
+		Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	},
+	[]string{"hub_hash"},
+)
+
+func init() {
+	legacyregistry.MustRegister(hubRoundTripDuration)
+}
+
+// HubState is the identity of a hub and the result of the last probe of the hub.
+type HubState struct {
+	HubHash         string        `json:"hubHash"`
+	Host            string        `json:"host"`
+	LastProbeTime   *time.Time    `json:"lastProbeTime,omitempty"`
+	LastRoundTrip   time.Duration `json:"lastRoundTrip,omitempty"`
+	LastProbeError  string        `json:"lastProbeError,omitempty"`
+	StatusWritesOff bool          `json:"statusWritesPaused"`
+}
+
+// HubProbe probes the hub periodically with a cheap read request and records the round trip latency, so that the
+// latency of the hub can be told from the latency of the agent. The probes back off while the status writes to the
+// hub are paused by the circuit breaker, since the breaker probes the hub itself.
+type HubProbe struct {
+	lock     sync.Mutex
+	hubHash  string
+	host     string
+	interval time.Duration
+	probe    func(ctx context.Context) error
+	breaker  *HubCircuitBreaker
+	clock    clock.Clock
+	// backoff is the interval of the next probe, it is doubled while the breaker is open
+	backoff       time.Duration
+	lastProbeTime time.Time
+	lastRoundTrip time.Duration
+	lastErr       error
+}
+
+// NewHubProbe returns a HubProbe of the hub with the hash and host, it probes the hub every interval with the probe.
+// The probes back off while the breaker is open if the breaker is not nil.
+func NewHubProbe(hubHash, host string, interval time.Duration, breaker *HubCircuitBreaker, probe func(ctx context.Context) error) *HubProbe {
+	return &HubProbe{
+		hubHash:  hubHash,
+		host:     host,
+		interval: interval,
+		probe:    probe,
+		breaker:  breaker,
+		clock:    clock.RealClock{},
+		backoff:  interval,
+	}
+}
+
+// HubProbes are the probes of the hubs of an agent by hub hash, the interval of the probes is changed and the
+// states of the hubs are served for debugging through them.
+type HubProbes struct {
+	lock   sync.Mutex
+	probes map[string]*HubProbe
+}
+
+// NewHubProbes returns HubProbes without any probe.
+func NewHubProbes() *HubProbes {
+	return &HubProbes{probes: map[string]*HubProbe{}}
+}
+
+// Add adds the probe of a hub, it replaces the probe of the hub with the same hash.
+func (h *HubProbes) Add(probe *HubProbe) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.probes[probe.hubHash] = probe
+}
+
+// SetInterval changes the interval of the probes of all the hubs, it takes effect from the next probe. The
+// non-positive intervals are ignored, the probes are only started if the interval is positive once the agent starts.
+func (h *HubProbes) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, probe := range h.probes {
+		probe.setInterval(interval)
+	}
+}
+
+func (p *HubProbe) setInterval(interval time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.interval = interval
+}
+
+// Run probes the hub until the context is done.
+func (p *HubProbe) Run(ctx context.Context) {
+	for {
+		next := p.probeOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.clock.After(next):
+		}
+	}
+}
+
+// probeOnce probes the hub unless the breaker is open, and returns the delay before the next probe.
+func (p *HubProbe) probeOnce(ctx context.Context) time.Duration {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.breaker != nil && p.breaker.Degraded() {
+		p.backoff *= 2
+		if max := p.interval * maxHubProbeBackoffFactor; p.backoff > max {
+			p.backoff = max
+		}
+		klog.V(4).Infof("Hub %s is unavailable, probe it again after %v", p.host, p.backoff)
+		return p.backoff
+	}
+	p.backoff = p.interval
+
+	start := p.clock.Now()
+	err := p.probe(ctx)
+	p.lastProbeTime = p.clock.Now()
+	p.lastRoundTrip = p.lastProbeTime.Sub(start)
+	p.lastErr = err
+	hubRoundTripDuration.WithLabelValues(p.hubHash).Observe(p.lastRoundTrip.Seconds())
+	if err != nil {
+		klog.V(4).Infof("Failed to probe hub %s: %v", p.host, err)
+	}
+	return p.backoff
+}
+
+// State returns the identity of the hub and the result of the last probe.
+func (p *HubProbe) State() HubState {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	state := HubState{
+		HubHash:         p.hubHash,
+		Host:            p.host,
+		LastRoundTrip:   p.lastRoundTrip,
+		StatusWritesOff: p.breaker != nil && p.breaker.Degraded(),
+	}
+	if !p.lastProbeTime.IsZero() {
+		lastProbeTime := p.lastProbeTime
+		state.LastProbeTime = &lastProbeTime
+	}
+	if p.lastErr != nil {
+		state.LastProbeError = p.lastErr.Error()
+	}
+	return state
+}
+
+// ServeHTTP serves the states of the hubs probed in json, e.g. at /debug/hubs. The credentials of the hubs are
+// not served.
+func (h *HubProbes) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	h.lock.Lock()
+	states := []HubState{}
+	for _, probe := range h.probes {
+		states = append(states, probe.State())
+	}
+	h.lock.Unlock()
+	sort.Slice(states, func(i, j int) bool { return states[i].HubHash < states[j].HubHash })
+
+	data, err := json.Marshal(states)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
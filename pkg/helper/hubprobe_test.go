@@ -0,0 +1,135 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestHubProbe(t *testing.T) {
+	connectionRefused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	fakeClock := clock.NewFakeClock(time.Now())
+	hubRoundTripDuration.Reset()
+
+	breaker := NewHubCircuitBreaker("hub-probe-test", 1, time.Minute, func(ctx context.Context) error { return nil })
+	breaker.clock = fakeClock
+
+	var probeErr error
+	probes := 0
+	probe := NewHubProbe("hub-probe-test", "https://hub.example.com", 10*time.Second, breaker, func(ctx context.Context) error {
+		probes++
+		// the round trip to the hub takes 200ms
+		fakeClock.Step(200 * time.Millisecond)
+		return probeErr
+	})
+	probe.clock = fakeClock
+
+	// the probe records the round trip latency
+	if next := probe.probeOnce(context.TODO()); next != 10*time.Second {
+		t.Errorf("expected next probe after 10s, but got %v", next)
+	}
+	if probes != 1 {
+		t.Fatalf("expected hub probed once, but got %d", probes)
+	}
+	observed := hubRoundTripDuration.WithLabelValues("hub-probe-test")
+	if count, _ := testutil.GetHistogramMetricCount(observed); count != 1 {
+		t.Errorf("expected 1 observation, but got %d", count)
+	}
+	if sum, _ := testutil.GetHistogramMetricValue(observed); sum != 0.2 {
+		t.Errorf("expected 0.2s observed, but got %v", sum)
+	}
+	if state := probe.State(); state.LastRoundTrip != 200*time.Millisecond || state.LastProbeTime == nil || len(state.LastProbeError) > 0 {
+		t.Errorf("unexpected state %v", state)
+	}
+
+	// the probes back off while the breaker is open
+	breaker.Record(connectionRefused)
+	for _, expected := range []time.Duration{20 * time.Second, 40 * time.Second, 80 * time.Second, 80 * time.Second} {
+		if next := probe.probeOnce(context.TODO()); next != expected {
+			t.Errorf("expected next probe after %v, but got %v", expected, next)
+		}
+	}
+	if probes != 1 {
+		t.Fatalf("expected hub not probed while the breaker is open, but got %d probes", probes)
+	}
+	if state := probe.State(); !state.StatusWritesOff {
+		t.Errorf("expected status writes paused, but got %v", state)
+	}
+
+	// the probes are resumed once the breaker is closed
+	breaker.Record(nil)
+	probeErr = fmt.Errorf("forbidden")
+	if next := probe.probeOnce(context.TODO()); next != 10*time.Second {
+		t.Errorf("expected next probe after 10s, but got %v", next)
+	}
+	if probes != 2 {
+		t.Fatalf("expected hub probed twice, but got %d", probes)
+	}
+
+	// the states of the hubs are served without the credentials
+	hubProbes := NewHubProbes()
+	hubProbes.Add(probe)
+	recorder := httptest.NewRecorder()
+	hubProbes.ServeHTTP(recorder, nil)
+	states := []HubState{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &states); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].HubHash != "hub-probe-test" || states[0].Host != "https://hub.example.com" ||
+		states[0].LastProbeError != "forbidden" || states[0].StatusWritesOff {
+		t.Errorf("unexpected states %v", states)
+	}
+
+	// the interval of the probes is changed through the probes of the hubs
+	hubProbes.SetInterval(time.Minute)
+	if next := probe.probeOnce(context.TODO()); next != time.Minute {
+		t.Errorf("expected next probe after 1m, but got %v", next)
+	}
+}
+
+func TestHubProbeRun(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	probed := make(chan struct{}, 10)
+	probe := NewHubProbe("hub-probe-run-test", "https://hub.example.com", 10*time.Second, nil, func(ctx context.Context) error {
+		probed <- struct{}{}
+		return nil
+	})
+	probe.clock = fakeClock
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		probe.Run(ctx)
+		close(done)
+	}()
+
+	// the hub is probed once started and then every interval
+	for i := 0; i < 3; i++ {
+		select {
+		case <-probed:
+		case <-time.After(wait.ForeverTestTimeout):
+			t.Fatalf("expected hub probed %d times", i+1)
+		}
+		for !fakeClock.HasWaiters() {
+			time.Sleep(time.Millisecond)
+		}
+		fakeClock.Step(10 * time.Second)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatalf("expected probe stopped")
+	}
+}
@@ -0,0 +1,39 @@
+package helper
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// ResourceCompletedReason is the reason of the Available condition of a finished Job with ttlSecondsAfterFinished,
+	// which is going to be deleted by the TTL controller of the managed cluster.
+	ResourceCompletedReason = "ResourceCompleted"
+	// ResourceCompletedAndExpiredReason is the reason of the Available condition of a finished Job deleted once its
+	// ttlSecondsAfterFinished expired. The Job is still available, and it is not applied again.
+	ResourceCompletedAndExpiredReason = "ResourceCompletedAndExpired"
+)
+
+// IsJob checks if the resource is a Job.
+func IsJob(resourceMeta workapiv1.ManifestResourceMeta) bool {
+	return resourceMeta.Group == "batch" && resourceMeta.Kind == "Job"
+}
+
+// IsJobFinishedWithTTL checks if the Job is complete or failed and has ttlSecondsAfterFinished, so that it is
+// deleted by the TTL controller once the ttl expires.
+func IsJobFinishedWithTTL(job *unstructured.Unstructured) bool {
+	if _, found, _ := unstructured.NestedInt64(job.Object, "spec", "ttlSecondsAfterFinished"); !found {
+		return false
+	}
+	conditions, _, _ := unstructured.NestedSlice(job.Object, "status", "conditions")
+	for _, item := range conditions {
+		condition, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if (condition["type"] == "Complete" || condition["type"] == "Failed") && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
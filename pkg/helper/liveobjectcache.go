@@ -0,0 +1,169 @@
+package helper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	liveObjectCacheHit  = "hit"
+	liveObjectCacheMiss = "miss"
+)
+
+var (
+	liveObjectCacheLookups = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name: "work_agent_live_object_cache_lookups_total",
+			Help: "Number of lookups of the live applied objects shared by the controllers of the agent by result. It is " +
+				"hit if the lookup is served by the cache, and miss if it is a request to the spoke apiserver.",
+		},
+		[]string{"result"},
+	)
+	liveObjectCacheHitRatio = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Name: "work_agent_live_object_cache_hit_ratio",
+			Help: "Ratio of the lookups of the live applied objects served by the cache since the agent started.",
+		},
+	)
+	liveObjectCacheObjects = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Name: "work_agent_live_object_cache_objects",
+			Help: "Number of live applied objects cached, including the objects cached as not found.",
+		},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(liveObjectCacheLookups)
+	legacyregistry.MustRegister(liveObjectCacheHitRatio)
+	legacyregistry.MustRegister(liveObjectCacheObjects)
+}
+
+// LiveObjectKey identifies a live applied object, the namespace is empty for the cluster scoped objects.
+type LiveObjectKey struct {
+	GVR       schema.GroupVersionResource
+	Namespace string
+	Name      string
+}
+
+// liveObject is an object cached by the LiveObjectCache, the object is nil if it was not found.
+type liveObject struct {
+	object    *unstructured.Unstructured
+	fetchedAt time.Time
+}
+
+// LiveObjectCache is a read-through cache of the live applied objects shared by the controllers of the agent, so that
+// the object applied by the manifest controller and checked by the availability controller within seconds is fetched
+// from the spoke apiserver once. An object is cached for at most ttl since the request fetching it was sent, and it is
+// dropped once it is written by the agent with Invalidate, so the object served is never staler than ttl.
+type LiveObjectCache struct {
+	lock          sync.Mutex
+	dynamicClient dynamic.Interface
+	ttl           time.Duration
+	clock         clock.Clock
+	objects       map[LiveObjectKey]liveObject
+	// generation is increased on each invalidation, an object fetched across an invalidation is not cached since it
+	// may be fetched before the write.
+	generation   uint64
+	hits, misses uint64
+}
+
+// NewLiveObjectCache returns a LiveObjectCache caching the objects for ttl. It must be run with Run to drop the
+// expired objects.
+func NewLiveObjectCache(dynamicClient dynamic.Interface, ttl time.Duration) *LiveObjectCache {
+	return &LiveObjectCache{
+		dynamicClient: dynamicClient,
+		ttl:           ttl,
+		clock:         clock.RealClock{},
+		objects:       map[LiveObjectKey]liveObject{},
+	}
+}
+
+// Get returns the object from the cache if it was fetched within ttl, or from the spoke apiserver otherwise. A
+// NotFound error is cached as well. The object returned must not be modified.
+func (c *LiveObjectCache) Get(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error) {
+	key := LiveObjectKey{GVR: gvr, Namespace: namespace, Name: name}
+
+	c.lock.Lock()
+	now := c.clock.Now()
+	if cached, ok := c.objects[key]; ok && now.Sub(cached.fetchedAt) < c.ttl {
+		c.recordLookup(liveObjectCacheHit)
+		c.lock.Unlock()
+		if cached.object == nil {
+			return nil, errors.NewNotFound(gvr.GroupResource(), name)
+		}
+		return cached.object, nil
+	}
+	c.recordLookup(liveObjectCacheMiss)
+	generation := c.generation
+	c.lock.Unlock()
+
+	obj, err := c.dynamicClient.Resource(gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if generation == c.generation {
+		c.objects[key] = liveObject{object: obj, fetchedAt: now}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Invalidate drops the object from the cache once it is written, so that the next Get fetches it from the spoke
+// apiserver. It does nothing if the cache is nil.
+func (c *LiveObjectCache) Invalidate(gvr schema.GroupVersionResource, namespace, name string) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.objects, LiveObjectKey{GVR: gvr, Namespace: namespace, Name: name})
+	c.generation++
+}
+
+// Run drops the expired objects periodically until the context is done.
+func (c *LiveObjectCache) Run(ctx context.Context) {
+	wait.Until(c.prune, c.ttl, ctx.Done())
+}
+
+// prune drops the expired objects and refreshes the metrics of the cache.
+func (c *LiveObjectCache) prune() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.clock.Now()
+	for key, cached := range c.objects {
+		if now.Sub(cached.fetchedAt) >= c.ttl {
+			delete(c.objects, key)
+		}
+	}
+	liveObjectCacheObjects.Set(float64(len(c.objects)))
+}
+
+// recordLookup counts the lookup and refreshes the hit ratio. The lock must be held by the caller.
+func (c *LiveObjectCache) recordLookup(result string) {
+	liveObjectCacheLookups.WithLabelValues(result).Inc()
+	if result == liveObjectCacheHit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	liveObjectCacheHitRatio.Set(float64(c.hits) / float64(c.hits+c.misses))
+}
@@ -0,0 +1,99 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestLiveObjectCache(t *testing.T) {
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	ttl := 10 * time.Second
+
+	dynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), newConfigMap("ns1", "cm1"))
+	fakeClock := clock.NewFakeClock(time.Now())
+	liveObjects := NewLiveObjectCache(dynamicClient, ttl)
+	liveObjects.clock = fakeClock
+
+	// updateLive changes the object on the spoke without invalidating the cache, as if it is written by someone else
+	updateLive := func(value string) {
+		t.Helper()
+		obj := newConfigMap("ns1", "cm1")
+		obj.SetLabels(map[string]string{"value": value})
+		if err := dynamicClient.Tracker().Update(configMaps, obj, "ns1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertGet := func(name string, live bool, expectedValue string) {
+		t.Helper()
+		dynamicClient.ClearActions()
+		obj, err := liveObjects.Get(context.TODO(), configMaps, "ns1", name)
+		switch {
+		case len(expectedValue) == 0 && !errors.IsNotFound(err):
+			t.Fatalf("expected %s not found, but got %v", name, err)
+		case len(expectedValue) > 0 && err != nil:
+			t.Fatalf("expected %s found, but got %v", name, err)
+		case len(expectedValue) > 0 && obj.GetLabels()["value"] != expectedValue:
+			t.Errorf("expected %s with value %q, but got %v", name, expectedValue, obj.GetLabels())
+		}
+		if fetched := len(dynamicClient.Actions()) > 0; fetched != live {
+			t.Errorf("expected %s fetched from the spoke %t, but got %t", name, live, fetched)
+		}
+	}
+
+	updateLive("v1")
+	assertGet("cm1", true, "v1")
+	assertGet("cm2", true, "")
+
+	// the objects are served by the cache within the ttl even if they are changed on the spoke
+	updateLive("v2")
+	fakeClock.Step(ttl - time.Second)
+	assertGet("cm1", false, "v1")
+	assertGet("cm2", false, "")
+
+	// the stale objects are never served once the ttl expires
+	fakeClock.Step(time.Second)
+	assertGet("cm1", true, "v2")
+	assertGet("cm2", true, "")
+
+	// a write invalidates the object before the ttl expires
+	updateLive("v3")
+	liveObjects.Invalidate(configMaps, "ns1", "cm1")
+	assertGet("cm1", true, "v3")
+	assertGet("cm1", false, "v3")
+
+	// the expired objects are dropped
+	fakeClock.Step(ttl)
+	liveObjects.prune()
+	if len(liveObjects.objects) != 0 {
+		t.Errorf("expected the expired objects dropped, but got %v", liveObjects.objects)
+	}
+}
+
+func TestLiveObjectCacheInvalidatedDuringFetch(t *testing.T) {
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	dynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), newConfigMap("ns1", "cm1"))
+	liveObjects := NewLiveObjectCache(dynamicClient, time.Minute)
+	liveObjects.clock = clock.NewFakeClock(time.Now())
+
+	// the object is written and invalidated while it is being fetched, the object fetched may be the one before the
+	// write, so it is not cached
+	dynamicClient.PrependReactor("get", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		liveObjects.Invalidate(configMaps, "ns1", "cm1")
+		return false, nil, nil
+	})
+	if _, err := liveObjects.Get(context.TODO(), configMaps, "ns1", "cm1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(liveObjects.objects) != 0 {
+		t.Errorf("expected the object fetched across an invalidation not cached, but got %v", liveObjects.objects)
+	}
+}
@@ -0,0 +1,262 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// localAPIUnixPrefix is the prefix of the address of the local API served on a unix socket.
+	localAPIUnixPrefix = "unix://"
+	// localAPISocketMode only allows the owner of the agent process to connect to the unix socket of the local API.
+	localAPISocketMode = 0600
+)
+
+// LocalWork is a manifestwork applied by the agent, served by the local API.
+type LocalWork struct {
+	HubHash             string `json:"hubHash"`
+	ManifestWorkName    string `json:"manifestWorkName"`
+	AppliedManifestWork string `json:"appliedManifestWork"`
+	// AppliedResources are the resources applied by the manifestwork
+	AppliedResources []workapiv1.AppliedManifestResourceMeta `json:"appliedResources,omitempty"`
+	// Conditions are the Applied and Available conditions of the manifestwork cached from the hub, which are the
+	// outcome of the last reconcile of the manifestwork. They are empty if the manifestwork is not cached.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// LocalAPI serves a read-only API of the manifestworks applied by the agent for the tooling on the managed cluster,
+// e.g. to look up the manifestwork owning a resource without the access to the hubs. It is served from the caches of
+// the agent, it never calls the apiservers.
+//   - GET /works lists the manifestworks with their applied resources and the outcome of their last reconcile;
+//   - GET /owners?group=&resource=&namespace=&name= lists the manifestworks owning the resource regardless of its
+//     version.
+type LocalAPI struct {
+	appliedManifestWorkIndexer cache.Indexer
+
+	lock sync.RWMutex
+	// manifestWorkListers are the listers of the manifestworks on the hubs by hub hash
+	manifestWorkListers map[string]worklister.ManifestWorkNamespaceLister
+}
+
+// NewLocalAPI returns a LocalAPI serving the appliedmanifestworks of the indexer, which must have the indexers of
+// AppliedManifestWorkIndexers.
+func NewLocalAPI(appliedManifestWorkIndexer cache.Indexer) *LocalAPI {
+	return &LocalAPI{
+		appliedManifestWorkIndexer: appliedManifestWorkIndexer,
+		manifestWorkListers:        map[string]worklister.ManifestWorkNamespaceLister{},
+	}
+}
+
+// AddHub adds the lister of the manifestworks on the hub, so that the outcome of the last reconcile of the
+// manifestworks of the hub is served.
+func (a *LocalAPI) AddHub(hubHash string, manifestWorkLister worklister.ManifestWorkNamespaceLister) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.manifestWorkListers[hubHash] = manifestWorkLister
+}
+
+// Handler returns the handler of the local API.
+func (a *LocalAPI) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/works", a.readOnly(a.serveWorks))
+	mux.HandleFunc("/owners", a.readOnly(a.serveOwners))
+	return mux
+}
+
+// readOnly rejects the requests which are not GET.
+func (a *LocalAPI) readOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (a *LocalAPI) serveWorks(w http.ResponseWriter, _ *http.Request) {
+	works := []LocalWork{}
+	for _, obj := range a.appliedManifestWorkIndexer.List() {
+		if appliedManifestWork, ok := obj.(*workapiv1.AppliedManifestWork); ok {
+			works = append(works, a.localWorkOf(appliedManifestWork, true))
+		}
+	}
+	writeLocalAPIResponse(w, sortLocalWorks(works))
+}
+
+func (a *LocalAPI) serveOwners(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if len(query.Get("resource")) == 0 || len(query.Get("name")) == 0 {
+		http.Error(w, "resource and name must be set", http.StatusBadRequest)
+		return
+	}
+
+	objs, err := a.appliedManifestWorkIndexer.ByIndex(AppliedManifestWorkByResourceIndex,
+		ResourceIndexKey(query.Get("group"), query.Get("resource"), query.Get("namespace"), query.Get("name")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	works := []LocalWork{}
+	for _, obj := range objs {
+		if appliedManifestWork, ok := obj.(*workapiv1.AppliedManifestWork); ok {
+			works = append(works, a.localWorkOf(appliedManifestWork, false))
+		}
+	}
+	writeLocalAPIResponse(w, sortLocalWorks(works))
+}
+
+// localWorkOf returns the LocalWork of the appliedmanifestwork, the applied resources are omitted unless withResources.
+func (a *LocalAPI) localWorkOf(appliedManifestWork *workapiv1.AppliedManifestWork, withResources bool) LocalWork {
+	work := LocalWork{
+		HubHash:             appliedManifestWork.Spec.HubHash,
+		ManifestWorkName:    appliedManifestWork.Spec.ManifestWorkName,
+		AppliedManifestWork: appliedManifestWork.Name,
+	}
+	if withResources {
+		work.AppliedResources = appliedManifestWork.Status.AppliedResources
+	}
+
+	a.lock.RLock()
+	lister, ok := a.manifestWorkListers[work.HubHash]
+	a.lock.RUnlock()
+	if !ok {
+		return work
+	}
+	manifestWork, err := lister.Get(work.ManifestWorkName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			klog.V(4).Infof("Failed to get manifestwork %s of hub %s: %v", work.ManifestWorkName, work.HubHash, err)
+		}
+		return work
+	}
+	for _, conditionType := range []string{workapiv1.WorkApplied, workapiv1.WorkAvailable} {
+		if condition := meta.FindStatusCondition(manifestWork.Status.Conditions, conditionType); condition != nil {
+			work.Conditions = append(work.Conditions, *condition)
+		}
+	}
+	return work
+}
+
+func sortLocalWorks(works []LocalWork) []LocalWork {
+	sort.Slice(works, func(i, j int) bool { return works[i].AppliedManifestWork < works[j].AppliedManifestWork })
+	return works
+}
+
+func writeLocalAPIResponse(w http.ResponseWriter, works []LocalWork) {
+	data, err := json.Marshal(works)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// ValidateLocalAPIAddress checks the address of the local API is a unix socket, e.g. unix:///var/run/work.sock, or
+// a loopback address, e.g. localhost:8443, so that the local API is not exposed out of the node.
+func ValidateLocalAPIAddress(address string) error {
+	if strings.HasPrefix(address, localAPIUnixPrefix) {
+		if len(strings.TrimPrefix(address, localAPIUnixPrefix)) == 0 {
+			return fmt.Errorf("the path of the unix socket must be set")
+		}
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("the host %q is neither localhost nor a loopback address", host)
+}
+
+// ListenLocalAPI listens on the address of the local API, see ValidateLocalAPIAddress. A unix socket is only
+// accessible by the owner of the agent process, the stale socket of a previous agent process is removed.
+func ListenLocalAPI(address string) (net.Listener, error) {
+	if err := ValidateLocalAPIAddress(address); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(address, localAPIUnixPrefix) {
+		return net.Listen("tcp", address)
+	}
+
+	path := strings.TrimPrefix(address, localAPIUnixPrefix)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// the socket is created with the permissions of the umask, so it is created in a directory only accessible by
+	// the owner and moved to the path once its mode is restricted, no other user can connect to it meanwhile.
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".local-api-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tempPath := filepath.Join(dir, filepath.Base(path))
+	listener, err := net.Listen("unix", tempPath)
+	if err != nil {
+		return nil, err
+	}
+	// the socket is removed by the path once the listener is closed instead of the temporary path
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tempPath, localAPISocketMode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &unixSocketListener{Listener: listener, path: path}, nil
+}
+
+// unixSocketListener removes the unix socket at the path once it is closed.
+type unixSocketListener struct {
+	net.Listener
+	path string
+}
+
+// Close closes the listener and removes the socket.
+func (l *unixSocketListener) Close() error {
+	err := l.Listener.Close()
+	if removeErr := os.Remove(l.path); removeErr != nil && !os.IsNotExist(removeErr) && err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// ServeLocalAPI serves the handler on the listener until the context is done.
+func ServeLocalAPI(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	server := &http.Server{Handler: handler}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+func newLocalAPI(t *testing.T) *LocalAPI {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, AppliedManifestWorkIndexers())
+	for _, appliedManifestWork := range []*workapiv1.AppliedManifestWork{
+		newAppliedManifestWork("hub1", "work1", "uid1"),
+		newAppliedManifestWork("hub1", "work2", "uid2"),
+		newAppliedManifestWork("hub2", "work1", "uid1"),
+	} {
+		if err := indexer.Add(appliedManifestWork); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	workIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	if err := workIndexer.Add(&workapiv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cluster1", Name: "work1"},
+		Status: workapiv1.ManifestWorkStatus{Conditions: []metav1.Condition{
+			{Type: workapiv1.WorkApplied, Status: metav1.ConditionFalse, Reason: "AppliedManifestWorkFailed"},
+			{Type: "Other", Status: metav1.ConditionTrue},
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	localAPI := NewLocalAPI(indexer)
+	localAPI.AddHub("hub1", worklister.NewManifestWorkLister(workIndexer).ManifestWorks("cluster1"))
+	return localAPI
+}
+
+func TestLocalAPI(t *testing.T) {
+	handler := newLocalAPI(t).Handler()
+
+	cases := []struct {
+		name               string
+		method             string
+		path               string
+		expectedStatusCode int
+		expectedWorks      []string
+		validate           func(t *testing.T, works []LocalWork)
+	}{
+		{
+			name:               "list works",
+			method:             http.MethodGet,
+			path:               "/works",
+			expectedStatusCode: http.StatusOK,
+			expectedWorks:      []string{"hub1-work1", "hub1-work2", "hub2-work1"},
+			validate: func(t *testing.T, works []LocalWork) {
+				if len(works[0].AppliedResources) != 1 || works[0].AppliedResources[0].UID != "uid1" {
+					t.Errorf("expected the applied resources of hub1-work1, but got %v", works[0].AppliedResources)
+				}
+				// the outcome of the last reconcile is served from the cache of the hub
+				if len(works[0].Conditions) != 1 || works[0].Conditions[0].Reason != "AppliedManifestWorkFailed" {
+					t.Errorf("expected the Applied condition of hub1-work1, but got %v", works[0].Conditions)
+				}
+				if len(works[1].Conditions) != 0 || len(works[2].Conditions) != 0 {
+					t.Errorf("expected no conditions of the works not cached, but got %v", works)
+				}
+			},
+		},
+		{
+			name:               "owners of a resource",
+			method:             http.MethodGet,
+			path:               "/owners?resource=secrets&namespace=ns1&name=uid1",
+			expectedStatusCode: http.StatusOK,
+			expectedWorks:      []string{"hub1-work1", "hub2-work1"},
+			validate: func(t *testing.T, works []LocalWork) {
+				if works[0].HubHash != "hub1" || works[0].ManifestWorkName != "work1" || len(works[0].AppliedResources) != 0 {
+					t.Errorf("unexpected owner %v", works[0])
+				}
+			},
+		},
+		{
+			name:               "no owner",
+			method:             http.MethodGet,
+			path:               "/owners?group=apps&resource=deployments&namespace=ns1&name=uid1",
+			expectedStatusCode: http.StatusOK,
+			expectedWorks:      []string{},
+		},
+		{
+			name:               "owners without name",
+			method:             http.MethodGet,
+			path:               "/owners?resource=secrets",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "mutation",
+			method:             http.MethodDelete,
+			path:               "/works",
+			expectedStatusCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:               "unknown path",
+			method:             http.MethodGet,
+			path:               "/debug",
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(c.method, c.path, nil))
+			if recorder.Code != c.expectedStatusCode {
+				t.Fatalf("expected status code %d, but got %d: %s", c.expectedStatusCode, recorder.Code, recorder.Body.String())
+			}
+			if c.expectedStatusCode != http.StatusOK {
+				return
+			}
+
+			works := []LocalWork{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &works); err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, work := range works {
+				names = append(names, work.AppliedManifestWork)
+			}
+			if !reflect.DeepEqual(names, c.expectedWorks) {
+				t.Fatalf("expected works %v, but got %v", c.expectedWorks, names)
+			}
+			if c.validate != nil {
+				c.validate(t, works)
+			}
+		})
+	}
+}
+
+func TestValidateLocalAPIAddress(t *testing.T) {
+	cases := []struct {
+		address     string
+		expectedErr bool
+	}{
+		{address: "unix:///var/run/work-agent/api.sock"},
+		{address: "unix://", expectedErr: true},
+		{address: "localhost:8443"},
+		{address: "127.0.0.1:8443"},
+		{address: "[::1]:8443"},
+		{address: "0.0.0.0:8443", expectedErr: true},
+		{address: ":8443", expectedErr: true},
+		{address: "10.0.0.1:8443", expectedErr: true},
+		{address: "localhost", expectedErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.address, func(t *testing.T) {
+			if err := ValidateLocalAPIAddress(c.address); (err != nil) != c.expectedErr {
+				t.Errorf("expected error %t, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestServeLocalAPIOnUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	// the stale socket of a previous agent process is removed
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := ListenLocalAPI("unix://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the user of the agent is allowed to connect to the socket
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("expected mode 0600 of the socket, but got %v", mode)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- ServeLocalAPI(ctx, listener, newLocalAPI(t).Handler())
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://localhost/works")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	works := []LocalWork{}
+	if err := json.NewDecoder(resp.Body).Decode(&works); err != nil {
+		t.Fatal(err)
+	}
+	if len(works) != 3 {
+		t.Errorf("expected 3 works, but got %v", works)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected the local API stopped, but got %v", err)
+	}
+	// the socket and the directory it is created in are removed
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected the socket removed, but got %v", entries)
+	}
+}
@@ -0,0 +1,70 @@
+package helper
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// ServerSideApplyFieldManager is the field manager of the resources applied with server side apply by the agent.
+const ServerSideApplyFieldManager = "work-agent"
+
+// UpdateFieldManager returns the field manager recorded by the apiserver for the resources updated by the agent
+// with the rest config, which is the prefix of the user agent before the first "/".
+func UpdateFieldManager(config *rest.Config) string {
+	userAgent := config.UserAgent
+	if len(userAgent) == 0 {
+		userAgent = rest.DefaultKubernetesUserAgent()
+	}
+	return strings.Split(userAgent, "/")[0]
+}
+
+// AdoptManagedFields returns the managed fields of the resource with the fields set by the updateManager owned by the
+// applyManager, so that the fields set by the agent before it applies the resource with server side apply are
+// owned by the apply and are removed once they are dropped from the manifest. False is returned if there is nothing to
+// adopt, e.g. the applyManager owns fields already.
+func AdoptManagedFields(
+	managedFields []metav1.ManagedFieldsEntry, updateManager, applyManager string) ([]metav1.ManagedFieldsEntry, bool) {
+	adopted := -1
+	for index, entry := range managedFields {
+		switch {
+		case entry.Manager == applyManager && entry.Operation == metav1.ManagedFieldsOperationApply:
+			return managedFields, false
+		case adopted < 0 && entry.Manager == updateManager && entry.Operation == metav1.ManagedFieldsOperationUpdate &&
+			len(entry.Subresource) == 0:
+			adopted = index
+		}
+	}
+	if adopted < 0 {
+		return managedFields, false
+	}
+
+	// the fields set by the other updates of the updateManager, e.g. with another apiVersion, are left unowned
+	result := []metav1.ManagedFieldsEntry{}
+	for index, entry := range managedFields {
+		switch {
+		case index == adopted:
+			entry.Manager, entry.Operation = applyManager, metav1.ManagedFieldsOperationApply
+		case entry.Manager == updateManager && entry.Operation == metav1.ManagedFieldsOperationUpdate &&
+			len(entry.Subresource) == 0:
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result, true
+}
+
+// ReleaseManagedFields returns the managed fields of the resource without the fields owned by the applyManager, so
+// that the fields are owned by the agent once it updates the resource instead of applying it with server side apply.
+// False is returned if the applyManager owns no field.
+func ReleaseManagedFields(managedFields []metav1.ManagedFieldsEntry, applyManager string) ([]metav1.ManagedFieldsEntry, bool) {
+	result := []metav1.ManagedFieldsEntry{}
+	for _, entry := range managedFields {
+		if entry.Manager == applyManager && entry.Operation == metav1.ManagedFieldsOperationApply {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result, len(result) != len(managedFields)
+}
@@ -0,0 +1,66 @@
+package helper
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestUpdateFieldManager(t *testing.T) {
+	if manager := UpdateFieldManager(&rest.Config{UserAgent: "work/v0.0.0 (linux/amd64) kubernetes/$Format"}); manager != "work" {
+		t.Errorf("expected field manager work, but got %q", manager)
+	}
+	if manager := UpdateFieldManager(&rest.Config{}); len(manager) == 0 {
+		t.Errorf("expected the field manager of the default user agent, but got none")
+	}
+}
+
+func TestAdoptManagedFields(t *testing.T) {
+	update := func(manager, apiVersion string) metav1.ManagedFieldsEntry {
+		return metav1.ManagedFieldsEntry{Manager: manager, Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: apiVersion}
+	}
+	apply := func(manager, apiVersion string) metav1.ManagedFieldsEntry {
+		return metav1.ManagedFieldsEntry{Manager: manager, Operation: metav1.ManagedFieldsOperationApply, APIVersion: apiVersion}
+	}
+
+	cases := []struct {
+		name            string
+		managedFields   []metav1.ManagedFieldsEntry
+		expectedFields  []metav1.ManagedFieldsEntry
+		expectedAdopted bool
+	}{
+		{
+			name:            "adopt the fields of the update",
+			managedFields:   []metav1.ManagedFieldsEntry{update("kubectl", "v1"), update("work", "v1"), update("work", "v1beta1")},
+			expectedFields:  []metav1.ManagedFieldsEntry{update("kubectl", "v1"), apply("work-agent", "v1")},
+			expectedAdopted: true,
+		},
+		{
+			name:           "adopted already",
+			managedFields:  []metav1.ManagedFieldsEntry{apply("work-agent", "v1"), update("work", "v1")},
+			expectedFields: []metav1.ManagedFieldsEntry{apply("work-agent", "v1"), update("work", "v1")},
+		},
+		{
+			name:           "nothing to adopt",
+			managedFields:  []metav1.ManagedFieldsEntry{update("kubectl", "v1")},
+			expectedFields: []metav1.ManagedFieldsEntry{update("kubectl", "v1")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fields, adopted := AdoptManagedFields(c.managedFields, "work", ServerSideApplyFieldManager)
+			if adopted != c.expectedAdopted || !reflect.DeepEqual(fields, c.expectedFields) {
+				t.Errorf("expected %v adopted %t, but got %v adopted %t", c.expectedFields, c.expectedAdopted, fields, adopted)
+			}
+		})
+	}
+
+	fields, released := ReleaseManagedFields([]metav1.ManagedFieldsEntry{update("kubectl", "v1"), apply("work-agent", "v1")},
+		ServerSideApplyFieldManager)
+	if !released || !reflect.DeepEqual(fields, []metav1.ManagedFieldsEntry{update("kubectl", "v1")}) {
+		t.Errorf("expected the fields of the apply released, but got %v released %t", fields, released)
+	}
+}
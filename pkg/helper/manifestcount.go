@@ -0,0 +1,15 @@
+package helper
+
+import "fmt"
+
+// DefaultMaxManifests is the default max number of manifests of a manifestwork. It is validated by the webhook on the
+// hub, and checked again by the agent for the manifestworks created before the webhook validated it.
+const DefaultMaxManifests = 500
+
+// ManifestCountBuckets are the buckets of the histograms of the number of manifests of manifestworks.
+var ManifestCountBuckets = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500}
+
+// TooManyManifestsMessage returns the message of a manifestwork with more manifests than the max.
+func TooManyManifestsMessage(count, max int) string {
+	return fmt.Sprintf("the manifestwork has %d manifests which exceeds the limit of %d manifests", count, max)
+}
@@ -0,0 +1,202 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+// ManifestDependency declares the manifests which the manifest with the ordinal depends on.
+type ManifestDependency struct {
+	Ordinal   int32                  `json:"ordinal"`
+	DependsOn []ManifestDependsOnRef `json:"dependsOn"`
+}
+
+// ManifestDependsOnRef refers to a manifest of the manifestwork either by ordinal, or by the group, kind, namespace
+// and name of the manifest. The namespace is not matched if it is empty.
+type ManifestDependsOnRef struct {
+	Ordinal   *int32 `json:"ordinal,omitempty"`
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+func (r ManifestDependsOnRef) String() string {
+	if r.Ordinal != nil {
+		return fmt.Sprintf("ordinal %d", *r.Ordinal)
+	}
+	return fmt.Sprintf("%s.%s %s/%s", r.Kind, r.Group, r.Namespace, r.Name)
+}
+
+// ManifestDependencies returns the ordinals of the manifests which each manifest of the manifestwork depends on,
+// keyed by the ordinal of the manifest. The references by group, kind and name are resolved to the ordinals of the
+// manifests. A bad request error is returned if the dependencies are invalid or have a cycle, so that it is not
+// retried.
+func ManifestDependencies(manifestWork *workapiv1.ManifestWork) (map[int32][]int32, error) {
+	value, ok := manifestWork.Annotations[constants.ManifestDependenciesAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	dependencies := []ManifestDependency{}
+	if err := json.Unmarshal([]byte(value), &dependencies); err != nil {
+		return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest dependencies of manifestwork %s: %v", manifestWork.Name, err))
+	}
+
+	manifests := manifestWork.Spec.Workload.Manifests
+	result := map[int32][]int32{}
+	for _, dependency := range dependencies {
+		if dependency.Ordinal < 0 || int(dependency.Ordinal) >= len(manifests) {
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest dependencies of manifestwork %s: ordinal %d is out of range",
+				manifestWork.Name, dependency.Ordinal))
+		}
+		for _, ref := range dependency.DependsOn {
+			ordinal, err := resolveManifestDependsOnRef(manifests, ref)
+			if err != nil {
+				return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest dependencies of manifestwork %s: manifest %d depends on %s: %v",
+					manifestWork.Name, dependency.Ordinal, ref, err))
+			}
+			result[dependency.Ordinal] = appendOrdinal(result[dependency.Ordinal], ordinal)
+		}
+	}
+
+	if cycle := findDependencyCycle(result); len(cycle) > 0 {
+		path := make([]string, 0, len(cycle))
+		for _, ordinal := range cycle {
+			path = append(path, fmt.Sprintf("%d", ordinal))
+		}
+		return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest dependencies of manifestwork %s: manifests %s depend on each other",
+			manifestWork.Name, strings.Join(path, " -> ")))
+	}
+	return result, nil
+}
+
+// DependencyDeadline returns how long the manifests of the manifestwork wait for the manifests they depend on
+// before the manifestwork is marked Degraded, 0 is returned if it is not set. A bad request error is returned if
+// the deadline is invalid.
+func DependencyDeadline(manifestWork *workapiv1.ManifestWork) (time.Duration, error) {
+	value, ok := manifestWork.Annotations[constants.DependencyDeadlineAnnotationKey]
+	if !ok {
+		return 0, nil
+	}
+
+	deadline, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.NewBadRequest(fmt.Sprintf("invalid dependency deadline of manifestwork %s: %v", manifestWork.Name, err))
+	}
+	if deadline <= 0 {
+		return 0, errors.NewBadRequest(fmt.Sprintf("invalid dependency deadline of manifestwork %s: %s is not positive",
+			manifestWork.Name, deadline))
+	}
+	return deadline, nil
+}
+
+// resolveManifestDependsOnRef returns the ordinal of the manifest the reference refers to, the reference by group,
+// kind and name must match exactly one manifest.
+func resolveManifestDependsOnRef(manifests []workapiv1.Manifest, ref ManifestDependsOnRef) (int32, error) {
+	if ref.Ordinal != nil {
+		if len(ref.Kind) != 0 || len(ref.Name) != 0 {
+			return 0, fmt.Errorf("either ordinal or kind and name can be set")
+		}
+		if *ref.Ordinal < 0 || int(*ref.Ordinal) >= len(manifests) {
+			return 0, fmt.Errorf("ordinal is out of range")
+		}
+		return *ref.Ordinal, nil
+	}
+	if len(ref.Kind) == 0 || len(ref.Name) == 0 {
+		return 0, fmt.Errorf("either ordinal or kind and name must be set")
+	}
+
+	matched := []int32{}
+	for index, manifest := range manifests {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+			continue
+		}
+		gvk := obj.GroupVersionKind()
+		if gvk.Group != ref.Group || gvk.Kind != ref.Kind || obj.GetName() != ref.Name {
+			continue
+		}
+		if len(ref.Namespace) != 0 && obj.GetNamespace() != ref.Namespace {
+			continue
+		}
+		matched = append(matched, int32(index))
+	}
+	switch len(matched) {
+	case 0:
+		return 0, fmt.Errorf("no manifest matches")
+	case 1:
+		return matched[0], nil
+	default:
+		return 0, fmt.Errorf("manifests %v match, set the namespace or ordinal", matched)
+	}
+}
+
+// appendOrdinal appends the ordinal to the sorted ordinals if it is not in them yet.
+func appendOrdinal(ordinals []int32, ordinal int32) []int32 {
+	for _, o := range ordinals {
+		if o == ordinal {
+			return ordinals
+		}
+	}
+	ordinals = append(ordinals, ordinal)
+	sort.Slice(ordinals, func(i, j int) bool { return ordinals[i] < ordinals[j] })
+	return ordinals
+}
+
+// findDependencyCycle returns the ordinals of the manifests on a cycle of the dependencies starting and ending with
+// the same ordinal, e.g. [0 1 0], nil is returned if there is no cycle.
+func findDependencyCycle(dependencies map[int32][]int32) []int32 {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := map[int32]int{}
+	var path []int32
+
+	var visit func(ordinal int32) []int32
+	visit = func(ordinal int32) []int32 {
+		switch states[ordinal] {
+		case visiting:
+			for index, o := range path {
+				if o == ordinal {
+					return append(append([]int32{}, path[index:]...), ordinal)
+				}
+			}
+		case visited:
+			return nil
+		}
+
+		states[ordinal] = visiting
+		path = append(path, ordinal)
+		for _, predecessor := range dependencies[ordinal] {
+			if cycle := visit(predecessor); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		states[ordinal] = visited
+		return nil
+	}
+
+	// visit the manifests in order, so that the same cycle is reported every time
+	ordinals := make([]int32, 0, len(dependencies))
+	for ordinal := range dependencies {
+		ordinals = append(ordinals, ordinal)
+	}
+	sort.Slice(ordinals, func(i, j int) bool { return ordinals[i] < ordinals[j] })
+	for _, ordinal := range ordinals {
+		if cycle := visit(ordinal); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package helper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+func newDependencyManifestWork(annotations map[string]string) *workapiv1.ManifestWork {
+	manifests := []workapiv1.Manifest{}
+	for _, raw := range []string{
+		`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"namespace": "ns1", "name": "config"}}`,
+		`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"namespace": "ns1", "name": "web"}}`,
+		`{"apiVersion": "v1", "kind": "Service", "metadata": {"namespace": "ns1", "name": "web"}}`,
+		`{"apiVersion": "v1", "kind": "Service", "metadata": {"namespace": "ns2", "name": "web"}}`,
+	} {
+		manifests = append(manifests, workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: []byte(raw)}})
+	}
+	return &workapiv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{Name: "work", Annotations: annotations},
+		Spec:       workapiv1.ManifestWorkSpec{Workload: workapiv1.ManifestsTemplate{Manifests: manifests}},
+	}
+}
+
+func TestManifestDependencies(t *testing.T) {
+	cases := []struct {
+		name         string
+		dependencies string
+		expected     map[int32][]int32
+		expectedErr  bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name: "by ordinal and by kind and name",
+			dependencies: `[
+				{"ordinal": 1, "dependsOn": [{"kind": "ConfigMap", "name": "config"}]},
+				{"ordinal": 2, "dependsOn": [{"group": "apps", "kind": "Deployment", "namespace": "ns1", "name": "web"}, {"ordinal": 0}, {"ordinal": 1}]}]`,
+			expected: map[int32][]int32{1: {0}, 2: {0, 1}},
+		},
+		{
+			name:         "by namespace",
+			dependencies: `[{"ordinal": 0, "dependsOn": [{"kind": "Service", "namespace": "ns2", "name": "web"}]}]`,
+			expected:     map[int32][]int32{0: {3}},
+		},
+		{
+			name:         "invalid json",
+			dependencies: `{`,
+			expectedErr:  true,
+		},
+		{
+			name:         "ordinal out of range",
+			dependencies: `[{"ordinal": 4, "dependsOn": [{"ordinal": 0}]}]`,
+			expectedErr:  true,
+		},
+		{
+			name:         "depends on ordinal out of range",
+			dependencies: `[{"ordinal": 0, "dependsOn": [{"ordinal": -1}]}]`,
+			expectedErr:  true,
+		},
+		{
+			name:         "no manifest matches",
+			dependencies: `[{"ordinal": 0, "dependsOn": [{"group": "apps", "kind": "Deployment", "name": "db"}]}]`,
+			expectedErr:  true,
+		},
+		{
+			name:         "more than one manifest matches",
+			dependencies: `[{"ordinal": 0, "dependsOn": [{"kind": "Service", "name": "web"}]}]`,
+			expectedErr:  true,
+		},
+		{
+			name:         "neither ordinal nor name",
+			dependencies: `[{"ordinal": 0, "dependsOn": [{"kind": "Service"}]}]`,
+			expectedErr:  true,
+		},
+		{
+			name:         "depends on itself",
+			dependencies: `[{"ordinal": 0, "dependsOn": [{"ordinal": 0}]}]`,
+			expectedErr:  true,
+		},
+		{
+			name: "cycle",
+			dependencies: `[
+				{"ordinal": 0, "dependsOn": [{"ordinal": 2}]},
+				{"ordinal": 1, "dependsOn": [{"ordinal": 0}]},
+				{"ordinal": 2, "dependsOn": [{"ordinal": 1}]}]`,
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if len(c.dependencies) > 0 {
+				annotations[constants.ManifestDependenciesAnnotationKey] = c.dependencies
+			}
+			actual, err := ManifestDependencies(newDependencyManifestWork(annotations))
+			if c.expectedErr {
+				if !errors.IsBadRequest(err) {
+					t.Errorf("expected bad request error, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(actual) != len(c.expected) || (len(actual) > 0 && !reflect.DeepEqual(actual, c.expected)) {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFindDependencyCycle(t *testing.T) {
+	cases := []struct {
+		name         string
+		dependencies map[int32][]int32
+		expected     []int32
+	}{
+		{
+			name:         "no cycle",
+			dependencies: map[int32][]int32{1: {0}, 2: {0, 1}, 3: {2}},
+		},
+		{
+			name:         "cycle",
+			dependencies: map[int32][]int32{0: {3}, 1: {0}, 2: {1}, 3: {2}},
+			expected:     []int32{0, 3, 2, 1, 0},
+		},
+		{
+			name:         "cycle not from the first manifest",
+			dependencies: map[int32][]int32{0: {1}, 1: {2}, 2: {1}},
+			expected:     []int32{1, 2, 1},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := findDependencyCycle(c.dependencies); !reflect.DeepEqual(actual, c.expected) {
+				t.Errorf("expected cycle %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDependencyDeadline(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		expected    time.Duration
+		expectedErr bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:        "deadline",
+			annotations: map[string]string{constants.DependencyDeadlineAnnotationKey: "10m"},
+			expected:    10 * time.Minute,
+		},
+		{
+			name:        "invalid deadline",
+			annotations: map[string]string{constants.DependencyDeadlineAnnotationKey: "soon"},
+			expectedErr: true,
+		},
+		{
+			name:        "not positive",
+			annotations: map[string]string{constants.DependencyDeadlineAnnotationKey: "0s"},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := DependencyDeadline(newDependencyManifestWork(c.annotations))
+			if c.expectedErr != errors.IsBadRequest(err) {
+				t.Fatalf("expected bad request error %t, but got %v", c.expectedErr, err)
+			}
+			if actual != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
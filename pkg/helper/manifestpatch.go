@@ -0,0 +1,244 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+const (
+	// UpdateStrategyTypeUpdate applies the manifest as the whole resource, which is the default.
+	UpdateStrategyTypeUpdate = "Update"
+	// UpdateStrategyTypePatch applies the manifest as a patch document of an existing resource.
+	UpdateStrategyTypePatch = "Patch"
+	// UpdateStrategyTypeServerSideApply applies the manifest with server side apply, the fields of the resource are
+	// owned by the field manager ServerSideApplyFieldManager.
+	UpdateStrategyTypeServerSideApply = "ServerSideApply"
+)
+
+// manifestPatchTypes are the supported patch types of the manifests with the update strategy Patch.
+var manifestPatchTypes = map[string]types.PatchType{
+	"JSONPatch":           types.JSONPatchType,
+	"MergePatch":          types.MergePatchType,
+	"StrategicMergePatch": types.StrategicMergePatchType,
+}
+
+// ManifestResourceIdentifier identifies the resource which a manifest is applied to.
+type ManifestResourceIdentifier struct {
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// ManifestUpdateStrategy defines how a manifest is applied. The revertPatch of the Patch strategy is applied once
+// the manifestwork is deleted or the patch is dropped from it, the patched resource is left as is if it is not set.
+type ManifestUpdateStrategy struct {
+	Type        string          `json:"type"`
+	PatchType   string          `json:"patchType,omitempty"`
+	RevertPatch json.RawMessage `json:"revertPatch,omitempty"`
+}
+
+// ManifestConfigOption defines how the manifest with the ordinal is applied.
+type ManifestConfigOption struct {
+	Ordinal            int32                      `json:"ordinal"`
+	ResourceIdentifier ManifestResourceIdentifier `json:"resourceIdentifier"`
+	UpdateStrategy     ManifestUpdateStrategy     `json:"updateStrategy"`
+}
+
+// ManifestPatch is a manifest which is the patch document of an existing resource.
+type ManifestPatch struct {
+	Resource  schema.GroupVersionResource
+	Namespace string
+	Name      string
+	PatchType types.PatchType
+	// RevertPatch is applied with the same patch type once the manifestwork is deleted or the patch is dropped, it
+	// is nil if not set.
+	RevertPatch []byte
+}
+
+// AppliedManifestPatch is a patch applied to an existing resource which is reverted with its revertPatch once the
+// patch is dropped from the manifestwork, it is recorded on the appliedmanifestwork.
+type AppliedManifestPatch struct {
+	ResourceIdentifier ManifestResourceIdentifier `json:"resourceIdentifier"`
+	PatchType          types.PatchType            `json:"patchType"`
+	RevertPatch        json.RawMessage            `json:"revertPatch"`
+}
+
+// ResourceIdentifier returns the identifier of the resource patched.
+func (p *ManifestPatch) ResourceIdentifier() ManifestResourceIdentifier {
+	return ManifestResourceIdentifier{
+		Group:     p.Resource.Group,
+		Version:   p.Resource.Version,
+		Resource:  p.Resource.Resource,
+		Namespace: p.Namespace,
+		Name:      p.Name,
+	}
+}
+
+// AppliedManifestPatchesOf returns the patches with a revertPatch in the order of the ordinals of their manifests.
+func AppliedManifestPatchesOf(patches map[int32]*ManifestPatch) []AppliedManifestPatch {
+	ordinals := []int{}
+	for ordinal, patch := range patches {
+		if len(patch.RevertPatch) != 0 {
+			ordinals = append(ordinals, int(ordinal))
+		}
+	}
+	sort.Ints(ordinals)
+
+	applied := []AppliedManifestPatch{}
+	for _, ordinal := range ordinals {
+		patch := patches[int32(ordinal)]
+		applied = append(applied, AppliedManifestPatch{
+			ResourceIdentifier: patch.ResourceIdentifier(),
+			PatchType:          patch.PatchType,
+			RevertPatch:        json.RawMessage(patch.RevertPatch),
+		})
+	}
+	return applied
+}
+
+// RevertManifestPatch applies the revertPatch of a patch to the resource. It is not an error if the resource is not
+// found, since there is nothing to revert.
+func RevertManifestPatch(
+	ctx context.Context, dynamicClient dynamic.Interface, recorder events.Recorder, patch AppliedManifestPatch) error {
+	identifier := patch.ResourceIdentifier
+	gvr := schema.GroupVersionResource{Group: identifier.Group, Version: identifier.Version, Resource: identifier.Resource}
+	_, err := dynamicClient.Resource(gvr).Namespace(identifier.Namespace).Patch(
+		ctx, identifier.Name, patch.PatchType, patch.RevertPatch, metav1.PatchOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to revert the patch of %s %s/%s: %w", gvr, identifier.Namespace, identifier.Name, err)
+	}
+	recorder.Eventf("PatchReverted", "Reverted the patch of %s %s/%s", gvr.Resource, identifier.Namespace, identifier.Name)
+	return nil
+}
+
+// ManifestPatches returns the patches of the manifests with the update strategy Patch keyed by the ordinal of the
+// manifests. A bad request error is returned if the config options are invalid, so that it is not retried.
+func ManifestPatches(manifestWork *workapiv1.ManifestWork) (map[int32]*ManifestPatch, error) {
+	options, err := manifestConfigOptions(manifestWork)
+	if err != nil {
+		return nil, err
+	}
+	// the invalid subresources are reported by ManifestSubresources
+	subresources, _ := ManifestSubresources(manifestWork)
+
+	result := map[int32]*ManifestPatch{}
+	for _, option := range options {
+		strategy := option.UpdateStrategy
+		if strategy.Type != UpdateStrategyTypePatch {
+			continue
+		}
+
+		identifier := option.ResourceIdentifier
+		patchType, ok := manifestPatchTypes[strategy.PatchType]
+		switch {
+		case !ok:
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest config options of manifestwork %s: patch type %q of manifest %d is not supported",
+				manifestWork.Name, strategy.PatchType, option.Ordinal))
+		case len(identifier.Version) == 0 || len(identifier.Resource) == 0 || len(identifier.Name) == 0:
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest config options of manifestwork %s: version, resource and name of manifest %d must be set",
+				manifestWork.Name, option.Ordinal))
+		case len(subresources[option.Ordinal]) != 0:
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest config options of manifestwork %s: manifest %d is applied to a subresource",
+				manifestWork.Name, option.Ordinal))
+		}
+
+		patch := &ManifestPatch{
+			Resource:  schema.GroupVersionResource{Group: identifier.Group, Version: identifier.Version, Resource: identifier.Resource},
+			Namespace: identifier.Namespace,
+			Name:      identifier.Name,
+			PatchType: patchType,
+		}
+		if len(strategy.RevertPatch) != 0 {
+			patch.RevertPatch = []byte(strategy.RevertPatch)
+		}
+		result[option.Ordinal] = patch
+	}
+	return result, nil
+}
+
+// ManifestServerSideApplies returns the ordinals of the manifests with the update strategy ServerSideApply. A bad
+// request error is returned if the config options are invalid, so that it is not retried.
+func ManifestServerSideApplies(manifestWork *workapiv1.ManifestWork) (map[int32]bool, error) {
+	options, err := manifestConfigOptions(manifestWork)
+	if err != nil {
+		return nil, err
+	}
+	// the invalid subresources are reported by ManifestSubresources
+	subresources, _ := ManifestSubresources(manifestWork)
+
+	result := map[int32]bool{}
+	for _, option := range options {
+		if option.UpdateStrategy.Type != UpdateStrategyTypeServerSideApply {
+			continue
+		}
+		if len(subresources[option.Ordinal]) != 0 {
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest config options of manifestwork %s: manifest %d is applied to a subresource",
+				manifestWork.Name, option.Ordinal))
+		}
+		result[option.Ordinal] = true
+	}
+	return result, nil
+}
+
+// manifestConfigOptions returns the config options of the manifestwork, a bad request error is returned if they are
+// invalid.
+func manifestConfigOptions(manifestWork *workapiv1.ManifestWork) ([]ManifestConfigOption, error) {
+	value, ok := manifestWork.Annotations[constants.ManifestConfigOptionsAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	options := []ManifestConfigOption{}
+	if err := json.Unmarshal([]byte(value), &options); err != nil {
+		return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest config options of manifestwork %s: %v", manifestWork.Name, err))
+	}
+	for _, option := range options {
+		if option.Ordinal < 0 || int(option.Ordinal) >= len(manifestWork.Spec.Workload.Manifests) {
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest config options of manifestwork %s: ordinal %d is out of range",
+				manifestWork.Name, option.Ordinal))
+		}
+		switch option.UpdateStrategy.Type {
+		case "", UpdateStrategyTypeUpdate, UpdateStrategyTypePatch, UpdateStrategyTypeServerSideApply:
+		default:
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest config options of manifestwork %s: update strategy %q is not supported",
+				manifestWork.Name, option.UpdateStrategy.Type))
+		}
+	}
+	return options, nil
+}
+
+// RevertManifestPatches applies the revert patches of the manifests with the update strategy Patch of the
+// manifestwork, e.g. once the manifestwork is deleted. The patched resources not found are skipped, since there is
+// nothing to revert.
+func RevertManifestPatches(
+	ctx context.Context, manifestWork *workapiv1.ManifestWork, dynamicClient dynamic.Interface, recorder events.Recorder) error {
+	patches, err := ManifestPatches(manifestWork)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, patch := range AppliedManifestPatchesOf(patches) {
+		if err := RevertManifestPatch(ctx, dynamicClient, recorder, patch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
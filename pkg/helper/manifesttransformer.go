@@ -0,0 +1,118 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// ManifestTransformer transforms the manifests of the manifestworks on the spoke before they are applied, e.g. to
+// inject the values specific to the managed cluster, so that they are not templated on the hub. The transformed
+// manifests are hashed, so the manifests are applied again once the transformation is changed. A manifest failing to
+// transform is not applied and is retried, it is reported with the reason ManifestTransformFailed.
+type ManifestTransformer interface {
+	// Transform changes the object decoded from the manifest in place. The kind, namespace and name of the object
+	// must not be changed.
+	Transform(ctx context.Context, obj *unstructured.Unstructured, resourceMeta workapiv1.ManifestResourceMeta) error
+}
+
+// ManifestTransformerFunc is a function implementing ManifestTransformer.
+type ManifestTransformerFunc func(ctx context.Context, obj *unstructured.Unstructured, resourceMeta workapiv1.ManifestResourceMeta) error
+
+// Transform calls the function.
+func (f ManifestTransformerFunc) Transform(ctx context.Context, obj *unstructured.Unstructured, resourceMeta workapiv1.ManifestResourceMeta) error {
+	return f(ctx, obj, resourceMeta)
+}
+
+// podSpecPaths are the paths of the pod specs in the workload resources, e.g. the spec of a pod, the pod template of
+// a deployment and the job template of a cronjob.
+var podSpecPaths = [][]string{
+	{"spec"},
+	{"spec", "template", "spec"},
+	{"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// imageRegistryRewriter rewrites the registry prefixes of the images of the containers, see
+// NewImageRegistryRewriter.
+type imageRegistryRewriter struct {
+	// prefixes are sorted from the longest, so that the most specific prefix is rewritten
+	prefixes []string
+	rewrites map[string]string
+}
+
+// NewImageRegistryRewriter returns a ManifestTransformer rewriting the images of the containers of the pods and the
+// pod templates, whose registry prefixes are the keys of the rewrites, with the values of the rewrites, e.g. to pull
+// the images from a mirror. A prefix matches the whole components of the image, the longest prefix matched wins,
+// e.g. docker.io/library is rewritten to mirror.example.com/library by docker.io=mirror.example.com.
+func NewImageRegistryRewriter(rewrites map[string]string) (ManifestTransformer, error) {
+	rewriter := &imageRegistryRewriter{rewrites: map[string]string{}}
+	for prefix, replacement := range rewrites {
+		prefix, replacement = strings.TrimSuffix(prefix, "/"), strings.TrimSuffix(replacement, "/")
+		if len(prefix) == 0 || len(replacement) == 0 {
+			return nil, fmt.Errorf("invalid image registry rewrite %q=%q, neither the prefix nor the replacement can be empty",
+				prefix, replacement)
+		}
+		rewriter.prefixes = append(rewriter.prefixes, prefix)
+		rewriter.rewrites[prefix] = replacement
+	}
+	sort.Slice(rewriter.prefixes, func(i, j int) bool {
+		return len(rewriter.prefixes[i]) > len(rewriter.prefixes[j])
+	})
+	return rewriter, nil
+}
+
+// Transform rewrites the images of the containers, init containers and ephemeral containers in the pod specs.
+func (r *imageRegistryRewriter) Transform(_ context.Context, obj *unstructured.Unstructured, _ workapiv1.ManifestResourceMeta) error {
+	for _, path := range podSpecPaths {
+		podSpec, found, err := unstructured.NestedMap(obj.Object, path...)
+		if err != nil || !found {
+			continue
+		}
+
+		changed := false
+		for _, field := range []string{"containers", "initContainers", "ephemeralContainers"} {
+			containers, found, err := unstructured.NestedSlice(podSpec, field)
+			if err != nil || !found {
+				continue
+			}
+			for _, item := range containers {
+				container, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				image, ok := container["image"].(string)
+				if !ok {
+					continue
+				}
+				if rewritten := r.rewrite(image); rewritten != image {
+					container["image"] = rewritten
+					changed = true
+				}
+			}
+			if err := unstructured.SetNestedSlice(podSpec, containers, field); err != nil {
+				return err
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := unstructured.SetNestedMap(obj.Object, podSpec, path...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewrite returns the image with the longest prefix matched rewritten, or the image as is if no prefix is matched.
+func (r *imageRegistryRewriter) rewrite(image string) string {
+	for _, prefix := range r.prefixes {
+		if image == prefix || strings.HasPrefix(image, prefix+"/") {
+			return r.rewrites[prefix] + strings.TrimPrefix(image, prefix)
+		}
+	}
+	return image
+}
@@ -0,0 +1,82 @@
+package helper
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+func TestImageRegistryRewriter(t *testing.T) {
+	rewriter, err := NewImageRegistryRewriter(map[string]string{
+		"docker.io":              "mirror.example.com/dockerhub/",
+		"docker.io/library":      "mirror.example.com/library",
+		"quay.io/open-cluster-m": "mirror.example.com/partial",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	podSpec := func(containers ...string) map[string]interface{} {
+		items := []interface{}{}
+		for _, image := range containers {
+			items = append(items, map[string]interface{}{"name": "c", "image": image})
+		}
+		return map[string]interface{}{"containers": items, "initContainers": []interface{}{map[string]interface{}{"image": "docker.io/busybox"}}}
+	}
+
+	cases := []struct {
+		name           string
+		obj            *unstructured.Unstructured
+		path           []string
+		expectedImages []string
+	}{
+		{
+			name: "pod",
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1", "kind": "Pod",
+				"spec": podSpec("docker.io/org/app:v1", "quay.io/open-cluster-management/work:latest")}},
+			path:           []string{"spec"},
+			expectedImages: []string{"mirror.example.com/dockerhub/org/app:v1", "quay.io/open-cluster-management/work:latest"},
+		},
+		{
+			name: "deployment with the longest prefix matched",
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "apps/v1", "kind": "Deployment",
+				"spec": map[string]interface{}{"template": map[string]interface{}{"spec": podSpec("docker.io/library/nginx", "nginx")}}}},
+			path:           []string{"spec", "template", "spec"},
+			expectedImages: []string{"mirror.example.com/library/nginx", "nginx"},
+		},
+		{
+			name: "cronjob",
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "batch/v1", "kind": "CronJob",
+				"spec": map[string]interface{}{"jobTemplate": map[string]interface{}{"spec": map[string]interface{}{
+					"template": map[string]interface{}{"spec": podSpec("docker.io/org/job")}}}}}},
+			path:           []string{"spec", "jobTemplate", "spec", "template", "spec"},
+			expectedImages: []string{"mirror.example.com/dockerhub/org/job"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := rewriter.Transform(context.TODO(), c.obj, workapiv1.ManifestResourceMeta{}); err != nil {
+				t.Fatal(err)
+			}
+			containers, _, _ := unstructured.NestedSlice(c.obj.Object, append(c.path, "containers")...)
+			if len(containers) != len(c.expectedImages) {
+				t.Fatalf("expected %d containers, but got %v", len(c.expectedImages), containers)
+			}
+			for i, container := range containers {
+				if image := container.(map[string]interface{})["image"]; image != c.expectedImages[i] {
+					t.Errorf("expected image %q, but got %q", c.expectedImages[i], image)
+				}
+			}
+			initContainers, _, _ := unstructured.NestedSlice(c.obj.Object, append(c.path, "initContainers")...)
+			if image := initContainers[0].(map[string]interface{})["image"]; image != "mirror.example.com/dockerhub/busybox" {
+				t.Errorf("expected the image of the init container rewritten, but got %q", image)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+// OrphaningSelectorRule is an orphaning rule selecting the resources of the group/resource by labels. It is
+// evaluated against the live resource once it is deleted, so the resources labeled after they are applied are
+// orphaned as well. The namespace "*" matches the resources in any namespace, an empty namespace matches the
+// cluster scoped resources only. The name must not be set, use the orphaning rules of the deleteOption instead.
+type OrphaningSelectorRule struct {
+	Group     string                `json:"group"`
+	Resource  string                `json:"resource"`
+	Namespace string                `json:"namespace,omitempty"`
+	Name      string                `json:"name,omitempty"`
+	Selector  *metav1.LabelSelector `json:"selector"`
+
+	selector labels.Selector
+}
+
+// OrphaningSelectorRules returns the orphaning rules with selectors of the manifestwork. They only take effect
+// with the propagation policy SelectivelyOrphan. A bad request error is returned if the rules are invalid, so that
+// it is not retried.
+func OrphaningSelectorRules(manifestWork *workapiv1.ManifestWork) ([]OrphaningSelectorRule, error) {
+	value, ok := manifestWork.Annotations[constants.OrphaningSelectorsAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	rules := []OrphaningSelectorRule{}
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, errors.NewBadRequest(fmt.Sprintf("invalid orphaning selectors of manifestwork %s: %v", manifestWork.Name, err))
+	}
+
+	for index := range rules {
+		rule := &rules[index]
+		switch {
+		case len(rule.Resource) == 0:
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid orphaning selectors of manifestwork %s: resource of rule %d is not set",
+				manifestWork.Name, index))
+		case len(rule.Name) != 0:
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid orphaning selectors of manifestwork %s: rule %d cannot have both name and selector set",
+				manifestWork.Name, index))
+		case rule.Selector == nil:
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid orphaning selectors of manifestwork %s: selector of rule %d is not set",
+				manifestWork.Name, index))
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(rule.Selector)
+		if err != nil {
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid orphaning selectors of manifestwork %s: selector of rule %d is invalid: %v",
+				manifestWork.Name, index, err))
+		}
+		rule.selector = selector
+	}
+	return rules, nil
+}
+
+// MatchOrphaningSelectorRules returns the index of the first orphaning rule matching the resource with the given
+// group/resource/namespace and labels, false is returned if none of the rules matches. The rules must be returned by
+// OrphaningSelectorRules.
+func MatchOrphaningSelectorRules(rules []OrphaningSelectorRule, group, resource, namespace string, resourceLabels map[string]string) (int, bool) {
+	for index, rule := range rules {
+		if rule.Group != group || rule.Resource != resource || rule.selector == nil {
+			continue
+		}
+		if rule.Namespace != namespace && (rule.Namespace != "*" || len(namespace) == 0) {
+			continue
+		}
+		if rule.selector.Matches(labels.Set(resourceLabels)) {
+			return index, true
+		}
+	}
+	return -1, false
+}
+
+// OrphaningSelectorReason returns the reason a resource of the manifestwork is orphaned, with the index of the
+// orphaning rule with selector matching the resource returned by MatchOrphaningSelectorRules.
+func OrphaningSelectorReason(manifestWorkName string, ruleIndex int) string {
+	return fmt.Sprintf("it matches orphaning selector %d of manifestwork %s", ruleIndex, manifestWorkName)
+}
+
+// OrphanAppliedResourceBySelectors removes the owner from the given applied resource like OrphanAppliedResource if
+// the live resource matches the orphaning rules with selectors of the manifestwork, and returns true if the resource
+// is orphaned. The resources which do not match are left to the callers, e.g. to be deleted.
+func OrphanAppliedResourceBySelectors(
+	resource workapiv1.AppliedManifestResourceMeta,
+	eventReason, manifestWorkName string,
+	rules []OrphaningSelectorRule,
+	dynamicClient dynamic.Interface,
+	recorder events.Recorder,
+	owner metav1.OwnerReference) (bool, error) {
+	if len(rules) == 0 {
+		return false, nil
+	}
+
+	gvr := schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Resource}
+	u, err := dynamicClient.
+		Resource(gvr).
+		Namespace(resource.Namespace).
+		Get(context.TODO(), resource.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf(
+			"Failed to get resource %v with key %s/%s: %w",
+			gvr, resource.Namespace, resource.Name, err)
+	}
+
+	ruleIndex, matched := MatchOrphaningSelectorRules(rules, resource.Group, resource.Resource, resource.Namespace, u.GetLabels())
+	if !matched {
+		return false, nil
+	}
+	return true, orphanResource(gvr, u, eventReason, OrphaningSelectorReason(manifestWorkName, ruleIndex), dynamicClient, recorder, owner)
+}
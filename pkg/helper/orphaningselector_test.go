@@ -0,0 +1,220 @@
+package helper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+func TestOrphaningSelectorRules(t *testing.T) {
+	cases := []struct {
+		name          string
+		annotations   map[string]string
+		expectedRules int
+		expectedErr   bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name: "valid rules",
+			annotations: map[string]string{constants.OrphaningSelectorsAnnotationKey: `[
+				{"resource": "secrets", "namespace": "*", "selector": {"matchLabels": {"keep": "true"}}},
+				{"group": "apps", "resource": "deployments", "namespace": "ns1",
+				 "selector": {"matchExpressions": [{"key": "tier", "operator": "In", "values": ["db"]}]}}]`},
+			expectedRules: 2,
+		},
+		{
+			name:        "invalid json",
+			annotations: map[string]string{constants.OrphaningSelectorsAnnotationKey: `{`},
+			expectedErr: true,
+		},
+		{
+			name: "name and selector in one rule",
+			annotations: map[string]string{constants.OrphaningSelectorsAnnotationKey: `[
+				{"resource": "secrets", "namespace": "ns1", "name": "n1", "selector": {"matchLabels": {"keep": "true"}}}]`},
+			expectedErr: true,
+		},
+		{
+			name:        "no selector",
+			annotations: map[string]string{constants.OrphaningSelectorsAnnotationKey: `[{"resource": "secrets", "namespace": "ns1"}]`},
+			expectedErr: true,
+		},
+		{
+			name: "invalid selector",
+			annotations: map[string]string{constants.OrphaningSelectorsAnnotationKey: `[
+				{"resource": "secrets", "selector": {"matchExpressions": [{"key": "keep", "operator": "Bad"}]}}]`},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work := &workapiv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "work", Annotations: c.annotations}}
+			rules, err := OrphaningSelectorRules(work)
+			if c.expectedErr {
+				if !errors.IsBadRequest(err) {
+					t.Errorf("expected bad request error, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rules) != c.expectedRules {
+				t.Errorf("expected %d rules, but got %d", c.expectedRules, len(rules))
+			}
+		})
+	}
+}
+
+func TestMatchOrphaningSelectorRules(t *testing.T) {
+	work := &workapiv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "work", Annotations: map[string]string{
+		constants.OrphaningSelectorsAnnotationKey: `[
+			{"resource": "secrets", "namespace": "ns1", "selector": {"matchLabels": {"keep": "ns1"}}},
+			{"resource": "secrets", "namespace": "*", "selector": {"matchLabels": {"keep": "true"}}},
+			{"group": "rbac.authorization.k8s.io", "resource": "clusterroles", "selector": {"matchLabels": {"keep": "true"}}}]`,
+	}}}
+	rules, err := OrphaningSelectorRules(work)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name          string
+		group         string
+		resource      string
+		namespace     string
+		labels        map[string]string
+		expectedIndex int
+		expected      bool
+	}{
+		{
+			name:          "matches the rule of the namespace",
+			resource:      "secrets",
+			namespace:     "ns1",
+			labels:        map[string]string{"keep": "ns1"},
+			expectedIndex: 0,
+			expected:      true,
+		},
+		{
+			name:          "wildcard namespace",
+			resource:      "secrets",
+			namespace:     "ns2",
+			labels:        map[string]string{"keep": "true", "app": "a"},
+			expectedIndex: 1,
+			expected:      true,
+		},
+		{
+			name:          "labels do not match",
+			resource:      "secrets",
+			namespace:     "ns2",
+			labels:        map[string]string{"keep": "ns1"},
+			expectedIndex: -1,
+		},
+		{
+			name:          "no labels",
+			resource:      "secrets",
+			namespace:     "ns1",
+			expectedIndex: -1,
+		},
+		{
+			name:          "cluster scoped resource",
+			group:         "rbac.authorization.k8s.io",
+			resource:      "clusterroles",
+			labels:        map[string]string{"keep": "true"},
+			expectedIndex: 2,
+			expected:      true,
+		},
+		{
+			name:          "another resource type",
+			resource:      "configmaps",
+			namespace:     "ns1",
+			labels:        map[string]string{"keep": "true"},
+			expectedIndex: -1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			index, matched := MatchOrphaningSelectorRules(rules, c.group, c.resource, c.namespace, c.labels)
+			if index != c.expectedIndex || matched != c.expected {
+				t.Errorf("expected rule %d matched %t, but got rule %d matched %t", c.expectedIndex, c.expected, index, matched)
+			}
+		})
+	}
+}
+
+func TestOrphanAppliedResourceBySelectors(t *testing.T) {
+	work := &workapiv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "work", Annotations: map[string]string{
+		constants.OrphaningSelectorsAnnotationKey: `[{"resource": "secrets", "namespace": "*", "selector": {"matchLabels": {"keep": "true"}}}]`,
+	}}}
+	rules, err := OrphaningSelectorRules(work)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := metav1.OwnerReference{APIVersion: "work.open-cluster-management.io/v1", Kind: "AppliedManifestWork", Name: "hub-work", UID: "owner"}
+	kept := newSecret("ns1", "kept", false, "kept", owner)
+	kept.Labels = map[string]string{"keep": "true"}
+	deleted := newSecret("ns1", "deleted", false, "deleted", owner)
+
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	fakeDynamicClient := fakedynamic.NewSimpleDynamicClient(scheme, kept, deleted)
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+	cases := []struct {
+		name           string
+		resource       workapiv1.AppliedManifestResourceMeta
+		expected       bool
+		expectedOwners int
+	}{
+		{
+			name:     "labeled resource is orphaned",
+			resource: workapiv1.AppliedManifestResourceMeta{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "kept", UID: "kept"},
+			expected: true,
+		},
+		{
+			name:           "resource without labels is not orphaned",
+			resource:       workapiv1.AppliedManifestResourceMeta{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "deleted", UID: "deleted"},
+			expectedOwners: 1,
+		},
+		{
+			name:     "missing resource",
+			resource: workapiv1.AppliedManifestResourceMeta{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "missing", UID: "missing"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			orphaned, err := OrphanAppliedResourceBySelectors(c.resource, constants.EventReasonResourceOrphaned, work.Name, rules, fakeDynamicClient, eventstesting.NewTestingEventRecorder(t), owner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if orphaned != c.expected {
+				t.Errorf("expected orphaned %t, but got %t", c.expected, orphaned)
+			}
+
+			actual, err := fakeDynamicClient.Resource(gvr).Namespace(c.resource.Namespace).Get(context.TODO(), c.resource.Name, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(actual.GetOwnerReferences()) != c.expectedOwners {
+				t.Errorf("expected %d owners, but got %v", c.expectedOwners, actual.GetOwnerReferences())
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package helper
+
+import (
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+// IsWorkPaused checks if the reconciliation of the manifestwork is paused, the manifests of a paused manifestwork
+// are not applied, pruned or checked for availability.
+func IsWorkPaused(manifestWork *workapiv1.ManifestWork) bool {
+	switch manifestWork.Annotations[constants.PausedAnnotationKey] {
+	case constants.PausedAnnotationValue, constants.PausedDeletionAnnotationValue:
+		return true
+	}
+	return false
+}
+
+// IsWorkDeletionPaused checks if the finalization of the manifestwork is paused as well, a deleting manifestwork
+// with its deletion paused keeps its applied resources until the annotation is changed.
+func IsWorkDeletionPaused(manifestWork *workapiv1.ManifestWork) bool {
+	return manifestWork.Annotations[constants.PausedAnnotationKey] == constants.PausedDeletionAnnotationValue
+}
@@ -0,0 +1,73 @@
+package helper
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// ReloadableRateLimiter is a token bucket rate limiter of the requests of a client whose qps and burst can be changed
+// while the client is in use, e.g. once the configuration of the agent is reloaded. It is set as the RateLimiter of
+// the rest config of the client, which supersedes the QPS and Burst of the rest config.
+type ReloadableRateLimiter struct {
+	lock    sync.RWMutex
+	qps     float32
+	burst   int
+	limiter flowcontrol.RateLimiter
+}
+
+// NewReloadableRateLimiter returns a ReloadableRateLimiter with the qps and burst.
+func NewReloadableRateLimiter(qps float32, burst int) *ReloadableRateLimiter {
+	return &ReloadableRateLimiter{
+		qps:     qps,
+		burst:   burst,
+		limiter: flowcontrol.NewTokenBucketRateLimiter(qps, burst),
+	}
+}
+
+// SetRate replaces the token bucket with a new one of the qps and burst if either of them is changed. The requests
+// already waiting for the old bucket are not affected.
+func (r *ReloadableRateLimiter) SetRate(qps float32, burst int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.qps == qps && r.burst == burst {
+		return
+	}
+	r.limiter.Stop()
+	r.qps, r.burst = qps, burst
+	r.limiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+}
+
+func (r *ReloadableRateLimiter) current() flowcontrol.RateLimiter {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.limiter
+}
+
+// TryAccept returns true if a token is taken immediately.
+func (r *ReloadableRateLimiter) TryAccept() bool {
+	return r.current().TryAccept()
+}
+
+// Accept blocks until a token is taken.
+func (r *ReloadableRateLimiter) Accept() {
+	r.current().Accept()
+}
+
+// Stop stops the rate limiter.
+func (r *ReloadableRateLimiter) Stop() {
+	r.current().Stop()
+}
+
+// QPS returns the current qps.
+func (r *ReloadableRateLimiter) QPS() float32 {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.qps
+}
+
+// Wait blocks until a token is taken or the context is done.
+func (r *ReloadableRateLimiter) Wait(ctx context.Context) error {
+	return r.current().Wait(ctx)
+}
@@ -0,0 +1,26 @@
+package helper
+
+import "testing"
+
+func TestReloadableRateLimiter(t *testing.T) {
+	limiter := NewReloadableRateLimiter(1, 2)
+	for i := 0; i < 2; i++ {
+		if !limiter.TryAccept() {
+			t.Fatalf("expected request %d accepted within the burst", i)
+		}
+	}
+	if limiter.TryAccept() {
+		t.Errorf("expected request rejected once the burst is used up")
+	}
+
+	// the bucket is replaced with a full one of the new rate
+	limiter.SetRate(10, 3)
+	if limiter.QPS() != 10 {
+		t.Errorf("expected qps 10, but got %v", limiter.QPS())
+	}
+	for i := 0; i < 3; i++ {
+		if !limiter.TryAccept() {
+			t.Fatalf("expected request %d accepted within the new burst", i)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package helper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+)
+
+const (
+	resourceCacheHit      = "cache"
+	resourceCacheLive     = "live"
+	resourceCacheFallback = "fallback"
+
+	// resourceCachePruneInterval is the interval to stop the informers idle for longer than the grace period.
+	resourceCachePruneInterval = 30 * time.Second
+)
+
+var (
+	resourceCacheInformers = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Name: "work_agent_resource_cache_informers",
+			Help: "Number of informers watching the resources applied by the agent, one for each resource type and namespace.",
+		},
+	)
+	resourceCacheObjects = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Name: "work_agent_resource_cache_objects",
+			Help: "Number of objects cached by the informers watching the resources applied by the agent, " +
+				"the memory used by the cache grows with it.",
+		},
+	)
+	resourceCacheLookups = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name: "work_agent_resource_cache_lookups_total",
+			Help: "Number of lookups of the resources applied by the agent by result. It is cache if the lookup is served " +
+				"by an informer, live if it is a request to the spoke apiserver, and fallback if it is a request to the spoke " +
+				"apiserver confirming a resource not found by an informer.",
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(resourceCacheInformers)
+	legacyregistry.MustRegister(resourceCacheObjects)
+	legacyregistry.MustRegister(resourceCacheLookups)
+}
+
+// ResourceCacheKey is a resource type in a namespace watched by an informer of the ResourceCache, the namespace is
+// empty for the cluster scoped resources.
+type ResourceCacheKey struct {
+	GVR       schema.GroupVersionResource
+	Namespace string
+}
+
+// resourceInformer is an informer of the ResourceCache and the time since it is idle.
+type resourceInformer struct {
+	informer cache.SharedIndexInformer
+	stopCh   chan struct{}
+	// idleSince is zero while the informer is referenced by enough resources
+	idleSince time.Time
+}
+
+// ResourceCache serves the lookups of the resources applied by the manifestworks from the informers of the resource
+// types and namespaces in use, instead of a request to the spoke apiserver for each lookup. An informer is started
+// once at least minResources resources of a type in a namespace are referenced, the other resources are fetched from
+// the spoke apiserver since watching them costs more than the requests. An informer referenced by fewer resources is
+// stopped after a grace period, so that it is not restarted over and over while the manifestworks are updated.
+type ResourceCache struct {
+	lock          sync.Mutex
+	dynamicClient dynamic.Interface
+	minResources  int
+	gracePeriod   time.Duration
+	clock         clock.Clock
+	// liveObjects serves the lookups of the resources not watched by informers, they are fetched from the spoke
+	// apiserver if it is nil.
+	liveObjects *LiveObjectCache
+	// references are the numbers of resources of each key referenced by each owner.
+	references map[string]map[ResourceCacheKey]int
+	counts     map[ResourceCacheKey]int
+	informers  map[ResourceCacheKey]*resourceInformer
+	stopped    bool
+}
+
+// NewResourceCache returns a ResourceCache. It must be run with Run to stop the idle informers. The resources not
+// watched by informers are looked up with liveObjects if it is not nil.
+func NewResourceCache(dynamicClient dynamic.Interface, liveObjects *LiveObjectCache, minResources int, gracePeriod time.Duration) *ResourceCache {
+	return &ResourceCache{
+		dynamicClient: dynamicClient,
+		liveObjects:   liveObjects,
+		minResources:  minResources,
+		gracePeriod:   gracePeriod,
+		clock:         clock.RealClock{},
+		references:    map[string]map[ResourceCacheKey]int{},
+		counts:        map[ResourceCacheKey]int{},
+		informers:     map[ResourceCacheKey]*resourceInformer{},
+	}
+}
+
+// SetReferences replaces the resources referenced by the owner, e.g. a manifestwork, with one key for each resource.
+// The references of the owner are dropped if the keys are empty. The informers of the keys referenced by enough
+// resources are started.
+func (c *ResourceCache) SetReferences(owner string, keys []ResourceCacheKey) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	changed := map[ResourceCacheKey]bool{}
+	for key, count := range c.references[owner] {
+		c.counts[key] -= count
+		if c.counts[key] <= 0 {
+			delete(c.counts, key)
+		}
+		changed[key] = true
+	}
+
+	references := map[ResourceCacheKey]int{}
+	for _, key := range keys {
+		references[key]++
+		c.counts[key]++
+		changed[key] = true
+	}
+	if len(references) == 0 {
+		delete(c.references, owner)
+	} else {
+		c.references[owner] = references
+	}
+
+	for key := range changed {
+		c.refreshInformer(key)
+	}
+}
+
+// refreshInformer starts the informer of the key if it is referenced by enough resources, or marks it idle otherwise.
+// The lock must be held by the caller.
+func (c *ResourceCache) refreshInformer(key ResourceCacheKey) {
+	informer, ok := c.informers[key]
+	if c.counts[key] < c.minResources {
+		if ok && informer.idleSince.IsZero() {
+			informer.idleSince = c.clock.Now()
+		}
+		return
+	}
+	if ok {
+		informer.idleSince = time.Time{}
+		return
+	}
+	if c.stopped {
+		return
+	}
+
+	klog.V(4).Infof("Start the informer of %s in namespace %q", key.GVR, key.Namespace)
+	informer = &resourceInformer{
+		informer: dynamicinformer.NewFilteredDynamicInformer(
+			c.dynamicClient, key.GVR, key.Namespace, 0, cache.Indexers{}, nil).Informer(),
+		stopCh: make(chan struct{}),
+	}
+	c.informers[key] = informer
+	resourceCacheInformers.Set(float64(len(c.informers)))
+	go informer.informer.Run(informer.stopCh)
+}
+
+// Get returns the resource from the informer of its type and namespace once the informer is synced, or from the
+// spoke apiserver or the live object cache otherwise. The resource returned must not be modified.
+func (c *ResourceCache) Get(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error) {
+	obj, _, err := c.get(ctx, gvr, namespace, name)
+	return obj, err
+}
+
+// GetConfirmingNotFound returns the resource like Get, but a resource not found by an informer is fetched from the
+// spoke apiserver or the live object cache as well, since the informer may not have caught up with a resource which is
+// just created.
+func (c *ResourceCache) GetConfirmingNotFound(
+	ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error) {
+	obj, cached, err := c.get(ctx, gvr, namespace, name)
+	if !cached || !errors.IsNotFound(err) {
+		return obj, err
+	}
+	resourceCacheLookups.WithLabelValues(resourceCacheFallback).Inc()
+	return c.getLive(ctx, gvr, namespace, name)
+}
+
+// get returns the resource like Get, and if it is looked up from an informer.
+func (c *ResourceCache) get(
+	ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, bool, error) {
+	c.lock.Lock()
+	informer, ok := c.informers[ResourceCacheKey{GVR: gvr, Namespace: namespace}]
+	c.lock.Unlock()
+
+	if !ok || !informer.informer.HasSynced() {
+		resourceCacheLookups.WithLabelValues(resourceCacheLive).Inc()
+		obj, err := c.getLive(ctx, gvr, namespace, name)
+		return obj, false, err
+	}
+
+	resourceCacheLookups.WithLabelValues(resourceCacheHit).Inc()
+	key := name
+	if len(namespace) > 0 {
+		key = namespace + "/" + name
+	}
+	obj, exists, err := informer.informer.GetStore().GetByKey(key)
+	if err != nil {
+		return nil, true, err
+	}
+	if !exists {
+		return nil, true, errors.NewNotFound(gvr.GroupResource(), name)
+	}
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, true, errors.NewNotFound(gvr.GroupResource(), name)
+	}
+	return resource, true, nil
+}
+
+// getLive returns the resource from the live object cache, or from the spoke apiserver if it is nil.
+func (c *ResourceCache) getLive(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error) {
+	if c.liveObjects != nil {
+		return c.liveObjects.Get(ctx, gvr, namespace, name)
+	}
+	return c.dynamicClient.Resource(gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// Run stops the informers idle for longer than the grace period periodically until the context is done, and then
+// stops all the informers.
+func (c *ResourceCache) Run(ctx context.Context) {
+	wait.Until(c.prune, resourceCachePruneInterval, ctx.Done())
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stopped = true
+	for key, informer := range c.informers {
+		close(informer.stopCh)
+		delete(c.informers, key)
+	}
+	resourceCacheInformers.Set(0)
+	resourceCacheObjects.Set(0)
+}
+
+// prune stops the informers idle for longer than the grace period and refreshes the metrics of the cache.
+func (c *ResourceCache) prune() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.clock.Now()
+	objects := 0
+	for key, informer := range c.informers {
+		if !informer.idleSince.IsZero() && now.Sub(informer.idleSince) >= c.gracePeriod {
+			klog.V(4).Infof("Stop the informer of %s in namespace %q idle since %s", key.GVR, key.Namespace, informer.idleSince)
+			close(informer.stopCh)
+			delete(c.informers, key)
+			continue
+		}
+		objects += len(informer.informer.GetStore().ListKeys())
+	}
+	resourceCacheInformers.Set(float64(len(c.informers)))
+	resourceCacheObjects.Set(float64(objects))
+}
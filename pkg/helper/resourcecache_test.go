@@ -0,0 +1,136 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+)
+
+func newConfigMap(namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func TestResourceCache(t *testing.T) {
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	ns1 := ResourceCacheKey{GVR: configMaps, Namespace: "ns1"}
+	ns2 := ResourceCacheKey{GVR: configMaps, Namespace: "ns2"}
+
+	dynamicClient := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{configMaps: "ConfigMapList"},
+		newConfigMap("ns1", "cm1"), newConfigMap("ns1", "cm2"), newConfigMap("ns2", "cm1"))
+	fakeClock := clock.NewFakeClock(time.Now())
+	resourceCache := NewResourceCache(dynamicClient, nil, 2, time.Minute)
+	resourceCache.clock = fakeClock
+
+	assertInformers := func(expected ...ResourceCacheKey) {
+		t.Helper()
+		resourceCache.lock.Lock()
+		defer resourceCache.lock.Unlock()
+		if len(resourceCache.informers) != len(expected) {
+			t.Fatalf("expected informers of %v, but got %d informers", expected, len(resourceCache.informers))
+		}
+		for _, key := range expected {
+			if _, ok := resourceCache.informers[key]; !ok {
+				t.Fatalf("expected the informer of %v started", key)
+			}
+		}
+	}
+	assertGet := func(key ResourceCacheKey, name string, live, found bool) {
+		t.Helper()
+		dynamicClient.ClearActions()
+		_, err := resourceCache.Get(context.TODO(), key.GVR, key.Namespace, name)
+		switch {
+		case found && err != nil:
+			t.Fatalf("expected %s/%s found, but got %v", key.Namespace, name, err)
+		case !found && !errors.IsNotFound(err):
+			t.Fatalf("expected %s/%s not found, but got %v", key.Namespace, name, err)
+		}
+		if requests := len(dynamicClient.Actions()); (requests > 0) != live {
+			t.Fatalf("expected live lookup %t, but got %d requests", live, requests)
+		}
+	}
+
+	// the informer is started once enough resources are referenced across the owners
+	resourceCache.SetReferences("work1", []ResourceCacheKey{ns1, ns2})
+	assertInformers()
+	assertGet(ns1, "cm1", true, true)
+	resourceCache.SetReferences("work2", []ResourceCacheKey{ns1})
+	assertInformers(ns1)
+
+	ns1Informer := resourceCache.informers[ns1]
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return ns1Informer.informer.HasSynced(), nil
+	}); err != nil {
+		t.Fatalf("the informer of %v is not synced: %v", ns1, err)
+	}
+	assertGet(ns1, "cm2", false, true)
+	assertGet(ns1, "cm3", false, false)
+
+	// a resource not found by the informer lagging behind the spoke is confirmed by a live lookup
+	if err := ns1Informer.informer.GetStore().Delete(newConfigMap("ns1", "cm2")); err != nil {
+		t.Fatal(err)
+	}
+	assertGet(ns1, "cm2", false, false)
+	dynamicClient.ClearActions()
+	if _, err := resourceCache.GetConfirmingNotFound(context.TODO(), configMaps, "ns1", "cm2"); err != nil {
+		t.Fatalf("expected ns1/cm2 found by the live lookup, but got %v", err)
+	}
+	if len(dynamicClient.Actions()) != 1 {
+		t.Fatalf("expected a live lookup, but got %v", dynamicClient.Actions())
+	}
+	if err := ns1Informer.informer.GetStore().Add(newConfigMap("ns1", "cm2")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the resources of the types not watched are fetched from the spoke
+	assertGet(ns2, "cm1", true, true)
+
+	// the informer is kept for the grace period once it is idle
+	resourceCache.SetReferences("work2", nil)
+	fakeClock.Step(30 * time.Second)
+	resourceCache.prune()
+	assertInformers(ns1)
+	assertGet(ns1, "cm1", false, true)
+
+	// the informer referenced again is not stopped
+	resourceCache.SetReferences("work2", []ResourceCacheKey{ns1})
+	fakeClock.Step(time.Minute)
+	resourceCache.prune()
+	assertInformers(ns1)
+
+	// the informer idle for the grace period is stopped
+	resourceCache.SetReferences("work1", nil)
+	resourceCache.SetReferences("work2", nil)
+	fakeClock.Step(time.Minute)
+	resourceCache.prune()
+	assertInformers()
+	select {
+	case <-ns1Informer.stopCh:
+	default:
+		t.Fatalf("expected the informer of %v stopped", ns1)
+	}
+	assertGet(ns1, "cm1", true, true)
+
+	// all informers are stopped once the cache stops
+	resourceCache.SetReferences("work1", []ResourceCacheKey{ns2, ns2})
+	assertInformers(ns2)
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	resourceCache.Run(ctx)
+	assertInformers()
+	resourceCache.SetReferences("work2", []ResourceCacheKey{ns1, ns1})
+	assertInformers()
+}
@@ -0,0 +1,135 @@
+package helper
+
+import (
+	goerrors "errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+func TestResourceError(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	conflictErr := errors.NewConflict(gvr.GroupResource(), "test", fmt.Errorf("the resource was recreated"))
+	noKindMatchErr := &meta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "test.io", Kind: "Foo"}}
+
+	cases := []struct {
+		name           string
+		err            error
+		expectedType   error
+		expectedReason string
+		expectedClass  ApplyErrorClass
+	}{
+		{
+			name:           "resource conflict",
+			err:            applyerrors.NewResourceConflictError(gvr, "ns1", "test", conflictErr),
+			expectedType:   applyerrors.ErrResourceConflict,
+			expectedReason: "ResourceConflict",
+			expectedClass:  ApplyErrorRetryable,
+		},
+		{
+			name:           "mapping not found",
+			err:            applyerrors.NewMappingNotFoundError(schema.GroupVersionResource{Group: "test.io", Version: "v1"}, "ns1", "test", noKindMatchErr),
+			expectedType:   applyerrors.ErrMappingNotFound,
+			expectedReason: "APIVersionNotAvailable",
+			expectedClass:  ApplyErrorRetryable,
+		},
+		{
+			name:           "validation failed",
+			err:            applyerrors.NewValidationFailedError(gvr, "ns1", "test", fmt.Errorf("spec.replicas must be set")),
+			expectedType:   applyerrors.ErrValidationFailed,
+			expectedReason: "ManifestInvalid",
+			expectedClass:  ApplyErrorTerminal,
+		},
+		{
+			name:           "namespace not permitted",
+			err:            applyerrors.NewNamespaceNotPermittedError(gvr, "ns1", "test", fmt.Errorf("namespace ns1 is not allowed")),
+			expectedType:   applyerrors.ErrNamespaceNotPermitted,
+			expectedReason: "NamespaceNotPermittedForExecutor",
+			expectedClass:  ApplyErrorTerminal,
+		},
+		{
+			name:           "resource forbidden by agent policy",
+			err:            applyerrors.NewResourceForbiddenByAgentPolicyError(gvr, "ns1", "test", fmt.Errorf("secrets are forbidden")),
+			expectedType:   applyerrors.ErrResourceForbiddenByAgentPolicy,
+			expectedReason: "ResourceForbiddenByAgentPolicy",
+			expectedClass:  ApplyErrorTerminal,
+		},
+		{
+			name:           "manifest transform failed",
+			err:            applyerrors.NewManifestTransformFailedError(gvr, "ns1", "test", fmt.Errorf("mirror is not reachable")),
+			expectedType:   applyerrors.ErrManifestTransformFailed,
+			expectedReason: "ManifestTransformFailed",
+			expectedClass:  ApplyErrorRetryable,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// the error is matched once it is wrapped and aggregated
+			_, err := AggregateManifestErrors(1, []error{nil, fmt.Errorf("failed to apply: %w", c.err)})
+			if c.expectedClass == ApplyErrorTerminal {
+				err = fmt.Errorf("failed to apply: %w", c.err)
+			}
+			if !goerrors.Is(err, c.expectedType) {
+				t.Fatalf("expected %v, but got %v", c.expectedType, err)
+			}
+			for _, other := range []error{applyerrors.ErrResourceConflict, applyerrors.ErrMappingNotFound,
+				applyerrors.ErrValidationFailed, applyerrors.ErrNamespaceNotPermitted} {
+				if other != c.expectedType && goerrors.Is(err, other) {
+					t.Errorf("expected not %v, but got %v", other, err)
+				}
+			}
+
+			// errors.As does not visit the aggregated errors, they are flattened like SplitNotAllowedErrors does
+			var aggregate utilerrors.Aggregate
+			if goerrors.As(err, &aggregate) {
+				err = aggregate.Errors()[0]
+			}
+			var resourceErr *applyerrors.ResourceError
+			if !goerrors.As(err, &resourceErr) {
+				t.Fatalf("expected applyerrors.ResourceError, but got %v", err)
+			}
+			if resourceErr.Namespace != "ns1" || resourceErr.Name != "test" || resourceErr.Reason() != c.expectedReason {
+				t.Errorf("unexpected error %v with reason %q", resourceErr, resourceErr.Reason())
+			}
+			if class := ClassifyApplyError(err); class != c.expectedClass {
+				t.Errorf("expected %q, but got %q", c.expectedClass, class)
+			}
+		})
+	}
+
+	// the wrapped errors are unwrapped
+	err := fmt.Errorf("manifest 0: %w", applyerrors.NewResourceConflictError(gvr, "ns1", "test", conflictErr))
+	if !errors.IsConflict(err) || err.Error() != "manifest 0: "+conflictErr.Error() {
+		t.Errorf("expected conflict error unwrapped, but got %v", err)
+	}
+	if err := applyerrors.NewMappingNotFoundError(gvr, "ns1", "test", noKindMatchErr); !IsAPIVersionNotAvailableError(err) {
+		t.Errorf("expected api version not available, but got %v", err)
+	}
+
+	// the resource of the manifest which cannot be decoded is unknown
+	decodeErr := fmt.Errorf("manifest 0: %w", applyerrors.NewManifestDecodeFailedError(fmt.Errorf("unexpected end of JSON input")))
+	var resourceErr *applyerrors.ResourceError
+	if !goerrors.Is(decodeErr, applyerrors.ErrManifestDecodeFailed) || !goerrors.As(decodeErr, &resourceErr) ||
+		resourceErr.Reason() != "ManifestDecodeFailed" || ClassifyApplyError(decodeErr) != ApplyErrorTerminal {
+		t.Errorf("expected terminal manifest decode failed error, but got %v", decodeErr)
+	}
+
+	// the errors with a requeue time are split out of the aggregated errors
+	requeueAfter, remaining := SplitNotAllowedErrors(utilerrors.NewAggregate([]error{
+		fmt.Errorf("manifest 0: %w", &applyerrors.ResourceError{Type: applyerrors.ErrResourceConflict, Err: conflictErr, RequeueTime: time.Second}),
+		fmt.Errorf("manifest 1: %w", applyerrors.NewResourceConflictError(gvr, "ns1", "test", conflictErr)),
+	}))
+	if requeueAfter != time.Second {
+		t.Errorf("expected requeue after 1s, but got %v", requeueAfter)
+	}
+	if !goerrors.Is(remaining, applyerrors.ErrResourceConflict) || len(remaining.(utilerrors.Aggregate).Errors()) != 1 {
+		t.Errorf("expected the conflict error without requeue time remaining, but got %v", remaining)
+	}
+}
@@ -0,0 +1,224 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+const (
+	// DefaultMaxStatusSize is the default max size of the marshaled status of a manifestwork, which leaves
+	// enough room for the spec of the manifestwork under the default request size limit of etcd.
+	DefaultMaxStatusSize = 512 * 1024
+
+	statusReasonMessagesDropped     = "ManifestMessagesDropped"
+	statusReasonConditionsCollapsed = "ManifestConditionsCollapsed"
+)
+
+// StatusBudgeter keeps the status of manifestworks under a max size, otherwise every status update of a
+// manifestwork with hundreds of manifests fails once the manifestwork exceeds the size limit of etcd. A status
+// over the max size is truncated progressively:
+// 1. the messages of the manifest conditions are dropped;
+// 2. the conditions of the manifests are collapsed into counts by type and status.
+// The resource metas of the manifests and the conditions of the manifestwork itself are never dropped, and the
+// condition StatusTruncated explains what is dropped.
+type StatusBudgeter struct {
+	maxSize int
+}
+
+// NewStatusBudgeter returns a StatusBudgeter. The status is not limited if maxSize is not positive.
+func NewStatusBudgeter(maxSize int) *StatusBudgeter {
+	return &StatusBudgeter{maxSize: maxSize}
+}
+
+// Budget truncates the status of the manifestwork with the given generation in place if its marshaled size exceeds
+// the max size, and returns true if the status is truncated. Once the status is truncated, it is truncated at least
+// as much until the generation changes, because a writer only refills part of the dropped fields, e.g. the messages
+// of the Available conditions, and the truncation would flap between the writers otherwise. The condition
+// StatusTruncated is removed if the status fits in the max size.
+func (b *StatusBudgeter) Budget(status *workapiv1.ManifestWorkStatus, generation int64) (bool, error) {
+	if b == nil || b.maxSize <= 0 {
+		return false, nil
+	}
+
+	// the existing condition is kept aside, so that its transition time is not changed if it is set again
+	var existing *metav1.Condition
+	if condition := meta.FindStatusCondition(status.Conditions, constants.WorkStatusTruncated); condition != nil {
+		existing = condition.DeepCopy()
+		meta.RemoveStatusCondition(&status.Conditions, constants.WorkStatusTruncated)
+	}
+	existingReason := ""
+	if existing != nil && existing.Status == metav1.ConditionTrue && existing.ObservedGeneration == generation {
+		existingReason = existing.Reason
+	}
+
+	if len(existingReason) == 0 {
+		if fits, err := b.fits(status); err != nil || fits {
+			return false, err
+		}
+	}
+
+	dropManifestMessages(status)
+	setStatusTruncated(status, existing, generation, statusReasonMessagesDropped,
+		fmt.Sprintf("The messages of the manifest conditions are dropped to fit the status in %d bytes", b.maxSize))
+	if existingReason != statusReasonConditionsCollapsed {
+		if fits, err := b.fits(status); err != nil || fits {
+			return true, err
+		}
+	}
+
+	meta.RemoveStatusCondition(&status.Conditions, constants.WorkStatusTruncated)
+	counts := collapseManifestConditions(status)
+	if existingReason == statusReasonConditionsCollapsed {
+		// keep the counts of the condition types which are not refilled by the writer
+		counts = mergeConditionCounts(counts, parseConditionCounts(existing.Message))
+	}
+	setStatusTruncated(status, existing, generation, statusReasonConditionsCollapsed,
+		fmt.Sprintf("The conditions of %d manifests are collapsed into counts to fit the status in %d bytes: %s",
+			len(status.ResourceStatus.Manifests), b.maxSize, formatConditionCounts(counts)))
+	if fits, err := b.fits(status); err != nil || fits {
+		return true, err
+	}
+
+	klog.Warningf("The status of manifestwork exceeds %d bytes after it is truncated", b.maxSize)
+	return true, nil
+}
+
+func (b *StatusBudgeter) fits(status *workapiv1.ManifestWorkStatus) (bool, error) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return false, err
+	}
+	return len(data) <= b.maxSize, nil
+}
+
+// dropManifestMessages clears the messages of the manifest conditions.
+func dropManifestMessages(status *workapiv1.ManifestWorkStatus) {
+	for i := range status.ResourceStatus.Manifests {
+		conditions := status.ResourceStatus.Manifests[i].Conditions
+		for j := range conditions {
+			conditions[j].Message = ""
+		}
+	}
+}
+
+// collapseManifestConditions removes the conditions of the manifests and returns the counts of the removed
+// conditions keyed by type and status, e.g. "Applied=True".
+func collapseManifestConditions(status *workapiv1.ManifestWorkStatus) map[string]int {
+	counts := map[string]int{}
+	for i := range status.ResourceStatus.Manifests {
+		for _, condition := range status.ResourceStatus.Manifests[i].Conditions {
+			counts[fmt.Sprintf("%s=%s", condition.Type, condition.Status)]++
+		}
+		status.ResourceStatus.Manifests[i].Conditions = []metav1.Condition{}
+	}
+	return counts
+}
+
+// mergeConditionCounts adds the existing counts of the condition types which are not in the counts.
+func mergeConditionCounts(counts, existing map[string]int) map[string]int {
+	types := sets.NewString()
+	for key := range counts {
+		types.Insert(strings.SplitN(key, "=", 2)[0])
+	}
+	for key, count := range existing {
+		if !types.Has(strings.SplitN(key, "=", 2)[0]) {
+			counts[key] = count
+		}
+	}
+	return counts
+}
+
+// formatConditionCounts formats the counts sorted by key, e.g. "Applied=True: 3, Available=Unknown: 1".
+func formatConditionCounts(counts map[string]int) string {
+	keys := []string{}
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	items := []string{}
+	for _, key := range keys {
+		items = append(items, fmt.Sprintf("%s: %d", key, counts[key]))
+	}
+	return strings.Join(items, ", ")
+}
+
+// parseConditionCounts parses the counts at the end of the message of the condition StatusTruncated.
+func parseConditionCounts(message string) map[string]int {
+	counts := map[string]int{}
+	index := strings.Index(message, "bytes: ")
+	if index < 0 {
+		return counts
+	}
+	for _, item := range strings.Split(message[index+len("bytes: "):], ", ") {
+		parts := strings.SplitN(item, ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		count, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+		counts[parts[0]] = count
+	}
+	return counts
+}
+
+func setStatusTruncated(status *workapiv1.ManifestWorkStatus, existing *metav1.Condition, generation int64, reason, message string) {
+	if existing != nil {
+		status.Conditions = append(status.Conditions, *existing)
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               constants.WorkStatusTruncated,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// statusBudgetingManifestWorkClient truncates the status of manifestworks with a StatusBudgeter before it is written.
+type statusBudgetingManifestWorkClient struct {
+	workv1client.ManifestWorkInterface
+	budgeter *StatusBudgeter
+}
+
+// NewStatusBudgetingManifestWorkClient returns a manifestwork client whose status writes are truncated by the budgeter.
+func NewStatusBudgetingManifestWorkClient(
+	client workv1client.ManifestWorkInterface, budgeter *StatusBudgeter) workv1client.ManifestWorkInterface {
+	return &statusBudgetingManifestWorkClient{ManifestWorkInterface: client, budgeter: budgeter}
+}
+
+func (c *statusBudgetingManifestWorkClient) UpdateStatus(
+	ctx context.Context, manifestWork *workapiv1.ManifestWork, opts metav1.UpdateOptions) (*workapiv1.ManifestWork, error) {
+	manifestWork = manifestWork.DeepCopy()
+	if _, err := c.budgeter.Budget(&manifestWork.Status, manifestWork.Generation); err != nil {
+		return nil, fmt.Errorf("failed to truncate status of manifestwork %s: %w", manifestWork.Name, err)
+	}
+	return c.ManifestWorkInterface.UpdateStatus(ctx, manifestWork, opts)
+}
+
+// BudgetManifestWorkStatus truncates the status of the manifestwork with the given generation in place with the
+// budgeter of the client, so that the status can be compared with the truncated status on the hub before it is
+// written. Nothing is changed if the client is not created by NewStatusBudgetingManifestWorkClient.
+func BudgetManifestWorkStatus(
+	client workv1client.ManifestWorkInterface, status *workapiv1.ManifestWorkStatus, generation int64) error {
+	c, ok := client.(*statusBudgetingManifestWorkClient)
+	if !ok {
+		return nil
+	}
+	_, err := c.budgeter.Budget(status, generation)
+	return err
+}
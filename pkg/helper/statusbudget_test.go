@@ -0,0 +1,219 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+// newLargeStatus returns a status with the given number of manifests, each of which has an Applied and an
+// Available condition with a message of the given length.
+func newLargeStatus(manifests, messageLength int) *workapiv1.ManifestWorkStatus {
+	status := &workapiv1.ManifestWorkStatus{
+		Conditions: []metav1.Condition{
+			newCondition(workapiv1.WorkApplied, string(metav1.ConditionTrue), "AppliedManifestWorkComplete", "Apply manifest work complete", nil),
+			newCondition(workapiv1.WorkAvailable, string(metav1.ConditionFalse), "ResourcesNotAvailable", "1 of 200 resources are not available", nil),
+		},
+	}
+	for i := 0; i < manifests; i++ {
+		available := string(metav1.ConditionTrue)
+		if i == 0 {
+			available = string(metav1.ConditionFalse)
+		}
+		status.ResourceStatus.Manifests = append(status.ResourceStatus.Manifests, workapiv1.ManifestCondition{
+			ResourceMeta: workapiv1.ManifestResourceMeta{
+				Ordinal: int32(i), Version: "v1", Kind: "ConfigMap", Resource: "configmaps", Namespace: "ns1", Name: fmt.Sprintf("cm%d", i),
+			},
+			Conditions: []metav1.Condition{
+				newCondition(string(workapiv1.ManifestApplied), string(metav1.ConditionTrue), "AppliedManifestComplete", strings.Repeat("a", messageLength), nil),
+				newCondition(string(workapiv1.ManifestAvailable), available, "ResourceAvailable", strings.Repeat("b", messageLength), nil),
+			},
+		})
+	}
+	return status
+}
+
+func statusSize(t *testing.T, status *workapiv1.ManifestWorkStatus) int {
+	data, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(data)
+}
+
+func TestStatusBudgeter(t *testing.T) {
+	cases := []struct {
+		name              string
+		maxSize           int
+		status            *workapiv1.ManifestWorkStatus
+		expectedTruncated bool
+		expectedReason    string
+		expectedMessages  bool
+		expectedCollapsed bool
+	}{
+		{
+			name:             "not limited",
+			maxSize:          0,
+			status:           newLargeStatus(200, 1000),
+			expectedMessages: true,
+		},
+		{
+			name:             "fits in the max size",
+			maxSize:          1024 * 1024,
+			status:           newLargeStatus(200, 1000),
+			expectedMessages: true,
+		},
+		{
+			name:    "remove the condition once the status fits in the max size after the generation changes",
+			maxSize: 1024 * 1024,
+			status: func() *workapiv1.ManifestWorkStatus {
+				status := newLargeStatus(200, 1000)
+				status.Conditions = append(status.Conditions, newCondition(
+					constants.WorkStatusTruncated, string(metav1.ConditionTrue), statusReasonMessagesDropped, "dropped", nil))
+				return status
+			}(),
+			expectedMessages: true,
+		},
+		{
+			name:              "drop messages",
+			maxSize:           200 * 1024,
+			status:            newLargeStatus(200, 1000),
+			expectedTruncated: true,
+			expectedReason:    statusReasonMessagesDropped,
+		},
+		{
+			name:              "collapse conditions",
+			maxSize:           50 * 1024,
+			status:            newLargeStatus(200, 1000),
+			expectedTruncated: true,
+			expectedReason:    statusReasonConditionsCollapsed,
+			expectedCollapsed: true,
+		},
+		{
+			name:              "too large after collapsed",
+			maxSize:           1024,
+			status:            newLargeStatus(200, 1000),
+			expectedTruncated: true,
+			expectedReason:    statusReasonConditionsCollapsed,
+			expectedCollapsed: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			budgeter := NewStatusBudgeter(c.maxSize)
+			status := c.status.DeepCopy()
+			truncated, err := budgeter.Budget(status, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if truncated != c.expectedTruncated {
+				t.Errorf("expected truncated %v, but got %v", c.expectedTruncated, truncated)
+			}
+			if c.expectedTruncated && c.maxSize >= 50*1024 && statusSize(t, status) > c.maxSize {
+				t.Errorf("expected status in %d bytes, but got %d", c.maxSize, statusSize(t, status))
+			}
+
+			// the conditions of the manifestwork are never dropped
+			for _, conditionType := range []string{workapiv1.WorkApplied, workapiv1.WorkAvailable} {
+				expected := meta.FindStatusCondition(c.status.Conditions, conditionType)
+				if actual := meta.FindStatusCondition(status.Conditions, conditionType); actual == nil || *actual != *expected {
+					t.Errorf("expected condition %v, but got %v", expected, actual)
+				}
+			}
+
+			condition := meta.FindStatusCondition(status.Conditions, constants.WorkStatusTruncated)
+			switch {
+			case !c.expectedTruncated && condition != nil:
+				t.Errorf("expected no condition StatusTruncated, but got %v", condition)
+			case c.expectedTruncated && (condition == nil || condition.Reason != c.expectedReason):
+				t.Errorf("expected condition StatusTruncated with reason %q, but got %v", c.expectedReason, condition)
+			}
+			if c.expectedCollapsed && !strings.Contains(condition.Message, "Applied=True: 200, Available=False: 1, Available=True: 199") {
+				t.Errorf("expected counts in message, but got %q", condition.Message)
+			}
+
+			// the resource metas of the manifests are never dropped
+			if len(status.ResourceStatus.Manifests) != len(c.status.ResourceStatus.Manifests) {
+				t.Fatalf("expected %d manifests, but got %d", len(c.status.ResourceStatus.Manifests), len(status.ResourceStatus.Manifests))
+			}
+			for i, manifest := range status.ResourceStatus.Manifests {
+				if manifest.ResourceMeta != c.status.ResourceStatus.Manifests[i].ResourceMeta {
+					t.Errorf("expected resource meta %v, but got %v", c.status.ResourceStatus.Manifests[i].ResourceMeta, manifest.ResourceMeta)
+				}
+				if c.expectedCollapsed != (len(manifest.Conditions) == 0) {
+					t.Errorf("expected collapsed %v, but got conditions %v", c.expectedCollapsed, manifest.Conditions)
+				}
+				for _, condition := range manifest.Conditions {
+					if c.expectedMessages != (len(condition.Message) > 0) {
+						t.Errorf("expected messages %v, but got %q", c.expectedMessages, condition.Message)
+					}
+				}
+			}
+
+			// the truncation is stable when a writer refills part of the dropped fields
+			refilled := status.DeepCopy()
+			for i := range refilled.ResourceStatus.Manifests {
+				refilled.ResourceStatus.Manifests[i].Conditions = MergeStatusConditions(
+					refilled.ResourceStatus.Manifests[i].Conditions, c.status.ResourceStatus.Manifests[i].Conditions[:1])
+			}
+			if _, err := budgeter.Budget(refilled, 1); err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(refilled, status) {
+				t.Errorf("expected stable status %v, but got %v", status.Conditions, refilled.Conditions)
+			}
+		})
+	}
+}
+
+func TestStatusBudgetingManifestWorkClient(t *testing.T) {
+	work := &workapiv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "work1", Namespace: "cluster1"}}
+	fakeClient := fakeworkclient.NewSimpleClientset(work)
+	client := NewStatusBudgetingManifestWorkClient(fakeClient.WorkV1().ManifestWorks("cluster1"), NewStatusBudgeter(200*1024))
+
+	updateStatus := func(status *workapiv1.ManifestWorkStatus) error {
+		newStatus := newLargeStatus(200, 1000)
+		status.Conditions = MergeStatusConditions(status.Conditions, newStatus.Conditions)
+		status.ResourceStatus.Manifests = MergeManifestConditions(status.ResourceStatus.Manifests, newStatus.ResourceStatus.Manifests)
+		return nil
+	}
+
+	updates := 0
+	for i := 0; i < 3; i++ {
+		work, err := fakeClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), "work1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, updated, err := UpdateManifestWorkStatus(context.TODO(), client, work, updateStatus)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated {
+			updates++
+		}
+	}
+	if updates != 1 {
+		t.Errorf("expected 1 update, but got %d", updates)
+	}
+
+	work, err := fakeClient.WorkV1().ManifestWorks("cluster1").Get(context.TODO(), "work1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := statusSize(t, &work.Status); size > 200*1024 {
+		t.Errorf("expected status in %d bytes, but got %d", 200*1024, size)
+	}
+	if !meta.IsStatusConditionTrue(work.Status.Conditions, constants.WorkStatusTruncated) {
+		t.Errorf("expected condition StatusTruncated, but got %v", work.Status.Conditions)
+	}
+}
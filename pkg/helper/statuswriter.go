@@ -0,0 +1,199 @@
+package helper
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	statusWriteSucceeded = "success"
+	statusWriteFailed    = "failure"
+)
+
+var (
+	statusWritesPending = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Name: "work_agent_status_writes_pending",
+			Help: "Number of manifestworks with status updates waiting to be written to the hub.",
+		},
+	)
+	statusWrites = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name: "work_agent_status_writes_total",
+			Help: "Number of status writes of the manifestworks to the hub by result.",
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(statusWritesPending)
+	legacyregistry.MustRegister(statusWrites)
+}
+
+// StatusWriter writes the status of the manifestworks to the hub with a pool of workers, so that a reconcile is
+// finished once the status updates are enqueued instead of waiting on the hub. The updates enqueued for a work are
+// coalesced into one write with the updates applied in the order they are enqueued, and the writes of a work are
+// never concurrent. The failed writes are retried with backoff, the writes to an unreachable hub are failed fast by
+// the circuit breaker of the client.
+type StatusWriter struct {
+	client  workv1client.ManifestWorkInterface
+	getWork func(name string) (*workapiv1.ManifestWork, error)
+	queue   workqueue.RateLimitingInterface
+
+	lock sync.Mutex
+	// pending are the status updates of each work waiting to be written
+	pending map[string][]UpdateManifestWorkStatusFunc
+	// pendingAnnotations are the annotations of each work waiting to be written
+	pendingAnnotations map[string]map[string]string
+}
+
+// NewStatusWriter returns a StatusWriter writing with the client. The works are fetched with getWork before they are
+// written, e.g. from a lister. It must be run with Run to write the status.
+func NewStatusWriter(client workv1client.ManifestWorkInterface, getWork func(name string) (*workapiv1.ManifestWork, error)) *StatusWriter {
+	return &StatusWriter{
+		client:             client,
+		getWork:            getWork,
+		queue:              workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "StatusWriter"),
+		pending:            map[string][]UpdateManifestWorkStatusFunc{},
+		pendingAnnotations: map[string]map[string]string{},
+	}
+}
+
+// Enqueue adds the status updates of the work, they are applied after the updates enqueued before.
+func (w *StatusWriter) Enqueue(manifestWorkName string, updateFuncs ...UpdateManifestWorkStatusFunc) {
+	if len(updateFuncs) == 0 {
+		return
+	}
+
+	w.lock.Lock()
+	w.pending[manifestWorkName] = append(w.pending[manifestWorkName], updateFuncs...)
+	statusWritesPending.Set(float64(len(w.pending)))
+	w.lock.Unlock()
+
+	w.queue.Add(manifestWorkName)
+}
+
+// EnqueueAnnotations adds the annotations of the work, they are written before the status of the work and override
+// the values of the same keys enqueued before.
+func (w *StatusWriter) EnqueueAnnotations(manifestWorkName string, annotations map[string]string) {
+	if len(annotations) == 0 {
+		return
+	}
+
+	w.lock.Lock()
+	pending, ok := w.pendingAnnotations[manifestWorkName]
+	if !ok {
+		pending = map[string]string{}
+		w.pendingAnnotations[manifestWorkName] = pending
+	}
+	for key, value := range annotations {
+		pending[key] = value
+	}
+	w.lock.Unlock()
+
+	w.queue.Add(manifestWorkName)
+}
+
+// Run starts the workers writing the status and blocks until the context is done.
+func (w *StatusWriter) Run(ctx context.Context, workers int) {
+	defer utilruntime.HandleCrash()
+	defer w.queue.ShutDown()
+
+	for i := 0; i < workers; i++ {
+		go wait.UntilWithContext(ctx, w.runWorker, 0)
+	}
+	<-ctx.Done()
+}
+
+func (w *StatusWriter) runWorker(ctx context.Context) {
+	for w.processNextWork(ctx) {
+	}
+}
+
+// processNextWork writes the status of a work from the queue. The queue never hands out a work being processed, so
+// the writes of a work are serialized.
+func (w *StatusWriter) processNextWork(ctx context.Context) bool {
+	key, quit := w.queue.Get()
+	if quit {
+		return false
+	}
+	defer w.queue.Done(key)
+
+	manifestWorkName := key.(string)
+	if err := w.write(ctx, manifestWorkName); err != nil {
+		statusWrites.WithLabelValues(statusWriteFailed).Inc()
+		klog.Errorf("Failed to write the status of manifestwork %s: %v", manifestWorkName, err)
+		w.queue.AddRateLimited(key)
+		return true
+	}
+	w.queue.Forget(key)
+	return true
+}
+
+// write writes the pending annotations of the work and then applies the pending status updates of the work and
+// writes its status. The updates are kept ahead of the updates enqueued since then if the write fails, and dropped
+// if the work is deleted.
+func (w *StatusWriter) write(ctx context.Context, manifestWorkName string) error {
+	w.lock.Lock()
+	updateFuncs := w.pending[manifestWorkName]
+	delete(w.pending, manifestWorkName)
+	annotations := w.pendingAnnotations[manifestWorkName]
+	delete(w.pendingAnnotations, manifestWorkName)
+	w.lock.Unlock()
+
+	if len(updateFuncs) == 0 && len(annotations) == 0 {
+		return nil
+	}
+
+	var err error
+	if len(annotations) > 0 {
+		err = PatchManifestWorkAnnotations(ctx, w.client, manifestWorkName, annotations)
+	}
+	if err == nil && len(updateFuncs) > 0 {
+		var manifestWork *workapiv1.ManifestWork
+		manifestWork, err = w.getWork(manifestWorkName)
+		if err == nil {
+			_, _, err = UpdateManifestWorkStatus(ctx, w.client, manifestWork.DeepCopy(), updateFuncs...)
+		}
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	defer func() {
+		statusWritesPending.Set(float64(len(w.pending)))
+	}()
+	switch {
+	case errors.IsNotFound(err):
+		klog.V(4).Infof("Drop the status updates of manifestwork %s since it is deleted", manifestWorkName)
+		return nil
+	case err != nil:
+		w.pending[manifestWorkName] = append(updateFuncs, w.pending[manifestWorkName]...)
+		if len(annotations) > 0 {
+			// the annotations enqueued since then override the failed ones
+			pending, ok := w.pendingAnnotations[manifestWorkName]
+			if !ok {
+				w.pendingAnnotations[manifestWorkName] = annotations
+				return err
+			}
+			for key, value := range annotations {
+				if _, ok := pending[key]; !ok {
+					pending[key] = value
+				}
+			}
+		}
+		return err
+	}
+	statusWrites.WithLabelValues(statusWriteSucceeded).Inc()
+	return nil
+}
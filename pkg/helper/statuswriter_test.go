@@ -0,0 +1,178 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// serialCheckingManifestWorkClient fails the test if the status of a work is written concurrently, and fails the
+// first write of each work to verify the updates are retried in order.
+type serialCheckingManifestWorkClient struct {
+	workv1client.ManifestWorkInterface
+	t *testing.T
+
+	lock     sync.Mutex
+	inflight map[string]bool
+	failed   map[string]bool
+}
+
+func (c *serialCheckingManifestWorkClient) UpdateStatus(
+	ctx context.Context, manifestWork *workapiv1.ManifestWork, opts metav1.UpdateOptions) (*workapiv1.ManifestWork, error) {
+	c.lock.Lock()
+	if c.inflight[manifestWork.Name] {
+		c.t.Errorf("expected the writes of manifestwork %s serialized, but got concurrent writes", manifestWork.Name)
+	}
+	c.inflight[manifestWork.Name] = true
+	fail := !c.failed[manifestWork.Name]
+	c.failed[manifestWork.Name] = true
+	c.lock.Unlock()
+
+	defer func() {
+		c.lock.Lock()
+		c.inflight[manifestWork.Name] = false
+		c.lock.Unlock()
+	}()
+
+	// a slow hub widens the window of concurrent writes
+	time.Sleep(time.Millisecond)
+	if fail {
+		return nil, ErrHubUnavailable
+	}
+	return c.ManifestWorkInterface.UpdateStatus(ctx, manifestWork, opts)
+}
+
+// appendOrder returns the status update appending the index to the message of the Order condition.
+func appendOrder(index int) UpdateManifestWorkStatusFunc {
+	return func(status *workapiv1.ManifestWorkStatus) error {
+		message := strconv.Itoa(index)
+		if condition := meta.FindStatusCondition(status.Conditions, "Order"); condition != nil {
+			message = condition.Message + "," + message
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type: "Order", Status: metav1.ConditionTrue, Reason: "Order", Message: message,
+		})
+		return nil
+	}
+}
+
+func TestStatusWriterOrdering(t *testing.T) {
+	works, updates := 5, 50
+	objects := []runtime.Object{}
+	for i := 0; i < works; i++ {
+		objects = append(objects, &workapiv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("work%d", i), Namespace: "cluster1"},
+		})
+	}
+	fakeWorkClient := fakeworkclient.NewSimpleClientset(objects...)
+	hubClient := fakeWorkClient.WorkV1().ManifestWorks("cluster1")
+	client := &serialCheckingManifestWorkClient{
+		ManifestWorkInterface: hubClient,
+		t:                     t,
+		inflight:              map[string]bool{},
+		failed:                map[string]bool{},
+	}
+	statusWriter := NewStatusWriter(client, func(name string) (*workapiv1.ManifestWork, error) {
+		return hubClient.Get(context.TODO(), name, metav1.GetOptions{})
+	})
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go statusWriter.Run(ctx, 3)
+
+	// the updates of each work are enqueued in order while the works are written concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < works; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			for index := 0; index < updates; index++ {
+				statusWriter.Enqueue(name, appendOrder(index))
+				time.Sleep(100 * time.Microsecond)
+			}
+		}(fmt.Sprintf("work%d", i))
+	}
+	wg.Wait()
+	// the updates of a deleted work are dropped
+	statusWriter.Enqueue("deleted", appendOrder(0))
+
+	expected := make([]string, updates)
+	for index := range expected {
+		expected[index] = strconv.Itoa(index)
+	}
+	expectedMessage := strings.Join(expected, ",")
+	for i := 0; i < works; i++ {
+		name := fmt.Sprintf("work%d", i)
+		var message string
+		if err := wait.PollImmediate(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+			work, err := hubClient.Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			if condition := meta.FindStatusCondition(work.Status.Conditions, "Order"); condition != nil {
+				message = condition.Message
+			}
+			return message == expectedMessage, nil
+		}); err != nil {
+			t.Errorf("expected the updates of %s applied in order %q, but got %q", name, expectedMessage, message)
+		}
+	}
+
+	if err := wait.PollImmediate(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+		statusWriter.lock.Lock()
+		defer statusWriter.lock.Unlock()
+		return len(statusWriter.pending) == 0, nil
+	}); err != nil {
+		t.Errorf("expected no pending updates, but got %v", statusWriter.pending)
+	}
+}
+
+func TestStatusWriterAnnotations(t *testing.T) {
+	work := &workapiv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{Name: "work1", Namespace: "cluster1", Annotations: map[string]string{"a": "0", "c": "0"}},
+	}
+	hubClient := fakeworkclient.NewSimpleClientset(work).WorkV1().ManifestWorks("cluster1")
+	writer := NewStatusWriter(hubClient, func(name string) (*workapiv1.ManifestWork, error) {
+		return hubClient.Get(context.TODO(), name, metav1.GetOptions{})
+	})
+
+	// the annotations enqueued later override the ones enqueued before
+	writer.EnqueueAnnotations(work.Name, map[string]string{"a": "1", "b": "1"})
+	writer.EnqueueAnnotations(work.Name, map[string]string{"b": "2"})
+	writer.Enqueue(work.Name, appendOrder(0))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go writer.Run(ctx, 1)
+
+	expected := map[string]string{"a": "1", "b": "2", "c": "0"}
+	if err := wait.PollImmediate(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+		updatedWork, err := hubClient.Get(context.TODO(), work.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if meta.FindStatusCondition(updatedWork.Status.Conditions, "Order") == nil {
+			return false, nil
+		}
+		for key, value := range expected {
+			if updatedWork.Annotations[key] != value {
+				return false, fmt.Errorf("expected annotations %v, but got %v", expected, updatedWork.Annotations)
+			}
+		}
+		return true, nil
+	}); err != nil {
+		t.Error(err)
+	}
+}
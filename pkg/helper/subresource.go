@@ -0,0 +1,51 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+const (
+	// SubresourceScale drives the replicas of an existing resource with the scale subresource.
+	SubresourceScale = "scale"
+	// SubresourceStatus writes the status of an existing resource with the status subresource.
+	SubresourceStatus = "status"
+)
+
+// ManifestSubresource defines the subresource which a manifest is applied to.
+type ManifestSubresource struct {
+	Ordinal     int32  `json:"ordinal"`
+	Subresource string `json:"subresource"`
+}
+
+// ManifestSubresources returns the subresources of the manifests of the manifestwork keyed by the ordinal of
+// the manifests. A bad request error is returned if the subresources are invalid, so that it is not retried.
+func ManifestSubresources(manifestWork *workapiv1.ManifestWork) (map[int32]string, error) {
+	value, ok := manifestWork.Annotations[constants.ManifestSubresourcesAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	subresources := []ManifestSubresource{}
+	if err := json.Unmarshal([]byte(value), &subresources); err != nil {
+		return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest subresources of manifestwork %s: %v", manifestWork.Name, err))
+	}
+
+	result := map[int32]string{}
+	for _, subresource := range subresources {
+		switch {
+		case subresource.Subresource != SubresourceScale && subresource.Subresource != SubresourceStatus:
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest subresources of manifestwork %s: subresource %q is not supported",
+				manifestWork.Name, subresource.Subresource))
+		case subresource.Ordinal < 0 || int(subresource.Ordinal) >= len(manifestWork.Spec.Workload.Manifests):
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid manifest subresources of manifestwork %s: ordinal %d is out of range",
+				manifestWork.Name, subresource.Ordinal))
+		}
+		result[subresource.Ordinal] = subresource.Subresource
+	}
+	return result, nil
+}
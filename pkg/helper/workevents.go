@@ -0,0 +1,105 @@
+package helper
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// maxDedupEventKeys is the number of recorded events above which the expired events are pruned.
+const maxDedupEventKeys = 4096
+
+// NewWorkEventRecorder returns a recorder whose component is suffixed with the hub hash and the name of the
+// manifestwork, so that the events on the spoke can be attributed to the manifestwork.
+func NewWorkEventRecorder(recorder events.Recorder, hubHash, manifestWorkName string) events.Recorder {
+	return recorder.WithComponentSuffix(fmt.Sprintf("%s-%s", hubHash, manifestWorkName))
+}
+
+// EventDeduplicator drops the events identical to an event recorded within the interval. Events are identical if
+// they have the same component, type, reason and message, e.g. the same failure of applying a resource of a
+// manifestwork retried over and over.
+type EventDeduplicator struct {
+	lock     sync.Mutex
+	clock    clock.Clock
+	interval time.Duration
+	// lastRecorded is the last time each event was recorded.
+	lastRecorded map[string]time.Time
+}
+
+// NewEventDeduplicator returns an EventDeduplicator, nil is returned if the interval is not positive.
+func NewEventDeduplicator(interval time.Duration) *EventDeduplicator {
+	if interval <= 0 {
+		return nil
+	}
+	return &EventDeduplicator{
+		clock:        clock.RealClock{},
+		interval:     interval,
+		lastRecorded: map[string]time.Time{},
+	}
+}
+
+// Wrap returns a recorder dropping the duplicated events, the recorder is returned as is if the deduplicator is nil.
+func (d *EventDeduplicator) Wrap(recorder events.Recorder) events.Recorder {
+	if d == nil {
+		return recorder
+	}
+	return &dedupingRecorder{Recorder: recorder, deduplicator: d}
+}
+
+// shouldRecord returns true if the event is not recorded within the interval.
+func (d *EventDeduplicator) shouldRecord(component, eventType, reason, message string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := d.clock.Now()
+	key := fmt.Sprintf("%s/%s/%s/%s", component, eventType, reason, message)
+	if last, ok := d.lastRecorded[key]; ok && now.Sub(last) < d.interval {
+		return false
+	}
+
+	if len(d.lastRecorded) >= maxDedupEventKeys {
+		for k, last := range d.lastRecorded {
+			if now.Sub(last) >= d.interval {
+				delete(d.lastRecorded, k)
+			}
+		}
+	}
+	d.lastRecorded[key] = now
+	return true
+}
+
+type dedupingRecorder struct {
+	events.Recorder
+	deduplicator *EventDeduplicator
+}
+
+func (r *dedupingRecorder) Event(reason, message string) {
+	if r.deduplicator.shouldRecord(r.ComponentName(), "Normal", reason, message) {
+		r.Recorder.Event(reason, message)
+	}
+}
+
+func (r *dedupingRecorder) Eventf(reason, messageFmt string, args ...interface{}) {
+	r.Event(reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *dedupingRecorder) Warning(reason, message string) {
+	if r.deduplicator.shouldRecord(r.ComponentName(), "Warning", reason, message) {
+		r.Recorder.Warning(reason, message)
+	}
+}
+
+func (r *dedupingRecorder) Warningf(reason, messageFmt string, args ...interface{}) {
+	r.Warning(reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *dedupingRecorder) ForComponent(componentName string) events.Recorder {
+	return r.deduplicator.Wrap(r.Recorder.ForComponent(componentName))
+}
+
+func (r *dedupingRecorder) WithComponentSuffix(componentNameSuffix string) events.Recorder {
+	return r.deduplicator.Wrap(r.Recorder.WithComponentSuffix(componentNameSuffix))
+}
@@ -0,0 +1,56 @@
+package helper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+func TestEventDeduplicator(t *testing.T) {
+	inMemoryRecorder := events.NewInMemoryRecorder("work-agent")
+	deduplicator := NewEventDeduplicator(5 * time.Minute)
+	fakeClock := clock.NewFakeClock(time.Now())
+	deduplicator.clock = fakeClock
+
+	if NewEventDeduplicator(0).Wrap(inMemoryRecorder) != inMemoryRecorder {
+		t.Errorf("expected events not deduplicated if the interval is not positive")
+	}
+
+	assertEvents := func(expected int) {
+		if actual := len(inMemoryRecorder.Events()); actual != expected {
+			t.Fatalf("expected %d events, but got %d: %v", expected, actual, inMemoryRecorder.Events())
+		}
+	}
+
+	recorder := NewWorkEventRecorder(deduplicator.Wrap(inMemoryRecorder), "hub1", "work1")
+	recorder.Warningf(constants.EventReasonResourceAppliedFailed, "Failed to apply manifest %d: %s", 0, "conflict")
+	recorder.Warningf(constants.EventReasonResourceAppliedFailed, "Failed to apply manifest %d: %s", 0, "conflict")
+	assertEvents(1)
+	event := inMemoryRecorder.Events()[0]
+	if event.Source.Component != "work-agent-hub1-work1" || event.Reason != constants.EventReasonResourceAppliedFailed ||
+		event.Type != corev1.EventTypeWarning {
+		t.Errorf("unexpected event %v", event)
+	}
+
+	// the events of other resources or reasons are not identical
+	recorder.Warningf(constants.EventReasonResourceAppliedFailed, "Failed to apply manifest %d: %s", 1, "conflict")
+	recorder.Eventf(constants.EventReasonResourceApplied, "Applied manifest %d", 0)
+	assertEvents(3)
+
+	// the identical event is recorded again after the interval
+	fakeClock.Step(5 * time.Minute)
+	recorder.Warningf(constants.EventReasonResourceAppliedFailed, "Failed to apply manifest %d: %s", 0, "conflict")
+	assertEvents(4)
+
+	// the events of other manifestworks are not identical
+	recorder = NewWorkEventRecorder(deduplicator.Wrap(inMemoryRecorder.ForComponent("work-agent")), "hub1", "work2")
+	recorder.Warningf(constants.EventReasonResourceAppliedFailed, "Failed to apply manifest %d: %s", 0, "conflict")
+	assertEvents(5)
+	if event := inMemoryRecorder.Events()[4]; event.Source.Component != "work-agent-hub1-work2" {
+		t.Errorf("unexpected event %v", event)
+	}
+}
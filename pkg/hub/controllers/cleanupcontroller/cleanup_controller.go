@@ -0,0 +1,133 @@
+package cleanupcontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
+	workinformer "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
+	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+// CleanupController holds the deletion of manifestworks on the hub with the finalizer ManifestWorkHubCleanupFinalizer
+// until the agent reports the applied resources are deleted on the managed cluster. If the cleanup is not reported
+// within the timeout, e.g. the agent is offline, the event CleanupTimedOut is recorded and the finalizers of both the
+// hub and the agent are removed, the applied resources left are evicted once the agent is back.
+type CleanupController struct {
+	manifestWorkClient workv1client.ManifestWorksGetter
+	manifestWorkLister worklister.ManifestWorkLister
+	timeout            time.Duration
+	clock              clock.Clock
+}
+
+// NewCleanupController returns a CleanupController, the cleanup never times out if the timeout is not positive.
+func NewCleanupController(
+	recorder events.Recorder,
+	manifestWorkClient workv1client.ManifestWorksGetter,
+	manifestWorkInformer workinformer.ManifestWorkInformer,
+	timeout time.Duration) factory.Controller {
+	controller := &CleanupController{
+		manifestWorkClient: manifestWorkClient,
+		manifestWorkLister: manifestWorkInformer.Lister(),
+		timeout:            timeout,
+		clock:              clock.RealClock{},
+	}
+
+	return factory.New().
+		WithInformersQueueKeyFunc(func(obj runtime.Object) string {
+			key, _ := cache.MetaNamespaceKeyFunc(obj)
+			return key
+		}, manifestWorkInformer.Informer()).
+		WithSync(controller.sync).ToController("ManifestWorkCleanupController", recorder)
+}
+
+func (c *CleanupController) sync(ctx context.Context, controllerContext factory.SyncContext) error {
+	key := controllerContext.QueueKey()
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore the invalid queue key
+		return nil
+	}
+	klog.V(4).Infof("Reconciling the cleanup of ManifestWork %q", key)
+
+	manifestWork, err := c.manifestWorkLister.ManifestWorks(namespace).Get(name)
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if manifestWork.DeletionTimestamp.IsZero() {
+		patch, err := helper.AddFinalizerPatch(manifestWork.Finalizers, constants.ManifestWorkHubCleanupFinalizer)
+		if err != nil || patch == nil {
+			return err
+		}
+		return c.patchFinalizers(ctx, manifestWork, patch)
+	}
+
+	if !helper.HasFinalizer(manifestWork, constants.ManifestWorkHubCleanupFinalizer) {
+		return nil
+	}
+
+	// the manifestwork is cleaned up if the agent reports it, or the agent has never added its finalizer, in which
+	// case no resource is applied. The agent cannot add its finalizer once the manifestwork is deleting.
+	if meta.IsStatusConditionTrue(manifestWork.Status.Conditions, constants.WorkCleanupCompleted) ||
+		!helper.HasFinalizer(manifestWork, constants.ManifestWorkFinalizer) {
+		return c.removeFinalizers(ctx, manifestWork, constants.ManifestWorkHubCleanupFinalizer)
+	}
+
+	if c.timeout <= 0 {
+		return nil
+	}
+	if remaining := manifestWork.DeletionTimestamp.Add(c.timeout).Sub(c.clock.Now()); remaining > 0 {
+		controllerContext.Queue().AddAfter(key, remaining)
+		return nil
+	}
+
+	controllerContext.Recorder().Warningf("CleanupTimedOut",
+		"The cleanup of ManifestWork %s is not reported by the agent within %s, the resources applied on the managed cluster may be left",
+		key, c.timeout)
+	return c.removeFinalizers(ctx, manifestWork, constants.ManifestWorkFinalizer, constants.ManifestWorkHubCleanupFinalizer)
+}
+
+func (c *CleanupController) removeFinalizers(ctx context.Context, manifestWork *workapiv1.ManifestWork, finalizers ...string) error {
+	patch, err := helper.RemoveFinalizerPatch(manifestWork.Finalizers, finalizers...)
+	if err != nil || patch == nil {
+		return err
+	}
+	return c.patchFinalizers(ctx, manifestWork, patch)
+}
+
+// patchFinalizers changes the finalizers of the manifestwork with the json patch, instead of updating the whole
+// manifestwork which may be changed by others since it was read. The manifestwork is requeued if the finalizers tested
+// by the patch are changed.
+func (c *CleanupController) patchFinalizers(ctx context.Context, manifestWork *workapiv1.ManifestWork, patch []byte) error {
+	_, err := c.manifestWorkClient.ManifestWorks(manifestWork.Namespace).Patch(
+		ctx, manifestWork.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case errors.IsInvalid(err):
+		// the test operation fails if the finalizers are changed, retry with the latest manifestwork
+		return errors.NewConflict(workapiv1.Resource("manifestworks"), manifestWork.Name, err)
+	case err != nil:
+		return fmt.Errorf("failed to patch the finalizers of ManifestWork %s/%s: %w", manifestWork.Namespace, manifestWork.Name, err)
+	}
+	return nil
+}
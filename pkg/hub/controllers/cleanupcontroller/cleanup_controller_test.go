@@ -0,0 +1,153 @@
+package cleanupcontroller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	clienttesting "k8s.io/client-go/testing"
+	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestSync(t *testing.T) {
+	now := time.Now()
+	deletedAt := metav1.NewTime(now.Add(-time.Minute))
+	cleanupCompleted := metav1.Condition{Type: constants.WorkCleanupCompleted, Status: metav1.ConditionTrue, Reason: "CleanupCompleted"}
+
+	cases := []struct {
+		name               string
+		deletionTimestamp  *metav1.Time
+		finalizers         []string
+		conditions         []metav1.Condition
+		timeout            time.Duration
+		expectedFinalizers []string
+		expectedRequeue    bool
+		expectedEvent      bool
+	}{
+		{
+			name:               "add finalizer",
+			finalizers:         []string{constants.ManifestWorkFinalizer},
+			timeout:            time.Hour,
+			expectedFinalizers: []string{constants.ManifestWorkFinalizer, constants.ManifestWorkHubCleanupFinalizer},
+		},
+		{
+			name:              "hold the deletion until the cleanup is reported",
+			deletionTimestamp: &deletedAt,
+			finalizers:        []string{constants.ManifestWorkFinalizer, constants.ManifestWorkHubCleanupFinalizer},
+			// the work is requeued once the cleanup times out in 1ms
+			timeout:         time.Minute + time.Millisecond,
+			expectedRequeue: true,
+		},
+		{
+			name:               "remove finalizer once the cleanup is reported",
+			deletionTimestamp:  &deletedAt,
+			finalizers:         []string{constants.ManifestWorkFinalizer, constants.ManifestWorkHubCleanupFinalizer},
+			conditions:         []metav1.Condition{cleanupCompleted},
+			timeout:            time.Hour,
+			expectedFinalizers: []string{constants.ManifestWorkFinalizer},
+		},
+		{
+			name:               "remove finalizer if the work is not applied by the agent",
+			deletionTimestamp:  &deletedAt,
+			finalizers:         []string{constants.ManifestWorkHubCleanupFinalizer},
+			timeout:            time.Hour,
+			expectedFinalizers: []string{},
+		},
+		{
+			name:               "remove finalizers once the cleanup times out",
+			deletionTimestamp:  &deletedAt,
+			finalizers:         []string{constants.ManifestWorkFinalizer, "test", constants.ManifestWorkHubCleanupFinalizer},
+			timeout:            time.Minute,
+			expectedFinalizers: []string{"test"},
+			expectedEvent:      true,
+		},
+		{
+			name:              "never time out",
+			deletionTimestamp: &deletedAt,
+			finalizers:        []string{constants.ManifestWorkFinalizer, constants.ManifestWorkHubCleanupFinalizer},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, _ := spoketesting.NewManifestWork(0)
+			work.DeletionTimestamp = c.deletionTimestamp
+			work.Finalizers = c.finalizers
+			work.Status.Conditions = c.conditions
+
+			fakeClient := fakeworkclient.NewSimpleClientset(work)
+			informerFactory := workinformers.NewSharedInformerFactory(fakeClient, 5*time.Minute)
+			if err := informerFactory.Work().V1().ManifestWorks().Informer().GetStore().Add(work); err != nil {
+				t.Fatal(err)
+			}
+			controller := &CleanupController{
+				manifestWorkClient: fakeClient.WorkV1(),
+				manifestWorkLister: informerFactory.Work().V1().ManifestWorks().Lister(),
+				timeout:            c.timeout,
+				clock:              clock.NewFakeClock(now),
+			}
+
+			recorder := events.NewInMemoryRecorder("test")
+			syncContext := spoketesting.NewFakeSyncContext(t, work.Namespace+"/"+work.Name).WithRecorder(recorder)
+			if err := controller.sync(context.TODO(), syncContext); err != nil {
+				t.Fatal(err)
+			}
+
+			actions := fakeClient.Actions()
+			if c.expectedFinalizers == nil {
+				if len(actions) != 0 {
+					t.Errorf("expected no action, but got %v", actions)
+				}
+			} else {
+				if len(actions) != 1 {
+					t.Fatalf("expected 1 action, but got %v", actions)
+				}
+				spoketesting.AssertAction(t, actions[0], "patch")
+				if patchType := actions[0].(clienttesting.PatchAction).GetPatchType(); patchType != types.JSONPatchType {
+					t.Errorf("expected the finalizers patched with a json patch, but got %s", patchType)
+				}
+				updated, err := fakeClient.WorkV1().ManifestWorks(work.Namespace).Get(context.TODO(), work.Name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !equalFinalizers(updated.Finalizers, c.expectedFinalizers) {
+					t.Errorf("expected finalizers %v, but got %v", c.expectedFinalizers, updated.Finalizers)
+				}
+			}
+
+			time.Sleep(10 * time.Millisecond)
+			if requeued := syncContext.Queue().Len() > 0; requeued != c.expectedRequeue {
+				t.Errorf("expected requeued %t, but got %t", c.expectedRequeue, requeued)
+			}
+			timedOut := false
+			for _, event := range recorder.Events() {
+				if event.Reason == "CleanupTimedOut" {
+					timedOut = true
+				}
+			}
+			if timedOut != c.expectedEvent {
+				t.Errorf("expected event CleanupTimedOut recorded %t, but got %v", c.expectedEvent, recorder.Events())
+			}
+		})
+	}
+}
+
+func equalFinalizers(finalizers, expected []string) bool {
+	if len(finalizers) != len(expected) {
+		return false
+	}
+	for i := range finalizers {
+		if finalizers[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package hub
+
+import (
+	"context"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/controllercmd"
+	"github.com/spf13/cobra"
+	workclientset "open-cluster-management.io/api/client/work/clientset/versioned"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+
+	"open-cluster-management.io/work/pkg/hub/controllers/cleanupcontroller"
+)
+
+// ManagerOptions defines the flags for the work hub manager
+type ManagerOptions struct {
+	CleanupTimeout     time.Duration
+	WorkInformerResync time.Duration
+}
+
+// NewManagerOptions returns the flags with default value set
+func NewManagerOptions() *ManagerOptions {
+	return &ManagerOptions{
+		CleanupTimeout:     24 * time.Hour,
+		WorkInformerResync: 10 * time.Minute,
+	}
+}
+
+// AddFlags register and binds the default flags
+func (o *ManagerOptions) AddFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.DurationVar(&o.CleanupTimeout, "cleanup-timeout", o.CleanupTimeout,
+		"Max time a deleting ManifestWork is held until the agent reports its applied resources are deleted on the managed cluster, "+
+			"e.g. while the agent is offline. Once it is exceeded, the event CleanupTimedOut is recorded and the ManifestWork is deleted. "+
+			"It never times out if it is not positive.")
+	flags.DurationVar(&o.WorkInformerResync, "work-informer-resync", o.WorkInformerResync,
+		"Resync period of the informer of ManifestWorks.")
+}
+
+// RunManager starts the controllers of the work hub manager
+func (o *ManagerOptions) RunManager(ctx context.Context, controllerContext *controllercmd.ControllerContext) error {
+	workClient, err := workclientset.NewForConfig(controllerContext.KubeConfig)
+	if err != nil {
+		return err
+	}
+	workInformerFactory := workinformers.NewSharedInformerFactory(workClient, o.WorkInformerResync)
+
+	cleanupController := cleanupcontroller.NewCleanupController(
+		controllerContext.EventRecorder,
+		workClient.WorkV1(),
+		workInformerFactory.Work().V1().ManifestWorks(),
+		o.CleanupTimeout,
+	)
+
+	go workInformerFactory.Start(ctx.Done())
+	go cleanupController.Run(ctx, 1)
+	<-ctx.Done()
+	return nil
+}
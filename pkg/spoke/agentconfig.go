@@ -0,0 +1,217 @@
+package spoke
+
+import (
+	goflag "flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/fileobserver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/config"
+	"open-cluster-management.io/work/pkg/spoke/controllers/manifestcontroller"
+)
+
+// agentConfigObserveInterval is the interval of checking the file of --agent-config for changes.
+const agentConfigObserveInterval = 10 * time.Second
+
+// flagConfiguration returns the configuration of the flags, the fields not configured in the file of --agent-config
+// are defaulted to them.
+func (o *WorkloadAgentOptions) flagConfiguration() *config.AgentConfiguration {
+	// copy the values, so that the configuration is not changed with the options
+	hubKubeconfigFile, spokeClusterName := o.HubKubeconfigFile, o.SpokeClusterName
+	qps, burst, maxAppliedResources, maxManifests := o.QPS, o.Burst, o.MaxAppliedResources, o.MaxManifests
+	cfg := &config.AgentConfiguration{
+		HubKubeconfigFile:              &hubKubeconfigFile,
+		SpokeClusterName:               &spokeClusterName,
+		HubWorkInformerResync:          &metav1.Duration{Duration: o.HubWorkInformerResync},
+		SpokeAppliedWorkInformerResync: &metav1.Duration{Duration: o.SpokeAppliedWorkInformerResync},
+		SpokeKubeInformerResync:        &metav1.Duration{Duration: o.SpokeKubeInformerResync},
+		SpokeKubeAPIQPS:                &qps,
+		SpokeKubeAPIBurst:              &burst,
+		ManifestApplyTimeout:           &metav1.Duration{Duration: o.ManifestApplyTimeout},
+		MaxAppliedResources:            &maxAppliedResources,
+		MaxManifests:                   &maxManifests,
+		HubProbeInterval:               &metav1.Duration{Duration: o.HubProbeInterval},
+		ForbiddenResources:             append([]string{}, o.ForbiddenResources...),
+	}
+	// the verbosity is not changed if the -v flag of klog is not registered, e.g. in tests
+	if f := goflag.CommandLine.Lookup("v"); f != nil {
+		if verbosity, err := strconv.ParseInt(f.Value.String(), 10, 32); err == nil {
+			v := int32(verbosity)
+			cfg.Verbosity = &v
+		}
+	}
+	return cfg
+}
+
+// applyConfiguration sets the options to the fields of the configuration, the fields not configured are ignored.
+func (o *WorkloadAgentOptions) applyConfiguration(cfg *config.AgentConfiguration) {
+	if cfg.HubKubeconfigFile != nil {
+		o.HubKubeconfigFile = *cfg.HubKubeconfigFile
+	}
+	if cfg.SpokeClusterName != nil {
+		o.SpokeClusterName = *cfg.SpokeClusterName
+	}
+	if cfg.HubWorkInformerResync != nil {
+		o.HubWorkInformerResync = cfg.HubWorkInformerResync.Duration
+	}
+	if cfg.SpokeAppliedWorkInformerResync != nil {
+		o.SpokeAppliedWorkInformerResync = cfg.SpokeAppliedWorkInformerResync.Duration
+	}
+	if cfg.SpokeKubeInformerResync != nil {
+		o.SpokeKubeInformerResync = cfg.SpokeKubeInformerResync.Duration
+	}
+	if cfg.SpokeKubeAPIQPS != nil {
+		o.QPS = *cfg.SpokeKubeAPIQPS
+	}
+	if cfg.SpokeKubeAPIBurst != nil {
+		o.Burst = *cfg.SpokeKubeAPIBurst
+	}
+	if cfg.ManifestApplyTimeout != nil {
+		o.ManifestApplyTimeout = cfg.ManifestApplyTimeout.Duration
+	}
+	if cfg.MaxAppliedResources != nil {
+		o.MaxAppliedResources = *cfg.MaxAppliedResources
+	}
+	if cfg.MaxManifests != nil {
+		o.MaxManifests = *cfg.MaxManifests
+	}
+	if cfg.HubProbeInterval != nil {
+		o.HubProbeInterval = cfg.HubProbeInterval.Duration
+	}
+	if cfg.ForbiddenResources != nil {
+		o.ForbiddenResources = cfg.ForbiddenResources
+	}
+}
+
+// agentConfigReloader applies the configuration in the file of --agent-config to the running agent once the file is
+// changed. The reloadable fields take effect without restarting the agent, the changes of the immutable fields are
+// ignored with a warning that a restart is required.
+type agentConfigReloader struct {
+	lock sync.Mutex
+	file string
+	// defaults is the configuration of the flags, the fields removed from the file are reverted to them
+	defaults *config.AgentConfiguration
+	// current is the configuration applied to the agent
+	current *config.AgentConfiguration
+	// data is the content of the file the current configuration is loaded from
+	data []byte
+	// rateLimiters limit the requests of the spoke clients
+	rateLimiters []*helper.ReloadableRateLimiter
+	// applyLimits are shared by the manifestwork controllers of all the hubs
+	applyLimits *manifestcontroller.ApplyLimits
+	// hubProbes are the probes of all the hubs
+	hubProbes *helper.HubProbes
+}
+
+// newAgentConfigReloader loads the configuration in the file of --agent-config and applies it to the options, so
+// that the configuration supersedes the flags. The verbosity is set once the configuration is loaded.
+func (o *WorkloadAgentOptions) newAgentConfigReloader() (*agentConfigReloader, error) {
+	r := &agentConfigReloader{
+		file:     o.AgentConfigFile,
+		defaults: o.flagConfiguration(),
+	}
+
+	data, cfg, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	if err := setVerbosity(cfg.Verbosity); err != nil {
+		return nil, err
+	}
+	r.data, r.current = data, cfg
+	o.applyConfiguration(cfg)
+	return r, nil
+}
+
+// load reads, defaults and validates the configuration in the file.
+func (r *agentConfigReloader) load() ([]byte, *config.AgentConfiguration, error) {
+	data, err := ioutil.ReadFile(r.file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read the agent config %q: %w", r.file, err)
+	}
+	cfg, err := config.Decode(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to decode the agent config %q: %w", r.file, err)
+	}
+	config.SetDefaults(cfg, r.defaults)
+	if errs := config.Validate(cfg); len(errs) > 0 {
+		return nil, nil, fmt.Errorf("the agent config %q is invalid: %w", r.file, errs.ToAggregate())
+	}
+	return data, cfg, nil
+}
+
+// reload loads the configuration in the file again and applies the reloadable fields to the running agent. The
+// current configuration is kept if the file is invalid.
+func (r *agentConfigReloader) reload() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	data, cfg, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	if changed := config.RestartRequired(r.current, cfg); len(changed) > 0 {
+		klog.Warningf("The fields %s of the agent config %q are changed, restart the agent for them to take effect",
+			strings.Join(changed, ","), r.file)
+		// keep the immutable fields in effect, so that the warning is logged until the agent is restarted
+		config.KeepImmutableFields(cfg, r.current)
+	}
+	forbiddenResources, err := parseForbiddenResources(cfg.ForbiddenResources)
+	if err != nil {
+		return err
+	}
+
+	if err := setVerbosity(cfg.Verbosity); err != nil {
+		return err
+	}
+	for _, limiter := range r.rateLimiters {
+		limiter.SetRate(*cfg.SpokeKubeAPIQPS, *cfg.SpokeKubeAPIBurst)
+	}
+	if r.applyLimits != nil {
+		r.applyLimits.Set(cfg.ManifestApplyTimeout.Duration, *cfg.MaxAppliedResources, *cfg.MaxManifests,
+			forbiddenResources)
+	}
+	if r.hubProbes != nil {
+		r.hubProbes.SetInterval(cfg.HubProbeInterval.Duration)
+	}
+
+	klog.Infof("The agent config %q is reloaded", r.file)
+	r.data, r.current = data, cfg
+	return nil
+}
+
+// run reloads the configuration once the file is changed until the stop channel is closed. The mounted ConfigMaps
+// are updated by replacing the symlinks, so the content of the file is polled instead of watching the file.
+func (r *agentConfigReloader) run(stopCh <-chan struct{}) error {
+	observer, err := fileobserver.NewObserver(agentConfigObserveInterval)
+	if err != nil {
+		return err
+	}
+	observer.AddReactor(func(file string, action fileobserver.ActionType) error {
+		if action == fileobserver.FileDeleted {
+			klog.Warningf("The agent config %q is deleted, keep the current config", file)
+			return nil
+		}
+		return r.reload()
+	}, map[string][]byte{r.file: r.data}, r.file)
+	go observer.Run(stopCh)
+	return nil
+}
+
+// setVerbosity sets the log level verbosity of klog, it is not changed if the verbosity is nil.
+func setVerbosity(verbosity *int32) error {
+	if verbosity == nil {
+		return nil
+	}
+	var level klog.Level
+	return level.Set(strconv.Itoa(int(*verbosity)))
+}
@@ -0,0 +1,106 @@
+package spoke
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/controllers/manifestcontroller"
+)
+
+func TestReloadAgentConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(data string) {
+		if err := ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	zero := int32(0)
+	defer setVerbosity(&zero)
+
+	o := NewWorkloadAgentOptions()
+	o.SpokeClusterName = "cluster1"
+	o.HubKubeconfigFile = "/spoke/hub-kubeconfig/kubeconfig"
+	o.AgentConfigFile = file
+	writeConfig(`
+spokeKubeAPIQPS: 20
+maxManifests: 30
+`)
+
+	// the config supersedes the flags
+	reloader, err := o.newAgentConfigReloader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.QPS != 20 || o.Burst != 100 || o.MaxManifests != 30 || o.SpokeClusterName != "cluster1" {
+		t.Errorf("expected the options superseded by the config, but got %+v", o)
+	}
+
+	limiter := helper.NewReloadableRateLimiter(o.QPS, o.Burst)
+	applyLimits := manifestcontroller.NewApplyLimits(o.ManifestApplyTimeout, o.MaxAppliedResources, o.MaxManifests, nil)
+	reloader.rateLimiters, reloader.applyLimits = []*helper.ReloadableRateLimiter{limiter}, applyLimits
+
+	// the reloadable fields take effect, the fields removed from the config are reverted to the flags
+	writeConfig(`
+verbosity: 6
+spokeKubeAPIQPS: 5
+manifestApplyTimeout: 1m
+maxAppliedResources: 500
+forbiddenResources:
+- secrets
+- clusterrolebindings.rbac.authorization.k8s.io
+`)
+	if err := reloader.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if limiter.QPS() != 5 {
+		t.Errorf("expected qps 5, but got %v", limiter.QPS())
+	}
+	if applyLimits.ApplyTimeout() != time.Minute || applyLimits.MaxAppliedResources() != 500 ||
+		applyLimits.MaxManifests() != helper.DefaultMaxManifests {
+		t.Errorf("unexpected apply limits %v, %d, %d", applyLimits.ApplyTimeout(), applyLimits.MaxAppliedResources(), applyLimits.MaxManifests())
+	}
+	if !applyLimits.IsForbidden(schema.GroupResource{Resource: "secrets"}) ||
+		!applyLimits.IsForbidden(schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "clusterrolebindings"}) {
+		t.Errorf("expected the secrets and clusterrolebindings forbidden")
+	}
+	if !klog.V(6).Enabled() || klog.V(7).Enabled() {
+		t.Errorf("expected verbosity 6")
+	}
+
+	// the immutable fields are kept until the agent is restarted
+	writeConfig(`
+spokeClusterName: cluster2
+hubWorkInformerResync: 1m
+spokeKubeAPIQPS: 10
+`)
+	if err := reloader.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if *reloader.current.SpokeClusterName != "cluster1" {
+		t.Errorf("expected cluster name kept, but got %q", *reloader.current.SpokeClusterName)
+	}
+	if reloader.current.HubWorkInformerResync.Duration != o.HubWorkInformerResync {
+		t.Errorf("expected hub work informer resync kept, but got %v", reloader.current.HubWorkInformerResync.Duration)
+	}
+	if applyLimits.IsForbidden(schema.GroupResource{Resource: "secrets"}) {
+		t.Errorf("expected the secrets not forbidden once they are removed from the config")
+	}
+	if limiter.QPS() != 10 {
+		t.Errorf("expected qps 10, but got %v", limiter.QPS())
+	}
+
+	// the current config is kept if the config is invalid
+	writeConfig(`spokeKubeAPIQPS: -1`)
+	if err := reloader.reload(); err == nil {
+		t.Errorf("expected error of the invalid config")
+	}
+	if limiter.QPS() != 10 {
+		t.Errorf("expected qps 10 kept, but got %v", limiter.QPS())
+	}
+}
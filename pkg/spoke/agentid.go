@@ -0,0 +1,58 @@
+package spoke
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// agentIDConfigMapName is the name of the configmap in the agent namespace which persists the agent id
+	agentIDConfigMapName = "work-agent-id"
+	agentIDKey           = "agentID"
+)
+
+// loadOrCreateAgentID returns the id of this agent instance. The id is persisted in a configmap in the agent
+// namespace so that it is kept across restarts. A new id is generated if the namespace is unknown.
+func loadOrCreateAgentID(ctx context.Context, kubeClient kubernetes.Interface, namespace string) (string, error) {
+	if len(namespace) == 0 {
+		return string(uuid.NewUUID()), nil
+	}
+
+	cm, err := kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, agentIDConfigMapName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      agentIDConfigMapName,
+			},
+			Data: map[string]string{agentIDKey: string(uuid.NewUUID())},
+		}
+		cm, err = kubeClient.CoreV1().ConfigMaps(namespace).Create(ctx, cm, metav1.CreateOptions{})
+		if err != nil {
+			return "", err
+		}
+	case err != nil:
+		return "", err
+	}
+
+	if agentID := cm.Data[agentIDKey]; len(agentID) > 0 {
+		return agentID, nil
+	}
+
+	cm = cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[agentIDKey] = string(uuid.NewUUID())
+	cm, err = kubeClient.CoreV1().ConfigMaps(namespace).Update(ctx, cm, metav1.UpdateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return cm.Data[agentIDKey], nil
+}
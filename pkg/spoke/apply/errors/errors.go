@@ -0,0 +1,147 @@
+// Package errors provides the typed errors of applying the manifests of the manifestworks to the spoke cluster, so
+// that the callers tell the errors apart with errors.Is and errors.As instead of matching the error messages.
+package errors
+
+import (
+	goerrors "errors"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The types of the ResourceErrors, a ResourceError of a type is matched by errors.Is with the type, e.g.
+// errors.Is(err, ErrResourceConflict).
+var (
+	// ErrResourceConflict means the resource is changed by others since it was read, or it was recreated.
+	ErrResourceConflict = goerrors.New("resource conflict")
+	// ErrMappingNotFound means the kind of the manifest is not served by the spoke cluster, e.g. the CRD of the
+	// manifest is not installed yet.
+	ErrMappingNotFound = goerrors.New("resource mapping not found")
+	// ErrValidationFailed means the manifest is invalid, it will not be applied until the manifestwork is changed.
+	ErrValidationFailed = goerrors.New("manifest validation failed")
+	// ErrNamespaceNotPermitted means the manifest is out of the namespaces which the manifestwork is allowed to apply
+	// to, it will not be applied until the manifestwork is changed.
+	ErrNamespaceNotPermitted = goerrors.New("namespace not permitted")
+	// ErrResourceForbiddenByAgentPolicy means the resource of the manifest is forbidden by the agent, it will not be
+	// applied until the manifestwork or the forbidden resources of the agent are changed.
+	ErrResourceForbiddenByAgentPolicy = goerrors.New("resource forbidden by agent policy")
+	// ErrAPIVersionRemoved means the apiVersion of the manifest is removed by the Kubernetes version of the spoke
+	// cluster, it will not be applied until the manifestwork is changed.
+	ErrAPIVersionRemoved = goerrors.New("api version removed")
+	// ErrManifestDecodeFailed means the manifest cannot be decoded, e.g. it is corrupted on the hub, it will not be
+	// applied until the manifestwork is changed.
+	ErrManifestDecodeFailed = goerrors.New("manifest decode failed")
+	// ErrManifestTransformFailed means a ManifestTransformer of the agent failed to transform the manifest, it is
+	// retried since the transformer may depend on the state of the spoke cluster.
+	ErrManifestTransformFailed = goerrors.New("manifest transform failed")
+)
+
+// resourceErrorReasons are the reasons of the Applied conditions of the manifests failed with the types of the
+// ResourceErrors.
+var resourceErrorReasons = map[error]string{
+	ErrResourceConflict:               "ResourceConflict",
+	ErrMappingNotFound:                "APIVersionNotAvailable",
+	ErrValidationFailed:               "ManifestInvalid",
+	ErrNamespaceNotPermitted:          "NamespaceNotPermittedForExecutor",
+	ErrResourceForbiddenByAgentPolicy: "ResourceForbiddenByAgentPolicy",
+	ErrAPIVersionRemoved:              "APIVersionRemovedInCluster",
+	ErrManifestDecodeFailed:           "ManifestDecodeFailed",
+	ErrManifestTransformFailed:        "ManifestTransformFailed",
+}
+
+// NotAllowedError is returned when applying a manifest is not allowed for now. Unlike retryable errors,
+// the manifestwork is requeued after the requeue time instead of being rate limited.
+type NotAllowedError struct {
+	Err         error
+	RequeueTime time.Duration
+}
+
+func (e *NotAllowedError) Error() string {
+	if e.Err == nil {
+		return "not allowed"
+	}
+	return e.Err.Error()
+}
+
+func (e *NotAllowedError) Unwrap() error {
+	return e.Err
+}
+
+// ResourceError is an error of applying or deleting a resource on the spoke cluster. It tells the type of the error
+// and the resource, so that the callers do not match the error message.
+type ResourceError struct {
+	// Type is one of ErrResourceConflict, ErrMappingNotFound, ErrValidationFailed, ErrNamespaceNotPermitted,
+	// ErrResourceForbiddenByAgentPolicy, ErrAPIVersionRemoved, ErrManifestDecodeFailed and ErrManifestTransformFailed
+	Type error
+	// GVR is the resource, the Resource is empty if the mapping of the kind is not found, and the GVR, Namespace and
+	// Name are all empty if the manifest cannot be decoded
+	GVR       schema.GroupVersionResource
+	Namespace string
+	Name      string
+	// RequeueTime is a hint to retry after the requeue time instead of the rate limited backoff, 0 means no hint
+	RequeueTime time.Duration
+	Err         error
+}
+
+func (e *ResourceError) Error() string {
+	if e.Err == nil {
+		return e.Type.Error()
+	}
+	return e.Err.Error()
+}
+
+func (e *ResourceError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if the target is the type of the error.
+func (e *ResourceError) Is(target error) bool {
+	return target == e.Type
+}
+
+// Reason returns the reason of the Applied condition of the manifest failed with the error.
+func (e *ResourceError) Reason() string {
+	return resourceErrorReasons[e.Type]
+}
+
+// NewResourceConflictError returns a ResourceError of ErrResourceConflict wrapping the error.
+func NewResourceConflictError(gvr schema.GroupVersionResource, namespace, name string, err error) error {
+	return &ResourceError{Type: ErrResourceConflict, GVR: gvr, Namespace: namespace, Name: name, Err: err}
+}
+
+// NewMappingNotFoundError returns a ResourceError of ErrMappingNotFound wrapping the error.
+func NewMappingNotFoundError(gvr schema.GroupVersionResource, namespace, name string, err error) error {
+	return &ResourceError{Type: ErrMappingNotFound, GVR: gvr, Namespace: namespace, Name: name, Err: err}
+}
+
+// NewValidationFailedError returns a ResourceError of ErrValidationFailed wrapping the error.
+func NewValidationFailedError(gvr schema.GroupVersionResource, namespace, name string, err error) error {
+	return &ResourceError{Type: ErrValidationFailed, GVR: gvr, Namespace: namespace, Name: name, Err: err}
+}
+
+// NewNamespaceNotPermittedError returns a ResourceError of ErrNamespaceNotPermitted wrapping the error.
+func NewNamespaceNotPermittedError(gvr schema.GroupVersionResource, namespace, name string, err error) error {
+	return &ResourceError{Type: ErrNamespaceNotPermitted, GVR: gvr, Namespace: namespace, Name: name, Err: err}
+}
+
+// NewResourceForbiddenByAgentPolicyError returns a ResourceError of ErrResourceForbiddenByAgentPolicy wrapping the
+// error.
+func NewResourceForbiddenByAgentPolicyError(gvr schema.GroupVersionResource, namespace, name string, err error) error {
+	return &ResourceError{Type: ErrResourceForbiddenByAgentPolicy, GVR: gvr, Namespace: namespace, Name: name, Err: err}
+}
+
+// NewAPIVersionRemovedError returns a ResourceError of ErrAPIVersionRemoved wrapping the error.
+func NewAPIVersionRemovedError(gvr schema.GroupVersionResource, namespace, name string, err error) error {
+	return &ResourceError{Type: ErrAPIVersionRemoved, GVR: gvr, Namespace: namespace, Name: name, Err: err}
+}
+
+// NewManifestDecodeFailedError returns a ResourceError of ErrManifestDecodeFailed wrapping the error, the resource of
+// the manifest is unknown.
+func NewManifestDecodeFailedError(err error) error {
+	return &ResourceError{Type: ErrManifestDecodeFailed, Err: err}
+}
+
+// NewManifestTransformFailedError returns a ResourceError of ErrManifestTransformFailed wrapping the error.
+func NewManifestTransformFailedError(gvr schema.GroupVersionResource, namespace, name string, err error) error {
+	return &ResourceError{Type: ErrManifestTransformFailed, GVR: gvr, Namespace: namespace, Name: name, Err: err}
+}
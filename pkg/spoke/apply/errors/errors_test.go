@@ -0,0 +1,55 @@
+package errors
+
+import (
+	goerrors "errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestResourceErrorIsAndAs(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	conflictErr := errors.NewConflict(gvr.GroupResource(), "test", fmt.Errorf("the resource was recreated"))
+
+	err := fmt.Errorf("manifest 0: %w", NewResourceConflictError(gvr, "ns1", "test", conflictErr))
+	if !goerrors.Is(err, ErrResourceConflict) || goerrors.Is(err, ErrValidationFailed) {
+		t.Errorf("expected resource conflict error, but got %v", err)
+	}
+	// the wrapped errors are unwrapped
+	if !errors.IsConflict(err) || err.Error() != "manifest 0: "+conflictErr.Error() {
+		t.Errorf("expected conflict error unwrapped, but got %v", err)
+	}
+
+	var resourceErr *ResourceError
+	if !goerrors.As(err, &resourceErr) {
+		t.Fatalf("expected ResourceError, but got %v", err)
+	}
+	if resourceErr.GVR != gvr || resourceErr.Namespace != "ns1" || resourceErr.Name != "test" ||
+		resourceErr.Reason() != "ResourceConflict" {
+		t.Errorf("unexpected error %#v with reason %q", resourceErr, resourceErr.Reason())
+	}
+
+	// the type is the message of the error without a wrapped error
+	if err := (&ResourceError{Type: ErrMappingNotFound}); err.Error() != ErrMappingNotFound.Error() {
+		t.Errorf("expected message %q, but got %q", ErrMappingNotFound.Error(), err.Error())
+	}
+}
+
+func TestNotAllowedError(t *testing.T) {
+	cause := fmt.Errorf("waiting for manifest 0")
+	err := fmt.Errorf("manifest 1: %w", &NotAllowedError{Err: cause, RequeueTime: time.Second})
+
+	var notAllowedErr *NotAllowedError
+	if !goerrors.As(err, &notAllowedErr) || notAllowedErr.RequeueTime != time.Second {
+		t.Fatalf("expected NotAllowedError, but got %v", err)
+	}
+	if !goerrors.Is(err, cause) {
+		t.Errorf("expected the cause unwrapped, but got %v", err)
+	}
+	if message := (&NotAllowedError{}).Error(); message != "not allowed" {
+		t.Errorf("expected message %q, but got %q", "not allowed", message)
+	}
+}
@@ -0,0 +1,199 @@
+// Package config defines the configuration of the work agent in a file, e.g. a mounted ConfigMap. The fields set in
+// the file supersede the flags of the agent, and the reloadable fields take effect once the file is changed without
+// restarting the agent.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// AgentConfiguration is the configuration of the work agent. A nil field is not configured in the file, the value of
+// the flag is used instead, see SetDefaults.
+type AgentConfiguration struct {
+	// The fields below are immutable, a change takes effect only once the agent is restarted.
+
+	// HubKubeconfigFile is the location of the kubeconfig file to connect to the hub, see --hub-kubeconfig.
+	HubKubeconfigFile *string `json:"hubKubeconfigFile,omitempty"`
+	// SpokeClusterName is the name of the managed cluster, see --spoke-cluster-name.
+	SpokeClusterName *string `json:"spokeClusterName,omitempty"`
+	// HubWorkInformerResync, SpokeAppliedWorkInformerResync and SpokeKubeInformerResync are the resync periods of the
+	// informers, see --hub-work-informer-resync, --spoke-appliedwork-informer-resync and --spoke-kube-informer-resync.
+	// The informers are started with the periods once, so they are not reloadable.
+	HubWorkInformerResync          *metav1.Duration `json:"hubWorkInformerResync,omitempty"`
+	SpokeAppliedWorkInformerResync *metav1.Duration `json:"spokeAppliedWorkInformerResync,omitempty"`
+	SpokeKubeInformerResync        *metav1.Duration `json:"spokeKubeInformerResync,omitempty"`
+
+	// The fields below are reloadable, a change takes effect once the file is reloaded.
+
+	// Verbosity is the log level verbosity, see -v.
+	Verbosity *int32 `json:"verbosity,omitempty"`
+	// SpokeKubeAPIQPS and SpokeKubeAPIBurst are the rate limits of the requests to the apiserver of the managed
+	// cluster, see --spoke-kube-api-qps and --spoke-kube-api-burst.
+	SpokeKubeAPIQPS   *float32 `json:"spokeKubeAPIQPS,omitempty"`
+	SpokeKubeAPIBurst *int     `json:"spokeKubeAPIBurst,omitempty"`
+	// ManifestApplyTimeout is the timeout of applying a manifest, see --manifest-apply-timeout.
+	ManifestApplyTimeout *metav1.Duration `json:"manifestApplyTimeout,omitempty"`
+	// MaxAppliedResources is the max number of resources applied by the agent, see --max-applied-resources.
+	MaxAppliedResources *int `json:"maxAppliedResources,omitempty"`
+	// MaxManifests is the max number of manifests of a manifestwork, see --max-manifests.
+	MaxManifests *int `json:"maxManifests,omitempty"`
+	// HubProbeInterval is the interval of the requests probing the hubs, see --hub-probe-interval.
+	HubProbeInterval *metav1.Duration `json:"hubProbeInterval,omitempty"`
+	// ForbiddenResources are the resources the manifests are not allowed to apply, see --forbidden-resources.
+	ForbiddenResources []string `json:"forbiddenResources,omitempty"`
+}
+
+// immutableFields are the json names of the fields which require a restart of the agent once they are changed.
+var immutableFields = []string{"hubKubeconfigFile", "spokeClusterName", "hubWorkInformerResync",
+	"spokeAppliedWorkInformerResync", "spokeKubeInformerResync"}
+
+// Load reads the configuration in yaml or json from the file. Unknown fields are rejected, so that a typo is not
+// ignored silently.
+func Load(path string) (*AgentConfiguration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// Decode decodes the configuration in yaml or json, an empty configuration is returned if the data is empty.
+func Decode(data []byte) (*AgentConfiguration, error) {
+	jsonData, err := yaml.ToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &AgentConfiguration{}
+	if jsonData = bytes.TrimSpace(jsonData); len(jsonData) == 0 || string(jsonData) == "null" {
+		return config, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// SetDefaults sets the fields not configured to the values of the defaults, which are usually the values of the
+// flags, so that a field removed from the file is reverted to the value of the flag.
+func SetDefaults(config, defaults *AgentConfiguration) {
+	configValue := reflect.ValueOf(config).Elem()
+	defaultsValue := reflect.ValueOf(defaults).Elem()
+	for i := 0; i < configValue.NumField(); i++ {
+		if configValue.Field(i).IsNil() && !defaultsValue.Field(i).IsNil() {
+			configValue.Field(i).Set(defaultsValue.Field(i))
+		}
+	}
+}
+
+// Validate validates the configuration.
+func Validate(config *AgentConfiguration) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if config.SpokeClusterName != nil {
+		for _, msg := range validation.IsDNS1123Label(*config.SpokeClusterName) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spokeClusterName"), *config.SpokeClusterName, msg))
+		}
+	}
+	if config.HubKubeconfigFile != nil && len(*config.HubKubeconfigFile) == 0 {
+		allErrs = append(allErrs, field.Required(field.NewPath("hubKubeconfigFile"), ""))
+	}
+	if config.Verbosity != nil && *config.Verbosity < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("verbosity"), *config.Verbosity, "must not be negative"))
+	}
+	if config.SpokeKubeAPIQPS != nil && *config.SpokeKubeAPIQPS <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spokeKubeAPIQPS"), *config.SpokeKubeAPIQPS, "must be positive"))
+	}
+	if config.SpokeKubeAPIBurst != nil && *config.SpokeKubeAPIBurst <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spokeKubeAPIBurst"), *config.SpokeKubeAPIBurst, "must be positive"))
+	}
+	if config.ManifestApplyTimeout != nil && config.ManifestApplyTimeout.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("manifestApplyTimeout"), config.ManifestApplyTimeout.Duration.String(),
+			"must not be negative"))
+	}
+	if config.MaxAppliedResources != nil && *config.MaxAppliedResources < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("maxAppliedResources"), *config.MaxAppliedResources,
+			"must not be negative"))
+	}
+	if config.MaxManifests != nil && *config.MaxManifests < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("maxManifests"), *config.MaxManifests, "must not be negative"))
+	}
+	for _, interval := range []struct {
+		name     string
+		duration *metav1.Duration
+	}{
+		{name: "hubProbeInterval", duration: config.HubProbeInterval},
+		{name: "hubWorkInformerResync", duration: config.HubWorkInformerResync},
+		{name: "spokeAppliedWorkInformerResync", duration: config.SpokeAppliedWorkInformerResync},
+		{name: "spokeKubeInformerResync", duration: config.SpokeKubeInformerResync},
+	} {
+		if interval.duration != nil && interval.duration.Duration < 0 {
+			allErrs = append(allErrs, field.Invalid(field.NewPath(interval.name), interval.duration.Duration.String(),
+				"must not be negative"))
+		}
+	}
+	for i, resource := range config.ForbiddenResources {
+		if _, err := ParseGroupResource(resource); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("forbiddenResources").Index(i), resource, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+// ParseGroupResource parses a resource in the format of resource.group, e.g. deployments.apps, or resource of the
+// core group, e.g. secrets.
+func ParseGroupResource(value string) (schema.GroupResource, error) {
+	groupResource := schema.ParseGroupResource(value)
+	if len(groupResource.Resource) == 0 {
+		return groupResource, fmt.Errorf("the resource of %q is empty, it must be in the format of resource.group", value)
+	}
+	return groupResource, nil
+}
+
+// RestartRequired returns the json names of the immutable fields changed from the old configuration to the new one,
+// which take effect only once the agent is restarted.
+func RestartRequired(old, new *AgentConfiguration) []string {
+	var changed []string
+	oldValue := reflect.ValueOf(old).Elem()
+	newValue := reflect.ValueOf(new).Elem()
+	for _, name := range immutableFields {
+		index := fieldIndexOf(name)
+		if !reflect.DeepEqual(oldValue.Field(index).Interface(), newValue.Field(index).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// KeepImmutableFields sets the immutable fields of the configuration to the values of the current configuration,
+// so that they are kept in effect until the agent is restarted.
+func KeepImmutableFields(config, current *AgentConfiguration) {
+	configValue := reflect.ValueOf(config).Elem()
+	currentValue := reflect.ValueOf(current).Elem()
+	for _, name := range immutableFields {
+		index := fieldIndexOf(name)
+		configValue.Field(index).Set(currentValue.Field(index))
+	}
+}
+
+// fieldIndexOf returns the index of the field of AgentConfiguration with the json name.
+func fieldIndexOf(name string) int {
+	configType := reflect.TypeOf(AgentConfiguration{})
+	for i := 0; i < configType.NumField(); i++ {
+		if tag := configType.Field(i).Tag.Get("json"); tag == name || tag == name+",omitempty" {
+			return i
+		}
+	}
+	panic(fmt.Sprintf("unknown field %s of AgentConfiguration", name))
+}
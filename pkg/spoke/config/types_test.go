@@ -0,0 +1,231 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newConfiguration() *AgentConfiguration {
+	hubKubeconfigFile, spokeClusterName := "/spoke/hub-kubeconfig/kubeconfig", "cluster1"
+	verbosity, qps, burst, maxAppliedResources, maxManifests := int32(4), float32(20), 40, 1000, 50
+	return &AgentConfiguration{
+		HubKubeconfigFile:              &hubKubeconfigFile,
+		SpokeClusterName:               &spokeClusterName,
+		HubWorkInformerResync:          &metav1.Duration{Duration: 5 * time.Minute},
+		SpokeAppliedWorkInformerResync: &metav1.Duration{Duration: 5 * time.Minute},
+		SpokeKubeInformerResync:        &metav1.Duration{Duration: 5 * time.Minute},
+		Verbosity:                      &verbosity,
+		SpokeKubeAPIQPS:                &qps,
+		SpokeKubeAPIBurst:              &burst,
+		ManifestApplyTimeout:           &metav1.Duration{Duration: 30 * time.Second},
+		MaxAppliedResources:            &maxAppliedResources,
+		MaxManifests:                   &maxManifests,
+		HubProbeInterval:               &metav1.Duration{Duration: time.Minute},
+		ForbiddenResources:             []string{"secrets", "clusterrolebindings.rbac.authorization.k8s.io"},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	cases := []struct {
+		name   string
+		config *AgentConfiguration
+	}{
+		{
+			name:   "empty",
+			config: &AgentConfiguration{},
+		},
+		{
+			name:   "all fields",
+			config: newConfiguration(),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, err := json.Marshal(c.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, c.config) {
+				t.Errorf("expected %s, but got %+v", data, actual)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		name        string
+		data        string
+		expected    func() *AgentConfiguration
+		expectedErr bool
+	}{
+		{
+			name:     "empty file",
+			data:     "",
+			expected: func() *AgentConfiguration { return &AgentConfiguration{} },
+		},
+		{
+			name: "yaml",
+			data: `
+spokeClusterName: cluster1
+spokeKubeAPIQPS: 20
+manifestApplyTimeout: 30s
+`,
+			expected: func() *AgentConfiguration {
+				expected := newConfiguration()
+				return &AgentConfiguration{
+					SpokeClusterName:     expected.SpokeClusterName,
+					SpokeKubeAPIQPS:      expected.SpokeKubeAPIQPS,
+					ManifestApplyTimeout: expected.ManifestApplyTimeout,
+				}
+			},
+		},
+		{
+			name:        "unknown field",
+			data:        "spokeClusterNmae: cluster1",
+			expectedErr: true,
+		},
+		{
+			name:        "invalid duration",
+			data:        "hubProbeInterval: soon",
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := Decode([]byte(c.data))
+			if c.expectedErr {
+				if err == nil {
+					t.Errorf("expected error, but got %+v", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := c.expected(); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("expected %+v, but got %+v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestSetDefaults(t *testing.T) {
+	defaults := newConfiguration()
+	maxManifests := 10
+	cfg := &AgentConfiguration{MaxManifests: &maxManifests}
+
+	SetDefaults(cfg, defaults)
+	if *cfg.MaxManifests != 10 {
+		t.Errorf("expected the configured max manifests kept, but got %d", *cfg.MaxManifests)
+	}
+	expected := newConfiguration()
+	expected.MaxManifests = &maxManifests
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, cfg)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name           string
+		mutate         func(c *AgentConfiguration)
+		expectedFields []string
+	}{
+		{
+			name:   "valid",
+			mutate: func(c *AgentConfiguration) {},
+		},
+		{
+			name:   "empty",
+			mutate: func(c *AgentConfiguration) { *c = AgentConfiguration{} },
+		},
+		{
+			name: "invalid fields",
+			mutate: func(c *AgentConfiguration) {
+				clusterName, hubKubeconfigFile, verbosity, qps, burst := "Cluster_1", "", int32(-1), float32(0), -1
+				c.SpokeClusterName, c.HubKubeconfigFile, c.Verbosity = &clusterName, &hubKubeconfigFile, &verbosity
+				c.SpokeKubeAPIQPS, c.SpokeKubeAPIBurst = &qps, &burst
+				c.ManifestApplyTimeout = &metav1.Duration{Duration: -time.Second}
+				c.HubProbeInterval = &metav1.Duration{Duration: -time.Second}
+			},
+			expectedFields: []string{"spokeClusterName", "hubKubeconfigFile", "verbosity", "spokeKubeAPIQPS", "spokeKubeAPIBurst",
+				"manifestApplyTimeout", "hubProbeInterval"},
+		},
+		{
+			name: "negative limits and resyncs",
+			mutate: func(c *AgentConfiguration) {
+				maxAppliedResources, maxManifests := -1, -1
+				c.MaxAppliedResources, c.MaxManifests = &maxAppliedResources, &maxManifests
+				c.SpokeKubeInformerResync = &metav1.Duration{Duration: -time.Minute}
+			},
+			expectedFields: []string{"maxAppliedResources", "maxManifests", "spokeKubeInformerResync"},
+		},
+		{
+			name: "invalid forbidden resources",
+			mutate: func(c *AgentConfiguration) {
+				c.ForbiddenResources = []string{"secrets", ".apps"}
+			},
+			expectedFields: []string{"forbiddenResources[1]"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := newConfiguration()
+			c.mutate(cfg)
+			fields := []string{}
+			for _, err := range Validate(cfg) {
+				if len(fields) == 0 || fields[len(fields)-1] != err.Field {
+					fields = append(fields, err.Field)
+				}
+			}
+			if len(fields) != len(c.expectedFields) || (len(fields) > 0 && !reflect.DeepEqual(fields, c.expectedFields)) {
+				t.Errorf("expected invalid fields %v, but got %v", c.expectedFields, fields)
+			}
+		})
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := newConfiguration()
+
+	reloaded := newConfiguration()
+	qps := float32(100)
+	reloaded.SpokeKubeAPIQPS = &qps
+	reloaded.HubProbeInterval = &metav1.Duration{Duration: 2 * time.Minute}
+	if changed := RestartRequired(old, reloaded); len(changed) != 0 {
+		t.Errorf("expected no restart required, but got %v", changed)
+	}
+
+	clusterName := "cluster2"
+	reloaded.SpokeClusterName = &clusterName
+	if changed := RestartRequired(old, reloaded); !reflect.DeepEqual(changed, []string{"spokeClusterName"}) {
+		t.Errorf("expected restart required by spokeClusterName, but got %v", changed)
+	}
+
+	reloaded = newConfiguration()
+	reloaded.ForbiddenResources = nil
+	reloaded.HubWorkInformerResync = &metav1.Duration{Duration: time.Minute}
+	if changed := RestartRequired(old, reloaded); !reflect.DeepEqual(changed, []string{"hubWorkInformerResync"}) {
+		t.Errorf("expected restart required by hubWorkInformerResync, but got %v", changed)
+	}
+
+	KeepImmutableFields(reloaded, old)
+	if changed := RestartRequired(old, reloaded); len(changed) != 0 {
+		t.Errorf("expected the immutable fields kept, but got %v", changed)
+	}
+	if reloaded.ForbiddenResources != nil {
+		t.Errorf("expected the reloadable fields not kept, but got %v", reloaded.ForbiddenResources)
+	}
+}
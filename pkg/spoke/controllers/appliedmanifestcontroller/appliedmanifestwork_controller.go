@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -23,7 +24,9 @@ import (
 	workinformer "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
 	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
 	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
 	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/controllers"
 )
 
 // AppliedManifestWorkController is to sync the applied resources of appliedmanifestwork with related
@@ -36,6 +39,7 @@ type AppliedManifestWorkController struct {
 	spokeDynamicClient        dynamic.Interface
 	hubHash                   string
 	rateLimiter               workqueue.RateLimiter
+	pruneThreshold            PruneThreshold
 }
 
 // NewAppliedManifestWorkController returns a AppliedManifestWorkController
@@ -47,7 +51,8 @@ func NewAppliedManifestWorkController(
 	manifestWorkLister worklister.ManifestWorkNamespaceLister,
 	appliedManifestWorkClient workv1client.AppliedManifestWorkInterface,
 	appliedManifestWorkInformer workinformer.AppliedManifestWorkInformer,
-	hubHash string) factory.Controller {
+	hubHash string,
+	pruneThreshold PruneThreshold) factory.Controller {
 
 	controller := &AppliedManifestWorkController{
 		manifestWorkClient:        manifestWorkClient,
@@ -57,6 +62,7 @@ func NewAppliedManifestWorkController(
 		spokeDynamicClient:        spokeDynamicClient,
 		hubHash:                   hubHash,
 		rateLimiter:               workqueue.NewItemExponentialFailureRateLimiter(5*time.Millisecond, 1000*time.Second),
+		pruneThreshold:            pruneThreshold,
 	}
 
 	return factory.New().
@@ -65,11 +71,15 @@ func NewAppliedManifestWorkController(
 			return accessor.GetName()
 		}, manifestWorkInformer.Informer()).
 		WithInformersQueueKeyFunc(helper.AppliedManifestworkQueueKeyFunc(hubHash), appliedManifestWorkInformer.Informer()).
-		WithSync(controller.sync).ToController("AppliedManifestWorkController", recorder)
+		WithSync(controllers.InstrumentSync(hubHash, "AppliedManifestWorkController", controller.sync)).ToController("AppliedManifestWorkController", recorder)
 }
 
 func (m *AppliedManifestWorkController) sync(ctx context.Context, controllerContext factory.SyncContext) error {
 	manifestWorkName := controllerContext.QueueKey()
+	if name, ok := helper.MalformedAppliedManifestWorkName(manifestWorkName); ok {
+		klog.Warningf("Ignore appliedmanifestwork %q, its name is not the hub hash and a manifestwork name joined with \"-\"", name)
+		return nil
+	}
 	klog.V(4).Infof("Reconciling ManifestWork %q", manifestWorkName)
 
 	manifestWork, err := m.manifestWorkLister.Get(manifestWorkName)
@@ -80,8 +90,8 @@ func (m *AppliedManifestWorkController) sync(ctx context.Context, controllerCont
 	if err != nil {
 		return err
 	}
-	// no work to do if we're deleted
-	if !manifestWork.DeletionTimestamp.IsZero() {
+	// no work to do if we're deleted or paused
+	if !manifestWork.DeletionTimestamp.IsZero() || helper.IsWorkPaused(manifestWork) {
 		return nil
 	}
 
@@ -115,12 +125,27 @@ func (m *AppliedManifestWorkController) syncManifestWork(
 	// spec because manifests in spec are only resource templates, while resource status records the real resources
 	// maintained by the manifest work.
 	var appliedResources []workapiv1.AppliedManifestResourceMeta
+	// contentIndex records the content hash of the latest applied resources, it is used to detect the
+	// renamed resources.
+	contentIndex := map[string]workapiv1.AppliedManifestResourceMeta{}
+	// the resources patched by the manifestwork are not owned by it, so they are neither tracked nor pruned. The
+	// invalid config options are ignored, since nothing is patched with them.
+	patches, _ := helper.ManifestPatches(manifestWork)
+	patched := map[helper.ManifestResourceIdentifier]bool{}
+	for _, patch := range patches {
+		identifier := patch.ResourceIdentifier()
+		identifier.Version = ""
+		patched[identifier] = true
+	}
 	var errs []error
 	for _, resourceStatus := range manifestWork.Status.ResourceStatus.Manifests {
 		gvr := schema.GroupVersionResource{Group: resourceStatus.ResourceMeta.Group, Version: resourceStatus.ResourceMeta.Version, Resource: resourceStatus.ResourceMeta.Resource}
 		if len(gvr.Resource) == 0 || len(gvr.Version) == 0 || len(resourceStatus.ResourceMeta.Name) == 0 {
 			continue
 		}
+		if _, ok := patches[resourceStatus.ResourceMeta.Ordinal]; ok {
+			continue
+		}
 
 		u, err := m.spokeDynamicClient.
 			Resource(gvr).
@@ -140,28 +165,112 @@ func (m *AppliedManifestWorkController) syncManifestWork(
 			continue
 		}
 
-		appliedResources = append(appliedResources, workapiv1.AppliedManifestResourceMeta{
+		appliedResource := workapiv1.AppliedManifestResourceMeta{
 			Group:     resourceStatus.ResourceMeta.Group,
 			Version:   resourceStatus.ResourceMeta.Version,
 			Resource:  resourceStatus.ResourceMeta.Resource,
 			Namespace: resourceStatus.ResourceMeta.Namespace,
 			Name:      resourceStatus.ResourceMeta.Name,
 			UID:       string(u.GetUID()),
-		})
+		}
+		appliedResources = append(appliedResources, appliedResource)
+
+		if hash, err := helper.ResourceContentHash(u); err == nil {
+			contentIndex[contentKey(appliedResource.Group, appliedResource.Resource, hash)] = appliedResource
+		}
 	}
 	if len(errs) != 0 {
 		return utilerrors.NewAggregate(errs)
 	}
 
 	owner := helper.NewAppliedManifestWorkOwner(appliedManifestWork)
+	recorder := helper.NewWorkEventRecorder(controllerContext.Recorder(), m.hubHash, manifestWork.Name)
 
 	// delete applied resources which are no longer maintained by manifest work
 	noLongerMaintainedResources := findUntrackedResources(appliedManifestWork.Status.AppliedResources, appliedResources)
+	// the patched resources tracked before are dropped without being deleted
+	noLongerMaintainedResources = excludePatchedResources(noLongerMaintainedResources, patched)
+
+	// pause the prune exceeding the prune threshold until it is confirmed, the resources are kept tracked meanwhile.
+	pruning, applied := len(noLongerMaintainedResources), len(appliedManifestWork.Status.AppliedResources)
+	prunePaused := m.pruneThreshold.exceeded(pruning, applied) && !pruneConfirmed(manifestWork, pruning)
+	if err := m.updatePruneConfirmationCondition(ctx, manifestWork, prunePaused, pruning, applied); err != nil {
+		return err
+	}
+	if prunePaused {
+		klog.Warningf("Pause pruning %d of %d applied resources of manifestwork %q, it exceeds the prune threshold",
+			pruning, applied, manifestWork.Name)
+		appliedResources = append(appliedResources, noLongerMaintainedResources...)
+		noLongerMaintainedResources = nil
+	}
 
 	reason := fmt.Sprintf("it is no longer maintained by manifestwork %s", manifestWork.Name)
 
-	resourcesPendingFinalization, errs := helper.DeleteAppliedResources(
-		noLongerMaintainedResources, reason, m.spokeDynamicClient, controllerContext.Recorder(), *owner)
+	deleteOption := helper.EffectiveDeleteOption(manifestWork, appliedManifestWork)
+	var selectorRules []helper.OrphaningSelectorRule
+	if deleteOption != nil && deleteOption.PropagationPolicy == workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan {
+		rules, err := helper.OrphaningSelectorRules(manifestWork)
+		if err != nil {
+			return err
+		}
+		selectorRules = rules
+	}
+
+	var resourcesPendingFinalization, resourcesBlocked []workapiv1.AppliedManifestResourceMeta
+	for _, resource := range noLongerMaintainedResources {
+		// orphan the resource instead of deleting it if it matches the orphaning rules of the manifestwork
+		if ruleIndex, orphaned := helper.OrphaningRuleIndex(resource.Group, resource.Resource, resource.Namespace, resource.Name,
+			deleteOption); orphaned {
+			if err := helper.OrphanAppliedResource(resource, constants.EventReasonResourceOrphanedOnRemoval, helper.OrphaningReason(manifestWork.Name, ruleIndex),
+				m.spokeDynamicClient, recorder, *owner); err != nil {
+				errs = append(errs, err)
+				resourcesPendingFinalization = append(resourcesPendingFinalization, resource)
+			}
+			continue
+		}
+		// the orphaning rules with selectors are evaluated against the live resource
+		orphaned, err := helper.OrphanAppliedResourceBySelectors(resource, constants.EventReasonResourceOrphanedOnRemoval, manifestWork.Name, selectorRules,
+			m.spokeDynamicClient, recorder, *owner)
+		if err != nil {
+			errs = append(errs, err)
+			resourcesPendingFinalization = append(resourcesPendingFinalization, resource)
+			continue
+		}
+		if orphaned {
+			continue
+		}
+
+		// keep the renamed resource until the resource it is renamed to is adopted by the appliedmanifestwork, so
+		// that the content is never left without a resource holding it.
+		if renamedTo, ok := m.findRenamedResource(ctx, resource, contentIndex); ok &&
+			!isTracked(appliedManifestWork.Status.AppliedResources, renamedTo) {
+			recorder.Eventf("ResourceRenamed",
+				"Resource %s/%s with key %s/%s is renamed to %s/%s by manifestwork %s, it is kept until %s/%s is adopted",
+				resource.Group, resource.Resource, resource.Namespace, resource.Name,
+				renamedTo.Namespace, renamedTo.Name, manifestWork.Name, renamedTo.Namespace, renamedTo.Name)
+			resourcesPendingFinalization = append(resourcesPendingFinalization, resource)
+			continue
+		}
+
+		pending, err := helper.DeleteAppliedResource(resource, reason, m.spokeDynamicClient, recorder, *owner)
+		switch {
+		case errors.IsForbidden(err):
+			// keep tracking the resource, it will be pruned once the agent is allowed to delete it.
+			resourcesBlocked = append(resourcesBlocked, resource)
+			resourcesPendingFinalization = append(resourcesPendingFinalization, resource)
+		case err != nil:
+			errs = append(errs, err)
+			resourcesPendingFinalization = append(resourcesPendingFinalization, resource)
+		case pending:
+			resourcesPendingFinalization = append(resourcesPendingFinalization, resource)
+		}
+	}
+
+	if !prunePaused {
+		if err := m.updateObsoleteResourcesCondition(ctx, manifestWork, resourcesBlocked); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	if len(errs) != 0 {
 		return utilerrors.NewAggregate(errs)
 	}
@@ -186,7 +295,7 @@ func (m *AppliedManifestWorkController) syncManifestWork(
 
 	willSkipStatusUpdate := reflect.DeepEqual(appliedManifestWork.Status.AppliedResources, appliedResources)
 	if willSkipStatusUpdate {
-		// requeue the work if there exists any resource pending for finalization
+		// requeue the work if there exists any resource pending for finalization or blocked
 		if len(resourcesPendingFinalization) != 0 {
 			controllerContext.Queue().AddAfter(manifestWork.Name, m.rateLimiter.When(manifestWork.Name))
 		}
@@ -205,6 +314,83 @@ func (m *AppliedManifestWorkController) syncManifestWork(
 	return err
 }
 
+// findRenamedResource returns the latest applied resource which has the same group/resource and the same
+// content with the given untracked resource. It indicates that the resource is only renamed in the manifestwork.
+func (m *AppliedManifestWorkController) findRenamedResource(
+	ctx context.Context,
+	resource workapiv1.AppliedManifestResourceMeta,
+	contentIndex map[string]workapiv1.AppliedManifestResourceMeta) (workapiv1.AppliedManifestResourceMeta, bool) {
+	if len(contentIndex) == 0 {
+		return workapiv1.AppliedManifestResourceMeta{}, false
+	}
+
+	gvr := schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Resource}
+	u, err := m.spokeDynamicClient.Resource(gvr).Namespace(resource.Namespace).Get(ctx, resource.Name, metav1.GetOptions{})
+	if err != nil {
+		return workapiv1.AppliedManifestResourceMeta{}, false
+	}
+
+	// the resource was re-created, it is not the one renamed
+	if string(u.GetUID()) != resource.UID {
+		return workapiv1.AppliedManifestResourceMeta{}, false
+	}
+
+	hash, err := helper.ResourceContentHash(u)
+	if err != nil {
+		return workapiv1.AppliedManifestResourceMeta{}, false
+	}
+
+	renamedTo, ok := contentIndex[contentKey(resource.Group, resource.Resource, hash)]
+	return renamedTo, ok
+}
+
+// updateObsoleteResourcesCondition sets the ObsoleteResourcesPending condition on the manifestwork if there are
+// resources that cannot be pruned, and removes the condition once all of them are pruned.
+func (m *AppliedManifestWorkController) updateObsoleteResourcesCondition(
+	ctx context.Context, manifestWork *workapiv1.ManifestWork, blocked []workapiv1.AppliedManifestResourceMeta) error {
+	if len(blocked) == 0 && meta.FindStatusCondition(manifestWork.Status.Conditions, constants.WorkObsoleteResourcesPending) == nil {
+		return nil
+	}
+
+	_, _, err := helper.UpdateManifestWorkStatus(ctx, m.manifestWorkClient, manifestWork.DeepCopy(),
+		func(status *workapiv1.ManifestWorkStatus) error {
+			if len(blocked) == 0 {
+				meta.RemoveStatusCondition(&status.Conditions, constants.WorkObsoleteResourcesPending)
+				return nil
+			}
+
+			var keys []string
+			for _, resource := range blocked {
+				keys = append(keys, fmt.Sprintf("%s/%s %s/%s", resource.Group, resource.Resource, resource.Namespace, resource.Name))
+			}
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               constants.WorkObsoleteResourcesPending,
+				Status:             metav1.ConditionTrue,
+				Reason:             "PruneForbidden",
+				ObservedGeneration: manifestWork.Generation,
+				Message:            fmt.Sprintf("Failed to prune resources no longer in spec: %s", strings.Join(keys, ", ")),
+			})
+			return nil
+		})
+	return err
+}
+
+// isTracked returns true if the resource with the same UID is recorded in the applied resources.
+func isTracked(appliedResources []workapiv1.AppliedManifestResourceMeta, resource workapiv1.AppliedManifestResourceMeta) bool {
+	for _, appliedResource := range appliedResources {
+		if appliedResource.Group == resource.Group && appliedResource.Resource == resource.Resource &&
+			appliedResource.Namespace == resource.Namespace && appliedResource.Name == resource.Name &&
+			appliedResource.UID == resource.UID {
+			return true
+		}
+	}
+	return false
+}
+
+func contentKey(group, resource, hash string) string {
+	return fmt.Sprintf("%s/%s/%s", group, resource, hash)
+}
+
 // findUntrackedResources returns applied resources which are no longer tracked by manifestwork
 // API version should be ignored when checking if a resource is no longer tracked by a manifestwork.
 // This is because we treat resources of same GroupResource but different version equivalent.
@@ -213,6 +399,25 @@ func (m *AppliedManifestWorkController) syncManifestWork(
 // 2. The UID in the newAppliedResources is always the latest updated one. The only possibility that UID
 // in appliedResources differs from what in newAppliedResources is that this resource is recreated.
 // Its UID in appliedResources is invalid hence recording it as untracked applied resource and delete it is safe.
+// excludePatchedResources returns the resources which are not patched by the manifestwork.
+func excludePatchedResources(
+	resources []workapiv1.AppliedManifestResourceMeta,
+	patched map[helper.ManifestResourceIdentifier]bool) []workapiv1.AppliedManifestResourceMeta {
+	var owned []workapiv1.AppliedManifestResourceMeta
+	for _, resource := range resources {
+		identifier := helper.ManifestResourceIdentifier{
+			Group:     resource.Group,
+			Resource:  resource.Resource,
+			Namespace: resource.Namespace,
+			Name:      resource.Name,
+		}
+		if !patched[identifier] {
+			owned = append(owned, resource)
+		}
+	}
+	return owned
+}
+
 func findUntrackedResources(appliedResources, newAppliedResources []workapiv1.AppliedManifestResourceMeta) []workapiv1.AppliedManifestResourceMeta {
 	var untracked []workapiv1.AppliedManifestResourceMeta
 
@@ -2,11 +2,18 @@ package appliedmanifestcontroller
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -17,6 +24,7 @@ import (
 	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
 	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
 	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
 	"open-cluster-management.io/work/pkg/helper"
 	"open-cluster-management.io/work/pkg/spoke/spoketesting"
 )
@@ -33,6 +41,11 @@ func newManifest(group, version, resource, namespace, name string) workapiv1.Man
 	}
 }
 
+func withData(u *unstructured.Unstructured, data string) *unstructured.Unstructured {
+	u.Object["data"] = map[string]interface{}{"key": data}
+	return u
+}
+
 func TestSyncManifestWork(t *testing.T) {
 	uid := types.UID("test")
 	appliedWork := spoketesting.NewAppliedManifestWork("test", 0, uid)
@@ -43,8 +56,14 @@ func TestSyncManifestWork(t *testing.T) {
 		existingResources                  []runtime.Object
 		appliedResources                   []workapiv1.AppliedManifestResourceMeta
 		manifests                          []workapiv1.ManifestCondition
+		deleteOption                       *workapiv1.DeleteOption
+		forbidDelete                       bool
+		pruneThreshold                     PruneThreshold
+		annotations                        map[string]string
+		configOptions                      string
 		validateAppliedManifestWorkActions func(t *testing.T, actions []clienttesting.Action)
 		expectedDeleteActions              []clienttesting.DeleteActionImpl
+		expectedOrphanedOnRemoval          int
 		expectedQueueLen                   int
 	}{
 		{
@@ -70,8 +89,9 @@ func TestSyncManifestWork(t *testing.T) {
 				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
 				spoketesting.NewUnstructuredSecret("ns3", "n3", false, "ns3-n3", *owner),
 				spoketesting.NewUnstructuredSecret("ns4", "n4", false, "ns4-n4", *owner),
-				spoketesting.NewUnstructuredSecret("ns5", "n5", false, "ns5-n5", *owner),
-				spoketesting.NewUnstructuredSecret("ns6", "n6", false, "ns6-n6", *owner),
+				// the new resources have a content different from the untracked ones, so they are not renamed
+				withData(spoketesting.NewUnstructuredSecret("ns5", "n5", false, "ns5-n5", *owner), "n5"),
+				withData(spoketesting.NewUnstructuredSecret("ns6", "n6", false, "ns6-n6", *owner), "n6"),
 			},
 			appliedResources: []workapiv1.AppliedManifestResourceMeta{
 				{Group: "", Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
@@ -187,6 +207,324 @@ func TestSyncManifestWork(t *testing.T) {
 			},
 			expectedDeleteActions: []clienttesting.DeleteActionImpl{},
 		},
+		{
+			name: "orphan untracked resources matching orphaning rules",
+			existingResources: []runtime.Object{
+				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1", *owner),
+				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
+			},
+			appliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+			},
+			manifests: []workapiv1.ManifestCondition{newManifest("", "v1", "secrets", "ns1", "n1")},
+			deleteOption: &workapiv1.DeleteOption{
+				PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+				SelectivelyOrphan: &workapiv1.SelectivelyOrphan{
+					OrphaningRules: []workapiv1.OrphaningRule{{Resource: "secrets", Namespace: "ns2", Name: "n2"}},
+				},
+			},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.AppliedManifestWork)
+				if !reflect.DeepEqual(work.Status.AppliedResources, []workapiv1.AppliedManifestResourceMeta{
+					{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				}) {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+			expectedDeleteActions:     []clienttesting.DeleteActionImpl{},
+			expectedOrphanedOnRemoval: 1,
+		},
+		{
+			name: "orphan untracked resources with the Orphan policy",
+			existingResources: []runtime.Object{
+				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1", *owner),
+				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
+				spoketesting.NewUnstructuredSecret("ns3", "n3", false, "ns3-n3", *owner),
+			},
+			appliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns3", Name: "n3", UID: "ns3-n3"},
+			},
+			manifests: []workapiv1.ManifestCondition{newManifest("", "v1", "secrets", "ns1", "n1")},
+			deleteOption: &workapiv1.DeleteOption{
+				PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan,
+			},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.AppliedManifestWork)
+				if !reflect.DeepEqual(work.Status.AppliedResources, []workapiv1.AppliedManifestResourceMeta{
+					{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				}) {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+			expectedDeleteActions:     []clienttesting.DeleteActionImpl{},
+			expectedOrphanedOnRemoval: 2,
+		},
+		{
+			name: "keep the resource of a manifest failed to decode",
+			existingResources: []runtime.Object{
+				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1", *owner),
+				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
+			},
+			appliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+			},
+			// the ManifestWorkController keeps the last known resource of the manifest failed to decode
+			manifests: []workapiv1.ManifestCondition{
+				newManifest("", "v1", "secrets", "ns1", "n1"),
+				func() workapiv1.ManifestCondition {
+					manifest := newManifest("", "v1", "secrets", "ns2", "n2")
+					manifest.Conditions = []metav1.Condition{
+						{Type: string(workapiv1.ManifestApplied), Status: metav1.ConditionFalse, Reason: "ManifestDecodeFailed"},
+						{Type: string(workapiv1.ManifestDegraded), Status: metav1.ConditionTrue, Reason: "ManifestDecodeFailed"},
+					}
+					return manifest
+				}(),
+			},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) > 0 {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+			expectedDeleteActions: []clienttesting.DeleteActionImpl{},
+		},
+		{
+			name: "keep the renamed resource until the resource it is renamed to is adopted",
+			existingResources: []runtime.Object{
+				withData(spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1", *owner), "n1"),
+				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
+				spoketesting.NewUnstructuredSecret("ns3", "n3", false, "ns3-n3", *owner),
+			},
+			appliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+			},
+			manifests: []workapiv1.ManifestCondition{
+				newManifest("", "v1", "secrets", "ns1", "n1"),
+				newManifest("", "v1", "secrets", "ns3", "n3"),
+			},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.AppliedManifestWork)
+				if !reflect.DeepEqual(work.Status.AppliedResources, []workapiv1.AppliedManifestResourceMeta{
+					{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+					{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+					{Version: "v1", Resource: "secrets", Namespace: "ns3", Name: "n3", UID: "ns3-n3"},
+				}) {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+			expectedDeleteActions: []clienttesting.DeleteActionImpl{},
+		},
+		{
+			name: "delete the renamed resource once the resource it is renamed to is adopted",
+			existingResources: []runtime.Object{
+				withData(spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1", *owner), "n1"),
+				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
+				spoketesting.NewUnstructuredSecret("ns3", "n3", false, "ns3-n3", *owner),
+			},
+			appliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns3", Name: "n3", UID: "ns3-n3"},
+			},
+			manifests: []workapiv1.ManifestCondition{
+				newManifest("", "v1", "secrets", "ns1", "n1"),
+				newManifest("", "v1", "secrets", "ns3", "n3"),
+			},
+			// the renamed resource is kept tracked until it is deleted
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) > 0 {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+			expectedDeleteActions: []clienttesting.DeleteActionImpl{
+				clienttesting.NewDeleteAction(schema.GroupVersionResource{Group: "", Version: "v1", Resource: "secrets"}, "ns2", "n2"),
+			},
+			expectedQueueLen: 1,
+		},
+		{
+			name: "report untracked resources which are forbidden to delete",
+			existingResources: []runtime.Object{
+				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1", *owner),
+				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
+			},
+			appliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+			},
+			manifests:    []workapiv1.ManifestCondition{newManifest("", "v1", "secrets", "ns1", "n1")},
+			forbidDelete: true,
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
+				cond := meta.FindStatusCondition(work.Status.Conditions, constants.WorkObsoleteResourcesPending)
+				if cond == nil || cond.Status != metav1.ConditionTrue || !strings.Contains(cond.Message, "/secrets ns2/n2") {
+					t.Fatal(spew.Sdump(work.Status.Conditions))
+				}
+			},
+			expectedDeleteActions: []clienttesting.DeleteActionImpl{
+				clienttesting.NewDeleteAction(schema.GroupVersionResource{Group: "", Version: "v1", Resource: "secrets"}, "ns2", "n2"),
+			},
+			expectedQueueLen: 1,
+		},
+		{
+			name: "pause the prune exceeding the prune threshold",
+			existingResources: []runtime.Object{
+				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1", *owner),
+				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
+			},
+			appliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+			},
+			pruneThreshold: PruneThreshold{MaxResources: 1},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
+				cond := meta.FindStatusCondition(work.Status.Conditions, constants.WorkPruneRequiresConfirmation)
+				if cond == nil || cond.Status != metav1.ConditionTrue || !strings.Contains(cond.Message, "Pruning 2 of 2") {
+					t.Fatal(spew.Sdump(work.Status.Conditions))
+				}
+			},
+			expectedDeleteActions: []clienttesting.DeleteActionImpl{},
+		},
+		{
+			name: "pause the prune exceeding the percent of the prune threshold",
+			existingResources: []runtime.Object{
+				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1", *owner),
+				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
+			},
+			appliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+			},
+			manifests:      []workapiv1.ManifestCondition{newManifest("", "v1", "secrets", "ns1", "n1")},
+			pruneThreshold: PruneThreshold{MaxPercent: 40},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
+				if meta.FindStatusCondition(work.Status.Conditions, constants.WorkPruneRequiresConfirmation) == nil {
+					t.Fatal(spew.Sdump(work.Status.Conditions))
+				}
+			},
+			expectedDeleteActions: []clienttesting.DeleteActionImpl{},
+		},
+		{
+			name: "prune within the prune threshold",
+			existingResources: []runtime.Object{
+				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1", *owner),
+				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
+			},
+			appliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+			},
+			manifests:      []workapiv1.ManifestCondition{newManifest("", "v1", "secrets", "ns1", "n1")},
+			pruneThreshold: PruneThreshold{MaxResources: 1, MaxPercent: 50},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) > 0 {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+			expectedDeleteActions: []clienttesting.DeleteActionImpl{
+				clienttesting.NewDeleteAction(schema.GroupVersionResource{Group: "", Version: "v1", Resource: "secrets"}, "ns2", "n2"),
+			},
+			// the resource deleted is tracked until it is gone
+			expectedQueueLen: 1,
+		},
+		{
+			name: "prune exceeding the prune threshold once it is confirmed",
+			existingResources: []runtime.Object{
+				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1", *owner),
+				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
+			},
+			appliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+			},
+			pruneThreshold: PruneThreshold{MaxResources: 1},
+			annotations:    map[string]string{constants.PruneConfirmationAnnotationKey: "2"},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) > 0 {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+			expectedDeleteActions: []clienttesting.DeleteActionImpl{
+				clienttesting.NewDeleteAction(schema.GroupVersionResource{Group: "", Version: "v1", Resource: "secrets"}, "ns1", "n1"),
+				clienttesting.NewDeleteAction(schema.GroupVersionResource{Group: "", Version: "v1", Resource: "secrets"}, "ns2", "n2"),
+			},
+			expectedQueueLen: 1,
+		},
+		{
+			name: "pause the prune of more resources than confirmed",
+			existingResources: []runtime.Object{
+				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1", *owner),
+				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
+			},
+			appliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+			},
+			pruneThreshold: PruneThreshold{MaxResources: 1},
+			annotations:    map[string]string{constants.PruneConfirmationAnnotationKey: "1"},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+				if _, ok := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork); !ok {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+			expectedDeleteActions: []clienttesting.DeleteActionImpl{},
+		},
+		{
+			name: "neither track nor delete patched resources",
+			existingResources: []runtime.Object{
+				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1"),
+				spoketesting.NewUnstructuredSecret("ns2", "n2", false, "ns2-n2", *owner),
+			},
+			appliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "ns1-n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+			},
+			manifests: func() []workapiv1.ManifestCondition {
+				patched, applied := newManifest("", "v1", "secrets", "ns1", "n1"), newManifest("", "v1", "secrets", "ns2", "n2")
+				applied.ResourceMeta.Ordinal = 1
+				return []workapiv1.ManifestCondition{patched, applied}
+			}(),
+			configOptions: `[{"ordinal": 0, "resourceIdentifier": {"version": "v1", "resource": "secrets", "namespace": "ns1", "name": "n1"},
+				"updateStrategy": {"type": "Patch", "patchType": "MergePatch"}}]`,
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.AppliedManifestWork)
+				if !reflect.DeepEqual(work.Status.AppliedResources, []workapiv1.AppliedManifestResourceMeta{
+					{Version: "v1", Resource: "secrets", Namespace: "ns2", Name: "n2", UID: "ns2-n2"},
+				}) {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+			expectedDeleteActions: []clienttesting.DeleteActionImpl{},
+		},
 	}
 
 	for _, c := range cases {
@@ -195,8 +533,19 @@ func TestSyncManifestWork(t *testing.T) {
 			testingAppliedWork := appliedWork.DeepCopy()
 			testingAppliedWork.Status.AppliedResources = c.appliedResources
 			testingWork.Status.ResourceStatus.Manifests = c.manifests
+			testingWork.Spec.DeleteOption = c.deleteOption
+			testingWork.Annotations = c.annotations
+			if len(c.configOptions) > 0 {
+				testingWork.Annotations = map[string]string{constants.ManifestConfigOptionsAnnotationKey: c.configOptions}
+				testingWork.Spec.Workload.Manifests = make([]workapiv1.Manifest, len(c.manifests))
+			}
 
 			fakeDynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), c.existingResources...)
+			if c.forbidDelete {
+				fakeDynamicClient.PrependReactor("delete", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.NewForbidden(action.GetResource().GroupResource(), "", fmt.Errorf("forbidden"))
+				})
+			}
 			fakeClient := fakeworkclient.NewSimpleClientset(testingWork, testingAppliedWork)
 			informerFactory := workinformers.NewSharedInformerFactory(fakeClient, 5*time.Minute)
 			informerFactory.Work().V1().ManifestWorks().Informer().GetStore().Add(testingWork)
@@ -209,9 +558,11 @@ func TestSyncManifestWork(t *testing.T) {
 				spokeDynamicClient:        fakeDynamicClient,
 				hubHash:                   "test",
 				rateLimiter:               workqueue.NewItemExponentialFailureRateLimiter(0, 1*time.Second),
+				pruneThreshold:            c.pruneThreshold,
 			}
 
-			controllerContext := spoketesting.NewFakeSyncContext(t, testingWork.Name)
+			recorder := events.NewInMemoryRecorder("test")
+			controllerContext := spoketesting.NewFakeSyncContext(t, testingWork.Name).WithRecorder(recorder)
 			err := controller.sync(context.TODO(), controllerContext)
 			if err != nil {
 				t.Fatal(err)
@@ -228,6 +579,16 @@ func TestSyncManifestWork(t *testing.T) {
 				t.Fatal(spew.Sdump(deleteActions))
 			}
 
+			orphanedOnRemoval := 0
+			for _, event := range recorder.Events() {
+				if event.Reason == constants.EventReasonResourceOrphanedOnRemoval {
+					orphanedOnRemoval++
+				}
+			}
+			if orphanedOnRemoval != c.expectedOrphanedOnRemoval {
+				t.Errorf("expected %d resources orphaned on removal, but got %d", c.expectedOrphanedOnRemoval, orphanedOnRemoval)
+			}
+
 			queueLen := controllerContext.Queue().Len()
 			if queueLen != c.expectedQueueLen {
 				t.Errorf("expected %d, but %d", c.expectedQueueLen, queueLen)
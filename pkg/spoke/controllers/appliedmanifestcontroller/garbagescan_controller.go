@@ -0,0 +1,214 @@
+package appliedmanifestcontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+	workinformer "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
+	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/controllers"
+)
+
+// GarbageScanController deletes the resources on the managed cluster which are owned by an appliedmanifestwork
+// but are tracked by neither the appliedmanifestwork nor the manifestwork. They are leaked if the agent crashes
+// after the resource is created but before it is recorded, and the manifest is removed from the manifestwork in
+// the meantime. Only the resources with the provenance labels are scanned, so that the scan does not list every
+// resource on the managed cluster.
+type GarbageScanController struct {
+	manifestWorkLister        worklister.ManifestWorkNamespaceLister
+	appliedManifestWorkLister worklister.AppliedManifestWorkLister
+	spokeDynamicClient        dynamic.Interface
+	spokeDiscoveryClient      discovery.DiscoveryInterface
+	hubHash                   string
+	// minAge is the min age of an untracked resource before it is deleted. It protects the resources which are
+	// just applied and not recorded yet.
+	minAge time.Duration
+}
+
+// NewGarbageScanController returns a GarbageScanController
+func NewGarbageScanController(
+	recorder events.Recorder,
+	spokeDynamicClient dynamic.Interface,
+	spokeDiscoveryClient discovery.DiscoveryInterface,
+	manifestWorkInformer workinformer.ManifestWorkInformer,
+	manifestWorkLister worklister.ManifestWorkNamespaceLister,
+	appliedManifestWorkInformer workinformer.AppliedManifestWorkInformer,
+	hubHash string,
+	scanInterval, minAge time.Duration) factory.Controller {
+
+	controller := &GarbageScanController{
+		manifestWorkLister:        manifestWorkLister,
+		appliedManifestWorkLister: appliedManifestWorkInformer.Lister(),
+		spokeDynamicClient:        spokeDynamicClient,
+		spokeDiscoveryClient:      spokeDiscoveryClient,
+		hubHash:                   hubHash,
+		minAge:                    minAge,
+	}
+
+	return factory.New().
+		WithBareInformers(manifestWorkInformer.Informer(), appliedManifestWorkInformer.Informer()).
+		WithSync(controllers.InstrumentSync(hubHash, "GarbageScanController", controller.sync)).ResyncEvery(scanInterval).ToController("GarbageScanController", recorder)
+}
+
+func (m *GarbageScanController) sync(ctx context.Context, controllerContext factory.SyncContext) error {
+	klog.V(4).Infof("Scanning garbage of AppliedManifestWorks")
+
+	gvrs, err := m.scannableResources()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, gvr := range gvrs {
+		list, err := m.spokeDynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{LabelSelector: constants.ManifestWorkLabelKey})
+		switch {
+		case errors.IsNotFound(err), errors.IsForbidden(err), errors.IsMethodNotSupported(err):
+			continue
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to list %v: %w", gvr, err))
+			continue
+		}
+
+		for i := range list.Items {
+			if err := m.syncResource(controllerContext.Recorder(), gvr, &list.Items[i]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// syncResource deletes the resource, or removes the owner from the resource if it has other owners, for each
+// appliedmanifestwork of this hub which owns the resource but does not track it.
+func (m *GarbageScanController) syncResource(recorder events.Recorder, gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	if obj.GetDeletionTimestamp() != nil || time.Since(obj.GetCreationTimestamp().Time) < m.minAge {
+		return nil
+	}
+
+	resource := workapiv1.AppliedManifestResourceMeta{
+		Group:     gvr.Group,
+		Version:   gvr.Version,
+		Resource:  gvr.Resource,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		UID:       string(obj.GetUID()),
+	}
+
+	var errs []error
+	for _, ownerRef := range obj.GetOwnerReferences() {
+		if _, ok := helper.ParseAppliedManifestWorkName(m.hubHash, ownerRef.Name); ownerRef.Kind != "AppliedManifestWork" || !ok {
+			continue
+		}
+
+		appliedManifestWork, err := m.appliedManifestWorkLister.Get(ownerRef.Name)
+		if errors.IsNotFound(err) {
+			// the resource is garbage collected by the kube garbage collector once its owner is deleted
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if appliedManifestWork.UID != ownerRef.UID || !appliedManifestWork.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		tracked, err := m.isTracked(appliedManifestWork, resource)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if tracked {
+			continue
+		}
+
+		reason := fmt.Sprintf("it is owned but not tracked by appliedmanifestwork %s", appliedManifestWork.Name)
+		owner := helper.NewAppliedManifestWorkOwner(appliedManifestWork)
+		workRecorder := helper.NewWorkEventRecorder(recorder, m.hubHash, appliedManifestWork.Spec.ManifestWorkName)
+		// an event is recorded for each deletion
+		if _, err := helper.DeleteAppliedResource(resource, reason, m.spokeDynamicClient, workRecorder, *owner); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// isTracked checks if the resource is tracked by the appliedmanifestwork or the status of the manifestwork. The
+// resource is treated as tracked if the manifestwork is not found, since it is unknown whether the resource is
+// still in the manifestwork.
+func (m *GarbageScanController) isTracked(
+	appliedManifestWork *workapiv1.AppliedManifestWork, resource workapiv1.AppliedManifestResourceMeta) (bool, error) {
+	for _, applied := range appliedManifestWork.Status.AppliedResources {
+		if applied.Group == resource.Group && applied.Resource == resource.Resource &&
+			applied.Namespace == resource.Namespace && applied.Name == resource.Name {
+			return true, nil
+		}
+	}
+
+	manifestWork, err := m.manifestWorkLister.Get(appliedManifestWork.Spec.ManifestWorkName)
+	if errors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, manifest := range manifestWork.Status.ResourceStatus.Manifests {
+		meta := manifest.ResourceMeta
+		if meta.Group == resource.Group && meta.Resource == resource.Resource &&
+			meta.Namespace == resource.Namespace && meta.Name == resource.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// scannableResources returns the resources on the managed cluster which can be listed and deleted. Only one
+// version is returned for each group/resource.
+func (m *GarbageScanController) scannableResources() ([]schema.GroupVersionResource, error) {
+	_, resourceLists, err := m.spokeDiscoveryClient.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+
+	resourceLists = discovery.FilteredBy(discovery.SupportsAllVerbs{Verbs: []string{"list", "delete"}}, resourceLists)
+
+	var gvrs []schema.GroupVersionResource
+	seen := map[schema.GroupResource]bool{}
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			// skip subresources
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			gvr := gv.WithResource(resource.Name)
+			if seen[gvr.GroupResource()] {
+				continue
+			}
+			seen[gvr.GroupResource()] = true
+			gvrs = append(gvrs, gvr)
+		}
+	}
+	return gvrs, nil
+}
@@ -0,0 +1,126 @@
+package appliedmanifestcontroller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestGarbageScan(t *testing.T) {
+	appliedWork := spoketesting.NewAppliedManifestWork("test", 0, "applied-uid")
+	appliedWork.Status.AppliedResources = []workapiv1.AppliedManifestResourceMeta{
+		{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "tracked", UID: "tracked"},
+	}
+	work, _ := spoketesting.NewManifestWork(0)
+	work.Status.ResourceStatus.Manifests = []workapiv1.ManifestCondition{newManifest("", "v1", "secrets", "ns1", "pending")}
+	owner := helper.NewAppliedManifestWorkOwner(appliedWork)
+	otherHubOwner := helper.NewAppliedManifestWorkOwner(spoketesting.NewAppliedManifestWork("other", 0, "other-uid"))
+
+	newSecret := func(name string, age time.Duration, labeled bool, owners ...metav1.OwnerReference) *unstructured.Unstructured {
+		secret := spoketesting.NewUnstructuredSecret("ns1", name, false, name, owners...)
+		secret.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-age)))
+		if labeled {
+			secret.SetLabels(map[string]string{constants.ManifestWorkLabelKey: work.Name})
+		}
+		return secret
+	}
+
+	cases := []struct {
+		name              string
+		existingResources []runtime.Object
+		expectedDeletes   []string
+	}{
+		{
+			name: "delete untracked resources",
+			existingResources: []runtime.Object{
+				newSecret("untracked", time.Hour, true, *owner),
+				newSecret("tracked", time.Hour, true, *owner),
+				newSecret("pending", time.Hour, true, *owner),
+			},
+			expectedDeletes: []string{"untracked"},
+		},
+		{
+			name: "keep young resources",
+			existingResources: []runtime.Object{
+				newSecret("untracked", time.Second, true, *owner),
+			},
+		},
+		{
+			name: "keep resources without provenance labels",
+			existingResources: []runtime.Object{
+				newSecret("untracked", time.Hour, false, *owner),
+			},
+		},
+		{
+			name: "keep resources of other hubs",
+			existingResources: []runtime.Object{
+				newSecret("untracked", time.Hour, true, *otherHubOwner),
+			},
+		},
+		{
+			name: "keep resources of recreated appliedmanifestworks",
+			existingResources: []runtime.Object{
+				newSecret("untracked", time.Hour, true, metav1.OwnerReference{
+					APIVersion: owner.APIVersion, Kind: owner.Kind, Name: owner.Name, UID: types.UID("stale-uid")}),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeDynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), c.existingResources...)
+			fakeDiscoveryClient := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+			fakeDiscoveryClient.Resources = []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "secrets", Namespaced: true, Kind: "Secret", Verbs: []string{"list", "delete"}},
+						{Name: "secrets/status", Namespaced: true, Kind: "Secret", Verbs: []string{"list", "delete"}},
+						{Name: "bindings", Namespaced: true, Kind: "Binding", Verbs: []string{"create"}},
+					},
+				},
+			}
+			fakeClient := fakeworkclient.NewSimpleClientset(work, appliedWork)
+			informerFactory := workinformers.NewSharedInformerFactory(fakeClient, 5*time.Minute)
+			informerFactory.Work().V1().ManifestWorks().Informer().GetStore().Add(work)
+			informerFactory.Work().V1().AppliedManifestWorks().Informer().GetStore().Add(appliedWork)
+			controller := GarbageScanController{
+				manifestWorkLister:        informerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks("cluster1"),
+				appliedManifestWorkLister: informerFactory.Work().V1().AppliedManifestWorks().Lister(),
+				spokeDynamicClient:        fakeDynamicClient,
+				spokeDiscoveryClient:      fakeDiscoveryClient,
+				hubHash:                   "test",
+				minAge:                    time.Minute,
+			}
+
+			if err := controller.sync(context.TODO(), spoketesting.NewFakeSyncContext(t, "key")); err != nil {
+				t.Fatal(err)
+			}
+
+			var deletes []string
+			for _, action := range fakeDynamicClient.Actions() {
+				if action.GetVerb() == "delete" {
+					deletes = append(deletes, action.(clienttesting.DeleteAction).GetName())
+				}
+			}
+			if !reflect.DeepEqual(deletes, c.expectedDeletes) {
+				t.Errorf("expected deletes %v, but got %v", c.expectedDeletes, deletes)
+			}
+		})
+	}
+}
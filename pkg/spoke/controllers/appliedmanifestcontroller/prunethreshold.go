@@ -0,0 +1,83 @@
+package appliedmanifestcontroller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+// pruneThresholdExceededReason is the reason of the condition PruneRequiresConfirmation of a manifestwork whose
+// prune is paused.
+const pruneThresholdExceededReason = "PruneThresholdExceeded"
+
+// PruneThreshold is the max number of the resources removed from a manifestwork pruned by a reconcile without
+// confirmation, in count and in percent of the resources applied by the manifestwork. A prune exceeding either of them
+// is paused until it is confirmed, e.g. the manifests of the manifestwork are emptied by mistake on the hub. A limit
+// is disabled if it is not positive.
+type PruneThreshold struct {
+	MaxResources int
+	MaxPercent   int
+}
+
+// exceeded returns if pruning the number of resources out of the applied ones exceeds the threshold.
+func (t PruneThreshold) exceeded(pruning, applied int) bool {
+	if pruning == 0 {
+		return false
+	}
+	if t.MaxResources > 0 && pruning > t.MaxResources {
+		return true
+	}
+	return t.MaxPercent > 0 && applied > 0 && pruning*100 > t.MaxPercent*applied
+}
+
+// pruneConfirmed returns if the prune of the number of resources is confirmed by the annotation
+// PruneConfirmationAnnotationKey of the manifestwork.
+func pruneConfirmed(manifestWork *workapiv1.ManifestWork, pruning int) bool {
+	value, ok := manifestWork.Annotations[constants.PruneConfirmationAnnotationKey]
+	if !ok {
+		return false
+	}
+	confirmed, err := strconv.Atoi(value)
+	if err != nil {
+		klog.Warningf("Ignore the annotation %s=%q of manifestwork %q, it is not a number",
+			constants.PruneConfirmationAnnotationKey, value, manifestWork.Name)
+		return false
+	}
+	return pruning <= confirmed
+}
+
+// updatePruneConfirmationCondition sets the PruneRequiresConfirmation condition on the manifestwork if the prune of
+// the resources is paused, and removes the condition once the prune is not paused any more.
+func (m *AppliedManifestWorkController) updatePruneConfirmationCondition(
+	ctx context.Context, manifestWork *workapiv1.ManifestWork, paused bool, pruning, applied int) error {
+	if !paused && meta.FindStatusCondition(manifestWork.Status.Conditions, constants.WorkPruneRequiresConfirmation) == nil {
+		return nil
+	}
+
+	_, _, err := helper.UpdateManifestWorkStatus(ctx, m.manifestWorkClient, manifestWork.DeepCopy(),
+		func(status *workapiv1.ManifestWorkStatus) error {
+			if !paused {
+				meta.RemoveStatusCondition(&status.Conditions, constants.WorkPruneRequiresConfirmation)
+				return nil
+			}
+
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               constants.WorkPruneRequiresConfirmation,
+				Status:             metav1.ConditionTrue,
+				Reason:             pruneThresholdExceededReason,
+				ObservedGeneration: manifestWork.Generation,
+				Message: fmt.Sprintf("Pruning %d of %d applied resources exceeds the prune threshold, set the annotation %s to %d to confirm",
+					pruning, applied, constants.PruneConfirmationAnnotationKey, pruning),
+			})
+			return nil
+		})
+	return err
+}
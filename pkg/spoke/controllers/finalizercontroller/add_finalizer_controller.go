@@ -9,6 +9,8 @@ import (
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 
 	"github.com/openshift/library-go/pkg/controller/factory"
@@ -16,6 +18,8 @@ import (
 	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
 	workinformer "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
 	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
 	"open-cluster-management.io/work/pkg/spoke/controllers"
 )
 
@@ -31,6 +35,7 @@ func NewAddFinalizerController(
 	manifestWorkClient workv1client.ManifestWorkInterface,
 	manifestWorkInformer workinformer.ManifestWorkInformer,
 	manifestWorkLister worklister.ManifestWorkNamespaceLister,
+	hubHash string,
 ) factory.Controller {
 
 	controller := &AddFinalizerController{
@@ -43,7 +48,7 @@ func NewAddFinalizerController(
 			accessor, _ := meta.Accessor(obj)
 			return accessor.GetName()
 		}, manifestWorkInformer.Informer()).
-		WithSync(controller.sync).ToController("ManifestWorkAddFinalizerController", recorder)
+		WithSync(controllers.InstrumentSync(hubHash, "ManifestWorkAddFinalizerController", controller.sync)).ToController("ManifestWorkAddFinalizerController", recorder)
 }
 
 func (m *AddFinalizerController) sync(ctx context.Context, controllerContext factory.SyncContext) error {
@@ -61,22 +66,43 @@ func (m *AddFinalizerController) sync(ctx context.Context, controllerContext fac
 	return m.syncManifestWork(ctx, manifestWork)
 }
 
-func (m *AddFinalizerController) syncManifestWork(ctx context.Context, originalManifestWork *workapiv1.ManifestWork) error {
-	manifestWork := originalManifestWork.DeepCopy()
+// syncManifestWork adds the finalizer to the manifestwork with a json patch, so that it does not conflict with the
+// changes of the other fields on hub. The manifests are not applied until the finalizer is added, see the
+// ManifestWorkController.
+func (m *AddFinalizerController) syncManifestWork(ctx context.Context, manifestWork *workapiv1.ManifestWork) error {
+	first := true
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if !first {
+			latest, err := m.manifestWorkClient.Get(ctx, manifestWork.Name, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			manifestWork = latest
+		}
+		first = false
 
-	// don't add finalizers to instances that are deleted
-	if !manifestWork.DeletionTimestamp.IsZero() {
-		return nil
-	}
+		// don't add finalizers to instances that are deleted
+		if !manifestWork.DeletionTimestamp.IsZero() {
+			return nil
+		}
 
-	// don't add finalizer to instances that already have it
-	for i := range manifestWork.Finalizers {
-		if manifestWork.Finalizers[i] == controllers.ManifestWorkFinalizer {
+		patch, err := helper.AddFinalizerPatch(manifestWork.Finalizers, constants.ManifestWorkFinalizer)
+		if err != nil || patch == nil {
+			return err
+		}
+
+		_, err = m.manifestWorkClient.Patch(ctx, manifestWork.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			// the manifestwork is deleted right after it is created, there is nothing to finalize
 			return nil
+		case errors.IsInvalid(err):
+			// the test operation fails if the finalizers are added by others, retry with the latest manifestwork
+			return errors.NewConflict(workapiv1.Resource("manifestworks"), manifestWork.Name, err)
 		}
-	}
-	// if this conflicts, we'll simply try again later
-	manifestWork.Finalizers = append(manifestWork.Finalizers, controllers.ManifestWorkFinalizer)
-	_, err := m.manifestWorkClient.Update(ctx, manifestWork, metav1.UpdateOptions{})
-	return err
+		return err
+	})
 }
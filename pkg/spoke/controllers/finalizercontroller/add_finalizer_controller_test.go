@@ -6,11 +6,15 @@ import (
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	clienttesting "k8s.io/client-go/testing"
 	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
 	workapiv1 "open-cluster-management.io/api/work/v1"
-	"open-cluster-management.io/work/pkg/spoke/controllers"
+	"open-cluster-management.io/work/pkg/constants"
 	"open-cluster-management.io/work/pkg/spoke/spoketesting"
 )
 
@@ -20,16 +24,14 @@ func TestAddFinalizer(t *testing.T) {
 		existingFinalizers []string
 		terminated         bool
 
-		validateActions func(t *testing.T, actions []clienttesting.Action)
+		expectedFinalizers []string
+		validateActions    func(t *testing.T, actions []clienttesting.Action)
 	}{
 		{
-			name: "add when empty",
+			name:               "add when empty",
+			expectedFinalizers: []string{constants.ManifestWorkFinalizer},
 			validateActions: func(t *testing.T, actions []clienttesting.Action) {
-				if len(actions) != 1 {
-					t.Fatal(spew.Sdump(actions))
-				}
-				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
-				if !reflect.DeepEqual(work.Finalizers, []string{controllers.ManifestWorkFinalizer}) {
+				if len(actions) != 1 || actions[0].(clienttesting.PatchAction).GetPatchType() != types.JSONPatchType {
 					t.Fatal(spew.Sdump(actions))
 				}
 			},
@@ -37,12 +39,9 @@ func TestAddFinalizer(t *testing.T) {
 		{
 			name:               "add when missing",
 			existingFinalizers: []string{"other"},
+			expectedFinalizers: []string{"other", constants.ManifestWorkFinalizer},
 			validateActions: func(t *testing.T, actions []clienttesting.Action) {
-				if len(actions) != 1 {
-					t.Fatal(spew.Sdump(actions))
-				}
-				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
-				if !reflect.DeepEqual(work.Finalizers, []string{"other", controllers.ManifestWorkFinalizer}) {
+				if len(actions) != 1 || actions[0].(clienttesting.PatchAction).GetPatchType() != types.JSONPatchType {
 					t.Fatal(spew.Sdump(actions))
 				}
 			},
@@ -58,7 +57,8 @@ func TestAddFinalizer(t *testing.T) {
 		},
 		{
 			name:               "skip when present",
-			existingFinalizers: []string{controllers.ManifestWorkFinalizer},
+			existingFinalizers: []string{constants.ManifestWorkFinalizer},
+			expectedFinalizers: []string{constants.ManifestWorkFinalizer},
 			validateActions: func(t *testing.T, actions []clienttesting.Action) {
 				if len(actions) > 0 {
 					t.Fatal(spew.Sdump(actions))
@@ -86,6 +86,72 @@ func TestAddFinalizer(t *testing.T) {
 				t.Fatal(err)
 			}
 			c.validateActions(t, fakeClient.Actions())
+
+			work, err := fakeClient.WorkV1().ManifestWorks(testingWork.Namespace).Get(context.TODO(), testingWork.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(work.Finalizers, c.expectedFinalizers) {
+				t.Errorf("expected finalizers %v, but got %v", c.expectedFinalizers, work.Finalizers)
+			}
 		})
 	}
 }
+
+func TestAddFinalizerConflict(t *testing.T) {
+	testingWork, _ := spoketesting.NewManifestWork(0)
+
+	// the finalizer of another controller is added on hub after the manifestwork is cached
+	latestWork := testingWork.DeepCopy()
+	latestWork.Finalizers = []string{"other"}
+	fakeClient := fakeworkclient.NewSimpleClientset(latestWork)
+	// the apiserver rejects the patch as invalid once the test operation fails
+	patched := false
+	fakeClient.PrependReactor("patch", "manifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if patched {
+			return false, nil, nil
+		}
+		patched = true
+		return true, nil, errors.NewInvalid(schema.GroupKind{Group: workapiv1.GroupName, Kind: "ManifestWork"}, testingWork.Name, nil)
+	})
+	controller := AddFinalizerController{
+		manifestWorkClient: fakeClient.WorkV1().ManifestWorks(testingWork.Namespace),
+	}
+
+	if err := controller.syncManifestWork(context.TODO(), testingWork); err != nil {
+		t.Fatal(err)
+	}
+
+	// the test operation of the patch fails and the finalizer is appended to the latest finalizers
+	actions := fakeClient.Actions()
+	if len(actions) != 3 || actions[0].GetVerb() != "patch" || actions[1].GetVerb() != "get" || actions[2].GetVerb() != "patch" {
+		t.Fatal(spew.Sdump(actions))
+	}
+	work, err := fakeClient.WorkV1().ManifestWorks(testingWork.Namespace).Get(context.TODO(), testingWork.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(work.Finalizers, []string{"other", constants.ManifestWorkFinalizer}) {
+		t.Errorf("expected the finalizer appended, but got %v", work.Finalizers)
+	}
+}
+
+func TestAddFinalizerNotFound(t *testing.T) {
+	testingWork, _ := spoketesting.NewManifestWork(0)
+
+	// the manifestwork is deleted on hub right after it is created
+	fakeClient := fakeworkclient.NewSimpleClientset()
+	fakeClient.PrependReactor("patch", "manifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(workapiv1.Resource("manifestworks"), testingWork.Name)
+	})
+	controller := AddFinalizerController{
+		manifestWorkClient: fakeClient.WorkV1().ManifestWorks(testingWork.Namespace),
+	}
+
+	if err := controller.syncManifestWork(context.TODO(), testingWork); err != nil {
+		t.Errorf("expected no error, but got %v", err)
+	}
+	if actions := fakeClient.Actions(); len(actions) != 1 {
+		t.Fatal(spew.Sdump(actions))
+	}
+}
@@ -2,7 +2,9 @@ package finalizercontroller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/openshift/library-go/pkg/controller/factory"
@@ -10,32 +12,52 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
 	workinformer "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
 	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
 	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
 	"open-cluster-management.io/work/pkg/helper"
 	"open-cluster-management.io/work/pkg/spoke/controllers"
 )
 
+// HeartbeatInterval is the interval at which the agent refreshes the heartbeat of its appliedmanifestworks.
+var HeartbeatInterval = 5 * time.Minute
+
 // AppliedManifestWorkFinalizeController handles cleanup of appliedmanifestwork resources before deletion is allowed.
+// It also refreshes the heartbeat of the appliedmanifestworks owned by this agent.
 type AppliedManifestWorkFinalizeController struct {
 	appliedManifestWorkClient workv1client.AppliedManifestWorkInterface
 	appliedManifestWorkLister worklister.AppliedManifestWorkLister
 	spokeDynamicClient        dynamic.Interface
 	rateLimiter               workqueue.RateLimiter
+	hubHash                   string
+	agentID                   string
+	// discoverUntracked deletes the resources owned by a deleting appliedmanifestwork but missing in its applied
+	// resources as well, see untrackedResources
+	discoverUntracked bool
 }
 
+// NewAppliedManifestWorkFinalizeController returns an AppliedManifestWorkFinalizeController. The resources owned by
+// a deleting appliedmanifestwork but missing in its applied resources, e.g. the list is truncated by an older agent,
+// are discovered and deleted as well if discoverUntracked is true.
 func NewAppliedManifestWorkFinalizeController(
 	recorder events.Recorder,
 	spokeDynamicClient dynamic.Interface,
 	appliedManifestWorkClient workv1client.AppliedManifestWorkInterface,
 	appliedManifestWorkInformer workinformer.AppliedManifestWorkInformer,
+	hubHash, agentID string,
+	discoverUntracked bool,
 ) factory.Controller {
 
 	controller := &AppliedManifestWorkFinalizeController{
@@ -43,6 +65,9 @@ func NewAppliedManifestWorkFinalizeController(
 		appliedManifestWorkLister: appliedManifestWorkInformer.Lister(),
 		spokeDynamicClient:        spokeDynamicClient,
 		rateLimiter:               workqueue.NewItemExponentialFailureRateLimiter(5*time.Millisecond, 1000*time.Second),
+		hubHash:                   hubHash,
+		agentID:                   agentID,
+		discoverUntracked:         discoverUntracked,
 	}
 
 	return factory.New().
@@ -50,7 +75,7 @@ func NewAppliedManifestWorkFinalizeController(
 			accessor, _ := meta.Accessor(obj)
 			return accessor.GetName()
 		}, appliedManifestWorkInformer.Informer()).
-		WithSync(controller.sync).ToController("AppliedManifestWorkFinalizer", recorder)
+		WithSync(controllers.InstrumentSync(hubHash, "AppliedManifestWorkFinalizer", controller.sync)).ToController("AppliedManifestWorkFinalizer", recorder)
 }
 
 func (m *AppliedManifestWorkFinalizeController) sync(ctx context.Context, controllerContext factory.SyncContext) error {
@@ -74,15 +99,15 @@ func (m *AppliedManifestWorkFinalizeController) sync(ctx context.Context, contro
 func (m *AppliedManifestWorkFinalizeController) syncAppliedManifestWork(ctx context.Context, controllerContext factory.SyncContext, originalManifestWork *workapiv1.AppliedManifestWork) error {
 	appliedManifestWork := originalManifestWork.DeepCopy()
 
-	// no work to do until we're deleted
+	// only refresh the heartbeat until we're deleted
 	if appliedManifestWork.DeletionTimestamp == nil || appliedManifestWork.DeletionTimestamp.IsZero() {
-		return nil
+		return m.heartbeat(ctx, controllerContext, appliedManifestWork)
 	}
 
 	// don't do work if the finalizer is not present
 	found := false
 	for i := range appliedManifestWork.Finalizers {
-		if appliedManifestWork.Finalizers[i] == controllers.AppliedManifestWorkFinalizer {
+		if appliedManifestWork.Finalizers[i] == constants.AppliedManifestWorkFinalizer {
 			found = true
 			break
 		}
@@ -94,16 +119,54 @@ func (m *AppliedManifestWorkFinalizeController) syncAppliedManifestWork(ctx cont
 	var err error
 
 	owner := helper.NewAppliedManifestWorkOwner(appliedManifestWork)
+	// the appliedmanifestworks created by older agents may have a corrupted spec, which is repaired from the name
+	hubHash, manifestWorkName, repaired := helper.AppliedManifestWorkIdentity(appliedManifestWork)
+	if repaired {
+		klog.Warningf("AppliedManifestWork %s has a corrupted spec, finalize it as manifestwork %s of hub %s",
+			appliedManifestWork.Name, manifestWorkName, hubHash)
+	}
+	recorder := helper.NewWorkEventRecorder(controllerContext.Recorder(), hubHash, manifestWorkName)
+
+	// the resources owned by the appliedmanifestwork but missing in its applied resources, e.g. the list is truncated
+	// by an older agent, are deleted as well
+	untracked, err := m.untrackedResources(ctx, appliedManifestWork, manifestWorkName)
+	if err != nil {
+		return err
+	}
+	if len(untracked) != 0 {
+		klog.Warningf("AppliedManifestWork %s does not track %d resources it owns, they are deleted as well",
+			appliedManifestWork.Name, len(untracked))
+		recorder.Warningf("AppliedResourcesRepaired",
+			"AppliedManifestWork %s does not track %d resources it owns: %s.", appliedManifestWork.Name, len(untracked),
+			strings.Join(resourceKeys(untracked), ", "))
+		appliedManifestWork.Status.AppliedResources = append(appliedManifestWork.Status.AppliedResources, untracked...)
+	}
 
 	// Work is deleting, we remove its related resources on spoke cluster
 	// We still need to run delete for every resource even with ownerref on it, since ownerref does not handle cluster
 	// scoped resource correctly.
-	reason := fmt.Sprintf("manifestwork %s is terminating", appliedManifestWork.Spec.ManifestWorkName)
+	// The deletion of crds is held if the apiserver would delete the custom resources owned by other
+	// appliedmanifestworks as well, unless the deletion is forced.
+	resourcesToDelete := appliedManifestWork.Status.AppliedResources
+	var resourcesHeld []workapiv1.AppliedManifestResourceMeta
+	blockedMessage := ""
+	if appliedManifestWork.Annotations[constants.ForceDeletionAnnotationKey] != "true" {
+		resourcesToDelete, resourcesHeld, blockedMessage, err = m.holdCRDsWithDependents(ctx, resourcesToDelete, *owner)
+		if err != nil {
+			return err
+		}
+	}
+
+	reason := fmt.Sprintf("manifestwork %s is terminating", manifestWorkName)
 	resourcesPendingFinalization, errs := helper.DeleteAppliedResources(
-		appliedManifestWork.Status.AppliedResources, reason, m.spokeDynamicClient, controllerContext.Recorder(), *owner)
+		resourcesToDelete, reason, m.spokeDynamicClient, recorder, *owner)
+	if len(resourcesHeld) != 0 {
+		resourcesPendingFinalization = append(resourcesPendingFinalization, resourcesHeld...)
+		sortAppliedResources(resourcesPendingFinalization, appliedManifestWork.Status.AppliedResources)
+	}
 
 	updatedAppliedManifestWork := false
-	if len(appliedManifestWork.Status.AppliedResources) != len(resourcesPendingFinalization) {
+	if len(originalManifestWork.Status.AppliedResources) != len(resourcesPendingFinalization) || len(untracked) != 0 {
 		// update the status of the manifest work accordingly
 		appliedManifestWork.Status.AppliedResources = resourcesPendingFinalization
 		appliedManifestWork, err = m.appliedManifestWorkClient.UpdateStatus(ctx, appliedManifestWork, metav1.UpdateOptions{})
@@ -114,10 +177,30 @@ func (m *AppliedManifestWorkFinalizeController) syncAppliedManifestWork(ctx cont
 			updatedAppliedManifestWork = true
 		}
 	}
+	// the appliedmanifestwork is nil if its status is failed to update
+	if appliedManifestWork != nil {
+		deniedMessage := deletionDeniedMessage(errs, len(resourcesToDelete))
+		denied, err := m.updateDeletionDenied(ctx, appliedManifestWork, deniedMessage)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if denied && len(deniedMessage) > 0 {
+			recorder.Warningf("ResourceDeletionDenied", "AppliedManifestWork %s: %s", appliedManifestWork.Name, deniedMessage)
+		}
+	}
 	if len(errs) != 0 {
 		return utilerrors.NewAggregate(errs)
 	}
 
+	blocked, err := m.updateDeletionBlocked(ctx, appliedManifestWork, blockedMessage)
+	if err != nil {
+		return err
+	}
+	if blocked && len(blockedMessage) > 0 {
+		recorder.Warningf("ResourceDeletionBlocked",
+			"AppliedManifestWork %s holds the deletion of crds with dependents: %s.", appliedManifestWork.Name, blockedMessage)
+	}
+
 	// requeue the work until all applied resources are deleted and finalized if the appliedmanifestwork itself is not updated
 	if len(resourcesPendingFinalization) != 0 {
 		if !updatedAppliedManifestWork {
@@ -129,10 +212,148 @@ func (m *AppliedManifestWorkFinalizeController) syncAppliedManifestWork(ctx cont
 	// reset the rate limiter for the appliedmanifestwork
 	m.rateLimiter.Forget(appliedManifestWork.Name)
 
-	helper.RemoveFinalizer(appliedManifestWork, controllers.AppliedManifestWorkFinalizer)
-	_, err = m.appliedManifestWorkClient.Update(ctx, appliedManifestWork, metav1.UpdateOptions{})
-	if err != nil {
+	if err := m.removeFinalizer(ctx, appliedManifestWork); err != nil {
 		return fmt.Errorf("Failed to remove finalizer from AppliedManifestWork %s: %w", appliedManifestWork.Name, err)
 	}
 	return nil
 }
+
+// untrackedResources returns the resources owned by the appliedmanifestwork which are not in its applied resources.
+// Only the resources of the types in the applied resources with the provenance label of the manifestwork are
+// discovered, so that the resources on the managed cluster are not listed entirely.
+func (m *AppliedManifestWorkFinalizeController) untrackedResources(
+	ctx context.Context, appliedManifestWork *workapiv1.AppliedManifestWork, manifestWorkName string) ([]workapiv1.AppliedManifestResourceMeta, error) {
+	if !m.discoverUntracked || len(manifestWorkName) == 0 {
+		return nil, nil
+	}
+
+	tracked := sets.NewString()
+	var gvrs []schema.GroupVersionResource
+	seen := map[schema.GroupVersionResource]bool{}
+	for _, resource := range appliedManifestWork.Status.AppliedResources {
+		tracked.Insert(helper.ResourceIndexKey(resource.Group, resource.Resource, resource.Namespace, resource.Name))
+		gvr := schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Resource}
+		if !seen[gvr] {
+			seen[gvr] = true
+			gvrs = append(gvrs, gvr)
+		}
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{constants.ManifestWorkLabelKey: manifestWorkName}).String()
+	var untracked []workapiv1.AppliedManifestResourceMeta
+	var errs []error
+	for _, gvr := range gvrs {
+		list, err := m.spokeDynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		switch {
+		case errors.IsNotFound(err), errors.IsForbidden(err), errors.IsMethodNotSupported(err):
+			continue
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to list %v: %w", gvr, err))
+			continue
+		}
+
+		for _, obj := range list.Items {
+			if tracked.Has(helper.ResourceIndexKey(gvr.Group, gvr.Resource, obj.GetNamespace(), obj.GetName())) {
+				continue
+			}
+			for _, ownerRef := range obj.GetOwnerReferences() {
+				if ownerRef.UID != appliedManifestWork.UID {
+					continue
+				}
+				untracked = append(untracked, workapiv1.AppliedManifestResourceMeta{
+					Group:     gvr.Group,
+					Version:   gvr.Version,
+					Resource:  gvr.Resource,
+					Namespace: obj.GetNamespace(),
+					Name:      obj.GetName(),
+					UID:       string(obj.GetUID()),
+				})
+				break
+			}
+		}
+	}
+	return untracked, utilerrors.NewAggregate(errs)
+}
+
+// resourceKeys returns the keys of the resources for logging, e.g. apps/deployments/ns1/test.
+func resourceKeys(resources []workapiv1.AppliedManifestResourceMeta) []string {
+	keys := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		keys = append(keys, helper.ResourceIndexKey(resource.Group, resource.Resource, resource.Namespace, resource.Name))
+	}
+	return keys
+}
+
+// removeFinalizer removes the finalizer of the agent from the appliedmanifestwork with a json patch, which tests
+// the finalizer at its index before removing it, so the finalizers added or removed by other writers are kept. The
+// patch is retried with the latest appliedmanifestwork if the finalizers are changed in the meantime.
+func (m *AppliedManifestWorkFinalizeController) removeFinalizer(
+	ctx context.Context, appliedManifestWork *workapiv1.AppliedManifestWork) error {
+	first := true
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if !first {
+			latest, err := m.appliedManifestWorkClient.Get(ctx, appliedManifestWork.Name, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			appliedManifestWork = latest
+		}
+		first = false
+
+		patch, err := helper.RemoveFinalizerPatch(appliedManifestWork.Finalizers, constants.AppliedManifestWorkFinalizer)
+		if err != nil || patch == nil {
+			return err
+		}
+
+		_, err = m.appliedManifestWorkClient.Patch(ctx, appliedManifestWork.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			return nil
+		case errors.IsInvalid(err):
+			// the test operation fails if the finalizers are changed, retry with the latest appliedmanifestwork
+			return errors.NewConflict(workapiv1.Resource("appliedmanifestworks"), appliedManifestWork.Name, err)
+		}
+		return err
+	})
+}
+
+// heartbeat records the agent id and the current time on the appliedmanifestwork if it belongs to the current hub
+// and its heartbeat is expired, and requeues the appliedmanifestwork for the next heartbeat.
+func (m *AppliedManifestWorkFinalizeController) heartbeat(
+	ctx context.Context, controllerContext factory.SyncContext, appliedManifestWork *workapiv1.AppliedManifestWork) error {
+	if len(m.agentID) == 0 || appliedManifestWork.Spec.HubHash != m.hubHash {
+		return nil
+	}
+
+	annotations := appliedManifestWork.Annotations
+	if lastHeartbeat, err := time.Parse(time.RFC3339, annotations[constants.HeartbeatAnnotationKey]); err == nil &&
+		annotations[constants.AgentIDAnnotationKey] == m.agentID {
+		if elapsed := time.Since(lastHeartbeat); elapsed < HeartbeatInterval {
+			controllerContext.Queue().AddAfter(appliedManifestWork.Name, HeartbeatInterval-elapsed)
+			return nil
+		}
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				constants.AgentIDAnnotationKey:   m.agentID,
+				constants.HeartbeatAnnotationKey: time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.appliedManifestWorkClient.Patch(ctx, appliedManifestWork.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("Failed to update heartbeat of AppliedManifestWork %s: %w", appliedManifestWork.Name, err)
+	}
+
+	controllerContext.Queue().AddAfter(appliedManifestWork.Name, HeartbeatInterval)
+	return nil
+}
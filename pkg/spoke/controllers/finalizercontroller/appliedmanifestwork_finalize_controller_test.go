@@ -2,12 +2,16 @@ package finalizercontroller
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -16,8 +20,8 @@ import (
 	"k8s.io/client-go/util/workqueue"
 	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
 	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
 	"open-cluster-management.io/work/pkg/helper"
-	"open-cluster-management.io/work/pkg/spoke/controllers"
 	"open-cluster-management.io/work/pkg/spoke/spoketesting"
 )
 
@@ -38,7 +42,7 @@ func TestFinalize(t *testing.T) {
 	}{
 		{
 			name:                               "skip when not delete",
-			existingFinalizers:                 []string{controllers.ManifestWorkFinalizer},
+			existingFinalizers:                 []string{constants.ManifestWorkFinalizer},
 			validateAppliedManifestWorkActions: noAction,
 			validateDynamicActions:             noAction,
 		},
@@ -52,7 +56,7 @@ func TestFinalize(t *testing.T) {
 		{
 			name:               "delete resources and remove finalizer",
 			terminated:         true,
-			existingFinalizers: []string{"a", controllers.AppliedManifestWorkFinalizer, "b"},
+			existingFinalizers: []string{"a", constants.AppliedManifestWorkFinalizer, "b"},
 			resourcesToRemove: []workapiv1.AppliedManifestResourceMeta{
 				{Group: "g1", Version: "v1", Resource: "r1", Namespace: "", Name: "n1"},
 				{Group: "g2", Version: "v2", Resource: "r2", Namespace: "ns2", Name: "n2"},
@@ -68,10 +72,7 @@ func TestFinalize(t *testing.T) {
 				if len(work.Status.AppliedResources) != 0 {
 					t.Fatal(spew.Sdump(actions[0]))
 				}
-				work = actions[1].(clienttesting.UpdateAction).GetObject().(*workapiv1.AppliedManifestWork)
-				if !reflect.DeepEqual(work.Finalizers, []string{"a", "b"}) {
-					t.Fatal(spew.Sdump(actions[1]))
-				}
+				assertRemoveFinalizerPatch(t, actions[1], 1)
 			},
 			validateDynamicActions: func(t *testing.T, actions []clienttesting.Action) {
 				if len(actions) != 4 {
@@ -103,7 +104,7 @@ func TestFinalize(t *testing.T) {
 		{
 			name:               "requeue work when deleting resources are still visiable",
 			terminated:         true,
-			existingFinalizers: []string{controllers.AppliedManifestWorkFinalizer},
+			existingFinalizers: []string{constants.AppliedManifestWorkFinalizer},
 			existingResources: []runtime.Object{
 				spoketesting.NewUnstructuredSecret("ns1", "n1", true, "ns1-n1", *owner),
 				spoketesting.NewUnstructuredSecret("ns2", "n2", true, "ns2-n2", *owner),
@@ -134,7 +135,7 @@ func TestFinalize(t *testing.T) {
 		{
 			name:               "ignore re-created resource and remove finalizer",
 			terminated:         true,
-			existingFinalizers: []string{controllers.AppliedManifestWorkFinalizer},
+			existingFinalizers: []string{constants.AppliedManifestWorkFinalizer},
 			existingResources: []runtime.Object{
 				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1", *owner),
 			},
@@ -152,10 +153,7 @@ func TestFinalize(t *testing.T) {
 					t.Fatal(spew.Sdump(actions[0]))
 				}
 
-				work = actions[1].(clienttesting.UpdateAction).GetObject().(*workapiv1.AppliedManifestWork)
-				if !reflect.DeepEqual(work.Finalizers, []string{}) {
-					t.Fatal(spew.Sdump(actions[0]))
-				}
+				assertRemoveFinalizerPatch(t, actions[1], 0)
 			},
 			validateDynamicActions: func(t *testing.T, actions []clienttesting.Action) {
 				if len(actions) != 2 {
@@ -213,8 +211,484 @@ func TestFinalize(t *testing.T) {
 	}
 }
 
+func TestRemoveFinalizer(t *testing.T) {
+	cases := []struct {
+		name string
+		// finalizers of the appliedmanifestwork in the cache and on the apiserver
+		cachedFinalizers   []string
+		existingFinalizers []string
+		expectedPatches    int
+		expectedFinalizers []string
+	}{
+		{
+			name:               "remove finalizer",
+			cachedFinalizers:   []string{"a", constants.AppliedManifestWorkFinalizer},
+			existingFinalizers: []string{"a", constants.AppliedManifestWorkFinalizer},
+			expectedPatches:    1,
+			expectedFinalizers: []string{"a"},
+		},
+		{
+			name:               "finalizer added concurrently",
+			cachedFinalizers:   []string{"a", constants.AppliedManifestWorkFinalizer},
+			existingFinalizers: []string{"b", "a", constants.AppliedManifestWorkFinalizer},
+			expectedPatches:    2,
+			expectedFinalizers: []string{"b", "a"},
+		},
+		{
+			name:               "finalizer removed concurrently",
+			cachedFinalizers:   []string{"a", constants.AppliedManifestWorkFinalizer, "b"},
+			existingFinalizers: []string{constants.AppliedManifestWorkFinalizer, "b"},
+			expectedPatches:    2,
+			expectedFinalizers: []string{"b"},
+		},
+		{
+			name:               "finalizer removed by others",
+			cachedFinalizers:   []string{constants.AppliedManifestWorkFinalizer},
+			existingFinalizers: []string{"a"},
+			expectedPatches:    1,
+			expectedFinalizers: []string{"a"},
+		},
+		{
+			name:               "finalizer not present",
+			cachedFinalizers:   []string{"a"},
+			existingFinalizers: []string{"a"},
+			expectedFinalizers: []string{"a"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			existingWork := spoketesting.NewAppliedManifestWork("test", 0, "test")
+			existingWork.Finalizers = c.existingFinalizers
+			cachedWork := existingWork.DeepCopy()
+			cachedWork.Finalizers = c.cachedFinalizers
+
+			fakeClient := fakeworkclient.NewSimpleClientset(existingWork)
+			// the object tracker does not return a status error if the test operation fails
+			fakeClient.PrependReactor("patch", "appliedmanifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				patchAction := action.(clienttesting.PatchAction)
+				work, err := fakeClient.Tracker().Get(workapiv1.Resource("appliedmanifestworks").WithVersion("v1"), "", patchAction.GetName())
+				if err != nil {
+					return true, nil, err
+				}
+				var operations []map[string]interface{}
+				if err := json.Unmarshal(patchAction.GetPatch(), &operations); err != nil {
+					return true, nil, err
+				}
+				var index int
+				fmt.Sscanf(operations[0]["path"].(string), "/metadata/finalizers/%d", &index)
+				finalizers := work.(*workapiv1.AppliedManifestWork).Finalizers
+				if index >= len(finalizers) || finalizers[index] != operations[0]["value"] {
+					return true, nil, errors.NewInvalid(workapiv1.GroupVersion.WithKind("AppliedManifestWork").GroupKind(), patchAction.GetName(), nil)
+				}
+				return false, nil, nil
+			})
+
+			controller := AppliedManifestWorkFinalizeController{
+				appliedManifestWorkClient: fakeClient.WorkV1().AppliedManifestWorks(),
+			}
+			if err := controller.removeFinalizer(context.TODO(), cachedWork); err != nil {
+				t.Fatal(err)
+			}
+
+			patches := 0
+			for _, action := range fakeClient.Actions() {
+				if action.GetVerb() == "patch" {
+					patches++
+				}
+			}
+			if patches != c.expectedPatches {
+				t.Errorf("expected %d patches, but got %s", c.expectedPatches, spew.Sdump(fakeClient.Actions()))
+			}
+
+			work, err := fakeClient.WorkV1().AppliedManifestWorks().Get(context.TODO(), existingWork.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(work.Finalizers, c.expectedFinalizers) {
+				t.Errorf("expected finalizers %v, but got %v", c.expectedFinalizers, work.Finalizers)
+			}
+		})
+	}
+}
+
+func assertRemoveFinalizerPatch(t *testing.T, action clienttesting.Action, index int) {
+	patchAction, ok := action.(clienttesting.PatchAction)
+	if !ok || patchAction.GetPatchType() != types.JSONPatchType {
+		t.Fatal(spew.Sdump(action))
+	}
+	expected := fmt.Sprintf(`[{"op":"test","path":"/metadata/finalizers/%d","value":%q},{"op":"remove","path":"/metadata/finalizers/%d"}]`,
+		index, constants.AppliedManifestWorkFinalizer, index)
+	if string(patchAction.GetPatch()) != expected {
+		t.Errorf("expected patch %s, but got %s", expected, patchAction.GetPatch())
+	}
+}
+
 func noAction(t *testing.T, actions []clienttesting.Action) {
 	if len(actions) > 0 {
 		t.Fatal(spew.Sdump(actions))
 	}
 }
+
+func TestHeartbeat(t *testing.T) {
+	cases := []struct {
+		name            string
+		annotations     map[string]string
+		hubHash         string
+		validateActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:            "skip appliedmanifestwork of other hub",
+			hubHash:         "other",
+			validateActions: noAction,
+		},
+		{
+			name:    "record heartbeat",
+			hubHash: "test",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+				spoketesting.AssertAction(t, actions[0], "patch")
+			},
+		},
+		{
+			name:    "skip fresh heartbeat",
+			hubHash: "test",
+			annotations: map[string]string{
+				constants.AgentIDAnnotationKey:   "agent1",
+				constants.HeartbeatAnnotationKey: time.Now().UTC().Format(time.RFC3339),
+			},
+			validateActions: noAction,
+		},
+		{
+			name:    "refresh heartbeat recorded by other agent",
+			hubHash: "test",
+			annotations: map[string]string{
+				constants.AgentIDAnnotationKey:   "agent2",
+				constants.HeartbeatAnnotationKey: time.Now().UTC().Format(time.RFC3339),
+			},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+				spoketesting.AssertAction(t, actions[0], "patch")
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			testingWork := spoketesting.NewAppliedManifestWork("test", 0, types.UID("test"))
+			testingWork.Annotations = c.annotations
+
+			fakeClient := fakeworkclient.NewSimpleClientset(testingWork)
+			controller := AppliedManifestWorkFinalizeController{
+				appliedManifestWorkClient: fakeClient.WorkV1().AppliedManifestWorks(),
+				rateLimiter:               workqueue.NewItemExponentialFailureRateLimiter(0, 1*time.Second),
+				hubHash:                   c.hubHash,
+				agentID:                   "agent1",
+			}
+
+			controllerContext := spoketesting.NewFakeSyncContext(t, testingWork.Name)
+			if err := controller.syncAppliedManifestWork(context.TODO(), controllerContext, testingWork); err != nil {
+				t.Fatal(err)
+			}
+			c.validateActions(t, fakeClient.Actions())
+		})
+	}
+}
+
+func TestFinalizeWithCRDDependents(t *testing.T) {
+	appliedWork := spoketesting.NewAppliedManifestWork("test", 0, types.UID("test"))
+	owner := helper.NewAppliedManifestWorkOwner(appliedWork)
+	otherWork := spoketesting.NewAppliedManifestWork("test", 1, types.UID("other"))
+	otherOwner := helper.NewAppliedManifestWorkOwner(otherWork)
+
+	crGVR := schema.GroupVersionResource{Group: "my.domain", Version: "v1", Resource: "guestbooks"}
+	newCRD := func() *unstructured.Unstructured {
+		crd := spoketesting.NewUnstructuredWithContent("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "guestbooks.my.domain",
+			map[string]interface{}{
+				"spec": map[string]interface{}{
+					"group": "my.domain",
+					"names": map[string]interface{}{"plural": "guestbooks"},
+					"versions": []interface{}{
+						map[string]interface{}{"name": "v1beta1", "storage": false},
+						map[string]interface{}{"name": "v1", "storage": true},
+					},
+				},
+			})
+		crd.SetUID("crd")
+		crd.SetOwnerReferences([]metav1.OwnerReference{*owner})
+		return crd
+	}
+	crdMeta := workapiv1.AppliedManifestResourceMeta{
+		Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions", Name: "guestbooks.my.domain", UID: "crd"}
+
+	cases := []struct {
+		name                string
+		annotations         map[string]string
+		existingCRs         []runtime.Object
+		expectedCRDDeleted  bool
+		expectedBlocked     string
+		expectedWorkPatches int
+	}{
+		{
+			name:               "delete crd without custom resources",
+			expectedCRDDeleted: true,
+		},
+		{
+			name: "delete crd with custom resources owned by the same work",
+			existingCRs: []runtime.Object{
+				spoketesting.NewUnstructured("my.domain/v1", "Guestbook", "ns1", "cr1", *owner),
+			},
+			expectedCRDDeleted: true,
+		},
+		{
+			name: "hold crd with custom resources owned by other works",
+			existingCRs: []runtime.Object{
+				spoketesting.NewUnstructured("my.domain/v1", "Guestbook", "ns1", "cr1", *otherOwner),
+				spoketesting.NewUnstructured("my.domain/v1", "Guestbook", "ns2", "cr2", *otherOwner),
+			},
+			expectedBlocked:     fmt.Sprintf("guestbooks.my.domain is used by %s", otherWork.Name),
+			expectedWorkPatches: 1,
+		},
+		{
+			name:        "skip recording the blocked deletion again",
+			annotations: map[string]string{constants.DeletionBlockedByDependentsAnnotationKey: fmt.Sprintf("guestbooks.my.domain is used by %s", otherWork.Name)},
+			existingCRs: []runtime.Object{
+				spoketesting.NewUnstructured("my.domain/v1", "Guestbook", "ns1", "cr1", *otherOwner),
+			},
+			expectedBlocked: fmt.Sprintf("guestbooks.my.domain is used by %s", otherWork.Name),
+		},
+		{
+			name:        "force to delete crd",
+			annotations: map[string]string{constants.ForceDeletionAnnotationKey: "true"},
+			existingCRs: []runtime.Object{
+				spoketesting.NewUnstructured("my.domain/v1", "Guestbook", "ns1", "cr1", *otherOwner),
+			},
+			expectedCRDDeleted: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			testingWork := appliedWork.DeepCopy()
+			testingWork.Annotations = c.annotations
+			testingWork.Finalizers = []string{constants.AppliedManifestWorkFinalizer}
+			now := metav1.Now()
+			testingWork.DeletionTimestamp = &now
+			testingWork.Status.AppliedResources = []workapiv1.AppliedManifestResourceMeta{crdMeta}
+
+			objects := append([]runtime.Object{newCRD()}, c.existingCRs...)
+			fakeDynamicClient := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(
+				runtime.NewScheme(), map[schema.GroupVersionResource]string{crGVR: "GuestbookList"}, objects...)
+			fakeClient := fakeworkclient.NewSimpleClientset(testingWork)
+			controller := AppliedManifestWorkFinalizeController{
+				appliedManifestWorkClient: fakeClient.WorkV1().AppliedManifestWorks(),
+				spokeDynamicClient:        fakeDynamicClient,
+				rateLimiter:               workqueue.NewItemExponentialFailureRateLimiter(0, 1*time.Second),
+			}
+
+			controllerContext := spoketesting.NewFakeSyncContext(t, testingWork.Name)
+			if err := controller.syncAppliedManifestWork(context.TODO(), controllerContext, testingWork); err != nil {
+				t.Fatal(err)
+			}
+
+			crdDeleted := false
+			for _, action := range fakeDynamicClient.Actions() {
+				if action.GetVerb() == "delete" && action.GetResource().Resource == "customresourcedefinitions" {
+					crdDeleted = true
+				}
+			}
+			if crdDeleted != c.expectedCRDDeleted {
+				t.Errorf("expected crd deleted %t, but got %s", c.expectedCRDDeleted, spew.Sdump(fakeDynamicClient.Actions()))
+			}
+
+			work, err := fakeClient.WorkV1().AppliedManifestWorks().Get(context.TODO(), testingWork.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if blocked := work.Annotations[constants.DeletionBlockedByDependentsAnnotationKey]; blocked != c.expectedBlocked {
+				t.Errorf("expected blocked deletion %q, but got %q", c.expectedBlocked, blocked)
+			}
+
+			if !c.expectedCRDDeleted && len(work.Status.AppliedResources) != 1 {
+				t.Errorf("expected the held crd is kept in the applied resources, but got %v", work.Status.AppliedResources)
+			}
+
+			patches := 0
+			for _, action := range fakeClient.Actions() {
+				if action.GetVerb() == "patch" && action.(clienttesting.PatchAction).GetPatchType() == types.MergePatchType {
+					patches++
+				}
+			}
+			if patches != c.expectedWorkPatches {
+				t.Errorf("expected %d patches, but got %s", c.expectedWorkPatches, spew.Sdump(fakeClient.Actions()))
+			}
+		})
+	}
+}
+
+func TestFinalizeDeniedByWebhook(t *testing.T) {
+	appliedWork := spoketesting.NewAppliedManifestWork("test", 0, types.UID("test"))
+	owner := helper.NewAppliedManifestWorkOwner(appliedWork)
+	denied := `[webhook=deny.policy.io] Failed to delete 1 of 2 applied resources: the secret is protected`
+
+	cases := []struct {
+		name           string
+		annotations    map[string]string
+		deniedResource string
+		expectedErr    bool
+		expectedDenied string
+	}{
+		{
+			name:           "record the denial of the webhook",
+			deniedResource: "n1",
+			expectedErr:    true,
+			expectedDenied: denied,
+		},
+		{
+			name:           "remove the denial once the resources are deleted",
+			annotations:    map[string]string{constants.DeletionDeniedByWebhookAnnotationKey: denied},
+			expectedDenied: "",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			testingWork := appliedWork.DeepCopy()
+			testingWork.Annotations = c.annotations
+			testingWork.Finalizers = []string{constants.AppliedManifestWorkFinalizer}
+			now := metav1.Now()
+			testingWork.DeletionTimestamp = &now
+			testingWork.Status.AppliedResources = []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n1", UID: "n1"},
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "n2", UID: "n2"},
+			}
+
+			fakeDynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(),
+				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "n1", *owner),
+				spoketesting.NewUnstructuredSecret("ns1", "n2", false, "n2", *owner))
+			fakeDynamicClient.PrependReactor("delete", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				if action.(clienttesting.DeleteAction).GetName() != c.deniedResource {
+					return false, nil, nil
+				}
+				return true, nil, &errors.StatusError{ErrStatus: metav1.Status{
+					Status:  metav1.StatusFailure,
+					Code:    403,
+					Reason:  metav1.StatusReasonForbidden,
+					Message: `admission webhook "deny.policy.io" denied the request: the secret is protected`,
+				}}
+			})
+			fakeClient := fakeworkclient.NewSimpleClientset(testingWork)
+			controller := AppliedManifestWorkFinalizeController{
+				appliedManifestWorkClient: fakeClient.WorkV1().AppliedManifestWorks(),
+				spokeDynamicClient:        fakeDynamicClient,
+				rateLimiter:               workqueue.NewItemExponentialFailureRateLimiter(0, 1*time.Second),
+			}
+
+			controllerContext := spoketesting.NewFakeSyncContext(t, testingWork.Name)
+			err := controller.syncAppliedManifestWork(context.TODO(), controllerContext, testingWork)
+			if (err != nil) != c.expectedErr {
+				t.Errorf("expected error %t, but got %v", c.expectedErr, err)
+			}
+
+			work, err := fakeClient.WorkV1().AppliedManifestWorks().Get(context.TODO(), testingWork.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if denied := work.Annotations[constants.DeletionDeniedByWebhookAnnotationKey]; denied != c.expectedDenied {
+				t.Errorf("expected denied deletion %q, but got %q", c.expectedDenied, denied)
+			}
+		})
+	}
+}
+
+func TestFinalizeCorruptedAppliedManifestWork(t *testing.T) {
+	uid := types.UID("test")
+	hubHash := helper.HubHash("https://hub.example.com")
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	owner := metav1.OwnerReference{APIVersion: "work.open-cluster-management.io/v1", Kind: "AppliedManifestWork", Name: hubHash + "-work-0", UID: uid}
+	otherOwner := metav1.OwnerReference{APIVersion: "work.open-cluster-management.io/v1", Kind: "AppliedManifestWork", Name: hubHash + "-work-1", UID: "other"}
+	newSecret := func(name string, owner metav1.OwnerReference) *unstructured.Unstructured {
+		secret := spoketesting.NewUnstructured("v1", "Secret", "ns1", name, owner)
+		secret.SetUID(types.UID(name))
+		secret.SetLabels(map[string]string{constants.ManifestWorkLabelKey: "work-0"})
+		return secret
+	}
+
+	cases := []struct {
+		name              string
+		corruptSpec       bool
+		discoverUntracked bool
+		expectedDeleted   []string
+	}{
+		{
+			name:              "delete untracked resources",
+			discoverUntracked: true,
+			expectedDeleted:   []string{"tracked", "untracked"},
+		},
+		{
+			name:              "delete untracked resources with the spec repaired from the name",
+			corruptSpec:       true,
+			discoverUntracked: true,
+			expectedDeleted:   []string{"tracked", "untracked"},
+		},
+		{
+			name:            "delete tracked resources with the spec corrupted",
+			corruptSpec:     true,
+			expectedDeleted: []string{"tracked"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			appliedWork := spoketesting.NewAppliedManifestWork(hubHash, 0, uid)
+			appliedWork.Finalizers = []string{constants.AppliedManifestWorkFinalizer}
+			now := metav1.Now()
+			appliedWork.DeletionTimestamp = &now
+			appliedWork.Status.AppliedResources = []workapiv1.AppliedManifestResourceMeta{
+				{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "tracked", UID: "tracked"},
+			}
+			if c.corruptSpec {
+				appliedWork.Spec = workapiv1.AppliedManifestWorkSpec{}
+			}
+
+			fakeDynamicClient := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{gvr: "SecretList"},
+				newSecret("tracked", owner), newSecret("untracked", owner), newSecret("other", otherOwner))
+			fakeClient := fakeworkclient.NewSimpleClientset(appliedWork)
+			controller := AppliedManifestWorkFinalizeController{
+				appliedManifestWorkClient: fakeClient.WorkV1().AppliedManifestWorks(),
+				spokeDynamicClient:        fakeDynamicClient,
+				rateLimiter:               workqueue.NewItemExponentialFailureRateLimiter(0, 1*time.Second),
+				discoverUntracked:         c.discoverUntracked,
+			}
+
+			controllerContext := spoketesting.NewFakeSyncContext(t, appliedWork.Name)
+			if err := controller.syncAppliedManifestWork(context.TODO(), controllerContext, appliedWork); err != nil {
+				t.Fatal(err)
+			}
+
+			var deleted []string
+			for _, action := range fakeDynamicClient.Actions() {
+				if action.GetVerb() == "delete" {
+					deleted = append(deleted, action.(clienttesting.DeleteAction).GetName())
+				}
+			}
+			if !reflect.DeepEqual(deleted, c.expectedDeleted) {
+				t.Errorf("expected %v deleted, but got %v", c.expectedDeleted, deleted)
+			}
+
+			// the resources pending finalization are tracked until they are deleted
+			updated, err := fakeClient.WorkV1().AppliedManifestWorks().Get(context.TODO(), appliedWork.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(updated.Status.AppliedResources) != len(c.expectedDeleted) {
+				t.Errorf("expected %d resources pending finalization, but got %v", len(c.expectedDeleted), updated.Status.AppliedResources)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package finalizercontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+const (
+	crdGroup    = "apiextensions.k8s.io"
+	crdResource = "customresourcedefinitions"
+)
+
+// holdCRDsWithDependents splits the applied resources into the resources to delete and the crds whose deletion is
+// held, because the apiserver would delete their custom resources owned by other appliedmanifestworks as well. It
+// also returns a message naming the appliedmanifestworks which block the deletion, empty if nothing is held.
+func (m *AppliedManifestWorkFinalizeController) holdCRDsWithDependents(
+	ctx context.Context,
+	resources []workapiv1.AppliedManifestResourceMeta,
+	owner metav1.OwnerReference) ([]workapiv1.AppliedManifestResourceMeta, []workapiv1.AppliedManifestResourceMeta, string, error) {
+	var toDelete, held []workapiv1.AppliedManifestResourceMeta
+	var messages []string
+
+	for _, resource := range resources {
+		if resource.Group != crdGroup || resource.Resource != crdResource {
+			toDelete = append(toDelete, resource)
+			continue
+		}
+
+		dependents, err := m.crdDependents(ctx, resource, owner)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		if len(dependents) == 0 {
+			toDelete = append(toDelete, resource)
+			continue
+		}
+
+		held = append(held, resource)
+		messages = append(messages, fmt.Sprintf("%s is used by %s", resource.Name, strings.Join(dependents, ", ")))
+	}
+
+	return toDelete, held, strings.Join(messages, "; "), nil
+}
+
+// crdDependents returns the names of the other appliedmanifestworks owning custom resources of the crd, which
+// are deleted by the apiserver once the crd is deleted. Nothing is returned if the crd is not going to be deleted
+// by the appliedmanifestwork.
+func (m *AppliedManifestWorkFinalizeController) crdDependents(
+	ctx context.Context,
+	resource workapiv1.AppliedManifestResourceMeta,
+	owner metav1.OwnerReference) ([]string, error) {
+	gvr := schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Resource}
+	crd, err := m.spokeDynamicClient.Resource(gvr).Get(ctx, resource.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get resource %v with key %s: %w", gvr, resource.Name, err)
+	}
+
+	// the crd is kept if it is not owned by the appliedmanifestwork only, or it is recreated by others
+	owners := crd.GetOwnerReferences()
+	if !helper.IsOwnedBy(owner, owners) || len(owners) > 1 || string(crd.GetUID()) != resource.UID {
+		return nil, nil
+	}
+	if crd.GetDeletionTimestamp() != nil && !crd.GetDeletionTimestamp().IsZero() {
+		return nil, nil
+	}
+
+	crGVR, ok := customResourceGVR(crd)
+	if !ok {
+		return nil, nil
+	}
+	crs, err := m.spokeDynamicClient.Resource(crGVR).List(ctx, metav1.ListOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return nil, nil
+	case errors.IsForbidden(err):
+		// do not hold the deletion forever if the agent is not allowed to check the custom resources
+		klog.Warningf("Unable to check the dependents of crd %s: %v", resource.Name, err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list resource %v: %w", crGVR, err)
+	}
+
+	dependents := sets.NewString()
+	for _, cr := range crs.Items {
+		for _, crOwner := range cr.GetOwnerReferences() {
+			if crOwner.APIVersion != owner.APIVersion || crOwner.Kind != owner.Kind || crOwner.UID == owner.UID {
+				continue
+			}
+			dependents.Insert(crOwner.Name)
+		}
+	}
+	return dependents.List(), nil
+}
+
+// customResourceGVR returns the gvr of the custom resources defined by the crd with its storage version.
+func customResourceGVR(crd *unstructured.Unstructured) (schema.GroupVersionResource, bool) {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	// the version field is only defined by the crds of apiextensions.k8s.io/v1beta1
+	version, _, _ := unstructured.NestedString(crd.Object, "spec", "version")
+
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		v, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if storage, _, _ := unstructured.NestedBool(v, "storage"); storage || len(version) == 0 {
+			version, _, _ = unstructured.NestedString(v, "name")
+		}
+	}
+
+	if len(group) == 0 || len(plural) == 0 || len(version) == 0 {
+		return schema.GroupVersionResource{}, false
+	}
+	return schema.GroupVersionResource{Group: group, Version: version, Resource: plural}, true
+}
+
+// updateDeletionBlocked records the message naming the appliedmanifestworks which block the deletion of the crds
+// on the appliedmanifestwork, the record is removed once the message is empty.
+func (m *AppliedManifestWorkFinalizeController) updateDeletionBlocked(
+	ctx context.Context, appliedManifestWork *workapiv1.AppliedManifestWork, message string) (bool, error) {
+	return m.updateAnnotation(ctx, appliedManifestWork, constants.DeletionBlockedByDependentsAnnotationKey, message, "blocked deletion")
+}
+
+// sortAppliedResources keeps the applied resources in the order they are recorded in the appliedmanifestwork.
+func sortAppliedResources(resources, recorded []workapiv1.AppliedManifestResourceMeta) {
+	index := map[workapiv1.AppliedManifestResourceMeta]int{}
+	for i, resource := range recorded {
+		index[resource] = i
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		return index[resources[i]] < index[resources[j]]
+	})
+}
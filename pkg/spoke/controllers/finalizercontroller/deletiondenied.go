@@ -0,0 +1,76 @@
+package finalizercontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+// deletionDeniedMessage returns the message of the denial of the deletion of the applied resources by an admission
+// webhook, empty if none of the errors of the deletion is a webhook denial. The first denial is reported.
+func deletionDeniedMessage(errs []error, total int) string {
+	denied := 0
+	var first helper.AdmissionWebhookDenial
+	for _, err := range errs {
+		denial, ok := helper.AdmissionWebhookDenialOf(err)
+		if !ok {
+			continue
+		}
+		if denied == 0 {
+			first = denial
+		}
+		denied++
+	}
+	if denied == 0 {
+		return ""
+	}
+	return helper.FormatAdmissionWebhookDenial(fmt.Sprintf("Failed to delete %d of %d applied resources", denied, total), first)
+}
+
+// updateDeletionDenied records the denial of the deletion of the applied resources by an admission webhook on the
+// appliedmanifestwork, the record is removed once the message is empty.
+func (m *AppliedManifestWorkFinalizeController) updateDeletionDenied(
+	ctx context.Context, appliedManifestWork *workapiv1.AppliedManifestWork, message string) (bool, error) {
+	return m.updateAnnotation(ctx, appliedManifestWork, constants.DeletionDeniedByWebhookAnnotationKey, message, "denied deletion")
+}
+
+// updateAnnotation sets the annotation on the appliedmanifestwork with a merge patch, the annotation is removed if the
+// value is empty. It returns true if the appliedmanifestwork is patched, the description of the annotation is used in
+// the error message.
+func (m *AppliedManifestWorkFinalizeController) updateAnnotation(
+	ctx context.Context, appliedManifestWork *workapiv1.AppliedManifestWork, key, value, description string) (bool, error) {
+	if appliedManifestWork.Annotations[key] == value {
+		return false, nil
+	}
+
+	var annotation interface{}
+	if len(value) > 0 {
+		annotation = value
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				key: annotation,
+			},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	_, err = m.appliedManifestWorkClient.Patch(ctx, appliedManifestWork.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Failed to update the %s of AppliedManifestWork %s: %w", description, appliedManifestWork.Name, err)
+	}
+	return true, nil
+}
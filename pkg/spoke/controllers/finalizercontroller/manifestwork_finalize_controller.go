@@ -11,27 +11,34 @@ import (
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
 	workinformer "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
 	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
 	"open-cluster-management.io/work/pkg/helper"
 	"open-cluster-management.io/work/pkg/spoke/controllers"
 )
 
 // ManifestWorkFinalizeController handles cleanup of manifestwork resources before deletion is allowed.
 type ManifestWorkFinalizeController struct {
-	manifestWorkClient        workv1client.ManifestWorkInterface
-	manifestWorkLister        worklister.ManifestWorkNamespaceLister
-	appliedManifestWorkClient workv1client.AppliedManifestWorkInterface
-	appliedManifestWorkLister worklister.AppliedManifestWorkLister
-	hubHash                   string
-	rateLimiter               workqueue.RateLimiter
+	manifestWorkClient         workv1client.ManifestWorkInterface
+	manifestWorkLister         worklister.ManifestWorkNamespaceLister
+	appliedManifestWorkClient  workv1client.AppliedManifestWorkInterface
+	appliedManifestWorkIndexer cache.Indexer
+	spokeDynamicClient         dynamic.Interface
+	hubHash                    string
+	rateLimiter                workqueue.RateLimiter
 }
 
 func NewManifestWorkFinalizeController(
 	recorder events.Recorder,
+	spokeDynamicClient dynamic.Interface,
 	manifestWorkClient workv1client.ManifestWorkInterface,
 	manifestWorkInformer workinformer.ManifestWorkInformer,
 	manifestWorkLister worklister.ManifestWorkNamespaceLister,
@@ -41,12 +48,13 @@ func NewManifestWorkFinalizeController(
 ) factory.Controller {
 
 	controller := &ManifestWorkFinalizeController{
-		manifestWorkClient:        manifestWorkClient,
-		manifestWorkLister:        manifestWorkLister,
-		appliedManifestWorkClient: appliedManifestWorkClient,
-		appliedManifestWorkLister: appliedManifestWorkInformer.Lister(),
-		hubHash:                   hubHash,
-		rateLimiter:               workqueue.NewItemExponentialFailureRateLimiter(5*time.Millisecond, 1000*time.Second),
+		manifestWorkClient:         manifestWorkClient,
+		manifestWorkLister:         manifestWorkLister,
+		appliedManifestWorkClient:  appliedManifestWorkClient,
+		appliedManifestWorkIndexer: appliedManifestWorkInformer.Informer().GetIndexer(),
+		spokeDynamicClient:         spokeDynamicClient,
+		hubHash:                    hubHash,
+		rateLimiter:                workqueue.NewItemExponentialFailureRateLimiter(5*time.Millisecond, 1000*time.Second),
 	}
 
 	return factory.New().
@@ -55,27 +63,34 @@ func NewManifestWorkFinalizeController(
 			return accessor.GetName()
 		}, manifestWorkInformer.Informer()).
 		WithInformersQueueKeyFunc(helper.AppliedManifestworkQueueKeyFunc(hubHash), appliedManifestWorkInformer.Informer()).
-		WithSync(controller.sync).ToController("ManifestWorkFinalizer", recorder)
+		WithSync(controllers.InstrumentSync(hubHash, "ManifestWorkFinalizer", controller.sync)).ToController("ManifestWorkFinalizer", recorder)
 }
 
 func (m *ManifestWorkFinalizeController) sync(ctx context.Context, controllerContext factory.SyncContext) error {
 	manifestWorkName := controllerContext.QueueKey()
-	appliedManifestWorkName := fmt.Sprintf("%s-%s", m.hubHash, manifestWorkName)
+	if name, ok := helper.MalformedAppliedManifestWorkName(manifestWorkName); ok {
+		klog.Warningf("Ignore appliedmanifestwork %q, its name is not the hub hash and a manifestwork name joined with \"-\"", name)
+		return nil
+	}
 	klog.V(4).Infof("Reconciling ManifestWork %q", manifestWorkName)
+	recorder := helper.NewWorkEventRecorder(controllerContext.Recorder(), m.hubHash, manifestWorkName)
 
 	manifestWork, err := m.manifestWorkLister.Get(manifestWorkName)
 
 	// Delete appliedmanifestwork if relating manfiestwork is not found or being deleted
 	switch {
 	case errors.IsNotFound(err):
-		err := m.deleteAppliedManifestWork(ctx, appliedManifestWorkName)
+		err := m.deleteAppliedManifestWork(ctx, recorder, nil, manifestWorkName)
 		if err != nil {
 			return err
 		}
 	case err != nil:
 		return err
+	case !manifestWork.DeletionTimestamp.IsZero() && helper.IsWorkDeletionPaused(manifestWork):
+		klog.V(4).Infof("The deletion of ManifestWork %q is paused", manifestWorkName)
+		return nil
 	case !manifestWork.DeletionTimestamp.IsZero():
-		err := m.deleteAppliedManifestWork(ctx, appliedManifestWorkName)
+		err := m.deleteAppliedManifestWork(ctx, recorder, manifestWork, manifestWorkName)
 		if err != nil {
 			return err
 		}
@@ -83,7 +98,7 @@ func (m *ManifestWorkFinalizeController) sync(ctx context.Context, controllerCon
 		return nil
 	}
 
-	_, err = m.appliedManifestWorkLister.Get(appliedManifestWorkName)
+	appliedManifestWork, err := helper.GetAppliedManifestWorkByManifestWork(m.appliedManifestWorkIndexer, m.hubHash, manifestWorkName)
 	switch {
 	case errors.IsNotFound(err):
 		// if the instance is not found, then we simply continue below this block to remove the finalizer
@@ -91,6 +106,22 @@ func (m *ManifestWorkFinalizeController) sync(ctx context.Context, controllerCon
 		return err
 	default:
 		// appliedmanifestwork still exists, requeue the manifestwork to check in the next loop.
+		if manifestWork != nil {
+			condition := metav1.Condition{
+				Type:    constants.WorkCleanupCompleted,
+				Status:  metav1.ConditionFalse,
+				Reason:  "CleanupInProgress",
+				Message: fmt.Sprintf("Deleting %d applied resources", len(appliedManifestWork.Status.AppliedResources)),
+			}
+			// the deletion of the applied resources is denied by an admission webhook
+			if denied := appliedManifestWork.Annotations[constants.DeletionDeniedByWebhookAnnotationKey]; len(denied) > 0 {
+				condition.Reason = helper.AdmissionWebhookDeniedReason
+				condition.Message = denied
+			}
+			if _, err := m.reportCleanup(ctx, manifestWork, condition); err != nil {
+				return err
+			}
+		}
 		controllerContext.Queue().AddAfter(manifestWorkName, m.rateLimiter.When(manifestWorkName))
 		return nil
 
@@ -103,8 +134,20 @@ func (m *ManifestWorkFinalizeController) sync(ctx context.Context, controllerCon
 	}
 
 	m.rateLimiter.Forget(manifestWorkName)
+	if !helper.HasFinalizer(manifestWork, constants.ManifestWorkFinalizer) {
+		return nil
+	}
+	manifestWork, err = m.reportCleanup(ctx, manifestWork, metav1.Condition{
+		Type:    constants.WorkCleanupCompleted,
+		Status:  metav1.ConditionTrue,
+		Reason:  "CleanupCompleted",
+		Message: "The applied resources are deleted",
+	})
+	if err != nil {
+		return err
+	}
 	manifestWork = manifestWork.DeepCopy()
-	helper.RemoveFinalizer(manifestWork, controllers.ManifestWorkFinalizer)
+	helper.RemoveFinalizer(manifestWork, constants.ManifestWorkFinalizer)
 	_, err = m.manifestWorkClient.Update(ctx, manifestWork, metav1.UpdateOptions{})
 	if err != nil {
 		return fmt.Errorf("Failed to remove finalizer from ManifestWork %s/%s: %w", manifestWork.Namespace, manifestWork.Name, err)
@@ -113,8 +156,34 @@ func (m *ManifestWorkFinalizeController) sync(ctx context.Context, controllerCon
 	return nil
 }
 
-func (m *ManifestWorkFinalizeController) deleteAppliedManifestWork(ctx context.Context, appliedManifestWorkName string) error {
-	appliedManifestWork, err := m.appliedManifestWorkLister.Get(appliedManifestWorkName)
+// reportCleanup sets the condition WorkCleanupCompleted on the deleting manifestwork if the cleanup is awaited by the
+// hub manager with the finalizer ManifestWorkHubCleanupFinalizer, and returns the updated manifestwork.
+func (m *ManifestWorkFinalizeController) reportCleanup(
+	ctx context.Context, manifestWork *workapiv1.ManifestWork, condition metav1.Condition) (*workapiv1.ManifestWork, error) {
+	if !helper.HasFinalizer(manifestWork, constants.ManifestWorkHubCleanupFinalizer) {
+		return manifestWork, nil
+	}
+	existing := meta.FindStatusCondition(manifestWork.Status.Conditions, condition.Type)
+	if existing != nil && existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message {
+		return manifestWork, nil
+	}
+
+	manifestWork = manifestWork.DeepCopy()
+	meta.SetStatusCondition(&manifestWork.Status.Conditions, condition)
+	updated, err := m.manifestWorkClient.UpdateStatus(ctx, manifestWork, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to report the cleanup of ManifestWork %s/%s: %w", manifestWork.Namespace, manifestWork.Name, err)
+	}
+	return updated, nil
+}
+
+// deleteAppliedManifestWork deletes the appliedmanifestwork. The owner is removed from the applied resources
+// orphaned by the deleteOption of the manifestwork beforehand, otherwise they are deleted by the kube garbage
+// collector together with the appliedmanifestwork, and the patched resources are reverted. The manifestwork is nil
+// if it is not found.
+func (m *ManifestWorkFinalizeController) deleteAppliedManifestWork(
+	ctx context.Context, recorder events.Recorder, manifestWork *workapiv1.ManifestWork, manifestWorkName string) error {
+	appliedManifestWork, err := helper.GetAppliedManifestWorkByManifestWork(m.appliedManifestWorkIndexer, m.hubHash, manifestWorkName)
 	switch {
 	case errors.IsNotFound(err):
 		return nil
@@ -124,5 +193,50 @@ func (m *ManifestWorkFinalizeController) deleteAppliedManifestWork(ctx context.C
 		return nil
 	}
 
-	return m.appliedManifestWorkClient.Delete(ctx, appliedManifestWorkName, metav1.DeleteOptions{})
+	if manifestWork != nil {
+		// the patched resources are not owned by the appliedmanifestwork, they are reverted if the patches have
+		// revert patches, the invalid config options are ignored since nothing is patched with them
+		if err := helper.RevertManifestPatches(ctx, manifestWork, m.spokeDynamicClient, recorder); err != nil && !errors.IsBadRequest(err) {
+			return err
+		}
+		deleteOption := helper.EffectiveDeleteOption(manifestWork, appliedManifestWork)
+		var selectorRules []helper.OrphaningSelectorRule
+		if deleteOption != nil && deleteOption.PropagationPolicy == workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan {
+			if selectorRules, err = helper.OrphaningSelectorRules(manifestWork); err != nil {
+				return err
+			}
+		}
+		if err := m.orphanAppliedResources(recorder, appliedManifestWork, deleteOption, selectorRules); err != nil {
+			return err
+		}
+	}
+
+	return m.appliedManifestWorkClient.Delete(ctx, appliedManifestWork.Name, metav1.DeleteOptions{})
+}
+
+// orphanAppliedResources removes the owner of the appliedmanifestwork from the applied resources which are
+// orphaned by the deleteOption, or by the orphaning rules with selectors evaluated against the live resources if
+// no orphaning rule with name matches. The appliedmanifestwork is deleted right after, so the orphaning rule
+// matching each resource is recorded in the event of the resource orphaned for auditing.
+func (m *ManifestWorkFinalizeController) orphanAppliedResources(
+	recorder events.Recorder, appliedManifestWork *workapiv1.AppliedManifestWork, deleteOption *workapiv1.DeleteOption,
+	selectorRules []helper.OrphaningSelectorRule) error {
+	owner := helper.NewAppliedManifestWorkOwner(appliedManifestWork)
+
+	var errs []error
+	for _, resource := range appliedManifestWork.Status.AppliedResources {
+		ruleIndex, orphaned := helper.OrphaningRuleIndex(resource.Group, resource.Resource, resource.Namespace, resource.Name, deleteOption)
+		if !orphaned {
+			if _, err := helper.OrphanAppliedResourceBySelectors(resource, constants.EventReasonResourceOrphaned, appliedManifestWork.Spec.ManifestWorkName, selectorRules,
+				m.spokeDynamicClient, recorder, *owner); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		reason := helper.OrphaningReason(appliedManifestWork.Spec.ManifestWorkName, ruleIndex)
+		if err := helper.OrphanAppliedResource(resource, constants.EventReasonResourceOrphaned, reason, m.spokeDynamicClient, recorder, *owner); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
 }
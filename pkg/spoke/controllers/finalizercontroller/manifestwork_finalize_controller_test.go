@@ -3,16 +3,23 @@ package finalizercontroller
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
 	clienttesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/util/workqueue"
 	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
 	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
 	workapiv1 "open-cluster-management.io/api/work/v1"
-	"open-cluster-management.io/work/pkg/spoke/controllers"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
 	"open-cluster-management.io/work/pkg/spoke/spoketesting"
 )
 
@@ -36,6 +43,7 @@ func TestSyncManifestWorkController(t *testing.T) {
 				ObjectMeta: metav1.ObjectMeta{
 					Name: fmt.Sprintf("%s-work", hubHash),
 				},
+				Spec: workapiv1.AppliedManifestWorkSpec{HubHash: hubHash, ManifestWorkName: "work"},
 			},
 			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
 				if len(actions) != 0 {
@@ -63,6 +71,7 @@ func TestSyncManifestWorkController(t *testing.T) {
 				ObjectMeta: metav1.ObjectMeta{
 					Name: fmt.Sprintf("%s-work", hubHash),
 				},
+				Spec: workapiv1.AppliedManifestWorkSpec{HubHash: hubHash, ManifestWorkName: "work"},
 			},
 			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
 				if len(actions) != 1 {
@@ -86,13 +95,14 @@ func TestSyncManifestWorkController(t *testing.T) {
 					Name:              "work",
 					Namespace:         "cluster1",
 					DeletionTimestamp: &now,
-					Finalizers:        []string{controllers.ManifestWorkFinalizer},
+					Finalizers:        []string{constants.ManifestWorkFinalizer},
 				},
 			},
 			appliedWork: &workapiv1.AppliedManifestWork{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: fmt.Sprintf("%s-work", hubHash),
 				},
+				Spec: workapiv1.AppliedManifestWorkSpec{HubHash: hubHash, ManifestWorkName: "work"},
 			},
 			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
 				if len(actions) != 1 {
@@ -108,6 +118,36 @@ func TestSyncManifestWorkController(t *testing.T) {
 			},
 			expectedQueueLen: 1,
 		},
+		{
+			name:     "do nothing when the deletion of work is paused",
+			workName: "work",
+			work: &workapiv1.ManifestWork{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "work",
+					Namespace:         "cluster1",
+					DeletionTimestamp: &now,
+					Finalizers:        []string{constants.ManifestWorkFinalizer},
+					Annotations:       map[string]string{constants.PausedAnnotationKey: constants.PausedDeletionAnnotationValue},
+				},
+			},
+			appliedWork: &workapiv1.AppliedManifestWork{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: fmt.Sprintf("%s-work", hubHash),
+				},
+				Spec: workapiv1.AppliedManifestWorkSpec{HubHash: hubHash, ManifestWorkName: "work"},
+			},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 0 {
+					t.Errorf("Expect 0 actions on appliedmanifestwork, but have %d", len(actions))
+				}
+			},
+			validateManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 0 {
+					t.Errorf("Suppose nothing done for manifestwork")
+				}
+			},
+			expectedQueueLen: 0,
+		},
 		{
 			name:     "requeue work when applied work is deleting",
 			workName: "work",
@@ -116,7 +156,7 @@ func TestSyncManifestWorkController(t *testing.T) {
 					Name:              "work",
 					Namespace:         "cluster1",
 					DeletionTimestamp: &now,
-					Finalizers:        []string{controllers.ManifestWorkFinalizer},
+					Finalizers:        []string{constants.ManifestWorkFinalizer},
 				},
 			},
 			appliedWork: &workapiv1.AppliedManifestWork{
@@ -124,6 +164,7 @@ func TestSyncManifestWorkController(t *testing.T) {
 					Name:              fmt.Sprintf("%s-work", hubHash),
 					DeletionTimestamp: &now,
 				},
+				Spec: workapiv1.AppliedManifestWorkSpec{HubHash: hubHash, ManifestWorkName: "work"},
 			},
 			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
 				if len(actions) != 0 {
@@ -145,7 +186,7 @@ func TestSyncManifestWorkController(t *testing.T) {
 					Name:              "work",
 					Namespace:         "cluster1",
 					DeletionTimestamp: &now,
-					Finalizers:        []string{controllers.ManifestWorkFinalizer},
+					Finalizers:        []string{constants.ManifestWorkFinalizer},
 				},
 			},
 			appliedWork: &workapiv1.AppliedManifestWork{
@@ -171,6 +212,124 @@ func TestSyncManifestWorkController(t *testing.T) {
 			},
 			expectedQueueLen: 0,
 		},
+		{
+			name:     "report cleanup in progress when the cleanup is awaited by hub",
+			workName: "work",
+			work: &workapiv1.ManifestWork{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "work",
+					Namespace:         "cluster1",
+					DeletionTimestamp: &now,
+					Finalizers:        []string{constants.ManifestWorkFinalizer, constants.ManifestWorkHubCleanupFinalizer},
+				},
+			},
+			appliedWork: &workapiv1.AppliedManifestWork{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              fmt.Sprintf("%s-work", hubHash),
+					DeletionTimestamp: &now,
+				},
+				Spec: workapiv1.AppliedManifestWorkSpec{HubHash: hubHash, ManifestWorkName: "work"},
+				Status: workapiv1.AppliedManifestWorkStatus{
+					AppliedResources: []workapiv1.AppliedManifestResourceMeta{{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "s1"}},
+				},
+			},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 0 {
+					t.Errorf("Expect 0 actions on appliedmanifestwork, but have %d", len(actions))
+				}
+			},
+			validateManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatalf("Suppose 1 action for manifestwork, but got %d", len(actions))
+				}
+				spoketesting.AssertAction(t, actions[0], "update")
+				obj := actions[0].(clienttesting.UpdateActionImpl).Object.(*workapiv1.ManifestWork)
+				condition := meta.FindStatusCondition(obj.Status.Conditions, constants.WorkCleanupCompleted)
+				if condition == nil || condition.Status != metav1.ConditionFalse || condition.Message != "Deleting 1 applied resources" {
+					t.Errorf("Expect cleanup in progress, but got %v", condition)
+				}
+			},
+			expectedQueueLen: 1,
+		},
+		{
+			name:     "report cleanup denied by webhook",
+			workName: "work",
+			work: &workapiv1.ManifestWork{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "work",
+					Namespace:         "cluster1",
+					DeletionTimestamp: &now,
+					Finalizers:        []string{constants.ManifestWorkFinalizer, constants.ManifestWorkHubCleanupFinalizer},
+				},
+			},
+			appliedWork: &workapiv1.AppliedManifestWork{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              fmt.Sprintf("%s-work", hubHash),
+					DeletionTimestamp: &now,
+					Annotations: map[string]string{
+						constants.DeletionDeniedByWebhookAnnotationKey: "[webhook=deny.policy.io] Failed to delete 1 of 1 applied resources: protected",
+					},
+				},
+				Spec: workapiv1.AppliedManifestWorkSpec{HubHash: hubHash, ManifestWorkName: "work"},
+			},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 0 {
+					t.Errorf("Expect 0 actions on appliedmanifestwork, but have %d", len(actions))
+				}
+			},
+			validateManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatalf("Suppose 1 action for manifestwork, but got %d", len(actions))
+				}
+				obj := actions[0].(clienttesting.UpdateActionImpl).Object.(*workapiv1.ManifestWork)
+				condition := meta.FindStatusCondition(obj.Status.Conditions, constants.WorkCleanupCompleted)
+				if condition == nil || condition.Reason != helper.AdmissionWebhookDeniedReason {
+					t.Fatalf("Expect cleanup denied by webhook, but got %v", condition)
+				}
+				if webhook, ok := helper.AdmissionWebhookOfMessage(condition.Message); !ok || webhook != "deny.policy.io" {
+					t.Errorf("Expect webhook deny.policy.io in the message, but got %q", condition.Message)
+				}
+			},
+			expectedQueueLen: 1,
+		},
+		{
+			name:     "report cleanup completed before removing finalizer",
+			workName: "work",
+			work: &workapiv1.ManifestWork{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "work",
+					Namespace:         "cluster1",
+					DeletionTimestamp: &now,
+					Finalizers:        []string{constants.ManifestWorkFinalizer, constants.ManifestWorkHubCleanupFinalizer},
+				},
+			},
+			appliedWork: &workapiv1.AppliedManifestWork{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "fake",
+				},
+			},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 0 {
+					t.Errorf("Expect 0 actions on appliedmanifestwork, but have %d", len(actions))
+				}
+			},
+			validateManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 2 {
+					t.Fatalf("Suppose 2 actions for manifestwork, but got %d", len(actions))
+				}
+				if actions[0].GetSubresource() != "status" {
+					t.Errorf("Expect the status updated first, but got %v", actions[0])
+				}
+				obj := actions[1].(clienttesting.UpdateActionImpl).Object.(*workapiv1.ManifestWork)
+				if !meta.IsStatusConditionTrue(obj.Status.Conditions, constants.WorkCleanupCompleted) {
+					t.Errorf("Expect cleanup completed, but got %v", obj.Status.Conditions)
+				}
+				if len(obj.Finalizers) != 1 || obj.Finalizers[0] != constants.ManifestWorkHubCleanupFinalizer {
+					t.Errorf("Expect only the hub finalizer left, but got %v", obj.Finalizers)
+				}
+			},
+			expectedQueueLen: 0,
+		},
 		{
 			name:     "delete appliedmanifestwork when no matched manifestwoork",
 			workName: "work",
@@ -184,6 +343,7 @@ func TestSyncManifestWorkController(t *testing.T) {
 				ObjectMeta: metav1.ObjectMeta{
 					Name: fmt.Sprintf("%s-work", hubHash),
 				},
+				Spec: workapiv1.AppliedManifestWorkSpec{HubHash: hubHash, ManifestWorkName: "work"},
 			},
 			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
 				if len(actions) != 1 {
@@ -206,14 +366,18 @@ func TestSyncManifestWorkController(t *testing.T) {
 			fakeClient := fakeworkclient.NewSimpleClientset(c.work, c.appliedWork)
 			informerFactory := workinformers.NewSharedInformerFactory(fakeClient, 5*time.Minute)
 			informerFactory.Work().V1().ManifestWorks().Informer().GetStore().Add(c.work)
+			if err := helper.AddAppliedManifestWorkIndexers(informerFactory.Work().V1().AppliedManifestWorks().Informer()); err != nil {
+				t.Fatal(err)
+			}
 			informerFactory.Work().V1().AppliedManifestWorks().Informer().GetStore().Add(c.appliedWork)
 			controller := &ManifestWorkFinalizeController{
-				manifestWorkClient:        fakeClient.WorkV1().ManifestWorks("cluster1"),
-				manifestWorkLister:        informerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks("cluster1"),
-				appliedManifestWorkClient: fakeClient.WorkV1().AppliedManifestWorks(),
-				appliedManifestWorkLister: informerFactory.Work().V1().AppliedManifestWorks().Lister(),
-				hubHash:                   hubHash,
-				rateLimiter:               workqueue.NewItemExponentialFailureRateLimiter(0, 1*time.Second),
+				spokeDynamicClient:         fakedynamic.NewSimpleDynamicClient(runtime.NewScheme()),
+				manifestWorkClient:         fakeClient.WorkV1().ManifestWorks("cluster1"),
+				manifestWorkLister:         informerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks("cluster1"),
+				appliedManifestWorkClient:  fakeClient.WorkV1().AppliedManifestWorks(),
+				appliedManifestWorkIndexer: informerFactory.Work().V1().AppliedManifestWorks().Informer().GetIndexer(),
+				hubHash:                    hubHash,
+				rateLimiter:                workqueue.NewItemExponentialFailureRateLimiter(0, 1*time.Second),
 			}
 
 			controllerContext := spoketesting.NewFakeSyncContext(t, c.workName)
@@ -243,3 +407,135 @@ func TestSyncManifestWorkController(t *testing.T) {
 		})
 	}
 }
+
+func TestOrphanAppliedResourcesOnWorkDeletion(t *testing.T) {
+	hubHash := "test"
+	now := metav1.Now()
+	appliedWork := spoketesting.NewAppliedManifestWork(hubHash, 0, "applied-uid")
+	appliedWork.Status.AppliedResources = []workapiv1.AppliedManifestResourceMeta{
+		{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "orphaned", UID: "orphaned"},
+		{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "deleted", UID: "deleted"},
+	}
+	owner := helper.NewAppliedManifestWorkOwner(appliedWork)
+
+	work, _ := spoketesting.NewManifestWork(0)
+	work.DeletionTimestamp = &now
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	work.Spec.DeleteOption = &workapiv1.DeleteOption{
+		PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+		SelectivelyOrphan: &workapiv1.SelectivelyOrphan{
+			OrphaningRules: []workapiv1.OrphaningRule{{Resource: "secrets", Namespace: "ns1", Name: "orphaned"}},
+		},
+	}
+
+	fakeDynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(),
+		spoketesting.NewUnstructuredSecret("ns1", "orphaned", false, "orphaned", *owner),
+		spoketesting.NewUnstructuredSecret("ns1", "deleted", false, "deleted", *owner))
+	fakeClient := fakeworkclient.NewSimpleClientset(work, appliedWork)
+	informerFactory := workinformers.NewSharedInformerFactory(fakeClient, 5*time.Minute)
+	informerFactory.Work().V1().ManifestWorks().Informer().GetStore().Add(work)
+	if err := helper.AddAppliedManifestWorkIndexers(informerFactory.Work().V1().AppliedManifestWorks().Informer()); err != nil {
+		t.Fatal(err)
+	}
+	informerFactory.Work().V1().AppliedManifestWorks().Informer().GetStore().Add(appliedWork)
+	controller := &ManifestWorkFinalizeController{
+		spokeDynamicClient:         fakeDynamicClient,
+		manifestWorkClient:         fakeClient.WorkV1().ManifestWorks("cluster1"),
+		manifestWorkLister:         informerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks("cluster1"),
+		appliedManifestWorkClient:  fakeClient.WorkV1().AppliedManifestWorks(),
+		appliedManifestWorkIndexer: informerFactory.Work().V1().AppliedManifestWorks().Informer().GetIndexer(),
+		hubHash:                    hubHash,
+		rateLimiter:                workqueue.NewItemExponentialFailureRateLimiter(0, 1*time.Second),
+	}
+
+	recorder := events.NewInMemoryRecorder("test")
+	if err := controller.sync(context.TODO(), spoketesting.NewFakeSyncContext(t, work.Name).WithRecorder(recorder)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the orphaning rule matching the resource is recorded for auditing
+	orphanedEvents := []string{}
+	for _, event := range recorder.Events() {
+		if event.Reason == constants.EventReasonResourceOrphaned {
+			orphanedEvents = append(orphanedEvents, event.Message)
+		}
+	}
+	if len(orphanedEvents) != 1 || !strings.Contains(orphanedEvents[0], "ns1/orphaned because it matches orphaning rule 0 of manifestwork") {
+		t.Errorf("expected the orphaning of secret orphaned by rule 0 recorded, but got %v", orphanedEvents)
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	for name, expectedOwners := range map[string]int{"orphaned": 0, "deleted": 1} {
+		obj, err := fakeDynamicClient.Resource(gvr).Namespace("ns1").Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(obj.GetOwnerReferences()) != expectedOwners {
+			t.Errorf("expected %d owners of %s, but got %v", expectedOwners, name, obj.GetOwnerReferences())
+		}
+	}
+}
+
+func TestRevertPatchesOnWorkDeletion(t *testing.T) {
+	hubHash := "test"
+	now := metav1.Now()
+	appliedWork := spoketesting.NewAppliedManifestWork(hubHash, 0, "applied-uid")
+
+	patch := workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: []byte(`{"metadata":{"labels":{"patched":"true"}}}`)}}
+	work, _ := spoketesting.NewManifestWork(0)
+	work.Spec.Workload.Manifests = []workapiv1.Manifest{patch, patch, patch}
+	work.DeletionTimestamp = &now
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	work.Annotations = map[string]string{constants.ManifestConfigOptionsAnnotationKey: `[
+		{"ordinal": 0, "resourceIdentifier": {"version": "v1", "resource": "secrets", "namespace": "ns1", "name": "reverted"},
+			"updateStrategy": {"type": "Patch", "patchType": "MergePatch", "revertPatch": {"metadata":{"labels":{"patched":null}}}}},
+		{"ordinal": 1, "resourceIdentifier": {"version": "v1", "resource": "secrets", "namespace": "ns1", "name": "kept"},
+			"updateStrategy": {"type": "Patch", "patchType": "MergePatch"}},
+		{"ordinal": 2, "resourceIdentifier": {"version": "v1", "resource": "secrets", "namespace": "ns1", "name": "missing"},
+			"updateStrategy": {"type": "Patch", "patchType": "MergePatch", "revertPatch": {"metadata":{"labels":{"patched":null}}}}}]`,
+	}
+
+	patched := func(name string) runtime.Object {
+		secret := spoketesting.NewUnstructured("v1", "Secret", "ns1", name)
+		secret.SetLabels(map[string]string{"patched": "true"})
+		return secret
+	}
+	fakeDynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), patched("reverted"), patched("kept"))
+	fakeClient := fakeworkclient.NewSimpleClientset(work, appliedWork)
+	informerFactory := workinformers.NewSharedInformerFactory(fakeClient, 5*time.Minute)
+	informerFactory.Work().V1().ManifestWorks().Informer().GetStore().Add(work)
+	if err := helper.AddAppliedManifestWorkIndexers(informerFactory.Work().V1().AppliedManifestWorks().Informer()); err != nil {
+		t.Fatal(err)
+	}
+	informerFactory.Work().V1().AppliedManifestWorks().Informer().GetStore().Add(appliedWork)
+	controller := &ManifestWorkFinalizeController{
+		spokeDynamicClient:         fakeDynamicClient,
+		manifestWorkClient:         fakeClient.WorkV1().ManifestWorks("cluster1"),
+		manifestWorkLister:         informerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks("cluster1"),
+		appliedManifestWorkClient:  fakeClient.WorkV1().AppliedManifestWorks(),
+		appliedManifestWorkIndexer: informerFactory.Work().V1().AppliedManifestWorks().Informer().GetIndexer(),
+		hubHash:                    hubHash,
+		rateLimiter:                workqueue.NewItemExponentialFailureRateLimiter(0, 1*time.Second),
+	}
+
+	if err := controller.sync(context.TODO(), spoketesting.NewFakeSyncContext(t, work.Name)); err != nil {
+		t.Fatal(err)
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	for name, expectedPatched := range map[string]bool{"reverted": false, "kept": true} {
+		obj, err := fakeDynamicClient.Resource(gvr).Namespace("ns1").Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := obj.GetLabels()["patched"]; ok != expectedPatched {
+			t.Errorf("expected %s patched %t, but got labels %v", name, expectedPatched, obj.GetLabels())
+		}
+	}
+	// the appliedmanifestwork is deleted once the patches are reverted
+	if actions := fakeClient.Actions(); len(actions) != 1 {
+		t.Errorf("expected the appliedmanifestwork deleted, but got %v", actions)
+	} else {
+		spoketesting.AssertAction(t, actions[0], "delete")
+	}
+}
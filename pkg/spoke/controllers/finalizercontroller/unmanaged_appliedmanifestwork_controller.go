@@ -0,0 +1,135 @@
+package finalizercontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
+	workinformer "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
+	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/controllers"
+)
+
+// UnmanagedAppliedWorkController evicts appliedmanifestworks which were created by agents connecting to other hubs
+// and are no longer maintained, i.e. the heartbeat of these appliedmanifestworks is stale for longer than the
+// eviction grace period. The appliedmanifestworks without the agent id and the heartbeat are never evicted.
+type UnmanagedAppliedWorkController struct {
+	appliedManifestWorkClient workv1client.AppliedManifestWorkInterface
+	appliedManifestWorkLister worklister.AppliedManifestWorkLister
+	// appliedManifestWorkIndexer indexes the appliedmanifestworks by hub hash, so that the appliedmanifestworks of
+	// the current hub are not visited on resync.
+	appliedManifestWorkIndexer cache.Indexer
+	hubHash                    string
+	evictionGracePeriod        time.Duration
+}
+
+// NewUnmanagedAppliedWorkController returns an UnmanagedAppliedWorkController
+func NewUnmanagedAppliedWorkController(
+	recorder events.Recorder,
+	appliedManifestWorkClient workv1client.AppliedManifestWorkInterface,
+	appliedManifestWorkInformer workinformer.AppliedManifestWorkInformer,
+	evictionGracePeriod time.Duration,
+	hubHash string,
+) factory.Controller {
+
+	controller := &UnmanagedAppliedWorkController{
+		appliedManifestWorkClient:  appliedManifestWorkClient,
+		appliedManifestWorkLister:  appliedManifestWorkInformer.Lister(),
+		appliedManifestWorkIndexer: appliedManifestWorkInformer.Informer().GetIndexer(),
+		hubHash:                    hubHash,
+		evictionGracePeriod:        evictionGracePeriod,
+	}
+
+	return factory.New().
+		WithInformersQueueKeyFunc(func(obj runtime.Object) string {
+			accessor, _ := meta.Accessor(obj)
+			return accessor.GetName()
+		}, appliedManifestWorkInformer.Informer()).
+		WithSync(controllers.InstrumentSync(hubHash, "UnmanagedAppliedManifestWork", controller.sync)).ResyncEvery(evictionGracePeriod).ToController("UnmanagedAppliedManifestWork", recorder)
+}
+
+func (m *UnmanagedAppliedWorkController) sync(ctx context.Context, controllerContext factory.SyncContext) error {
+	appliedManifestWorkName := controllerContext.QueueKey()
+	if appliedManifestWorkName != factory.DefaultQueueKey {
+		klog.V(4).Infof("Reconciling AppliedManifestWork %q", appliedManifestWorkName)
+		appliedManifestWork, err := m.appliedManifestWorkLister.Get(appliedManifestWorkName)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return m.syncAppliedManifestWork(ctx, controllerContext, appliedManifestWork)
+	}
+
+	var errs []error
+	for _, hubHash := range m.appliedManifestWorkIndexer.ListIndexFuncValues(helper.AppliedManifestWorkByHubHashIndex) {
+		if hubHash == m.hubHash {
+			continue
+		}
+		appliedManifestWorks, err := helper.ListAppliedManifestWorksByHubHash(m.appliedManifestWorkIndexer, hubHash)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, appliedManifestWork := range appliedManifestWorks {
+			if err := m.syncAppliedManifestWork(ctx, controllerContext, appliedManifestWork); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (m *UnmanagedAppliedWorkController) syncAppliedManifestWork(
+	ctx context.Context, controllerContext factory.SyncContext, appliedManifestWork *workapiv1.AppliedManifestWork) error {
+	// appliedmanifestworks of the current hub are maintained by this agent
+	if appliedManifestWork.Spec.HubHash == m.hubHash {
+		return nil
+	}
+
+	if !appliedManifestWork.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	// only the appliedmanifestworks of the agents refreshing the heartbeat are evicted, the appliedmanifestworks
+	// without a heartbeat may be maintained by an agent which does not refresh it, e.g. an older agent.
+	agentID, ok := appliedManifestWork.Annotations[constants.AgentIDAnnotationKey]
+	if !ok {
+		klog.V(4).Infof("Skip AppliedManifestWork %q without the agent id, its heartbeat is not tracked", appliedManifestWork.Name)
+		return nil
+	}
+	lastHeartbeat, err := time.Parse(time.RFC3339, appliedManifestWork.Annotations[constants.HeartbeatAnnotationKey])
+	if err != nil {
+		klog.V(4).Infof("Skip AppliedManifestWork %q of agent %q without a valid heartbeat", appliedManifestWork.Name, agentID)
+		return nil
+	}
+
+	if elapsed := time.Since(lastHeartbeat); elapsed < m.evictionGracePeriod {
+		controllerContext.Queue().AddAfter(appliedManifestWork.Name, m.evictionGracePeriod-elapsed)
+		return nil
+	}
+
+	err = m.appliedManifestWorkClient.Delete(ctx, appliedManifestWork.Name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("Failed to evict AppliedManifestWork %s: %w", appliedManifestWork.Name, err)
+	}
+
+	controllerContext.Recorder().Eventf("AppliedManifestWorkEvicted",
+		"Evicted AppliedManifestWork %s of agent %q because its heartbeat is stale since %s",
+		appliedManifestWork.Name, agentID, lastHeartbeat.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,98 @@
+package finalizercontroller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clienttesting "k8s.io/client-go/testing"
+	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestSyncUnmanagedAppliedWork(t *testing.T) {
+	cases := []struct {
+		name            string
+		appliedWork     *workapiv1.AppliedManifestWork
+		validateActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:            "skip appliedmanifestwork of current hub",
+			appliedWork:     newAppliedWorkWithHeartbeat("hub1", time.Now().Add(-2*time.Hour)),
+			validateActions: noAction,
+		},
+		{
+			name:            "keep appliedmanifestwork of other hub with fresh heartbeat",
+			appliedWork:     newAppliedWorkWithHeartbeat("hub2", time.Now().Add(-1*time.Minute)),
+			validateActions: noAction,
+		},
+		{
+			name:        "evict appliedmanifestwork of other hub with stale heartbeat",
+			appliedWork: newAppliedWorkWithHeartbeat("hub2", time.Now().Add(-2*time.Hour)),
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+				spoketesting.AssertAction(t, actions[0], "delete")
+			},
+		},
+		{
+			name: "keep appliedmanifestwork of other hub without heartbeat",
+			appliedWork: func() *workapiv1.AppliedManifestWork {
+				appliedWork := newAppliedWorkWithHeartbeat("hub2", time.Now().Add(-2*time.Hour))
+				delete(appliedWork.Annotations, constants.HeartbeatAnnotationKey)
+				return appliedWork
+			}(),
+			validateActions: noAction,
+		},
+		{
+			name: "keep appliedmanifestwork of other hub without agent id",
+			appliedWork: func() *workapiv1.AppliedManifestWork {
+				appliedWork := newAppliedWorkWithHeartbeat("hub2", time.Now().Add(-2*time.Hour))
+				delete(appliedWork.Annotations, constants.AgentIDAnnotationKey)
+				return appliedWork
+			}(),
+			validateActions: noAction,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeClient := fakeworkclient.NewSimpleClientset(c.appliedWork)
+			informerFactory := workinformers.NewSharedInformerFactory(fakeClient, 5*time.Minute)
+			if err := helper.AddAppliedManifestWorkIndexers(informerFactory.Work().V1().AppliedManifestWorks().Informer()); err != nil {
+				t.Fatal(err)
+			}
+			informerFactory.Work().V1().AppliedManifestWorks().Informer().GetStore().Add(c.appliedWork)
+			controller := &UnmanagedAppliedWorkController{
+				appliedManifestWorkClient:  fakeClient.WorkV1().AppliedManifestWorks(),
+				appliedManifestWorkLister:  informerFactory.Work().V1().AppliedManifestWorks().Lister(),
+				appliedManifestWorkIndexer: informerFactory.Work().V1().AppliedManifestWorks().Informer().GetIndexer(),
+				hubHash:                    "hub1",
+				evictionGracePeriod:        time.Hour,
+			}
+
+			controllerContext := spoketesting.NewFakeSyncContext(t, c.appliedWork.Name)
+			if err := controller.sync(context.TODO(), controllerContext); err != nil {
+				t.Fatal(err)
+			}
+			c.validateActions(t, fakeClient.Actions())
+		})
+	}
+}
+
+func newAppliedWorkWithHeartbeat(hubHash string, lastHeartbeat time.Time) *workapiv1.AppliedManifestWork {
+	appliedWork := spoketesting.NewAppliedManifestWork(hubHash, 0, "test")
+	appliedWork.CreationTimestamp = metav1.NewTime(lastHeartbeat)
+	appliedWork.Annotations = map[string]string{
+		constants.AgentIDAnnotationKey:   "agent1",
+		constants.HeartbeatAnnotationKey: lastHeartbeat.UTC().Format(time.RFC3339),
+	}
+	return appliedWork
+}
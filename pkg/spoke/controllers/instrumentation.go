@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var (
+	queueDepthDesc = metrics.NewDesc("work_agent_controller_queue_depth",
+		"Number of keys waiting in the queue of the controller.",
+		[]string{"hub_hash", "controller"}, nil, metrics.ALPHA, "")
+	queueRetriesDesc = metrics.NewDesc("work_agent_controller_queue_retries",
+		"Number of keys retried by the controller since their last sync failed.",
+		[]string{"hub_hash", "controller"}, nil, metrics.ALPHA, "")
+	lastSuccessfulSyncTimeDesc = metrics.NewDesc("work_agent_controller_last_successful_sync_timestamp_seconds",
+		"Unix time of the last successful sync of the controller.",
+		[]string{"hub_hash", "controller"}, nil, metrics.ALPHA, "")
+)
+
+// controllerStates records the state of the instrumented controllers by hub hash and name, the controllers of
+// the hubs are instrumented separately.
+var controllerStates = newControllerStateRegistry()
+
+func init() {
+	legacyregistry.CustomMustRegister(&controllerStateCollector{registry: controllerStates})
+}
+
+// ControllerState is the state of an instrumented controller.
+type ControllerState struct {
+	HubHash                string     `json:"hubHash"`
+	Name                   string     `json:"name"`
+	QueueDepth             int        `json:"queueDepth"`
+	Retries                int        `json:"retries"`
+	LastSuccessfulSyncTime *time.Time `json:"lastSuccessfulSyncTime,omitempty"`
+}
+
+type controllerState struct {
+	queue                  workqueue.RateLimitingInterface
+	failedKeys             sets.String
+	lastSuccessfulSyncTime time.Time
+}
+
+// controllerKey is the key of the state of a controller of a hub.
+type controllerKey struct {
+	hubHash string
+	name    string
+}
+
+type controllerStateRegistry struct {
+	lock   sync.Mutex
+	states map[controllerKey]*controllerState
+}
+
+func newControllerStateRegistry() *controllerStateRegistry {
+	return &controllerStateRegistry{states: map[controllerKey]*controllerState{}}
+}
+
+// InstrumentSync wraps the sync function of the controller of the hub with the hash with the given name, so that the
+// queue depth, the number of retried keys and the last successful sync time of the controller are exposed as metrics
+// and by DebugHandler. The controller factory of library-go does not expose its queue, so the queue is taken from the
+// sync context once the controller syncs.
+func InstrumentSync(hubHash, name string, sync factory.SyncFunc) factory.SyncFunc {
+	return controllerStates.instrumentSync(hubHash, name, sync)
+}
+
+func (r *controllerStateRegistry) instrumentSync(hubHash, name string, sync factory.SyncFunc) factory.SyncFunc {
+	key := controllerKey{hubHash: hubHash, name: name}
+	r.lock.Lock()
+	r.states[key] = &controllerState{failedKeys: sets.NewString()}
+	r.lock.Unlock()
+
+	return func(ctx context.Context, syncCtx factory.SyncContext) error {
+		err := sync(ctx, syncCtx)
+		r.record(key, syncCtx, err)
+		return err
+	}
+}
+
+func (r *controllerStateRegistry) record(key controllerKey, syncCtx factory.SyncContext, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	state := r.states[key]
+	state.queue = syncCtx.Queue()
+	if err != nil {
+		state.failedKeys.Insert(syncCtx.QueueKey())
+		return
+	}
+	state.failedKeys.Delete(syncCtx.QueueKey())
+	state.lastSuccessfulSyncTime = time.Now()
+}
+
+// list returns the states of the controllers sorted by hub hash and name.
+func (r *controllerStateRegistry) list() []ControllerState {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	states := []ControllerState{}
+	for key, state := range r.states {
+		s := ControllerState{
+			HubHash: key.hubHash,
+			Name:    key.name,
+			Retries: state.failedKeys.Len(),
+		}
+		if state.queue != nil {
+			s.QueueDepth = state.queue.Len()
+		}
+		if !state.lastSuccessfulSyncTime.IsZero() {
+			lastSuccessfulSyncTime := state.lastSuccessfulSyncTime
+			s.LastSuccessfulSyncTime = &lastSuccessfulSyncTime
+		}
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].HubHash != states[j].HubHash {
+			return states[i].HubHash < states[j].HubHash
+		}
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
+
+// DebugHandler serves the states of the instrumented controllers of all the hubs in json, e.g. at /debug/works.
+func DebugHandler(w http.ResponseWriter, _ *http.Request) {
+	controllerStates.serveHTTP(w)
+}
+
+func (r *controllerStateRegistry) serveHTTP(w http.ResponseWriter) {
+	data, err := json.Marshal(r.list())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// controllerStateCollector reports the states of the controllers when the metrics are scraped, so that the
+// queue depth is up to date even if a controller is stuck in a sync.
+type controllerStateCollector struct {
+	metrics.BaseStableCollector
+	registry *controllerStateRegistry
+}
+
+func (c *controllerStateCollector) DescribeWithStability(ch chan<- *metrics.Desc) {
+	ch <- queueDepthDesc
+	ch <- queueRetriesDesc
+	ch <- lastSuccessfulSyncTimeDesc
+}
+
+func (c *controllerStateCollector) CollectWithStability(ch chan<- metrics.Metric) {
+	for _, state := range c.registry.list() {
+		ch <- metrics.NewLazyConstMetric(queueDepthDesc, metrics.GaugeValue, float64(state.QueueDepth), state.HubHash, state.Name)
+		ch <- metrics.NewLazyConstMetric(queueRetriesDesc, metrics.GaugeValue, float64(state.Retries), state.HubHash, state.Name)
+		if state.LastSuccessfulSyncTime != nil {
+			ch <- metrics.NewLazyConstMetric(lastSuccessfulSyncTimeDesc, metrics.GaugeValue,
+				float64(state.LastSuccessfulSyncTime.Unix()), state.HubHash, state.Name)
+		}
+	}
+}
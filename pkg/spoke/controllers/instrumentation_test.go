@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+type fakeSyncContext struct {
+	queue    workqueue.RateLimitingInterface
+	queueKey string
+	recorder events.Recorder
+}
+
+func (f fakeSyncContext) Queue() workqueue.RateLimitingInterface { return f.queue }
+func (f fakeSyncContext) QueueKey() string                       { return f.queueKey }
+func (f fakeSyncContext) Recorder() events.Recorder              { return f.recorder }
+
+func TestInstrumentSync(t *testing.T) {
+	registry := newControllerStateRegistry()
+	failing := true
+	sync := registry.instrumentSync("hub1", "test", func(ctx context.Context, syncCtx factory.SyncContext) error {
+		if failing && strings.HasPrefix(syncCtx.QueueKey(), "fail") {
+			return fmt.Errorf("failed to sync %s", syncCtx.QueueKey())
+		}
+		return nil
+	})
+
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer queue.ShutDown()
+	recorder := eventstesting.NewTestingEventRecorder(t)
+
+	// process the next key in the queue like the controller factory does, a failed key is not requeued so
+	// that the queue depth does not depend on the rate limiter.
+	processNext := func() {
+		key, _ := queue.Get()
+		defer queue.Done(key)
+		_ = sync(context.TODO(), fakeSyncContext{queue: queue, queueKey: key.(string), recorder: recorder})
+	}
+	assertMetrics := func(depth, retries int) {
+		expected := fmt.Sprintf(`
+# HELP work_agent_controller_queue_depth [ALPHA] Number of keys waiting in the queue of the controller.
+# TYPE work_agent_controller_queue_depth gauge
+work_agent_controller_queue_depth{controller="test",hub_hash="hub1"} %d
+# HELP work_agent_controller_queue_retries [ALPHA] Number of keys retried by the controller since their last sync failed.
+# TYPE work_agent_controller_queue_retries gauge
+work_agent_controller_queue_retries{controller="test",hub_hash="hub1"} %d
+`, depth, retries)
+		// a collector can be registered only once
+		collector := &controllerStateCollector{registry: registry}
+		if err := testutil.CustomCollectAndCompare(collector, strings.NewReader(expected),
+			"work_agent_controller_queue_depth", "work_agent_controller_queue_retries"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the queue is not known before the controller syncs
+	assertMetrics(0, 0)
+	if states := registry.list(); len(states) != 1 || states[0].LastSuccessfulSyncTime != nil {
+		t.Fatalf("expected no successful sync, but got %v", states)
+	}
+
+	for _, key := range []string{"work1", "fail1", "work2", "fail2"} {
+		queue.Add(key)
+	}
+	processNext()
+	assertMetrics(3, 0)
+	if states := registry.list(); states[0].LastSuccessfulSyncTime == nil {
+		t.Fatalf("expected successful sync, but got %v", states)
+	}
+
+	// the failed keys are counted as retries
+	processNext()
+	processNext()
+	processNext()
+	assertMetrics(0, 2)
+
+	// a key is not retried any more once it is synced successfully
+	failing = false
+	queue.Add("fail1")
+	queue.Add("work3")
+	processNext()
+	assertMetrics(1, 1)
+
+	// the controller with the same name of another hub is instrumented separately
+	registry.instrumentSync("hub2", "test", func(ctx context.Context, syncCtx factory.SyncContext) error { return nil })
+
+	recorderResponse := httptest.NewRecorder()
+	registry.serveHTTP(recorderResponse)
+	states := []ControllerState{}
+	if err := json.Unmarshal(recorderResponse.Body.Bytes(), &states); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states[0].HubHash != "hub1" || states[0].Name != "test" || states[0].QueueDepth != 1 ||
+		states[0].Retries != 1 || states[1].HubHash != "hub2" || states[1].QueueDepth != 0 {
+		t.Errorf("unexpected states %v", states)
+	}
+}
@@ -0,0 +1,60 @@
+package manifestcontroller
+
+import (
+	goerrors "errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+// apiVersionDeprecatedReason is the reason of the APIVersionDeprecated condition of a manifest whose apiVersion is
+// deprecated but still served by the spoke.
+const apiVersionDeprecatedReason = "APIVersionDeprecatedInCluster"
+
+// apiVersionDeprecatedCondition returns the APIVersionDeprecated condition of the manifest applied with the error if
+// its apiVersion is deprecated by the Kubernetes version of the spoke, false is returned otherwise, or if the
+// apiVersion is removed already. The condition only warns, the manifest is still applied.
+func apiVersionDeprecatedCondition(
+	checker *helper.APIVersionChecker, resourceMeta workapiv1.ManifestResourceMeta, err error) (metav1.Condition, bool) {
+	if len(resourceMeta.Kind) == 0 || goerrors.Is(err, applyerrors.ErrAPIVersionRemoved) {
+		return metav1.Condition{}, false
+	}
+	gvk := schema.GroupVersionKind{Group: resourceMeta.Group, Version: resourceMeta.Version, Kind: resourceMeta.Kind}
+	deprecation, version, ok := checker.Deprecated(gvk)
+	if !ok {
+		return metav1.Condition{}, false
+	}
+	return metav1.Condition{
+		Type:   constants.ManifestAPIVersionDeprecated,
+		Status: metav1.ConditionTrue,
+		Reason: apiVersionDeprecatedReason,
+		Message: fmt.Sprintf("%s %s is deprecated in Kubernetes v%s and removed in v%s, the spoke cluster runs v%s, %s",
+			deprecation.GroupVersion, deprecation.Kind, deprecation.DeprecatedIn, deprecation.RemovedIn, version,
+			deprecation.ReplacementMessage()),
+	}, true
+}
+
+// withAPIVersionDeprecatedConditions returns a function updating the status with the updateStatusFunc, and removing
+// the APIVersionDeprecated condition of the manifests which are not deprecated any more, e.g. the apiVersion of the
+// manifest is replaced. The ordinals of the deprecated manifests are the keys of deprecated.
+func withAPIVersionDeprecatedConditions(
+	updateStatusFunc helper.UpdateManifestWorkStatusFunc, deprecated map[int32]bool) helper.UpdateManifestWorkStatusFunc {
+	return func(oldStatus *workapiv1.ManifestWorkStatus) error {
+		if err := updateStatusFunc(oldStatus); err != nil {
+			return err
+		}
+		for i, manifest := range oldStatus.ResourceStatus.Manifests {
+			if !deprecated[manifest.ResourceMeta.Ordinal] {
+				meta.RemoveStatusCondition(&oldStatus.ResourceStatus.Manifests[i].Conditions, constants.ManifestAPIVersionDeprecated)
+			}
+		}
+		return nil
+	}
+}
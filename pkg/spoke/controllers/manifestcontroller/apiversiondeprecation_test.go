@@ -0,0 +1,112 @@
+package manifestcontroller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestSyncWithAPIVersionDeprecation(t *testing.T) {
+	// the spoke serves batch/v1beta1 but not policy/v1beta1
+	mapper := restmapper.NewDiscoveryRESTMapper([]*restmapper.APIGroupResources{
+		{
+			Group: metav1.APIGroup{
+				Name: "batch",
+				Versions: []metav1.GroupVersionForDiscovery{
+					{Version: "v1", GroupVersion: "batch/v1"},
+					{Version: "v1beta1", GroupVersion: "batch/v1beta1"},
+				},
+				PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1", GroupVersion: "batch/v1"},
+			},
+			VersionedResources: map[string][]metav1.APIResource{
+				"v1":      {{Name: "cronjobs", Group: "batch", Namespaced: true, Kind: "CronJob"}},
+				"v1beta1": {{Name: "cronjobs", Group: "batch", Namespaced: true, Kind: "CronJob"}},
+			},
+		},
+	})
+
+	cases := []struct {
+		name                  string
+		manifest              *unstructured.Unstructured
+		gitVersion            string
+		existingDeprecated    bool
+		expectedAppliedStatus metav1.ConditionStatus
+		expectedAppliedReason string
+		expectedDeprecated    bool
+	}{
+		{
+			name:                  "removed",
+			manifest:              spoketesting.NewUnstructured("policy/v1beta1", "PodSecurityPolicy", "", "test"),
+			gitVersion:            "v1.25.0",
+			expectedAppliedStatus: metav1.ConditionFalse,
+			expectedAppliedReason: "APIVersionRemovedInCluster",
+		},
+		{
+			name:                  "deprecated but served",
+			manifest:              spoketesting.NewUnstructured("batch/v1beta1", "CronJob", "ns1", "test"),
+			gitVersion:            "v1.23.0",
+			expectedAppliedStatus: metav1.ConditionTrue,
+			expectedAppliedReason: "AppliedManifestComplete",
+			expectedDeprecated:    true,
+		},
+		{
+			name:                  "replaced by the version not deprecated",
+			manifest:              spoketesting.NewUnstructured("batch/v1", "CronJob", "ns1", "test"),
+			gitVersion:            "v1.23.0",
+			existingDeprecated:    true,
+			expectedAppliedStatus: metav1.ConditionTrue,
+			expectedAppliedReason: "AppliedManifestComplete",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0, c.manifest)
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			if c.existingDeprecated {
+				work.Status.ResourceStatus.Manifests = []workapiv1.ManifestCondition{{
+					ResourceMeta: workapiv1.ManifestResourceMeta{
+						Ordinal: 0, Group: "batch", Version: "v1beta1", Kind: "CronJob", Resource: "cronjobs", Namespace: "ns1", Name: "test"},
+					Conditions: []metav1.Condition{{
+						Type: constants.ManifestAPIVersionDeprecated, Status: metav1.ConditionTrue, Reason: apiVersionDeprecatedReason}},
+				}}
+			}
+			controller := newController(work, nil, mapper).withKubeObject().withUnstructuredObject()
+			controller.controller.apiVersionChecker = helper.NewAPIVersionChecker(&fakediscovery.FakeDiscovery{
+				Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+					{GroupVersion: "batch/v1"}, {GroupVersion: "batch/v1beta1"}, {GroupVersion: "policy/v1"},
+				}},
+				FakedServerVersion: &version.Info{GitVersion: c.gitVersion},
+			})
+
+			// the removed apiVersion is not retried
+			if err := controller.controller.sync(context.TODO(), spoketesting.NewFakeSyncContext(t, workKey)); err != nil {
+				t.Fatal(err)
+			}
+
+			workActions := controller.workClient.Actions()
+			updatedWork := lastUpdatedWork(t, workActions)
+			conditions := updatedWork.Status.ResourceStatus.Manifests[0].Conditions
+			applied := meta.FindStatusCondition(conditions, string(workapiv1.ManifestApplied))
+			if applied == nil || applied.Status != c.expectedAppliedStatus || applied.Reason != c.expectedAppliedReason {
+				t.Errorf("expected Applied condition %s with reason %s, but got %v", c.expectedAppliedStatus, c.expectedAppliedReason, applied)
+			}
+			deprecated := meta.FindStatusCondition(conditions, constants.ManifestAPIVersionDeprecated)
+			if (deprecated != nil) != c.expectedDeprecated {
+				t.Errorf("expected APIVersionDeprecated condition %t, but got %v", c.expectedDeprecated, deprecated)
+			}
+		})
+	}
+}
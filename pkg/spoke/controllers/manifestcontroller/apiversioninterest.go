@@ -0,0 +1,154 @@
+package manifestcontroller
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// crdQueueKeyPrefix is the prefix of the queue keys of the CRDs on the spoke. It contains ":" which is not
+	// allowed in the names of manifestworks.
+	crdQueueKeyPrefix = "CustomResourceDefinition:"
+
+	// defaultMaxAPIVersionInterests is the max number of manifestworks waiting on an API version tracked by
+	// apiVersionInterest.
+	defaultMaxAPIVersionInterests = 10000
+)
+
+// NewCustomResourceDefinitionInformer returns an informer of the CRDs on the spoke.
+func NewCustomResourceDefinitionInformer(client apiextensionsclient.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.ApiextensionsV1().CustomResourceDefinitions().List(context.Background(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.ApiextensionsV1().CustomResourceDefinitions().Watch(context.Background(), options)
+			},
+		},
+		&apiextensionsv1.CustomResourceDefinition{},
+		resyncPeriod,
+		cache.Indexers{},
+	)
+}
+
+// crdQueueKeyFunc returns the queue key of the CRD on the spoke.
+func crdQueueKeyFunc(obj runtime.Object) string {
+	crd, ok := obj.(*apiextensionsv1.CustomResourceDefinition)
+	if !ok {
+		return ""
+	}
+	return crdQueueKeyPrefix + crd.Name
+}
+
+func isCRDQueueKey(key string) bool {
+	return strings.HasPrefix(key, crdQueueKeyPrefix)
+}
+
+// apiVersionInterest records the manifestworks waiting on the API versions which are not served by the spoke
+// yet, e.g. a manifestwork contains a CR whose CRD is installed by another manifestwork, so that they can be
+// requeued once the CRD is installed instead of waiting for the rate limited retries. The number of tracked
+// manifestworks is bounded, a manifestwork not tracked is still retried with rate limiting.
+type apiVersionInterest struct {
+	lock       sync.Mutex
+	maxEntries int
+	entries    int
+	// works are the names of the manifestworks waiting on each API version.
+	works map[schema.GroupVersion]sets.String
+}
+
+func newAPIVersionInterest(maxEntries int) *apiVersionInterest {
+	return &apiVersionInterest{
+		maxEntries: maxEntries,
+		works:      map[schema.GroupVersion]sets.String{},
+	}
+}
+
+// register records that the manifestwork is waiting on the API versions, and clears its interest in the other
+// API versions. The interest of the manifestwork is cleared if the API versions are empty, e.g. the manifests
+// are applied.
+func (i *apiVersionInterest) register(workName string, groupVersions []schema.GroupVersion) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	waiting := map[schema.GroupVersion]bool{}
+	for _, gv := range groupVersions {
+		waiting[gv] = true
+	}
+
+	for gv, works := range i.works {
+		if waiting[gv] || !works.Has(workName) {
+			continue
+		}
+		works.Delete(workName)
+		i.entries--
+		if works.Len() == 0 {
+			delete(i.works, gv)
+		}
+	}
+
+	for gv := range waiting {
+		works, ok := i.works[gv]
+		if ok && works.Has(workName) {
+			continue
+		}
+		if i.entries >= i.maxEntries {
+			klog.Warningf("Too many ManifestWorks waiting on API versions, ManifestWork %q waiting on %s is not tracked", workName, gv)
+			continue
+		}
+		if !ok {
+			works = sets.NewString()
+			i.works[gv] = works
+		}
+		works.Insert(workName)
+		i.entries++
+	}
+}
+
+// waitingWorks returns the names of the manifestworks waiting on the API version.
+func (i *apiVersionInterest) waitingWorks(gv schema.GroupVersion) []string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return i.works[gv].List()
+}
+
+// enqueueWaitingWorks requeues the manifestworks waiting on the API versions served by the CRD.
+func (m *ManifestWorkController) enqueueWaitingWorks(controllerContext factory.SyncContext, crdKey string) error {
+	obj, exists, err := m.crdStore.GetByKey(strings.TrimPrefix(crdKey, crdQueueKeyPrefix))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	crd, ok := obj.(*apiextensionsv1.CustomResourceDefinition)
+	if !ok {
+		return nil
+	}
+
+	for _, version := range crd.Spec.Versions {
+		if !version.Served {
+			continue
+		}
+		gv := schema.GroupVersion{Group: crd.Spec.Group, Version: version.Name}
+		for _, workName := range m.apiVersionInterest.waitingWorks(gv) {
+			klog.V(4).Infof("API version %s is installed, requeue ManifestWork %q", gv, workName)
+			controllerContext.Queue().Add(workName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package manifestcontroller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestAPIVersionInterest(t *testing.T) {
+	gv1 := schema.GroupVersion{Group: "test.io", Version: "v1"}
+	gv2 := schema.GroupVersion{Group: "test.io", Version: "v2"}
+	interest := newAPIVersionInterest(3)
+
+	interest.register("work1", []schema.GroupVersion{gv1, gv2})
+	interest.register("work2", []schema.GroupVersion{gv1})
+	if works := interest.waitingWorks(gv1); !reflect.DeepEqual(works, []string{"work1", "work2"}) {
+		t.Errorf("expected works waiting on %s, but got %v", gv1, works)
+	}
+
+	// the entries beyond the max are not tracked
+	interest.register("work3", []schema.GroupVersion{gv2})
+	if works := interest.waitingWorks(gv2); !reflect.DeepEqual(works, []string{"work1"}) {
+		t.Errorf("expected works waiting on %s, but got %v", gv2, works)
+	}
+
+	// the interest in the other api versions is cleared
+	interest.register("work1", []schema.GroupVersion{gv1})
+	interest.register("work3", []schema.GroupVersion{gv2})
+	if works := interest.waitingWorks(gv2); !reflect.DeepEqual(works, []string{"work3"}) {
+		t.Errorf("expected works waiting on %s, but got %v", gv2, works)
+	}
+
+	// the interest is cleared once the manifests are applied
+	interest.register("work1", nil)
+	interest.register("work2", nil)
+	interest.register("work3", nil)
+	if len(interest.works) != 0 || interest.entries != 0 {
+		t.Errorf("expected no interest, but got %v", interest.works)
+	}
+}
+
+func TestSyncAPIVersionNotAvailable(t *testing.T) {
+	work, workKey := spoketesting.NewManifestWork(0,
+		spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
+		spoketesting.NewUnstructured("test.io/v1", "Foo", "ns1", "test"))
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+	crdStore := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	controller.controller.crdStore = crdStore
+	controller.controller.apiVersionInterest = newAPIVersionInterest(defaultMaxAPIVersionInterests)
+
+	// the manifest is retried since the crd may be installed later
+	if err := controller.controller.sync(context.TODO(), spoketesting.NewFakeSyncContext(t, workKey)); err == nil {
+		t.Errorf("expected error, but got nil")
+	}
+	workActions := controller.workClient.Actions()
+	updatedWork := lastUpdatedWork(t, workActions)
+	condition := meta.FindStatusCondition(updatedWork.Status.ResourceStatus.Manifests[1].Conditions, string(workapiv1.ManifestApplied))
+	if condition == nil || condition.Reason != "APIVersionNotAvailable" {
+		t.Errorf("expected reason APIVersionNotAvailable, but got %v", condition)
+	}
+
+	// the work is requeued once the crd serving the api version is installed
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "foos.test.io"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "test.io",
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1", Served: true},
+			},
+		},
+	}
+	if err := crdStore.Add(crd); err != nil {
+		t.Fatal(err)
+	}
+	syncContext := spoketesting.NewFakeSyncContext(t, crdQueueKeyFunc(crd))
+	if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+		t.Fatal(err)
+	}
+	if syncContext.Queue().Len() != 1 {
+		t.Errorf("expected work %q requeued, but got queue length %d", workKey, syncContext.Queue().Len())
+	}
+}
@@ -0,0 +1,115 @@
+package manifestcontroller
+
+import (
+	goerrors "errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+// appliedResourceCapRequeueTime is the interval to requeue a manifestwork with manifests not applied because of the
+// cap of the applied resources, the cap may not be reached any more once other manifestworks are deleted.
+const appliedResourceCapRequeueTime = time.Minute
+
+var appliedResources = metrics.NewGauge(
+	&metrics.GaugeOpts{
+		Name: "work_agent_applied_resources",
+		Help: "Number of resources applied by all the AppliedManifestWorks on the managed cluster.",
+	},
+)
+
+func init() {
+	legacyregistry.MustRegister(appliedResources)
+}
+
+// appliedResourceCapExceededError is returned when a manifest is not applied since the cap of the resources
+// applied by the agent is reached.
+type appliedResourceCapExceededError struct {
+	max   int
+	count int
+}
+
+func (e *appliedResourceCapExceededError) Error() string {
+	return fmt.Sprintf("the cap of %d resources applied by the agent is reached, %d resources are applied", e.max, e.count)
+}
+
+// appliedResourceBudget is the number of the resources which can still be applied under the cap of the resources
+// applied by the agent within a reconcile of a manifestwork. The applied resources are counted by their uids in
+// the index of the appliedmanifestworks, so the resources applied but not recorded on the appliedmanifestworks
+// yet are not counted and the cap may be exceeded slightly.
+type appliedResourceBudget struct {
+	max       int
+	count     int
+	remaining int
+	// admitted are the resources admitted already, they are admitted again without consuming the budget when
+	// the apply is retried on conflict.
+	admitted map[appliedResourceKey]bool
+}
+
+// newAppliedResourceBudget returns the budget of the applied resources, nil is returned if the cap is not positive.
+// The number of the applied resources is exposed as a metric regardless of the cap.
+func newAppliedResourceBudget(max int, appliedManifestWorkIndexer cache.Indexer) *appliedResourceBudget {
+	count := len(appliedManifestWorkIndexer.ListIndexFuncValues(helper.AppliedManifestWorkByResourceUIDIndex))
+	appliedResources.Set(float64(count))
+	if max <= 0 {
+		return nil
+	}
+	return &appliedResourceBudget{max: max, count: count, remaining: max - count, admitted: map[appliedResourceKey]bool{}}
+}
+
+// admit consumes the budget for a resource new to the manifestwork, a NotAllowedError is returned once the budget
+// is used up. It always admits if the budget is nil.
+func (b *appliedResourceBudget) admit(key appliedResourceKey) error {
+	if b == nil || b.admitted[key] {
+		return nil
+	}
+	if b.remaining <= 0 {
+		return &helper.NotAllowedError{
+			Err:         &appliedResourceCapExceededError{max: b.max, count: b.count},
+			RequeueTime: appliedResourceCapRequeueTime,
+		}
+	}
+	b.remaining--
+	b.admitted[key] = true
+	return nil
+}
+
+// isAppliedResourceCapExceededError checks if the manifest is not applied because of the cap of the applied resources.
+func isAppliedResourceCapExceededError(err error) bool {
+	var capErr *appliedResourceCapExceededError
+	return goerrors.As(err, &capErr)
+}
+
+// withAppliedResourceCapCondition returns a function updating the status with the updateStatusFunc, and setting the
+// condition ResourceQuotaExceededByAgentPolicy of the manifestwork if any manifest is not applied because of the
+// cap of the applied resources, or removing the condition otherwise.
+func withAppliedResourceCapCondition(
+	updateStatusFunc helper.UpdateManifestWorkStatusFunc, budget *appliedResourceBudget, generation int64, notApplied int) helper.UpdateManifestWorkStatusFunc {
+	return func(oldStatus *workapiv1.ManifestWorkStatus) error {
+		if err := updateStatusFunc(oldStatus); err != nil {
+			return err
+		}
+		if budget == nil || notApplied == 0 {
+			meta.RemoveStatusCondition(&oldStatus.Conditions, constants.WorkResourceQuotaExceededByAgentPolicy)
+			return nil
+		}
+		oldStatus.Conditions = helper.MergeStatusConditions(oldStatus.Conditions, []metav1.Condition{{
+			Type:               constants.WorkResourceQuotaExceededByAgentPolicy,
+			Status:             metav1.ConditionTrue,
+			Reason:             "MaxAppliedResourcesReached",
+			ObservedGeneration: generation,
+			Message: fmt.Sprintf("%d manifests are not applied, since the agent applies at most %d resources and %d resources are applied",
+				notApplied, budget.max, budget.count),
+		}})
+		return nil
+	}
+}
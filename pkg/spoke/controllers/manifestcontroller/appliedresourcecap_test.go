@@ -0,0 +1,69 @@
+package manifestcontroller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestAppliedResourceBudget(t *testing.T) {
+	key1 := appliedResourceKey{resource: "secrets", namespace: "ns1", name: "n1"}
+	key2 := appliedResourceKey{resource: "secrets", namespace: "ns1", name: "n2"}
+	budget := &appliedResourceBudget{max: 2, count: 1, remaining: 1, admitted: map[appliedResourceKey]bool{}}
+
+	if err := budget.admit(key1); err != nil {
+		t.Errorf("expected resource admitted, but got %v", err)
+	}
+	// the resource retried on conflict is admitted again
+	if err := budget.admit(key1); err != nil {
+		t.Errorf("expected resource admitted again, but got %v", err)
+	}
+	if err := budget.admit(key2); !isAppliedResourceCapExceededError(err) {
+		t.Errorf("expected cap exceeded error, but got %v", err)
+	}
+
+	var nilBudget *appliedResourceBudget
+	if err := nilBudget.admit(key2); err != nil {
+		t.Errorf("expected resource admitted without cap, but got %v", err)
+	}
+}
+
+func TestSyncAppliedResourceCapExceeded(t *testing.T) {
+	work, workKey := spoketesting.NewManifestWork(0,
+		spoketesting.NewUnstructured("v1", "Secret", "ns1", "n1"),
+		spoketesting.NewUnstructured("v1", "Secret", "ns1", "n2"))
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	// another manifestwork has applied a resource already
+	otherAppliedWork := spoketesting.NewAppliedManifestWork("otherhub", 1, "otheruid")
+	otherAppliedWork.Status.AppliedResources = []workapiv1.AppliedManifestResourceMeta{
+		{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "other", UID: "secretuid"},
+	}
+	controller := newController(work, otherAppliedWork, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+	controller.controller.limits = NewApplyLimits(0, 2, 0, nil)
+
+	syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+	// the manifestwork is requeued after the requeue time of the cap instead of being rate limited
+	requeueAfter, err := helper.SplitNotAllowedErrors(controller.controller.sync(context.TODO(), syncContext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requeueAfter != appliedResourceCapRequeueTime {
+		t.Errorf("expected requeue after %v, but got %v", appliedResourceCapRequeueTime, requeueAfter)
+	}
+
+	workActions := controller.workClient.Actions()
+	updatedWork := lastUpdatedWork(t, workActions)
+	assertManifestCondition(t, updatedWork.Status.ResourceStatus.Manifests, 0, string(workapiv1.ManifestApplied), metav1.ConditionTrue)
+	assertManifestCondition(t, updatedWork.Status.ResourceStatus.Manifests, 1, string(workapiv1.ManifestApplied), metav1.ConditionFalse)
+	condition := meta.FindStatusCondition(updatedWork.Status.ResourceStatus.Manifests[1].Conditions, string(workapiv1.ManifestApplied))
+	if condition.Reason != constants.WorkResourceQuotaExceededByAgentPolicy {
+		t.Errorf("expected reason %s, but got %v", constants.WorkResourceQuotaExceededByAgentPolicy, condition)
+	}
+	assertCondition(t, updatedWork.Status.Conditions, constants.WorkResourceQuotaExceededByAgentPolicy, metav1.ConditionTrue)
+}
@@ -0,0 +1,86 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+// applyInProgressReason is the reason of the condition Progressing of a manifestwork whose manifests are being
+// applied by more than one reconcile.
+const applyInProgressReason = "ApplyInProgress"
+
+// applyDeadlineOf returns the deadline of applying the manifests of a reconcile started at the start time. There is no
+// deadline if the apply deadline is not positive, or the results of an interrupted reconcile cannot be checkpointed
+// without the cache of the manifest hashes, since the manifests would be applied from scratch again and again.
+func (m *ManifestWorkController) applyDeadlineOf(start time.Time, hashes *manifestHashView) time.Time {
+	if m.applyDeadline <= 0 || hashes == nil {
+		return time.Time{}
+	}
+	return start.Add(m.applyDeadline)
+}
+
+// checkpointApply records the results of the manifests applied by a reconcile interrupted by the apply deadline, so
+// that the next reconcile resumes from them, and reports them with the condition Progressing. The manifestwork is
+// requeued immediately to apply the other manifests.
+func (m *ManifestWorkController) checkpointApply(
+	ctx context.Context,
+	controllerContext factory.SyncContext,
+	manifestWork *workapiv1.ManifestWork,
+	hashes *manifestHashView,
+	results []applyResult) error {
+	hashes.checkpoint(manifestWork.Generation, results)
+
+	total := len(manifestWork.Spec.Workload.Manifests)
+	klog.V(2).Infof("ManifestWork %q applied %d/%d manifests before the apply deadline %s, resume applying the others",
+		manifestWork.Name, len(results), total, m.applyDeadline)
+
+	manifestConditions := make([]workapiv1.ManifestCondition, 0, len(results))
+	for _, result := range results {
+		manifestConditions = append(manifestConditions, workapiv1.ManifestCondition{
+			ResourceMeta: result.resourceMeta,
+			Conditions:   []metav1.Condition{buildAppliedStatusCondition(result)},
+		})
+	}
+	if err := m.updateStatus(ctx, manifestWork,
+		withApplyProgressCheckpoint(manifestConditions, len(results), total, manifestWork.Generation)); err != nil {
+		return withOrigin(originHubWrite, fmt.Errorf("Failed to update work status with err %w", err))
+	}
+
+	controllerContext.Queue().Add(manifestWork.Name)
+	return nil
+}
+
+// withApplyProgressCheckpoint returns the status update merging the manifest conditions of the manifests applied so
+// far, and setting the condition Progressing with the number of them. The conditions of the other manifests are kept
+// until they are applied.
+func withApplyProgressCheckpoint(
+	manifestConditions []workapiv1.ManifestCondition, processed, total int, generation int64) helper.UpdateManifestWorkStatusFunc {
+	return func(oldStatus *workapiv1.ManifestWorkStatus) error {
+		newManifestConditions := append([]workapiv1.ManifestCondition{}, manifestConditions...)
+		for _, manifest := range oldStatus.ResourceStatus.Manifests {
+			if ordinal := int(manifest.ResourceMeta.Ordinal); ordinal >= processed && ordinal < total {
+				newManifestConditions = append(newManifestConditions, manifest)
+			}
+		}
+		oldStatus.ResourceStatus.Manifests = helper.MergeManifestConditions(oldStatus.ResourceStatus.Manifests, newManifestConditions)
+
+		meta.SetStatusCondition(&oldStatus.Conditions, metav1.Condition{
+			Type:               constants.WorkProgressing,
+			Status:             metav1.ConditionTrue,
+			Reason:             applyInProgressReason,
+			ObservedGeneration: generation,
+			Message:            fmt.Sprintf("applied %d/%d", processed, total),
+		})
+		return nil
+	}
+}
@@ -0,0 +1,86 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestApplyDeadline(t *testing.T) {
+	var objects []*unstructured.Unstructured
+	for i := 0; i < 4; i++ {
+		objects = append(objects, spoketesting.NewUnstructured("v1", "Secret", "ns1", fmt.Sprintf("test%d", i)))
+	}
+	work, workKey := spoketesting.NewManifestWork(0, objects...)
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	appliedWork := &workapiv1.AppliedManifestWork{
+		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("-%s", work.Name)},
+		Spec:       workapiv1.AppliedManifestWorkSpec{ManifestWorkName: work.Name},
+	}
+	controller := newController(work, appliedWork, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+	controller.controller.manifestHashes = newManifestHashes()
+	controller.controller.applyDeadline = 50 * time.Millisecond
+
+	// each manifest is applied slowly like with a slow webhook, so that the deadline is exceeded before all
+	// the manifests are applied
+	controller.kubeClient.PrependReactor("create", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		time.Sleep(30 * time.Millisecond)
+		return false, nil, nil
+	})
+
+	var checkpoints []string
+	for i := 0; i < len(objects); i++ {
+		syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+		if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+			t.Fatal(err)
+		}
+
+		workActions := controller.workClient.Actions()
+		updatedWork := lastUpdatedWork(t, workActions)
+		progressing := meta.FindStatusCondition(updatedWork.Status.Conditions, constants.WorkProgressing)
+		if progressing == nil {
+			if !meta.IsStatusConditionTrue(updatedWork.Status.Conditions, workapiv1.WorkApplied) ||
+				len(updatedWork.Status.ResourceStatus.Manifests) != len(objects) {
+				t.Errorf("expected all the manifests applied, but got %v", updatedWork.Status)
+			}
+			break
+		}
+
+		// the manifests applied so far are reported, and the manifestwork is requeued to apply the others
+		if progressing.Reason != applyInProgressReason ||
+			progressing.Message != fmt.Sprintf("applied %d/%d", len(updatedWork.Status.ResourceStatus.Manifests), len(objects)) {
+			t.Errorf("unexpected condition Progressing %v with %d manifests", progressing, len(updatedWork.Status.ResourceStatus.Manifests))
+		}
+		if syncContext.Queue().Len() != 1 {
+			t.Errorf("expected the manifestwork requeued immediately")
+		}
+		checkpoints = append(checkpoints, progressing.Message)
+	}
+
+	if len(checkpoints) == 0 {
+		t.Errorf("expected checkpoints before all the manifests are applied")
+	}
+	// the manifests applied before the checkpoints are not applied again
+	created := map[string]int{}
+	for _, action := range controller.kubeClient.Actions() {
+		if action.GetVerb() == "create" {
+			accessor, _ := meta.Accessor(action.(clienttesting.CreateAction).GetObject())
+			created[accessor.GetName()]++
+		}
+	}
+	for i := 0; i < len(objects); i++ {
+		if name := fmt.Sprintf("test%d", i); created[name] != 1 {
+			t.Errorf("expected %s created once, but got %v", name, created)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package manifestcontroller
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ApplyLimits are the limits of applying the manifestworks, which can be changed while the controllers are running,
+// e.g. once the configuration of the agent is reloaded. They are shared by the controllers of all the hubs. A nil
+// ApplyLimits does not limit anything.
+type ApplyLimits struct {
+	lock sync.RWMutex
+	// applyTimeout is the timeout of applying a manifest, there is no timeout if it is not positive
+	applyTimeout time.Duration
+	// maxAppliedResources is the max number of resources applied by the agent, it is not limited if it is not positive
+	maxAppliedResources int
+	// maxManifests is the max number of manifests of a manifestwork, it is not limited if it is not positive
+	maxManifests int
+	// forbiddenResources are the resources the manifests are not allowed to apply
+	forbiddenResources map[schema.GroupResource]struct{}
+}
+
+// NewApplyLimits returns the ApplyLimits with the given limits.
+func NewApplyLimits(applyTimeout time.Duration, maxAppliedResources, maxManifests int,
+	forbiddenResources []schema.GroupResource) *ApplyLimits {
+	l := &ApplyLimits{}
+	l.Set(applyTimeout, maxAppliedResources, maxManifests, forbiddenResources)
+	return l
+}
+
+// Set changes the limits, they take effect from the next reconcile of the manifestworks.
+func (l *ApplyLimits) Set(applyTimeout time.Duration, maxAppliedResources, maxManifests int,
+	forbiddenResources []schema.GroupResource) {
+	forbidden := map[schema.GroupResource]struct{}{}
+	for _, resource := range forbiddenResources {
+		forbidden[resource] = struct{}{}
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.applyTimeout = applyTimeout
+	l.maxAppliedResources = maxAppliedResources
+	l.maxManifests = maxManifests
+	l.forbiddenResources = forbidden
+}
+
+// ApplyTimeout returns the timeout of applying a manifest.
+func (l *ApplyLimits) ApplyTimeout() time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.applyTimeout
+}
+
+// MaxAppliedResources returns the max number of resources applied by the agent.
+func (l *ApplyLimits) MaxAppliedResources() int {
+	if l == nil {
+		return 0
+	}
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.maxAppliedResources
+}
+
+// MaxManifests returns the max number of manifests of a manifestwork.
+func (l *ApplyLimits) MaxManifests() int {
+	if l == nil {
+		return 0
+	}
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.maxManifests
+}
+
+// IsForbidden returns if the manifests are not allowed to apply the resource.
+func (l *ApplyLimits) IsForbidden(resource schema.GroupResource) bool {
+	if l == nil {
+		return false
+	}
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	_, forbidden := l.forbiddenResources[resource]
+	return forbidden
+}
@@ -0,0 +1,42 @@
+package manifestcontroller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestSyncForbiddenResource(t *testing.T) {
+	work, workKey := spoketesting.NewManifestWork(0,
+		spoketesting.NewUnstructured("v1", "Secret", "ns1", "n1"),
+		spoketesting.NewUnstructured("apps/v1", "Deployment", "ns1", "n1"))
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+	controller.controller.limits = NewApplyLimits(0, 0, 0, []schema.GroupResource{{Group: "apps", Resource: "deployments"}})
+
+	syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+	if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range controller.dynamicClient.Actions() {
+		if action.GetVerb() == "create" {
+			t.Errorf("expected the forbidden deployment not created, but got %v", action)
+		}
+	}
+
+	workActions := controller.workClient.Actions()
+	updatedWork := lastUpdatedWork(t, workActions)
+	assertManifestCondition(t, updatedWork.Status.ResourceStatus.Manifests, 0, string(workapiv1.ManifestApplied), metav1.ConditionTrue)
+	assertManifestCondition(t, updatedWork.Status.ResourceStatus.Manifests, 1, string(workapiv1.ManifestApplied), metav1.ConditionFalse)
+	condition := meta.FindStatusCondition(updatedWork.Status.ResourceStatus.Manifests[1].Conditions, string(workapiv1.ManifestApplied))
+	if condition.Reason != "ResourceForbiddenByAgentPolicy" {
+		t.Errorf("expected reason ResourceForbiddenByAgentPolicy, but got %v", condition)
+	}
+}
@@ -0,0 +1,94 @@
+package manifestcontroller
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+var (
+	// LastAppliedTimeChurnThreshold is the minimum change of the last applied time to be recorded on the work.
+	LastAppliedTimeChurnThreshold = 10 * time.Minute
+	// ApplyDurationChurnThreshold is the minimum change of the apply duration to be recorded on the work.
+	ApplyDurationChurnThreshold = 1 * time.Second
+)
+
+// applyStats records when the manifests of a work were last applied and how long the apply took.
+// The ManifestWork API has no status field for them, so they are recorded in the annotations
+// LastAppliedTimeAnnotationKey and LastApplyDurationAnnotationKey of the work.
+type applyStats struct {
+	lastAppliedTime time.Time
+	duration        time.Duration
+	// retries are recorded once the manifests failed to apply, see retryStats
+	retries *retryStats
+}
+
+// annotations returns the annotations recording the apply stats.
+func (s applyStats) annotations() map[string]string {
+	return map[string]string{
+		constants.LastAppliedTimeAnnotationKey:   s.lastAppliedTime.UTC().Format(time.RFC3339),
+		constants.LastApplyDurationAnnotationKey: strconv.FormatInt(s.duration.Milliseconds(), 10),
+	}
+}
+
+// applyStatsOf returns the apply stats recorded on the work. False is returned if the work has no stats.
+func applyStatsOf(manifestWork *workapiv1.ManifestWork) (applyStats, bool) {
+	lastAppliedTime, err := time.Parse(time.RFC3339, manifestWork.Annotations[constants.LastAppliedTimeAnnotationKey])
+	if err != nil {
+		return applyStats{}, false
+	}
+	durationMilliseconds, err := strconv.ParseInt(manifestWork.Annotations[constants.LastApplyDurationAnnotationKey], 10, 64)
+	if err != nil {
+		return applyStats{}, false
+	}
+	return applyStats{lastAppliedTime: lastAppliedTime, duration: time.Duration(durationMilliseconds) * time.Millisecond}, true
+}
+
+// applyStatsChanged returns true if the apply stats should be recorded on the work. The stats recorded already
+// are kept if the new stats are within the churn thresholds, so that the work is not updated on every resync,
+// unless the work was not applied at its current generation.
+func applyStatsChanged(manifestWork *workapiv1.ManifestWork, stats applyStats) bool {
+	if stats.lastAppliedTime.IsZero() {
+		return false
+	}
+
+	existing := meta.FindStatusCondition(manifestWork.Status.Conditions, workapiv1.WorkApplied)
+	if existing == nil || existing.Status != metav1.ConditionTrue || existing.ObservedGeneration != manifestWork.Generation {
+		return true
+	}
+
+	existingStats, ok := applyStatsOf(manifestWork)
+	if !ok {
+		return true
+	}
+	return stats.lastAppliedTime.Sub(existingStats.lastAppliedTime) >= LastAppliedTimeChurnThreshold ||
+		absDuration(stats.duration-existingStats.duration) >= ApplyDurationChurnThreshold
+}
+
+// recordApplyStats records the apply stats in the annotations of the work once all its manifests are applied.
+func (m *ManifestWorkController) recordApplyStats(
+	ctx context.Context, manifestWork *workapiv1.ManifestWork, stats applyStats) error {
+	if !applyStatsChanged(manifestWork, stats) {
+		return nil
+	}
+
+	// the annotations are written with the status to the hub by the status writer if configured
+	if m.statusWriter != nil {
+		m.statusWriter.EnqueueAnnotations(manifestWork.Name, stats.annotations())
+		return nil
+	}
+	return helper.PatchManifestWorkAnnotations(ctx, m.manifestWorkClient, manifestWork.Name, stats.annotations())
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,101 @@
+package manifestcontroller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestApplyStatsChurn(t *testing.T) {
+	lastAppliedTime := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	existingStats := applyStats{lastAppliedTime: lastAppliedTime, duration: 500 * time.Millisecond}
+
+	cases := []struct {
+		name               string
+		existingStats      *applyStats
+		existingGeneration int64
+		stats              applyStats
+		expectedUpdated    bool
+		expectedStats      applyStats
+	}{
+		{
+			name:            "record stats without existing stats",
+			stats:           existingStats,
+			expectedUpdated: true,
+			expectedStats:   existingStats,
+		},
+		{
+			name:          "no update within churn threshold",
+			existingStats: &existingStats,
+			stats:         applyStats{lastAppliedTime: lastAppliedTime.Add(5 * time.Minute), duration: 800 * time.Millisecond},
+			expectedStats: existingStats,
+		},
+		{
+			name:            "update last applied time beyond churn threshold",
+			existingStats:   &existingStats,
+			stats:           applyStats{lastAppliedTime: lastAppliedTime.Add(15 * time.Minute), duration: 800 * time.Millisecond},
+			expectedUpdated: true,
+			expectedStats:   applyStats{lastAppliedTime: lastAppliedTime.Add(15 * time.Minute), duration: 800 * time.Millisecond},
+		},
+		{
+			name:            "update apply duration beyond churn threshold",
+			existingStats:   &existingStats,
+			stats:           applyStats{lastAppliedTime: lastAppliedTime.Add(5 * time.Minute), duration: 3 * time.Second},
+			expectedUpdated: true,
+			expectedStats:   applyStats{lastAppliedTime: lastAppliedTime.Add(5 * time.Minute), duration: 3 * time.Second},
+		},
+		{
+			name:               "update stats when generation changes",
+			existingStats:      &existingStats,
+			existingGeneration: 1,
+			stats:              applyStats{lastAppliedTime: lastAppliedTime.Add(5 * time.Minute), duration: 800 * time.Millisecond},
+			expectedUpdated:    true,
+			expectedStats:      applyStats{lastAppliedTime: lastAppliedTime.Add(5 * time.Minute), duration: 800 * time.Millisecond},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, _ := spoketesting.NewManifestWork(0)
+			if c.existingStats != nil {
+				work.Annotations = c.existingStats.annotations()
+				work.Status.Conditions = []metav1.Condition{
+					newCondition(string(workapiv1.WorkApplied), string(metav1.ConditionTrue), "AppliedManifestWorkComplete",
+						"Apply manifest work complete", c.existingGeneration, &metav1.Time{Time: lastAppliedTime}),
+				}
+			}
+			fakeClient := fakeworkclient.NewSimpleClientset(work)
+			controller := &ManifestWorkController{manifestWorkClient: fakeClient.WorkV1().ManifestWorks(work.Namespace)}
+
+			if err := controller.recordApplyStats(context.TODO(), work, c.stats); err != nil {
+				t.Fatal(err)
+			}
+			actions := fakeClient.Actions()
+			if c.expectedUpdated != (len(actions) > 0) {
+				t.Errorf("expected updated %t, but got %v", c.expectedUpdated, actions)
+			}
+
+			updatedWork, err := fakeClient.WorkV1().ManifestWorks(work.Namespace).Get(context.TODO(), work.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, ok := applyStatsOf(updatedWork)
+			if !ok {
+				t.Fatalf("expected apply stats in annotations %v", updatedWork.Annotations)
+			}
+			if !actual.lastAppliedTime.Equal(c.expectedStats.lastAppliedTime) || actual.duration != c.expectedStats.duration {
+				t.Errorf("expected stats %v, but got %v", c.expectedStats, actual)
+			}
+			for _, condition := range updatedWork.Status.Conditions {
+				if condition.Message != "Apply manifest work complete" {
+					t.Errorf("expected the condition message unchanged, but got %q", condition.Message)
+				}
+			}
+		})
+	}
+}
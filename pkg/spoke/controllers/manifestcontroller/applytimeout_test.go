@@ -0,0 +1,82 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestApplyTimeout(t *testing.T) {
+	cases := []struct {
+		name            string
+		timeouts        string
+		expectedReasons []string
+	}{
+		{
+			name:            "apply of a hanging manifest times out",
+			expectedReasons: []string{"ApplyTimedOut", "AppliedManifestComplete"},
+		},
+		{
+			name:            "timeout overridden by the manifestwork",
+			timeouts:        `[{"ordinal": 0, "timeout": "5s"}]`,
+			expectedReasons: []string{"AppliedManifestFailedRetryable", "AppliedManifestComplete"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0,
+				spoketesting.NewUnstructured("v1", "Secret", "ns2", "test"),
+				spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"))
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			if len(c.timeouts) > 0 {
+				work.Annotations = map[string]string{constants.ManifestApplyTimeoutsAnnotationKey: c.timeouts}
+			}
+			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+			controller.controller.limits = NewApplyLimits(50*time.Millisecond, 0, 0, nil)
+
+			// the creation of the first manifest hangs like a webhook not responding, and fails since the fake client does not
+			// honor the context
+			controller.kubeClient.PrependReactor("create", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				if action.GetNamespace() != "ns2" {
+					return false, nil, nil
+				}
+				time.Sleep(200 * time.Millisecond)
+				return true, nil, fmt.Errorf("webhook is not responding")
+			})
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			// the failed manifest is retried
+			if err := controller.controller.sync(context.TODO(), syncContext); err == nil {
+				t.Errorf("expected sync error, but got nil")
+			}
+
+			var created []string
+			for _, action := range controller.kubeClient.Actions() {
+				if action.GetVerb() == "create" {
+					created = append(created, action.GetNamespace())
+				}
+			}
+			if len(created) != 2 {
+				t.Errorf("expected both manifests are applied, but got %v", created)
+			}
+
+			workActions := controller.workClient.Actions()
+			updatedWork := lastUpdatedWork(t, workActions)
+			for i, manifest := range updatedWork.Status.ResourceStatus.Manifests {
+				condition := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestApplied))
+				if condition == nil || condition.Reason != c.expectedReasons[i] {
+					t.Errorf("expected reason %q of manifest %d, but got %v", c.expectedReasons[i], i, condition)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package manifestcontroller
+
+import (
+	goerrors "errors"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+// errorOrigin is where an error of the reconcile of a manifestwork comes from.
+type errorOrigin string
+
+const (
+	originSpokeRead  errorOrigin = "SpokeRead"
+	originSpokeWrite errorOrigin = "SpokeWrite"
+	originHubWrite   errorOrigin = "HubWrite"
+)
+
+// errorClass is how an error of the reconcile of a manifestwork is retried.
+type errorClass string
+
+const (
+	// classThrottled means the apiserver asks the agent to slow down, e.g. 429 Too Many Requests.
+	classThrottled errorClass = "Throttled"
+	// classConflict means the resource is changed by others since it was read.
+	classConflict errorClass = "Conflict"
+	// classTransient means the error is likely gone once retried, e.g. a timeout or the etcd leader is changed.
+	classTransient errorClass = "Transient"
+	// classTerminal means the error will not be resolved until the manifestwork is changed.
+	classTerminal errorClass = "Terminal"
+)
+
+const (
+	conflictRetryDelay  = 100 * time.Millisecond
+	transientRetryDelay = 500 * time.Millisecond
+	throttledRetryDelay = 2 * time.Second
+	// maxRetryDelay caps the Retry-After suggested by the apiserver.
+	maxRetryDelay = 5 * time.Minute
+	// retryJitterFactor spreads the retries of the manifestworks failing at the same time.
+	retryJitterFactor = 0.5
+)
+
+var reconcileErrors = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Name: "work_agent_manifestwork_reconcile_errors_total",
+		Help: "Number of errors of the reconciles of ManifestWorks by origin (SpokeRead, SpokeWrite or HubWrite) and class " +
+			"(Throttled, Conflict, Transient or Terminal).",
+	},
+	[]string{"origin", "class"},
+)
+
+func init() {
+	legacyregistry.MustRegister(reconcileErrors)
+}
+
+// originError tags the error with its origin.
+type originError struct {
+	origin errorOrigin
+	err    error
+}
+
+func (e *originError) Error() string {
+	return e.err.Error()
+}
+
+func (e *originError) Unwrap() error {
+	return e.err
+}
+
+// withOrigin tags the error with the origin, nil is returned if the error is nil.
+func withOrigin(origin errorOrigin, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &originError{origin: origin, err: err}
+}
+
+// classifyError returns the origin and the class of the error, and the delay suggested by the apiserver with the
+// Retry-After of a throttled request, 0 if there is none. The origin is the defaultOrigin unless the error is tagged
+// with withOrigin.
+func classifyError(defaultOrigin errorOrigin, err error) (errorOrigin, errorClass, time.Duration) {
+	origin := defaultOrigin
+	var originErr *originError
+	if goerrors.As(err, &originErr) {
+		origin = originErr.origin
+	}
+
+	switch {
+	case errors.IsTooManyRequests(err):
+		var retryAfter time.Duration
+		if seconds, ok := errors.SuggestsClientDelay(err); ok {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return origin, classThrottled, retryAfter
+	case errors.IsConflict(err):
+		return origin, classConflict, 0
+	case isEtcdLeaderChangedError(err):
+		return origin, classTransient, 0
+	case helper.ClassifyApplyError(err) == helper.ApplyErrorTerminal:
+		return origin, classTerminal, 0
+	}
+	return origin, classTransient, 0
+}
+
+// isEtcdLeaderChangedError checks if the request failed since the leader of etcd behind the apiserver is changed.
+func isEtcdLeaderChangedError(err error) bool {
+	return errors.IsInternalError(err) && strings.Contains(err.Error(), "etcdserver: leader changed")
+}
+
+// retryDelay returns the base delay before an error of the class is retried, the retryAfter suggested by the
+// apiserver is respected for the throttled errors. 0 is returned if the error is not retried with a delay, which
+// are the terminal errors and the errors of the hub, since the writes to the hub are guarded by the circuit breaker.
+func retryDelay(origin errorOrigin, class errorClass, retryAfter time.Duration) time.Duration {
+	if origin == originHubWrite {
+		return 0
+	}
+
+	switch class {
+	case classThrottled:
+		if retryAfter <= 0 {
+			return throttledRetryDelay
+		}
+		if retryAfter > maxRetryDelay {
+			return maxRetryDelay
+		}
+		return retryAfter
+	case classConflict:
+		return conflictRetryDelay
+	case classTransient:
+		return transientRetryDelay
+	}
+	return 0
+}
+
+// withRetryDelays classifies the errors of the reconcile, records them in the metrics, and wraps the errors of the
+// spoke in RetryAfterErrors with the jittered delays of their classes, so that they are retried after the delays
+// instead of the rate limited backoff of the controller factory. The aggregated errors are flattened, and the
+// NotAllowedErrors are kept as they are.
+func withRetryDelays(defaultOrigin errorOrigin, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var aggregate utilerrors.Aggregate
+	if goerrors.As(err, &aggregate) {
+		var errs []error
+		for _, e := range aggregate.Errors() {
+			e = withRetryDelays(defaultOrigin, e)
+			var nested utilerrors.Aggregate
+			if goerrors.As(e, &nested) {
+				errs = append(errs, nested.Errors()...)
+				continue
+			}
+			errs = append(errs, e)
+		}
+		return utilerrors.NewAggregate(errs)
+	}
+
+	var notAllowedErr *helper.NotAllowedError
+	if goerrors.As(err, &notAllowedErr) {
+		return err
+	}
+
+	origin, class, retryAfter := classifyError(defaultOrigin, err)
+	reconcileErrors.WithLabelValues(string(origin), string(class)).Inc()
+	delay := retryDelay(origin, class, retryAfter)
+	if delay == 0 {
+		return err
+	}
+	return &helper.RetryAfterError{Err: err, RequeueTime: wait.Jitter(delay, retryJitterFactor)}
+}
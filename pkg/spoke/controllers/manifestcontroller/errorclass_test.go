@@ -0,0 +1,138 @@
+package manifestcontroller
+
+import (
+	goerrors "errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+func TestClassifyError(t *testing.T) {
+	secrets := schema.GroupResource{Resource: "secrets"}
+	cases := []struct {
+		name               string
+		err                error
+		expectedOrigin     errorOrigin
+		expectedClass      errorClass
+		expectedRetryAfter time.Duration
+	}{
+		{
+			name:               "throttled with retry after",
+			err:                fmt.Errorf("manifest 0: %w", errors.NewTooManyRequests("slow down", 7)),
+			expectedOrigin:     originSpokeWrite,
+			expectedClass:      classThrottled,
+			expectedRetryAfter: 7 * time.Second,
+		},
+		{
+			name:           "throttled without retry after",
+			err:            errors.NewTooManyRequestsError("slow down"),
+			expectedOrigin: originSpokeWrite,
+			expectedClass:  classThrottled,
+		},
+		{
+			name:           "conflict",
+			err:            errors.NewConflict(secrets, "test", fmt.Errorf("changed")),
+			expectedOrigin: originSpokeWrite,
+			expectedClass:  classConflict,
+		},
+		{
+			name:           "etcd leader changed",
+			err:            withOrigin(originSpokeRead, errors.NewInternalError(fmt.Errorf("etcdserver: leader changed"))),
+			expectedOrigin: originSpokeRead,
+			expectedClass:  classTransient,
+		},
+		{
+			name:           "timeout",
+			err:            errors.NewServerTimeout(secrets, "get", 1),
+			expectedOrigin: originSpokeWrite,
+			expectedClass:  classTransient,
+		},
+		{
+			name:           "invalid",
+			err:            errors.NewInvalid(schema.GroupKind{Kind: "Secret"}, "test", nil),
+			expectedOrigin: originSpokeWrite,
+			expectedClass:  classTerminal,
+		},
+		{
+			name:           "hub unavailable",
+			err:            withOrigin(originHubWrite, fmt.Errorf("Failed to update work status with err %w", helper.ErrHubUnavailable)),
+			expectedOrigin: originHubWrite,
+			expectedClass:  classTransient,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			origin, class, retryAfter := classifyError(originSpokeWrite, c.err)
+			if origin != c.expectedOrigin || class != c.expectedClass || retryAfter != c.expectedRetryAfter {
+				t.Errorf("expected %s %s retry after %v, but got %s %s retry after %v",
+					c.expectedOrigin, c.expectedClass, c.expectedRetryAfter, origin, class, retryAfter)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	cases := []struct {
+		name          string
+		origin        errorOrigin
+		class         errorClass
+		retryAfter    time.Duration
+		expectedDelay time.Duration
+	}{
+		{name: "throttled", origin: originSpokeWrite, class: classThrottled, expectedDelay: throttledRetryDelay},
+		{name: "throttled with retry after", origin: originSpokeWrite, class: classThrottled, retryAfter: 30 * time.Second, expectedDelay: 30 * time.Second},
+		{name: "throttled with long retry after", origin: originSpokeRead, class: classThrottled, retryAfter: time.Hour, expectedDelay: maxRetryDelay},
+		{name: "conflict", origin: originSpokeWrite, class: classConflict, expectedDelay: conflictRetryDelay},
+		{name: "transient", origin: originSpokeRead, class: classTransient, expectedDelay: transientRetryDelay},
+		{name: "terminal", origin: originSpokeWrite, class: classTerminal},
+		{name: "hub", origin: originHubWrite, class: classThrottled, retryAfter: 30 * time.Second},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if delay := retryDelay(c.origin, c.class, c.retryAfter); delay != c.expectedDelay {
+				t.Errorf("expected delay %v, but got %v", c.expectedDelay, delay)
+			}
+		})
+	}
+}
+
+func TestWithRetryDelays(t *testing.T) {
+	hubErr := withOrigin(originHubWrite, errors.NewTooManyRequests("slow down", 1))
+	err := withRetryDelays(originSpokeWrite, utilerrors.NewAggregate([]error{
+		utilerrors.NewAggregate([]error{
+			fmt.Errorf("manifest 0: %w", errors.NewTooManyRequests("slow down", 10)),
+			fmt.Errorf("manifest 1: %w", &helper.NotAllowedError{Err: fmt.Errorf("not allowed"), RequeueTime: time.Minute}),
+		}),
+		hubErr,
+	}))
+
+	var aggregate utilerrors.Aggregate
+	if !goerrors.As(err, &aggregate) || len(aggregate.Errors()) != 3 {
+		t.Fatalf("expected 3 errors flattened, but got %v", err)
+	}
+	var retryAfterErr *helper.RetryAfterError
+	if !goerrors.As(aggregate.Errors()[0], &retryAfterErr) ||
+		retryAfterErr.RequeueTime < 10*time.Second || retryAfterErr.RequeueTime > 15*time.Second {
+		t.Errorf("expected the throttled error retried after the jittered Retry-After, but got %v", aggregate.Errors()[0])
+	}
+	var notAllowedErr *helper.NotAllowedError
+	if !goerrors.As(aggregate.Errors()[1], &notAllowedErr) || goerrors.As(aggregate.Errors()[1], &retryAfterErr) {
+		t.Errorf("expected the not allowed error kept, but got %v", aggregate.Errors()[1])
+	}
+	if aggregate.Errors()[2] != hubErr {
+		t.Errorf("expected the hub error kept, but got %v", aggregate.Errors()[2])
+	}
+
+	// the hub error is returned to the controller factory, the others are requeued after the min delay
+	requeueAfter, remaining := helper.SplitNotAllowedErrors(err)
+	if requeueAfter != retryAfterErr.RequeueTime || remaining == nil || remaining.Error() != hubErr.Error() {
+		t.Errorf("expected requeue after %v with the hub error, but got %v with %v", retryAfterErr.RequeueTime, requeueAfter, remaining)
+	}
+}
@@ -0,0 +1,122 @@
+package manifestcontroller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+// applyEvent is an event of the apply of a manifest.
+type applyEvent struct {
+	warning      bool
+	reason       string
+	message      string
+	resourceMeta workapiv1.ManifestResourceMeta
+}
+
+// applyEventsOf returns the events of the apply results, which are the manifests failed to apply and the ones
+// changed by the apply.
+func applyEventsOf(results []applyResult) []applyEvent {
+	applyEvents := []applyEvent{}
+	for _, result := range results {
+		switch {
+		case result.Error != nil:
+			applyEvents = append(applyEvents, applyEvent{
+				warning: true,
+				reason:  constants.EventReasonResourceAppliedFailed,
+				message: fmt.Sprintf("Failed to apply manifest %d%s: %v",
+					result.resourceMeta.Ordinal, resourceMessage(result.resourceMeta), result.Error),
+				resourceMeta: result.resourceMeta,
+			})
+		case result.Changed:
+			applyEvents = append(applyEvents, applyEvent{
+				reason:       constants.EventReasonResourceApplied,
+				message:      fmt.Sprintf("Applied manifest %d%s", result.resourceMeta.Ordinal, resourceMessage(result.resourceMeta)),
+				resourceMeta: result.resourceMeta,
+			})
+		}
+	}
+	return applyEvents
+}
+
+// eventFingerprint returns the fingerprint of the apply events of the generation of a manifestwork. It is the hash
+// of the reasons and the resources of the events, the messages are not included since the errors may differ in
+// details on each apply. An empty string is returned if there is no event.
+func eventFingerprint(generation int64, applyEvents []applyEvent) string {
+	if len(applyEvents) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(applyEvents))
+	for _, event := range applyEvents {
+		resourceMeta := event.resourceMeta
+		keys = append(keys, fmt.Sprintf("%s/%d/%s/%s/%s/%s/%s", event.reason, resourceMeta.Ordinal,
+			resourceMeta.Group, resourceMeta.Resource, resourceMeta.Kind, resourceMeta.Namespace, resourceMeta.Name))
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d", generation)
+	for _, key := range keys {
+		fmt.Fprintf(hash, "\n%s", key)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))[:16]
+}
+
+// emitApplyEvents emits the apply events of the manifestwork only if their fingerprint differs from the one recorded
+// on the appliedmanifestwork, and records the new fingerprint, so that the same events are neither emitted on each
+// resync nor again once the agent restarts. A reconcile without any event keeps the recorded fingerprint, since the
+// manifests re-applied after a restart may report the changes again. The events are emitted on each reconcile if the
+// fingerprints are not persisted.
+func (m *ManifestWorkController) emitApplyEvents(
+	ctx context.Context,
+	recorder events.Recorder,
+	appliedManifestWork *workapiv1.AppliedManifestWork,
+	generation int64,
+	applyEvents []applyEvent) {
+	if !m.persistEventFingerprints {
+		for _, event := range applyEvents {
+			emitApplyEvent(recorder, event)
+		}
+		return
+	}
+
+	fingerprint := eventFingerprint(generation, applyEvents)
+	if len(fingerprint) == 0 || appliedManifestWork.Annotations[constants.EventFingerprintAnnotationKey] == fingerprint {
+		return
+	}
+
+	for _, event := range applyEvents {
+		emitApplyEvent(recorder, event)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{constants.EventFingerprintAnnotationKey: fingerprint},
+		},
+	})
+	if err == nil {
+		_, err = m.appliedManifestWorkClient.Patch(ctx, appliedManifestWork.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	}
+	if err != nil {
+		// the events may be emitted again on the next reconcile, which is harmless
+		klog.Warningf("Failed to record the event fingerprint on appliedmanifestwork %s: %v", appliedManifestWork.Name, err)
+	}
+}
+
+func emitApplyEvent(recorder events.Recorder, event applyEvent) {
+	if event.warning {
+		recorder.Warning(event.reason, event.message)
+		return
+	}
+	recorder.Event(event.reason, event.message)
+}
@@ -0,0 +1,103 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestEventFingerprint(t *testing.T) {
+	secret := workapiv1.ManifestResourceMeta{Ordinal: 0, Kind: "Secret", Resource: "secrets", Namespace: "ns1", Name: "test"}
+	configMap := workapiv1.ManifestResourceMeta{Ordinal: 1, Kind: "ConfigMap", Resource: "configmaps", Namespace: "ns1", Name: "test"}
+	applied := applyEvent{reason: constants.EventReasonResourceApplied, message: "applied", resourceMeta: secret}
+	failed := applyEvent{warning: true, reason: constants.EventReasonResourceAppliedFailed, message: "timeout", resourceMeta: configMap}
+
+	if fingerprint := eventFingerprint(1, nil); len(fingerprint) != 0 {
+		t.Errorf("expected no fingerprint without events, but got %q", fingerprint)
+	}
+
+	fingerprint := eventFingerprint(1, []applyEvent{applied, failed})
+	if reordered := eventFingerprint(1, []applyEvent{failed, applied}); reordered != fingerprint {
+		t.Errorf("expected the fingerprint independent of the order of the events")
+	}
+	failedAgain := failed
+	failedAgain.message = "connection refused"
+	if fingerprint2 := eventFingerprint(1, []applyEvent{applied, failedAgain}); fingerprint2 != fingerprint {
+		t.Errorf("expected the fingerprint independent of the messages of the events")
+	}
+	if fingerprint2 := eventFingerprint(2, []applyEvent{applied, failed}); fingerprint2 == fingerprint {
+		t.Errorf("expected the fingerprint changed with the generation")
+	}
+	if fingerprint2 := eventFingerprint(1, []applyEvent{applied}); fingerprint2 == fingerprint {
+		t.Errorf("expected the fingerprint changed with the events")
+	}
+}
+
+// Test the apply events are not emitted again once the agent restarts
+func TestApplyEventsAcrossRestarts(t *testing.T) {
+	work, workKey := spoketesting.NewManifestWork(0, spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"))
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	work.Generation = 1
+
+	// the controller starts with the appliedmanifestwork left by the last run
+	startController := func(work *workapiv1.ManifestWork, appliedWork *workapiv1.AppliedManifestWork) *testController {
+		controller := newController(work, appliedWork, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+		controller.controller.hubHash = "hub1"
+		controller.controller.persistEventFingerprints = true
+		controller.kubeClient.PrependReactor("create", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, fmt.Errorf("webhook is not responding")
+		})
+		return controller
+	}
+	syncAndCountEvents := func(controller *testController) int {
+		recorder := events.NewInMemoryRecorder("work-agent")
+		if err := controller.controller.sync(context.TODO(), spoketesting.NewFakeSyncContext(t, workKey).WithRecorder(recorder)); err == nil {
+			t.Errorf("expected the apply failed")
+		}
+		count := 0
+		for _, event := range recorder.Events() {
+			if event.Reason == constants.EventReasonResourceAppliedFailed {
+				count++
+			}
+		}
+		return count
+	}
+	appliedWorkOf := func(controller *testController) *workapiv1.AppliedManifestWork {
+		appliedWork, err := controller.workClient.WorkV1().AppliedManifestWorks().Get(context.TODO(), "hub1-"+work.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return appliedWork
+	}
+
+	controller := startController(work, nil)
+	if count := syncAndCountEvents(controller); count != 1 {
+		t.Fatalf("expected 1 event on the first run, but got %d", count)
+	}
+	appliedWork := appliedWorkOf(controller)
+	if len(appliedWork.Annotations[constants.EventFingerprintAnnotationKey]) == 0 {
+		t.Fatalf("expected the event fingerprint recorded, but got %v", appliedWork.Annotations)
+	}
+
+	// the same failure is not reported again after the restart
+	controller = startController(work, appliedWork)
+	if count := syncAndCountEvents(controller); count != 0 {
+		t.Errorf("expected no duplicate event after the restart, but got %d", count)
+	}
+
+	// the failure is reported again once the manifestwork is updated
+	updatedWork := work.DeepCopy()
+	updatedWork.Generation = 2
+	controller = startController(updatedWork, appliedWork)
+	if count := syncAndCountEvents(controller); count != 1 {
+		t.Errorf("expected 1 event once the manifestwork is updated, but got %d", count)
+	}
+}
@@ -0,0 +1,67 @@
+package manifestcontroller
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+var jobGroupKind = schema.GroupKind{Group: "batch", Kind: "Job"}
+
+// injectJobTTL returns the manifest of a Job with the ttlSecondsAfterFinished set to the ttl if the manifest does not
+// set it, so that the completed Job and its pods are deleted by the TTL controller of the managed cluster. The
+// manifest is returned as is if the ttl is nil, or it is not a Job.
+func injectJobTTL(manifest workapiv1.Manifest, ttl *int64) (workapiv1.Manifest, error) {
+	if ttl == nil {
+		return manifest, nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+		return manifest, err
+	}
+	if obj.GroupVersionKind().GroupKind() != jobGroupKind {
+		return manifest, nil
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "ttlSecondsAfterFinished"); found {
+		return manifest, nil
+	}
+
+	if err := unstructured.SetNestedField(obj.Object, *ttl, "spec", "ttlSecondsAfterFinished"); err != nil {
+		return manifest, err
+	}
+	raw, err := obj.MarshalJSON()
+	if err != nil {
+		return manifest, err
+	}
+	return workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}}, nil
+}
+
+// skipExpiredJobs marks the results of the manifests of the Jobs deleted once their ttlSecondsAfterFinished expired
+// as skipped, so that the completed Jobs are not created and run again. A Job is expired if it is reported with the
+// reason ResourceCompletedAndExpired by the status of the manifestwork, and it is applied again once the manifest is
+// changed to another Job.
+func skipExpiredJobs(manifestWork *workapiv1.ManifestWork, results []applyResult) {
+	for _, manifest := range manifestWork.Status.ResourceStatus.Manifests {
+		resourceMeta := manifest.ResourceMeta
+		available := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestAvailable))
+		if available == nil || available.Reason != helper.ResourceCompletedAndExpiredReason || !helper.IsJob(resourceMeta) ||
+			int(resourceMeta.Ordinal) >= len(manifestWork.Spec.Workload.Manifests) {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(manifestWork.Spec.Workload.Manifests[resourceMeta.Ordinal].Raw); err != nil {
+			continue
+		}
+		if obj.GroupVersionKind().GroupKind() != jobGroupKind || obj.GetName() != resourceMeta.Name ||
+			(len(obj.GetNamespace()) != 0 && obj.GetNamespace() != resourceMeta.Namespace) {
+			continue
+		}
+		results[resourceMeta.Ordinal] = applyResult{resourceMeta: resourceMeta, skipped: true}
+	}
+}
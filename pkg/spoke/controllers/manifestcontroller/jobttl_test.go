@@ -0,0 +1,103 @@
+package manifestcontroller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestInjectJobTTL(t *testing.T) {
+	ttl := int64(600)
+	explicit := spoketesting.NewUnstructuredWithContent("batch/v1", "Job", "ns1", "job1",
+		map[string]interface{}{"spec": map[string]interface{}{"ttlSecondsAfterFinished": int64(0)}})
+
+	cases := []struct {
+		name        string
+		manifest    *unstructured.Unstructured
+		ttl         *int64
+		expectedTTL interface{}
+	}{
+		{
+			name:        "inject into job without ttl",
+			manifest:    spoketesting.NewUnstructured("batch/v1", "Job", "ns1", "job1"),
+			ttl:         &ttl,
+			expectedTTL: int64(600),
+		},
+		{
+			name:        "keep the explicit ttl",
+			manifest:    explicit,
+			ttl:         &ttl,
+			expectedTTL: int64(0),
+		},
+		{
+			name:     "not inject into other kinds",
+			manifest: spoketesting.NewUnstructured("v1", "Secret", "ns1", "n1"),
+			ttl:      &ttl,
+		},
+		{
+			name:     "not inject if disabled",
+			manifest: spoketesting.NewUnstructured("batch/v1", "Job", "ns1", "job1"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, _ := spoketesting.NewManifestWork(0, c.manifest)
+			manifest, err := injectJobTTL(work.Spec.Workload.Manifests[0], c.ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj := &unstructured.Unstructured{}
+			if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+				t.Fatal(err)
+			}
+			actual, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "ttlSecondsAfterFinished")
+			switch {
+			case c.expectedTTL == nil && found:
+				t.Errorf("expected no ttl, but got %v", actual)
+			case c.expectedTTL != nil && actual != c.expectedTTL:
+				t.Errorf("expected ttl %v, but got %v", c.expectedTTL, actual)
+			}
+		})
+	}
+}
+
+func TestSkipExpiredJobs(t *testing.T) {
+	newJobCondition := func(ordinal int32, name, reason string) workapiv1.ManifestCondition {
+		return workapiv1.ManifestCondition{
+			ResourceMeta: workapiv1.ManifestResourceMeta{
+				Ordinal: ordinal, Group: "batch", Version: "v1", Kind: "Job", Resource: "jobs", Namespace: "ns1", Name: name},
+			Conditions: []metav1.Condition{
+				{Type: string(workapiv1.ManifestAvailable), Status: metav1.ConditionTrue, Reason: reason},
+			},
+		}
+	}
+
+	work, _ := spoketesting.NewManifestWork(0,
+		spoketesting.NewUnstructured("batch/v1", "Job", "ns1", "expired"),
+		spoketesting.NewUnstructured("batch/v1", "Job", "ns1", "completed"),
+		spoketesting.NewUnstructured("batch/v1", "Job", "ns1", "renamed"),
+	)
+	work.Status.ResourceStatus.Manifests = []workapiv1.ManifestCondition{
+		newJobCondition(0, "expired", helper.ResourceCompletedAndExpiredReason),
+		newJobCondition(1, "completed", helper.ResourceCompletedReason),
+		// the manifest is changed to another job since the job expired
+		newJobCondition(2, "expired-before", helper.ResourceCompletedAndExpiredReason),
+	}
+
+	results := make([]applyResult, 3)
+	skipExpiredJobs(work, results)
+	for ordinal, expected := range []bool{true, false, false} {
+		if results[ordinal].skipped != expected {
+			t.Errorf("expected manifest %d skipped %t, but got %t", ordinal, expected, results[ordinal].skipped)
+		}
+	}
+	if results[0].resourceMeta.Name != "expired" {
+		t.Errorf("expected the resource meta of the expired job kept, but got %v", results[0].resourceMeta)
+	}
+}
@@ -0,0 +1,30 @@
+package manifestcontroller
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// getLiveObject returns the existing resource from the live object cache shared with the other controllers, or from
+// the spoke apiserver if the cache is nil. The resource returned must not be modified.
+func (m *ManifestWorkController) getLiveObject(
+	ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error) {
+	if m.liveObjects != nil {
+		return m.liveObjects.Get(ctx, gvr, namespace, name)
+	}
+	return m.spokeDynamicClient.Resource(gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// invalidateLiveObject drops the resource from the live object cache once the manifest is applied, whether the apply
+// succeeded or not, since the resource may be written even if the apply failed.
+func (m *ManifestWorkController) invalidateLiveObject(resourceMeta workapiv1.ManifestResourceMeta) {
+	if len(resourceMeta.Resource) == 0 {
+		return
+	}
+	gvr := schema.GroupVersionResource{Group: resourceMeta.Group, Version: resourceMeta.Version, Resource: resourceMeta.Resource}
+	m.liveObjects.Invalidate(gvr, resourceMeta.Namespace, resourceMeta.Name)
+}
@@ -0,0 +1,46 @@
+package manifestcontroller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestApplyInvalidatesLiveObjects(t *testing.T) {
+	secrets := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	work, workKey := spoketesting.NewManifestWork(0, spoketesting.NewUnstructured("v1", "Secret", "ns1", "n1"))
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	controller := newController(work, nil, spoketesting.NewFakeRestMapper()).
+		withKubeObject().
+		withUnstructuredObject()
+	liveObjects := helper.NewLiveObjectCache(controller.dynamicClient, time.Hour)
+	controller.controller.liveObjects = liveObjects
+
+	// the secret is looked up by another controller before it is applied, so it is cached as not found
+	if _, err := liveObjects.Get(context.TODO(), secrets, "ns1", "n1"); !errors.IsNotFound(err) {
+		t.Fatalf("expected the secret not found, but got %v", err)
+	}
+
+	syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+	if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+		t.Fatal(err)
+	}
+	if len(controller.kubeClient.Actions()) == 0 {
+		t.Fatalf("expected the secret applied")
+	}
+
+	// the secret applied is fetched from the spoke instead of the cache
+	controller.dynamicClient.ClearActions()
+	if _, err := liveObjects.Get(context.TODO(), secrets, "ns1", "n1"); !errors.IsNotFound(err) {
+		t.Fatalf("expected the secret not found by the fake dynamic client, but got %v", err)
+	}
+	if len(controller.dynamicClient.Actions()) != 1 {
+		t.Errorf("expected the secret invalidated once applied, but got actions %v", controller.dynamicClient.Actions())
+	}
+}
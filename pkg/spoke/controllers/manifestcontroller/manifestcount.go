@@ -0,0 +1,67 @@
+package manifestcontroller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+var (
+	manifestWorkManifests = metrics.NewHistogram(
+		&metrics.HistogramOpts{
+			Name:    "work_agent_manifestwork_manifests",
+			Help:    "Number of manifests of the ManifestWorks reconciled by the agent.",
+			Buckets: helper.ManifestCountBuckets,
+		},
+	)
+	tooManyManifests = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name: "work_agent_manifestworks_too_many_manifests_total",
+			Help: "Number of reconciles of ManifestWorks not applied since they have more manifests than the max allowed by the agent.",
+		},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(manifestWorkManifests, tooManyManifests)
+}
+
+// exceedsMaxManifests checks if the manifestwork has more manifests than the max, it is not limited if the max is not
+// positive. The number of manifests is exposed as a metric regardless of the max.
+func exceedsMaxManifests(manifestWork *workapiv1.ManifestWork, max int) bool {
+	count := len(manifestWork.Spec.Workload.Manifests)
+	manifestWorkManifests.Observe(float64(count))
+	if max <= 0 || count <= max {
+		return false
+	}
+	tooManyManifests.Inc()
+	return true
+}
+
+// setTooManyManifestsCondition sets the condition TooManyManifests of the manifestwork, the condition is removed
+// once the manifestwork is applied again.
+func (m *ManifestWorkController) setTooManyManifestsCondition(ctx context.Context, manifestWork *workapiv1.ManifestWork, maxManifests int) error {
+	message := helper.TooManyManifestsMessage(len(manifestWork.Spec.Workload.Manifests), maxManifests)
+	if existing := meta.FindStatusCondition(manifestWork.Status.Conditions, constants.WorkTooManyManifests); existing != nil &&
+		existing.Status == metav1.ConditionTrue && existing.ObservedGeneration == manifestWork.Generation && existing.Message == message {
+		return nil
+	}
+
+	return m.updateStatus(ctx, manifestWork, func(status *workapiv1.ManifestWorkStatus) error {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               constants.WorkTooManyManifests,
+			Status:             metav1.ConditionTrue,
+			Reason:             "MaxManifestsExceeded",
+			ObservedGeneration: manifestWork.Generation,
+			Message:            message,
+		})
+		return nil
+	})
+}
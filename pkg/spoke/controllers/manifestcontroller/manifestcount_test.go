@@ -0,0 +1,100 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestSyncTooManyManifests(t *testing.T) {
+	tooManyManifestsCondition := metav1.Condition{
+		Type:    constants.WorkTooManyManifests,
+		Status:  metav1.ConditionTrue,
+		Reason:  "MaxManifestsExceeded",
+		Message: "the manifestwork has 3 manifests which exceeds the limit of 2 manifests",
+	}
+
+	cases := []struct {
+		name              string
+		manifests         int
+		maxManifests      int
+		conditions        []metav1.Condition
+		expectedApplied   bool
+		expectedCondition bool
+		expectedNoUpdate  bool
+	}{
+		{
+			name:            "exactly at the limit",
+			manifests:       2,
+			maxManifests:    2,
+			expectedApplied: true,
+		},
+		{
+			name:              "one over the limit",
+			manifests:         3,
+			maxManifests:      2,
+			expectedCondition: true,
+		},
+		{
+			name:              "oversized work with the condition",
+			manifests:         3,
+			maxManifests:      2,
+			conditions:        []metav1.Condition{tooManyManifestsCondition},
+			expectedCondition: true,
+			expectedNoUpdate:  true,
+		},
+		{
+			name:            "oversized work reduced to the limit",
+			manifests:       2,
+			maxManifests:    2,
+			conditions:      []metav1.Condition{tooManyManifestsCondition},
+			expectedApplied: true,
+		},
+		{
+			name:            "not limited",
+			manifests:       3,
+			expectedApplied: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			manifests := []*unstructured.Unstructured{}
+			for i := 0; i < c.manifests; i++ {
+				manifests = append(manifests, spoketesting.NewUnstructured("v1", "Secret", "ns1", fmt.Sprintf("test%d", i)))
+			}
+			work, workKey := spoketesting.NewManifestWork(0, manifests...)
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			work.Status.Conditions = c.conditions
+			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+			controller.controller.limits = NewApplyLimits(0, 0, c.maxManifests, nil)
+
+			if err := controller.controller.sync(context.TODO(), spoketesting.NewFakeSyncContext(t, workKey)); err != nil {
+				t.Fatal(err)
+			}
+
+			if applied := len(controller.kubeClient.Actions()) != 0; applied != c.expectedApplied {
+				t.Errorf("expected applied %t, but got actions %v", c.expectedApplied, controller.kubeClient.Actions())
+			}
+
+			workActions := controller.workClient.Actions()
+			if c.expectedNoUpdate {
+				if len(workActions) != 0 {
+					t.Errorf("expected no status update, but got %v", workActions)
+				}
+				return
+			}
+			updatedWork := lastUpdatedWork(t, workActions)
+			if exceeded := meta.IsStatusConditionTrue(updatedWork.Status.Conditions, constants.WorkTooManyManifests); exceeded != c.expectedCondition {
+				t.Errorf("expected TooManyManifests condition %t, but got %v", c.expectedCondition, updatedWork.Status.Conditions)
+			}
+		})
+	}
+}
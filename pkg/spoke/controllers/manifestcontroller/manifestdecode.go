@@ -0,0 +1,116 @@
+package manifestcontroller
+
+import (
+	goerrors "errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/applier"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+// manifestDecodeFailedReason is the reason of the Degraded condition of a manifest which cannot be decoded, and of
+// the ManifestDecodeError condition of the manifestwork.
+const manifestDecodeFailedReason = "ManifestDecodeFailed"
+
+// checkManifestDecodable returns a ResourceError of ErrManifestDecodeFailed if the raw manifest cannot be decoded,
+// e.g. it is truncated or corrupted on the hub.
+func checkManifestDecodable(manifest workapiv1.Manifest) error {
+	if manifest.Object != nil {
+		return nil
+	}
+	if _, err := applier.Decode(manifest.Raw); err != nil {
+		return applyerrors.NewManifestDecodeFailedError(err)
+	}
+	return nil
+}
+
+func isManifestDecodeFailedError(err error) bool {
+	return goerrors.Is(err, applyerrors.ErrManifestDecodeFailed)
+}
+
+// keepLastKnownResources sets the resource meta of the manifests failed to decode to the last known one in the status
+// of the manifestwork with the same ordinal, so that the resources applied from them are still tracked by the
+// appliedmanifestwork instead of being pruned as if the manifests were removed. The ordinals of the manifests failed
+// to decode are returned.
+func keepLastKnownResources(manifestWork *workapiv1.ManifestWork, results []applyResult) []int32 {
+	lastKnown := map[int32]workapiv1.ManifestResourceMeta{}
+	for _, manifest := range manifestWork.Status.ResourceStatus.Manifests {
+		if len(manifest.ResourceMeta.Resource) == 0 || len(manifest.ResourceMeta.Name) == 0 {
+			continue
+		}
+		lastKnown[manifest.ResourceMeta.Ordinal] = manifest.ResourceMeta
+	}
+
+	var failed []int32
+	for index, result := range results {
+		if !isManifestDecodeFailedError(result.Error) {
+			continue
+		}
+		failed = append(failed, result.resourceMeta.Ordinal)
+		if resourceMeta, ok := lastKnown[result.resourceMeta.Ordinal]; ok {
+			results[index].resourceMeta = resourceMeta
+		}
+	}
+	return failed
+}
+
+// manifestDecodeFailedCondition returns the Degraded condition of the manifest failed to decode with the error.
+func manifestDecodeFailedCondition(err error) metav1.Condition {
+	return metav1.Condition{
+		Type:    string(workapiv1.ManifestDegraded),
+		Status:  metav1.ConditionTrue,
+		Reason:  manifestDecodeFailedReason,
+		Message: fmt.Sprintf("Failed to decode manifest, the resource applied from it is kept: %v", err),
+	}
+}
+
+// withManifestDecodeErrorCondition returns a function updating the status with the updateStatusFunc, and setting
+// the condition ManifestDecodeError of the manifestwork with the ordinals of the manifests failed to decode, or
+// removing the condition otherwise. The Degraded conditions of the manifests which are decoded again are removed.
+func withManifestDecodeErrorCondition(
+	updateStatusFunc helper.UpdateManifestWorkStatusFunc, generation int64, failed []int32) helper.UpdateManifestWorkStatusFunc {
+	return func(oldStatus *workapiv1.ManifestWorkStatus) error {
+		if err := updateStatusFunc(oldStatus); err != nil {
+			return err
+		}
+
+		failedOrdinals := map[int32]bool{}
+		for _, ordinal := range failed {
+			failedOrdinals[ordinal] = true
+		}
+		for i, manifest := range oldStatus.ResourceStatus.Manifests {
+			if failedOrdinals[manifest.ResourceMeta.Ordinal] {
+				continue
+			}
+			if degraded := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestDegraded)); degraded != nil &&
+				degraded.Reason == manifestDecodeFailedReason {
+				meta.RemoveStatusCondition(&oldStatus.ResourceStatus.Manifests[i].Conditions, string(workapiv1.ManifestDegraded))
+			}
+		}
+
+		if len(failed) == 0 {
+			meta.RemoveStatusCondition(&oldStatus.Conditions, constants.WorkManifestDecodeError)
+			return nil
+		}
+		ordinals := make([]string, 0, len(failed))
+		for _, ordinal := range failed {
+			ordinals = append(ordinals, fmt.Sprintf("%d", ordinal))
+		}
+		oldStatus.Conditions = helper.MergeStatusConditions(oldStatus.Conditions, []metav1.Condition{{
+			Type:               constants.WorkManifestDecodeError,
+			Status:             metav1.ConditionTrue,
+			Reason:             manifestDecodeFailedReason,
+			ObservedGeneration: generation,
+			Message: fmt.Sprintf("Failed to decode manifests %s, the resources applied from them are kept until they are fixed",
+				strings.Join(ordinals, ", ")),
+		}})
+		return nil
+	}
+}
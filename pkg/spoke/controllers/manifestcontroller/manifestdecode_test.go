@@ -0,0 +1,116 @@
+package manifestcontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestSyncWithManifestDecodeFailure(t *testing.T) {
+	n2Meta := workapiv1.ManifestResourceMeta{
+		Ordinal: 1, Version: "v1", Kind: "Secret", Resource: "secrets", Namespace: "ns1", Name: "n2"}
+
+	cases := []struct {
+		name                 string
+		corrupted            bool
+		existingDecodeFailed bool
+		expectedDecodeError  bool
+	}{
+		{
+			name:                "one bad manifest among good ones",
+			corrupted:           true,
+			expectedDecodeError: true,
+		},
+		{
+			name:                 "bad manifest fixed",
+			existingDecodeFailed: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0,
+				spoketesting.NewUnstructured("v1", "Secret", "ns1", "n1"),
+				spoketesting.NewUnstructured("v1", "Secret", "ns1", "n2"),
+				spoketesting.NewUnstructured("v1", "Secret", "ns1", "n3"),
+			)
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			if c.corrupted {
+				// the manifest is truncated on the hub
+				work.Spec.Workload.Manifests[1].Raw = []byte(`{"apiVersion":"v1","kind":"Secret","metadata":{"name":"n2"`)
+			}
+			// the manifest was applied before it is corrupted
+			work.Status.ResourceStatus.Manifests = []workapiv1.ManifestCondition{{
+				ResourceMeta: n2Meta,
+				Conditions: []metav1.Condition{
+					{Type: string(workapiv1.ManifestApplied), Status: metav1.ConditionTrue, Reason: "AppliedManifestComplete"},
+				},
+			}}
+			if c.existingDecodeFailed {
+				work.Status.ResourceStatus.Manifests[0].Conditions = append(work.Status.ResourceStatus.Manifests[0].Conditions,
+					metav1.Condition{Type: string(workapiv1.ManifestDegraded), Status: metav1.ConditionTrue, Reason: manifestDecodeFailedReason})
+				work.Status.Conditions = []metav1.Condition{
+					{Type: constants.WorkManifestDecodeError, Status: metav1.ConditionTrue, Reason: manifestDecodeFailedReason},
+				}
+			}
+			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+
+			// the manifest failed to decode is not retried until the manifestwork is changed
+			if err := controller.controller.sync(context.TODO(), spoketesting.NewFakeSyncContext(t, workKey)); err != nil {
+				t.Fatal(err)
+			}
+
+			// nothing is pruned on the spoke
+			for _, action := range append(controller.kubeClient.Actions(), controller.dynamicClient.Actions()...) {
+				if action.GetVerb() == "delete" {
+					t.Errorf("expected no resource deleted, but got %v", action)
+				}
+			}
+
+			workActions := controller.workClient.Actions()
+			updatedWork := lastUpdatedWork(t, workActions)
+			manifests := updatedWork.Status.ResourceStatus.Manifests
+			if len(manifests) != 3 {
+				t.Fatalf("expected 3 manifest conditions, but got %v", manifests)
+			}
+			for _, manifest := range manifests {
+				applied := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestApplied))
+				degraded := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestDegraded))
+				if c.expectedDecodeError && manifest.ResourceMeta.Ordinal == 1 {
+					// the resource applied from the bad manifest is still tracked
+					if manifest.ResourceMeta != n2Meta {
+						t.Errorf("expected the last known resource %v kept, but got %v", n2Meta, manifest.ResourceMeta)
+					}
+					if applied == nil || applied.Status != metav1.ConditionFalse || applied.Reason != manifestDecodeFailedReason {
+						t.Errorf("expected Applied condition false with reason %s, but got %v", manifestDecodeFailedReason, applied)
+					}
+					if degraded == nil || degraded.Status != metav1.ConditionTrue || degraded.Reason != manifestDecodeFailedReason {
+						t.Errorf("expected Degraded condition true with reason %s, but got %v", manifestDecodeFailedReason, degraded)
+					}
+					continue
+				}
+				if applied == nil || applied.Status != metav1.ConditionTrue {
+					t.Errorf("expected manifest %d applied, but got %v", manifest.ResourceMeta.Ordinal, applied)
+				}
+				if degraded != nil {
+					t.Errorf("expected manifest %d not degraded, but got %v", manifest.ResourceMeta.Ordinal, degraded)
+				}
+			}
+
+			decodeError := meta.FindStatusCondition(updatedWork.Status.Conditions, constants.WorkManifestDecodeError)
+			switch {
+			case c.expectedDecodeError && (decodeError == nil || !strings.Contains(decodeError.Message, "manifests 1,")):
+				t.Errorf("expected ManifestDecodeError condition naming manifest 1, but got %v", decodeError)
+			case !c.expectedDecodeError && decodeError != nil:
+				t.Errorf("expected no ManifestDecodeError condition, but got %v", decodeError)
+			}
+		})
+	}
+}
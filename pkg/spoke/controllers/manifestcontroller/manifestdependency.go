@@ -0,0 +1,169 @@
+package manifestcontroller
+
+import (
+	goerrors "errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+const (
+	// waitingForDependencyReason is the reason of the Applied condition of a manifest waiting for the manifests it
+	// depends on to be available.
+	waitingForDependencyReason = "WaitingForDependency"
+	// dependencyDeadlineExceededReason is the reason of the Degraded condition of a manifestwork whose manifests wait
+	// for the manifests they depend on for longer than the dependency deadline.
+	dependencyDeadlineExceededReason = "DependencyDeadlineExceeded"
+)
+
+// DependencyRequeueTime is the interval to requeue a manifestwork with manifests waiting for the manifests they
+// depend on, it is exposed so that integration tests can crank it up. The manifestwork is requeued once its status
+// is changed as well, e.g. the manifests it depends on become available.
+var DependencyRequeueTime = 10 * time.Second
+
+// dependencyWaitingError is returned when a manifest is not applied since the manifests it depends on are not
+// available yet.
+type dependencyWaitingError struct {
+	ordinals []int32
+}
+
+func (e *dependencyWaitingError) Error() string {
+	ordinals := make([]string, 0, len(e.ordinals))
+	for _, ordinal := range e.ordinals {
+		ordinals = append(ordinals, fmt.Sprintf("%d", ordinal))
+	}
+	return fmt.Sprintf("waiting for manifests %s to be available", strings.Join(ordinals, ","))
+}
+
+// isDependencyWaitingError checks if the manifest is not applied because it waits for the manifests it depends on.
+func isDependencyWaitingError(err error) bool {
+	var waitingErr *dependencyWaitingError
+	return goerrors.As(err, &waitingErr)
+}
+
+// manifestDependencies are the manifests which the manifests of a manifestwork depend on and are not available yet.
+type manifestDependencies struct {
+	// unavailable are the ordinals of the unavailable manifests each manifest depends on, keyed by its ordinal
+	unavailable map[int32][]int32
+}
+
+// manifestDependenciesOf returns the manifestDependencies of the manifestwork with the dependencies returned by
+// helper.ManifestDependencies, nil is returned if none of the manifests depends on others. The availability of the
+// manifests is the Available condition in the status of the manifestwork, evaluated by the AvailableStatusController.
+func manifestDependenciesOf(manifestWork *workapiv1.ManifestWork, dependencies map[int32][]int32) *manifestDependencies {
+	if len(dependencies) == 0 {
+		return nil
+	}
+
+	available := map[int32]bool{}
+	for _, manifest := range manifestWork.Status.ResourceStatus.Manifests {
+		if meta.IsStatusConditionTrue(manifest.Conditions, string(workapiv1.ManifestAvailable)) {
+			available[manifest.ResourceMeta.Ordinal] = true
+		}
+	}
+
+	d := &manifestDependencies{unavailable: map[int32][]int32{}}
+	for ordinal, predecessors := range dependencies {
+		for _, predecessor := range predecessors {
+			if !available[predecessor] {
+				d.unavailable[ordinal] = append(d.unavailable[ordinal], predecessor)
+			}
+		}
+	}
+	return d
+}
+
+// wait returns a NotAllowedError if the manifest with the index depends on manifests not available yet, so that the
+// manifestwork is requeued after DependencyRequeueTime. It never waits if the dependencies are nil.
+func (d *manifestDependencies) wait(index int) error {
+	if d == nil || len(d.unavailable[int32(index)]) == 0 {
+		return nil
+	}
+	return &helper.NotAllowedError{
+		Err:         &dependencyWaitingError{ordinals: d.unavailable[int32(index)]},
+		RequeueTime: DependencyRequeueTime,
+	}
+}
+
+// dependencyWaits records since when the manifests of the manifestworks wait for the manifests they depend on in
+// memory, keyed by the name of the manifestwork. The wait starts again once the manifestwork is changed.
+type dependencyWaits struct {
+	lock  sync.Mutex
+	waits map[string]dependencyWait
+}
+
+type dependencyWait struct {
+	generation int64
+	since      time.Time
+}
+
+func newDependencyWaits() *dependencyWaits {
+	return &dependencyWaits{
+		waits: map[string]dependencyWait{},
+	}
+}
+
+// waitingSince records the manifestwork is waiting and returns since when it waits. The given time is returned if
+// the waits are nil.
+func (w *dependencyWaits) waitingSince(manifestWorkName string, generation int64, now time.Time) time.Time {
+	if w == nil {
+		return now
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	wait, ok := w.waits[manifestWorkName]
+	if !ok || wait.generation != generation {
+		wait = dependencyWait{generation: generation, since: now}
+		w.waits[manifestWorkName] = wait
+	}
+	return wait.since
+}
+
+// reset drops the wait of the manifestwork once none of its manifests waits or it is deleted. It does nothing if
+// the waits are nil.
+func (w *dependencyWaits) reset(manifestWorkName string) {
+	if w == nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	delete(w.waits, manifestWorkName)
+}
+
+// withDependencyDeadlineCondition returns a function updating the status with the updateStatusFunc, and setting the
+// condition Degraded of the manifestwork if the manifests waiting for the manifests they depend on exceed the
+// dependency deadline. The Degraded condition set for the deadline is removed otherwise, while the Degraded condition
+// of the other reasons, e.g. the drift of the resources, is left to the AvailableStatusController.
+func withDependencyDeadlineCondition(
+	updateStatusFunc helper.UpdateManifestWorkStatusFunc, generation int64, waiting int, deadline time.Duration, exceeded bool) helper.UpdateManifestWorkStatusFunc {
+	return func(oldStatus *workapiv1.ManifestWorkStatus) error {
+		if err := updateStatusFunc(oldStatus); err != nil {
+			return err
+		}
+		if !exceeded {
+			if existing := meta.FindStatusCondition(oldStatus.Conditions, string(workapiv1.WorkDegraded)); existing != nil &&
+				existing.Reason == dependencyDeadlineExceededReason {
+				meta.RemoveStatusCondition(&oldStatus.Conditions, string(workapiv1.WorkDegraded))
+			}
+			return nil
+		}
+		oldStatus.Conditions = helper.MergeStatusConditions(oldStatus.Conditions, []metav1.Condition{{
+			Type:               string(workapiv1.WorkDegraded),
+			Status:             metav1.ConditionTrue,
+			Reason:             dependencyDeadlineExceededReason,
+			ObservedGeneration: generation,
+			Message: fmt.Sprintf("%d manifests wait for the manifests they depend on to be available for longer than %s",
+				waiting, deadline),
+		}})
+		return nil
+	}
+}
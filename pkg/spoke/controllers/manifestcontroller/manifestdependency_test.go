@@ -0,0 +1,163 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestSyncWithManifestDependencies(t *testing.T) {
+	availableCondition := func(ordinal int32, name string) workapiv1.ManifestCondition {
+		return workapiv1.ManifestCondition{
+			ResourceMeta: workapiv1.ManifestResourceMeta{
+				Ordinal: ordinal, Version: "v1", Kind: "Secret", Resource: "secrets", Namespace: "ns1", Name: name,
+			},
+			Conditions: []metav1.Condition{{Type: string(workapiv1.ManifestAvailable), Status: metav1.ConditionTrue, Reason: "ResourceAvailable"}},
+		}
+	}
+
+	cases := []struct {
+		name            string
+		dependencies    string
+		deadline        string
+		waitingSince    time.Duration
+		statuses        []workapiv1.ManifestCondition
+		applied         bool
+		expectedCreated []string
+		// expectedReasons are the reasons of the Applied conditions of the manifests
+		expectedReasons  []string
+		expectedDegraded bool
+	}{
+		{
+			name:            "no dependencies",
+			expectedCreated: []string{"test0", "test1"},
+			expectedReasons: []string{"AppliedManifestComplete", "AppliedManifestComplete"},
+		},
+		{
+			name:            "wait for the manifest by ordinal",
+			dependencies:    `[{"ordinal": 1, "dependsOn": [{"ordinal": 0}]}]`,
+			expectedCreated: []string{"test0"},
+			expectedReasons: []string{"AppliedManifestComplete", "WaitingForDependency"},
+		},
+		{
+			name:            "wait for the manifest by kind and name",
+			dependencies:    `[{"ordinal": 0, "dependsOn": [{"kind": "Secret", "namespace": "ns1", "name": "test1"}]}]`,
+			expectedCreated: []string{"test1"},
+			expectedReasons: []string{"WaitingForDependency", "AppliedManifestComplete"},
+		},
+		{
+			name:            "the manifest depended on is available",
+			dependencies:    `[{"ordinal": 1, "dependsOn": [{"ordinal": 0}]}]`,
+			statuses:        []workapiv1.ManifestCondition{availableCondition(0, "test0")},
+			expectedCreated: []string{"test0", "test1"},
+			expectedReasons: []string{"AppliedManifestComplete", "AppliedManifestComplete"},
+		},
+		{
+			name:            "the manifest applied is not gated",
+			dependencies:    `[{"ordinal": 1, "dependsOn": [{"ordinal": 0}]}]`,
+			applied:         true,
+			expectedCreated: []string{"test0"},
+			expectedReasons: []string{"AppliedManifestComplete", "AppliedManifestComplete"},
+		},
+		{
+			name:             "the dependency deadline is exceeded",
+			dependencies:     `[{"ordinal": 1, "dependsOn": [{"ordinal": 0}]}]`,
+			deadline:         "5m",
+			waitingSince:     10 * time.Minute,
+			expectedCreated:  []string{"test0"},
+			expectedReasons:  []string{"AppliedManifestComplete", "WaitingForDependency"},
+			expectedDegraded: true,
+		},
+		{
+			name:            "within the dependency deadline",
+			dependencies:    `[{"ordinal": 1, "dependsOn": [{"ordinal": 0}]}]`,
+			deadline:        "5m",
+			waitingSince:    time.Minute,
+			expectedCreated: []string{"test0"},
+			expectedReasons: []string{"AppliedManifestComplete", "WaitingForDependency"},
+		},
+		{
+			name:            "cycle",
+			dependencies:    `[{"ordinal": 0, "dependsOn": [{"ordinal": 1}]}, {"ordinal": 1, "dependsOn": [{"ordinal": 0}]}]`,
+			expectedReasons: []string{"AppliedManifestFailedTerminal", "AppliedManifestFailedTerminal"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0,
+				spoketesting.NewUnstructured("v1", "Secret", "ns1", "test0"),
+				spoketesting.NewUnstructured("v1", "Secret", "ns1", "test1"))
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			work.Annotations = map[string]string{}
+			if len(c.dependencies) > 0 {
+				work.Annotations[constants.ManifestDependenciesAnnotationKey] = c.dependencies
+			}
+			if len(c.deadline) > 0 {
+				work.Annotations[constants.DependencyDeadlineAnnotationKey] = c.deadline
+			}
+			work.Status.ResourceStatus.Manifests = c.statuses
+
+			var appliedWork *workapiv1.AppliedManifestWork
+			var kubeObjects []runtime.Object
+			if c.applied {
+				appliedWork = spoketesting.NewAppliedManifestWork("", 0, "")
+				appliedWork.Name = fmt.Sprintf("-%s", work.Name)
+				appliedWork.Spec.ManifestWorkName = work.Name
+				appliedWork.Status.AppliedResources = []workapiv1.AppliedManifestResourceMeta{
+					{Version: "v1", Resource: "secrets", Namespace: "ns1", Name: "test1", UID: "uid1"},
+				}
+				secret := spoketesting.NewSecret("test1", "ns1", "")
+				secret.UID, secret.Type = "uid1", corev1.SecretTypeOpaque
+				kubeObjects = append(kubeObjects, secret)
+			}
+			controller := newController(work, appliedWork, spoketesting.NewFakeRestMapper()).withKubeObject(kubeObjects...).withUnstructuredObject()
+			controller.controller.dependencyWaits = newDependencyWaits()
+			if c.waitingSince > 0 {
+				controller.controller.dependencyWaits.waitingSince(work.Name, work.Generation, time.Now().Add(-c.waitingSince))
+			}
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			if err := controller.controller.sync(context.TODO(), syncContext); err != nil && len(c.dependencies) == 0 {
+				t.Fatal(err)
+			}
+
+			created := []string{}
+			for _, action := range controller.kubeClient.Actions() {
+				if action.GetVerb() == "create" {
+					created = append(created, action.(clienttesting.CreateAction).GetObject().(*corev1.Secret).Name)
+				}
+			}
+			if fmt.Sprint(created) != fmt.Sprint(c.expectedCreated) {
+				t.Errorf("expected %v created, but got %v", c.expectedCreated, created)
+			}
+
+			workActions := controller.workClient.Actions()
+			updatedWork := lastUpdatedWork(t, workActions)
+			reasons := []string{}
+			for _, manifest := range updatedWork.Status.ResourceStatus.Manifests {
+				if condition := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestApplied)); condition != nil {
+					reasons = append(reasons, condition.Reason)
+				}
+			}
+			if fmt.Sprint(reasons) != fmt.Sprint(c.expectedReasons) {
+				t.Errorf("expected reasons %v, but got %v", c.expectedReasons, reasons)
+			}
+
+			degraded := meta.FindStatusCondition(updatedWork.Status.Conditions, string(workapiv1.WorkDegraded))
+			if c.expectedDegraded != (degraded != nil && degraded.Reason == dependencyDeadlineExceededReason) {
+				t.Errorf("expected degraded %t, but got %v", c.expectedDegraded, degraded)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package manifestcontroller
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+var unchangedManifestsSkipped = metrics.NewCounter(
+	&metrics.CounterOpts{
+		Name: "work_agent_unchanged_manifests_skipped_total",
+		Help: "Number of manifests not applied again by the reconciles of ManifestWorks since they are not changed since they were applied.",
+	},
+)
+
+func init() {
+	legacyregistry.MustRegister(unchangedManifestsSkipped)
+}
+
+// manifestHashes caches the hashes of the manifests applied by the manifestworks in memory, keyed by the uid of the
+// manifestwork and the ordinal of the manifest, so that a reconcile triggered by the change of one manifest does not
+// apply all the other manifests of the manifestwork again. The cache is empty once the agent restarts, it is rebuilt
+// lazily as the manifestworks are applied.
+type manifestHashes struct {
+	lock  sync.Mutex
+	works map[string]*workManifestHashes
+}
+
+// workManifestHashes are the hashes of the manifests of a manifestwork by the ordinals of the manifests.
+type workManifestHashes struct {
+	uid    types.UID
+	hashes map[int32]appliedManifestHash
+	// checkpoint is the results of the manifests applied by the last reconcile if it was interrupted by the apply
+	// deadline, the next reconcile resumes from it.
+	checkpoint *applyCheckpoint
+}
+
+// applyCheckpoint is the results of the manifests applied by a reconcile of a generation of the manifestwork.
+type applyCheckpoint struct {
+	generation int64
+	results    []applyResult
+}
+
+// appliedManifestHash is the hash of an applied manifest and the uid of the resource applied from it.
+type appliedManifestHash struct {
+	hash string
+	uid  types.UID
+}
+
+func newManifestHashes() *manifestHashes {
+	return &manifestHashes{
+		works: map[string]*workManifestHashes{},
+	}
+}
+
+// forget drops the hashes of the manifestwork, so that all its manifests are applied by the next reconcile. It does
+// nothing if the cache is nil.
+func (h *manifestHashes) forget(manifestWorkName string) {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	delete(h.works, manifestWorkName)
+}
+
+// reconcileOf returns the view of the cache for a reconcile of the manifestwork. The hashes of a manifestwork
+// recreated with another uid and of the manifests removed from the manifestwork are dropped. Nil is returned if the
+// cache is nil.
+func (h *manifestHashes) reconcileOf(manifestWork *workapiv1.ManifestWork) *manifestHashView {
+	if h == nil {
+		return nil
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	work, ok := h.works[manifestWork.Name]
+	if !ok || work.uid != manifestWork.UID {
+		work = &workManifestHashes{uid: manifestWork.UID, hashes: map[int32]appliedManifestHash{}}
+		h.works[manifestWork.Name] = work
+	}
+	for ordinal := range work.hashes {
+		if int(ordinal) >= len(manifestWork.Spec.Workload.Manifests) {
+			delete(work.hashes, ordinal)
+		}
+	}
+
+	// the resources reported available and not drifted by the status
+	settled := map[int32]workapiv1.ManifestResourceMeta{}
+	for _, manifest := range manifestWork.Status.ResourceStatus.Manifests {
+		if meta.IsStatusConditionTrue(manifest.Conditions, string(workapiv1.ManifestAvailable)) &&
+			!meta.IsStatusConditionTrue(manifest.Conditions, string(workapiv1.ManifestDegraded)) {
+			settled[manifest.ResourceMeta.Ordinal] = manifest.ResourceMeta
+		}
+	}
+
+	return &manifestHashView{hashes: h, work: work, settled: settled}
+}
+
+// manifestHashView is the view of the manifest hashes of a manifestwork for a reconcile.
+type manifestHashView struct {
+	hashes  *manifestHashes
+	work    *workManifestHashes
+	settled map[int32]workapiv1.ManifestResourceMeta
+}
+
+// isUnchanged checks if the manifest is not changed since it was applied. It is unchanged if its hash and the uid
+// of the resource recorded on the appliedmanifestwork are the same as when it was applied, and the resource is
+// reported available and not drifted by the status of the manifestwork. False is returned if the view is nil.
+func (v *manifestHashView) isUnchanged(resourceMeta workapiv1.ManifestResourceMeta, hash string, recordedUID types.UID) bool {
+	if v == nil || len(recordedUID) == 0 {
+		return false
+	}
+	settled, ok := v.settled[resourceMeta.Ordinal]
+	if !ok || settled.Group != resourceMeta.Group || settled.Resource != resourceMeta.Resource ||
+		settled.Namespace != resourceMeta.Namespace || settled.Name != resourceMeta.Name {
+		return false
+	}
+
+	v.hashes.lock.Lock()
+	defer v.hashes.lock.Unlock()
+	applied, ok := v.work.hashes[resourceMeta.Ordinal]
+	return ok && applied.hash == hash && applied.uid == recordedUID
+}
+
+// record records the hash of the manifest applied, the hash is dropped if the uid of the resource applied is unknown.
+// It does nothing if the view is nil.
+func (v *manifestHashView) record(ordinal int32, hash string, uid types.UID) {
+	if v == nil {
+		return
+	}
+	v.hashes.lock.Lock()
+	defer v.hashes.lock.Unlock()
+
+	if len(hash) == 0 || len(uid) == 0 {
+		delete(v.work.hashes, ordinal)
+		return
+	}
+	v.work.hashes[ordinal] = appliedManifestHash{hash: hash, uid: uid}
+}
+
+// checkpoint records the results of the manifests applied by a reconcile interrupted by the apply deadline, so that
+// the next reconcile of the same generation resumes from them. It does nothing if the view is nil.
+func (v *manifestHashView) checkpoint(generation int64, results []applyResult) {
+	if v == nil {
+		return
+	}
+	v.hashes.lock.Lock()
+	defer v.hashes.lock.Unlock()
+
+	v.work.checkpoint = &applyCheckpoint{generation: generation, results: append([]applyResult{}, results...)}
+}
+
+// resume copies the results of the checkpoint of the generation into the results as resumed, so that the manifests
+// applied by the interrupted reconcile are not applied again, except the ones failed with a conflict. The checkpoint
+// is dropped, and the number of the results resumed is returned. It does nothing if the view is nil.
+func (v *manifestHashView) resume(generation int64, results []applyResult) int {
+	if v == nil {
+		return 0
+	}
+	v.hashes.lock.Lock()
+	defer v.hashes.lock.Unlock()
+
+	checkpoint := v.work.checkpoint
+	v.work.checkpoint = nil
+	if checkpoint == nil || checkpoint.generation != generation || len(checkpoint.results) > len(results) {
+		return 0
+	}
+	for index, result := range checkpoint.results {
+		if errors.IsConflict(result.Error) {
+			continue
+		}
+		result.resumed = true
+		results[index] = result
+	}
+	return len(checkpoint.results)
+}
+
+// manifestHash returns the hash of the manifest applied with the owner, the manifest is the one sent to the spoke
+// after the provenance is injected and the namespace is overridden.
+func manifestHash(manifest workapiv1.Manifest, owner metav1.OwnerReference) (string, error) {
+	data, err := json.Marshal(struct {
+		Manifest []byte                `json:"manifest"`
+		Owner    metav1.OwnerReference `json:"owner"`
+	}{Manifest: manifest.Raw, Owner: owner})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
@@ -0,0 +1,243 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+// unchangedManifestsFixture is a manifestwork with the resources applied and available on the spoke.
+type unchangedManifestsFixture struct {
+	work          *workapiv1.ManifestWork
+	appliedWork   *workapiv1.AppliedManifestWork
+	dynamicClient *fakedynamic.FakeDynamicClient
+	hashes        *manifestHashes
+}
+
+func newUnchangedManifestsFixture(numOfManifests int, cached bool) *unchangedManifestsFixture {
+	objects := []*unstructured.Unstructured{}
+	spokeObjects := []runtime.Object{}
+	for i := 0; i < numOfManifests; i++ {
+		object := newObjectWithValue(fmt.Sprintf("n%d", i), "val1")
+		objects = append(objects, object)
+		spokeObject := object.DeepCopy()
+		spokeObject.SetUID(types.UID(fmt.Sprintf("uid%d", i)))
+		spokeObjects = append(spokeObjects, spokeObject)
+	}
+
+	work, _ := spoketesting.NewManifestWork(0, objects...)
+	work.UID = "work-uid"
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	appliedWork := &workapiv1.AppliedManifestWork{
+		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("-%s", work.Name)},
+		Spec:       workapiv1.AppliedManifestWorkSpec{ManifestWorkName: work.Name},
+	}
+	for i := 0; i < numOfManifests; i++ {
+		resourceMeta := workapiv1.ManifestResourceMeta{
+			Ordinal: int32(i), Version: "v1", Kind: "NewObject", Resource: "newobjects", Namespace: "ns1", Name: fmt.Sprintf("n%d", i),
+		}
+		work.Status.ResourceStatus.Manifests = append(work.Status.ResourceStatus.Manifests, workapiv1.ManifestCondition{
+			ResourceMeta: resourceMeta,
+			Conditions:   []metav1.Condition{{Type: string(workapiv1.ManifestAvailable), Status: metav1.ConditionTrue, Reason: "ResourceAvailable"}},
+		})
+		appliedWork.Status.AppliedResources = append(appliedWork.Status.AppliedResources, workapiv1.AppliedManifestResourceMeta{
+			Version: "v1", Resource: "newobjects", Namespace: "ns1", Name: resourceMeta.Name, UID: fmt.Sprintf("uid%d", i),
+		})
+	}
+
+	fixture := &unchangedManifestsFixture{
+		work:          work,
+		appliedWork:   appliedWork,
+		dynamicClient: fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), spokeObjects...),
+	}
+	if cached {
+		fixture.hashes = newManifestHashes()
+	}
+	return fixture
+}
+
+// sync reconciles the manifestwork and returns the actions against the spoke.
+func (f *unchangedManifestsFixture) sync(t testing.TB) []clienttesting.Action {
+	controller := newController(f.work.DeepCopy(), f.appliedWork.DeepCopy(), spoketesting.NewFakeRestMapper()).withKubeObject()
+	controller.controller.spokeDynamicClient = f.dynamicClient
+	controller.controller.manifestHashes = f.hashes
+
+	f.dynamicClient.ClearActions()
+	// the in memory recorder works with both tests and benchmarks
+	syncContext := spoketesting.NewFakeSyncContext(nil, f.work.Name).WithRecorder(events.NewInMemoryRecorder("test"))
+	if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+		t.Fatal(err)
+	}
+	return f.dynamicClient.Actions()
+}
+
+func newObjectWithValue(name, value string) *unstructured.Unstructured {
+	return spoketesting.NewUnstructuredWithContent(
+		"v1", "NewObject", "ns1", name, map[string]interface{}{"spec": map[string]interface{}{"key1": value}})
+}
+
+func TestSyncUnchangedManifests(t *testing.T) {
+	cases := []struct {
+		name              string
+		cached            bool
+		update            func(f *unchangedManifestsFixture)
+		expectedResources []string
+		expectedWrites    int
+	}{
+		{
+			name:   "skip unchanged manifests",
+			cached: true,
+		},
+		{
+			name:   "apply the changed manifest only",
+			cached: true,
+			update: func(f *unchangedManifestsFixture) {
+				raw, _ := newObjectWithValue("n1", "val2").MarshalJSON()
+				f.work.Spec.Workload.Manifests[1].Raw = raw
+			},
+			expectedResources: []string{"n1"},
+			expectedWrites:    1,
+		},
+		{
+			name:   "apply the manifest of the resource not available",
+			cached: true,
+			update: func(f *unchangedManifestsFixture) {
+				f.work.Status.ResourceStatus.Manifests[1].Conditions[0].Status = metav1.ConditionFalse
+			},
+			expectedResources: []string{"n1"},
+		},
+		{
+			name:   "apply the manifest of the resource drifted",
+			cached: true,
+			update: func(f *unchangedManifestsFixture) {
+				f.work.Status.ResourceStatus.Manifests[1].Conditions = append(f.work.Status.ResourceStatus.Manifests[1].Conditions,
+					metav1.Condition{Type: string(workapiv1.ManifestDegraded), Status: metav1.ConditionTrue, Reason: "ResourceDrifted"})
+			},
+			expectedResources: []string{"n1"},
+		},
+		{
+			name:   "apply the manifest of the resource recreated",
+			cached: true,
+			update: func(f *unchangedManifestsFixture) {
+				f.appliedWork.Status.AppliedResources[1].UID = "uid-recreated"
+			},
+			expectedResources: []string{"n1"},
+		},
+		{
+			name:   "apply all manifests of the manifestwork recreated",
+			cached: true,
+			update: func(f *unchangedManifestsFixture) {
+				f.work.UID = "work-uid-recreated"
+			},
+			expectedResources: []string{"n0", "n1", "n2"},
+		},
+		{
+			name:   "apply all manifests once they are forgotten",
+			cached: true,
+			update: func(f *unchangedManifestsFixture) {
+				f.hashes.forget(f.work.Name)
+			},
+			expectedResources: []string{"n0", "n1", "n2"},
+		},
+		{
+			name:   "apply all manifests once a resync is requested",
+			cached: true,
+			update: func(f *unchangedManifestsFixture) {
+				f.work.Annotations = map[string]string{constants.ResyncRequestAnnotationKey: "2026-10-17T10:00:00Z"}
+			},
+			expectedResources: []string{"n0", "n1", "n2"},
+		},
+		{
+			name:   "skip unchanged manifests once the resync request is handled",
+			cached: true,
+			update: func(f *unchangedManifestsFixture) {
+				f.work.Annotations = map[string]string{constants.ResyncRequestAnnotationKey: "2026-10-17T10:00:00Z"}
+				f.work.Status.Conditions = append(f.work.Status.Conditions, metav1.Condition{
+					Type:    constants.WorkResyncRequestHandled,
+					Status:  metav1.ConditionTrue,
+					Reason:  "ResyncRequestHandled",
+					Message: resyncRequestHandledMessage("2026-10-17T10:00:00Z"),
+				})
+			},
+		},
+		{
+			name: "apply all manifests without cache",
+			update: func(f *unchangedManifestsFixture) {
+				raw, _ := newObjectWithValue("n1", "val2").MarshalJSON()
+				f.work.Spec.Workload.Manifests[1].Raw = raw
+			},
+			expectedResources: []string{"n0", "n1", "n2"},
+			expectedWrites:    1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fixture := newUnchangedManifestsFixture(3, c.cached)
+			// the first reconcile after the agent starts applies all manifests
+			fixture.sync(t)
+
+			if c.update != nil {
+				c.update(fixture)
+			}
+			actions := fixture.sync(t)
+
+			resources := sets.NewString()
+			writes := 0
+			for _, action := range actions {
+				if action.GetVerb() != "get" {
+					writes++
+					continue
+				}
+				resources.Insert(action.(clienttesting.GetActionImpl).GetName())
+			}
+			if !reflect.DeepEqual(resources.List(), sets.NewString(c.expectedResources...).List()) {
+				t.Errorf("expected resources %v applied, but got %v", c.expectedResources, resources.List())
+			}
+			if writes != c.expectedWrites {
+				t.Errorf("expected %d writes, but got %d: %v", c.expectedWrites, writes, actions)
+			}
+		})
+	}
+}
+
+// BenchmarkSyncOneManifestChanged measures the requests to the spoke by a reconcile of a large manifestwork with
+// one manifest changed.
+func BenchmarkSyncOneManifestChanged(b *testing.B) {
+	const numOfManifests = 100
+
+	for _, cached := range []bool{true, false} {
+		b.Run(fmt.Sprintf("cached=%t", cached), func(b *testing.B) {
+			fixture := newUnchangedManifestsFixture(numOfManifests, cached)
+			fixture.sync(b)
+
+			requests, writes := 0, 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				raw, _ := newObjectWithValue("n0", fmt.Sprintf("val%d", i+2)).MarshalJSON()
+				fixture.work.Spec.Workload.Manifests[0].Raw = raw
+				for _, action := range fixture.sync(b) {
+					requests++
+					if action.GetVerb() != "get" {
+						writes++
+					}
+				}
+			}
+			b.ReportMetric(float64(requests)/float64(b.N), "requests/op")
+			b.ReportMetric(float64(writes)/float64(b.N), "writes/op")
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package manifestcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+// applyPatch patches the existing resource identified by the patch with the manifest, which is the patch document
+// instead of an object. The resource must exist already, it is neither created nor owned by the manifestwork, so it
+// is not deleted with the manifestwork.
+func (m *ManifestWorkController) applyPatch(
+	ctx context.Context,
+	index int,
+	manifest workapiv1.Manifest,
+	patch *helper.ManifestPatch,
+	scope *namespaceScope,
+	recorder events.Recorder) applyResult {
+	result := applyResult{}
+	result.resourceMeta = workapiv1.ManifestResourceMeta{
+		Ordinal:   int32(index),
+		Group:     patch.Resource.Group,
+		Version:   patch.Resource.Version,
+		Resource:  patch.Resource.Resource,
+		Namespace: patch.Namespace,
+		Name:      patch.Name,
+	}
+
+	gvk, err := m.restMapper.KindFor(patch.Resource)
+	if err != nil {
+		result.Error = applyerrors.NewMappingNotFoundError(patch.Resource, patch.Namespace, patch.Name, err)
+		return result
+	}
+	result.resourceMeta.Kind = gvk.Kind
+	if err := scope.check(patch.Resource, patch.Namespace, patch.Name); err != nil {
+		result.Error = err
+		return result
+	}
+	if len(manifest.Raw) == 0 {
+		result.Error = applyerrors.NewValidationFailedError(patch.Resource, patch.Namespace, patch.Name,
+			errors.NewBadRequest(fmt.Sprintf("the patch document of manifest %d is empty", index)))
+		return result
+	}
+
+	actual, err := m.spokeDynamicClient.Resource(patch.Resource).Namespace(patch.Namespace).Patch(
+		ctx, patch.Name, patch.PatchType, manifest.Raw, metav1.PatchOptions{})
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.Result = actual
+	// the patch is applied on each reconcile, the resource is only changed if it is not patched yet
+	if m.patchedVersions.changed(patch.ResourceIdentifier(), actual.GetResourceVersion()) {
+		result.Changed = true
+		recorder.Eventf(fmt.Sprintf("%s Patched", gvk.Kind), "Patched %s/%s", patch.Namespace, patch.Name)
+	}
+	return result
+}
+
+// revertDroppedPatches reverts the patches recorded on the appliedmanifestwork which are dropped from the
+// manifestwork, and records the patches with a revertPatch of the manifestwork before they are applied. The patches
+// failed to revert are kept recorded, so that they are reverted again by the next reconcile.
+func (m *ManifestWorkController) revertDroppedPatches(
+	ctx context.Context,
+	appliedManifestWork *workapiv1.AppliedManifestWork,
+	patches map[int32]*helper.ManifestPatch,
+	recorder events.Recorder) error {
+	recorded := []helper.AppliedManifestPatch{}
+	if value, ok := appliedManifestWork.Annotations[constants.AppliedManifestPatchesAnnotationKey]; ok {
+		if err := json.Unmarshal([]byte(value), &recorded); err != nil {
+			klog.Warningf("Ignore the annotation %s of appliedmanifestwork %q, it is invalid: %v",
+				constants.AppliedManifestPatchesAnnotationKey, appliedManifestWork.Name, err)
+		}
+	}
+
+	targets := map[helper.ManifestResourceIdentifier]bool{}
+	for _, patch := range patches {
+		targets[patch.ResourceIdentifier()] = true
+	}
+
+	applied := helper.AppliedManifestPatchesOf(patches)
+	var errs []error
+	for _, patch := range recorded {
+		if targets[patch.ResourceIdentifier] {
+			continue
+		}
+		if err := helper.RevertManifestPatch(ctx, m.spokeDynamicClient, recorder, patch); err != nil {
+			errs = append(errs, err)
+			applied = append(applied, patch)
+			continue
+		}
+		m.patchedVersions.forget(patch.ResourceIdentifier)
+	}
+
+	if err := m.recordAppliedPatches(ctx, appliedManifestWork, applied); err != nil {
+		errs = append(errs, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// recordAppliedPatches records the patches on the appliedmanifestwork, the annotation is removed if there is none.
+func (m *ManifestWorkController) recordAppliedPatches(
+	ctx context.Context, appliedManifestWork *workapiv1.AppliedManifestWork, applied []helper.AppliedManifestPatch) error {
+	existing, ok := appliedManifestWork.Annotations[constants.AppliedManifestPatchesAnnotationKey]
+	var value interface{}
+	if len(applied) > 0 {
+		data, err := json.Marshal(applied)
+		if err != nil {
+			return err
+		}
+		if ok && existing == string(data) {
+			return nil
+		}
+		value = string(data)
+	} else if !ok {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{constants.AppliedManifestPatchesAnnotationKey: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.appliedManifestWorkClient.Patch(ctx, appliedManifestWork.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// patchedVersions records the resource versions of the resources once they are patched, so that it is known if a
+// patch changes the resource by comparing against the previous result instead of getting the resource first.
+type patchedVersions struct {
+	lock     sync.Mutex
+	versions map[helper.ManifestResourceIdentifier]string
+}
+
+func newPatchedVersions() *patchedVersions {
+	return &patchedVersions{
+		versions: map[helper.ManifestResourceIdentifier]string{},
+	}
+}
+
+// changed records the resource version of the resource patched and returns if it is changed since the resource was
+// last patched. It is always changed if the resource is not patched yet since the agent starts or the recorder is nil.
+func (v *patchedVersions) changed(identifier helper.ManifestResourceIdentifier, resourceVersion string) bool {
+	if v == nil {
+		return true
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	last, ok := v.versions[identifier]
+	v.versions[identifier] = resourceVersion
+	return !ok || last != resourceVersion
+}
+
+// forget drops the resource version of the resource once its patch is reverted. It does nothing if the recorder is
+// nil.
+func (v *patchedVersions) forget(identifier helper.ManifestResourceIdentifier) {
+	if v == nil {
+		return
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	delete(v.versions, identifier)
+}
@@ -0,0 +1,199 @@
+package manifestcontroller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestApplyPatch(t *testing.T) {
+	patchOf := func(patchType string) string {
+		return `[{"ordinal": 0, "resourceIdentifier": {"version": "v1", "resource": "secrets", "namespace": "ns1", "name": "n1"},
+			"updateStrategy": {"type": "Patch", "patchType": "` + patchType + `"}}]`
+	}
+
+	cases := []struct {
+		name                  string
+		configOptions         string
+		patch                 string
+		existingObjects       []runtime.Object
+		expectedActions       []string
+		expectedAppliedStatus metav1.ConditionStatus
+		expectedSyncErr       bool
+		validateActions       func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:                  "merge patch existing resource",
+			configOptions:         patchOf("MergePatch"),
+			patch:                 `{"metadata":{"labels":{"patched":"true"}}}`,
+			existingObjects:       []runtime.Object{spoketesting.NewUnstructured("v1", "Secret", "ns1", "n1")},
+			expectedActions:       []string{"patch"},
+			expectedAppliedStatus: metav1.ConditionTrue,
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				patch := actions[0].(clienttesting.PatchAction)
+				if string(patch.GetPatch()) != `{"metadata":{"labels":{"patched":"true"}}}` {
+					t.Errorf("expected the manifest patched as is, but got %s", patch.GetPatch())
+				}
+			},
+		},
+		{
+			name:                  "json patch existing resource",
+			configOptions:         patchOf("JSONPatch"),
+			patch:                 `[{"op":"add","path":"/metadata/labels","value":{"patched":"true"}}]`,
+			existingObjects:       []runtime.Object{spoketesting.NewUnstructured("v1", "Secret", "ns1", "n1")},
+			expectedActions:       []string{"patch"},
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "patch missing resource",
+			configOptions:         patchOf("MergePatch"),
+			patch:                 `{"metadata":{"labels":{"patched":"true"}}}`,
+			expectedActions:       []string{"patch"},
+			expectedAppliedStatus: metav1.ConditionFalse,
+			// the resource may be created later, so it is retried
+			expectedSyncErr: true,
+		},
+		{
+			name:                  "unsupported patch type",
+			configOptions:         patchOf("ApplyPatch"),
+			patch:                 `{"metadata":{"labels":{"patched":"true"}}}`,
+			existingObjects:       []runtime.Object{spoketesting.NewUnstructured("v1", "Secret", "ns1", "n1")},
+			expectedAppliedStatus: metav1.ConditionFalse,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0)
+			work.Spec.Workload.Manifests = []workapiv1.Manifest{{RawExtension: runtime.RawExtension{Raw: []byte(c.patch)}}}
+			work.Annotations = map[string]string{constants.ManifestConfigOptionsAnnotationKey: c.configOptions}
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).
+				withKubeObject().
+				withUnstructuredObject(c.existingObjects...)
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			if err := controller.controller.sync(context.TODO(), syncContext); (err != nil) != c.expectedSyncErr {
+				t.Fatalf("expected sync error %t, but got %v", c.expectedSyncErr, err)
+			}
+
+			actions := controller.dynamicClient.Actions()
+			if len(actions) != len(c.expectedActions) {
+				t.Fatalf("expected actions %v, but got %v", c.expectedActions, actions)
+			}
+			for i, verb := range c.expectedActions {
+				spoketesting.AssertAction(t, actions[i], verb)
+			}
+			if c.validateActions != nil {
+				c.validateActions(t, actions)
+			}
+			if c.expectedAppliedStatus == metav1.ConditionTrue {
+				patched, err := controller.dynamicClient.Tracker().Get(
+					schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, "ns1", "n1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if labels := patched.(*unstructured.Unstructured).GetLabels(); labels["patched"] != "true" ||
+					len(patched.(*unstructured.Unstructured).GetOwnerReferences()) != 0 {
+					t.Errorf("expected resource patched without owner, but got %v", patched)
+				}
+			}
+
+			workActions := controller.workClient.Actions()
+			updatedWork := lastUpdatedWork(t, workActions)
+			if !meta.IsStatusConditionPresentAndEqual(updatedWork.Status.Conditions, workapiv1.WorkApplied, c.expectedAppliedStatus) {
+				t.Errorf("expected Applied condition %s, but got %v", c.expectedAppliedStatus, updatedWork.Status.Conditions)
+			}
+			// the resource meta comes from the config option, so that the availability of the resource is checked
+			if resourceMeta := updatedWork.Status.ResourceStatus.Manifests[0].ResourceMeta; c.expectedActions != nil &&
+				(resourceMeta.Resource != "secrets" || resourceMeta.Kind != "Secret" || resourceMeta.Name != "n1") {
+				t.Errorf("expected resource meta of the patched secret, but got %v", resourceMeta)
+			}
+		})
+	}
+}
+
+func TestRevertDroppedPatches(t *testing.T) {
+	recorded := `[{"resourceIdentifier":{"group":"","version":"v1","resource":"secrets","namespace":"ns1","name":"n1"},` +
+		`"patchType":"application/merge-patch+json","revertPatch":{"metadata":{"labels":{"patched":null}}}}]`
+
+	cases := []struct {
+		name               string
+		configOptions      string
+		expectedActions    []string
+		expectedAnnotation string
+		expectedPatched    bool
+	}{
+		{
+			name:            "revert the dropped patch",
+			expectedActions: []string{"patch"},
+		},
+		{
+			name: "keep the patch applied",
+			configOptions: `[{"ordinal": 0, "resourceIdentifier": {"version": "v1", "resource": "secrets", "namespace": "ns1", "name": "n1"},
+				"updateStrategy": {"type": "Patch", "patchType": "MergePatch", "revertPatch": {"metadata":{"labels":{"patched":null}}}}}]`,
+			expectedActions:    []string{"patch"},
+			expectedAnnotation: recorded,
+			expectedPatched:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0)
+			work.Spec.Workload.Manifests = []workapiv1.Manifest{{RawExtension: runtime.RawExtension{Raw: []byte(`{"metadata":{"labels":{"patched":"true"}}}`)}}}
+			if len(c.configOptions) > 0 {
+				work.Annotations = map[string]string{constants.ManifestConfigOptionsAnnotationKey: c.configOptions}
+			} else {
+				work.Spec.Workload.Manifests = []workapiv1.Manifest{}
+			}
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			appliedWork := spoketesting.NewAppliedManifestWork("", 0, "applied-uid")
+			appliedWork.Annotations = map[string]string{constants.AppliedManifestPatchesAnnotationKey: recorded}
+			existing := spoketesting.NewUnstructured("v1", "Secret", "ns1", "n1")
+			existing.SetLabels(map[string]string{"patched": "true"})
+			controller := newController(work, appliedWork, spoketesting.NewFakeRestMapper()).
+				withKubeObject().
+				withUnstructuredObject(existing)
+			if err := controller.workClient.Tracker().Add(appliedWork); err != nil {
+				t.Fatal(err)
+			}
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+				t.Fatal(err)
+			}
+
+			actions := controller.dynamicClient.Actions()
+			if len(actions) != len(c.expectedActions) {
+				t.Fatalf("expected actions %v, but got %v", c.expectedActions, actions)
+			}
+			patched, err := controller.dynamicClient.Tracker().Get(
+				schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, "ns1", "n1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := patched.(*unstructured.Unstructured).GetLabels()["patched"]; ok != c.expectedPatched {
+				t.Errorf("expected the patch applied %t, but got %v", c.expectedPatched, patched)
+			}
+
+			updatedAppliedWork, err := controller.workClient.WorkV1().AppliedManifestWorks().Get(
+				context.TODO(), appliedWork.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if annotation := updatedAppliedWork.Annotations[constants.AppliedManifestPatchesAnnotationKey]; annotation != c.expectedAnnotation {
+				t.Errorf("expected the applied patches %q, but got %q", c.expectedAnnotation, annotation)
+			}
+		})
+	}
+}
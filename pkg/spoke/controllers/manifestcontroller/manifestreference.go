@@ -0,0 +1,244 @@
+package manifestcontroller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// ManifestReferenceKind is the kind of the manifests which reference the actual manifests in hub configmaps or
+	// secrets. It allows to ship manifests larger than the size limit of a manifestwork, e.g. CRDs with huge schemas.
+	ManifestReferenceKind = "ManifestReference"
+
+	manifestReferenceKeySeparator = "/"
+)
+
+// manifestReference references a key of a configmap or secret on the hub containing the (optionally gzipped)
+// JSON of the actual manifest. A manifest referencing a configmap looks like:
+//
+//	{
+//	  "apiVersion": "work.open-cluster-management.io/v1",
+//	  "kind": "ManifestReference",
+//	  "spec": {"kind": "ConfigMap", "namespace": "cluster1", "name": "crds", "key": "crd.json.gz"}
+//	}
+//
+// The namespace defaults to the namespace of the manifestwork.
+type manifestReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Key       string `json:"key"`
+}
+
+func (r *manifestReference) String() string {
+	return fmt.Sprintf("%s %s/%s key %s", r.Kind, r.Namespace, r.Name, r.Key)
+}
+
+// queueKey returns the key used to requeue the manifestworks referencing the configmap/secret.
+func (r *manifestReference) queueKey() string {
+	return referenceQueueKey(r.Kind, r.Namespace, r.Name)
+}
+
+// referenceQueueKey returns the queue key of a configmap/secret. Unlike names of manifestworks, it
+// contains the separator "/".
+func referenceQueueKey(kind, namespace, name string) string {
+	return strings.Join([]string{kind, namespace, name}, manifestReferenceKeySeparator)
+}
+
+// referenceQueueKeyFunc returns the queue key of the configmap/secret on the hub.
+func referenceQueueKeyFunc(obj runtime.Object) string {
+	switch t := obj.(type) {
+	case *corev1.ConfigMap:
+		return referenceQueueKey("ConfigMap", t.Namespace, t.Name)
+	case *corev1.Secret:
+		return referenceQueueKey("Secret", t.Namespace, t.Name)
+	}
+	return ""
+}
+
+func isReferenceQueueKey(key string) bool {
+	return strings.Contains(key, manifestReferenceKeySeparator)
+}
+
+// parseManifestReference returns the reference if the manifest is a ManifestReference, otherwise nil is returned.
+func parseManifestReference(namespace string, manifest workapiv1.Manifest) (*manifestReference, error) {
+	object := struct {
+		APIVersion string            `json:"apiVersion"`
+		Kind       string            `json:"kind"`
+		Spec       manifestReference `json:"spec"`
+	}{}
+	if err := json.Unmarshal(manifest.Raw, &object); err != nil {
+		return nil, err
+	}
+	if object.APIVersion != workapiv1.GroupVersion.String() || object.Kind != ManifestReferenceKind {
+		return nil, nil
+	}
+
+	reference := &object.Spec
+	if len(reference.Namespace) == 0 {
+		reference.Namespace = namespace
+	}
+	switch {
+	case reference.Kind != "ConfigMap" && reference.Kind != "Secret":
+		return nil, fmt.Errorf("unsupported kind %q of manifest reference, only ConfigMap and Secret are supported", reference.Kind)
+	case len(reference.Name) == 0 || len(reference.Key) == 0:
+		return nil, fmt.Errorf("name and key of manifest reference must be set")
+	}
+	return reference, nil
+}
+
+// resolveManifest returns the actual manifest if the manifest is a ManifestReference, together with the reference.
+// The manifest is returned as is if it is not a reference.
+func (m *ManifestWorkController) resolveManifest(
+	namespace string, manifest workapiv1.Manifest) (workapiv1.Manifest, *manifestReference, error) {
+	reference, err := parseManifestReference(namespace, manifest)
+	if err != nil || reference == nil {
+		return manifest, nil, err
+	}
+
+	if m.hubConfigMapLister == nil || m.hubSecretLister == nil {
+		return manifest, reference, fmt.Errorf("manifest references are not enabled on the agent")
+	}
+
+	var data []byte
+	var found bool
+	switch reference.Kind {
+	case "ConfigMap":
+		configMap, err := m.hubConfigMapLister.ConfigMaps(reference.Namespace).Get(reference.Name)
+		if err != nil {
+			return manifest, reference, fmt.Errorf("failed to get referenced %s: %w", reference, err)
+		}
+		data, found = configMap.BinaryData[reference.Key]
+		if !found {
+			var value string
+			value, found = configMap.Data[reference.Key]
+			data = []byte(value)
+		}
+	case "Secret":
+		secret, err := m.hubSecretLister.Secrets(reference.Namespace).Get(reference.Name)
+		if err != nil {
+			return manifest, reference, fmt.Errorf("failed to get referenced %s: %w", reference, err)
+		}
+		data, found = secret.Data[reference.Key]
+	}
+	if !found {
+		return manifest, reference, fmt.Errorf("key not found in referenced %s", reference)
+	}
+
+	raw, err := decompress(data)
+	if err != nil {
+		return manifest, reference, fmt.Errorf("failed to decompress referenced %s: %w", reference, err)
+	}
+	return workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}}, reference, nil
+}
+
+// referencesOf returns the queue keys of the configmaps/secrets referenced by the manifestwork.
+func referencesOf(manifestWork *workapiv1.ManifestWork) []string {
+	var keys []string
+	for _, manifest := range manifestWork.Spec.Workload.Manifests {
+		reference, err := parseManifestReference(manifestWork.Namespace, manifest)
+		if err != nil || reference == nil {
+			continue
+		}
+		keys = append(keys, reference.queueKey())
+	}
+	return keys
+}
+
+// manifestReferences indexes the manifestworks by the configmaps/secrets they reference, so that the manifestworks
+// referencing a configmap/secret are requeued once it is changed without listing all the manifestworks, which may be
+// fetched from the hub on demand. The index is updated once the manifestworks are reconciled.
+type manifestReferences struct {
+	lock sync.Mutex
+	// works are the names of the manifestworks referencing each configmap/secret by its queue key
+	works map[string]sets.String
+	// references are the queue keys of the configmaps/secrets referenced by each manifestwork
+	references map[string]sets.String
+}
+
+func newManifestReferences() *manifestReferences {
+	return &manifestReferences{
+		works:      map[string]sets.String{},
+		references: map[string]sets.String{},
+	}
+}
+
+// register records the configmaps/secrets referenced by the manifestwork, and clears the ones it no longer
+// references. All the references of the manifestwork are cleared if the keys are empty, e.g. it is deleted.
+func (r *manifestReferences) register(workName string, referenceKeys []string) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	referenced := sets.NewString(referenceKeys...)
+	for key := range r.references[workName].Difference(referenced) {
+		r.works[key].Delete(workName)
+		if r.works[key].Len() == 0 {
+			delete(r.works, key)
+		}
+	}
+	for key := range referenced {
+		if _, ok := r.works[key]; !ok {
+			r.works[key] = sets.NewString()
+		}
+		r.works[key].Insert(workName)
+	}
+	if referenced.Len() == 0 {
+		delete(r.references, workName)
+		return
+	}
+	r.references[workName] = referenced
+}
+
+// referencingWorks returns the names of the manifestworks referencing the configmap/secret with the queue key.
+func (r *manifestReferences) referencingWorks(referenceKey string) []string {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.works[referenceKey].List()
+}
+
+// enqueueReferencingWorks requeues the manifestworks referencing the configmap/secret with the given queue key.
+func (m *ManifestWorkController) enqueueReferencingWorks(controllerContext factory.SyncContext, referenceKey string) error {
+	for _, workName := range m.manifestReferences.referencingWorks(referenceKey) {
+		controllerContext.Queue().Add(workName)
+	}
+	return nil
+}
+
+// sourceMessage returns the message describing the source of the manifest if it is a reference.
+func sourceMessage(source *manifestReference) string {
+	if source == nil {
+		return ""
+	}
+	return fmt.Sprintf(" from %s", source)
+}
+
+// decompress returns the gunzipped data, data which is not gzipped is returned as is.
+func decompress(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
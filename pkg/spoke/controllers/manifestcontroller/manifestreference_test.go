@@ -0,0 +1,186 @@
+package manifestcontroller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestResolveManifest(t *testing.T) {
+	secret := spoketesting.NewUnstructuredSecret("ns1", "test", false, "")
+	secretJSON, _ := secret.MarshalJSON()
+
+	configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	configMapIndexer.Add(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cluster1", Name: "manifests"},
+		BinaryData: map[string][]byte{"secret.json.gz": gzipData(t, secretJSON)},
+		Data:       map[string]string{"secret.json": string(secretJSON)},
+	})
+	secretIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	secretIndexer.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cluster1", Name: "manifests"},
+		Data:       map[string][]byte{"secret.json.gz": gzipData(t, secretJSON)},
+	})
+
+	cases := []struct {
+		name              string
+		manifest          workapiv1.Manifest
+		disabled          bool
+		expectedReference string
+		expectedErr       bool
+	}{
+		{
+			name:     "inline manifest",
+			manifest: toManifest(secretJSON),
+		},
+		{
+			name:              "gzipped manifest in configmap",
+			manifest:          newManifestReference("ConfigMap", "", "manifests", "secret.json.gz"),
+			expectedReference: "ConfigMap/cluster1/manifests",
+		},
+		{
+			name:              "plain manifest in configmap",
+			manifest:          newManifestReference("ConfigMap", "cluster1", "manifests", "secret.json"),
+			expectedReference: "ConfigMap/cluster1/manifests",
+		},
+		{
+			name:              "gzipped manifest in secret",
+			manifest:          newManifestReference("Secret", "", "manifests", "secret.json.gz"),
+			expectedReference: "Secret/cluster1/manifests",
+		},
+		{
+			name:              "key not found",
+			manifest:          newManifestReference("ConfigMap", "", "manifests", "missing"),
+			expectedReference: "ConfigMap/cluster1/manifests",
+			expectedErr:       true,
+		},
+		{
+			name:              "configmap not found",
+			manifest:          newManifestReference("ConfigMap", "", "missing", "secret.json"),
+			expectedReference: "ConfigMap/cluster1/missing",
+			expectedErr:       true,
+		},
+		{
+			name:        "unsupported kind",
+			manifest:    newManifestReference("Pod", "", "manifests", "secret.json"),
+			expectedErr: true,
+		},
+		{
+			name:              "manifest references disabled",
+			manifest:          newManifestReference("ConfigMap", "", "manifests", "secret.json.gz"),
+			disabled:          true,
+			expectedReference: "ConfigMap/cluster1/manifests",
+			expectedErr:       true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			controller := &ManifestWorkController{}
+			if !c.disabled {
+				controller.hubConfigMapLister = corev1listers.NewConfigMapLister(configMapIndexer)
+				controller.hubSecretLister = corev1listers.NewSecretLister(secretIndexer)
+			}
+
+			manifest, reference, err := controller.resolveManifest("cluster1", c.manifest)
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("expected error %t, but got %v", c.expectedErr, err)
+			}
+
+			actualReference := ""
+			if reference != nil {
+				actualReference = reference.queueKey()
+			}
+			if actualReference != c.expectedReference {
+				t.Errorf("expected reference %q, but got %q", c.expectedReference, actualReference)
+			}
+
+			if err == nil && !bytes.Equal(manifest.Raw, secretJSON) {
+				t.Errorf("expected manifest %s, but got %s", secretJSON, manifest.Raw)
+			}
+		})
+	}
+}
+
+func TestReferencesOf(t *testing.T) {
+	work, _ := spoketesting.NewManifestWork(0, spoketesting.NewUnstructuredSecret("ns1", "test", false, ""))
+	work.Spec.Workload.Manifests = append(work.Spec.Workload.Manifests,
+		newManifestReference("ConfigMap", "", "cm1", "key1"),
+		newManifestReference("Secret", "ns1", "secret1", "key1"))
+
+	actual := fmt.Sprintf("%v", referencesOf(work))
+	expected := "[ConfigMap/cluster1/cm1 Secret/ns1/secret1]"
+	if actual != expected {
+		t.Errorf("expected %s, but got %s", expected, actual)
+	}
+}
+
+func TestEnqueueReferencingWorks(t *testing.T) {
+	references := newManifestReferences()
+	references.register("work1", []string{"ConfigMap/cluster1/cm1", "Secret/ns1/secret1"})
+	references.register("work2", []string{"ConfigMap/cluster1/cm1"})
+	// the manifestwork no longer references the secret
+	references.register("work1", []string{"ConfigMap/cluster1/cm1"})
+	// the manifestwork is deleted
+	references.register("work3", []string{"Secret/ns1/secret1"})
+	references.register("work3", nil)
+
+	// the manifestworks are not listed, so that they are not fetched from the hub
+	controller := &ManifestWorkController{manifestReferences: references}
+	cases := []struct {
+		referenceKey  string
+		expectedWorks []string
+	}{
+		{referenceKey: "ConfigMap/cluster1/cm1", expectedWorks: []string{"work1", "work2"}},
+		{referenceKey: "Secret/ns1/secret1"},
+	}
+	for _, c := range cases {
+		syncContext := spoketesting.NewFakeSyncContext(t, c.referenceKey)
+		if err := controller.enqueueReferencingWorks(syncContext, c.referenceKey); err != nil {
+			t.Fatal(err)
+		}
+		var works []string
+		for syncContext.Queue().Len() > 0 {
+			key, _ := syncContext.Queue().Get()
+			works = append(works, key.(string))
+		}
+		sort.Strings(works)
+		if !reflect.DeepEqual(works, c.expectedWorks) {
+			t.Errorf("expected manifestworks %v requeued for %s, but got %v", c.expectedWorks, c.referenceKey, works)
+		}
+	}
+}
+
+func newManifestReference(kind, namespace, name, key string) workapiv1.Manifest {
+	return toManifest([]byte(fmt.Sprintf(
+		`{"apiVersion":"work.open-cluster-management.io/v1","kind":"ManifestReference","spec":{"kind":%q,"namespace":%q,"name":%q,"key":%q}}`,
+		kind, namespace, name, key)))
+}
+
+func toManifest(raw []byte) workapiv1.Manifest {
+	manifest := workapiv1.Manifest{}
+	manifest.Raw = raw
+	return manifest
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,49 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/helper"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+// transformManifest returns the manifest transformed by the transformers in order, the manifest is returned as is if
+// there is no transformer. A ResourceError of ErrManifestTransformFailed is returned if a transformer fails, or it
+// changes the kind, namespace or name of the manifest, since the resource meta of the manifest is resolved already.
+func transformManifest(
+	ctx context.Context,
+	manifest workapiv1.Manifest,
+	resourceMeta workapiv1.ManifestResourceMeta,
+	gvr schema.GroupVersionResource,
+	transformers []helper.ManifestTransformer) (workapiv1.Manifest, error) {
+	if len(transformers) == 0 {
+		return manifest, nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+		return manifest, err
+	}
+	gvk, namespace, name := obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName()
+	for _, transformer := range transformers {
+		if err := transformer.Transform(ctx, obj, resourceMeta); err != nil {
+			return manifest, applyerrors.NewManifestTransformFailedError(gvr, resourceMeta.Namespace, resourceMeta.Name, err)
+		}
+	}
+	if obj.GroupVersionKind() != gvk || obj.GetNamespace() != namespace || obj.GetName() != name {
+		return manifest, applyerrors.NewManifestTransformFailedError(gvr, resourceMeta.Namespace, resourceMeta.Name,
+			fmt.Errorf("the kind, namespace or name of the manifest is changed by the transformers"))
+	}
+
+	raw, err := obj.MarshalJSON()
+	if err != nil {
+		return manifest, err
+	}
+	return workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}}, nil
+}
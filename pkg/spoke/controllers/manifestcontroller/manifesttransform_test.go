@@ -0,0 +1,97 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestTransformManifests(t *testing.T) {
+	labeler := helper.ManifestTransformerFunc(func(_ context.Context, obj *unstructured.Unstructured, _ workapiv1.ManifestResourceMeta) error {
+		obj.SetLabels(map[string]string{"cluster": "cluster1"})
+		return nil
+	})
+	renamer := helper.ManifestTransformerFunc(func(_ context.Context, obj *unstructured.Unstructured, _ workapiv1.ManifestResourceMeta) error {
+		obj.SetName("renamed")
+		return nil
+	})
+	failing := helper.ManifestTransformerFunc(func(_ context.Context, _ *unstructured.Unstructured, _ workapiv1.ManifestResourceMeta) error {
+		return fmt.Errorf("mirror is not reachable")
+	})
+
+	cases := []struct {
+		name           string
+		transformers   []helper.ManifestTransformer
+		expectedReason string
+		expectedLabels map[string]string
+	}{
+		{
+			name:           "no transformer",
+			expectedReason: "AppliedManifestComplete",
+		},
+		{
+			name:           "transformed manifest",
+			transformers:   []helper.ManifestTransformer{labeler},
+			expectedReason: "AppliedManifestComplete",
+			expectedLabels: map[string]string{"cluster": "cluster1"},
+		},
+		{
+			name:           "transformer failed",
+			transformers:   []helper.ManifestTransformer{labeler, failing},
+			expectedReason: "ManifestTransformFailed",
+		},
+		{
+			name:           "transformer changed the name",
+			transformers:   []helper.ManifestTransformer{renamer},
+			expectedReason: "ManifestTransformFailed",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0, spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"))
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+			controller.controller.transformers = c.transformers
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			// the failed transform is retried
+			if err := controller.controller.sync(context.TODO(), syncContext); (err != nil) != (c.expectedReason != "AppliedManifestComplete") {
+				t.Errorf("unexpected sync error %v", err)
+			}
+
+			workActions := controller.workClient.Actions()
+			updatedWork := lastUpdatedWork(t, workActions)
+			condition := meta.FindStatusCondition(updatedWork.Status.ResourceStatus.Manifests[0].Conditions, string(workapiv1.ManifestApplied))
+			if condition == nil || condition.Reason != c.expectedReason {
+				t.Errorf("expected reason %q, but got %v", c.expectedReason, condition)
+			}
+
+			var created []*corev1.Secret
+			for _, action := range controller.kubeClient.Actions() {
+				if action.GetVerb() == "create" {
+					created = append(created, action.(clienttesting.CreateAction).GetObject().(*corev1.Secret))
+				}
+			}
+			if c.expectedReason != "AppliedManifestComplete" {
+				if len(created) != 0 {
+					t.Errorf("expected the manifest not applied, but got %v", created)
+				}
+				return
+			}
+			if len(created) != 1 || created[0].Name != "test" || len(created[0].Labels) != len(c.expectedLabels) ||
+				created[0].Labels["cluster"] != c.expectedLabels["cluster"] {
+				t.Errorf("expected the secret created with labels %v, but got %v", c.expectedLabels, created)
+			}
+		})
+	}
+}
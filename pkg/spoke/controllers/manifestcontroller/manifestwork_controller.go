@@ -2,13 +2,14 @@ package manifestcontroller
 
 import (
 	"context"
+	"encoding/json"
+	goerrors "errors"
 	"fmt"
 	"reflect"
 	"strings"
 	"time"
 
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
-	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -16,22 +17,28 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/dynamic"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 
 	"github.com/openshift/library-go/pkg/controller/factory"
 	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
-	"github.com/openshift/library-go/pkg/operator/resource/resourcemerge"
 	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
 	workinformer "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
 	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
 	workapiv1 "open-cluster-management.io/api/work/v1"
 
+	"open-cluster-management.io/work/pkg/applier"
+	"open-cluster-management.io/work/pkg/constants"
 	"open-cluster-management.io/work/pkg/helper"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
 	"open-cluster-management.io/work/pkg/spoke/controllers"
 )
 
@@ -40,24 +47,140 @@ var ResyncInterval = 5 * time.Minute
 // ManifestWorkController is to reconcile the workload resources
 // fetched from hub cluster on spoke cluster.
 type ManifestWorkController struct {
-	manifestWorkClient        workv1client.ManifestWorkInterface
-	manifestWorkLister        worklister.ManifestWorkNamespaceLister
-	appliedManifestWorkClient workv1client.AppliedManifestWorkInterface
-	appliedManifestWorkLister worklister.AppliedManifestWorkLister
-	spokeDynamicClient        dynamic.Interface
-	spokeKubeclient           kubernetes.Interface
-	spokeAPIExtensionClient   apiextensionsclient.Interface
-	hubHash                   string
-	restMapper                meta.RESTMapper
+	manifestWorkClient         workv1client.ManifestWorkInterface
+	manifestWorkLister         worklister.ManifestWorkNamespaceLister
+	appliedManifestWorkClient  workv1client.AppliedManifestWorkInterface
+	appliedManifestWorkIndexer cache.Indexer
+	spokeDynamicClient         dynamic.Interface
+	spokeKubeclient            kubernetes.Interface
+	spokeAPIExtensionClient    apiextensionsclient.Interface
+	hubHash                    string
+	restMapper                 meta.RESTMapper
+	hubConfigMapLister         corev1listers.ConfigMapLister
+	hubSecretLister            corev1listers.SecretLister
+	propagateProvenance        bool
+	startupThrottle            *startupThrottle
+	limits                     *ApplyLimits
+	namespaceScope             *namespaceScope
+	crdStore                   cache.Store
+	apiVersionInterest         *apiVersionInterest
+	apiVersionChecker          *helper.APIVersionChecker
+	driftTracker               *helper.DriftTracker
+	manifestHashes             *manifestHashes
+	applyRetries               *applyRetries
+	dependencyWaits            *dependencyWaits
+	patchedVersions            *patchedVersions
+	manifestReferences         *manifestReferences
+	// updateFieldManager is the field manager of the resources updated by the agent, the fields owned by it are
+	// adopted by the server side apply once the update strategy of a manifest is changed to ServerSideApply.
+	updateFieldManager string
+	// statusWriter writes the status to the hub asynchronously, the status is written in the reconcile if it is nil
+	statusWriter *helper.StatusWriter
+	// persistEventFingerprints dedups the apply events with the fingerprints recorded on the appliedmanifestworks,
+	// the events are emitted on each reconcile if it is false
+	persistEventFingerprints bool
+
+	// defaultDeletePropagationPolicy is recorded on the appliedmanifestworks created, see
+	// constants.DefaultDeletePropagationPolicyAnnotationKey
+	defaultDeletePropagationPolicy workapiv1.DeletePropagationPolicyType
+	// jobTTLSecondsAfterFinished is injected into the Job manifests without ttlSecondsAfterFinished, it is not
+	// injected if it is nil
+	jobTTLSecondsAfterFinished *int64
+	// liveObjects serves the lookups of the existing resources before they are applied, the resources applied are
+	// invalidated in it. The resources are fetched from the spoke apiserver if it is nil.
+	liveObjects *helper.LiveObjectCache
+	// applyDeadline is the deadline of applying the manifests of a manifestwork in a reconcile, there is no deadline
+	// if it is not positive
+	applyDeadline time.Duration
+	// transformers transform the manifests in order before they are applied
+	transformers []helper.ManifestTransformer
 }
 
 type applyResult struct {
 	resourceapply.ApplyResult
 
 	resourceMeta workapiv1.ManifestResourceMeta
+	// source is the configmap/secret on the hub containing the manifest if the manifest is a reference
+	source *manifestReference
+	// required is the raw manifest applied, it is nil if the manifest is applied to a subresource. It is not decoded
+	// here, so that the decoded object is released once the manifest is applied.
+	required []byte
+	// skipped is true if the manifest is not applied since it is not changed since it was applied
+	skipped bool
+	// resumed is true if the manifest was applied by the last reconcile interrupted by the apply deadline
+	resumed bool
 }
 
-// NewManifestWorkController returns a ManifestWorkController
+// workApply is the state of applying the manifests of a manifestwork by a reconcile, which is shared by its manifests.
+type workApply struct {
+	// namespace is the namespace of the manifestwork, where the manifests referenced on the hub are resolved
+	namespace    string
+	deleteOption *workapiv1.DeleteOption
+	recorder     events.Recorder
+	owner        metav1.OwnerReference
+	uids         map[appliedResourceKey]types.UID
+	provenance   *provenance
+	override     *namespaceOverride
+	scope        *namespaceScope
+	dependencies *manifestDependencies
+	// subresources, patches and timeouts are configured per manifest by the ordinals of the manifests
+	subresources map[int32]string
+	patches      map[int32]*helper.ManifestPatch
+	timeouts     map[int32]time.Duration
+	ssa          *serverSideApplies
+	budget       *appliedResourceBudget
+	hashes       *manifestHashView
+}
+
+// ManifestWorkControllerOptions are the options of the ManifestWorkController, the zero value disables the features.
+type ManifestWorkControllerOptions struct {
+	// HubKubeInformers resolve the manifests referencing the configmaps/secrets on the hub, the references are not
+	// supported if it is nil.
+	HubKubeInformers kubeinformers.SharedInformerFactory
+	// SpokeCRDInformer requeues the manifestworks waiting on the API versions not served by the spoke yet once the CRDs
+	// are installed if it is not nil.
+	SpokeCRDInformer cache.SharedIndexInformer
+	// PropagateProvenance injects the provenance labels/annotations into the applied resources.
+	PropagateProvenance bool
+	// StartupApplyQPS and StartupApplyBurst limit the first reconcile of the manifestworks after the agent starts, it
+	// is not limited if StartupApplyQPS is not positive.
+	StartupApplyQPS   float32
+	StartupApplyBurst int
+	// Limits are the limits of applying the manifestworks, which can be changed while the controller is running.
+	Limits *ApplyLimits
+	// AllowedNamespaces are the only namespaces the manifests are applied to if it is not empty, and the cluster
+	// scoped manifests are rejected unless AllowClusterScoped, see namespaceScope.
+	AllowedNamespaces  []string
+	AllowClusterScoped bool
+	// DefaultDeletePropagationPolicy is used by the manifestworks without a deleteOption, it is recorded on their
+	// appliedmanifestworks once they are created.
+	DefaultDeletePropagationPolicy workapiv1.DeletePropagationPolicyType
+	// DriftTracker records the baselines of the applied resources and requests the manifestworks with drifted
+	// resources to be applied again if it is not nil.
+	DriftTracker *helper.DriftTracker
+	// StatusWriter writes the status to the hub if it is not nil, so that the reconcile is not blocked by the hub.
+	StatusWriter *helper.StatusWriter
+	// PersistEventFingerprints emits the apply events only once the outcome of the apply is changed, even across the
+	// restarts of the agent.
+	PersistEventFingerprints bool
+	// JobTTLSecondsAfterFinished is the ttlSecondsAfterFinished of the Job manifests without one if it is not nil,
+	// and the completed Jobs deleted once the ttl expires are not applied again.
+	JobTTLSecondsAfterFinished *int64
+	// LiveObjects looks up the existing resources, it is shared with the other controllers if it is not nil.
+	LiveObjects *helper.LiveObjectCache
+	// ApplyDeadline interrupts a reconcile applying the manifests of a manifestwork for longer than it, the following
+	// reconcile resumes from the progress, there is no deadline if it is not positive.
+	ApplyDeadline time.Duration
+	// Transformers transform the manifests in order once they are decoded, before they are applied.
+	Transformers []helper.ManifestTransformer
+	// UpdateFieldManager is the field manager of the resources updated by the agent, whose fields are adopted once
+	// the manifests are changed to be applied with server side apply.
+	UpdateFieldManager string
+}
+
+// NewManifestWorkController returns a ManifestWorkController applying the manifestworks of the hub with the hubHash to
+// the spoke cluster. The manifests not changed since they were applied are not applied again as long as their
+// resources are available, unless a resync is requested with the annotation ResyncRequestAnnotationKey.
 func NewManifestWorkController(
 	ctx context.Context,
 	recorder events.Recorder,
@@ -70,27 +193,73 @@ func NewManifestWorkController(
 	appliedManifestWorkClient workv1client.AppliedManifestWorkInterface,
 	appliedManifestWorkInformer workinformer.AppliedManifestWorkInformer,
 	hubHash string,
-	restMapper meta.RESTMapper) factory.Controller {
+	restMapper meta.RESTMapper,
+	options ManifestWorkControllerOptions) factory.Controller {
 
 	controller := &ManifestWorkController{
-		manifestWorkClient:        manifestWorkClient,
-		manifestWorkLister:        manifestWorkLister,
-		appliedManifestWorkClient: appliedManifestWorkClient,
-		appliedManifestWorkLister: appliedManifestWorkInformer.Lister(),
-		spokeDynamicClient:        spokeDynamicClient,
-		spokeKubeclient:           spokeKubeClient,
-		spokeAPIExtensionClient:   spokeAPIExtensionClient,
-		hubHash:                   hubHash,
-		restMapper:                restMapper,
-	}
-
-	return factory.New().
+		manifestWorkClient:         manifestWorkClient,
+		manifestWorkLister:         manifestWorkLister,
+		appliedManifestWorkClient:  appliedManifestWorkClient,
+		appliedManifestWorkIndexer: appliedManifestWorkInformer.Informer().GetIndexer(),
+		spokeDynamicClient:         spokeDynamicClient,
+		spokeKubeclient:            spokeKubeClient,
+		spokeAPIExtensionClient:    spokeAPIExtensionClient,
+		hubHash:                    hubHash,
+		restMapper:                 restMapper,
+		propagateProvenance:        options.PropagateProvenance,
+		startupThrottle:            newStartupThrottle(options.StartupApplyQPS, options.StartupApplyBurst, clock.RealClock{}),
+		limits:                     options.Limits,
+		namespaceScope:             newNamespaceScope(options.AllowedNamespaces, options.AllowClusterScoped),
+		apiVersionChecker:          helper.NewAPIVersionChecker(spokeKubeClient.Discovery()),
+		driftTracker:               options.DriftTracker,
+		manifestHashes:             newManifestHashes(),
+		applyRetries:               newApplyRetries(),
+		dependencyWaits:            newDependencyWaits(),
+		patchedVersions:            newPatchedVersions(),
+		statusWriter:               options.StatusWriter,
+		persistEventFingerprints:   options.PersistEventFingerprints,
+		jobTTLSecondsAfterFinished: options.JobTTLSecondsAfterFinished,
+		liveObjects:                options.LiveObjects,
+		applyDeadline:              options.ApplyDeadline,
+		transformers:               options.Transformers,
+		updateFieldManager:         options.UpdateFieldManager,
+
+		defaultDeletePropagationPolicy: options.DefaultDeletePropagationPolicy,
+	}
+
+	controllerFactory := factory.New().
 		WithInformersQueueKeyFunc(func(obj runtime.Object) string {
 			accessor, _ := meta.Accessor(obj)
 			return accessor.GetName()
 		}, manifestWorkInformer.Informer()).
-		WithInformersQueueKeyFunc(helper.AppliedManifestworkQueueKeyFunc(hubHash), appliedManifestWorkInformer.Informer()).
-		WithSync(controller.sync).ResyncEvery(ResyncInterval).ToController("ManifestWorkAgent", recorder)
+		WithInformersQueueKeyFunc(helper.AppliedManifestworkQueueKeyFunc(hubHash), appliedManifestWorkInformer.Informer())
+
+	if hubKubeInformers := options.HubKubeInformers; hubKubeInformers != nil {
+		controller.hubConfigMapLister = hubKubeInformers.Core().V1().ConfigMaps().Lister()
+		controller.hubSecretLister = hubKubeInformers.Core().V1().Secrets().Lister()
+		controller.manifestReferences = newManifestReferences()
+		controllerFactory = controllerFactory.WithInformersQueueKeyFunc(referenceQueueKeyFunc,
+			hubKubeInformers.Core().V1().ConfigMaps().Informer(), hubKubeInformers.Core().V1().Secrets().Informer())
+	}
+
+	if spokeCRDInformer := options.SpokeCRDInformer; spokeCRDInformer != nil {
+		controller.crdStore = spokeCRDInformer.GetStore()
+		controller.apiVersionInterest = newAPIVersionInterest(defaultMaxAPIVersionInterests)
+		controllerFactory = controllerFactory.WithInformersQueueKeyFunc(crdQueueKeyFunc, spokeCRDInformer)
+	}
+
+	if driftTracker := options.DriftTracker; driftTracker != nil {
+		controllerFactory = controllerFactory.WithPostStartHooks(func(ctx context.Context, syncContext factory.SyncContext) error {
+			driftTracker.SetRemediateFunc(func(manifestWorkName string) {
+				// the drifted resources are applied again even if their manifests are not changed
+				controller.manifestHashes.forget(manifestWorkName)
+				syncContext.Queue().Add(manifestWorkName)
+			})
+			return nil
+		})
+	}
+
+	return controllerFactory.WithSync(controllers.InstrumentSync(hubHash, "ManifestWorkAgent", helper.RequeueNotAllowedSync(controller.sync))).ResyncEvery(ResyncInterval).ToController("ManifestWorkAgent", recorder)
 }
 
 // sync is the main reconcile loop for manifest work. It is triggered in two scenarios
@@ -98,17 +267,37 @@ func NewManifestWorkController(
 // 2. Resources defined in manifest changed on spoke
 func (m *ManifestWorkController) sync(ctx context.Context, controllerContext factory.SyncContext) error {
 	manifestWorkName := controllerContext.QueueKey()
+	if isReferenceQueueKey(manifestWorkName) {
+		// a configmap/secret on the hub is changed, requeue the manifestworks referencing it
+		return m.enqueueReferencingWorks(controllerContext, manifestWorkName)
+	}
+	if isCRDQueueKey(manifestWorkName) {
+		// a CRD on the spoke is changed, requeue the manifestworks waiting on the API versions it serves
+		return m.enqueueWaitingWorks(controllerContext, manifestWorkName)
+	}
+	if name, ok := helper.MalformedAppliedManifestWorkName(manifestWorkName); ok {
+		klog.Warningf("Ignore appliedmanifestwork %q, its name is not the hub hash and a manifestwork name joined with \"-\"", name)
+		return nil
+	}
 	klog.V(4).Infof("Reconciling ManifestWork %q", manifestWorkName)
 
 	manifestWork, err := m.manifestWorkLister.Get(manifestWorkName)
 	if errors.IsNotFound(err) {
 		// work not found, could have been deleted, do nothing.
+		m.registerAPIVersionInterest(manifestWorkName, nil)
+		m.driftTracker.SetBaselines(manifestWorkName, nil)
+		m.manifestHashes.forget(manifestWorkName)
+		m.applyRetries.reset(manifestWorkName)
+		m.dependencyWaits.reset(manifestWorkName)
+		m.manifestReferences.register(manifestWorkName, nil)
 		return nil
 	}
 	if err != nil {
 		return err
 	}
 	manifestWork = manifestWork.DeepCopy()
+	// the manifestwork is requeued once the configmaps/secrets it references are changed
+	m.manifestReferences.register(manifestWorkName, referencesOf(manifestWork))
 
 	// no work to do if we're deleted
 	if !manifestWork.DeletionTimestamp.IsZero() {
@@ -119,7 +308,7 @@ func (m *ManifestWorkController) sync(ctx context.Context, controllerContext fac
 	// it ensures all maintained resources will be cleaned once manifestwork is deleted
 	found := false
 	for i := range manifestWork.Finalizers {
-		if manifestWork.Finalizers[i] == controllers.ManifestWorkFinalizer {
+		if manifestWork.Finalizers[i] == constants.ManifestWorkFinalizer {
 			found = true
 			break
 		}
@@ -127,54 +316,202 @@ func (m *ManifestWorkController) sync(ctx context.Context, controllerContext fac
 	if !found {
 		return nil
 	}
+
+	// freeze the reconciliation of a paused manifestwork, it is reconciled fully again once it is resumed
+	if helper.IsWorkPaused(manifestWork) {
+		klog.V(4).Infof("ManifestWork %q is paused, skip applying manifests", manifestWorkName)
+		return m.setPausedCondition(ctx, manifestWork)
+	}
+
+	// stop applying the manifests once a run-to-completion manifestwork is completed, so that the completed
+	// payload, e.g. a job, is not recreated after it is deleted.
+	if helper.IsWorkCompleted(manifestWork) {
+		klog.V(4).Infof("ManifestWork %q is completed, skip applying manifests", manifestWorkName)
+		return nil
+	}
+
+	// the manifestworks created before the webhook limited the number of manifests are not applied
+	if maxManifests := m.limits.MaxManifests(); exceedsMaxManifests(manifestWork, maxManifests) {
+		klog.Warningf("ManifestWork %q has %d manifests which exceeds the limit of %d manifests, skip applying manifests",
+			manifestWorkName, len(manifestWork.Spec.Workload.Manifests), maxManifests)
+		return m.setTooManyManifestsCondition(ctx, manifestWork, maxManifests)
+	}
+
 	// Apply appliedManifestWork
 	appliedManifestWorkName := fmt.Sprintf("%s-%s", m.hubHash, manifestWork.Name)
-	appliedManifestWork, err := m.appliedManifestWorkLister.Get(appliedManifestWorkName)
+	appliedManifestWork, err := helper.GetAppliedManifestWorkByManifestWork(m.appliedManifestWorkIndexer, m.hubHash, manifestWork.Name)
 	switch {
 	case errors.IsNotFound(err):
 		appliedManifestWork = &workapiv1.AppliedManifestWork{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:       appliedManifestWorkName,
-				Finalizers: []string{controllers.AppliedManifestWorkFinalizer},
+				Finalizers: []string{constants.AppliedManifestWorkFinalizer},
 			},
 			Spec: workapiv1.AppliedManifestWorkSpec{
 				HubHash:          m.hubHash,
 				ManifestWorkName: manifestWorkName,
 			},
 		}
+		if len(m.defaultDeletePropagationPolicy) > 0 {
+			appliedManifestWork.Annotations = map[string]string{
+				constants.DefaultDeletePropagationPolicyAnnotationKey: string(m.defaultDeletePropagationPolicy),
+			}
+		}
 		appliedManifestWork, err = m.appliedManifestWorkClient.Create(ctx, appliedManifestWork, metav1.CreateOptions{})
 		if err != nil {
-			return err
+			return withRetryDelays(originSpokeWrite, err)
 		}
 	case err != nil:
 		return err
 	}
 
+	// a resync requested with the annotation applies all the manifests again, even if they are not changed
+	resyncRequest := pendingResyncRequest(manifestWork)
+	if len(resyncRequest) > 0 {
+		klog.V(4).Infof("ManifestWork %q is requested to resync with %q", manifestWorkName, resyncRequest)
+		m.manifestHashes.forget(manifestWorkName)
+	}
+
+	// pace the first reconcile of the manifestworks after the agent starts
+	if m.startupThrottle.isFirstReconcile(manifestWorkName) {
+		unchanged := len(resyncRequest) == 0 && isUnchangedSinceApplied(manifestWork, appliedManifestWork)
+		if !m.startupThrottle.admit(manifestWorkName, unchanged) {
+			controllerContext.Queue().AddAfter(manifestWorkName, m.startupThrottle.requeueAfter)
+			return nil
+		}
+		if unchanged {
+			klog.V(4).Infof("ManifestWork %q is not changed since it was applied, skip the first reconcile", manifestWorkName)
+			return nil
+		}
+	}
+
 	// We creat a ownerref instead of controller ref since multiple controller can declare the ownership of a manifests
 	owner := helper.NewAppliedManifestWorkOwner(appliedManifestWork)
+	// uids of the applied resources, they are verified on apply to avoid updating resources recreated by others
+	uids := recordedUIDs(appliedManifestWork)
+	provenance := m.provenanceOf(manifestWork)
+	override := namespaceOverrideOf(manifestWork)
+	scope := namespaceScopeOf(manifestWork, m.namespaceScope)
+	// the events of the resources are attributed to the manifestwork
+	recorder := helper.NewWorkEventRecorder(controllerContext.Recorder(), m.hubHash, manifestWorkName)
+	// the resources new to the manifestwork are applied within the cap of the resources applied by the agent
+	budget := newAppliedResourceBudget(m.limits.MaxAppliedResources(), m.appliedManifestWorkIndexer)
+	// the owner is removed from the resources orphaned on deletion
+	deleteOption := helper.EffectiveDeleteOption(manifestWork, appliedManifestWork)
+	// the manifests not changed since they were applied are not applied again
+	hashes := m.manifestHashes.reconcileOf(manifestWork)
 
 	errs := []error{}
 	// Apply resources on spoke cluster.
 	resourceResults := make([]applyResult, len(manifestWork.Spec.Workload.Manifests))
-	retry.RetryOnConflict(retry.DefaultBackoff, func() error {
-		resourceResults = m.applyManifests(
-			ctx, manifestWork.Spec.Workload.Manifests, manifestWork.Spec.DeleteOption, controllerContext.Recorder(), *owner, resourceResults)
+	processed := len(resourceResults)
+	applyStartTime := time.Now()
+	subresources, err := helper.ManifestSubresources(manifestWork)
+	var patches map[int32]*helper.ManifestPatch
+	if err == nil {
+		patches, err = helper.ManifestPatches(manifestWork)
+	}
+	var ssa *serverSideApplies
+	var serverSideApplyOrdinals map[int32]bool
+	if err == nil {
+		serverSideApplyOrdinals, err = helper.ManifestServerSideApplies(manifestWork)
+	}
+	var timeouts map[int32]time.Duration
+	if err == nil {
+		timeouts, err = helper.ManifestApplyTimeouts(manifestWork)
+	}
+	var dependencies map[int32][]int32
+	if err == nil {
+		dependencies, err = helper.ManifestDependencies(manifestWork)
+	}
+	var dependencyDeadline time.Duration
+	if err == nil {
+		dependencyDeadline, err = helper.DependencyDeadline(manifestWork)
+	}
+	if err != nil {
+		// none of the manifests is applied, since it is unknown how they should be applied
+		for index := range resourceResults {
+			resourceResults[index].resourceMeta.Ordinal = int32(index)
+			resourceResults[index].Error = err
+		}
+	} else {
+		// the patches dropped from the manifestwork are reverted, the patched resources are not owned by it
+		if err := m.revertDroppedPatches(ctx, appliedManifestWork, patches, recorder); err != nil {
+			errs = append(errs, err)
+		}
+		// the manifests wait for the manifests they depend on to be available before they are applied
+		waits := manifestDependenciesOf(manifestWork, dependencies)
+		// the completed Jobs deleted by the TTL controller are not created again
+		skipExpiredJobs(manifestWork, resourceResults)
+		// the manifests applied by the last reconcile interrupted by the apply deadline are not applied again
+		hashes.resume(manifestWork.Generation, resourceResults)
+		deadline := m.applyDeadlineOf(applyStartTime, hashes)
+		ssa = serverSideAppliesOf(appliedManifestWork, serverSideApplyOrdinals)
+		apply := &workApply{
+			namespace:    manifestWork.Namespace,
+			deleteOption: deleteOption,
+			recorder:     recorder,
+			owner:        *owner,
+			uids:         uids,
+			provenance:   provenance,
+			override:     override,
+			scope:        scope,
+			dependencies: waits,
+			subresources: subresources,
+			patches:      patches,
+			ssa:          ssa,
+			timeouts:     timeouts,
+			budget:       budget,
+			hashes:       hashes,
+		}
+		retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+			resourceResults, processed = m.applyManifests(ctx, apply, manifestWork.Spec.Workload.Manifests, deadline, resourceResults)
 
-		for _, result := range resourceResults {
-			if errors.IsConflict(result.Error) {
-				return result.Error
+			for _, result := range resourceResults {
+				if errors.IsConflict(result.Error) {
+					return result.Error
+				}
 			}
-		}
 
-		return nil
-	})
+			return nil
+		})
+	}
+	// the update strategies of the resources are recorded, so that their field ownership is migrated once changed
+	if err := m.recordServerSideApplies(
+		ctx, appliedManifestWork, ssa, resourceResults, processed == len(resourceResults)); err != nil {
+		errs = append(errs, err)
+	}
+	if processed < len(resourceResults) {
+		// report the manifests applied so far and resume applying the others in the next reconcile
+		return m.checkpointApply(ctx, controllerContext, manifestWork, hashes, resourceResults[:processed])
+	}
+	stats := applyStats{lastAppliedTime: time.Now()}
+	stats.duration = stats.lastAppliedTime.Sub(applyStartTime)
+
+	// the resources applied from the manifests which cannot be decoded any more are not pruned
+	decodeFailed := keepLastKnownResources(manifestWork, resourceResults)
 
 	newManifestConditions := []workapiv1.ManifestCondition{}
-	for _, result := range resourceResults {
-		if result.Error != nil {
-			errs = append(errs, result.Error)
+	manifestErrors := make([]error, len(resourceResults))
+	waitingGroupVersions := []schema.GroupVersion{}
+	capExceeded, waiting := 0, 0
+	deprecated := map[int32]bool{}
+	for index, result := range resourceResults {
+		manifestErrors[index] = result.Error
+		if isAppliedResourceCapExceededError(result.Error) {
+			capExceeded++
+		}
+		if isDependencyWaitingError(result.Error) {
+			waiting++
+		}
+		if helper.IsAPIVersionNotAvailableError(result.Error) {
+			waitingGroupVersions = append(waitingGroupVersions,
+				schema.GroupVersion{Group: result.resourceMeta.Group, Version: result.resourceMeta.Version})
+		}
+		if result.Error != nil && helper.ClassifyApplyError(result.Error) == helper.ApplyErrorTerminal {
+			klog.Warningf("Failed to apply manifest %d of work %s with terminal error: %v",
+				result.resourceMeta.Ordinal, manifestWorkName, result.Error)
 		}
-
 		manifestCondition := workapiv1.ManifestCondition{
 			ResourceMeta: result.resourceMeta,
 			Conditions:   []metav1.Condition{},
@@ -182,157 +519,329 @@ func (m *ManifestWorkController) sync(ctx context.Context, controllerContext fac
 
 		// Add applied status condition
 		manifestCondition.Conditions = append(manifestCondition.Conditions, buildAppliedStatusCondition(result))
+		// warn about the apiVersion deprecated by the spoke, the manifest is still applied
+		if condition, ok := apiVersionDeprecatedCondition(m.apiVersionChecker, result.resourceMeta, result.Error); ok {
+			manifestCondition.Conditions = append(manifestCondition.Conditions, condition)
+			deprecated[result.resourceMeta.Ordinal] = true
+		}
+		if isManifestDecodeFailedError(result.Error) {
+			manifestCondition.Conditions = append(manifestCondition.Conditions, manifestDecodeFailedCondition(result.Error))
+		}
 
 		newManifestConditions = append(newManifestConditions, manifestCondition)
 	}
 
-	// Update work status
-	_, _, err = helper.UpdateManifestWorkStatus(
-		ctx, m.manifestWorkClient, manifestWork, m.generateUpdateStatusFunc(manifestWork.Generation, newManifestConditions))
+	// the events are emitted only if the outcome of the apply is changed
+	m.emitApplyEvents(ctx, recorder, appliedManifestWork, manifestWork.Generation, applyEventsOf(resourceResults))
+	m.registerAPIVersionInterest(manifestWorkName, waitingGroupVersions)
+	m.recordDriftBaselines(manifestWorkName, resourceResults)
+
+	appliedCondition, err := helper.AggregateManifestErrors(manifestWork.Generation, manifestErrors)
 	if err != nil {
-		errs = append(errs, fmt.Errorf("Failed to update work status with err %w", err))
+		errs = append(errs, err)
+	}
+	if policy := defaultDeletePropagationPolicyOf(manifestWork, appliedManifestWork); appliedCondition != nil && len(policy) > 0 {
+		appliedCondition.Message = fmt.Sprintf("%s (delete propagation policy %s by default of the agent)", appliedCondition.Message, policy)
+	}
+	// count the failed attempts since the manifestwork was last applied successfully
+	if appliedCondition != nil && appliedCondition.Status == metav1.ConditionFalse {
+		recorded := 0
+		if existing := meta.FindStatusCondition(manifestWork.Status.Conditions, workapiv1.WorkApplied); existing != nil &&
+			existing.Status == metav1.ConditionFalse {
+			if existingStats, ok := parseRetryStats(existing.Message); ok {
+				recorded = existingStats.retries
+			}
+		}
+		retries := newRetryStats(m.applyRetries.failed(manifestWorkName, recorded), stats.lastAppliedTime, manifestErrors)
+		stats.retries = &retries
+	} else if appliedCondition != nil {
+		m.applyRetries.reset(manifestWorkName)
+	}
+
+	// the manifestwork is degraded once its manifests wait for the manifests they depend on beyond the deadline
+	deadlineExceeded := false
+	if waiting > 0 {
+		since := m.dependencyWaits.waitingSince(manifestWorkName, manifestWork.Generation, stats.lastAppliedTime)
+		deadlineExceeded = dependencyDeadline > 0 && stats.lastAppliedTime.Sub(since) > dependencyDeadline
+	} else {
+		m.dependencyWaits.reset(manifestWorkName)
+	}
+
+	// warn about the orphaning rules matching none of the resources, e.g. with a typo
+	unmatchedRules, rulesChecked := unmatchedOrphaningRules(deleteOption, resourceResults)
+
+	// Update work status
+	updateStatusFunc := withAppliedResourceCapCondition(
+		m.generateUpdateStatusFunc(newManifestConditions, appliedCondition, stats), budget, manifestWork.Generation, capExceeded)
+	updateStatusFunc = withOrphaningRuleUnmatchedCondition(
+		updateStatusFunc, deleteOption, manifestWork.Generation, unmatchedRules, rulesChecked)
+	updateStatusFunc = withSpecFeaturesNotSupportedCondition(
+		updateStatusFunc, manifestWork.Generation, unsupportedSpecFeatures(manifestWork))
+	updateStatusFunc = withResyncRequestHandledCondition(updateStatusFunc, manifestWork.Generation, resyncRequest)
+	updateStatusFunc = withDependencyDeadlineCondition(
+		updateStatusFunc, manifestWork.Generation, waiting, dependencyDeadline, deadlineExceeded)
+	updateStatusFunc = withAPIVersionDeprecatedConditions(updateStatusFunc, deprecated)
+	updateStatusFunc = withManifestDecodeErrorCondition(updateStatusFunc, manifestWork.Generation, decodeFailed)
+	if err := m.updateStatus(ctx, manifestWork, updateStatusFunc); err != nil {
+		errs = append(errs, withOrigin(originHubWrite, fmt.Errorf("Failed to update work status with err %w", err)))
+	} else if appliedCondition != nil && appliedCondition.Status == metav1.ConditionTrue {
+		if err := m.recordApplyStats(ctx, manifestWork, stats); err != nil {
+			errs = append(errs, withOrigin(originHubWrite, fmt.Errorf("Failed to record the apply stats of work with err %w", err)))
+		}
 	}
 	if len(errs) > 0 {
 		err = utilerrors.NewAggregate(errs)
 		klog.Errorf("Reconcile work %s fails with err: %v", manifestWorkName, err)
+		// the errors of the spoke are retried after the delays of their classes
+		return withRetryDelays(originSpokeWrite, err)
 	}
-	return err
+
+	// the applied spec hash is only read by the startup throttle, it is not recorded if the throttle is disabled
+	if m.startupThrottle.enabled() && appliedCondition != nil && appliedCondition.Status == metav1.ConditionTrue {
+		if err := m.recordAppliedSpecHash(ctx, manifestWork, appliedManifestWork, resourceResults); err != nil {
+			return withRetryDelays(originSpokeWrite, err)
+		}
+	}
+	return nil
 }
 
 func (m *ManifestWorkController) applyManifests(
 	ctx context.Context,
+	apply *workApply,
 	manifests []workapiv1.Manifest,
-	deleteOption *workapiv1.DeleteOption,
-	recorder events.Recorder,
-	owner metav1.OwnerReference,
-	existingResults []applyResult) []applyResult {
+	deadline time.Time,
+	existingResults []applyResult) ([]applyResult, int) {
 
+	applied := 0
 	for index, manifest := range manifests {
 		switch {
+		case existingResults[index].skipped:
+			// Do not apply if the manifest is not changed since it was applied.
+		case existingResults[index].resumed:
+			// Do not apply if the manifest was applied by the reconcile interrupted by the apply deadline.
+		case applied > 0 && !deadline.IsZero() && !time.Now().Before(deadline) &&
+			(existingResults[index].Result == nil || errors.IsConflict(existingResults[index].Error)):
+			// Stop applying once the deadline is exceeded, the other manifests are applied by the next reconcile.
+			return existingResults, index
 		case existingResults[index].Result == nil:
 			// Apply if there is not result.
-			existingResults[index] = m.applyOneManifest(ctx, index, manifest, deleteOption, recorder, owner)
+			existingResults[index] = m.applyOneManifestWithTimeout(ctx, apply, index, manifest, existingResults[:index])
+			m.invalidateLiveObject(existingResults[index].resourceMeta)
+			applied++
 		case errors.IsConflict(existingResults[index].Error):
 			// Apply if there is a resource confilct error.
-			existingResults[index] = m.applyOneManifest(ctx, index, manifest, deleteOption, recorder, owner)
+			existingResults[index] = m.applyOneManifestWithTimeout(ctx, apply, index, manifest, existingResults[:index])
+			m.invalidateLiveObject(existingResults[index].resourceMeta)
+			applied++
 		}
 	}
 
-	return existingResults
+	return existingResults, len(manifests)
+}
+
+// applyOneManifestWithTimeout applies the manifest within the timeout, so that a hanging request, e.g. to an admission
+// webhook, does not stall applying the other manifests. An ApplyTimeoutError is returned once the timeout is exceeded.
+func (m *ManifestWorkController) applyOneManifestWithTimeout(
+	ctx context.Context, apply *workApply, index int, manifest workapiv1.Manifest, siblings []applyResult) applyResult {
+	timeout, ok := apply.timeouts[int32(index)]
+	if !ok {
+		timeout = m.limits.ApplyTimeout()
+	}
+	if timeout <= 0 {
+		return m.applyOneManifest(ctx, apply, index, manifest, siblings)
+	}
+
+	applyCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := m.applyOneManifest(applyCtx, apply, index, manifest, siblings)
+	if result.Error != nil && (goerrors.Is(result.Error, context.DeadlineExceeded) || applyCtx.Err() == context.DeadlineExceeded) {
+		result.Error = &helper.ApplyTimeoutError{Err: result.Error, Timeout: timeout}
+	}
+	return result
 }
 
 func (m *ManifestWorkController) applyOneManifest(
-	ctx context.Context,
-	index int,
-	manifest workapiv1.Manifest,
-	deleteOption *workapiv1.DeleteOption,
-	recorder events.Recorder,
-	owner metav1.OwnerReference) applyResult {
+	ctx context.Context, apply *workApply, index int, manifest workapiv1.Manifest, siblings []applyResult) applyResult {
+	subresource := apply.subresources[int32(index)]
 
-	clientHolder := resourceapply.NewClientHolder().
-		WithAPIExtensionsClient(m.spokeAPIExtensionClient).
-		WithKubernetes(m.spokeKubeclient).
-		WithDynamicClient(m.spokeDynamicClient)
+	// the manifest of a patch is the patch document of an existing resource, it is not decoded as an object
+	if patch := apply.patches[int32(index)]; patch != nil {
+		return m.applyPatch(ctx, index, manifest, patch, apply.scope, apply.recorder)
+	}
 
+	resourceApplier := m.resourceApplier()
 	result := applyResult{}
 
-	resMeta, gvr, err := buildManifestResourceMeta(index, manifest, m.restMapper)
-	result.resourceMeta = resMeta
+	// the manifest referenced on the hub is decoded once it is resolved
+	err := checkManifestDecodable(manifest)
+	if err == nil {
+		manifest, result.source, err = m.resolveManifest(apply.namespace, manifest)
+		if err == nil && result.source != nil {
+			err = checkManifestDecodable(manifest)
+		}
+	}
+	if err == nil {
+		manifest, err = sanitizeManifest(manifest, subresource == helper.SubresourceStatus)
+	}
+	if err == nil {
+		// the stringData of secrets is converted into data, so that the equivalent manifests are hashed the same
+		manifest, err = applier.NormalizeSecret(manifest)
+	}
+	if err == nil {
+		manifest, err = apply.provenance.inject(manifest)
+	}
+	if err == nil {
+		manifest, err = apply.override.apply(manifest, m.restMapper)
+	}
+	if err == nil {
+		manifest, err = apply.scope.apply(manifest, m.restMapper)
+	}
+	if err == nil {
+		manifest, err = injectJobTTL(manifest, m.jobTTLSecondsAfterFinished)
+	}
 	if err != nil {
+		result.resourceMeta.Ordinal = int32(index)
 		result.Error = err
 		return result
 	}
 
-	owner = manageOwnerRef(gvr, resMeta.Namespace, resMeta.Name, deleteOption, owner)
+	resMeta, gvr, err := resourceApplier.ResourceMeta(index, manifest)
+	result.resourceMeta = resMeta
+	if helper.IsAPIVersionNotAvailableError(err) {
+		// the apiVersion removed by the spoke will never be available
+		err = m.apiVersionChecker.Removed(schema.GroupVersionKind{Group: resMeta.Group, Version: resMeta.Version, Kind: resMeta.Kind},
+			resMeta.Namespace, resMeta.Name, err)
+	}
+	if err == nil && m.limits.IsForbidden(gvr.GroupResource()) {
+		err = applyerrors.NewResourceForbiddenByAgentPolicyError(gvr, resMeta.Namespace, resMeta.Name,
+			fmt.Errorf("the resource %s is forbidden by the agent", gvr.GroupResource()))
+	}
+	if err == nil {
+		// the manifest is transformed before it is applied and hashed, so it is applied again once the
+		// transformation is changed
+		manifest, err = transformManifest(ctx, manifest, resMeta, gvr, m.transformers)
+	}
+	if err != nil {
+		result.Error = err
+		return result
+	}
 
-	results := resourceapply.ApplyDirectly(ctx, clientHolder, recorder, func(name string) ([]byte, error) {
-		unstructuredObj := &unstructured.Unstructured{}
-		err := unstructuredObj.UnmarshalJSON(manifest.Raw)
-		if err != nil {
-			return nil, err
+	// the manifest is not gated by the manifests it depends on any more once it is applied
+	key := appliedResourceKey{group: gvr.Group, resource: gvr.Resource, namespace: resMeta.Namespace, name: resMeta.Name}
+	if _, applied := apply.uids[key]; !applied {
+		if err := apply.dependencies.wait(index); err != nil {
+			result.Error = err
+			return result
 		}
-
-		unstructuredObj.SetOwnerReferences([]metav1.OwnerReference{owner})
-		return unstructuredObj.MarshalJSON()
-	}, "manifest")
-
-	result.Result = results[0].Result
-	result.Changed = results[0].Changed
-	result.Error = results[0].Error
-
-	// Try apply with dynamic client if the manifest cannot be decoded by scheme or typed client is not found
-	// TODO we should check the certain error.
-	// Use dynamic client when scheme cannot decode manifest or typed client cannot handle the object
-	if isDecodeError(result.Error) || isUnhandledError(result.Error) || isUnsupportedError(result.Error) {
-		result.Result, result.Changed, result.Error = m.applyUnstructured(ctx, manifest.Raw, owner, gvr, recorder)
 	}
 
-	return result
-}
-
-func (m *ManifestWorkController) decodeUnstructured(data []byte) (*unstructured.Unstructured, error) {
-	unstructuredObj := &unstructured.Unstructured{}
-	err := unstructuredObj.UnmarshalJSON(data)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to decode object: %w", err)
+	// the manifest applied to a subresource drives a part of an existing resource, the resource is not owned
+	if len(subresource) != 0 {
+		result.Result, result.Changed, result.Error = m.applySubresource(ctx, manifest.Raw, gvr, subresource, apply.recorder)
+		return result
 	}
 
+	// the owner references declared by the manifest are kept, and the siblings they name are resolved
+	manifest, err = resolveOwnerReferences(manifest, siblings, apply.uids)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to find gvr from restmapping: %w", err)
+		result.Error = err
+		return result
 	}
 
-	return unstructuredObj, nil
-}
+	result.required = manifest.Raw
 
-func (m *ManifestWorkController) applyUnstructured(
-	ctx context.Context,
-	data []byte,
-	owner metav1.OwnerReference,
-	gvr schema.GroupVersionResource,
-	recorder events.Recorder) (*unstructured.Unstructured, bool, error) {
+	owner := manageOwnerRef(gvr, resMeta.Namespace, resMeta.Name, apply.deleteOption, apply.owner)
 
-	required, err := m.decodeUnstructured(data)
+	hash, err := manifestHash(manifest, owner)
 	if err != nil {
-		return nil, false, err
+		result.Error = err
+		return result
+	}
+	// the manifest is applied again once its update strategy is changed
+	serverSide := apply.ssa.enabled(int32(index))
+	if serverSide {
+		hash = hash + "/" + helper.UpdateStrategyTypeServerSideApply
+	}
+	if apply.hashes.isUnchanged(resMeta, hash, apply.uids[key]) {
+		klog.V(4).Infof("Manifest %d%s is not changed since it was applied, skip applying it", index, resourceMessage(resMeta))
+		unchangedManifestsSkipped.Inc()
+		result.skipped = true
+		return result
 	}
 
-	required.SetOwnerReferences([]metav1.OwnerReference{owner})
+	if _, ok := apply.uids[key]; !ok {
+		if err := apply.budget.admit(key); err != nil {
+			result.Error = err
+			return result
+		}
+	}
+	expectedUID, err := m.adoptionUID(ctx, gvr, resMeta, apply.uids[key], apply.recorder)
+	if err != nil {
+		result.Error = withOrigin(originSpokeRead, err)
+		return result
+	}
 
-	existing, err := m.spokeDynamicClient.
-		Resource(gvr).
-		Namespace(required.GetNamespace()).
-		Get(ctx, required.GetName(), metav1.GetOptions{})
+	if err := m.migrateFieldOwnership(ctx, gvr, resMeta, apply.ssa, serverSide, apply.recorder); err != nil {
+		result.Error = withOrigin(originSpokeWrite, err)
+		return result
+	}
+	if serverSide {
+		var actual *unstructured.Unstructured
+		actual, result.Changed, result.Error = resourceApplier.ApplyResourceServerSide(
+			ctx, manifest, gvr, &owner, expectedUID, helper.ServerSideApplyFieldManager, apply.recorder)
+		if actual != nil {
+			result.Result = actual
+		}
+		if result.Error == nil {
+			apply.ssa.record(gvr, resMeta, true)
+		}
+	} else {
+		result.Result, result.Changed, result.Error = resourceApplier.ApplyResource(ctx, manifest, gvr, &owner, expectedUID, apply.recorder)
+	}
 
-	switch {
-	case errors.IsNotFound(err):
-		actual, err := m.spokeDynamicClient.Resource(gvr).Namespace(required.GetNamespace()).Create(
-			ctx, resourcemerge.WithCleanLabelsAndAnnotations(required).(*unstructured.Unstructured), metav1.CreateOptions{})
-		recorder.Eventf(fmt.Sprintf(
-			"%s Created", required.GetKind()), "Created %s/%s because it was missing", required.GetNamespace(), required.GetName())
-		return actual, true, err
-	case err != nil:
-		return nil, false, err
+	if result.Error == nil {
+		result.Error = verifyAppliedUID(gvr, expectedUID, result.Result)
 	}
+	appliedUID := types.UID("")
+	if result.Error == nil {
+		if accessor, err := meta.Accessor(result.Result); err == nil {
+			appliedUID = accessor.GetUID()
+		}
+	}
+	apply.hashes.record(int32(index), hash, appliedUID)
 
-	// Merge OwnerRefs.
-	existingOwners := existing.GetOwnerReferences()
-	modified := resourcemerge.BoolPtr(false)
+	// the resource is shared with other manifestworks, e.g. the manifest is moved from another manifestwork, the
+	// resource will only be handed over to this manifestwork once it is removed from the other manifestworks.
+	if result.Error == nil && result.Changed {
+		if accessor, err := meta.Accessor(result.Result); err == nil {
+			if others := helper.OtherAppliedManifestWorkOwners(owner, accessor.GetOwnerReferences()); len(others) > 0 {
+				apply.recorder.Eventf("ResourceOwnershipShared", "%s %s/%s is also owned by %s",
+					resMeta.Kind, resMeta.Namespace, resMeta.Name, strings.Join(others, ", "))
+			}
+		}
+	}
 
-	resourcemerge.MergeOwnerRefs(modified, &existingOwners, required.GetOwnerReferences())
+	return result
+}
 
-	// Always overwrite required ownerrefs from existing, since ownerrefs of required has been merged to existing
-	required.SetOwnerReferences(existingOwners)
+// resourceApplier returns the applier applying the manifests to the spoke cluster. The controller shares the apply
+// pipeline of the applier, but applies the manifests one by one with ResourceMeta and ApplyResource instead of Apply,
+// since a manifest of a manifestwork is skipped if it is not changed, waits on the manifests it depends on, is
+// patched, applied with server side apply or to a subresource, and is applied with the UID of the resource recorded
+// on the appliedmanifestwork as the precondition, which are not options of Apply.
+func (m *ManifestWorkController) resourceApplier() *applier.Applier {
+	return applier.NewApplier(m.spokeKubeclient, m.spokeAPIExtensionClient, m.spokeDynamicClient, m.restMapper)
+}
 
-	// Compare and update the unstrcuctured.
-	if isSameUnstructured(required, existing) {
-		return existing, false, nil
+// defaultDeletePropagationPolicyOf returns the default delete propagation policy recorded on the appliedmanifestwork
+// if the manifestwork does not specify a deleteOption, otherwise an empty policy is returned.
+func defaultDeletePropagationPolicyOf(
+	manifestWork *workapiv1.ManifestWork, appliedManifestWork *workapiv1.AppliedManifestWork) workapiv1.DeletePropagationPolicyType {
+	if manifestWork.Spec.DeleteOption != nil {
+		return ""
 	}
-	required.SetResourceVersion(existing.GetResourceVersion())
-	actual, err := m.spokeDynamicClient.Resource(gvr).Namespace(required.GetNamespace()).Update(
-		ctx, required, metav1.UpdateOptions{})
-	recorder.Eventf(fmt.Sprintf(
-		"%s Updated", required.GetKind()), "Updated %s/%s", required.GetNamespace(), required.GetName())
-	return actual, true, err
+	return workapiv1.DeletePropagationPolicyType(appliedManifestWork.Annotations[constants.DefaultDeletePropagationPolicyAnnotationKey])
 }
 
 // manageOwnerRef return a ownerref based on the resource and the deleteOption indicating whether the owneref
@@ -343,246 +852,166 @@ func manageOwnerRef(
 	deleteOption *workapiv1.DeleteOption,
 	myOwner metav1.OwnerReference) metav1.OwnerReference {
 
-	// Be default, it is forgound deletion.
-	if deleteOption == nil {
+	if !helper.IsOrphaned(gvr.Group, gvr.Resource, namespace, name, deleteOption) {
 		return myOwner
 	}
 
-	removalKey := fmt.Sprintf("%s-", myOwner.UID)
 	ownerCopy := myOwner.DeepCopy()
-
-	switch deleteOption.PropagationPolicy {
-	case workapiv1.DeletePropagationPolicyTypeForeground:
-		return myOwner
-	case workapiv1.DeletePropagationPolicyTypeOrphan:
-		ownerCopy.UID = types.UID(removalKey)
-		return *ownerCopy
-	}
-
-	// If there is none specified selectivelyOrphan, none of the manifests should be orphaned
-	if deleteOption.SelectivelyOrphan == nil {
-		return myOwner
-	}
-
-	for _, o := range deleteOption.SelectivelyOrphan.OrphaningRules {
-		if o.Group != gvr.Group {
-			continue
-		}
-
-		if o.Resource != gvr.Resource {
-			continue
-		}
-
-		if o.Name != name {
-			continue
-		}
-
-		if o.Namespace != namespace {
-			continue
-		}
-
-		ownerCopy.UID = types.UID(removalKey)
-		return *ownerCopy
-	}
-
-	return myOwner
+	ownerCopy.UID = types.UID(fmt.Sprintf("%s-", myOwner.UID))
+	return *ownerCopy
 }
 
-// generateUpdateStatusFunc returns a function which aggregates manifest conditions and generates work conditions.
-// Rules to generate work status conditions from manifest conditions
-// #1: Applied - work status condition (with type Applied) is applied if all manifest conditions (with type Applied) are applied
+// generateUpdateStatusFunc returns a function which merges the manifest conditions and the work Applied condition
+// aggregated from the apply errors of the manifests into the work status. The retry stats are recorded in the
+// message of the Applied condition once any manifest failed to apply.
 // TODO: add rules for other condition types, like Progressing, Available, Degraded
-func (m *ManifestWorkController) generateUpdateStatusFunc(generation int64, newManifestConditions []workapiv1.ManifestCondition) helper.UpdateManifestWorkStatusFunc {
+func (m *ManifestWorkController) generateUpdateStatusFunc(
+	newManifestConditions []workapiv1.ManifestCondition, appliedCondition *metav1.Condition, stats applyStats) helper.UpdateManifestWorkStatusFunc {
 	return func(oldStatus *workapiv1.ManifestWorkStatus) error {
 		// merge the new manifest conditions with the existing manifest conditions
 		oldStatus.ResourceStatus.Manifests = helper.MergeManifestConditions(oldStatus.ResourceStatus.Manifests, newManifestConditions)
 
-		// aggregate manifest condition to generate work condition
-		newConditions := []metav1.Condition{}
-
 		// handle condition type Applied
-		if inCondition, exists := allInCondition(string(workapiv1.ManifestApplied), newManifestConditions); exists {
-			appliedCondition := metav1.Condition{
-				Type:               workapiv1.WorkApplied,
-				ObservedGeneration: generation,
-			}
-			if inCondition {
-				appliedCondition.Status = metav1.ConditionTrue
-				appliedCondition.Reason = "AppliedManifestWorkComplete"
-				appliedCondition.Message = "Apply manifest work complete"
-			} else {
-				appliedCondition.Status = metav1.ConditionFalse
-				appliedCondition.Reason = "AppliedManifestWorkFailed"
-				appliedCondition.Message = "Failed to apply manifest work"
+		newConditions := []metav1.Condition{}
+		if appliedCondition != nil {
+			condition := *appliedCondition
+			if condition.Status == metav1.ConditionFalse && stats.retries != nil {
+				condition.Message = retryConditionMessage(condition.Message, *stats.retries,
+					meta.FindStatusCondition(oldStatus.Conditions, workapiv1.WorkApplied), condition.ObservedGeneration)
 			}
-			newConditions = append(newConditions, appliedCondition)
+			newConditions = append(newConditions, condition)
 		}
 
 		oldStatus.Conditions = helper.MergeStatusConditions(oldStatus.Conditions, newConditions)
+		// the manifestwork is resumed once it is applied again
+		meta.RemoveStatusCondition(&oldStatus.Conditions, constants.WorkPaused)
+		meta.RemoveStatusCondition(&oldStatus.Conditions, constants.WorkTooManyManifests)
+		meta.RemoveStatusCondition(&oldStatus.Conditions, constants.WorkProgressing)
 		return nil
 	}
 }
 
-// isDecodeError is to check if the error returned from resourceapply is due to that the object cannot
-// be decoded or no typed client can handle the object.
-func isDecodeError(err error) bool {
-	return err != nil && strings.HasPrefix(err.Error(), "cannot decode")
-}
-
-// isUnhandledError is to check if the error returned from resourceapply is due to that no typed
-// client can handle the object
-func isUnhandledError(err error) bool {
-	return err != nil && strings.HasPrefix(err.Error(), "unhandled type")
-}
-
-// isUnsupportedError is to check if the error returned from resourceapply is due to
-// the PR https://github.com/openshift/library-go/pull/1042
-func isUnsupportedError(err error) bool {
-	return err != nil && strings.HasPrefix(err.Error(), "unsupported object type")
-}
-
-// isSameUnstructured compares the two unstructured object.
-// The comparison ignores the metadata and status field, and check if the two objects are semantically equal.
-func isSameUnstructured(obj1, obj2 *unstructured.Unstructured) bool {
-	obj1Copy := obj1.DeepCopy()
-	obj2Copy := obj2.DeepCopy()
-
-	// Compare gvk, name, namespace at first
-	if obj1Copy.GroupVersionKind() != obj2Copy.GroupVersionKind() {
-		return false
-	}
-	if obj1Copy.GetName() != obj2Copy.GetName() {
-		return false
-	}
-	if obj1Copy.GetNamespace() != obj2Copy.GetNamespace() {
-		return false
-	}
-
-	// Compare label and annotations
-	if !equality.Semantic.DeepEqual(obj1Copy.GetLabels(), obj2Copy.GetLabels()) {
-		return false
+// registerAPIVersionInterest records the API versions not served by the spoke yet which the manifestwork is
+// waiting on, it does nothing if the CRDs on the spoke are not watched.
+func (m *ManifestWorkController) registerAPIVersionInterest(manifestWorkName string, groupVersions []schema.GroupVersion) {
+	if m.apiVersionInterest == nil {
+		return
 	}
-	if !equality.Semantic.DeepEqual(obj1Copy.GetAnnotations(), obj2Copy.GetAnnotations()) {
-		return false
-	}
-	if !equality.Semantic.DeepEqual(obj1Copy.GetOwnerReferences(), obj2Copy.GetOwnerReferences()) {
-		return false
-	}
-
-	// Compare semantically after removing metadata and status field
-	delete(obj1Copy.Object, "metadata")
-	delete(obj2Copy.Object, "metadata")
-	delete(obj1Copy.Object, "status")
-	delete(obj2Copy.Object, "status")
-
-	return equality.Semantic.DeepEqual(obj1Copy.Object, obj2Copy.Object)
+	m.apiVersionInterest.register(manifestWorkName, groupVersions)
 }
 
-// allInCondition checks status of conditions with a particular type in ManifestCondition array.
-// Return true only if conditions with the condition type exist and they are all in condition.
-func allInCondition(conditionType string, manifests []workapiv1.ManifestCondition) (inCondition bool, exists bool) {
-	for _, manifest := range manifests {
-		for _, condition := range manifest.Conditions {
-			if condition.Type == conditionType {
-				exists = true
-			}
+// recordDriftBaselines records the baselines of the resources applied successfully, so that the modifications
+// of the resources out of band can be detected. It does nothing if the drift is not tracked.
+func (m *ManifestWorkController) recordDriftBaselines(manifestWorkName string, results []applyResult) {
+	if m.driftTracker == nil {
+		return
+	}
 
-			if condition.Type == conditionType && condition.Status == metav1.ConditionFalse {
-				return false, true
+	baselines := []helper.DriftBaseline{}
+	for _, result := range results {
+		if result.skipped || isManifestDecodeFailedError(result.Error) {
+			// the baseline recorded once the manifest was applied is kept
+			if baseline, ok := m.driftTracker.Baseline(manifestWorkName, result.resourceMeta); ok {
+				baselines = append(baselines, baseline)
 			}
+			continue
 		}
+		if result.Error != nil || result.required == nil || result.Result == nil || reflect.ValueOf(result.Result).IsNil() {
+			continue
+		}
+		baseline, err := helper.NewDriftBaseline(result.resourceMeta, result.required, result.Result)
+		if err != nil {
+			klog.Warningf("Failed to record the drift baseline of manifest %d of work %s: %v",
+				result.resourceMeta.Ordinal, manifestWorkName, err)
+			continue
+		}
+		baselines = append(baselines, baseline)
 	}
+	m.driftTracker.SetBaselines(manifestWorkName, baselines)
+}
 
-	return exists, exists
+// resourceMessage returns the message describing the resource of the manifest, it is empty if the resource is
+// unknown, e.g. the manifest cannot be decoded.
+func resourceMessage(resourceMeta workapiv1.ManifestResourceMeta) string {
+	switch {
+	case len(resourceMeta.Kind) == 0 || len(resourceMeta.Name) == 0:
+		return ""
+	case len(resourceMeta.Namespace) == 0:
+		return fmt.Sprintf(" %s %s", resourceMeta.Kind, resourceMeta.Name)
+	default:
+		return fmt.Sprintf(" %s %s/%s", resourceMeta.Kind, resourceMeta.Namespace, resourceMeta.Name)
+	}
 }
 
 func buildAppliedStatusCondition(result applyResult) metav1.Condition {
-	if result.Error != nil {
-		return metav1.Condition{
-			Type:    string(workapiv1.ManifestApplied),
-			Status:  metav1.ConditionFalse,
-			Reason:  "AppliedManifestFailed",
-			Message: fmt.Sprintf("Failed to apply manifest: %v", result.Error),
-		}
+	if isAppliedResourceCapExceededError(result.Error) {
+		return applier.FailedAppliedCondition(constants.WorkResourceQuotaExceededByAgentPolicy, result.Error, sourceMessage(result.source))
 	}
-
-	return metav1.Condition{
-		Type:    string(workapiv1.ManifestApplied),
-		Status:  metav1.ConditionTrue,
-		Reason:  "AppliedManifestComplete",
-		Message: "Apply manifest complete",
+	if isDependencyWaitingError(result.Error) {
+		return applier.FailedAppliedCondition(waitingForDependencyReason, result.Error, sourceMessage(result.source))
 	}
+	return applier.AppliedCondition(result.Error, sourceMessage(result.source))
 }
 
-// buildManifestResourceMeta returns resource meta for manifest. It tries to get the resource
-// meta from the result object in ApplyResult struct. If the resource meta is incompleted, fall
-// back to manifest template for the meta info.
-func buildManifestResourceMeta(
-	index int,
-	manifest workapiv1.Manifest,
-	restMapper meta.RESTMapper) (resourceMeta workapiv1.ManifestResourceMeta, gvr schema.GroupVersionResource, err error) {
-	errs := []error{}
-
-	var object runtime.Object
-
-	// try to get resource meta from manifest if the one got from apply result is incompleted
-	switch {
-	case manifest.Object != nil:
-		object = manifest.Object
-	default:
-		unstructuredObj := &unstructured.Unstructured{}
-		if err = unstructuredObj.UnmarshalJSON(manifest.Raw); err != nil {
-			errs = append(errs, err)
-			return resourceMeta, gvr, utilerrors.NewAggregate(errs)
-		}
-		object = unstructuredObj
-	}
-	resourceMeta, gvr, err = buildResourceMeta(index, object, restMapper)
-	if err == nil {
-		return resourceMeta, gvr, nil
+// setPausedCondition sets the Paused condition of the manifestwork to true.
+func (m *ManifestWorkController) setPausedCondition(ctx context.Context, manifestWork *workapiv1.ManifestWork) error {
+	if meta.IsStatusConditionTrue(manifestWork.Status.Conditions, constants.WorkPaused) {
+		return nil
 	}
 
-	return resourceMeta, gvr, utilerrors.NewAggregate(errs)
+	return m.updateStatus(ctx, manifestWork, func(status *workapiv1.ManifestWorkStatus) error {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               constants.WorkPaused,
+			Status:             metav1.ConditionTrue,
+			Reason:             "ManifestWorkPaused",
+			ObservedGeneration: manifestWork.Generation,
+			Message:            fmt.Sprintf("The reconciliation is paused by the annotation %s", constants.PausedAnnotationKey),
+		})
+		return nil
+	})
 }
 
-func buildResourceMeta(
-	index int,
-	object runtime.Object,
-	restMapper meta.RESTMapper) (workapiv1.ManifestResourceMeta, schema.GroupVersionResource, error) {
-	resourceMeta := workapiv1.ManifestResourceMeta{
-		Ordinal: int32(index),
+// updateStatus enqueues the status updates of the manifestwork to the status writer, or writes them to the hub if
+// there is no status writer.
+func (m *ManifestWorkController) updateStatus(
+	ctx context.Context, manifestWork *workapiv1.ManifestWork, updateFuncs ...helper.UpdateManifestWorkStatusFunc) error {
+	if m.statusWriter != nil {
+		m.statusWriter.Enqueue(manifestWork.Name, updateFuncs...)
+		return nil
 	}
+	_, _, err := helper.UpdateManifestWorkStatus(ctx, m.manifestWorkClient, manifestWork, updateFuncs...)
+	return err
+}
 
-	if object == nil || reflect.ValueOf(object).IsNil() {
-		return resourceMeta, schema.GroupVersionResource{}, nil
+// recordAppliedSpecHash records the spec hash of the manifestwork on the appliedmanifestwork once all the manifests
+// are applied. It is not recorded if any manifest is a reference, since the referenced content is not in the spec.
+func (m *ManifestWorkController) recordAppliedSpecHash(
+	ctx context.Context,
+	manifestWork *workapiv1.ManifestWork,
+	appliedManifestWork *workapiv1.AppliedManifestWork,
+	results []applyResult) error {
+	for _, result := range results {
+		if result.source != nil {
+			return nil
+		}
 	}
 
-	// set gvk
-	gvk, err := helper.GuessObjectGroupVersionKind(object)
+	hash, err := appliedSpecHash(manifestWork)
 	if err != nil {
-		return resourceMeta, schema.GroupVersionResource{}, err
+		return err
 	}
-	resourceMeta.Group = gvk.Group
-	resourceMeta.Version = gvk.Version
-	resourceMeta.Kind = gvk.Kind
-
-	// set namespace/name
-	if accessor, e := meta.Accessor(object); e != nil {
-		err = fmt.Errorf("cannot access metadata of %v: %w", object, e)
-	} else {
-		resourceMeta.Namespace = accessor.GetNamespace()
-		resourceMeta.Name = accessor.GetName()
+	if appliedManifestWork.Annotations[constants.AppliedSpecHashAnnotationKey] == hash {
+		return nil
 	}
 
-	// set resource
-	if restMapper == nil {
-		return resourceMeta, schema.GroupVersionResource{}, err
-	}
-	mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{constants.AppliedSpecHashAnnotationKey: hash},
+		},
+	})
 	if err != nil {
-		return resourceMeta, schema.GroupVersionResource{}, fmt.Errorf("the server doesn't have a resource type %q", gvk.Kind)
+		return err
 	}
-
-	resourceMeta.Resource = mapping.Resource.Resource
-	return resourceMeta, mapping.Resource, err
+	_, err = m.appliedManifestWorkClient.Patch(ctx, appliedManifestWork.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
 }
@@ -2,11 +2,12 @@ package manifestcontroller
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/openshift/library-go/pkg/operator/events"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -15,13 +16,15 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/diff"
+	"k8s.io/apimachinery/pkg/util/wait"
 	fakedynamic "k8s.io/client-go/dynamic/fake"
 	fakekube "k8s.io/client-go/kubernetes/fake"
 	clienttesting "k8s.io/client-go/testing"
 	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
 	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
 	workapiv1 "open-cluster-management.io/api/work/v1"
-	"open-cluster-management.io/work/pkg/spoke/controllers"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
 	"open-cluster-management.io/work/pkg/spoke/spoketesting"
 )
 
@@ -35,13 +38,16 @@ type testController struct {
 func newController(work *workapiv1.ManifestWork, appliedWork *workapiv1.AppliedManifestWork, mapper meta.RESTMapper) *testController {
 	fakeWorkClient := fakeworkclient.NewSimpleClientset(work)
 	workInformerFactory := workinformers.NewSharedInformerFactoryWithOptions(fakeWorkClient, 5*time.Minute, workinformers.WithNamespace("cluster1"))
+	appliedManifestWorkInformer := workInformerFactory.Work().V1().AppliedManifestWorks().Informer()
+	// the indexers are always registered with a new informer
+	_ = helper.AddAppliedManifestWorkIndexers(appliedManifestWorkInformer)
 
 	controller := &ManifestWorkController{
-		manifestWorkClient:        fakeWorkClient.WorkV1().ManifestWorks("cluster1"),
-		manifestWorkLister:        workInformerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks("cluster1"),
-		appliedManifestWorkClient: fakeWorkClient.WorkV1().AppliedManifestWorks(),
-		appliedManifestWorkLister: workInformerFactory.Work().V1().AppliedManifestWorks().Lister(),
-		restMapper:                mapper,
+		manifestWorkClient:         fakeWorkClient.WorkV1().ManifestWorks("cluster1"),
+		manifestWorkLister:         workInformerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks("cluster1"),
+		appliedManifestWorkClient:  fakeWorkClient.WorkV1().AppliedManifestWorks(),
+		appliedManifestWorkIndexer: appliedManifestWorkInformer.GetIndexer(),
+		restMapper:                 mapper,
 	}
 
 	workInformerFactory.Work().V1().ManifestWorks().Informer().GetStore().Add(work)
@@ -78,6 +84,20 @@ func assertCondition(t *testing.T, conditions []metav1.Condition, expectedCondit
 	}
 }
 
+// lastUpdatedWork returns the manifestwork of the last update action, the apply stats of the manifestwork are
+// patched after its status is updated.
+func lastUpdatedWork(t *testing.T, actions []clienttesting.Action) *workapiv1.ManifestWork {
+	for i := len(actions) - 1; i >= 0; i-- {
+		if update, ok := actions[i].(clienttesting.UpdateAction); ok {
+			if work, ok := update.GetObject().(*workapiv1.ManifestWork); ok {
+				return work
+			}
+		}
+	}
+	t.Fatalf("expected the manifestwork updated, but got %v", actions)
+	return nil
+}
+
 func assertManifestCondition(
 	t *testing.T, conds []workapiv1.ManifestCondition, index int32, expectedCondition string, expectedStatus metav1.ConditionStatus) {
 	cond := findManifestConditionByIndex(index, conds)
@@ -209,11 +229,7 @@ func (t *testCase) validate(
 		spoketesting.AssertAction(ts, spokeKubeActions[index], t.expectedKubeAction[index])
 	}
 
-	actual, ok := actualWorkActions[len(actualWorkActions)-1].(clienttesting.UpdateActionImpl)
-	if !ok {
-		ts.Errorf("Expected to get update action")
-	}
-	actualWork := actual.Object.(*workapiv1.ManifestWork)
+	actualWork := lastUpdatedWork(ts, actualWorkActions)
 	for index, cond := range t.expectedManifestConditions {
 		assertManifestCondition(ts, actualWork.Status.ResourceStatus.Manifests, int32(index), cond.conditionType, cond.status)
 	}
@@ -262,14 +278,14 @@ func TestSync(t *testing.T) {
 	cases := []*testCase{
 		newTestCase("create single resource").
 			withWorkManifest(spoketesting.NewUnstructured("v1", "Secret", "ns1", "test")).
-			withExpectedWorkAction("update").
+			withExpectedWorkAction("update", "patch").
 			withAppliedWorkAction("create").
 			withExpectedKubeAction("get", "create").
 			withExpectedManifestCondition(expectedCondition{string(workapiv1.ManifestApplied), metav1.ConditionTrue}).
 			withExpectedWorkCondition(expectedCondition{string(workapiv1.WorkApplied), metav1.ConditionTrue}),
 		newTestCase("create single deployment resource").
 			withWorkManifest(spoketesting.NewUnstructured("apps/v1", "Deployment", "ns1", "test")).
-			withExpectedWorkAction("update").
+			withExpectedWorkAction("update", "patch").
 			withAppliedWorkAction("create").
 			withExpectedDynamicAction("get", "create").
 			withExpectedManifestCondition(expectedCondition{string(workapiv1.ManifestApplied), metav1.ConditionTrue}).
@@ -277,14 +293,14 @@ func TestSync(t *testing.T) {
 		newTestCase("update single resource").
 			withWorkManifest(spoketesting.NewUnstructured("v1", "Secret", "ns1", "test")).
 			withSpokeObject(spoketesting.NewSecret("test", "ns1", "value2")).
-			withExpectedWorkAction("update").
+			withExpectedWorkAction("update", "patch").
 			withAppliedWorkAction("create").
 			withExpectedKubeAction("get", "delete", "create").
 			withExpectedManifestCondition(expectedCondition{string(workapiv1.ManifestApplied), metav1.ConditionTrue}).
 			withExpectedWorkCondition(expectedCondition{string(workapiv1.WorkApplied), metav1.ConditionTrue}),
 		newTestCase("create single unstructured resource").
 			withWorkManifest(spoketesting.NewUnstructured("v1", "NewObject", "ns1", "test")).
-			withExpectedWorkAction("update").
+			withExpectedWorkAction("update", "patch").
 			withAppliedWorkAction("create").
 			withExpectedDynamicAction("get", "create").
 			withExpectedManifestCondition(expectedCondition{string(workapiv1.ManifestApplied), metav1.ConditionTrue}).
@@ -292,7 +308,7 @@ func TestSync(t *testing.T) {
 		newTestCase("update single unstructured resource").
 			withWorkManifest(spoketesting.NewUnstructuredWithContent("v1", "NewObject", "ns1", "n1", map[string]interface{}{"spec": map[string]interface{}{"key1": "val1"}})).
 			withSpokeDynamicObject(spoketesting.NewUnstructuredWithContent("v1", "NewObject", "ns1", "n1", map[string]interface{}{"spec": map[string]interface{}{"key1": "val2"}})).
-			withExpectedWorkAction("update").
+			withExpectedWorkAction("update", "patch").
 			withAppliedWorkAction("create").
 			withExpectedDynamicAction("get", "update").
 			withExpectedManifestCondition(expectedCondition{string(workapiv1.ManifestApplied), metav1.ConditionTrue}).
@@ -300,7 +316,7 @@ func TestSync(t *testing.T) {
 		newTestCase("multiple create&update resource").
 			withWorkManifest(spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"), spoketesting.NewUnstructured("v1", "Secret", "ns2", "test")).
 			withSpokeObject(spoketesting.NewSecret("test", "ns1", "value2")).
-			withExpectedWorkAction("update").
+			withExpectedWorkAction("update", "patch").
 			withAppliedWorkAction("create").
 			withExpectedKubeAction("get", "delete", "create", "get", "create").
 			withExpectedManifestCondition(expectedCondition{string(workapiv1.ManifestApplied), metav1.ConditionTrue}, expectedCondition{string(workapiv1.ManifestApplied), metav1.ConditionTrue}).
@@ -310,7 +326,7 @@ func TestSync(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			work, workKey := spoketesting.NewManifestWork(0, c.workManifest...)
-			work.Finalizers = []string{controllers.ManifestWorkFinalizer}
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
 			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).
 				withKubeObject(c.spokeObject...).
 				withUnstructuredObject(c.spokeDynamicObject...)
@@ -337,7 +353,7 @@ func TestFailedToApplyResource(t *testing.T) {
 		withExpectedWorkCondition(expectedCondition{string(workapiv1.WorkApplied), metav1.ConditionFalse})
 
 	work, workKey := spoketesting.NewManifestWork(0, tc.workManifest...)
-	work.Finalizers = []string{controllers.ManifestWorkFinalizer}
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
 	controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject(tc.spokeObject...).withUnstructuredObject()
 
 	// Add a reactor on fake client to throw error when creating secret on namespace ns2
@@ -363,56 +379,248 @@ func TestFailedToApplyResource(t *testing.T) {
 	tc.validate(t, controller.dynamicClient, controller.workClient, controller.kubeClient)
 }
 
-// Test unstructured compare
-func TestIsSameUnstructured(t *testing.T) {
+func TestSyncEvents(t *testing.T) {
+	work, workKey := spoketesting.NewManifestWork(0,
+		spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"), spoketesting.NewUnstructured("v1", "Secret", "ns2", "test"))
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+	controller.controller.hubHash = "hub1"
+	controller.kubeClient.PrependReactor("create", "secrets", func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+		if action.(clienttesting.CreateActionImpl).Object.(*corev1.Secret).Namespace == "ns1" {
+			return false, nil, nil
+		}
+		return true, nil, fmt.Errorf("Fake error")
+	})
+
+	recorder := events.NewInMemoryRecorder("work-agent")
+	syncContext := spoketesting.NewFakeSyncContext(t, workKey).WithRecorder(recorder)
+	if err := controller.controller.sync(context.TODO(), syncContext); err == nil {
+		t.Errorf("Should return an err")
+	}
+
+	reasons := map[string]string{}
+	for _, event := range recorder.Events() {
+		if event.Source.Component != "work-agent-hub1-"+workKey {
+			t.Errorf("expected event attributed to the work, but got %v", event)
+		}
+		reasons[event.Reason] = event.Message
+	}
+	if message := reasons[constants.EventReasonResourceApplied]; message != "Applied manifest 0 Secret ns1/test" {
+		t.Errorf("unexpected event %s: %q", constants.EventReasonResourceApplied, message)
+	}
+	if message := reasons[constants.EventReasonResourceAppliedFailed]; !strings.HasPrefix(message, "Failed to apply manifest 1 Secret ns2/test") {
+		t.Errorf("unexpected event %s: %q", constants.EventReasonResourceAppliedFailed, message)
+	}
+}
+
+// Test manifests of a completed work are not applied
+func TestSyncCompletedWork(t *testing.T) {
+	work, workKey := spoketesting.NewManifestWork(0, spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"))
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	work.Status.Conditions = []metav1.Condition{
+		{Type: constants.WorkCompleted, Status: metav1.ConditionTrue, Reason: "CompletionRulesMet"},
+	}
+	controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+
+	syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+	if err := controller.controller.sync(nil, syncContext); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(controller.workClient.Actions()) != 0 || len(controller.kubeClient.Actions()) != 0 || len(controller.dynamicClient.Actions()) != 0 {
+		t.Errorf("expected no action, but got %v, %v, %v",
+			controller.workClient.Actions(), controller.kubeClient.Actions(), controller.dynamicClient.Actions())
+	}
+}
+
+func TestSyncDefaultDeletePropagationPolicy(t *testing.T) {
 	cases := []struct {
-		name     string
-		obj1     *unstructured.Unstructured
-		obj2     *unstructured.Unstructured
-		expected bool
+		name           string
+		deleteOption   *workapiv1.DeleteOption
+		defaultPolicy  workapiv1.DeletePropagationPolicyType
+		expectedOwners int
+		expectedPolicy string
 	}{
 		{
-			name:     "different kind",
-			obj1:     spoketesting.NewUnstructured("v1", "Kind1", "ns1", "n1"),
-			obj2:     spoketesting.NewUnstructured("v1", "Kind2", "ns1", "n1"),
-			expected: false,
+			name:           "foreground by default",
+			defaultPolicy:  workapiv1.DeletePropagationPolicyTypeForeground,
+			expectedOwners: 1,
+			expectedPolicy: "Foreground",
 		},
 		{
-			name:     "different namespace",
-			obj1:     spoketesting.NewUnstructured("v1", "Kind1", "ns1", "n1"),
-			obj2:     spoketesting.NewUnstructured("v1", "Kind1", "ns2", "n1"),
-			expected: false,
+			name:           "orphan by default",
+			defaultPolicy:  workapiv1.DeletePropagationPolicyTypeOrphan,
+			expectedOwners: 0,
+			expectedPolicy: "Orphan",
 		},
 		{
-			name:     "different name",
-			obj1:     spoketesting.NewUnstructured("v1", "Kind1", "ns1", "n1"),
-			obj2:     spoketesting.NewUnstructured("v1", "Kind1", "ns1", "n2"),
-			expected: false,
+			name:           "explicit foreground over orphan by default",
+			deleteOption:   &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeForeground},
+			defaultPolicy:  workapiv1.DeletePropagationPolicyTypeOrphan,
+			expectedOwners: 1,
+		},
+		{
+			name:           "explicit orphan over foreground by default",
+			deleteOption:   &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan},
+			defaultPolicy:  workapiv1.DeletePropagationPolicyTypeForeground,
+			expectedOwners: 0,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0, spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"))
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			work.Spec.DeleteOption = c.deleteOption
+			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+			controller.controller.defaultDeletePropagationPolicy = c.defaultPolicy
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+				t.Fatal(err)
+			}
+
+			// the default policy is recorded on the appliedmanifestwork
+			appliedWork, err := controller.workClient.WorkV1().AppliedManifestWorks().Get(
+				context.TODO(), fmt.Sprintf("%s-%s", controller.controller.hubHash, work.Name), metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if policy := appliedWork.Annotations[constants.DefaultDeletePropagationPolicyAnnotationKey]; policy != string(c.defaultPolicy) {
+				t.Errorf("expected the default policy %q recorded, but got %q", c.defaultPolicy, policy)
+			}
+
+			secret, err := controller.kubeClient.CoreV1().Secrets("ns1").Get(context.TODO(), "test", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(secret.OwnerReferences) != c.expectedOwners {
+				t.Errorf("expected %d owners, but got %v", c.expectedOwners, secret.OwnerReferences)
+			}
+
+			// the default policy is reported only if it is effective
+			updated, err := controller.workClient.WorkV1().ManifestWorks(work.Namespace).Get(context.TODO(), work.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			applied := meta.FindStatusCondition(updated.Status.Conditions, workapiv1.WorkApplied)
+			if applied == nil {
+				t.Fatalf("expected the work applied, but got %v", updated.Status.Conditions)
+			}
+			reported := strings.Contains(applied.Message, "delete propagation policy")
+			if reported != (len(c.expectedPolicy) > 0) || !strings.Contains(applied.Message, c.expectedPolicy) {
+				t.Errorf("expected the default policy %q reported, but got %q", c.expectedPolicy, applied.Message)
+			}
+		})
+	}
+}
+
+func TestSyncPausedWork(t *testing.T) {
+	cases := []struct {
+		name                    string
+		annotations             map[string]string
+		conditions              []metav1.Condition
+		expectedApplied         bool
+		expectedPausedCondition bool
+	}{
+		{
+			name:                    "paused",
+			annotations:             map[string]string{constants.PausedAnnotationKey: constants.PausedAnnotationValue},
+			expectedPausedCondition: true,
 		},
 		{
-			name:     "different spec",
-			obj1:     spoketesting.NewUnstructuredWithContent("v1", "Kind1", "ns1", "n1", map[string]interface{}{"spec": map[string]interface{}{"key1": "val1"}}),
-			obj2:     spoketesting.NewUnstructuredWithContent("v1", "Kind1", "ns1", "n1", map[string]interface{}{"spec": map[string]interface{}{"key1": "val2"}}),
-			expected: false,
+			name:                    "deletion paused",
+			annotations:             map[string]string{constants.PausedAnnotationKey: constants.PausedDeletionAnnotationValue},
+			expectedPausedCondition: true,
 		},
 		{
-			name:     "same spec, different status",
-			obj1:     spoketesting.NewUnstructuredWithContent("v1", "Kind1", "ns1", "n1", map[string]interface{}{"spec": map[string]interface{}{"key1": "val1"}, "status": "status1"}),
-			obj2:     spoketesting.NewUnstructuredWithContent("v1", "Kind1", "ns1", "n1", map[string]interface{}{"spec": map[string]interface{}{"key1": "val1"}, "status": "status2"}),
-			expected: true,
+			name:            "resumed",
+			conditions:      []metav1.Condition{{Type: constants.WorkPaused, Status: metav1.ConditionTrue, Reason: "ManifestWorkPaused"}},
+			expectedApplied: true,
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			actual := isSameUnstructured(c.obj1, c.obj2)
-			if c.expected != actual {
-				t.Errorf("expected %t, but %t", c.expected, actual)
+			work, workKey := spoketesting.NewManifestWork(0, spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"))
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			work.Annotations = c.annotations
+			work.Status.Conditions = c.conditions
+			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+				t.Fatal(err)
+			}
+
+			if applied := len(controller.kubeClient.Actions()) != 0; applied != c.expectedApplied {
+				t.Errorf("expected applied %t, but got actions %v", c.expectedApplied, controller.kubeClient.Actions())
+			}
+
+			workActions := controller.workClient.Actions()
+			updatedWork := lastUpdatedWork(t, workActions)
+			if paused := meta.IsStatusConditionTrue(updatedWork.Status.Conditions, constants.WorkPaused); paused != c.expectedPausedCondition {
+				t.Errorf("expected Paused condition %t, but got %v", c.expectedPausedCondition, updatedWork.Status.Conditions)
 			}
 		})
 	}
 }
 
+// Test the reconcile is not blocked by a slow hub once the status is written by the status writer
+func TestSyncWithStatusWriter(t *testing.T) {
+	work, workKey := spoketesting.NewManifestWork(0, spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"))
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+
+	// the status writes to the hub are blocked until the hub is released
+	release := make(chan struct{})
+	hubWorkClient := fakeworkclient.NewSimpleClientset(work)
+	hubWorkClient.PrependReactor("update", "manifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() == "status" {
+			<-release
+		}
+		return false, nil, nil
+	})
+	hubClient := hubWorkClient.WorkV1().ManifestWorks(work.Namespace)
+	statusWriter := helper.NewStatusWriter(hubClient, controller.controller.manifestWorkLister.Get)
+	controller.controller.statusWriter = statusWriter
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go statusWriter.Run(ctx, 1)
+
+	synced := make(chan error)
+	go func() {
+		synced <- controller.controller.sync(ctx, spoketesting.NewFakeSyncContext(t, workKey))
+	}()
+	select {
+	case err := <-synced:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(wait.ForeverTestTimeout):
+		close(release)
+		t.Fatalf("expected the reconcile finished while the hub is blocked")
+	}
+	for _, action := range controller.workClient.Actions() {
+		if action.GetResource().Resource == "manifestworks" {
+			t.Errorf("expected no status written in the reconcile, but got %v", action)
+		}
+	}
+
+	// the status is written once the hub is released
+	close(release)
+	if err := wait.PollImmediate(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+		updatedWork, err := hubClient.Get(context.TODO(), work.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return meta.IsStatusConditionTrue(updatedWork.Status.Conditions, workapiv1.WorkApplied), nil
+	}); err != nil {
+		t.Errorf("expected the Applied condition written to the hub: %v", err)
+	}
+}
+
 func TestGenerateUpdateStatusFunc(t *testing.T) {
 	transitionTime := metav1.Now()
 
@@ -445,7 +653,8 @@ func TestGenerateUpdateStatusFunc(t *testing.T) {
 				newManifestCondition(1, "resource1", newCondition(string(workapiv1.ManifestApplied), string(metav1.ConditionFalse), "my-reason", "my-message", 0, nil)),
 			},
 			expectedStatusConditions: []metav1.Condition{
-				newCondition(string(workapiv1.WorkApplied), string(metav1.ConditionFalse), "AppliedManifestWorkFailed", "Failed to apply manifest work", 0, nil),
+				newCondition(string(workapiv1.WorkApplied), string(metav1.ConditionFalse), "AppliedManifestWorkFailed",
+					"Failed to apply manifest work: 1 of 2 manifests failed (1 retryable, 0 terminal, 0 not allowed)", 0, nil),
 			},
 		},
 		{
@@ -473,7 +682,8 @@ func TestGenerateUpdateStatusFunc(t *testing.T) {
 			},
 			generation: 1,
 			expectedStatusConditions: []metav1.Condition{
-				newCondition(string(workapiv1.WorkApplied), string(metav1.ConditionFalse), "AppliedManifestWorkFailed", "Failed to apply manifest work", 1, nil),
+				newCondition(string(workapiv1.WorkApplied), string(metav1.ConditionFalse), "AppliedManifestWorkFailed",
+					"Failed to apply manifest work: 1 of 2 manifests failed (1 retryable, 0 terminal, 0 not allowed)", 1, nil),
 			},
 		},
 	}
@@ -481,7 +691,17 @@ func TestGenerateUpdateStatusFunc(t *testing.T) {
 	controller := &ManifestWorkController{}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			updateStatusFunc := controller.generateUpdateStatusFunc(c.generation, c.manifestConditions)
+			// the manifests which are not applied are failed with retryable errors
+			manifestErrors := []error{}
+			for _, manifestCondition := range c.manifestConditions {
+				var err error
+				if meta.IsStatusConditionFalse(manifestCondition.Conditions, string(workapiv1.ManifestApplied)) {
+					err = fmt.Errorf("my-message")
+				}
+				manifestErrors = append(manifestErrors, err)
+			}
+			appliedCondition, _ := helper.AggregateManifestErrors(c.generation, manifestErrors)
+			updateStatusFunc := controller.generateUpdateStatusFunc(c.manifestConditions, appliedCondition, applyStats{})
 			manifestWorkStatus := &workapiv1.ManifestWorkStatus{
 				Conditions: c.startingStatusConditions,
 			}
@@ -504,159 +724,6 @@ func TestGenerateUpdateStatusFunc(t *testing.T) {
 	}
 }
 
-func TestAllInCondition(t *testing.T) {
-	cases := []struct {
-		name               string
-		conditionType      string
-		manifestConditions []workapiv1.ManifestCondition
-		expected           []bool
-	}{
-		{
-			name:          "condition does not exist",
-			conditionType: "one",
-			manifestConditions: []workapiv1.ManifestCondition{
-				newManifestCondition(0, "resource0", newCondition("two", string(metav1.ConditionTrue), "my-reason", "my-message", 0, nil)),
-				newManifestCondition(1, "resource1", newCondition("two", string(metav1.ConditionFalse), "my-reason", "my-message", 0, nil)),
-			},
-			expected: []bool{false, false},
-		},
-		{
-			name:          "all manifests are in the condition",
-			conditionType: "one",
-			manifestConditions: []workapiv1.ManifestCondition{
-				newManifestCondition(0, "resource0", newCondition("one", string(metav1.ConditionTrue), "my-reason", "my-message", 0, nil)),
-				newManifestCondition(1, "resource1", newCondition("one", string(metav1.ConditionTrue), "my-reason", "my-message", 0, nil)),
-				newManifestCondition(2, "resource0", newCondition("two", string(metav1.ConditionTrue), "my-reason", "my-message", 0, nil)),
-				newManifestCondition(3, "resource1", newCondition("two", string(metav1.ConditionFalse), "my-reason", "my-message", 0, nil)),
-			},
-			expected: []bool{true, true},
-		},
-		{
-			name:          "one of manifests is not in the condition",
-			conditionType: "two",
-			manifestConditions: []workapiv1.ManifestCondition{
-				newManifestCondition(0, "resource0", newCondition("one", string(metav1.ConditionTrue), "my-reason", "my-message", 0, nil)),
-				newManifestCondition(1, "resource1", newCondition("one", string(metav1.ConditionTrue), "my-reason", "my-message", 0, nil)),
-				newManifestCondition(2, "resource0", newCondition("two", string(metav1.ConditionTrue), "my-reason", "my-message", 0, nil)),
-				newManifestCondition(3, "resource1", newCondition("two", string(metav1.ConditionFalse), "my-reason", "my-message", 0, nil)),
-			},
-			expected: []bool{false, true},
-		},
-	}
-
-	for _, c := range cases {
-		t.Run(c.name, func(t *testing.T) {
-			inCondition, exists := allInCondition(c.conditionType, c.manifestConditions)
-			if c.expected[0] != inCondition {
-				t.Errorf("expected %t, but %t", c.expected[0], inCondition)
-			}
-
-			if c.expected[1] != exists {
-				t.Errorf("expected %t, but %t", c.expected[1], exists)
-			}
-		})
-	}
-}
-
-func TestBuildResourceMeta(t *testing.T) {
-	var secret *corev1.Secret
-	var u *unstructured.Unstructured
-
-	cases := []struct {
-		name       string
-		object     runtime.Object
-		restMapper meta.RESTMapper
-		expected   workapiv1.ManifestResourceMeta
-	}{
-		{
-			name:     "build meta for non-unstructured object",
-			object:   spoketesting.NewSecret("test", "ns1", "value2"),
-			expected: workapiv1.ManifestResourceMeta{Version: "v1", Kind: "Secret", Namespace: "ns1", Name: "test"},
-		},
-		{
-			name:       "build meta for non-unstructured object with rest mapper",
-			object:     spoketesting.NewSecret("test", "ns1", "value2"),
-			restMapper: spoketesting.NewFakeRestMapper(),
-			expected:   workapiv1.ManifestResourceMeta{Version: "v1", Kind: "Secret", Resource: "secrets", Namespace: "ns1", Name: "test"},
-		},
-		{
-			name:     "build meta for non-unstructured nil",
-			object:   secret,
-			expected: workapiv1.ManifestResourceMeta{},
-		},
-		{
-			name:     "build meta for unstructured object",
-			object:   spoketesting.NewUnstructured("v1", "Kind1", "ns1", "n1"),
-			expected: workapiv1.ManifestResourceMeta{Version: "v1", Kind: "Kind1", Namespace: "ns1", Name: "n1"},
-		},
-		{
-			name:       "build meta for unstructured object with rest mapper",
-			object:     spoketesting.NewUnstructured("v1", "NewObject", "ns1", "n1"),
-			restMapper: spoketesting.NewFakeRestMapper(),
-			expected:   workapiv1.ManifestResourceMeta{Version: "v1", Kind: "NewObject", Resource: "newobjects", Namespace: "ns1", Name: "n1"},
-		},
-		{
-			name:     "build meta for unstructured nil",
-			object:   u,
-			expected: workapiv1.ManifestResourceMeta{},
-		},
-		{
-			name:     "build meta with nil",
-			object:   nil,
-			expected: workapiv1.ManifestResourceMeta{},
-		},
-	}
-
-	for _, c := range cases {
-		t.Run(c.name, func(t *testing.T) {
-			actual, _, err := buildResourceMeta(0, c.object, c.restMapper)
-			if err != nil {
-				t.Errorf("Should be success with no err: %v", err)
-			}
-
-			actual.Ordinal = c.expected.Ordinal
-			if !equality.Semantic.DeepEqual(actual, c.expected) {
-				t.Errorf(diff.ObjectDiff(actual, c.expected))
-			}
-		})
-	}
-}
-
-func TestBuildManifestResourceMeta(t *testing.T) {
-	cases := []struct {
-		name           string
-		applyResult    runtime.Object
-		manifestObject runtime.Object
-		restMapper     meta.RESTMapper
-		expected       workapiv1.ManifestResourceMeta
-	}{
-		{
-			name:           "fall back to manifest",
-			manifestObject: spoketesting.NewSecret("test2", "ns2", "value2"),
-			restMapper:     spoketesting.NewFakeRestMapper(),
-			expected:       workapiv1.ManifestResourceMeta{Version: "v1", Kind: "Secret", Resource: "secrets", Namespace: "ns2", Name: "test2"},
-		},
-	}
-
-	for _, c := range cases {
-		t.Run(c.name, func(t *testing.T) {
-			manifest := workapiv1.Manifest{}
-			if c.manifestObject != nil {
-				manifest.Object = c.manifestObject
-			}
-			actual, _, err := buildManifestResourceMeta(0, manifest, c.restMapper)
-			if err != nil {
-				t.Errorf("Should be success with no err: %v", err)
-			}
-
-			actual.Ordinal = c.expected.Ordinal
-			if !equality.Semantic.DeepEqual(actual, c.expected) {
-				t.Errorf(diff.ObjectDiff(actual, c.expected))
-			}
-		})
-	}
-}
-
 func TestManageOwner(t *testing.T) {
 	testGVR := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
 
@@ -735,144 +802,3 @@ func TestManageOwner(t *testing.T) {
 		})
 	}
 }
-
-func TestApplyUnstructred(t *testing.T) {
-	cases := []struct {
-		name            string
-		owner           metav1.OwnerReference
-		existingObject  []runtime.Object
-		required        *unstructured.Unstructured
-		gvr             schema.GroupVersionResource
-		validateActions func(t *testing.T, actions []clienttesting.Action)
-	}{
-		{
-			name:           "create a new object with owner",
-			existingObject: []runtime.Object{},
-			owner:          metav1.OwnerReference{Name: "test", UID: "testowner"},
-			required:       spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
-			gvr:            schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
-			validateActions: func(t *testing.T, actions []clienttesting.Action) {
-				if len(actions) != 2 {
-					t.Errorf("Expect 2 actions, but have %d", len(actions))
-				}
-
-				spoketesting.AssertAction(t, actions[0], "get")
-				spoketesting.AssertAction(t, actions[1], "create")
-
-				obj := actions[1].(clienttesting.CreateActionImpl).Object.(*unstructured.Unstructured)
-				owners := obj.GetOwnerReferences()
-				if len(owners) != 1 {
-					t.Errorf("Expect 2 owners, but have %d", len(owners))
-				}
-
-				if owners[0].UID != "testowner" {
-					t.Errorf("Owner UId is not correct, got %s", owners[0].UID)
-				}
-			},
-		},
-		{
-			name:           "create a new object without owner",
-			existingObject: []runtime.Object{},
-			owner:          metav1.OwnerReference{Name: "test", UID: "testowner-"},
-			required:       spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
-			gvr:            schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
-			validateActions: func(t *testing.T, actions []clienttesting.Action) {
-				if len(actions) != 2 {
-					t.Errorf("Expect 2 actions, but have %d", len(actions))
-				}
-
-				spoketesting.AssertAction(t, actions[0], "get")
-				spoketesting.AssertAction(t, actions[1], "create")
-
-				obj := actions[1].(clienttesting.CreateActionImpl).Object.(*unstructured.Unstructured)
-				owners := obj.GetOwnerReferences()
-				if len(owners) != 0 {
-					t.Errorf("Expect 1 owners, but have %d", len(owners))
-				}
-			},
-		},
-		{
-			name:           "update an object owner",
-			existingObject: []runtime.Object{spoketesting.NewUnstructured("v1", "Secret", "ns1", "test", metav1.OwnerReference{Name: "test1", UID: "testowner1"})},
-			owner:          metav1.OwnerReference{Name: "test", UID: "testowner"},
-			required:       spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
-			gvr:            schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
-			validateActions: func(t *testing.T, actions []clienttesting.Action) {
-				if len(actions) != 2 {
-					t.Errorf("Expect 2 actions, but have %d", len(actions))
-				}
-
-				spoketesting.AssertAction(t, actions[0], "get")
-				spoketesting.AssertAction(t, actions[1], "update")
-
-				obj := actions[1].(clienttesting.UpdateActionImpl).Object.(*unstructured.Unstructured)
-				owners := obj.GetOwnerReferences()
-				if len(owners) != 2 {
-					t.Errorf("Expect 2 owners, but have %d", len(owners))
-				}
-
-				if owners[0].UID != "testowner1" {
-					t.Errorf("Owner UId is not correct, got %s", owners[0].UID)
-				}
-				if owners[1].UID != "testowner" {
-					t.Errorf("Owner UId is not correct, got %s", owners[1].UID)
-				}
-			},
-		},
-		{
-			name:           "update an object without owner",
-			existingObject: []runtime.Object{spoketesting.NewUnstructured("v1", "Secret", "ns1", "test", metav1.OwnerReference{Name: "test1", UID: "testowner1"})},
-			owner:          metav1.OwnerReference{Name: "test", UID: "testowner-"},
-			required:       spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
-			gvr:            schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
-			validateActions: func(t *testing.T, actions []clienttesting.Action) {
-				if len(actions) != 1 {
-					t.Errorf("Expect 1 actions, but have %d", len(actions))
-				}
-
-				spoketesting.AssertAction(t, actions[0], "get")
-			},
-		},
-		{
-			name:           "remove an object owner",
-			existingObject: []runtime.Object{spoketesting.NewUnstructured("v1", "Secret", "ns1", "test", metav1.OwnerReference{Name: "test", UID: "testowner"})},
-			owner:          metav1.OwnerReference{Name: "test", UID: "testowner-"},
-			required:       spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
-			gvr:            schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
-			validateActions: func(t *testing.T, actions []clienttesting.Action) {
-				if len(actions) != 2 {
-					t.Errorf("Expect 2 actions, but have %d", len(actions))
-				}
-
-				spoketesting.AssertAction(t, actions[0], "get")
-				spoketesting.AssertAction(t, actions[1], "update")
-
-				obj := actions[1].(clienttesting.UpdateActionImpl).Object.(*unstructured.Unstructured)
-				owners := obj.GetOwnerReferences()
-				if len(owners) != 0 {
-					t.Errorf("Expect 0 owner, but have %d", len(owners))
-				}
-			},
-		},
-	}
-
-	for _, c := range cases {
-		t.Run(c.name, func(t *testing.T) {
-			work, workKey := spoketesting.NewManifestWork(0)
-			work.Finalizers = []string{controllers.ManifestWorkFinalizer}
-			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).
-				withUnstructuredObject(c.existingObject...)
-			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
-
-			data, _ := json.Marshal(c.required)
-			_, _, err := controller.controller.applyUnstructured(
-				context.TODO(), data, c.owner, c.gvr, syncContext.Recorder())
-
-			if err != nil {
-				t.Errorf("expect no error, but got %v", err)
-			}
-
-			c.validateActions(t, controller.dynamicClient.Actions())
-		})
-	}
-}
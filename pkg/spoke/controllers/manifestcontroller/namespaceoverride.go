@@ -0,0 +1,77 @@
+package manifestcontroller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+// namespaceOverride is the namespace which the namespaced manifests of a manifestwork are applied to regardless of
+// the namespaces in the manifests.
+type namespaceOverride struct {
+	namespace string
+	// force rewrites the namespaces specified in the manifests as well, otherwise they are reported as conflicts.
+	force bool
+}
+
+// namespaceOverrideOf returns the namespace override of the manifestwork, nil is returned if it is not set.
+func namespaceOverrideOf(manifestWork *workapiv1.ManifestWork) *namespaceOverride {
+	namespace := manifestWork.Annotations[constants.NamespaceOverrideAnnotationKey]
+	if len(namespace) == 0 {
+		return nil
+	}
+
+	return &namespaceOverride{
+		namespace: namespace,
+		force:     manifestWork.Annotations[constants.NamespaceOverrideForceAnnotationKey] == "true",
+	}
+}
+
+// apply returns the manifest with the namespace overridden. The manifest is returned as it is if it is cluster
+// scoped or opts out of the override. Since the resource meta of the manifest is built after the override, the
+// applied resources, availability checks and orphaning rules all refer to the overridden namespace.
+func (o *namespaceOverride) apply(manifest workapiv1.Manifest, restMapper meta.RESTMapper) (workapiv1.Manifest, error) {
+	if o == nil {
+		return manifest, nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+		return manifest, err
+	}
+
+	if obj.GetAnnotations()[constants.KeepNamespaceAnnotationKey] == "true" {
+		return manifest, nil
+	}
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		// the mapping error is reported when the resource meta of the manifest is built
+		return manifest, nil
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return manifest, nil
+	}
+
+	switch namespace := obj.GetNamespace(); {
+	case namespace == o.namespace:
+		return manifest, nil
+	case len(namespace) != 0 && !o.force:
+		return manifest, applyerrors.NewValidationFailedError(mapping.Resource, namespace, obj.GetName(), errors.NewBadRequest(fmt.Sprintf(
+			"the namespace %q of %s %s conflicts with the namespace override %q", namespace, gvk.Kind, obj.GetName(), o.namespace)))
+	}
+
+	obj.SetNamespace(o.namespace)
+	raw, err := obj.MarshalJSON()
+	if err != nil {
+		return manifest, err
+	}
+	return workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}}, nil
+}
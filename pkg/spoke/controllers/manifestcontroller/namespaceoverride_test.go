@@ -0,0 +1,146 @@
+package manifestcontroller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestSyncWithNamespaceOverride(t *testing.T) {
+	keepNamespace := func(obj *unstructured.Unstructured) *unstructured.Unstructured {
+		obj.SetAnnotations(map[string]string{constants.KeepNamespaceAnnotationKey: "true"})
+		return obj
+	}
+	orphan := func(namespace string) *workapiv1.DeleteOption {
+		return &workapiv1.DeleteOption{
+			PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+			SelectivelyOrphan: &workapiv1.SelectivelyOrphan{
+				OrphaningRules: []workapiv1.OrphaningRule{
+					{Resource: "secrets", Namespace: namespace, Name: "test"},
+				},
+			},
+		}
+	}
+
+	cases := []struct {
+		name                  string
+		manifest              *unstructured.Unstructured
+		force                 bool
+		deleteOption          *workapiv1.DeleteOption
+		expectedNamespace     string
+		expectedOrphaned      bool
+		expectedAppliedStatus metav1.ConditionStatus
+	}{
+		{
+			name:                  "override empty namespace",
+			manifest:              spoketesting.NewUnstructured("v1", "Secret", "", "test"),
+			expectedNamespace:     "tenant",
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "same namespace",
+			manifest:              spoketesting.NewUnstructured("v1", "Secret", "tenant", "test"),
+			expectedNamespace:     "tenant",
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "conflict namespace",
+			manifest:              spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
+			expectedAppliedStatus: metav1.ConditionFalse,
+		},
+		{
+			name:                  "force to override namespace",
+			manifest:              spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
+			force:                 true,
+			expectedNamespace:     "tenant",
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "manifest opts out",
+			manifest:              keepNamespace(spoketesting.NewUnstructured("v1", "Secret", "ns1", "test")),
+			expectedNamespace:     "ns1",
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "cluster scoped manifest",
+			manifest:              spoketesting.NewUnstructured("v1", "Namespace", "", "test"),
+			expectedNamespace:     "",
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "orphaning rule with the overridden namespace",
+			manifest:              spoketesting.NewUnstructured("v1", "Secret", "", "test"),
+			deleteOption:          orphan("tenant"),
+			expectedNamespace:     "tenant",
+			expectedOrphaned:      true,
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "orphaning rule with the namespace in the manifest",
+			manifest:              spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"),
+			force:                 true,
+			deleteOption:          orphan("ns1"),
+			expectedNamespace:     "tenant",
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0, c.manifest)
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			work.Annotations = map[string]string{constants.NamespaceOverrideAnnotationKey: "tenant"}
+			if c.force {
+				work.Annotations[constants.NamespaceOverrideForceAnnotationKey] = "true"
+			}
+			work.Spec.DeleteOption = c.deleteOption
+			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+				t.Fatal(err)
+			}
+
+			workActions := controller.workClient.Actions()
+			updatedWork := lastUpdatedWork(t, workActions)
+			if !meta.IsStatusConditionPresentAndEqual(updatedWork.Status.Conditions, workapiv1.WorkApplied, c.expectedAppliedStatus) {
+				t.Errorf("expected Applied condition %s, but got %v", c.expectedAppliedStatus, updatedWork.Status.Conditions)
+			}
+
+			var created []clienttesting.CreateAction
+			for _, action := range controller.kubeClient.Actions() {
+				if createAction, ok := action.(clienttesting.CreateAction); ok {
+					created = append(created, createAction)
+				}
+			}
+			if c.expectedAppliedStatus != metav1.ConditionTrue {
+				if len(created) != 0 {
+					t.Errorf("expected nothing created, but got %v", created)
+				}
+				return
+			}
+			if len(created) != 1 {
+				t.Fatalf("expected 1 resource created, but got %v", controller.kubeClient.Actions())
+			}
+
+			accessor, _ := meta.Accessor(created[0].GetObject())
+			if accessor.GetNamespace() != c.expectedNamespace {
+				t.Errorf("expected resource created in namespace %q, but got %q", c.expectedNamespace, accessor.GetNamespace())
+			}
+			if ns := updatedWork.Status.ResourceStatus.Manifests[0].ResourceMeta.Namespace; ns != c.expectedNamespace {
+				t.Errorf("expected namespace %q in resource status, but got %q", c.expectedNamespace, ns)
+			}
+
+			if owned := len(accessor.GetOwnerReferences()) != 0; owned == c.expectedOrphaned {
+				t.Errorf("expected orphaned %t, but got owners %v", c.expectedOrphaned, accessor.GetOwnerReferences())
+			}
+		})
+	}
+}
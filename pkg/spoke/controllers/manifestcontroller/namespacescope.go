@@ -0,0 +1,129 @@
+package manifestcontroller
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+// namespaceScope is the set of namespaces which the manifests of a manifestwork are allowed to be applied to, so
+// that a manifest omitting its namespace is not applied to a namespace out of the scope, e.g. default.
+type namespaceScope struct {
+	// namespaces are the allowed namespaces, the first one is the default of the manifests without a namespace
+	namespaces []string
+	// clusterScoped allows the cluster scoped manifests
+	clusterScoped bool
+}
+
+// newNamespaceScope returns the namespaceScope of the agent, nil is returned if the namespaces are not limited.
+func newNamespaceScope(namespaces []string, clusterScoped bool) *namespaceScope {
+	if len(namespaces) == 0 {
+		return nil
+	}
+	return &namespaceScope{namespaces: namespaces, clusterScoped: clusterScoped}
+}
+
+// namespaceScopeOf returns the namespaceScope of the manifestwork declared with the annotation
+// AllowedNamespacesAnnotationKey within the scope of the agent. The scope of the agent is returned if the annotation
+// is not set, nil is returned if neither of them is set.
+func namespaceScopeOf(manifestWork *workapiv1.ManifestWork, agentScope *namespaceScope) *namespaceScope {
+	value := manifestWork.Annotations[constants.AllowedNamespacesAnnotationKey]
+	if len(value) == 0 {
+		return agentScope
+	}
+
+	scope := &namespaceScope{
+		clusterScoped: manifestWork.Annotations[constants.AllowClusterScopedAnnotationKey] == "true",
+	}
+	for _, namespace := range strings.Split(value, ",") {
+		if namespace = strings.TrimSpace(namespace); len(namespace) == 0 {
+			continue
+		}
+		if agentScope != nil && !sets.NewString(agentScope.namespaces...).Has(namespace) {
+			continue
+		}
+		scope.namespaces = append(scope.namespaces, namespace)
+	}
+	if agentScope != nil {
+		scope.clusterScoped = scope.clusterScoped && agentScope.clusterScoped
+	}
+	return scope
+}
+
+// apply returns the manifest with the namespace defaulted to the first allowed namespace if the manifest is
+// namespaced without a namespace. The manifests out of the scope are rejected before they are applied with a
+// ResourceError of ErrNamespaceNotPermitted. It is applied after the namespace override, so the overridden namespace
+// must be in the scope as well.
+func (s *namespaceScope) apply(manifest workapiv1.Manifest, restMapper meta.RESTMapper) (workapiv1.Manifest, error) {
+	if s == nil {
+		return manifest, nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+		return manifest, err
+	}
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		// the mapping error is reported when the resource meta of the manifest is built
+		return manifest, nil
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		if s.clusterScoped {
+			return manifest, nil
+		}
+		return manifest, applyerrors.NewNamespaceNotPermittedError(mapping.Resource, "", obj.GetName(), fmt.Errorf(
+			"cluster scoped %s %s is not permitted, the manifestwork is limited to namespaces %s",
+			gvk.Kind, obj.GetName(), strings.Join(s.namespaces, ",")))
+	}
+
+	namespace := obj.GetNamespace()
+	if len(namespace) != 0 {
+		if sets.NewString(s.namespaces...).Has(namespace) {
+			return manifest, nil
+		}
+		return manifest, applyerrors.NewNamespaceNotPermittedError(mapping.Resource, namespace, obj.GetName(), fmt.Errorf(
+			"namespace %q of %s %s is not permitted, the manifestwork is limited to namespaces %s",
+			namespace, gvk.Kind, obj.GetName(), strings.Join(s.namespaces, ",")))
+	}
+	if len(s.namespaces) == 0 {
+		return manifest, applyerrors.NewNamespaceNotPermittedError(mapping.Resource, namespace, obj.GetName(), fmt.Errorf(
+			"%s %s is not permitted, none of the namespaces of the manifestwork is allowed by the agent", gvk.Kind, obj.GetName()))
+	}
+
+	obj.SetNamespace(s.namespaces[0])
+	raw, err := obj.MarshalJSON()
+	if err != nil {
+		return manifest, err
+	}
+	return workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}}, nil
+}
+
+// check rejects the resource out of the scope like apply, the resource is cluster scoped if the namespace is empty.
+// It is used by the manifests which identify their resources out of the manifests, e.g. patches.
+func (s *namespaceScope) check(gvr schema.GroupVersionResource, namespace, name string) error {
+	switch {
+	case s == nil:
+		return nil
+	case len(namespace) == 0 && !s.clusterScoped:
+		return applyerrors.NewNamespaceNotPermittedError(gvr, "", name, fmt.Errorf(
+			"cluster scoped %s %s is not permitted, the manifestwork is limited to namespaces %s",
+			gvr.Resource, name, strings.Join(s.namespaces, ",")))
+	case len(namespace) != 0 && !sets.NewString(s.namespaces...).Has(namespace):
+		return applyerrors.NewNamespaceNotPermittedError(gvr, namespace, name, fmt.Errorf(
+			"namespace %q of %s %s is not permitted, the manifestwork is limited to namespaces %s",
+			namespace, gvr.Resource, name, strings.Join(s.namespaces, ",")))
+	}
+	return nil
+}
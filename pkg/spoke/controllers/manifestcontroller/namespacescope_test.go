@@ -0,0 +1,144 @@
+package manifestcontroller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestSyncWithNamespaceScope(t *testing.T) {
+	cases := []struct {
+		name                  string
+		manifest              *unstructured.Unstructured
+		annotations           map[string]string
+		agentScope            *namespaceScope
+		expectedNamespace     string
+		expectedAppliedStatus metav1.ConditionStatus
+		expectedReason        string
+	}{
+		{
+			name:                  "default to the first allowed namespace",
+			manifest:              spoketesting.NewUnstructured("v1", "Secret", "", "test"),
+			annotations:           map[string]string{constants.AllowedNamespacesAnnotationKey: "tenant1, tenant2"},
+			expectedNamespace:     "tenant1",
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "allowed namespace",
+			manifest:              spoketesting.NewUnstructured("v1", "Secret", "tenant2", "test"),
+			annotations:           map[string]string{constants.AllowedNamespacesAnnotationKey: "tenant1,tenant2"},
+			expectedNamespace:     "tenant2",
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "namespace not permitted",
+			manifest:              spoketesting.NewUnstructured("v1", "Secret", "default", "test"),
+			annotations:           map[string]string{constants.AllowedNamespacesAnnotationKey: "tenant1"},
+			expectedAppliedStatus: metav1.ConditionFalse,
+			expectedReason:        "NamespaceNotPermittedForExecutor",
+		},
+		{
+			name:                  "cluster scoped manifest not permitted",
+			manifest:              spoketesting.NewUnstructured("v1", "Namespace", "", "test"),
+			annotations:           map[string]string{constants.AllowedNamespacesAnnotationKey: "tenant1"},
+			expectedAppliedStatus: metav1.ConditionFalse,
+			expectedReason:        "NamespaceNotPermittedForExecutor",
+		},
+		{
+			name:     "cluster scoped manifest allowed",
+			manifest: spoketesting.NewUnstructured("v1", "Namespace", "", "test"),
+			annotations: map[string]string{
+				constants.AllowedNamespacesAnnotationKey:  "tenant1",
+				constants.AllowClusterScopedAnnotationKey: "true",
+			},
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "default to the namespace of the agent",
+			manifest:              spoketesting.NewUnstructured("v1", "Secret", "", "test"),
+			agentScope:            newNamespaceScope([]string{"agent1"}, false),
+			expectedNamespace:     "agent1",
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "manifestwork narrows the namespaces of the agent",
+			manifest:              spoketesting.NewUnstructured("v1", "Secret", "", "test"),
+			annotations:           map[string]string{constants.AllowedNamespacesAnnotationKey: "tenant1,agent2"},
+			agentScope:            newNamespaceScope([]string{"agent1", "agent2"}, false),
+			expectedNamespace:     "agent2",
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "manifestwork cannot widen the namespaces of the agent",
+			manifest:              spoketesting.NewUnstructured("v1", "Secret", "tenant1", "test"),
+			annotations:           map[string]string{constants.AllowedNamespacesAnnotationKey: "tenant1"},
+			agentScope:            newNamespaceScope([]string{"agent1"}, false),
+			expectedAppliedStatus: metav1.ConditionFalse,
+			expectedReason:        "NamespaceNotPermittedForExecutor",
+		},
+		{
+			name:     "manifestwork cannot allow cluster scoped manifests denied by the agent",
+			manifest: spoketesting.NewUnstructured("v1", "Namespace", "", "test"),
+			annotations: map[string]string{
+				constants.AllowedNamespacesAnnotationKey:  "agent1",
+				constants.AllowClusterScopedAnnotationKey: "true",
+			},
+			agentScope:            newNamespaceScope([]string{"agent1"}, false),
+			expectedAppliedStatus: metav1.ConditionFalse,
+			expectedReason:        "NamespaceNotPermittedForExecutor",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0, c.manifest)
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			work.Annotations = c.annotations
+			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+			controller.controller.namespaceScope = c.agentScope
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+				t.Fatal(err)
+			}
+
+			workActions := controller.workClient.Actions()
+			updatedWork := lastUpdatedWork(t, workActions)
+			if !meta.IsStatusConditionPresentAndEqual(updatedWork.Status.Conditions, workapiv1.WorkApplied, c.expectedAppliedStatus) {
+				t.Errorf("expected Applied condition %s, but got %v", c.expectedAppliedStatus, updatedWork.Status.Conditions)
+			}
+
+			var created []clienttesting.CreateAction
+			for _, action := range controller.kubeClient.Actions() {
+				if createAction, ok := action.(clienttesting.CreateAction); ok {
+					created = append(created, createAction)
+				}
+			}
+			if c.expectedAppliedStatus != metav1.ConditionTrue {
+				if len(created) != 0 {
+					t.Errorf("expected nothing created, but got %v", created)
+				}
+				condition := meta.FindStatusCondition(updatedWork.Status.ResourceStatus.Manifests[0].Conditions, string(workapiv1.ManifestApplied))
+				if condition == nil || condition.Reason != c.expectedReason {
+					t.Errorf("expected reason %q, but got %v", c.expectedReason, condition)
+				}
+				return
+			}
+			if len(created) != 1 {
+				t.Fatalf("expected 1 resource created, but got %v", controller.kubeClient.Actions())
+			}
+
+			accessor, _ := meta.Accessor(created[0].GetObject())
+			if accessor.GetNamespace() != c.expectedNamespace {
+				t.Errorf("expected resource created in namespace %q, but got %q", c.expectedNamespace, accessor.GetNamespace())
+			}
+		})
+	}
+}
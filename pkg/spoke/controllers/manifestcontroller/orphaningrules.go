@@ -0,0 +1,101 @@
+package manifestcontroller
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+// maxUnmatchedOrphaningRulesListed is the max number of unmatched orphaning rules listed in the condition message.
+const maxUnmatchedOrphaningRulesListed = 10
+
+// unmatchedOrphaningRules returns the indexes of the orphaning rules of the SelectivelyOrphan deleteOption which
+// match none of the resources of the manifests. False is returned if the rules are not checked, since the
+// deleteOption is not SelectivelyOrphan or the resource of any manifest is unknown, e.g. it cannot be decoded.
+func unmatchedOrphaningRules(deleteOption *workapiv1.DeleteOption, results []applyResult) ([]int, bool) {
+	if deleteOption == nil || deleteOption.PropagationPolicy != workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan ||
+		deleteOption.SelectivelyOrphan == nil {
+		return nil, false
+	}
+	rules := deleteOption.SelectivelyOrphan.OrphaningRules
+
+	matched := make([]bool, len(rules))
+	for _, result := range results {
+		resourceMeta := result.resourceMeta
+		if len(resourceMeta.Resource) == 0 || len(resourceMeta.Name) == 0 {
+			return nil, false
+		}
+		// a resource may be matched by more than one rule, all of them are matched
+		for index, rule := range rules {
+			if _, ok := helper.MatchOrphaningRules([]workapiv1.OrphaningRule{rule},
+				resourceMeta.Group, resourceMeta.Resource, resourceMeta.Namespace, resourceMeta.Name); ok {
+				matched[index] = true
+			}
+		}
+	}
+
+	var unmatched []int
+	for index := range rules {
+		if !matched[index] {
+			unmatched = append(unmatched, index)
+		}
+	}
+	return unmatched, true
+}
+
+// withOrphaningRuleUnmatchedCondition returns a function updating the status with the updateStatusFunc, and setting
+// the condition OrphaningRuleUnmatched of the manifestwork if any orphaning rule is unmatched, or removing the
+// condition once all the rules are matched or the manifestwork has no orphaning rules. The condition is kept as it
+// is if the rules are not checked.
+func withOrphaningRuleUnmatchedCondition(
+	updateStatusFunc helper.UpdateManifestWorkStatusFunc, deleteOption *workapiv1.DeleteOption, generation int64,
+	unmatched []int, checked bool) helper.UpdateManifestWorkStatusFunc {
+	return func(oldStatus *workapiv1.ManifestWorkStatus) error {
+		if err := updateStatusFunc(oldStatus); err != nil {
+			return err
+		}
+		switch {
+		case !checked && deleteOption != nil && deleteOption.PropagationPolicy == workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan:
+			return nil
+		case len(unmatched) == 0:
+			meta.RemoveStatusCondition(&oldStatus.Conditions, constants.WorkOrphaningRuleUnmatched)
+			return nil
+		}
+
+		rules := deleteOption.SelectivelyOrphan.OrphaningRules
+		listed := []string{}
+		for _, index := range unmatched {
+			if len(listed) == maxUnmatchedOrphaningRulesListed {
+				listed = append(listed, fmt.Sprintf("and %d more", len(unmatched)-maxUnmatchedOrphaningRulesListed))
+				break
+			}
+			listed = append(listed, fmt.Sprintf("rule %d (%s)", index, orphaningRuleMessage(rules[index])))
+		}
+		oldStatus.Conditions = helper.MergeStatusConditions(oldStatus.Conditions, []metav1.Condition{{
+			Type:               constants.WorkOrphaningRuleUnmatched,
+			Status:             metav1.ConditionTrue,
+			Reason:             "OrphaningRulesUnmatched",
+			ObservedGeneration: generation,
+			Message: fmt.Sprintf("%d of %d orphaning rules match no resources of the manifestwork: %s",
+				len(unmatched), len(rules), strings.Join(listed, ", ")),
+		}})
+		return nil
+	}
+}
+
+// orphaningRuleMessage returns the message describing the resource of the orphaning rule, e.g. deployments.apps ns1/n1.
+func orphaningRuleMessage(rule workapiv1.OrphaningRule) string {
+	resource := rule.Resource
+	if len(rule.Group) > 0 {
+		resource = fmt.Sprintf("%s.%s", rule.Resource, rule.Group)
+	}
+	if len(rule.Namespace) == 0 {
+		return fmt.Sprintf("%s %s", resource, rule.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", resource, rule.Namespace, rule.Name)
+}
@@ -0,0 +1,147 @@
+package manifestcontroller
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+func newSelectivelyOrphan(rules ...workapiv1.OrphaningRule) *workapiv1.DeleteOption {
+	return &workapiv1.DeleteOption{
+		PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+		SelectivelyOrphan: &workapiv1.SelectivelyOrphan{OrphaningRules: rules},
+	}
+}
+
+func TestUnmatchedOrphaningRules(t *testing.T) {
+	results := []applyResult{
+		{resourceMeta: workapiv1.ManifestResourceMeta{Group: "apps", Resource: "deployments", Namespace: "ns1", Name: "n1"}},
+		{resourceMeta: workapiv1.ManifestResourceMeta{Resource: "configmaps", Namespace: "ns2", Name: "n2"}},
+		{resourceMeta: workapiv1.ManifestResourceMeta{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Name: "n3"}},
+	}
+
+	cases := []struct {
+		name              string
+		deleteOption      *workapiv1.DeleteOption
+		results           []applyResult
+		expectedUnmatched []int
+		expectedChecked   bool
+	}{
+		{
+			name: "no delete option",
+		},
+		{
+			name:         "orphan all",
+			deleteOption: &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan},
+		},
+		{
+			name: "all rules matched",
+			deleteOption: newSelectivelyOrphan(
+				workapiv1.OrphaningRule{Group: "apps", Resource: "deployments", Namespace: "ns1", Name: "n1"},
+				workapiv1.OrphaningRule{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Name: "n3"},
+			),
+			expectedChecked: true,
+		},
+		{
+			name: "overlapping rules matched",
+			deleteOption: newSelectivelyOrphan(
+				workapiv1.OrphaningRule{Resource: "configmaps", Namespace: "ns2", Name: "n2"},
+				workapiv1.OrphaningRule{Resource: "configmaps", Namespace: "*", Name: "n2"},
+			),
+			expectedChecked: true,
+		},
+		{
+			name: "partially matched rules",
+			deleteOption: newSelectivelyOrphan(
+				workapiv1.OrphaningRule{Group: "apps", Resource: "deployment", Namespace: "ns1", Name: "n1"},
+				workapiv1.OrphaningRule{Resource: "configmaps", Namespace: "*", Name: "n2"},
+				workapiv1.OrphaningRule{Resource: "configmaps", Namespace: "ns1", Name: "n2"},
+				workapiv1.OrphaningRule{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Name: "n3"},
+			),
+			expectedUnmatched: []int{0, 2},
+			expectedChecked:   true,
+		},
+		{
+			name: "unknown resource",
+			deleteOption: newSelectivelyOrphan(
+				workapiv1.OrphaningRule{Group: "apps", Resource: "deployment", Namespace: "ns1", Name: "n1"},
+			),
+			results: append([]applyResult{{resourceMeta: workapiv1.ManifestResourceMeta{Ordinal: 3}}}, results...),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.results == nil {
+				c.results = results
+			}
+			unmatched, checked := unmatchedOrphaningRules(c.deleteOption, c.results)
+			if !reflect.DeepEqual(unmatched, c.expectedUnmatched) || checked != c.expectedChecked {
+				t.Errorf("expected unmatched rules %v checked %t, but got %v checked %t",
+					c.expectedUnmatched, c.expectedChecked, unmatched, checked)
+			}
+		})
+	}
+}
+
+func TestOrphaningRuleUnmatchedCondition(t *testing.T) {
+	deleteOption := newSelectivelyOrphan(
+		workapiv1.OrphaningRule{Group: "apps", Resource: "deployment", Namespace: "ns1", Name: "n1"},
+		workapiv1.OrphaningRule{Resource: "configmaps", Name: "n2"},
+	)
+	existing := metav1.Condition{
+		Type: constants.WorkOrphaningRuleUnmatched, Status: metav1.ConditionTrue, Reason: "OrphaningRulesUnmatched", Message: "existing",
+	}
+	noop := func(status *workapiv1.ManifestWorkStatus) error { return nil }
+
+	cases := []struct {
+		name            string
+		deleteOption    *workapiv1.DeleteOption
+		unmatched       []int
+		checked         bool
+		expectedMessage string
+	}{
+		{
+			name:            "unmatched rules",
+			deleteOption:    deleteOption,
+			unmatched:       []int{0, 1},
+			checked:         true,
+			expectedMessage: "2 of 2 orphaning rules match no resources of the manifestwork: rule 0 (deployment.apps ns1/n1), rule 1 (configmaps n2)",
+		},
+		{
+			name:         "all rules matched",
+			deleteOption: deleteOption,
+			checked:      true,
+		},
+		{
+			name:            "rules not checked",
+			deleteOption:    deleteOption,
+			expectedMessage: "existing",
+		},
+		{
+			name: "orphaning rules removed",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			status := &workapiv1.ManifestWorkStatus{Conditions: []metav1.Condition{existing}}
+			updateStatusFunc := withOrphaningRuleUnmatchedCondition(noop, c.deleteOption, 0, c.unmatched, c.checked)
+			if err := updateStatusFunc(status); err != nil {
+				t.Fatal(err)
+			}
+
+			condition := meta.FindStatusCondition(status.Conditions, constants.WorkOrphaningRuleUnmatched)
+			switch {
+			case len(c.expectedMessage) == 0 && condition != nil:
+				t.Errorf("expected the condition removed, but got %v", condition)
+			case len(c.expectedMessage) > 0 && (condition == nil || condition.Message != c.expectedMessage):
+				t.Errorf("expected the condition with message %q, but got %v", c.expectedMessage, condition)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package manifestcontroller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// resolveOwnerReferences sets the UIDs of the owner references declared by the manifest without UID, which name the
+// resources of the manifests applied earlier in the same manifestwork, e.g. a child object tied to a parent custom
+// resource delivered in the manifestwork. The UIDs are taken from the results of the earlier manifests, or from the
+// uids recorded in the appliedmanifestwork if the earlier manifests are not applied again since they are not changed.
+// An error is returned if an owner reference without UID names no applied sibling, or if more than one owner
+// reference is the controller.
+func resolveOwnerReferences(
+	manifest workapiv1.Manifest, siblings []applyResult, uids map[appliedResourceKey]types.UID) (workapiv1.Manifest, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+		return manifest, nil
+	}
+	ownerRefs := obj.GetOwnerReferences()
+	if len(ownerRefs) == 0 {
+		return manifest, nil
+	}
+
+	controller := -1
+	resolved := false
+	for index, ownerRef := range ownerRefs {
+		if ownerRef.Controller != nil && *ownerRef.Controller {
+			if controller >= 0 {
+				return manifest, fmt.Errorf("owner references %s and %s are both the controller, only one controller is allowed",
+					ownerRefMessage(ownerRefs[controller]), ownerRefMessage(ownerRef))
+			}
+			controller = index
+		}
+		if len(ownerRef.UID) != 0 {
+			continue
+		}
+
+		uid, err := siblingUID(ownerRef, obj.GetNamespace(), siblings, uids)
+		if err != nil {
+			return manifest, err
+		}
+		ownerRefs[index].UID = uid
+		resolved = true
+	}
+	if !resolved {
+		return manifest, nil
+	}
+
+	obj.SetOwnerReferences(ownerRefs)
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return manifest, fmt.Errorf("failed to encode the manifest with the owner references resolved: %w", err)
+	}
+	return workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: data}}, nil
+}
+
+// siblingUID returns the UID of the resource named by the owner reference among the resources of the manifests
+// applied earlier. The owner is in the namespace of the dependent, or cluster scoped.
+func siblingUID(
+	ownerRef metav1.OwnerReference, namespace string, siblings []applyResult, uids map[appliedResourceKey]types.UID) (types.UID, error) {
+	gv, err := schema.ParseGroupVersion(ownerRef.APIVersion)
+	if err != nil {
+		return "", fmt.Errorf("invalid apiVersion of owner reference %s: %w", ownerRefMessage(ownerRef), err)
+	}
+
+	for _, sibling := range siblings {
+		resMeta := sibling.resourceMeta
+		if resMeta.Group != gv.Group || resMeta.Kind != ownerRef.Kind || resMeta.Name != ownerRef.Name {
+			continue
+		}
+		if len(resMeta.Namespace) != 0 && resMeta.Namespace != namespace {
+			continue
+		}
+
+		var uid types.UID
+		switch {
+		case sibling.Error != nil:
+		case sibling.Result != nil:
+			if accessor, err := meta.Accessor(sibling.Result); err == nil {
+				uid = accessor.GetUID()
+			}
+		case sibling.skipped:
+			uid = uids[appliedResourceKey{
+				group: resMeta.Group, resource: resMeta.Resource, namespace: resMeta.Namespace, name: resMeta.Name}]
+		}
+		if len(uid) == 0 {
+			return "", fmt.Errorf("owner %s is not applied yet", ownerRefMessage(ownerRef))
+		}
+		return uid, nil
+	}
+	return "", fmt.Errorf("owner reference %s has no uid and names no resource applied earlier in the manifestwork",
+		ownerRefMessage(ownerRef))
+}
+
+func ownerRefMessage(ownerRef metav1.OwnerReference) string {
+	return fmt.Sprintf("%s %s/%s", ownerRef.APIVersion, ownerRef.Kind, ownerRef.Name)
+}
@@ -0,0 +1,115 @@
+package manifestcontroller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func newOwnerRef(apiVersion, kind, name, uid string, controller bool) metav1.OwnerReference {
+	ownerRef := metav1.OwnerReference{APIVersion: apiVersion, Kind: kind, Name: name, UID: types.UID(uid)}
+	if controller {
+		ownerRef.Controller = &controller
+	}
+	return ownerRef
+}
+
+func TestResolveOwnerReferences(t *testing.T) {
+	appliedParent := spoketesting.NewUnstructured("example.com/v1", "Parent", "ns1", "parent")
+	appliedParent.SetUID("parent-uid")
+	siblings := []applyResult{
+		{resourceMeta: workapiv1.ManifestResourceMeta{Group: "example.com", Kind: "Parent", Resource: "parents", Namespace: "ns1", Name: "parent"}},
+		{resourceMeta: workapiv1.ManifestResourceMeta{Group: "example.com", Kind: "Cluster", Resource: "clusters", Name: "cluster"}},
+		{resourceMeta: workapiv1.ManifestResourceMeta{Kind: "ConfigMap", Resource: "configmaps", Namespace: "ns2", Name: "config"}},
+		{resourceMeta: workapiv1.ManifestResourceMeta{Kind: "Secret", Resource: "secrets", Namespace: "ns1", Name: "failed"}},
+	}
+	siblings[0].Result = appliedParent
+	siblings[1].skipped = true
+	siblings[2].Result = spoketesting.NewUnstructured("v1", "ConfigMap", "ns2", "config")
+	siblings[3].Error = fmt.Errorf("failed to apply")
+	uids := map[appliedResourceKey]types.UID{
+		{group: "example.com", resource: "clusters", name: "cluster"}: "cluster-uid",
+	}
+
+	cases := []struct {
+		name           string
+		ownerRefs      []metav1.OwnerReference
+		expectedOwners []metav1.OwnerReference
+		expectedErr    string
+	}{
+		{
+			name: "no owner references",
+		},
+		{
+			name:           "owner references with uid",
+			ownerRefs:      []metav1.OwnerReference{newOwnerRef("v1", "ConfigMap", "other", "other-uid", true)},
+			expectedOwners: []metav1.OwnerReference{newOwnerRef("v1", "ConfigMap", "other", "other-uid", true)},
+		},
+		{
+			name: "siblings resolved",
+			ownerRefs: []metav1.OwnerReference{
+				newOwnerRef("example.com/v1", "Parent", "parent", "", true),
+				newOwnerRef("example.com/v1", "Cluster", "cluster", "", false),
+			},
+			expectedOwners: []metav1.OwnerReference{
+				newOwnerRef("example.com/v1", "Parent", "parent", "parent-uid", true),
+				newOwnerRef("example.com/v1", "Cluster", "cluster", "cluster-uid", false),
+			},
+		},
+		{
+			name:        "sibling in another namespace",
+			ownerRefs:   []metav1.OwnerReference{newOwnerRef("v1", "ConfigMap", "config", "", false)},
+			expectedErr: "names no resource applied earlier",
+		},
+		{
+			name:        "sibling failed to apply",
+			ownerRefs:   []metav1.OwnerReference{newOwnerRef("v1", "Secret", "failed", "", false)},
+			expectedErr: "is not applied yet",
+		},
+		{
+			name: "conflicting controllers",
+			ownerRefs: []metav1.OwnerReference{
+				newOwnerRef("example.com/v1", "Parent", "parent", "", true),
+				newOwnerRef("v1", "ConfigMap", "other", "other-uid", true),
+			},
+			expectedErr: "owner references example.com/v1 Parent/parent and v1 ConfigMap/other are both the controller",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			obj := spoketesting.NewUnstructured("v1", "Secret", "ns1", "child", c.ownerRefs...)
+			data, err := obj.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			manifest, err := resolveOwnerReferences(workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: data}}, siblings, uids)
+			switch {
+			case len(c.expectedErr) > 0:
+				if err == nil || !strings.Contains(err.Error(), c.expectedErr) {
+					t.Fatalf("expected error %q, but got %v", c.expectedErr, err)
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			resolved := &unstructured.Unstructured{}
+			if err := resolved.UnmarshalJSON(manifest.Raw); err != nil {
+				t.Fatal(err)
+			}
+			if owners := resolved.GetOwnerReferences(); !reflect.DeepEqual(owners, c.expectedOwners) {
+				t.Errorf("expected owners %v, but got %v", c.expectedOwners, owners)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package manifestcontroller
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+// provenance is the labels and annotations injected into the applied resources of a manifestwork, so that the
+// tools on the managed cluster are able to group the resources by the hub and manifestwork applying them.
+type provenance struct {
+	labels      map[string]string
+	annotations map[string]string
+}
+
+// provenanceOf returns the provenance of the manifestwork, nil is returned if provenance is not enabled on the
+// agent or it is disabled by the manifestwork.
+func (m *ManifestWorkController) provenanceOf(manifestWork *workapiv1.ManifestWork) *provenance {
+	if !m.propagateProvenance || manifestWork.Annotations[constants.DisableProvenanceAnnotationKey] == "true" {
+		return nil
+	}
+
+	return &provenance{
+		labels: map[string]string{
+			constants.ManifestWorkLabelKey:          manifestWork.Name,
+			constants.ManifestWorkNamespaceLabelKey: manifestWork.Namespace,
+		},
+		annotations: map[string]string{
+			constants.HubHashAnnotationKey:         m.hubHash,
+			constants.ManifestWorkUIDAnnotationKey: string(manifestWork.UID),
+		},
+	}
+}
+
+// inject returns the manifest with the provenance labels and annotations. The labels and annotations specified in
+// the manifest win over the provenance ones with the same keys. Since they are injected before the manifest is
+// compared with the existing resource, they are not treated as drift.
+func (p *provenance) inject(manifest workapiv1.Manifest) (workapiv1.Manifest, error) {
+	if p == nil {
+		return manifest, nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+		return manifest, err
+	}
+
+	obj.SetLabels(mergeAbsent(obj.GetLabels(), p.labels))
+	obj.SetAnnotations(mergeAbsent(obj.GetAnnotations(), p.annotations))
+
+	raw, err := obj.MarshalJSON()
+	if err != nil {
+		return manifest, err
+	}
+	return workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}}, nil
+}
+
+// mergeAbsent adds the items which are absent in existing.
+func mergeAbsent(existing, items map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range items {
+		merged[k] = v
+	}
+	for k, v := range existing {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,133 @@
+package manifestcontroller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestProvenance(t *testing.T) {
+	expectedLabels := map[string]string{
+		constants.ManifestWorkLabelKey:          "work-0",
+		constants.ManifestWorkNamespaceLabelKey: "cluster1",
+	}
+	expectedAnnotations := map[string]string{
+		constants.HubHashAnnotationKey:         "hub1",
+		constants.ManifestWorkUIDAnnotationKey: "work-uid",
+	}
+
+	newObject := func(labels, annotations map[string]string) *unstructured.Unstructured {
+		obj := spoketesting.NewUnstructuredWithContent(
+			"v1", "NewObject", "ns1", "n1", map[string]interface{}{"spec": map[string]interface{}{"key1": "val1"}})
+		obj.SetLabels(labels)
+		obj.SetAnnotations(annotations)
+		return obj
+	}
+
+	cases := []struct {
+		name                string
+		propagateProvenance bool
+		workAnnotations     map[string]string
+		manifest            *unstructured.Unstructured
+		existingObjects     []runtime.Object
+		validateActions     func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:                "inject provenance",
+			propagateProvenance: true,
+			manifest:            newObject(nil, nil),
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				spoketesting.AssertAction(t, actions[len(actions)-1], "create")
+				obj := actions[len(actions)-1].(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+				if !reflect.DeepEqual(obj.GetLabels(), expectedLabels) || !reflect.DeepEqual(obj.GetAnnotations(), expectedAnnotations) {
+					t.Errorf("unexpected provenance %v, %v", obj.GetLabels(), obj.GetAnnotations())
+				}
+			},
+		},
+		{
+			name:                "user specified labels win",
+			propagateProvenance: true,
+			manifest:            newObject(map[string]string{constants.ManifestWorkLabelKey: "mine"}, nil),
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				obj := actions[len(actions)-1].(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+				if obj.GetLabels()[constants.ManifestWorkLabelKey] != "mine" {
+					t.Errorf("expected user specified label, but got %v", obj.GetLabels())
+				}
+				if obj.GetLabels()[constants.ManifestWorkNamespaceLabelKey] != "cluster1" {
+					t.Errorf("expected provenance label, but got %v", obj.GetLabels())
+				}
+			},
+		},
+		{
+			name:                "provenance is not treated as drift",
+			propagateProvenance: true,
+			manifest:            newObject(nil, nil),
+			existingObjects: []runtime.Object{func() runtime.Object {
+				obj := newObject(expectedLabels, expectedAnnotations)
+				owner := helper.NewAppliedManifestWorkOwner(
+					&workapiv1.AppliedManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "hub1-work-0"}})
+				obj.SetOwnerReferences([]metav1.OwnerReference{*owner})
+				return obj
+			}()},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				for _, action := range actions {
+					if action.GetVerb() != "get" {
+						t.Errorf("expected no update, but got %s", spew.Sdump(actions))
+					}
+				}
+			},
+		},
+		{
+			name:                "disabled by manifestwork",
+			propagateProvenance: true,
+			workAnnotations:     map[string]string{constants.DisableProvenanceAnnotationKey: "true"},
+			manifest:            newObject(nil, nil),
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				obj := actions[len(actions)-1].(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+				if len(obj.GetLabels()) != 0 || len(obj.GetAnnotations()) != 0 {
+					t.Errorf("expected no provenance, but got %v, %v", obj.GetLabels(), obj.GetAnnotations())
+				}
+			},
+		},
+		{
+			name:     "disabled on agent",
+			manifest: newObject(nil, nil),
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				obj := actions[len(actions)-1].(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+				if len(obj.GetLabels()) != 0 || len(obj.GetAnnotations()) != 0 {
+					t.Errorf("expected no provenance, but got %v, %v", obj.GetLabels(), obj.GetAnnotations())
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0, c.manifest)
+			work.UID = "work-uid"
+			work.Annotations = c.workAnnotations
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).
+				withKubeObject().
+				withUnstructuredObject(c.existingObjects...)
+			controller.controller.hubHash = "hub1"
+			controller.controller.propagateProvenance = c.propagateProvenance
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+				t.Fatal(err)
+			}
+			c.validateActions(t, controller.dynamicClient.Actions())
+		})
+	}
+}
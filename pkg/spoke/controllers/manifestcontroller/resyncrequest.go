@@ -0,0 +1,51 @@
+package manifestcontroller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+// pendingResyncRequest returns the resync request of the annotation ResyncRequestAnnotationKey of the manifestwork
+// if it is not handled yet, otherwise an empty string is returned.
+func pendingResyncRequest(manifestWork *workapiv1.ManifestWork) string {
+	request := manifestWork.Annotations[constants.ResyncRequestAnnotationKey]
+	if len(request) == 0 {
+		return ""
+	}
+	handled := meta.FindStatusCondition(manifestWork.Status.Conditions, constants.WorkResyncRequestHandled)
+	if handled != nil && handled.Message == resyncRequestHandledMessage(request) {
+		return ""
+	}
+	return request
+}
+
+func resyncRequestHandledMessage(request string) string {
+	return fmt.Sprintf("Resync request %s is handled", request)
+}
+
+// withResyncRequestHandledCondition returns a function updating the status with the updateStatusFunc, and recording
+// the resync request in the condition ResyncRequestHandled of the manifestwork if the request is not empty.
+func withResyncRequestHandledCondition(
+	updateStatusFunc helper.UpdateManifestWorkStatusFunc, generation int64, request string) helper.UpdateManifestWorkStatusFunc {
+	return func(oldStatus *workapiv1.ManifestWorkStatus) error {
+		if err := updateStatusFunc(oldStatus); err != nil {
+			return err
+		}
+		if len(request) == 0 {
+			return nil
+		}
+		oldStatus.Conditions = helper.MergeStatusConditions(oldStatus.Conditions, []metav1.Condition{{
+			Type:               constants.WorkResyncRequestHandled,
+			Status:             metav1.ConditionTrue,
+			Reason:             "ResyncRequestHandled",
+			ObservedGeneration: generation,
+			Message:            resyncRequestHandledMessage(request),
+		}})
+		return nil
+	}
+}
@@ -0,0 +1,99 @@
+package manifestcontroller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestPendingResyncRequest(t *testing.T) {
+	handled := func(request string) []metav1.Condition {
+		return []metav1.Condition{{
+			Type:    constants.WorkResyncRequestHandled,
+			Status:  metav1.ConditionTrue,
+			Reason:  "ResyncRequestHandled",
+			Message: resyncRequestHandledMessage(request),
+		}}
+	}
+
+	cases := []struct {
+		name       string
+		request    string
+		conditions []metav1.Condition
+		expected   string
+	}{
+		{
+			name: "no request",
+		},
+		{
+			name:     "new request",
+			request:  "t1",
+			expected: "t1",
+		},
+		{
+			name:       "request handled",
+			request:    "t1",
+			conditions: handled("t1"),
+		},
+		{
+			name:       "another request",
+			request:    "t2",
+			conditions: handled("t1"),
+			expected:   "t2",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, _ := spoketesting.NewManifestWork(0)
+			if len(c.request) > 0 {
+				work.Annotations = map[string]string{constants.ResyncRequestAnnotationKey: c.request}
+			}
+			work.Status.Conditions = c.conditions
+			if actual := pendingResyncRequest(work); actual != c.expected {
+				t.Errorf("expected pending request %q, but got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+// Test the resync request handled is recorded in the status
+func TestSyncWithResyncRequest(t *testing.T) {
+	work, workKey := spoketesting.NewManifestWork(0, spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"))
+	work.Finalizers = []string{constants.ManifestWorkFinalizer}
+	work.Generation = 2
+	work.Annotations = map[string]string{constants.ResyncRequestAnnotationKey: "t1"}
+	controller := newController(work, nil, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+
+	if err := controller.controller.sync(context.TODO(), spoketesting.NewFakeSyncContext(t, workKey)); err != nil {
+		t.Fatal(err)
+	}
+
+	updatedWork, err := controller.workClient.WorkV1().ManifestWorks(work.Namespace).Get(context.TODO(), work.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(updatedWork.Status.Conditions, constants.WorkResyncRequestHandled)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.ObservedGeneration != 2 ||
+		condition.Message != "Resync request t1 is handled" {
+		t.Errorf("unexpected condition %v", condition)
+	}
+	if request := pendingResyncRequest(updatedWork); len(request) != 0 {
+		t.Errorf("expected no pending request, but got %q", request)
+	}
+
+	// the condition is kept once the request is handled
+	status := updatedWork.Status.DeepCopy()
+	if err := withResyncRequestHandledCondition(
+		func(*workapiv1.ManifestWorkStatus) error { return nil }, 3, "")(status); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(status.Conditions, constants.WorkResyncRequestHandled) {
+		t.Errorf("expected the condition kept, but got %v", status.Conditions)
+	}
+}
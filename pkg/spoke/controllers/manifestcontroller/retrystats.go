@@ -0,0 +1,134 @@
+package manifestcontroller
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	retryStatsSeparator = "; retries="
+	retryStatsFormat    = "%d lastAttemptTime=%s lastError=%q"
+
+	// maxRetryErrorLength is the max length of the last error recorded in the work status.
+	maxRetryErrorLength = 256
+)
+
+// retryStats records how many times the manifests of a work failed to apply since they were last applied
+// successfully, when the last failed attempt was and its error. The ManifestWork API has no status field for
+// them, so they are appended to the message of the Applied condition of the work once it is false.
+type retryStats struct {
+	retries         int
+	lastAttemptTime time.Time
+	lastError       string
+}
+
+// newRetryStats returns the retry stats of a failed attempt, the last error is the error of the first manifest
+// failed to apply.
+func newRetryStats(retries int, lastAttemptTime time.Time, manifestErrors []error) retryStats {
+	stats := retryStats{retries: retries, lastAttemptTime: lastAttemptTime}
+	for ordinal, err := range manifestErrors {
+		if err == nil {
+			continue
+		}
+		stats.lastError = fmt.Sprintf("manifest %d: %v", ordinal, err)
+		break
+	}
+	if len(stats.lastError) > maxRetryErrorLength {
+		// the rune cut at the max length is dropped
+		stats.lastError = strings.ToValidUTF8(stats.lastError[:maxRetryErrorLength], "") + "..."
+	}
+	return stats
+}
+
+// formatMessage appends the retry stats to the message.
+func (s retryStats) formatMessage(message string) string {
+	return message + retryStatsSeparator + fmt.Sprintf(retryStatsFormat,
+		s.retries, s.lastAttemptTime.UTC().Format(time.RFC3339), s.lastError)
+}
+
+// parseRetryStats returns the retry stats recorded in the message. False is returned if the message has no stats.
+func parseRetryStats(message string) (retryStats, bool) {
+	index := strings.Index(message, retryStatsSeparator)
+	if index < 0 {
+		return retryStats{}, false
+	}
+
+	var stats retryStats
+	var lastAttemptTime string
+	if _, err := fmt.Sscanf(message[index+len(retryStatsSeparator):], retryStatsFormat,
+		&stats.retries, &lastAttemptTime, &stats.lastError); err != nil {
+		return retryStats{}, false
+	}
+	t, err := time.Parse(time.RFC3339, lastAttemptTime)
+	if err != nil {
+		return retryStats{}, false
+	}
+	stats.lastAttemptTime = t
+	return stats, true
+}
+
+// retryConditionMessage returns the message of the false Applied condition with the retry stats. The stats recorded
+// on the existing condition are kept unless the error is changed or the number of retries reaches a power of two,
+// so that the work status is not updated on every retry of a work failing over and over.
+func retryConditionMessage(message string, stats retryStats, existing *metav1.Condition, generation int64) string {
+	if existing == nil || existing.Status != metav1.ConditionFalse || existing.ObservedGeneration != generation {
+		return stats.formatMessage(message)
+	}
+
+	existingStats, ok := parseRetryStats(existing.Message)
+	if !ok || existingStats.lastError != stats.lastError || isPowerOfTwo(stats.retries) {
+		return stats.formatMessage(message)
+	}
+	return existingStats.formatMessage(message)
+}
+
+func isPowerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+// applyRetries counts the failed attempts to apply the manifestworks since they were last applied successfully.
+type applyRetries struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+func newApplyRetries() *applyRetries {
+	return &applyRetries{
+		counts: map[string]int{},
+	}
+}
+
+// failed records a failed attempt to apply the manifestwork and returns the number of failed attempts. The count
+// starts from the retries recorded in the work status if the attempts are not counted yet, e.g. after the agent
+// restarts. The retries recorded are returned plus one if the counter is nil.
+func (r *applyRetries) failed(manifestWorkName string, recorded int) int {
+	if r == nil {
+		return recorded + 1
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	count, ok := r.counts[manifestWorkName]
+	if !ok {
+		count = recorded
+	}
+	count++
+	r.counts[manifestWorkName] = count
+	return count
+}
+
+// reset drops the count of the manifestwork once it is applied successfully or deleted. It does nothing if the
+// counter is nil.
+func (r *applyRetries) reset(manifestWorkName string) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.counts, manifestWorkName)
+}
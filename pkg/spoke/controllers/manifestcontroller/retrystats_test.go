@@ -0,0 +1,112 @@
+package manifestcontroller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+func TestRetryStatsFormat(t *testing.T) {
+	lastAttemptTime := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	stats := newRetryStats(3, lastAttemptTime, []error{nil, fmt.Errorf("quoted \"error\"; retries=5")})
+	if stats.lastError != `manifest 1: quoted "error"; retries=5` {
+		t.Errorf("unexpected last error %q", stats.lastError)
+	}
+
+	message := stats.formatMessage("Failed to apply manifest work")
+	if expected := `Failed to apply manifest work; retries=3 lastAttemptTime=2021-06-01T10:00:00Z lastError="manifest 1: quoted \"error\"; retries=5"`; message != expected {
+		t.Errorf("expected message %q, but got %q", expected, message)
+	}
+	parsed, ok := parseRetryStats(message)
+	if !ok || !reflect.DeepEqual(parsed, stats) {
+		t.Errorf("expected stats %v parsed, but got %v", stats, parsed)
+	}
+	if _, ok := parseRetryStats("Failed to apply manifest work"); ok {
+		t.Errorf("expected no stats parsed from the message without stats")
+	}
+
+	long := newRetryStats(1, lastAttemptTime, []error{fmt.Errorf("%s", strings.Repeat("x", 2*maxRetryErrorLength))})
+	if len(long.lastError) != maxRetryErrorLength+len("...") || !strings.HasSuffix(long.lastError, "...") {
+		t.Errorf("expected the last error truncated, but got %q", long.lastError)
+	}
+}
+
+func TestRetryStatsChurn(t *testing.T) {
+	startTime := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	retries := newApplyRetries()
+
+	var existing *metav1.Condition
+	written := []int{}
+	attempt := func(err error, generation int64) {
+		recorded := 0
+		if existing != nil {
+			if stats, ok := parseRetryStats(existing.Message); ok {
+				recorded = stats.retries
+			}
+		}
+		count := retries.failed("work", recorded)
+		stats := newRetryStats(count, startTime.Add(time.Duration(count)*time.Minute), []error{err})
+		message := retryConditionMessage("Failed to apply manifest work", stats, existing, generation)
+		if existing == nil || message != existing.Message {
+			written = append(written, count)
+		}
+		existing = &metav1.Condition{
+			Type:               workapiv1.WorkApplied,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Message:            message,
+		}
+	}
+
+	// the stats are written once the retries reach powers of two
+	for i := 0; i < 9; i++ {
+		attempt(fmt.Errorf("error1"), 0)
+	}
+	if expected := []int{1, 2, 4, 8}; !reflect.DeepEqual(written, expected) {
+		t.Errorf("expected the stats written at %v retries, but got %v", expected, written)
+	}
+	stats, _ := parseRetryStats(existing.Message)
+	if stats.retries != 8 || !stats.lastAttemptTime.Equal(startTime.Add(8*time.Minute)) {
+		t.Errorf("expected the stats of the 8th retry kept, but got %v", stats)
+	}
+
+	// the stats are written once the error is changed
+	written = nil
+	attempt(fmt.Errorf("error2"), 0)
+	attempt(fmt.Errorf("error2"), 0)
+	if expected := []int{10}; !reflect.DeepEqual(written, expected) {
+		t.Errorf("expected the stats written at %v retries, but got %v", expected, written)
+	}
+
+	// the stats are written once the generation is changed
+	written = nil
+	attempt(fmt.Errorf("error2"), 1)
+	if expected := []int{12}; !reflect.DeepEqual(written, expected) {
+		t.Errorf("expected the stats written at %v retries, but got %v", expected, written)
+	}
+
+	// the count starts from the retries recorded in the status after the agent restarts
+	retries = newApplyRetries()
+	written = nil
+	attempt(fmt.Errorf("error2"), 1)
+	if len(written) != 0 {
+		t.Errorf("expected no stats written, but got %v", written)
+	}
+	if count := retries.failed("work", 0); count != 14 {
+		t.Errorf("expected 14 retries counted, but got %d", count)
+	}
+
+	// the count is reset once the manifestwork is applied
+	retries.reset("work")
+	existing = nil
+	written = nil
+	attempt(fmt.Errorf("error2"), 1)
+	if expected := []int{1}; !reflect.DeepEqual(written, expected) {
+		t.Errorf("expected the stats written at %v retries, but got %v", expected, written)
+	}
+}
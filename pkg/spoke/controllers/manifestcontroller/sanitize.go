@@ -0,0 +1,69 @@
+package manifestcontroller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+// serverOnlyMetadataFields are the fields of the metadata populated by the apiserver, they are present in the
+// manifests copied from live objects and are never applied.
+var serverOnlyMetadataFields = []string{
+	"managedFields", "resourceVersion", "uid", "creationTimestamp", "generation", "selfLink",
+	"deletionTimestamp", "deletionGracePeriodSeconds",
+}
+
+// sanitizeManifest strips the status and the server only metadata from the manifest, e.g. when a live object is
+// copied into the manifestwork, so that they neither fail the apply nor are reported as a difference. The status is
+// kept if the manifest is applied to the status subresource. The manifest is returned as is if there is nothing to
+// strip or it cannot be decoded, the decode error is reported when the manifest is applied.
+func sanitizeManifest(manifest workapiv1.Manifest, keepStatus bool) (workapiv1.Manifest, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+		return manifest, nil
+	}
+
+	stripped := false
+	if _, ok := obj.Object["status"]; ok && !keepStatus {
+		delete(obj.Object, "status")
+		stripped = true
+	}
+	if metadata, ok := obj.Object["metadata"].(map[string]interface{}); ok {
+		for _, field := range serverOnlyMetadataFields {
+			if _, ok := metadata[field]; ok {
+				delete(metadata, field)
+				stripped = true
+			}
+		}
+	}
+	if !stripped {
+		return manifest, nil
+	}
+
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return manifest, fmt.Errorf("failed to encode the sanitized manifest: %w", err)
+	}
+	return workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: data}}, nil
+}
+
+// sanitizedManifests returns the manifests of the manifestwork sanitized by sanitizeManifest, the status of the
+// manifests applied to the status subresource is kept.
+func sanitizedManifests(manifestWork *workapiv1.ManifestWork) []workapiv1.Manifest {
+	// the invalid subresources are reported when the manifestwork is applied
+	subresources, _ := helper.ManifestSubresources(manifestWork)
+
+	manifests := make([]workapiv1.Manifest, 0, len(manifestWork.Spec.Workload.Manifests))
+	for index, manifest := range manifestWork.Spec.Workload.Manifests {
+		sanitized, err := sanitizeManifest(manifest, subresources[int32(index)] == helper.SubresourceStatus)
+		if err != nil {
+			sanitized = manifest
+		}
+		manifests = append(manifests, sanitized)
+	}
+	return manifests
+}
@@ -0,0 +1,164 @@
+package manifestcontroller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+// liveDeployment is a deployment copied from a live cluster with kubectl get -o json.
+const liveDeployment = `{
+	"apiVersion": "apps/v1",
+	"kind": "Deployment",
+	"metadata": {
+		"name": "nginx",
+		"namespace": "default",
+		"labels": {"app": "nginx"},
+		"annotations": {"deployment.kubernetes.io/revision": "1"},
+		"uid": "0c1b5c5e-8a3f-4a8e-9d2a-2f0d6b0f3a11",
+		"resourceVersion": "123456",
+		"generation": 3,
+		"creationTimestamp": "2022-01-01T00:00:00Z",
+		"selfLink": "/apis/apps/v1/namespaces/default/deployments/nginx",
+		"managedFields": [{
+			"manager": "kubectl-client-side-apply",
+			"operation": "Update",
+			"apiVersion": "apps/v1",
+			"time": "2022-01-01T00:00:00Z",
+			"fieldsType": "FieldsV1",
+			"fieldsV1": {"f:spec": {"f:replicas": {}}}
+		}]
+	},
+	"spec": {
+		"replicas": 2,
+		"selector": {"matchLabels": {"app": "nginx"}},
+		"template": {
+			"metadata": {"labels": {"app": "nginx"}},
+			"spec": {"containers": [{"name": "nginx", "image": "nginx:1.21"}]}
+		}
+	},
+	"status": {
+		"observedGeneration": 3,
+		"replicas": 2,
+		"readyReplicas": 2,
+		"availableReplicas": 2,
+		"conditions": [{"type": "Available", "status": "True"}]
+	}
+}`
+
+func TestSanitizeManifest(t *testing.T) {
+	manifest := workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: []byte(liveDeployment)}}
+
+	cases := []struct {
+		name       string
+		keepStatus bool
+	}{
+		{
+			name: "strip status",
+		},
+		{
+			name:       "keep status for the status subresource",
+			keepStatus: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sanitized, err := sanitizeManifest(manifest, c.keepStatus)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj := &unstructured.Unstructured{}
+			if err := obj.UnmarshalJSON(sanitized.Raw); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, field := range serverOnlyMetadataFields {
+				if _, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "metadata", field); found {
+					t.Errorf("expected metadata.%s stripped, but got %v", field, obj.Object["metadata"])
+				}
+			}
+			if _, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "status"); found != c.keepStatus {
+				t.Errorf("expected status kept %t, but got %v", c.keepStatus, obj.Object["status"])
+			}
+			if obj.GetName() != "nginx" || obj.GetNamespace() != "default" || obj.GetLabels()["app"] != "nginx" ||
+				obj.GetAnnotations()["deployment.kubernetes.io/revision"] != "1" {
+				t.Errorf("expected the metadata set by users kept, but got %v", obj.Object["metadata"])
+			}
+			if replicas, _, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); replicas != 2 {
+				t.Errorf("expected the spec kept, but got %v", obj.Object["spec"])
+			}
+		})
+	}
+}
+
+func TestSanitizeManifestUnchanged(t *testing.T) {
+	raw := []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm1","namespace":"ns1"},"data":{"a":"b"}}`)
+	manifest := workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}}
+
+	sanitized, err := sanitizeManifest(manifest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sanitized.Raw) != string(raw) {
+		t.Errorf("expected the manifest unchanged, but got %s", string(sanitized.Raw))
+	}
+
+	// the invalid manifest is reported when it is applied
+	invalid := workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: []byte("invalid")}}
+	if sanitized, err := sanitizeManifest(invalid, false); err != nil || string(sanitized.Raw) != "invalid" {
+		t.Errorf("expected the invalid manifest unchanged, but got %s, %v", string(sanitized.Raw), err)
+	}
+}
+
+func TestAppliedSpecHashSanitized(t *testing.T) {
+	newWork := func(mutate func(obj *unstructured.Unstructured)) *workapiv1.ManifestWork {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON([]byte(liveDeployment)); err != nil {
+			t.Fatal(err)
+		}
+		mutate(obj)
+		data, err := obj.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		work := &workapiv1.ManifestWork{}
+		work.Spec.Workload.Manifests = []workapiv1.Manifest{{RawExtension: runtime.RawExtension{Raw: data}}}
+		return work
+	}
+
+	hash, err := appliedSpecHash(newWork(func(obj *unstructured.Unstructured) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the live object copied again after its status and resource version changed
+	recopied := newWork(func(obj *unstructured.Unstructured) {
+		obj.SetResourceVersion("123457")
+		_ = unstructured.SetNestedField(obj.Object, int64(1), "status", "readyReplicas")
+	})
+	if recopiedHash, _ := appliedSpecHash(recopied); recopiedHash != hash {
+		t.Errorf("expected the hash unchanged by cosmetic differences")
+	}
+
+	// the status is part of the spec of the manifest applied to the status subresource
+	subresources, _ := json.Marshal([]map[string]interface{}{{"ordinal": 0, "subresource": "status"}})
+	recopied.Annotations = map[string]string{constants.ManifestSubresourcesAnnotationKey: string(subresources)}
+	original := newWork(func(obj *unstructured.Unstructured) {})
+	original.Annotations = recopied.Annotations
+	originalHash, _ := appliedSpecHash(original)
+	if recopiedHash, _ := appliedSpecHash(recopied); recopiedHash == originalHash {
+		t.Errorf("expected the hash changed by the status applied to the status subresource")
+	}
+
+	changed := newWork(func(obj *unstructured.Unstructured) {
+		_ = unstructured.SetNestedField(obj.Object, int64(3), "spec", "replicas")
+	})
+	if changedHash, _ := appliedSpecHash(changed); changedHash == hash {
+		t.Errorf("expected the hash changed by the spec")
+	}
+}
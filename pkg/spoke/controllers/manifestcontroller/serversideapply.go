@@ -0,0 +1,230 @@
+package manifestcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+// serverSideApplies tracks the update strategies of the resources of a manifestwork. The resources recorded on the
+// appliedmanifestwork were applied with server side apply, so the field ownership of a resource is migrated once the
+// update strategy of its manifest is changed.
+type serverSideApplies struct {
+	lock sync.Mutex
+	// ordinals are the ordinals of the manifests applied with server side apply
+	ordinals map[int32]bool
+	// recorded are the resources recorded on the appliedmanifestwork as applied with server side apply
+	recorded map[helper.ManifestResourceIdentifier]bool
+	// states are the update strategies of the resources migrated or applied by this reconcile, true if a resource
+	// is applied with server side apply
+	states map[helper.ManifestResourceIdentifier]bool
+}
+
+func serverSideAppliesOf(appliedManifestWork *workapiv1.AppliedManifestWork, ordinals map[int32]bool) *serverSideApplies {
+	ssa := &serverSideApplies{
+		ordinals: ordinals,
+		recorded: map[helper.ManifestResourceIdentifier]bool{},
+		states:   map[helper.ManifestResourceIdentifier]bool{},
+	}
+	value, ok := appliedManifestWork.Annotations[constants.AppliedServerSideApplyResourcesAnnotationKey]
+	if !ok {
+		return ssa
+	}
+	identifiers := []helper.ManifestResourceIdentifier{}
+	if err := json.Unmarshal([]byte(value), &identifiers); err != nil {
+		klog.Warningf("Failed to decode the server side apply resources of appliedmanifestwork %s: %v",
+			appliedManifestWork.Name, err)
+		return ssa
+	}
+	for _, identifier := range identifiers {
+		ssa.recorded[identifier] = true
+	}
+	return ssa
+}
+
+// enabled returns true if the manifest is applied with server side apply.
+func (s *serverSideApplies) enabled(ordinal int32) bool {
+	if s == nil {
+		return false
+	}
+	return s.ordinals[ordinal]
+}
+
+// wasEnabled returns true if the resource was applied with server side apply by the previous reconciles.
+func (s *serverSideApplies) wasEnabled(gvr schema.GroupVersionResource, resMeta workapiv1.ManifestResourceMeta) bool {
+	if s == nil {
+		return false
+	}
+	return s.recorded[serverSideApplyIdentifier(gvr, resMeta)]
+}
+
+// record records the update strategy the resource is applied with.
+func (s *serverSideApplies) record(gvr schema.GroupVersionResource, resMeta workapiv1.ManifestResourceMeta, serverSide bool) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.states[serverSideApplyIdentifier(gvr, resMeta)] = serverSide
+}
+
+// serverSideApplyIdentifier identifies the resource regardless of its version, since the field ownership is kept
+// once the manifest is changed to another version of the resource.
+func serverSideApplyIdentifier(gvr schema.GroupVersionResource, resMeta workapiv1.ManifestResourceMeta) helper.ManifestResourceIdentifier {
+	return helper.ManifestResourceIdentifier{
+		Group:     gvr.Group,
+		Resource:  gvr.Resource,
+		Namespace: resMeta.Namespace,
+		Name:      resMeta.Name,
+	}
+}
+
+// migrateFieldOwnership migrates the field ownership of the resource once the update strategy of its manifest is
+// changed. The fields set by the agent with updates are adopted by the server side apply once the manifest is changed
+// to be applied with server side apply, so that they are removed once they are dropped from the manifest. The fields
+// owned by the server side apply are released once it is changed back, so that they are owned by the updates again.
+func (m *ManifestWorkController) migrateFieldOwnership(
+	ctx context.Context,
+	gvr schema.GroupVersionResource,
+	resMeta workapiv1.ManifestResourceMeta,
+	ssa *serverSideApplies,
+	serverSide bool,
+	recorder events.Recorder) error {
+	if ssa == nil || serverSide == ssa.wasEnabled(gvr, resMeta) {
+		return nil
+	}
+
+	existing, err := m.getLiveObject(ctx, gvr, resMeta.Namespace, resMeta.Name)
+	switch {
+	case errors.IsNotFound(err):
+		// the resource is created with the new update strategy
+		if !serverSide {
+			ssa.record(gvr, resMeta, false)
+		}
+		return nil
+	case err != nil:
+		return err
+	}
+
+	var managedFields []metav1.ManagedFieldsEntry
+	var migrated bool
+	if serverSide {
+		managedFields, migrated = helper.AdoptManagedFields(
+			existing.GetManagedFields(), m.updateFieldManager, helper.ServerSideApplyFieldManager)
+	} else {
+		managedFields, migrated = helper.ReleaseManagedFields(existing.GetManagedFields(), helper.ServerSideApplyFieldManager)
+	}
+	if migrated {
+		// all the managed fields are cleared with a single empty entry instead of an empty list, which is ignored
+		if len(managedFields) == 0 {
+			managedFields = []metav1.ManagedFieldsEntry{{}}
+		}
+		patch, err := json.Marshal([]map[string]interface{}{
+			{"op": "test", "path": "/metadata/resourceVersion", "value": existing.GetResourceVersion()},
+			{"op": "replace", "path": "/metadata/managedFields", "value": managedFields},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := m.spokeDynamicClient.Resource(gvr).Namespace(resMeta.Namespace).Patch(
+			ctx, resMeta.Name, types.JSONPatchType, patch, metav1.PatchOptions{}); err != nil {
+			return err
+		}
+		if serverSide {
+			recorder.Eventf("ResourceFieldsAdopted", "The fields of %s %s/%s set by %s are adopted by %s",
+				resMeta.Kind, resMeta.Namespace, resMeta.Name, m.updateFieldManager, helper.ServerSideApplyFieldManager)
+		} else {
+			recorder.Eventf("ResourceFieldsReleased", "The fields of %s %s/%s owned by %s are released",
+				resMeta.Kind, resMeta.Namespace, resMeta.Name, helper.ServerSideApplyFieldManager)
+		}
+	}
+	if !serverSide {
+		ssa.record(gvr, resMeta, false)
+	}
+	return nil
+}
+
+// recordServerSideApplies records the resources applied with server side apply on the appliedmanifestwork. The
+// resources recorded already are kept unless they are migrated by this reconcile, or they are no longer in the
+// results once all the manifests are applied.
+func (m *ManifestWorkController) recordServerSideApplies(
+	ctx context.Context,
+	appliedManifestWork *workapiv1.AppliedManifestWork,
+	ssa *serverSideApplies,
+	results []applyResult,
+	complete bool) error {
+	if ssa == nil {
+		return nil
+	}
+
+	resources := map[helper.ManifestResourceIdentifier]bool{}
+	for _, result := range results {
+		gvr := schema.GroupVersionResource{Group: result.resourceMeta.Group, Resource: result.resourceMeta.Resource}
+		resources[serverSideApplyIdentifier(gvr, result.resourceMeta)] = true
+	}
+
+	ssa.lock.Lock()
+	identifiers := []helper.ManifestResourceIdentifier{}
+	for identifier := range ssa.recorded {
+		if _, ok := ssa.states[identifier]; ok || (complete && !resources[identifier]) {
+			continue
+		}
+		identifiers = append(identifiers, identifier)
+	}
+	for identifier, serverSide := range ssa.states {
+		if serverSide {
+			identifiers = append(identifiers, identifier)
+		}
+	}
+	ssa.lock.Unlock()
+	sort.Slice(identifiers, func(i, j int) bool {
+		a, b := identifiers[i], identifiers[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	existing, ok := appliedManifestWork.Annotations[constants.AppliedServerSideApplyResourcesAnnotationKey]
+	var value interface{}
+	if len(identifiers) > 0 {
+		data, err := json.Marshal(identifiers)
+		if err != nil {
+			return err
+		}
+		if ok && existing == string(data) {
+			return nil
+		}
+		value = string(data)
+	} else if !ok {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{constants.AppliedServerSideApplyResourcesAnnotationKey: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.appliedManifestWorkClient.Patch(ctx, appliedManifestWork.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,138 @@
+package manifestcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestMigrateFieldOwnership(t *testing.T) {
+	serverSideApply := `[{"ordinal": 0, "resourceIdentifier": {"version": "v1", "resource": "secrets", "namespace": "ns1", "name": "n1"},
+		"updateStrategy": {"type": "ServerSideApply"}}]`
+	recorded := `[{"group":"","version":"","resource":"secrets","namespace":"ns1","name":"n1"}]`
+
+	cases := []struct {
+		name                  string
+		configOptions         string
+		recorded              string
+		managedFields         []metav1.ManagedFieldsEntry
+		expectedManagedFields []metav1.ManagedFieldsEntry
+		expectedApply         bool
+		expectedAnnotation    string
+	}{
+		{
+			name:          "adopt the fields once changed to server side apply",
+			configOptions: serverSideApply,
+			managedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "work", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1"},
+			},
+			expectedManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: helper.ServerSideApplyFieldManager, Operation: metav1.ManagedFieldsOperationApply, APIVersion: "v1"},
+			},
+			expectedApply:      true,
+			expectedAnnotation: recorded,
+		},
+		{
+			name:     "release the fields once changed back to update",
+			recorded: recorded,
+			managedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1"},
+				{Manager: helper.ServerSideApplyFieldManager, Operation: metav1.ManagedFieldsOperationApply, APIVersion: "v1"},
+			},
+			expectedManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0, spoketesting.NewUnstructuredSecret("ns1", "n1", false, ""))
+			if len(c.configOptions) > 0 {
+				work.Annotations = map[string]string{constants.ManifestConfigOptionsAnnotationKey: c.configOptions}
+			}
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			appliedWork := spoketesting.NewAppliedManifestWork("", 0, "applied-uid")
+			if len(c.recorded) > 0 {
+				appliedWork.Annotations = map[string]string{constants.AppliedServerSideApplyResourcesAnnotationKey: c.recorded}
+			}
+			existing := spoketesting.NewUnstructured("v1", "Secret", "ns1", "n1")
+			existing.SetManagedFields(c.managedFields)
+			existing.SetResourceVersion("1")
+			controller := newController(work, appliedWork, spoketesting.NewFakeRestMapper()).
+				withKubeObject(spoketesting.NewSecret("n1", "ns1", "")).
+				withUnstructuredObject(existing)
+			controller.controller.updateFieldManager = "work"
+			if err := controller.workClient.Tracker().Add(appliedWork); err != nil {
+				t.Fatal(err)
+			}
+			// the fake dynamic client does not support server side apply
+			applied := false
+			controller.dynamicClient.PrependReactor("patch", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				if action.(clienttesting.PatchAction).GetPatchType() != types.ApplyPatchType {
+					return false, nil, nil
+				}
+				applied = true
+				return true, spoketesting.NewUnstructuredSecret("ns1", "n1", false, ""), nil
+			})
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+				t.Fatal(err)
+			}
+
+			var managedFields []metav1.ManagedFieldsEntry
+			for _, action := range controller.dynamicClient.Actions() {
+				patch, ok := action.(clienttesting.PatchAction)
+				if !ok || patch.GetPatchType() != types.JSONPatchType {
+					continue
+				}
+				operations := []struct {
+					Value json.RawMessage `json:"value"`
+				}{}
+				if err := json.Unmarshal(patch.GetPatch(), &operations); err != nil {
+					t.Fatal(err)
+				}
+				if err := json.Unmarshal(operations[1].Value, &managedFields); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(managedFields) != len(c.expectedManagedFields) {
+				t.Fatalf("expected managed fields %v, but got %v", c.expectedManagedFields, managedFields)
+			}
+			for i := range managedFields {
+				if managedFields[i].Manager != c.expectedManagedFields[i].Manager ||
+					managedFields[i].Operation != c.expectedManagedFields[i].Operation {
+					t.Errorf("expected managed fields %v, but got %v", c.expectedManagedFields, managedFields)
+				}
+			}
+			if applied != c.expectedApply {
+				t.Errorf("expected the manifest applied with server side apply %t, but got %t", c.expectedApply, applied)
+			}
+
+			workActions := controller.workClient.Actions()
+			updatedWork := lastUpdatedWork(t, workActions)
+			if !meta.IsStatusConditionTrue(updatedWork.Status.Conditions, workapiv1.WorkApplied) {
+				t.Errorf("expected the manifestwork applied, but got %v", updatedWork.Status.Conditions)
+			}
+			updatedAppliedWork, err := controller.workClient.WorkV1().AppliedManifestWorks().Get(
+				context.TODO(), appliedWork.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if annotation := updatedAppliedWork.Annotations[constants.AppliedServerSideApplyResourcesAnnotationKey]; annotation != c.expectedAnnotation {
+				t.Errorf("expected the server side apply resources %q, but got %q", c.expectedAnnotation, annotation)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package manifestcontroller
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/version"
+)
+
+// knownFields is the allowlist of the fields of a struct of the ManifestWork spec known to the agent, keyed by the
+// json names of the fields. A nil value means any field below the field is known, e.g. the raw manifests. The key
+// "*" is the allowlist of the items of a list.
+type knownFields map[string]knownFields
+
+// knownSpecFields are the fields of the ManifestWork spec supported by the agent. A field of a newer spec is
+// dropped when the manifestwork is decoded by the agent, so it is not in effect.
+var knownSpecFields = knownFields{
+	"workload": {
+		"manifests": nil,
+	},
+	"deleteOption": {
+		"propagationPolicy": nil,
+		"selectivelyOrphans": {
+			"orphaningRules": {
+				"*": {"group": nil, "resource": nil, "namespace": nil, "name": nil},
+			},
+		},
+	},
+}
+
+// supportedPropagationPolicies are the values of the delete propagation policy supported by the agent. ForeGround is
+// the default of the CRD, it is handled as Foreground.
+var supportedPropagationPolicies = map[workapiv1.DeletePropagationPolicyType]bool{
+	"ForeGround": true,
+	workapiv1.DeletePropagationPolicyTypeForeground:        true,
+	workapiv1.DeletePropagationPolicyTypeOrphan:            true,
+	workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan: true,
+}
+
+// unsupportedSpecFeatures returns the spec features of the manifestwork not supported by the agent, which are the
+// enum values unknown to the agent and the fields populated but unknown to the agent. The fields populated are read
+// from the managed fields of the manifestwork, since the unknown fields are dropped once it is decoded.
+func unsupportedSpecFeatures(manifestWork *workapiv1.ManifestWork) []string {
+	unsupported := map[string]bool{}
+
+	if deleteOption := manifestWork.Spec.DeleteOption; deleteOption != nil && len(deleteOption.PropagationPolicy) > 0 &&
+		!supportedPropagationPolicies[deleteOption.PropagationPolicy] {
+		unsupported[fmt.Sprintf("spec.deleteOption.propagationPolicy=%s", deleteOption.PropagationPolicy)] = true
+	}
+
+	for _, entry := range manifestWork.ManagedFields {
+		if entry.FieldsV1 == nil || len(entry.Subresource) > 0 {
+			continue
+		}
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			klog.V(4).Infof("Failed to decode the managed fields of manifestwork %s by %s: %v", manifestWork.Name, entry.Manager, err)
+			continue
+		}
+		if spec, ok := fields["f:spec"].(map[string]interface{}); ok {
+			unknownFields("spec", spec, knownSpecFields, unsupported)
+		}
+	}
+
+	features := []string{}
+	for feature := range unsupported {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	return features
+}
+
+// unknownFields adds the paths of the fields in the fieldsV1 set which are not in the allowlist into unknown.
+func unknownFields(path string, fields map[string]interface{}, known knownFields, unknown map[string]bool) {
+	for key, value := range fields {
+		var name, fieldPath string
+		switch {
+		case key == ".":
+			continue
+		case strings.HasPrefix(key, "f:"):
+			name = strings.TrimPrefix(key, "f:")
+			fieldPath = path + "." + name
+		default:
+			// the items of a list are keyed by their keys, indexes or values, e.g. k:{...}, i:0 or v:...
+			name = "*"
+			fieldPath = path + "[]"
+		}
+
+		knownField, ok := known[name]
+		if !ok {
+			unknown[fieldPath] = true
+			continue
+		}
+		if knownField == nil {
+			continue
+		}
+		if children, ok := value.(map[string]interface{}); ok {
+			unknownFields(fieldPath, children, knownField, unknown)
+		}
+	}
+}
+
+// withSpecFeaturesNotSupportedCondition returns a function updating the status with the updateStatusFunc, and setting
+// the condition SpecFeaturesNotSupported of the manifestwork if any spec feature is not supported by the agent, or
+// removing the condition otherwise.
+func withSpecFeaturesNotSupportedCondition(
+	updateStatusFunc helper.UpdateManifestWorkStatusFunc, generation int64, unsupported []string) helper.UpdateManifestWorkStatusFunc {
+	return func(oldStatus *workapiv1.ManifestWorkStatus) error {
+		if err := updateStatusFunc(oldStatus); err != nil {
+			return err
+		}
+		if len(unsupported) == 0 {
+			meta.RemoveStatusCondition(&oldStatus.Conditions, constants.WorkSpecFeaturesNotSupported)
+			return nil
+		}
+
+		agentVersion := version.Get().GitVersion
+		if len(agentVersion) == 0 {
+			agentVersion = "unknown"
+		}
+		oldStatus.Conditions = helper.MergeStatusConditions(oldStatus.Conditions, []metav1.Condition{{
+			Type:               constants.WorkSpecFeaturesNotSupported,
+			Status:             metav1.ConditionTrue,
+			Reason:             "SpecFeaturesNotSupported",
+			ObservedGeneration: generation,
+			Message: fmt.Sprintf("The spec features %s are not supported by the agent of version %s, they are ignored "+
+				"while the rest of the manifestwork is applied", strings.Join(unsupported, ", "), agentVersion),
+		}})
+		return nil
+	}
+}
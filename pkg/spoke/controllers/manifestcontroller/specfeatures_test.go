@@ -0,0 +1,108 @@
+package manifestcontroller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+func newManagedFields(manager, subresource, fieldsV1 string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:     manager,
+		Operation:   metav1.ManagedFieldsOperationUpdate,
+		FieldsType:  "FieldsV1",
+		FieldsV1:    &metav1.FieldsV1{Raw: []byte(fieldsV1)},
+		Subresource: subresource,
+	}
+}
+
+func TestUnsupportedSpecFeatures(t *testing.T) {
+	knownSpec := `{"f:spec":{".":{},"f:workload":{"f:manifests":{}},"f:deleteOption":{"f:propagationPolicy":{},` +
+		`"f:selectivelyOrphans":{"f:orphaningRules":{"k:{\"group\":\"\",\"name\":\"n1\"}":{".":{},"f:group":{},"f:name":{}}}}}}}`
+
+	cases := []struct {
+		name          string
+		deleteOption  *workapiv1.DeleteOption
+		managedFields []metav1.ManagedFieldsEntry
+		expected      []string
+	}{
+		{
+			name: "no managed fields",
+		},
+		{
+			name:          "known fields",
+			deleteOption:  &workapiv1.DeleteOption{PropagationPolicy: "ForeGround"},
+			managedFields: []metav1.ManagedFieldsEntry{newManagedFields("hub", "", knownSpec)},
+		},
+		{
+			name:         "future enum value",
+			deleteOption: &workapiv1.DeleteOption{PropagationPolicy: "OrphanAfterTimeout"},
+			expected:     []string{"spec.deleteOption.propagationPolicy=OrphanAfterTimeout"},
+		},
+		{
+			name: "future fields",
+			managedFields: []metav1.ManagedFieldsEntry{
+				newManagedFields("hub", "", knownSpec),
+				newManagedFields("controller", "",
+					`{"f:metadata":{"f:labels":{}},"f:spec":{"f:manifestConfigs":{},"f:deleteOption":{"f:ttlSecondsAfterDeletion":{},`+
+						`"f:selectivelyOrphans":{"f:orphaningRules":{"k:{\"group\":\"\",\"name\":\"n1\"}":{"f:condition":{}}}}}}}`),
+				// the fields of the status are set by the agent
+				newManagedFields("agent", "status", `{"f:status":{"f:conditions":{}}}`),
+			},
+			expected: []string{
+				"spec.deleteOption.selectivelyOrphans.orphaningRules[].condition",
+				"spec.deleteOption.ttlSecondsAfterDeletion",
+				"spec.manifestConfigs",
+			},
+		},
+		{
+			name:          "invalid managed fields",
+			managedFields: []metav1.ManagedFieldsEntry{newManagedFields("hub", "", "invalid")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work := &workapiv1.ManifestWork{
+				ObjectMeta: metav1.ObjectMeta{Name: "work", ManagedFields: c.managedFields},
+				Spec:       workapiv1.ManifestWorkSpec{DeleteOption: c.deleteOption},
+			}
+			if c.expected == nil {
+				c.expected = []string{}
+			}
+			if actual := unsupportedSpecFeatures(work); !reflect.DeepEqual(actual, c.expected) {
+				t.Errorf("expected unsupported features %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSpecFeaturesNotSupportedCondition(t *testing.T) {
+	noop := func(status *workapiv1.ManifestWorkStatus) error { return nil }
+	status := &workapiv1.ManifestWorkStatus{}
+
+	updateStatusFunc := withSpecFeaturesNotSupportedCondition(noop, 2, []string{"spec.manifestConfigs", "spec.updateStrategy"})
+	if err := updateStatusFunc(status); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(status.Conditions, constants.WorkSpecFeaturesNotSupported)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.ObservedGeneration != 2 ||
+		!strings.Contains(condition.Message, "spec.manifestConfigs, spec.updateStrategy") ||
+		!strings.Contains(condition.Message, "agent of version unknown") {
+		t.Errorf("unexpected condition %v", condition)
+	}
+
+	// the condition is removed once all the features are supported
+	updateStatusFunc = withSpecFeaturesNotSupportedCondition(noop, 3, nil)
+	if err := updateStatusFunc(status); err != nil {
+		t.Fatal(err)
+	}
+	if condition := meta.FindStatusCondition(status.Conditions, constants.WorkSpecFeaturesNotSupported); condition != nil {
+		t.Errorf("expected the condition removed, but got %v", condition)
+	}
+}
@@ -0,0 +1,134 @@
+package manifestcontroller
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+var startupThrottledWorks = metrics.NewGauge(
+	&metrics.GaugeOpts{
+		Name: "work_agent_startup_throttled_works",
+		Help: "Number of ManifestWorks waiting for their first apply since the agent started.",
+	},
+)
+
+func init() {
+	legacyregistry.MustRegister(startupThrottledWorks)
+}
+
+// startupThrottle limits the rate of the first reconcile of each manifestwork since the agent started, so that the
+// spoke apiserver is not slammed by the manifestworks enqueued all at once by the initial informer sync. The
+// manifestworks which are applied already and not changed since then skip the first reconcile without consuming
+// tokens, their resources are still reconciled on the next resync.
+type startupThrottle struct {
+	lock    sync.Mutex
+	limiter flowcontrol.RateLimiter
+	// requeueAfter is the interval to requeue a throttled manifestwork, it is about the time to refill a token.
+	requeueAfter time.Duration
+	// reconciled are the manifestworks which have had their first reconcile since the agent started.
+	reconciled sets.String
+	// throttled are the manifestworks waiting for a token for their first reconcile.
+	throttled sets.String
+}
+
+// newStartupThrottle returns a startupThrottle, nil is returned if qps is not positive.
+func newStartupThrottle(qps float32, burst int, clock flowcontrol.Clock) *startupThrottle {
+	if qps <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &startupThrottle{
+		limiter:      flowcontrol.NewTokenBucketRateLimiterWithClock(qps, burst, clock),
+		requeueAfter: time.Duration(float64(time.Second) / float64(qps)),
+		reconciled:   sets.NewString(),
+		throttled:    sets.NewString(),
+	}
+}
+
+// enabled returns if the throttle is enabled, it is disabled if the startup apply qps is not positive.
+func (t *startupThrottle) enabled() bool {
+	return t != nil
+}
+
+// isFirstReconcile checks if the manifestwork has not been reconciled since the agent started.
+func (t *startupThrottle) isFirstReconcile(name string) bool {
+	if t == nil {
+		return false
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return !t.reconciled.Has(name)
+}
+
+// admit returns true if the first reconcile of the manifestwork is allowed. A manifestwork which is not changed
+// since it was applied is always admitted without consuming tokens.
+func (t *startupThrottle) admit(name string, unchanged bool) bool {
+	if t == nil {
+		return true
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	defer func() { startupThrottledWorks.Set(float64(t.throttled.Len())) }()
+
+	if t.reconciled.Has(name) || unchanged || t.limiter.TryAccept() {
+		t.reconciled.Insert(name)
+		t.throttled.Delete(name)
+		return true
+	}
+
+	t.throttled.Insert(name)
+	return false
+}
+
+// appliedSpecHash returns the hash of the spec and annotations of the manifestwork, it is recorded on the
+// appliedmanifestwork once all the manifests are applied. The manifests are sanitized before hashing, so that
+// the changes of the status or the server only metadata in the manifests are not taken as changes of the spec. The
+// apply stats recorded by the agent are not hashed either.
+func appliedSpecHash(manifestWork *workapiv1.ManifestWork) (string, error) {
+	spec := *manifestWork.Spec.DeepCopy()
+	spec.Workload.Manifests = sanitizedManifests(manifestWork)
+	var annotations map[string]string
+	if manifestWork.Annotations != nil {
+		annotations = map[string]string{}
+	}
+	for key, value := range manifestWork.Annotations {
+		if key == constants.LastAppliedTimeAnnotationKey || key == constants.LastApplyDurationAnnotationKey {
+			continue
+		}
+		annotations[key] = value
+	}
+	data, err := json.Marshal(struct {
+		Spec        workapiv1.ManifestWorkSpec `json:"spec"`
+		Annotations map[string]string          `json:"annotations"`
+	}{Spec: spec, Annotations: annotations})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// isUnchangedSinceApplied checks if the spec hash of the manifestwork matches the one recorded on the
+// appliedmanifestwork.
+func isUnchangedSinceApplied(manifestWork *workapiv1.ManifestWork, appliedManifestWork *workapiv1.AppliedManifestWork) bool {
+	recorded, ok := appliedManifestWork.Annotations[constants.AppliedSpecHashAnnotationKey]
+	if !ok {
+		return false
+	}
+	hash, err := appliedSpecHash(manifestWork)
+	return err == nil && hash == recorded
+}
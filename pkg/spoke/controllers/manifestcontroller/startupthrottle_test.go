@@ -0,0 +1,178 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestStartupThrottle(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	throttle := newStartupThrottle(2, 2, fakeClock)
+
+	// a backlog of works enqueued all at once by the initial informer sync
+	var backlog []string
+	for i := 0; i < 10; i++ {
+		backlog = append(backlog, fmt.Sprintf("work-%d", i))
+	}
+
+	admitBacklog := func() []string {
+		var admitted, pending []string
+		for _, name := range backlog {
+			if throttle.admit(name, false) {
+				admitted = append(admitted, name)
+			} else {
+				pending = append(pending, name)
+			}
+		}
+		backlog = pending
+		return admitted
+	}
+
+	if admitted := admitBacklog(); len(admitted) != 2 {
+		t.Errorf("expected the burst of works admitted, but got %v", admitted)
+	}
+	if admitted := admitBacklog(); len(admitted) != 0 {
+		t.Errorf("expected no work admitted before tokens are refilled, but got %v", admitted)
+	}
+
+	// unchanged works do not consume tokens
+	if !throttle.admit("unchanged", true) {
+		t.Errorf("expected unchanged work admitted")
+	}
+
+	fakeClock.Step(time.Second)
+	if admitted := admitBacklog(); len(admitted) != 2 {
+		t.Errorf("expected 2 works admitted in a second, but got %v", admitted)
+	}
+	if throttle.throttled.Len() != 6 {
+		t.Errorf("expected 6 throttled works, but got %v", throttle.throttled.List())
+	}
+
+	fakeClock.Step(3 * time.Second)
+	if admitted := admitBacklog(); len(admitted) != 2 {
+		t.Errorf("expected no more than the burst admitted, but got %v", admitted)
+	}
+
+	// works reconciled once are not throttled any more
+	if throttle.isFirstReconcile("work-0") || !throttle.admit("work-0", false) {
+		t.Errorf("expected work-0 not throttled after its first reconcile")
+	}
+	if !throttle.isFirstReconcile(backlog[0]) {
+		t.Errorf("expected %s waiting for its first reconcile", backlog[0])
+	}
+}
+
+func TestSyncWithStartupThrottle(t *testing.T) {
+	cases := []struct {
+		name           string
+		recordedHash   func(work *workapiv1.ManifestWork) string
+		expectedQueued bool
+	}{
+		{
+			name:           "throttle changed work",
+			recordedHash:   func(work *workapiv1.ManifestWork) string { return "stale" },
+			expectedQueued: true,
+		},
+		{
+			name: "skip unchanged work",
+			recordedHash: func(work *workapiv1.ManifestWork) string {
+				hash, _ := appliedSpecHash(work)
+				return hash
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0, spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"))
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			appliedWork := spoketesting.NewAppliedManifestWork("", 0, "applied-uid")
+			appliedWork.Annotations = map[string]string{constants.AppliedSpecHashAnnotationKey: c.recordedHash(work)}
+			controller := newController(work, appliedWork, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+			// no token is left
+			controller.controller.startupThrottle = newStartupThrottle(1, 1, clock.NewFakeClock(time.Now()))
+			controller.controller.startupThrottle.admit("other", false)
+			// requeue the throttled work right away to check the queue
+			controller.controller.startupThrottle.requeueAfter = 0
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(controller.kubeClient.Actions()) != 0 || len(controller.workClient.Actions()) != 0 {
+				t.Errorf("expected no apply, but got %v, %v", controller.kubeClient.Actions(), controller.workClient.Actions())
+			}
+			if queued := syncContext.Queue().Len() != 0; queued != c.expectedQueued {
+				t.Errorf("expected queued %t, but got %d", c.expectedQueued, syncContext.Queue().Len())
+			}
+		})
+	}
+}
+
+func TestRecordAppliedSpecHash(t *testing.T) {
+	cases := []struct {
+		name            string
+		throttle        *startupThrottle
+		recordedHash    func(work *workapiv1.ManifestWork) string
+		expectedPatched bool
+	}{
+		{
+			name:         "throttle disabled",
+			recordedHash: func(work *workapiv1.ManifestWork) string { return "stale" },
+		},
+		{
+			name:            "hash changed",
+			throttle:        newStartupThrottle(100, 100, clock.NewFakeClock(time.Now())),
+			recordedHash:    func(work *workapiv1.ManifestWork) string { return "stale" },
+			expectedPatched: true,
+		},
+		{
+			name:     "hash unchanged",
+			throttle: newStartupThrottle(100, 100, clock.NewFakeClock(time.Now())),
+			recordedHash: func(work *workapiv1.ManifestWork) string {
+				hash, _ := appliedSpecHash(work)
+				return hash
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0, spoketesting.NewUnstructured("v1", "Secret", "ns1", "test"))
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			appliedWork := spoketesting.NewAppliedManifestWork("", 0, "applied-uid")
+			appliedWork.Name = "-" + work.Name
+			appliedWork.Spec.ManifestWorkName = work.Name
+			appliedWork.Annotations = map[string]string{constants.AppliedSpecHashAnnotationKey: c.recordedHash(work)}
+			controller := newController(work, appliedWork, spoketesting.NewFakeRestMapper()).withKubeObject().withUnstructuredObject()
+			if err := controller.workClient.Tracker().Add(appliedWork); err != nil {
+				t.Fatal(err)
+			}
+			controller.controller.startupThrottle = c.throttle
+			// the first reconcile is done, so that the unchanged work is applied as well
+			c.throttle.admit(work.Name, false)
+
+			if err := controller.controller.sync(context.TODO(), spoketesting.NewFakeSyncContext(t, workKey)); err != nil {
+				t.Fatal(err)
+			}
+
+			patched := false
+			for _, action := range controller.workClient.Actions() {
+				if action.GetVerb() == "patch" && action.GetResource().Resource == "appliedmanifestworks" {
+					patched = true
+				}
+			}
+			if patched != c.expectedPatched {
+				t.Errorf("expected patched %t, but got %v", c.expectedPatched, controller.workClient.Actions())
+			}
+		})
+	}
+}
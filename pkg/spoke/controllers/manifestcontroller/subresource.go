@@ -0,0 +1,103 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"open-cluster-management.io/work/pkg/applier"
+	"open-cluster-management.io/work/pkg/helper"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+// applySubresource applies the manifest to the subresource of the existing resource. The resource must exist
+// already, it is neither created nor owned by the manifestwork. Only the replicas of the manifest are applied for
+// the scale subresource, and only the status of the manifest is applied for the status subresource.
+func (m *ManifestWorkController) applySubresource(
+	ctx context.Context,
+	data []byte,
+	gvr schema.GroupVersionResource,
+	subresource string,
+	recorder events.Recorder) (*unstructured.Unstructured, bool, error) {
+	required, err := applier.Decode(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch subresource {
+	case helper.SubresourceScale:
+		return m.applyScale(ctx, required, gvr, recorder)
+	case helper.SubresourceStatus:
+		return m.applyStatus(ctx, required, gvr, recorder)
+	}
+	return nil, false, applyerrors.NewValidationFailedError(gvr, required.GetNamespace(), required.GetName(),
+		errors.NewBadRequest(fmt.Sprintf("subresource %q is not supported", subresource)))
+}
+
+func (m *ManifestWorkController) applyScale(
+	ctx context.Context,
+	required *unstructured.Unstructured,
+	gvr schema.GroupVersionResource,
+	recorder events.Recorder) (*unstructured.Unstructured, bool, error) {
+	replicas, found, err := unstructured.NestedInt64(required.Object, "spec", "replicas")
+	if err != nil || !found {
+		return nil, false, applyerrors.NewValidationFailedError(gvr, required.GetNamespace(), required.GetName(), errors.NewBadRequest(fmt.Sprintf(
+			"spec.replicas of %s %s/%s must be set to apply the scale subresource", required.GetKind(), required.GetNamespace(), required.GetName())))
+	}
+
+	client := m.spokeDynamicClient.Resource(gvr).Namespace(required.GetNamespace())
+	scale, err := client.Get(ctx, required.GetName(), metav1.GetOptions{}, helper.SubresourceScale)
+	if err != nil {
+		return nil, false, err
+	}
+
+	existingReplicas, _, _ := unstructured.NestedInt64(scale.Object, "spec", "replicas")
+	if existingReplicas == replicas {
+		return scale, false, nil
+	}
+
+	if err := unstructured.SetNestedField(scale.Object, replicas, "spec", "replicas"); err != nil {
+		return nil, false, err
+	}
+	actual, err := client.Update(ctx, scale, metav1.UpdateOptions{}, helper.SubresourceScale)
+	recorder.Eventf(fmt.Sprintf("%s Scaled", required.GetKind()),
+		"Scaled %s/%s from %d to %d replicas", required.GetNamespace(), required.GetName(), existingReplicas, replicas)
+	return actual, true, err
+}
+
+func (m *ManifestWorkController) applyStatus(
+	ctx context.Context,
+	required *unstructured.Unstructured,
+	gvr schema.GroupVersionResource,
+	recorder events.Recorder) (*unstructured.Unstructured, bool, error) {
+	requiredStatus, found, err := unstructured.NestedMap(required.Object, "status")
+	if err != nil || !found {
+		return nil, false, applyerrors.NewValidationFailedError(gvr, required.GetNamespace(), required.GetName(), errors.NewBadRequest(fmt.Sprintf(
+			"status of %s %s/%s must be set to apply the status subresource", required.GetKind(), required.GetNamespace(), required.GetName())))
+	}
+
+	client := m.spokeDynamicClient.Resource(gvr).Namespace(required.GetNamespace())
+	existing, err := client.Get(ctx, required.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+
+	existingStatus, _, _ := unstructured.NestedMap(existing.Object, "status")
+	if equality.Semantic.DeepEqual(existingStatus, requiredStatus) {
+		return existing, false, nil
+	}
+
+	existing = existing.DeepCopy()
+	if err := unstructured.SetNestedMap(existing.Object, requiredStatus, "status"); err != nil {
+		return nil, false, err
+	}
+	actual, err := client.UpdateStatus(ctx, existing, metav1.UpdateOptions{})
+	recorder.Eventf(fmt.Sprintf("%s Status Updated", required.GetKind()),
+		"Updated status of %s/%s", required.GetNamespace(), required.GetName())
+	return actual, true, err
+}
@@ -0,0 +1,153 @@
+package manifestcontroller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestApplySubresource(t *testing.T) {
+	newObject := func(content map[string]interface{}) *unstructured.Unstructured {
+		return spoketesting.NewUnstructuredWithContent("v1", "NewObject", "ns1", "n1", content)
+	}
+	newScale := func(replicas int64) *unstructured.Unstructured {
+		return spoketesting.NewUnstructuredWithContent("autoscaling/v1", "Scale", "ns1", "n1",
+			map[string]interface{}{"spec": map[string]interface{}{"replicas": replicas}})
+	}
+
+	cases := []struct {
+		name                  string
+		subresources          string
+		manifest              *unstructured.Unstructured
+		existingObjects       []runtime.Object
+		existingScale         *unstructured.Unstructured
+		expectedActions       []string
+		expectedAppliedStatus metav1.ConditionStatus
+		expectedSyncErr       bool
+		validateActions       func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:                  "scale existing resource",
+			subresources:          `[{"ordinal": 0, "subresource": "scale"}]`,
+			manifest:              newObject(map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(3)}}),
+			existingScale:         newScale(1),
+			expectedActions:       []string{"get", "update"},
+			expectedAppliedStatus: metav1.ConditionTrue,
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				update := actions[1].(clienttesting.UpdateAction)
+				if update.GetSubresource() != "scale" {
+					t.Errorf("expected update of scale subresource, but got %q", update.GetSubresource())
+				}
+				replicas, _, _ := unstructured.NestedInt64(update.GetObject().(*unstructured.Unstructured).Object, "spec", "replicas")
+				if replicas != 3 {
+					t.Errorf("expected 3 replicas, but got %d", replicas)
+				}
+			},
+		},
+		{
+			name:                  "skip scaling with the same replicas",
+			subresources:          `[{"ordinal": 0, "subresource": "scale"}]`,
+			manifest:              newObject(map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(3)}}),
+			existingScale:         newScale(3),
+			expectedActions:       []string{"get"},
+			expectedAppliedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                  "scale without replicas",
+			subresources:          `[{"ordinal": 0, "subresource": "scale"}]`,
+			manifest:              newObject(map[string]interface{}{"spec": map[string]interface{}{}}),
+			expectedAppliedStatus: metav1.ConditionFalse,
+		},
+		{
+			name:                  "update status of existing resource",
+			subresources:          `[{"ordinal": 0, "subresource": "status"}]`,
+			manifest:              newObject(map[string]interface{}{"status": map[string]interface{}{"phase": "Ready"}}),
+			existingObjects:       []runtime.Object{newObject(map[string]interface{}{"spec": map[string]interface{}{"key1": "val1"}})},
+			expectedActions:       []string{"get", "update"},
+			expectedAppliedStatus: metav1.ConditionTrue,
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				update := actions[1].(clienttesting.UpdateAction)
+				if update.GetSubresource() != "status" {
+					t.Errorf("expected update of status subresource, but got %q", update.GetSubresource())
+				}
+				obj := update.GetObject().(*unstructured.Unstructured)
+				if len(obj.GetOwnerReferences()) != 0 {
+					t.Errorf("expected resource not owned, but got %v", obj.GetOwnerReferences())
+				}
+				if phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase"); phase != "Ready" {
+					t.Errorf("expected status applied, but got %v", obj.Object)
+				}
+				if val, _, _ := unstructured.NestedString(obj.Object, "spec", "key1"); val != "val1" {
+					t.Errorf("expected spec kept, but got %v", obj.Object)
+				}
+			},
+		},
+		{
+			name:                  "update status of missing resource",
+			subresources:          `[{"ordinal": 0, "subresource": "status"}]`,
+			manifest:              newObject(map[string]interface{}{"status": map[string]interface{}{"phase": "Ready"}}),
+			expectedActions:       []string{"get"},
+			expectedAppliedStatus: metav1.ConditionFalse,
+			// the resource may be created later, so it is retried
+			expectedSyncErr: true,
+		},
+		{
+			name:                  "unsupported subresource",
+			subresources:          `[{"ordinal": 0, "subresource": "exec"}]`,
+			manifest:              newObject(map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(3)}}),
+			expectedAppliedStatus: metav1.ConditionFalse,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0, c.manifest)
+			work.Annotations = map[string]string{constants.ManifestSubresourcesAnnotationKey: c.subresources}
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			controller := newController(work, nil, spoketesting.NewFakeRestMapper()).
+				withKubeObject().
+				withUnstructuredObject(c.existingObjects...)
+			if c.existingScale != nil {
+				controller.dynamicClient.PrependReactor("get", "newobjects", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					if action.GetSubresource() != "scale" {
+						return false, nil, nil
+					}
+					return true, c.existingScale, nil
+				})
+				controller.dynamicClient.PrependReactor("update", "newobjects", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, action.(clienttesting.UpdateAction).GetObject(), nil
+				})
+			}
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			if err := controller.controller.sync(context.TODO(), syncContext); (err != nil) != c.expectedSyncErr {
+				t.Fatalf("expected sync error %t, but got %v", c.expectedSyncErr, err)
+			}
+
+			actions := controller.dynamicClient.Actions()
+			if len(actions) != len(c.expectedActions) {
+				t.Fatalf("expected actions %v, but got %v", c.expectedActions, actions)
+			}
+			for i, verb := range c.expectedActions {
+				spoketesting.AssertAction(t, actions[i], verb)
+			}
+			if c.validateActions != nil {
+				c.validateActions(t, actions)
+			}
+
+			workActions := controller.workClient.Actions()
+			updatedWork := lastUpdatedWork(t, workActions)
+			if !meta.IsStatusConditionPresentAndEqual(updatedWork.Status.Conditions, workapiv1.WorkApplied, c.expectedAppliedStatus) {
+				t.Errorf("expected Applied condition %s, but got %v", c.expectedAppliedStatus, updatedWork.Status.Conditions)
+			}
+		})
+	}
+}
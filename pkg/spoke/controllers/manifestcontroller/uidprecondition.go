@@ -0,0 +1,84 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	applyerrors "open-cluster-management.io/work/pkg/spoke/apply/errors"
+)
+
+// appliedResourceKey identifies an applied resource regardless of its version, since resources of the same
+// group/resource but different versions are treated as the same resource.
+type appliedResourceKey struct {
+	group, resource, namespace, name string
+}
+
+// recordedUIDs returns the uids of the applied resources recorded in the appliedmanifestwork.
+func recordedUIDs(appliedManifestWork *workapiv1.AppliedManifestWork) map[appliedResourceKey]types.UID {
+	uids := map[appliedResourceKey]types.UID{}
+	for _, resource := range appliedManifestWork.Status.AppliedResources {
+		if len(resource.UID) == 0 {
+			continue
+		}
+		key := appliedResourceKey{group: resource.Group, resource: resource.Resource, namespace: resource.Namespace, name: resource.Name}
+		uids[key] = types.UID(resource.UID)
+	}
+	return uids
+}
+
+// adoptionUID returns the uid of the existing resource which the apply is expected to update. If the uid differs
+// from the one recorded in the appliedmanifestwork, the resource was deleted and recreated by someone else since
+// it was applied. The recreated resource is adopted as a new resource, which is what the agent does for any
+// existing resource, and an event is recorded instead of updating it silently. An empty uid is returned if the
+// resource was never recorded or does not exist.
+func (m *ManifestWorkController) adoptionUID(
+	ctx context.Context,
+	gvr schema.GroupVersionResource,
+	resMeta workapiv1.ManifestResourceMeta,
+	recordedUID types.UID,
+	recorder events.Recorder) (types.UID, error) {
+	if len(recordedUID) == 0 {
+		return "", nil
+	}
+
+	existing, err := m.getLiveObject(ctx, gvr, resMeta.Namespace, resMeta.Name)
+	switch {
+	case errors.IsNotFound(err):
+		return "", nil
+	case err != nil:
+		return "", err
+	}
+
+	if existing.GetUID() != recordedUID {
+		recorder.Warningf("ResourceRecreated",
+			"%s %s/%s was recreated with uid %s after being applied with uid %s, adopting it as a new resource",
+			resMeta.Kind, resMeta.Namespace, resMeta.Name, existing.GetUID(), recordedUID)
+	}
+	return existing.GetUID(), nil
+}
+
+// verifyAppliedUID checks that the resource returned by the apply is the one the adoption decision was made for.
+// A conflict error is returned if the resource was recreated during the apply, so that the manifest is applied
+// again with a new adoption decision.
+func verifyAppliedUID(gvr schema.GroupVersionResource, expectedUID types.UID, obj runtime.Object) error {
+	if len(expectedUID) == 0 || obj == nil {
+		return nil
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil
+	}
+	if len(accessor.GetUID()) == 0 || accessor.GetUID() == expectedUID {
+		return nil
+	}
+	return applyerrors.NewResourceConflictError(gvr, accessor.GetNamespace(), accessor.GetName(), errors.NewConflict(gvr.GroupResource(), accessor.GetName(),
+		fmt.Errorf("the resource was recreated during apply, expected uid %s but got %s", expectedUID, accessor.GetUID())))
+}
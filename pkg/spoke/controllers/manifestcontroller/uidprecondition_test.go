@@ -0,0 +1,109 @@
+package manifestcontroller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clienttesting "k8s.io/client-go/testing"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestApplyRecreatedResource(t *testing.T) {
+	newObject := func(uid, value string) *unstructured.Unstructured {
+		obj := spoketesting.NewUnstructuredWithContent(
+			"v1", "NewObject", "ns1", "n1", map[string]interface{}{"spec": map[string]interface{}{"key1": value}})
+		obj.SetUID(types.UID(uid))
+		return obj
+	}
+
+	cases := []struct {
+		name string
+		// uids of the resource returned by the successive gets, it simulates the resource is recreated between gets
+		getUIDs            []string
+		expectedUpdateUIDs []types.UID
+	}{
+		{
+			name:               "update the resource with the recorded uid",
+			getUIDs:            []string{"uid1"},
+			expectedUpdateUIDs: []types.UID{"uid1"},
+		},
+		{
+			name:               "adopt the resource recreated after apply",
+			getUIDs:            []string{"uid2"},
+			expectedUpdateUIDs: []types.UID{"uid2"},
+		},
+		{
+			name:               "reapply the resource recreated during apply",
+			getUIDs:            []string{"uid2", "uid3"},
+			expectedUpdateUIDs: []types.UID{"uid2", "uid3"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, workKey := spoketesting.NewManifestWork(0, newObject("", "val1"))
+			work.Finalizers = []string{constants.ManifestWorkFinalizer}
+			appliedWork := &workapiv1.AppliedManifestWork{
+				ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("-%s", work.Name)},
+				Spec:       workapiv1.AppliedManifestWorkSpec{ManifestWorkName: work.Name},
+				Status: workapiv1.AppliedManifestWorkStatus{
+					AppliedResources: []workapiv1.AppliedManifestResourceMeta{
+						{Version: "v1", Resource: "newobjects", Namespace: "ns1", Name: "n1", UID: "uid1"},
+					},
+				},
+			}
+			controller := newController(work, appliedWork, spoketesting.NewFakeRestMapper()).
+				withKubeObject().
+				withUnstructuredObject()
+
+			// the resource on the spoke is recreated with a new uid on each get until the last uid
+			gets := 0
+			controller.dynamicClient.PrependReactor("get", "newobjects", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				uid := c.getUIDs[len(c.getUIDs)-1]
+				if gets < len(c.getUIDs) {
+					uid = c.getUIDs[gets]
+				}
+				gets++
+				return true, newObject(uid, "val2"), nil
+			})
+			// the uid of the update works as a precondition
+			controller.dynamicClient.PrependReactor("update", "newobjects", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				obj := action.(clienttesting.UpdateAction).GetObject().(*unstructured.Unstructured)
+				current := c.getUIDs[len(c.getUIDs)-1]
+				if gets <= len(c.getUIDs) {
+					current = c.getUIDs[gets-1]
+				}
+				if string(obj.GetUID()) != current {
+					return true, nil, errors.NewConflict(
+						schema.GroupResource{Resource: "newobjects"}, obj.GetName(), fmt.Errorf("uid precondition failed"))
+				}
+				return true, obj, nil
+			})
+
+			syncContext := spoketesting.NewFakeSyncContext(t, workKey)
+			if err := controller.controller.sync(context.TODO(), syncContext); err != nil {
+				t.Fatal(err)
+			}
+
+			var updateUIDs []types.UID
+			for _, action := range controller.dynamicClient.Actions() {
+				if action.GetVerb() == "update" {
+					updateUIDs = append(updateUIDs, action.(clienttesting.UpdateAction).GetObject().(*unstructured.Unstructured).GetUID())
+				}
+			}
+			if !reflect.DeepEqual(updateUIDs, c.expectedUpdateUIDs) {
+				t.Errorf("expected updates with uids %v, but got %v", c.expectedUpdateUIDs, updateUIDs)
+			}
+		})
+	}
+}
@@ -11,17 +11,30 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
 	workinformer "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
 	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
 	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
 	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/controllers"
+)
+
+const (
+	// staleCacheReason is the reason of the Available condition evaluated against a stale cache.
+	staleCacheReason = "StaleCache"
+	// resourcesDriftedReason is the reason of the Degraded condition of a manifestwork whose resources are modified
+	// out of band.
+	resourcesDriftedReason = "ResourcesDrifted"
+	// resourceDriftedReason is the reason of the Degraded condition of a manifest whose resource is modified out of
+	// band.
+	resourceDriftedReason = "ResourceDrifted"
 )
 
 // ControllerSyncInterval is exposed so that integration tests can crank up the controller resync speed.
@@ -31,7 +44,53 @@ var ControllerReSyncInterval = 30 * time.Second
 type AvailableStatusController struct {
 	manifestWorkClient workv1client.ManifestWorkInterface
 	manifestWorkLister worklister.ManifestWorkNamespaceLister
-	spokeDynamicClient dynamic.Interface
+	// manifestWorkStore is the store of the manifestwork informer, the manifestworks in it are requeued one by one on
+	// resync, so that only the manifestworks synced are fetched by a lister fetching them on demand.
+	manifestWorkStore         cache.Store
+	appliedManifestWorkClient workv1client.AppliedManifestWorkInterface
+	appliedManifestWorkLister worklister.AppliedManifestWorkLister
+	spokeDynamicClient        dynamic.Interface
+	restMapper                meta.RESTMapper
+	hubHash                   string
+	// cacheFreshness tracks the cache of manifestworks, the availability evaluated against a cache which is not
+	// in sync for longer than staleCacheThreshold is reported as Unknown instead of False.
+	cacheFreshness      *helper.CacheFreshness
+	staleCacheThreshold time.Duration
+	// driftTracker detects the applied resources modified out of band, which are reported with the condition Degraded.
+	driftTracker *helper.DriftTracker
+	// resourceCache serves the lookups of the applied resources from informers, the resources are fetched from the
+	// spoke apiserver if it is nil.
+	resourceCache *helper.ResourceCache
+	// liveObjects serves the lookups of the applied resources if the resource cache is nil, it is shared with the
+	// manifest controller.
+	liveObjects *helper.LiveObjectCache
+	// notFoundGracePeriod is the time since a manifest is applied during which a resource not found in the resource
+	// cache is looked up from the spoke apiserver as well, since the informer may not have caught up with it. It is
+	// disabled if it is not positive.
+	notFoundGracePeriod time.Duration
+	// statusWriter writes the status to the hub asynchronously together with the status updates of the manifest
+	// controller, the status is written in the reconcile if it is nil.
+	statusWriter *helper.StatusWriter
+}
+
+// AvailableStatusControllerOptions are the optional settings of the AvailableStatusController.
+type AvailableStatusControllerOptions struct {
+	// CacheFreshness tracks the cache of manifestworks, the availability evaluated against a cache which is not in
+	// sync for longer than StaleCacheThreshold is reported as Unknown instead of False. It is not tracked if nil.
+	CacheFreshness      *helper.CacheFreshness
+	StaleCacheThreshold time.Duration
+	// DriftTracker detects the applied resources modified out of band if it is not nil.
+	DriftTracker *helper.DriftTracker
+	// ResourceCache serves the lookups of the applied resources from informers if it is not nil.
+	ResourceCache *helper.ResourceCache
+	// LiveObjects serves the lookups of the applied resources if ResourceCache is nil, it is shared with the
+	// manifest controller.
+	LiveObjects *helper.LiveObjectCache
+	// NotFoundGracePeriod is the time since a manifest is applied during which a resource not found in the
+	// ResourceCache is looked up from the spoke apiserver as well, it is disabled if it is not positive.
+	NotFoundGracePeriod time.Duration
+	// StatusWriter writes the status to the hub if it is not nil, so that the reconcile is not blocked by the hub.
+	StatusWriter *helper.StatusWriter
 }
 
 // NewAvailableStatusController returns a AvailableStatusController
@@ -41,11 +100,28 @@ func NewAvailableStatusController(
 	manifestWorkClient workv1client.ManifestWorkInterface,
 	manifestWorkInformer workinformer.ManifestWorkInformer,
 	manifestWorkLister worklister.ManifestWorkNamespaceLister,
+	appliedManifestWorkClient workv1client.AppliedManifestWorkInterface,
+	appliedManifestWorkInformer workinformer.AppliedManifestWorkInformer,
+	hubHash string,
+	restMapper meta.RESTMapper,
+	options AvailableStatusControllerOptions,
 ) factory.Controller {
 	controller := &AvailableStatusController{
-		manifestWorkClient: manifestWorkClient,
-		manifestWorkLister: manifestWorkLister,
-		spokeDynamicClient: spokeDynamicClient,
+		manifestWorkClient:        manifestWorkClient,
+		manifestWorkLister:        manifestWorkLister,
+		manifestWorkStore:         manifestWorkInformer.Informer().GetStore(),
+		appliedManifestWorkClient: appliedManifestWorkClient,
+		appliedManifestWorkLister: appliedManifestWorkInformer.Lister(),
+		spokeDynamicClient:        spokeDynamicClient,
+		restMapper:                restMapper,
+		hubHash:                   hubHash,
+		cacheFreshness:            options.CacheFreshness,
+		staleCacheThreshold:       options.StaleCacheThreshold,
+		driftTracker:              options.DriftTracker,
+		resourceCache:             options.ResourceCache,
+		liveObjects:               options.LiveObjects,
+		notFoundGracePeriod:       options.NotFoundGracePeriod,
+		statusWriter:              options.StatusWriter,
 	}
 
 	return factory.New().
@@ -53,7 +129,7 @@ func NewAvailableStatusController(
 			accessor, _ := meta.Accessor(obj)
 			return accessor.GetName()
 		}, manifestWorkInformer.Informer()).
-		WithSync(controller.sync).ResyncEvery(ControllerReSyncInterval).ToController("AvailableStatusController", recorder)
+		WithSync(controllers.InstrumentSync(hubHash, "AvailableStatusController", controller.sync)).ResyncEvery(ControllerReSyncInterval).ToController("AvailableStatusController", recorder)
 }
 
 func (c *AvailableStatusController) sync(ctx context.Context, controllerContext factory.SyncContext) error {
@@ -62,7 +138,8 @@ func (c *AvailableStatusController) sync(ctx context.Context, controllerContext
 		// sync a particular manifestwork
 		manifestWork, err := c.manifestWorkLister.Get(manifestWorkName)
 		if errors.IsNotFound(err) {
-			// work not found, could have been deleted, do nothing.
+			// work not found, could have been deleted, release its references to the resource cache.
+			c.setCacheReferences(manifestWorkName, nil)
 			return nil
 		}
 		if err != nil {
@@ -76,38 +153,74 @@ func (c *AvailableStatusController) sync(ctx context.Context, controllerContext
 		return nil
 	}
 
-	// resync all manifestworks
-	klog.V(4).Infof("Reconciling all ManifestWorks")
-	manifestWorks, err := c.manifestWorkLister.List(labels.Everything())
-	if err != nil {
-		return fmt.Errorf("unable to list manifestworks: %w", err)
-	}
-
-	errs := []error{}
-	for _, manifestWork := range manifestWorks {
-		err = c.syncManifestWork(ctx, manifestWork)
+	// resync all manifestworks by requeueing them from the store of the informer, which may only have their metadata
+	klog.V(4).Infof("Requeue all ManifestWorks")
+	for _, obj := range c.manifestWorkStore.List() {
+		accessor, err := meta.Accessor(obj)
 		if err != nil {
-			errs = append(errs, fmt.Errorf("unable to sync manifestwork %q: %w", manifestWork.Name, err))
+			continue
 		}
-	}
-	if len(errs) > 0 {
-		return fmt.Errorf("unable to resync manifestworks: %w", utilerrors.NewAggregate(errs))
+		controllerContext.Queue().Add(accessor.GetName())
 	}
 	return nil
 }
 
 func (c *AvailableStatusController) syncManifestWork(ctx context.Context, originalManifestWork *workapiv1.ManifestWork) error {
 	klog.V(4).Infof("Reconciling ManifestWork %q", originalManifestWork.Name)
+	if helper.IsWorkPaused(originalManifestWork) {
+		return nil
+	}
 	manifestWork := originalManifestWork.DeepCopy()
 
-	needStatusUpdate := false
+	// reference the resources of the manifestwork before they are looked up, so that the informers of the resource
+	// types in use are started
+	c.setCacheReferences(manifestWork.Name, manifestWork.Status.ResourceStatus.Manifests)
+
+	// the resources of a manifestwork from a stale cache may be removed or renamed already, e.g. after the hub
+	// is unreachable, so they are not reported as unavailable to avoid false alarms on the hub.
+	staleCache := c.cacheFreshness.IsStale(c.staleCacheThreshold)
+
+	var healths []resourceHealth
+	drifted := 0
 	// handle status condition of manifests
 	for index, manifest := range manifestWork.Status.ResourceStatus.Manifests {
-		availableStatusCondition := buildAvailableStatusCondition(manifest.ResourceMeta, c.spokeDynamicClient)
-		newConditions := helper.MergeStatusConditions(manifest.Conditions, []metav1.Condition{availableStatusCondition})
-		if !reflect.DeepEqual(manifestWork.Status.ResourceStatus.Manifests[index].Conditions, newConditions) {
-			manifestWork.Status.ResourceStatus.Manifests[index].Conditions = newConditions
-			needStatusUpdate = true
+		availableStatusCondition, obj := c.buildAvailableStatusCondition(manifest.ResourceMeta, c.isJustApplied(manifestWork, manifest))
+		// the Jobs deleted once their ttlSecondsAfterFinished expired are completed instead of unavailable
+		availableStatusCondition = jobAvailableStatusCondition(manifest, availableStatusCondition, obj)
+		generation := int64(0)
+		if obj != nil {
+			generation = obj.GetGeneration()
+		}
+		if staleCache && availableStatusCondition.Status == metav1.ConditionFalse {
+			availableStatusCondition = metav1.Condition{
+				Type:   availableStatusCondition.Type,
+				Status: metav1.ConditionUnknown,
+				Reason: staleCacheReason,
+				Message: fmt.Sprintf("Resource is not available, but the cache of manifestworks is not synced since %s",
+					c.cacheFreshness.LastSyncTime().UTC().Format(time.RFC3339)),
+			}
+		}
+		if availableStatusCondition.Reason != "IncompletedResourceMeta" {
+			healths = append(healths, newResourceHealth(manifest.ResourceMeta, generation, availableStatusCondition))
+		}
+		manifestWork.Status.ResourceStatus.Manifests[index].Conditions = helper.MergeStatusConditions(
+			manifest.Conditions, []metav1.Condition{availableStatusCondition})
+
+		// the resource is degraded if it is modified out of band since it was applied
+		if availableStatusCondition.Status == metav1.ConditionTrue && c.driftTracker.IsDrifted(manifestWork.Name, manifest.ResourceMeta, obj) {
+			drifted++
+			manifestWork.Status.ResourceStatus.Manifests[index].Conditions = helper.MergeStatusConditions(
+				manifestWork.Status.ResourceStatus.Manifests[index].Conditions, []metav1.Condition{{
+					Type:    string(workapiv1.ManifestDegraded),
+					Status:  metav1.ConditionTrue,
+					Reason:  resourceDriftedReason,
+					Message: "Resource is modified out of band since it was applied",
+				}})
+		} else if degraded := meta.FindStatusCondition(manifestWork.Status.ResourceStatus.Manifests[index].Conditions,
+			string(workapiv1.ManifestDegraded)); degraded != nil && degraded.Reason == resourceDriftedReason {
+			// the Degraded condition of the other reasons is set by the ManifestWorkController, e.g. the manifest
+			// cannot be decoded
+			meta.RemoveStatusCondition(&manifestWork.Status.ResourceStatus.Manifests[index].Conditions, string(workapiv1.ManifestDegraded))
 		}
 	}
 
@@ -126,10 +239,51 @@ func (c *AvailableStatusController) syncManifestWork(ctx context.Context, origin
 		workAvailableStatusCondition := aggregateManifestConditions(manifestWork.Generation, manifestWork.Status.ResourceStatus.Manifests)
 		workStatusConditions = helper.MergeStatusConditions(manifestWork.Status.Conditions, []metav1.Condition{workAvailableStatusCondition})
 	}
+
+	// handle status condition Degraded of manifestwork, the manifestwork is applied again if the drift is remediated
+	if drifted > 0 {
+		workStatusConditions = helper.MergeStatusConditions(workStatusConditions, []metav1.Condition{{
+			Type:               string(workapiv1.WorkDegraded),
+			Status:             metav1.ConditionTrue,
+			Reason:             resourcesDriftedReason,
+			ObservedGeneration: manifestWork.Generation,
+			Message:            fmt.Sprintf("%d of %d resources are modified out of band", drifted, len(manifestWork.Status.ResourceStatus.Manifests)),
+		}})
+		if helper.DriftPolicy(manifestWork) == constants.DriftPolicyRemediate {
+			c.driftTracker.Remediate(manifestWork.Name)
+		}
+	} else if degraded := meta.FindStatusCondition(workStatusConditions, string(workapiv1.WorkDegraded)); degraded != nil &&
+		degraded.Reason == resourcesDriftedReason {
+		// the Degraded condition of the other reasons is set by the ManifestWorkController, e.g. the manifests wait
+		// for the manifests they depend on beyond the deadline
+		meta.RemoveStatusCondition(&workStatusConditions, string(workapiv1.WorkDegraded))
+	}
+
+	// handle status condition Completed of run-to-completion manifestwork
+	if completedCondition, ok := c.buildCompletedStatusCondition(manifestWork); ok {
+		workStatusConditions = helper.MergeStatusConditions(workStatusConditions, []metav1.Condition{completedCondition})
+	}
 	manifestWork.Status.Conditions = workStatusConditions
 
+	// mirror the health of the applied resources to the appliedmanifestwork
+	if err := c.updateAppliedResourceHealth(ctx, manifestWork, healths); err != nil {
+		return err
+	}
+
+	// truncate the status as it is going to be written, so that it is compared with the truncated status on the hub
+	untruncatedStatus := manifestWork.Status.DeepCopy()
+	if err := helper.BudgetManifestWorkStatus(c.manifestWorkClient, &manifestWork.Status, manifestWork.Generation); err != nil {
+		return err
+	}
+
 	// no work if the status of manifestwork does not change
-	if !needStatusUpdate && reflect.DeepEqual(originalManifestWork.Status.Conditions, manifestWork.Status.Conditions) {
+	if reflect.DeepEqual(originalManifestWork.Status, manifestWork.Status) {
+		return nil
+	}
+
+	// the status writer truncates the status once the changes are applied to the latest status
+	if c.statusWriter != nil {
+		c.statusWriter.Enqueue(manifestWork.Name, withAvailabilityChanges(originalManifestWork.Status, *untruncatedStatus))
 		return nil
 	}
 
@@ -138,15 +292,63 @@ func (c *AvailableStatusController) syncManifestWork(ctx context.Context, origin
 	return err
 }
 
+// withAvailabilityChanges returns the status update applying the changes of the conditions owned by the controller
+// from the original status to the new status, so that the status updates of the manifest controller enqueued to the
+// status writer meanwhile are not overwritten.
+func withAvailabilityChanges(original, status workapiv1.ManifestWorkStatus) helper.UpdateManifestWorkStatusFunc {
+	return func(oldStatus *workapiv1.ManifestWorkStatus) error {
+		for index, manifest := range oldStatus.ResourceStatus.Manifests {
+			before := findManifestCondition(original.ResourceStatus.Manifests, manifest.ResourceMeta)
+			after := findManifestCondition(status.ResourceStatus.Manifests, manifest.ResourceMeta)
+			if before == nil || after == nil {
+				continue
+			}
+			for _, conditionType := range []string{string(workapiv1.ManifestAvailable), string(workapiv1.ManifestDegraded)} {
+				applyConditionChange(&oldStatus.ResourceStatus.Manifests[index].Conditions, conditionType, before.Conditions, after.Conditions)
+			}
+		}
+		for _, conditionType := range []string{workapiv1.WorkAvailable, workapiv1.WorkDegraded, constants.WorkCompleted} {
+			applyConditionChange(&oldStatus.Conditions, conditionType, original.Conditions, status.Conditions)
+		}
+		return nil
+	}
+}
+
+// findManifestCondition returns the manifest condition of the resource, or nil if it is not found.
+func findManifestCondition(manifests []workapiv1.ManifestCondition, resourceMeta workapiv1.ManifestResourceMeta) *workapiv1.ManifestCondition {
+	for index := range manifests {
+		if manifests[index].ResourceMeta == resourceMeta {
+			return &manifests[index]
+		}
+	}
+	return nil
+}
+
+// applyConditionChange sets or removes the condition of the type in the conditions if it is changed from before to
+// after, the conditions are untouched otherwise.
+func applyConditionChange(conditions *[]metav1.Condition, conditionType string, before, after []metav1.Condition) {
+	beforeCondition, afterCondition := meta.FindStatusCondition(before, conditionType), meta.FindStatusCondition(after, conditionType)
+	switch {
+	case reflect.DeepEqual(beforeCondition, afterCondition):
+	case afterCondition == nil:
+		meta.RemoveStatusCondition(conditions, conditionType)
+	default:
+		meta.SetStatusCondition(conditions, *afterCondition)
+	}
+}
+
 // aggregateManifestConditions aggregates status conditions of manifests and returns a status
 // condition for manifestwork
 func aggregateManifestConditions(generation int64, manifests []workapiv1.ManifestCondition) metav1.Condition {
-	available, unavailable, unknown := 0, 0, 0
+	available, unavailable, unknown, stale := 0, 0, 0, 0
 	for _, manifest := range manifests {
 		for _, condition := range manifest.Conditions {
 			if condition.Type != string(workapiv1.ManifestAvailable) {
 				continue
 			}
+			if condition.Reason == staleCacheReason {
+				stale += 1
+			}
 
 			switch condition.Status {
 			case metav1.ConditionTrue:
@@ -168,6 +370,14 @@ func aggregateManifestConditions(generation int64, manifests []workapiv1.Manifes
 			ObservedGeneration: generation,
 			Message:            fmt.Sprintf("%d of %d resources are not available", unavailable, len(manifests)),
 		}
+	case stale > 0:
+		return metav1.Condition{
+			Type:               string(workapiv1.WorkAvailable),
+			Status:             metav1.ConditionUnknown,
+			Reason:             staleCacheReason,
+			ObservedGeneration: generation,
+			Message:            fmt.Sprintf("%d of %d resources have unknown status because the cache is stale", stale, len(manifests)),
+		}
 	case unknown > 0:
 		return metav1.Condition{
 			Type:               string(workapiv1.WorkAvailable),
@@ -187,31 +397,35 @@ func aggregateManifestConditions(generation int64, manifests []workapiv1.Manifes
 	}
 }
 
-// buildAvailableStatusCondition returns a StatusCondition with type Available for a given manifest resource
-func buildAvailableStatusCondition(resourceMeta workapiv1.ManifestResourceMeta, dynamicClient dynamic.Interface) metav1.Condition {
+// buildAvailableStatusCondition returns a StatusCondition with type Available for a given manifest resource,
+// together with the resource, which is nil if the resource does not exist. The resource of a manifest just applied is
+// only unavailable once it is not found by a live lookup either.
+func (c *AvailableStatusController) buildAvailableStatusCondition(
+	resourceMeta workapiv1.ManifestResourceMeta, justApplied bool) (metav1.Condition, *unstructured.Unstructured) {
 	conditionType := string(workapiv1.ManifestAvailable)
 
-	if len(resourceMeta.Resource) == 0 || len(resourceMeta.Version) == 0 || len(resourceMeta.Name) == 0 {
+	key, ok := c.resourceCacheKey(resourceMeta)
+	if !ok {
 		return metav1.Condition{
 			Type:    conditionType,
 			Status:  metav1.ConditionUnknown,
 			Reason:  "IncompletedResourceMeta",
 			Message: "Resource meta is incompleted",
-		}
+		}, nil
 	}
 
-	available, err := isResourceAvailable(resourceMeta.Namespace, resourceMeta.Name, schema.GroupVersionResource{
-		Group:    resourceMeta.Group,
-		Version:  resourceMeta.Version,
-		Resource: resourceMeta.Resource,
-	}, dynamicClient)
+	getResource := c.getResource
+	if justApplied {
+		getResource = c.getResourceConfirmingNotFound
+	}
+	available, obj, err := isResourceAvailable(key.Namespace, resourceMeta.Name, key.GVR, getResource)
 	if err != nil {
 		return metav1.Condition{
 			Type:    conditionType,
 			Status:  metav1.ConditionUnknown,
-			Reason:  "FetchingResourceFailed",
-			Message: fmt.Sprintf("Failed to fetch resource: %v", err),
-		}
+			Reason:  "AvailabilityCheckFailed",
+			Message: fmt.Sprintf("Failed to check availability of resource: %v", err),
+		}, obj
 	}
 
 	if available {
@@ -220,7 +434,7 @@ func buildAvailableStatusCondition(resourceMeta workapiv1.ManifestResourceMeta,
 			Status:  metav1.ConditionTrue,
 			Reason:  "ResourceAvailable",
 			Message: "Resource is available",
-		}
+		}, obj
 	}
 
 	return metav1.Condition{
@@ -228,17 +442,206 @@ func buildAvailableStatusCondition(resourceMeta workapiv1.ManifestResourceMeta,
 		Status:  metav1.ConditionFalse,
 		Reason:  "ResourceNotAvailable",
 		Message: "Resource is not available",
+	}, obj
+}
+
+// buildCompletedStatusCondition returns a StatusCondition with type Completed for a manifestwork with completion
+// rules. False is returned if the manifestwork has no completion rules or it is completed already, since the
+// completion of a manifestwork is not changed once it is completed.
+func (c *AvailableStatusController) buildCompletedStatusCondition(manifestWork *workapiv1.ManifestWork) (metav1.Condition, bool) {
+	rules, err := helper.CompletionRules(manifestWork)
+	if err != nil {
+		return metav1.Condition{
+			Type:               constants.WorkCompleted,
+			Status:             metav1.ConditionFalse,
+			Reason:             "InvalidCompletionRules",
+			ObservedGeneration: manifestWork.Generation,
+			Message:            err.Error(),
+		}, true
+	}
+	if rules == nil || helper.IsWorkCompleted(manifestWork) {
+		return metav1.Condition{}, false
+	}
+
+	completed := 0
+	for _, rule := range rules {
+		for _, manifest := range manifestWork.Status.ResourceStatus.Manifests {
+			if manifest.ResourceMeta.Ordinal == rule.Ordinal && c.isResourceCompleted(manifest.ResourceMeta, rule.ConditionType) {
+				completed++
+				break
+			}
+		}
+	}
+
+	if completed < len(rules) {
+		return metav1.Condition{
+			Type:               constants.WorkCompleted,
+			Status:             metav1.ConditionFalse,
+			Reason:             "CompletionRulesNotMet",
+			ObservedGeneration: manifestWork.Generation,
+			Message:            fmt.Sprintf("%d of %d completion rules are met", completed, len(rules)),
+		}, true
+	}
+
+	return metav1.Condition{
+		Type:               constants.WorkCompleted,
+		Status:             metav1.ConditionTrue,
+		Reason:             "CompletionRulesMet",
+		ObservedGeneration: manifestWork.Generation,
+		Message:            "All completion rules are met",
+	}, true
+}
+
+// isResourceCompleted checks if the status condition of the resource with the given type is true.
+func (c *AvailableStatusController) isResourceCompleted(resourceMeta workapiv1.ManifestResourceMeta, conditionType string) bool {
+	key, ok := c.resourceCacheKey(resourceMeta)
+	if !ok {
+		return false
+	}
+
+	obj, err := c.getResource(key.GVR, key.Namespace, resourceMeta.Name)
+	if err != nil {
+		return false
 	}
+	return hasTrueCondition(obj, conditionType)
 }
 
-// isResourceAvailable checks if the specific resource is available or not
-func isResourceAvailable(namespace, name string, gvr schema.GroupVersionResource, dynamicClient dynamic.Interface) (bool, error) {
-	_, err := dynamicClient.Resource(gvr).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+// resourceCacheKey returns the resource type and namespace of the resource, the namespace is empty if the resource
+// is cluster scoped even if the namespace is set in the manifest. False is returned if the resource meta is incomplete.
+func (c *AvailableStatusController) resourceCacheKey(resourceMeta workapiv1.ManifestResourceMeta) (helper.ResourceCacheKey, bool) {
+	if len(resourceMeta.Resource) == 0 || len(resourceMeta.Version) == 0 || len(resourceMeta.Name) == 0 {
+		return helper.ResourceCacheKey{}, false
+	}
+
+	gvr := schema.GroupVersionResource{
+		Group:    resourceMeta.Group,
+		Version:  resourceMeta.Version,
+		Resource: resourceMeta.Resource,
+	}
+	namespace := resourceMeta.Namespace
+	if c.isClusterScoped(gvr) {
+		namespace = ""
+	}
+	return helper.ResourceCacheKey{GVR: gvr, Namespace: namespace}, true
+}
+
+// setCacheReferences replaces the resources of the manifestwork referenced in the resource cache. The references are
+// keyed by the hub hash as well since the cache is shared by the controllers of all the hubs. It does nothing if the
+// cache is nil.
+func (c *AvailableStatusController) setCacheReferences(manifestWorkName string, manifests []workapiv1.ManifestCondition) {
+	if c.resourceCache == nil {
+		return
+	}
+	var keys []helper.ResourceCacheKey
+	for _, manifest := range manifests {
+		if key, ok := c.resourceCacheKey(manifest.ResourceMeta); ok {
+			keys = append(keys, key)
+		}
+	}
+	c.resourceCache.SetReferences(fmt.Sprintf("%s/%s", c.hubHash, manifestWorkName), keys)
+}
+
+// getResource returns the resource from the resource cache, or from the live object cache or the spoke apiserver if
+// the resource cache is nil.
+func (c *AvailableStatusController) getResource(gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error) {
+	if c.resourceCache != nil {
+		return c.resourceCache.Get(context.TODO(), gvr, namespace, name)
+	}
+	if c.liveObjects != nil {
+		return c.liveObjects.Get(context.TODO(), gvr, namespace, name)
+	}
+	return c.spokeDynamicClient.Resource(gvr).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+// getResourceConfirmingNotFound returns the resource like getResource, but a resource not found in the resource cache
+// is looked up from the live object cache or the spoke apiserver as well.
+func (c *AvailableStatusController) getResourceConfirmingNotFound(
+	gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error) {
+	if c.resourceCache == nil {
+		return c.getResource(gvr, namespace, name)
+	}
+	return c.resourceCache.GetConfirmingNotFound(context.TODO(), gvr, namespace, name)
+}
+
+// isJustApplied returns if the manifest is applied within the notFoundGracePeriod. The Applied condition of the
+// manifest is not changed once it is applied again, so the last applied time of the manifests recorded on the work
+// by the manifestwork controller is used if it is later than the transition of the condition to True.
+func (c *AvailableStatusController) isJustApplied(manifestWork *workapiv1.ManifestWork, manifest workapiv1.ManifestCondition) bool {
+	if c.notFoundGracePeriod <= 0 || c.resourceCache == nil {
+		return false
+	}
+	applied := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestApplied))
+	if applied == nil || applied.Status != metav1.ConditionTrue {
+		return false
+	}
+
+	appliedTime := applied.LastTransitionTime.Time
+	if lastAppliedTime, err := time.Parse(time.RFC3339,
+		manifestWork.Annotations[constants.LastAppliedTimeAnnotationKey]); err == nil && lastAppliedTime.After(appliedTime) {
+		appliedTime = lastAppliedTime
+	}
+	return time.Since(appliedTime) < c.notFoundGracePeriod
+}
+
+// isClusterScoped checks the scope of the resource with the rest mapper. The resource is treated as namespace
+// scoped if the rest mapper is not able to tell its scope.
+func (c *AvailableStatusController) isClusterScoped(gvr schema.GroupVersionResource) bool {
+	if c.restMapper == nil {
+		return false
+	}
+
+	gvk, err := c.restMapper.KindFor(gvr)
+	if err != nil {
+		return false
+	}
+
+	mapping, err := c.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false
+	}
+
+	return mapping.Scope.Name() == meta.RESTScopeNameRoot
+}
+
+// isResourceAvailable checks if the specific resource is available or not, and returns the resource, which is
+// nil if the resource does not exist. A resource is available once it exists, except for the well known kinds whose availability is
+// determined by their status.
+func isResourceAvailable(namespace, name string, gvr schema.GroupVersionResource,
+	getResource func(gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error)) (bool, *unstructured.Unstructured, error) {
+	obj, err := getResource(gvr, namespace, name)
 	if errors.IsNotFound(err) {
-		return false, nil
+		return false, nil, nil
 	}
 	if err != nil {
-		return false, err
+		return false, nil, err
+	}
+
+	switch gvr.GroupResource() {
+	case crdGroupResource:
+		return hasTrueCondition(obj, "Established"), obj, nil
+	case namespaceGroupResource:
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		return phase == "Active", obj, nil
+	}
+	return true, obj, nil
+}
+
+var (
+	crdGroupResource       = schema.GroupResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}
+	namespaceGroupResource = schema.GroupResource{Group: "", Resource: "namespaces"}
+)
+
+// hasTrueCondition checks if the condition with the given type in the status of the object is true.
+func hasTrueCondition(obj *unstructured.Unstructured, conditionType string) bool {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, condition := range conditions {
+		c, ok := condition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if c["type"] == conditionType && c["status"] == string(metav1.ConditionTrue) {
+			return true
+		}
 	}
-	return true, nil
+	return false
 }
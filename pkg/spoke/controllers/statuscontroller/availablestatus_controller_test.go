@@ -2,15 +2,24 @@ package statuscontroller
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	fakedynamic "k8s.io/client-go/dynamic/fake"
 	clienttesting "k8s.io/client-go/testing"
 	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
 	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
 	"open-cluster-management.io/work/pkg/spoke/spoketesting"
 )
 
@@ -20,6 +29,8 @@ func TestSyncManifestWork(t *testing.T) {
 		existingResources []runtime.Object
 		manifests         []workapiv1.ManifestCondition
 		workConditions    []metav1.Condition
+		annotations       map[string]string
+		staleCache        bool
 		validateActions   func(t *testing.T, actions []clienttesting.Action)
 	}{
 		{
@@ -118,6 +129,39 @@ func TestSyncManifestWork(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:       "build status with unknown instead of not available if the cache is stale",
+			staleCache: true,
+			existingResources: []runtime.Object{
+				spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1"),
+			},
+			manifests: []workapiv1.ManifestCondition{
+				newManifest("", "v1", "secrets", "ns1", "n1"),
+				newManifest("", "v1", "secrets", "ns2", "n2"),
+			},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
+				if len(work.Status.ResourceStatus.Manifests) != 2 {
+					t.Fatal(spew.Sdump(work.Status.ResourceStatus.Manifests))
+				}
+				if !hasStatusCondition(work.Status.ResourceStatus.Manifests[0].Conditions, string(workapiv1.ManifestAvailable), metav1.ConditionTrue) {
+					t.Fatal(spew.Sdump(work.Status.ResourceStatus.Manifests[0].Conditions))
+				}
+				condition := meta.FindStatusCondition(work.Status.ResourceStatus.Manifests[1].Conditions, string(workapiv1.ManifestAvailable))
+				if condition == nil || condition.Status != metav1.ConditionUnknown || condition.Reason != staleCacheReason {
+					t.Fatal(spew.Sdump(work.Status.ResourceStatus.Manifests[1].Conditions))
+				}
+
+				condition = meta.FindStatusCondition(work.Status.Conditions, string(workapiv1.WorkAvailable))
+				if condition == nil || condition.Status != metav1.ConditionUnknown || condition.Reason != staleCacheReason {
+					t.Fatal(spew.Sdump(work.Status.Conditions))
+				}
+			},
+		},
 		{
 			name: "build status when one of resosurce has incompleted meta",
 			existingResources: []runtime.Object{
@@ -148,11 +192,117 @@ func TestSyncManifestWork(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "build status with cluster scoped resources",
+			existingResources: []runtime.Object{
+				newNamespace("ns1", "Active"),
+				newCRD("crd1", "True"),
+				newCRD("crd2", "False"),
+			},
+			manifests: []workapiv1.ManifestCondition{
+				newManifest("", "v1", "namespaces", "cluster1", "ns1"),
+				newManifest("apiextensions.k8s.io", "v1", "customresourcedefinitions", "", "crd1"),
+				newManifest("apiextensions.k8s.io", "v1", "customresourcedefinitions", "", "crd2"),
+			},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
+				if len(work.Status.ResourceStatus.Manifests) != 3 {
+					t.Fatal(spew.Sdump(work.Status.ResourceStatus.Manifests))
+				}
+				if !hasStatusCondition(work.Status.ResourceStatus.Manifests[0].Conditions, string(workapiv1.ManifestAvailable), metav1.ConditionTrue) {
+					t.Fatal(spew.Sdump(work.Status.ResourceStatus.Manifests[0].Conditions))
+				}
+				if !hasStatusCondition(work.Status.ResourceStatus.Manifests[1].Conditions, string(workapiv1.ManifestAvailable), metav1.ConditionTrue) {
+					t.Fatal(spew.Sdump(work.Status.ResourceStatus.Manifests[1].Conditions))
+				}
+				if !hasStatusCondition(work.Status.ResourceStatus.Manifests[2].Conditions, string(workapiv1.ManifestAvailable), metav1.ConditionFalse) {
+					t.Fatal(spew.Sdump(work.Status.ResourceStatus.Manifests[2].Conditions))
+				}
+			},
+		},
+		{
+			name: "mark work completed once completion rules are met",
+			existingResources: []runtime.Object{
+				newJob("ns1", "job1", "Complete"),
+			},
+			manifests: []workapiv1.ManifestCondition{
+				newManifestWthCondition("batch", "v1", "jobs", "ns1", "job1"),
+			},
+			annotations: map[string]string{
+				constants.CompletionRulesAnnotationKey: `[{"ordinal":0,"conditionType":"Complete"}]`,
+			},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
+				if !hasStatusCondition(work.Status.Conditions, constants.WorkCompleted, metav1.ConditionTrue) {
+					t.Fatal(spew.Sdump(work.Status.Conditions))
+				}
+			},
+		},
+		{
+			name: "do not mark work completed if completion rules are not met",
+			existingResources: []runtime.Object{
+				newJob("ns1", "job1", "Failed"),
+			},
+			manifests: []workapiv1.ManifestCondition{
+				newManifestWthCondition("batch", "v1", "jobs", "ns1", "job1"),
+			},
+			annotations: map[string]string{
+				constants.CompletionRulesAnnotationKey: `[{"ordinal":0,"conditionType":"Complete"}]`,
+			},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
+				if !hasStatusCondition(work.Status.Conditions, constants.WorkCompleted, metav1.ConditionFalse) {
+					t.Fatal(spew.Sdump(work.Status.Conditions))
+				}
+			},
+		},
+		{
+			name: "keep work completed after the payload is deleted",
+			manifests: []workapiv1.ManifestCondition{
+				newManifestWthCondition("batch", "v1", "jobs", "ns1", "job1"),
+			},
+			annotations: map[string]string{
+				constants.CompletionRulesAnnotationKey: `[{"ordinal":0,"conditionType":"Complete"}]`,
+			},
+			workConditions: []metav1.Condition{
+				{
+					Type:   constants.WorkCompleted,
+					Status: metav1.ConditionTrue,
+					Reason: "CompletionRulesMet",
+				},
+			},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
+				if !hasStatusCondition(work.Status.Conditions, constants.WorkCompleted, metav1.ConditionTrue) {
+					t.Fatal(spew.Sdump(work.Status.Conditions))
+				}
+				if !hasStatusCondition(work.Status.Conditions, string(workapiv1.WorkAvailable), metav1.ConditionFalse) {
+					t.Fatal(spew.Sdump(work.Status.Conditions))
+				}
+			},
+		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			testingWork, _ := spoketesting.NewManifestWork(0)
+			testingWork.Annotations = c.annotations
 			testingWork.Status = workapiv1.ManifestWorkStatus{
 				Conditions: c.workConditions,
 				ResourceStatus: workapiv1.ManifestResourceStatus{
@@ -165,6 +315,11 @@ func TestSyncManifestWork(t *testing.T) {
 			controller := AvailableStatusController{
 				manifestWorkClient: fakeClient.WorkV1().ManifestWorks(testingWork.Namespace),
 				spokeDynamicClient: fakeDynamicClient,
+				restMapper:         spoketesting.NewFakeRestMapper(),
+			}
+			if c.staleCache {
+				controller.cacheFreshness = newStaleCacheFreshness(t)
+				controller.staleCacheThreshold = time.Nanosecond
 			}
 
 			err := controller.syncManifestWork(context.TODO(), testingWork)
@@ -176,6 +331,268 @@ func TestSyncManifestWork(t *testing.T) {
 	}
 }
 
+func TestResyncManifestWorks(t *testing.T) {
+	work1, _ := spoketesting.NewManifestWork(0)
+	work1.Name = "work1"
+	work2, _ := spoketesting.NewManifestWork(1)
+	work2.Name = "work2"
+
+	fakeClient := fakeworkclient.NewSimpleClientset(work1, work2)
+	informerFactory := workinformers.NewSharedInformerFactory(fakeClient, 5*time.Minute)
+	store := informerFactory.Work().V1().ManifestWorks().Informer().GetStore()
+	for _, work := range []*workapiv1.ManifestWork{work1, work2} {
+		if err := store.Add(work); err != nil {
+			t.Fatal(err)
+		}
+	}
+	controller := AvailableStatusController{
+		manifestWorkClient: fakeClient.WorkV1().ManifestWorks(work1.Namespace),
+		manifestWorkLister: informerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks(work1.Namespace),
+		manifestWorkStore:  store,
+	}
+
+	// the manifestworks are requeued instead of being synced by the resync
+	syncContext := spoketesting.NewFakeSyncContext(t, "key")
+	if err := controller.sync(context.TODO(), syncContext); err != nil {
+		t.Fatal(err)
+	}
+	if syncContext.Queue().Len() != 2 {
+		t.Errorf("expected 2 manifestworks requeued, but got %d", syncContext.Queue().Len())
+	}
+	if len(fakeClient.Actions()) != 0 {
+		t.Errorf("expected no actions, but got %v", spew.Sdump(fakeClient.Actions()))
+	}
+}
+
+func TestSyncDriftedManifestWork(t *testing.T) {
+	required := spoketesting.NewUnstructured("v1", "ConfigMap", "ns1", "n1")
+	_ = unstructured.SetNestedField(required.Object, "v1", "data", "key")
+	requiredData, _ := required.MarshalJSON()
+	live := required.DeepCopy()
+	_ = unstructured.SetNestedField(live.Object, "v2", "data", "key")
+	resourceMeta := workapiv1.ManifestResourceMeta{Version: "v1", Resource: "configmaps", Namespace: "ns1", Name: "n1"}
+
+	for _, policy := range []string{constants.DriftPolicyReportOnly, constants.DriftPolicyRemediate} {
+		t.Run(policy, func(t *testing.T) {
+			testingWork, _ := spoketesting.NewManifestWork(0)
+			testingWork.Annotations = map[string]string{constants.DriftPolicyAnnotationKey: policy}
+			testingWork.Status.ResourceStatus.Manifests = []workapiv1.ManifestCondition{{ResourceMeta: resourceMeta}}
+
+			tracker := helper.NewDriftTracker()
+			baseline, err := helper.NewDriftBaseline(resourceMeta, requiredData, required)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tracker.SetBaselines(testingWork.Name, []helper.DriftBaseline{baseline})
+			remediated := []string{}
+			tracker.SetRemediateFunc(func(name string) { remediated = append(remediated, name) })
+
+			fakeClient := fakeworkclient.NewSimpleClientset(testingWork)
+			controller := AvailableStatusController{
+				manifestWorkClient: fakeClient.WorkV1().ManifestWorks(testingWork.Namespace),
+				spokeDynamicClient: fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), live),
+				restMapper:         spoketesting.NewFakeRestMapper(),
+				driftTracker:       tracker,
+			}
+			if err := controller.syncManifestWork(context.TODO(), testingWork); err != nil {
+				t.Fatal(err)
+			}
+
+			actions := fakeClient.Actions()
+			if len(actions) != 1 {
+				t.Fatal(spew.Sdump(actions))
+			}
+			work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
+			if !hasStatusCondition(work.Status.Conditions, string(workapiv1.WorkAvailable), metav1.ConditionTrue) ||
+				!hasStatusCondition(work.Status.Conditions, workapiv1.WorkDegraded, metav1.ConditionTrue) {
+				t.Fatal(spew.Sdump(work.Status.Conditions))
+			}
+			if !hasStatusCondition(work.Status.ResourceStatus.Manifests[0].Conditions, string(workapiv1.ManifestDegraded), metav1.ConditionTrue) {
+				t.Fatal(spew.Sdump(work.Status.ResourceStatus.Manifests[0].Conditions))
+			}
+			if expected := policy == constants.DriftPolicyRemediate; (len(remediated) == 1) != expected {
+				t.Errorf("expected remediated %v, but got %v", expected, remediated)
+			}
+		})
+	}
+}
+
+func TestSyncManifestWorkWithDegradedManifests(t *testing.T) {
+	testingWork, _ := spoketesting.NewManifestWork(0)
+	drifted := newManifest("", "v1", "configmaps", "ns1", "n1")
+	drifted.Conditions = []metav1.Condition{
+		{Type: string(workapiv1.ManifestDegraded), Status: metav1.ConditionTrue, Reason: resourceDriftedReason},
+	}
+	// the Degraded condition is set by the ManifestWorkController
+	decodeFailed := newManifest("", "v1", "configmaps", "ns1", "n2")
+	decodeFailed.Conditions = []metav1.Condition{
+		{Type: string(workapiv1.ManifestDegraded), Status: metav1.ConditionTrue, Reason: "ManifestDecodeFailed"},
+	}
+	testingWork.Status.ResourceStatus.Manifests = []workapiv1.ManifestCondition{drifted, decodeFailed}
+
+	fakeClient := fakeworkclient.NewSimpleClientset(testingWork)
+	controller := AvailableStatusController{
+		manifestWorkClient: fakeClient.WorkV1().ManifestWorks(testingWork.Namespace),
+		spokeDynamicClient: fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(),
+			spoketesting.NewUnstructured("v1", "ConfigMap", "ns1", "n1"), spoketesting.NewUnstructured("v1", "ConfigMap", "ns1", "n2")),
+		restMapper: spoketesting.NewFakeRestMapper(),
+	}
+	if err := controller.syncManifestWork(context.TODO(), testingWork); err != nil {
+		t.Fatal(err)
+	}
+
+	actions := fakeClient.Actions()
+	if len(actions) != 1 {
+		t.Fatal(spew.Sdump(actions))
+	}
+	work := actions[0].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
+	if meta.FindStatusCondition(work.Status.ResourceStatus.Manifests[0].Conditions, string(workapiv1.ManifestDegraded)) != nil {
+		t.Errorf("expected the drift remediated, but got %v", spew.Sdump(work.Status.ResourceStatus.Manifests[0].Conditions))
+	}
+	if !hasStatusCondition(work.Status.ResourceStatus.Manifests[1].Conditions, string(workapiv1.ManifestDegraded), metav1.ConditionTrue) {
+		t.Errorf("expected the Degraded condition kept, but got %v", spew.Sdump(work.Status.ResourceStatus.Manifests[1].Conditions))
+	}
+}
+
+func TestSyncManifestWorkWithResourceCache(t *testing.T) {
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	testingWork, _ := spoketesting.NewManifestWork(0)
+	testingWork.Status.ResourceStatus.Manifests = []workapiv1.ManifestCondition{
+		newManifest("", "v1", "configmaps", "ns1", "n1"),
+		newManifest("", "v1", "configmaps", "ns1", "n2"),
+	}
+
+	fakeClient := fakeworkclient.NewSimpleClientset(testingWork)
+	fakeDynamicClient := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{configMaps: "ConfigMapList"},
+		spoketesting.NewUnstructured("v1", "ConfigMap", "ns1", "n1"), spoketesting.NewUnstructured("v1", "ConfigMap", "ns1", "n2"))
+	resourceCache := helper.NewResourceCache(fakeDynamicClient, nil, 2, time.Minute)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go resourceCache.Run(ctx)
+	controller := AvailableStatusController{
+		manifestWorkClient: fakeClient.WorkV1().ManifestWorks(testingWork.Namespace),
+		spokeDynamicClient: fakeDynamicClient,
+		restMapper:         spoketesting.NewFakeRestMapper(),
+		resourceCache:      resourceCache,
+	}
+
+	// the resources are fetched from the spoke until the informer is synced
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		fakeDynamicClient.ClearActions()
+		if err := controller.syncManifestWork(context.TODO(), testingWork); err != nil {
+			return false, err
+		}
+		for _, action := range fakeDynamicClient.Actions() {
+			if action.GetVerb() == "get" {
+				return false, nil
+			}
+		}
+		return true, nil
+	}); err != nil {
+		t.Fatalf("expected the resources served by the cache, but got %v", err)
+	}
+
+	work := fakeClient.Actions()[len(fakeClient.Actions())-1].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
+	if !hasStatusCondition(work.Status.Conditions, string(workapiv1.WorkAvailable), metav1.ConditionTrue) {
+		t.Fatal(spew.Sdump(work.Status.Conditions))
+	}
+}
+
+// newStaleCacheFreshness returns a CacheFreshness tracking an informer which fails to list manifestworks.
+func newStaleCacheFreshness(t *testing.T) *helper.CacheFreshness {
+	fakeClient := fakeworkclient.NewSimpleClientset()
+	fakeClient.PrependReactor("list", "manifestworks", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("hub is down")
+	})
+	informer := workinformers.NewSharedInformerFactory(fakeClient, 5*time.Minute).Work().V1().ManifestWorks().Informer()
+	freshness, err := helper.NewCacheFreshness(informer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	go informer.Run(stopCh)
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return freshness.IsStale(time.Nanosecond), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return freshness
+}
+
+func TestSyncManifestWorkWithLaggingResourceCache(t *testing.T) {
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	testingWork, _ := spoketesting.NewManifestWork(0)
+	justApplied := newManifest("", "v1", "configmaps", "ns1", "n3")
+	justApplied.Conditions = []metav1.Condition{{
+		Type:               string(workapiv1.ManifestApplied),
+		Status:             metav1.ConditionTrue,
+		Reason:             "AppliedManifestComplete",
+		LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Minute)),
+	}}
+	testingWork.Status.ResourceStatus.Manifests = []workapiv1.ManifestCondition{
+		newManifest("", "v1", "configmaps", "ns1", "n1"),
+		newManifest("", "v1", "configmaps", "ns1", "n2"),
+		justApplied,
+	}
+
+	// the informer watches a spoke lagging behind the spoke of the live lookups, which has the resource just created
+	laggingDynamicClient := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{configMaps: "ConfigMapList"},
+		spoketesting.NewUnstructured("v1", "ConfigMap", "ns1", "n1"), spoketesting.NewUnstructured("v1", "ConfigMap", "ns1", "n2"))
+	fakeDynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(),
+		spoketesting.NewUnstructured("v1", "ConfigMap", "ns1", "n1"), spoketesting.NewUnstructured("v1", "ConfigMap", "ns1", "n2"),
+		spoketesting.NewUnstructured("v1", "ConfigMap", "ns1", "n3"))
+	resourceCache := helper.NewResourceCache(laggingDynamicClient, helper.NewLiveObjectCache(fakeDynamicClient, 0), 2, time.Minute)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go resourceCache.Run(ctx)
+
+	fakeClient := fakeworkclient.NewSimpleClientset(testingWork)
+	controller := AvailableStatusController{
+		manifestWorkClient:  fakeClient.WorkV1().ManifestWorks(testingWork.Namespace),
+		spokeDynamicClient:  fakeDynamicClient,
+		restMapper:          spoketesting.NewFakeRestMapper(),
+		resourceCache:       resourceCache,
+		notFoundGracePeriod: 10 * time.Second,
+	}
+	lastManifestConditions := func() []metav1.Condition {
+		work := fakeClient.Actions()[len(fakeClient.Actions())-1].(clienttesting.UpdateAction).GetObject().(*workapiv1.ManifestWork)
+		return work.Status.ResourceStatus.Manifests[2].Conditions
+	}
+
+	// the resource not found by the synced informer beyond the grace period is not available
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		fakeDynamicClient.ClearActions()
+		if err := controller.syncManifestWork(context.TODO(), testingWork); err != nil {
+			return false, err
+		}
+		return len(fakeDynamicClient.Actions()) == 0, nil
+	}); err != nil {
+		t.Fatalf("expected the resources served by the cache, but got %v", err)
+	}
+	if !hasStatusCondition(lastManifestConditions(), string(workapiv1.ManifestAvailable), metav1.ConditionFalse) {
+		t.Fatal(spew.Sdump(lastManifestConditions()))
+	}
+
+	// the resource just applied again is looked up from the spoke once it is not found by the informer, though its
+	// Applied condition is not transitioned since then
+	testingWork.Annotations = map[string]string{
+		constants.LastAppliedTimeAnnotationKey: time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := controller.syncManifestWork(context.TODO(), testingWork); err != nil {
+		t.Fatal(err)
+	}
+	if actions := fakeDynamicClient.Actions(); len(actions) != 1 || actions[0].GetVerb() != "get" {
+		t.Fatalf("expected a live lookup, but got %v", actions)
+	}
+	if !hasStatusCondition(lastManifestConditions(), string(workapiv1.ManifestAvailable), metav1.ConditionTrue) {
+		t.Fatal(spew.Sdump(lastManifestConditions()))
+	}
+}
+
 func newManifest(group, version, resource, namespace, name string) workapiv1.ManifestCondition {
 	return workapiv1.ManifestCondition{
 		ResourceMeta: workapiv1.ManifestResourceMeta{
@@ -212,3 +629,69 @@ func hasStatusCondition(conditions []metav1.Condition, conditionType string, sta
 
 	return false
 }
+
+func newNamespace(name, phase string) *unstructured.Unstructured {
+	ns := spoketesting.NewUnstructured("v1", "Namespace", "", name)
+	_ = unstructured.SetNestedField(ns.Object, phase, "status", "phase")
+	return ns
+}
+
+func newCRD(name string, established metav1.ConditionStatus) *unstructured.Unstructured {
+	crd := spoketesting.NewUnstructured("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", name)
+	_ = unstructured.SetNestedSlice(crd.Object, []interface{}{
+		map[string]interface{}{"type": "Established", "status": string(established)},
+	}, "status", "conditions")
+	return crd
+}
+
+func newJob(namespace, name, conditionType string) *unstructured.Unstructured {
+	job := spoketesting.NewUnstructured("batch/v1", "Job", namespace, name)
+	_ = unstructured.SetNestedSlice(job.Object, []interface{}{
+		map[string]interface{}{"type": conditionType, "status": string(metav1.ConditionTrue)},
+	}, "status", "conditions")
+	return job
+}
+
+func TestWithAvailabilityChanges(t *testing.T) {
+	resourceMeta := workapiv1.ManifestResourceMeta{Version: "v1", Resource: "configmaps", Namespace: "ns1", Name: "n1"}
+	original := workapiv1.ManifestWorkStatus{
+		Conditions: []metav1.Condition{
+			{Type: workapiv1.WorkApplied, Status: metav1.ConditionTrue, Reason: "AppliedManifestWorkComplete"},
+		},
+		ResourceStatus: workapiv1.ManifestResourceStatus{Manifests: []workapiv1.ManifestCondition{{
+			ResourceMeta: resourceMeta,
+			Conditions: []metav1.Condition{
+				{Type: string(workapiv1.ManifestApplied), Status: metav1.ConditionTrue, Reason: "AppliedManifestComplete"},
+				{Type: string(workapiv1.ManifestDegraded), Status: metav1.ConditionTrue, Reason: resourceDriftedReason},
+			},
+		}}},
+	}
+	status := *original.DeepCopy()
+	meta.SetStatusCondition(&status.Conditions,
+		metav1.Condition{Type: workapiv1.WorkAvailable, Status: metav1.ConditionTrue, Reason: "ResourcesAvailable"})
+	meta.SetStatusCondition(&status.ResourceStatus.Manifests[0].Conditions,
+		metav1.Condition{Type: string(workapiv1.ManifestAvailable), Status: metav1.ConditionTrue, Reason: "ResourceAvailable"})
+	meta.RemoveStatusCondition(&status.ResourceStatus.Manifests[0].Conditions, string(workapiv1.ManifestDegraded))
+
+	// the manifest controller fails to apply the manifest meanwhile
+	latest := *original.DeepCopy()
+	meta.SetStatusCondition(&latest.Conditions,
+		metav1.Condition{Type: workapiv1.WorkApplied, Status: metav1.ConditionFalse, Reason: "AppliedManifestWorkFailed"})
+	meta.SetStatusCondition(&latest.ResourceStatus.Manifests[0].Conditions,
+		metav1.Condition{Type: string(workapiv1.ManifestApplied), Status: metav1.ConditionFalse, Reason: "AppliedManifestFailed"})
+
+	if err := withAvailabilityChanges(original, status)(&latest); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasStatusCondition(latest.Conditions, workapiv1.WorkApplied, metav1.ConditionFalse) ||
+		!hasStatusCondition(latest.Conditions, workapiv1.WorkAvailable, metav1.ConditionTrue) {
+		t.Error(spew.Sdump(latest.Conditions))
+	}
+	conditions := latest.ResourceStatus.Manifests[0].Conditions
+	if !hasStatusCondition(conditions, string(workapiv1.ManifestApplied), metav1.ConditionFalse) ||
+		!hasStatusCondition(conditions, string(workapiv1.ManifestAvailable), metav1.ConditionTrue) ||
+		meta.FindStatusCondition(conditions, string(workapiv1.ManifestDegraded)) != nil {
+		t.Error(spew.Sdump(conditions))
+	}
+}
@@ -0,0 +1,41 @@
+package statuscontroller
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+// jobAvailableStatusCondition returns the Available condition of a Job built from the condition of the resource, so
+// that a finished Job deleted once its ttlSecondsAfterFinished expired is not reported as unavailable. A Job is known
+// to be expired only if it was reported finished with the ttl before it was deleted.
+func jobAvailableStatusCondition(
+	manifest workapiv1.ManifestCondition, condition metav1.Condition, obj *unstructured.Unstructured) metav1.Condition {
+	if !helper.IsJob(manifest.ResourceMeta) {
+		return condition
+	}
+
+	switch {
+	case condition.Status == metav1.ConditionTrue && obj != nil && helper.IsJobFinishedWithTTL(obj):
+		return metav1.Condition{
+			Type:    condition.Type,
+			Status:  metav1.ConditionTrue,
+			Reason:  helper.ResourceCompletedReason,
+			Message: "Job is finished, it is deleted once its ttlSecondsAfterFinished expires",
+		}
+	case condition.Status == metav1.ConditionFalse && obj == nil:
+		existing := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestAvailable))
+		if existing == nil || (existing.Reason != helper.ResourceCompletedReason && existing.Reason != helper.ResourceCompletedAndExpiredReason) {
+			return condition
+		}
+		return metav1.Condition{
+			Type:    condition.Type,
+			Status:  metav1.ConditionTrue,
+			Reason:  helper.ResourceCompletedAndExpiredReason,
+			Message: "Job is finished and deleted once its ttlSecondsAfterFinished expired",
+		}
+	}
+	return condition
+}
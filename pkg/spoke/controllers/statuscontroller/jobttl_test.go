@@ -0,0 +1,119 @@
+package statuscontroller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestJobAvailableStatusCondition(t *testing.T) {
+	jobMeta := workapiv1.ManifestResourceMeta{Group: "batch", Version: "v1", Kind: "Job", Resource: "jobs", Namespace: "ns1", Name: "job1"}
+	available := metav1.Condition{Type: string(workapiv1.ManifestAvailable), Status: metav1.ConditionTrue, Reason: "ResourceAvailable"}
+	notAvailable := metav1.Condition{Type: string(workapiv1.ManifestAvailable), Status: metav1.ConditionFalse, Reason: "ResourceNotAvailable"}
+	newJob := func(ttl bool, conditionType string) *unstructured.Unstructured {
+		content := map[string]interface{}{
+			"status": map[string]interface{}{"conditions": []interface{}{
+				map[string]interface{}{"type": conditionType, "status": "True"},
+			}},
+		}
+		if ttl {
+			content["spec"] = map[string]interface{}{"ttlSecondsAfterFinished": int64(60)}
+		}
+		return spoketesting.NewUnstructuredWithContent("batch/v1", "Job", "ns1", "job1", content)
+	}
+
+	cases := []struct {
+		name           string
+		resourceMeta   workapiv1.ManifestResourceMeta
+		existingReason string
+		condition      metav1.Condition
+		obj            *unstructured.Unstructured
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:           "completed job with ttl",
+			resourceMeta:   jobMeta,
+			condition:      available,
+			obj:            newJob(true, "Complete"),
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: helper.ResourceCompletedReason,
+		},
+		{
+			name:           "failed job with ttl",
+			resourceMeta:   jobMeta,
+			condition:      available,
+			obj:            newJob(true, "Failed"),
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: helper.ResourceCompletedReason,
+		},
+		{
+			name:           "completed job without ttl",
+			resourceMeta:   jobMeta,
+			condition:      available,
+			obj:            newJob(false, "Complete"),
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: "ResourceAvailable",
+		},
+		{
+			name:           "running job with ttl",
+			resourceMeta:   jobMeta,
+			condition:      available,
+			obj:            newJob(true, "Suspended"),
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: "ResourceAvailable",
+		},
+		{
+			name:           "completed job expired",
+			resourceMeta:   jobMeta,
+			existingReason: helper.ResourceCompletedReason,
+			condition:      notAvailable,
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: helper.ResourceCompletedAndExpiredReason,
+		},
+		{
+			name:           "expired job stays expired",
+			resourceMeta:   jobMeta,
+			existingReason: helper.ResourceCompletedAndExpiredReason,
+			condition:      notAvailable,
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: helper.ResourceCompletedAndExpiredReason,
+		},
+		{
+			name:           "running job deleted",
+			resourceMeta:   jobMeta,
+			existingReason: "ResourceAvailable",
+			condition:      notAvailable,
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "ResourceNotAvailable",
+		},
+		{
+			name:           "other kinds deleted",
+			resourceMeta:   workapiv1.ManifestResourceMeta{Version: "v1", Kind: "Secret", Resource: "secrets", Namespace: "ns1", Name: "n1"},
+			existingReason: helper.ResourceCompletedReason,
+			condition:      notAvailable,
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "ResourceNotAvailable",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			manifest := workapiv1.ManifestCondition{ResourceMeta: c.resourceMeta}
+			if len(c.existingReason) != 0 {
+				manifest.Conditions = []metav1.Condition{
+					{Type: string(workapiv1.ManifestAvailable), Status: metav1.ConditionTrue, Reason: c.existingReason},
+				}
+			}
+			condition := jobAvailableStatusCondition(manifest, c.condition, c.obj)
+			if condition.Status != c.expectedStatus || condition.Reason != c.expectedReason {
+				t.Errorf("expected %s with reason %s, but got %v", c.expectedStatus, c.expectedReason, condition)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package statuscontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+)
+
+// MaxResourceHealthMessageLength is the max length of the message of each applied resource recorded on the
+// appliedmanifestwork, longer messages are truncated to keep the size of the appliedmanifestwork bounded.
+var MaxResourceHealthMessageLength = 256
+
+// resourceHealth is the health of an applied resource mirrored to the appliedmanifestwork.
+type resourceHealth struct {
+	Group     string `json:"group"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// LastAppliedGeneration is the generation of the resource observed on the managed cluster, it is 0 if
+	// the resource does not exist.
+	LastAppliedGeneration int64  `json:"lastAppliedGeneration"`
+	Available             bool   `json:"available"`
+	Message               string `json:"message,omitempty"`
+}
+
+func newResourceHealth(resourceMeta workapiv1.ManifestResourceMeta, generation int64, condition metav1.Condition) resourceHealth {
+	message := condition.Message
+	if len(message) > MaxResourceHealthMessageLength {
+		message = message[:MaxResourceHealthMessageLength]
+	}
+	return resourceHealth{
+		Group:                 resourceMeta.Group,
+		Resource:              resourceMeta.Resource,
+		Namespace:             resourceMeta.Namespace,
+		Name:                  resourceMeta.Name,
+		LastAppliedGeneration: generation,
+		Available:             condition.Status == metav1.ConditionTrue,
+		Message:               message,
+	}
+}
+
+// updateAppliedResourceHealth records the health of the applied resources on the appliedmanifestwork of the
+// manifestwork. It is a write on the managed cluster only, so it is done on every sync once the health changes.
+func (c *AvailableStatusController) updateAppliedResourceHealth(
+	ctx context.Context, manifestWork *workapiv1.ManifestWork, healths []resourceHealth) error {
+	if c.appliedManifestWorkClient == nil || c.appliedManifestWorkLister == nil {
+		return nil
+	}
+
+	appliedManifestWorkName := fmt.Sprintf("%s-%s", c.hubHash, manifestWork.Name)
+	appliedManifestWork, err := c.appliedManifestWorkLister.Get(appliedManifestWorkName)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !appliedManifestWork.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	if healths == nil {
+		healths = []resourceHealth{}
+	}
+	value, err := json.Marshal(healths)
+	if err != nil {
+		return err
+	}
+	if existing, ok := appliedManifestWork.Annotations[constants.AppliedResourceHealthAnnotationKey]; ok && existing == string(value) {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				constants.AppliedResourceHealthAnnotationKey: string(value),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.appliedManifestWorkClient.Patch(ctx, appliedManifestWorkName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("Failed to update resource health of AppliedManifestWork %s: %w", appliedManifestWorkName, err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package statuscontroller
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke/spoketesting"
+)
+
+func TestAppliedResourceHealth(t *testing.T) {
+	secret := spoketesting.NewUnstructuredSecret("ns1", "n1", false, "ns1-n1")
+	secret.SetGeneration(2)
+
+	testingWork, _ := spoketesting.NewManifestWork(0)
+	testingWork.Status.ResourceStatus.Manifests = []workapiv1.ManifestCondition{
+		newManifest("", "v1", "secrets", "ns1", "n1"),
+	}
+	testingAppliedWork := spoketesting.NewAppliedManifestWork("test", 0, "uid")
+
+	fakeClient := fakeworkclient.NewSimpleClientset(testingWork, testingAppliedWork)
+	fakeDynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), secret)
+	informerFactory := workinformers.NewSharedInformerFactory(fakeClient, 5*time.Minute)
+	appliedWorkStore := informerFactory.Work().V1().AppliedManifestWorks().Informer().GetStore()
+	if err := appliedWorkStore.Add(testingAppliedWork); err != nil {
+		t.Fatal(err)
+	}
+
+	controller := AvailableStatusController{
+		manifestWorkClient:        fakeClient.WorkV1().ManifestWorks(testingWork.Namespace),
+		appliedManifestWorkClient: fakeClient.WorkV1().AppliedManifestWorks(),
+		appliedManifestWorkLister: informerFactory.Work().V1().AppliedManifestWorks().Lister(),
+		spokeDynamicClient:        fakeDynamicClient,
+		restMapper:                spoketesting.NewFakeRestMapper(),
+		hubHash:                   "test",
+	}
+
+	// sync syncs the manifestwork and returns the health recorded on the appliedmanifestwork
+	sync := func() []resourceHealth {
+		if err := controller.syncManifestWork(context.TODO(), testingWork); err != nil {
+			t.Fatal(err)
+		}
+		appliedWork, err := fakeClient.WorkV1().AppliedManifestWorks().Get(context.TODO(), testingAppliedWork.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := appliedWorkStore.Update(appliedWork); err != nil {
+			t.Fatal(err)
+		}
+
+		healths := []resourceHealth{}
+		if err := json.Unmarshal([]byte(appliedWork.Annotations[constants.AppliedResourceHealthAnnotationKey]), &healths); err != nil {
+			t.Fatal(err)
+		}
+		return healths
+	}
+
+	expected := []resourceHealth{
+		{Resource: "secrets", Namespace: "ns1", Name: "n1", LastAppliedGeneration: 2, Available: true, Message: "Resource is available"},
+	}
+	if healths := sync(); !reflect.DeepEqual(healths, expected) {
+		t.Errorf("expected %s, but got %s", spew.Sdump(expected), spew.Sdump(healths))
+	}
+
+	// no patch if the health does not change
+	fakeClient.ClearActions()
+	sync()
+	for _, action := range fakeClient.Actions() {
+		if action.GetVerb() == "patch" {
+			t.Errorf("expected no patch, but got %s", spew.Sdump(action))
+		}
+	}
+
+	// the health is updated once the resource is deleted out-of-band
+	err := fakeDynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "secrets"}).
+		Namespace("ns1").Delete(context.TODO(), "n1", metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []resourceHealth{
+		{Resource: "secrets", Namespace: "ns1", Name: "n1", LastAppliedGeneration: 0, Available: false, Message: "Resource is not available"},
+	}
+	if healths := sync(); !reflect.DeepEqual(healths, expected) {
+		t.Errorf("expected %s, but got %s", spew.Sdump(expected), spew.Sdump(healths))
+	}
+}
+
+func TestResourceHealthMessageLength(t *testing.T) {
+	health := newResourceHealth(workapiv1.ManifestResourceMeta{}, 1, metav1.Condition{
+		Status:  metav1.ConditionUnknown,
+		Message: strings.Repeat("a", MaxResourceHealthMessageLength+100),
+	})
+	if len(health.Message) != MaxResourceHealthMessageLength {
+		t.Errorf("expected message truncated to %d, but got %d", MaxResourceHealthMessageLength, len(health.Message))
+	}
+	if health.Available {
+		t.Errorf("expected not available")
+	}
+}
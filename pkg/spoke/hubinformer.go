@@ -0,0 +1,134 @@
+package spoke
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/tools/cache"
+	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
+	workinformer "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
+	worklister "open-cluster-management.io/api/client/work/listers/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// lazyManifestWorkCacheSize is the max number of full manifestworks cached by the lazy lister
+	lazyManifestWorkCacheSize = 256
+	// lazyManifestWorkCacheTTL is how long a full manifestwork is cached by the lazy lister
+	lazyManifestWorkCacheTTL = 10 * time.Minute
+)
+
+// lazyManifestWorkInformer is a ManifestWorkInformer which only lists/watches the metadata of manifestworks
+// on the hub. The events of the metadata informer trigger the controllers, and the full manifestworks are
+// fetched from the hub by the lister when the controllers reconcile them.
+type lazyManifestWorkInformer struct {
+	informer cache.SharedIndexInformer
+	lister   *lazyManifestWorkLister
+}
+
+func newLazyManifestWorkInformer(
+	metadataClient metadata.Interface,
+	workClient workv1client.ManifestWorksGetter,
+	namespace string,
+	resyncPeriod time.Duration) workinformer.ManifestWorkInformer {
+	informer := metadatainformer.NewFilteredMetadataInformer(
+		metadataClient, workapiv1.GroupVersion.WithResource("manifestworks"), namespace, resyncPeriod,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil).Informer()
+
+	return &lazyManifestWorkInformer{
+		informer: informer,
+		lister: &lazyManifestWorkLister{
+			indexer:    informer.GetIndexer(),
+			workClient: workClient,
+			works:      utilcache.NewLRUExpireCache(lazyManifestWorkCacheSize),
+		},
+	}
+}
+
+func (i *lazyManifestWorkInformer) Informer() cache.SharedIndexInformer {
+	return i.informer
+}
+
+func (i *lazyManifestWorkInformer) Lister() worklister.ManifestWorkLister {
+	return i.lister
+}
+
+// lazyManifestWorkLister gets manifestworks from the metadata cache and fetches the full manifestworks
+// from the hub on demand. Fetched manifestworks are cached until their resource version changes, so that
+// unchanged manifestworks are not fetched again on every reconcile. Lists are served by a single list
+// request to the hub instead, so that listing does not fetch each manifestwork or evict the cached ones.
+type lazyManifestWorkLister struct {
+	indexer    cache.Indexer
+	workClient workv1client.ManifestWorksGetter
+	works      *utilcache.LRUExpireCache
+}
+
+func (l *lazyManifestWorkLister) List(selector labels.Selector) ([]*workapiv1.ManifestWork, error) {
+	return l.list(metav1.NamespaceAll, selector)
+}
+
+func (l *lazyManifestWorkLister) ManifestWorks(namespace string) worklister.ManifestWorkNamespaceLister {
+	return &lazyManifestWorkNamespaceLister{lister: l, namespace: namespace}
+}
+
+func (l *lazyManifestWorkLister) list(namespace string, selector labels.Selector) ([]*workapiv1.ManifestWork, error) {
+	workList, err := l.workClient.ManifestWorks(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	works := make([]*workapiv1.ManifestWork, 0, len(workList.Items))
+	for i := range workList.Items {
+		works = append(works, &workList.Items[i])
+	}
+	return works, nil
+}
+
+func (l *lazyManifestWorkLister) get(accessor metav1.Object) (*workapiv1.ManifestWork, error) {
+	key := accessor.GetNamespace() + "/" + accessor.GetName()
+	if cached, ok := l.works.Get(key); ok {
+		work := cached.(*workapiv1.ManifestWork)
+		if work.UID == accessor.GetUID() && work.ResourceVersion == accessor.GetResourceVersion() {
+			return work.DeepCopy(), nil
+		}
+	}
+
+	work, err := l.workClient.ManifestWorks(accessor.GetNamespace()).Get(context.TODO(), accessor.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	l.works.Add(key, work, lazyManifestWorkCacheTTL)
+	return work.DeepCopy(), nil
+}
+
+type lazyManifestWorkNamespaceLister struct {
+	lister    *lazyManifestWorkLister
+	namespace string
+}
+
+func (l *lazyManifestWorkNamespaceLister) List(selector labels.Selector) ([]*workapiv1.ManifestWork, error) {
+	return l.lister.list(l.namespace, selector)
+}
+
+func (l *lazyManifestWorkNamespaceLister) Get(name string) (*workapiv1.ManifestWork, error) {
+	obj, exists, err := l.lister.indexer.GetByKey(l.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(workapiv1.Resource("manifestwork"), name)
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	return l.lister.get(accessor)
+}
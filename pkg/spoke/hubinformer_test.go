@@ -0,0 +1,175 @@
+package spoke
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kuberuntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
+	fakemetadata "k8s.io/client-go/metadata/fake"
+	"k8s.io/client-go/tools/cache"
+	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	workinformer "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+func TestLazyManifestWorkLister(t *testing.T) {
+	work := newManifestWork("cluster1", "work1", 10)
+	work.ResourceVersion = "1"
+	fakeClient := fakeworkclient.NewSimpleClientset(work)
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	if err := indexer.Add(newPartialObjectMetadata(work)); err != nil {
+		t.Fatal(err)
+	}
+	lister := &lazyManifestWorkLister{
+		indexer:    indexer,
+		workClient: fakeClient.WorkV1(),
+		works:      utilcache.NewLRUExpireCache(10),
+	}
+
+	// the full manifestwork is fetched only once if it is unchanged, and a copy is returned on every get
+	for i := 0; i < 2; i++ {
+		actual, err := lister.ManifestWorks("cluster1").Get("work1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(actual.Spec.Workload.Manifests) != 10 {
+			t.Errorf("expected full manifestwork, but got %v", actual)
+		}
+		actual.Spec.Workload.Manifests = nil
+	}
+	if len(fakeClient.Actions()) != 1 {
+		t.Errorf("expected 1 get action, but got %v", fakeClient.Actions())
+	}
+
+	// the manifestworks are listed by a single list request
+	works, err := lister.ManifestWorks("cluster1").List(labels.Everything())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(works) != 1 {
+		t.Errorf("expected 1 manifestwork, but got %v", works)
+	}
+	if len(fakeClient.Actions()) != 2 || !fakeClient.Actions()[1].Matches("list", "manifestworks") {
+		t.Errorf("expected get and list actions, but got %v", fakeClient.Actions())
+	}
+
+	// the full manifestwork is fetched again once its resource version changes
+	work.ResourceVersion = "2"
+	if err := indexer.Update(newPartialObjectMetadata(work)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lister.ManifestWorks("cluster1").Get("work1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeClient.Actions()) != 3 {
+		t.Errorf("expected 3 actions, but got %v", fakeClient.Actions())
+	}
+
+	if _, err := lister.ManifestWorks("cluster1").Get("work2"); !errors.IsNotFound(err) {
+		t.Errorf("expected not found error, but got %v", err)
+	}
+}
+
+// BenchmarkHubInformerMemory compares the memory retained by the typed informer and the metadata only
+// informer on a hub with large manifestworks.
+func BenchmarkHubInformerMemory(b *testing.B) {
+	const numOfWorks, numOfManifests = 200, 50
+
+	works := []kuberuntime.Object{}
+	metadataObjs := []kuberuntime.Object{}
+	for i := 0; i < numOfWorks; i++ {
+		work := newManifestWork("cluster1", fmt.Sprintf("work%d", i), numOfManifests)
+		works = append(works, work)
+		metadataObjs = append(metadataObjs, newPartialObjectMetadata(work))
+	}
+
+	modes := map[string]func() workinformer.ManifestWorkInformer{
+		"typed": func() workinformer.ManifestWorkInformer {
+			factory := workinformers.NewSharedInformerFactoryWithOptions(
+				fakeworkclient.NewSimpleClientset(works...), 0, workinformers.WithNamespace("cluster1"))
+			return factory.Work().V1().ManifestWorks()
+		},
+		"metadata-only": func() workinformer.ManifestWorkInformer {
+			scheme := kuberuntime.NewScheme()
+			if err := metav1.AddMetaToScheme(scheme); err != nil {
+				b.Fatal(err)
+			}
+			return newLazyManifestWorkInformer(fakemetadata.NewSimpleMetadataClient(scheme, metadataObjs...),
+				fakeworkclient.NewSimpleClientset(works...).WorkV1(), "cluster1", 0)
+		},
+	}
+
+	for name, newInformer := range modes {
+		b.Run(name, func(b *testing.B) {
+			var retained uint64
+			for i := 0; i < b.N; i++ {
+				informer := newInformer()
+				before := heapAlloc()
+
+				ctx, cancel := context.WithCancel(context.Background())
+				go informer.Informer().Run(ctx.Done())
+				if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
+					b.Fatal("failed to sync informer")
+				}
+				if len(informer.Informer().GetStore().List()) != numOfWorks {
+					b.Fatal("unexpected number of manifestworks in informer")
+				}
+
+				if after := heapAlloc(); after > before {
+					retained += after - before
+				}
+				cancel()
+				runtime.KeepAlive(informer)
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "retained-bytes/op")
+		})
+	}
+}
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	time.Sleep(10 * time.Millisecond)
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func newManifestWork(namespace, name string, numOfManifests int) *workapiv1.ManifestWork {
+	work := &workapiv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			UID:       types.UID("uid-" + name),
+		},
+	}
+	data := strings.Repeat("a", 1024)
+	for i := 0; i < numOfManifests; i++ {
+		work.Spec.Workload.Manifests = append(work.Spec.Workload.Manifests, workapiv1.Manifest{
+			RawExtension: kuberuntime.RawExtension{
+				Raw: []byte(fmt.Sprintf(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"namespace":"default","name":"cm%d"},"data":{"key":"%s"}}`, i, data)),
+			},
+		})
+	}
+	return work
+}
+
+func newPartialObjectMetadata(work *workapiv1.ManifestWork) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: workapiv1.GroupVersion.String(),
+			Kind:       "ManifestWork",
+		},
+		ObjectMeta: *work.ObjectMeta.DeepCopy(),
+	}
+}
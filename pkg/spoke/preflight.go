@@ -0,0 +1,71 @@
+package spoke
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// hubPermission is a permission on the manifestworks in the cluster namespace on hub required by the agent.
+type hubPermission struct {
+	verb        string
+	subresource string
+}
+
+var requiredHubPermissions = []hubPermission{
+	{verb: "get"},
+	{verb: "list"},
+	{verb: "watch"},
+	{verb: "update", subresource: "status"},
+}
+
+// hubPreflightCheck verifies the cluster namespace exists on hub and the agent is allowed to access the
+// manifestworks in it, so that the agent fails fast with an actionable error instead of running with empty
+// informers. The existence of the namespace is not verified if the agent is not allowed to get it.
+func hubPreflightCheck(ctx context.Context, hubKubeClient kubernetes.Interface, clusterName string) error {
+	_, err := hubKubeClient.CoreV1().Namespaces().Get(ctx, clusterName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return fmt.Errorf("the cluster namespace %q does not exist on hub, check --spoke-cluster-name", clusterName)
+	case errors.IsForbidden(err):
+		klog.Warningf("Unable to verify the cluster namespace %q exists on hub: %v", clusterName, err)
+	case err != nil:
+		return fmt.Errorf("unable to get the cluster namespace %q on hub: %w", clusterName, err)
+	}
+
+	errs := []error{}
+	for _, permission := range requiredHubPermissions {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   clusterName,
+					Verb:        permission.verb,
+					Group:       "work.open-cluster-management.io",
+					Resource:    "manifestworks",
+					Subresource: permission.subresource,
+				},
+			},
+		}
+		review, err := hubKubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to review the access to manifestworks on hub: %w", err)
+		}
+		if review.Status.Allowed {
+			continue
+		}
+
+		resource := "manifestworks"
+		if len(permission.subresource) != 0 {
+			resource = fmt.Sprintf("manifestworks/%s", permission.subresource)
+		}
+		errs = append(errs, fmt.Errorf("the agent is not allowed to %s %s in the cluster namespace %q on hub, grant it with a role bound to the agent in the namespace",
+			permission.verb, resource, clusterName))
+	}
+	return utilerrors.NewAggregate(errs)
+}
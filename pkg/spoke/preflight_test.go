@@ -0,0 +1,87 @@
+package spoke
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestHubPreflightCheck(t *testing.T) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	cases := []struct {
+		name             string
+		existingObjects  []runtime.Object
+		namespaceErr     error
+		deniedVerbs      []string
+		expectedErrParts []string
+	}{
+		{
+			name:            "allowed",
+			existingObjects: []runtime.Object{namespace},
+		},
+		{
+			name:             "namespace not found",
+			expectedErrParts: []string{`the cluster namespace "cluster1" does not exist on hub`},
+		},
+		{
+			name:         "namespace forbidden",
+			namespaceErr: errors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "cluster1", fmt.Errorf("denied")),
+		},
+		{
+			name:            "permissions denied",
+			existingObjects: []runtime.Object{namespace},
+			deniedVerbs:     []string{"watch", "update"},
+			expectedErrParts: []string{
+				`not allowed to watch manifestworks in the cluster namespace "cluster1"`,
+				`not allowed to update manifestworks/status in the cluster namespace "cluster1"`,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := fakekube.NewSimpleClientset(c.existingObjects...)
+			if c.namespaceErr != nil {
+				kubeClient.PrependReactor("get", "namespaces", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, c.namespaceErr
+				})
+			}
+			kubeClient.PrependReactor("create", "selfsubjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview).DeepCopy()
+				review.Status.Allowed = true
+				for _, verb := range c.deniedVerbs {
+					if review.Spec.ResourceAttributes.Verb == verb {
+						review.Status.Allowed = false
+					}
+				}
+				return true, review, nil
+			})
+
+			err := hubPreflightCheck(context.TODO(), kubeClient, "cluster1")
+			if len(c.expectedErrParts) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error, but got nil")
+			}
+			for _, part := range c.expectedErrParts {
+				if !strings.Contains(err.Error(), part) {
+					t.Errorf("expected error containing %q, but got %v", part, err)
+				}
+			}
+		})
+	}
+}
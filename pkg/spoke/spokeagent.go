@@ -3,41 +3,143 @@ package spoke
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"open-cluster-management.io/work/pkg/constants"
 	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke/config"
+	"open-cluster-management.io/work/pkg/spoke/controllers"
 	"open-cluster-management.io/work/pkg/spoke/controllers/appliedmanifestcontroller"
 	"open-cluster-management.io/work/pkg/spoke/controllers/finalizercontroller"
 	"open-cluster-management.io/work/pkg/spoke/controllers/manifestcontroller"
 	"open-cluster-management.io/work/pkg/spoke/controllers/statuscontroller"
-	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
+	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/spf13/cobra"
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apiserver/pkg/server/healthz"
 	"k8s.io/client-go/dynamic"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 
 	workclientset "open-cluster-management.io/api/client/work/clientset/versioned"
 	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// hubWriteFailureThreshold is the number of consecutive failed status writes before pausing writes to the hub
+	hubWriteFailureThreshold = 5
+	// hubWriteBackoff is how long status writes to the hub are paused before probing the hub again
+	hubWriteBackoff = 30 * time.Second
+	// hubReadyzPath is the path of the readiness checks of the hubs, it fails while any of the hubs is not ready
+	hubReadyzPath = "/readyz/hubs"
+	// minInformerResync is the min resync period of the informers, resync is disabled if the period is 0
+	minInformerResync = 30 * time.Second
+	// liveObjectCacheTTLFraction is the fraction of the status resync interval the live applied objects are cached
+	// for, so that the objects looked up by the controllers within a resync are fetched once.
+	liveObjectCacheTTLFraction = 3
+)
+
+// The constructors of the informers, they are replaced in tests to verify the resync periods passed to them.
+var (
+	newWorkInformerFactory = workinformers.NewSharedInformerFactoryWithOptions
+	newKubeInformerFactory = kubeinformers.NewSharedInformerFactoryWithOptions
+	newCRDInformer         = manifestcontroller.NewCustomResourceDefinitionInformer
 )
 
 // WorkloadAgentOptions defines the flags for workload agent
 type WorkloadAgentOptions struct {
-	HubKubeconfigFile   string
-	SpokeKubeconfigFile string
-	SpokeClusterName    string
-	QPS                 float32
-	Burst               int
+	HubKubeconfigFile                      string
+	AdditionalHubKubeconfigFiles           []string
+	SpokeKubeconfigFile                    string
+	SpokeContext                           string
+	SpokeClusterName                       string
+	QPS                                    float32
+	Burst                                  int
+	AppliedManifestWorkEvictionGracePeriod time.Duration
+	HubMetadataOnlyInformer                bool
+	EnableManifestReferences               bool
+	PropagateProvenance                    bool
+	EnableGarbageScan                      bool
+	GarbageScanInterval                    time.Duration
+	GarbageScanMinAge                      time.Duration
+	StartupApplyQPS                        float32
+	StartupApplyBurst                      int
+	ManifestApplyTimeout                   time.Duration
+	MaxStatusSize                          int
+	StaleCacheThreshold                    time.Duration
+	EventDedupInterval                     time.Duration
+	HubWorkInformerResync                  time.Duration
+	SpokeAppliedWorkInformerResync         time.Duration
+	SpokeKubeInformerResync                time.Duration
+	MaxAppliedResources                    int
+	MaxManifests                           int
+	AllowedNamespaces                      []string
+	ForbiddenResources                     []string
+	AllowClusterScoped                     bool
+	DiscoverUntrackedResources             bool
+	DiscoveryCacheTTL                      time.Duration
+	DiscoveryNegativeCacheTTL              time.Duration
+	DefaultDeletePropagationPolicy         string
+	ResourceCacheMinResources              int
+	ResourceCacheGracePeriod               time.Duration
+	ResourceCacheNotFoundGracePeriod       time.Duration
+	StatusWriters                          int
+	PersistEventFingerprints               bool
+	HubProbeInterval                       time.Duration
+	LocalAPIAddress                        string
+	AgentConfigFile                        string
+	JobTTLSecondsAfterFinished             int64
+	ManifestWorkApplyDeadline              time.Duration
+	ImageRegistryRewrites                  map[string]string
+	PruneThresholdResources                int
+	PruneThresholdPercent                  int
+	// ManifestTransformers are registered by the distributions embedding the agent, they transform the manifests
+	// before the image registry rewrites, see helper.ManifestTransformer.
+	ManifestTransformers []helper.ManifestTransformer
 }
 
 // NewWorkloadAgentOptions returns the flags with default value set
 func NewWorkloadAgentOptions() *WorkloadAgentOptions {
 	return &WorkloadAgentOptions{
-		QPS:   50,
-		Burst: 100,
+		QPS:                                    50,
+		Burst:                                  100,
+		AppliedManifestWorkEvictionGracePeriod: 60 * time.Minute,
+		GarbageScanInterval:                    60 * time.Minute,
+		GarbageScanMinAge:                      10 * time.Minute,
+		StartupApplyQPS:                        20,
+		StartupApplyBurst:                      100,
+		ManifestApplyTimeout:                   10 * time.Second,
+		MaxStatusSize:                          helper.DefaultMaxStatusSize,
+		MaxManifests:                           helper.DefaultMaxManifests,
+		DiscoverUntrackedResources:             true,
+		StaleCacheThreshold:                    time.Minute,
+		EventDedupInterval:                     5 * time.Minute,
+		HubWorkInformerResync:                  5 * time.Minute,
+		SpokeAppliedWorkInformerResync:         5 * time.Minute,
+		SpokeKubeInformerResync:                5 * time.Minute,
+		DiscoveryCacheTTL:                      10 * time.Minute,
+		DiscoveryNegativeCacheTTL:              30 * time.Second,
+		DefaultDeletePropagationPolicy:         string(workapiv1.DeletePropagationPolicyTypeForeground),
+		ResourceCacheGracePeriod:               10 * time.Minute,
+		ResourceCacheNotFoundGracePeriod:       10 * time.Second,
+		PersistEventFingerprints:               true,
+		HubProbeInterval:                       30 * time.Second,
+		JobTTLSecondsAfterFinished:             -1,
+		ManifestWorkApplyDeadline:              2 * time.Minute,
 	}
 }
 
@@ -46,28 +148,330 @@ func (o *WorkloadAgentOptions) AddFlags(cmd *cobra.Command) {
 	flags := cmd.Flags()
 	// This command only supports reading from config
 	flags.StringVar(&o.HubKubeconfigFile, "hub-kubeconfig", o.HubKubeconfigFile, "Location of kubeconfig file to connect to hub cluster.")
+	flags.StringArrayVar(&o.AdditionalHubKubeconfigFiles, "additional-hub-kubeconfig", o.AdditionalHubKubeconfigFiles,
+		"Location of kubeconfig file to connect to another hub cluster, e.g. while the cluster is migrated between hubs. "+
+			"It can be repeated, the ManifestWorks in the cluster namespace of every hub are applied by the agent.")
 	flags.StringVar(&o.SpokeKubeconfigFile, "spoke-kubeconfig", o.SpokeKubeconfigFile,
 		"Location of kubeconfig file to connect to spoke cluster. If this is not set, will use '--kubeconfig' to build client to connect to the managed cluster.")
+	flags.StringVar(&o.SpokeContext, "spoke-context", o.SpokeContext,
+		"Context in the kubeconfig file of '--spoke-kubeconfig' to connect to spoke cluster. If this is not set, the current context is used.")
 	flags.StringVar(&o.SpokeClusterName, "spoke-cluster-name", o.SpokeClusterName, "Name of spoke cluster.")
 	flags.Float32Var(&o.QPS, "spoke-kube-api-qps", o.QPS, "QPS to use while talking with apiserver on spoke cluster.")
 	flags.IntVar(&o.Burst, "spoke-kube-api-burst", o.Burst, "Burst to use while talking with apiserver on spoke cluster.")
+	flags.DurationVar(&o.AppliedManifestWorkEvictionGracePeriod, "appliedmanifestwork-eviction-grace-period", o.AppliedManifestWorkEvictionGracePeriod,
+		"Grace period after which an AppliedManifestWork of another hub is evicted if its heartbeat is not refreshed. The "+
+			"AppliedManifestWorks without a heartbeat, e.g. of an older agent, are never evicted.")
+	flags.BoolVar(&o.HubMetadataOnlyInformer, "hub-metadata-only-informer", o.HubMetadataOnlyInformer,
+		"Only list/watch the metadata of ManifestWorks on hub, and fetch the full ManifestWorks when reconciling them. "+
+			"This reduces the memory usage of the agent on hubs with large ManifestWorks.")
+	flags.BoolVar(&o.EnableManifestReferences, "enable-manifest-references", o.EnableManifestReferences,
+		"Support manifests referencing ConfigMaps/Secrets in the cluster namespace on hub. "+
+			"The agent must be allowed to list/watch ConfigMaps and Secrets in the cluster namespace on hub.")
+	flags.BoolVar(&o.PropagateProvenance, "propagate-provenance", o.PropagateProvenance,
+		"Add labels/annotations of the hub and ManifestWork to the applied resources. "+
+			"It can be disabled by a ManifestWork with the annotation work.open-cluster-management.io/disable-provenance=true.")
+	flags.BoolVar(&o.EnableGarbageScan, "enable-garbage-scan", o.EnableGarbageScan,
+		"Periodically delete the resources owned by an AppliedManifestWork but not tracked by it, which may be leaked "+
+			"if the agent crashes while applying. Only the resources with the provenance labels are scanned, see --propagate-provenance.")
+	flags.DurationVar(&o.GarbageScanInterval, "garbage-scan-interval", o.GarbageScanInterval,
+		"Interval of the garbage scan.")
+	flags.DurationVar(&o.GarbageScanMinAge, "garbage-scan-min-age", o.GarbageScanMinAge,
+		"Min age of an untracked resource before it is deleted by the garbage scan.")
+	flags.Float32Var(&o.StartupApplyQPS, "startup-apply-qps", o.StartupApplyQPS,
+		"Max number of ManifestWorks applied per second for the first time after the agent starts. "+
+			"ManifestWorks unchanged since they were applied are not counted. It is not limited if it is not positive.")
+	flags.IntVar(&o.StartupApplyBurst, "startup-apply-burst", o.StartupApplyBurst,
+		"Burst of ManifestWorks applied for the first time after the agent starts.")
+	flags.DurationVar(&o.ManifestApplyTimeout, "manifest-apply-timeout", o.ManifestApplyTimeout,
+		"Timeout of applying a manifest, after which the other manifests of the ManifestWork are applied and the manifest is retried. "+
+			"It can be overridden by a ManifestWork with the annotation work.open-cluster-management.io/manifest-apply-timeouts. "+
+			"There is no timeout if it is not positive.")
+	flags.IntVar(&o.MaxStatusSize, "max-status-size", o.MaxStatusSize,
+		"Max size in bytes of the status of a ManifestWork. A larger status is truncated, the messages of the manifest conditions "+
+			"are dropped first and then the manifest conditions are collapsed into counts. It is not limited if it is not positive.")
+	flags.DurationVar(&o.StaleCacheThreshold, "stale-cache-threshold", o.StaleCacheThreshold,
+		"Max time the cache of ManifestWorks may be out of sync with the hub, e.g. while the hub is unreachable, before the resources "+
+			"not available are reported with the Available condition Unknown and reason StaleCache instead of False. "+
+			"It is disabled if it is not positive.")
+	flags.DurationVar(&o.EventDedupInterval, "event-dedup-interval", o.EventDedupInterval,
+		"Min interval between identical events, e.g. the same failure of applying a resource of a ManifestWork. "+
+			"Identical events are not deduplicated if it is not positive.")
+	flags.IntVar(&o.MaxAppliedResources, "max-applied-resources", o.MaxAppliedResources,
+		"Max number of resources applied by the agent across all ManifestWorks. Once it is reached, the manifests of resources "+
+			"new to a ManifestWork are not applied and the ManifestWork has the condition ResourceQuotaExceededByAgentPolicy. "+
+			"It is not limited if it is not positive.")
+	flags.IntVar(&o.MaxManifests, "max-manifests", o.MaxManifests,
+		"Max number of manifests of a ManifestWork, it should match the limit of the webhook on the hub. None of the manifests "+
+			"of a ManifestWork with more manifests is applied, and the ManifestWork has the condition TooManyManifests. "+
+			"It is not limited if it is not positive.")
+	flags.StringSliceVar(&o.ForbiddenResources, "forbidden-resources", o.ForbiddenResources,
+		"Resources in the format of resource.group, e.g. clusterrolebindings.rbac.authorization.k8s.io, which the manifests "+
+			"of ManifestWorks are not allowed to apply. The manifests of the resources are not applied and have the condition "+
+			"Applied False with reason ResourceForbiddenByAgentPolicy.")
+	flags.StringSliceVar(&o.AllowedNamespaces, "allowed-namespaces", o.AllowedNamespaces,
+		"Namespaces which the manifests of ManifestWorks are allowed to be applied to, a ManifestWork can narrow them with the "+
+			"annotation "+constants.AllowedNamespacesAnnotationKey+". The namespaced manifests without a namespace are applied "+
+			"to the first allowed namespace, and the other manifests are rejected. It is not limited if it is empty.")
+	flags.BoolVar(&o.AllowClusterScoped, "allow-cluster-scoped", o.AllowClusterScoped,
+		"Allow the cluster scoped manifests of ManifestWorks if --allowed-namespaces is set, a ManifestWork must allow them as "+
+			"well with the annotation "+constants.AllowClusterScopedAnnotationKey+" if it narrows the allowed namespaces.")
+	flags.BoolVar(&o.DiscoverUntrackedResources, "discover-untracked-resources", o.DiscoverUntrackedResources,
+		"Delete the resources owned by a deleting AppliedManifestWork but missing in its applied resources as well, e.g. the list "+
+			"is truncated by an older agent. They are discovered by listing the resources of the types in the applied resources "+
+			"with the provenance label "+constants.ManifestWorkLabelKey+".")
+	flags.DurationVar(&o.HubWorkInformerResync, "hub-work-informer-resync", o.HubWorkInformerResync,
+		"Resync period of the informers of ManifestWorks, and of ConfigMaps/Secrets if --enable-manifest-references is set, on hub. "+
+			"Every ManifestWork is reapplied on resync. The Available condition of the resources is refreshed every "+
+			statuscontroller.ControllerReSyncInterval.String()+" regardless of the resync periods. "+
+			"It must be 0 to disable resync or at least "+minInformerResync.String()+".")
+	flags.DurationVar(&o.SpokeAppliedWorkInformerResync, "spoke-appliedwork-informer-resync", o.SpokeAppliedWorkInformerResync,
+		"Resync period of the informer of AppliedManifestWorks on spoke cluster. "+
+			"It must be 0 to disable resync or at least "+minInformerResync.String()+".")
+	flags.DurationVar(&o.SpokeKubeInformerResync, "spoke-kube-informer-resync", o.SpokeKubeInformerResync,
+		"Resync period of the informer of CustomResourceDefinitions on spoke cluster. "+
+			"It must be 0 to disable resync or at least "+minInformerResync.String()+".")
+	flags.DurationVar(&o.DiscoveryCacheTTL, "discovery-cache-ttl", o.DiscoveryCacheTTL,
+		"Max time the resources discovered from the spoke cluster are cached. The cache is refreshed earlier if a resource is not "+
+			"found in it or a CRD is changed. It is never expired if it is not positive.")
+	flags.DurationVar(&o.DiscoveryNegativeCacheTTL, "discovery-negative-cache-ttl", o.DiscoveryNegativeCacheTTL,
+		"Time a resource not found on the spoke cluster is cached, e.g. the CRD of a manifest is not installed yet, before it is "+
+			"discovered again. Every lookup of a resource not found refreshes the discovery if it is not positive.")
+	flags.StringVar(&o.DefaultDeletePropagationPolicy, "default-delete-propagation-policy", o.DefaultDeletePropagationPolicy,
+		"Delete propagation policy of the ManifestWorks without a deleteOption, Foreground or Orphan. It is recorded on the "+
+			"AppliedManifestWork once the ManifestWork is applied, changing it does not affect the ManifestWorks already applied.")
+	flags.IntVar(&o.ResourceCacheMinResources, "resource-cache-min-resources", o.ResourceCacheMinResources,
+		"Min number of applied resources of a type in a namespace, across all ManifestWorks, to watch them with an informer "+
+			"for the availability checks instead of fetching each of them from the spoke cluster on every check. The agent must be "+
+			"allowed to list/watch the resources cluster wide. Informers are not used if it is not positive, which is the default.")
+	flags.DurationVar(&o.ResourceCacheGracePeriod, "resource-cache-grace-period", o.ResourceCacheGracePeriod,
+		"Time an informer of the applied resources is kept after fewer resources than --resource-cache-min-resources are applied, "+
+			"so that it is not restarted over and over while the ManifestWorks are updated.")
+	flags.DurationVar(&o.ResourceCacheNotFoundGracePeriod, "resource-cache-not-found-grace-period", o.ResourceCacheNotFoundGracePeriod,
+		"Time since a manifest is applied during which its resource not found by an informer of --resource-cache-min-resources "+
+			"is fetched from the spoke cluster before it is reported unavailable, since the informer may not have caught up with "+
+			"the resource just created. It is disabled if it is not positive.")
+	flags.IntVar(&o.StatusWriters, "status-writers", o.StatusWriters,
+		"Number of workers writing the status of ManifestWorks to the hub, so that the ManifestWorks are applied without "+
+			"waiting on the hub, the availability of the ManifestWorks is written by them as well. If it is 0, which is the default, "+
+			"the status is written while the ManifestWorks are reconciled.")
+	flags.BoolVar(&o.PersistEventFingerprints, "persist-event-fingerprints", o.PersistEventFingerprints,
+		"Record the fingerprint of the last apply events of each ManifestWork on its AppliedManifestWork, so that the events "+
+			"are only emitted once the outcome of the apply is changed, even after the agent restarts.")
+	flags.DurationVar(&o.HubProbeInterval, "hub-probe-interval", o.HubProbeInterval,
+		"Interval of the requests probing each hub to record the round trip latency of the hub. The probes back off while "+
+			"the status writes to the hub are paused. The hubs are not probed if it is not positive.")
+	flags.StringVar(&o.LocalAPIAddress, "local-api-address", o.LocalAPIAddress,
+		"Address of the read-only API of the ManifestWorks applied by the agent for the tooling on the managed cluster, "+
+			"e.g. unix:///var/run/work-agent/api.sock or localhost:8443. A unix socket is only accessible by the user of the "+
+			"agent. It is not served if it is empty.")
+	flags.StringVar(&o.AgentConfigFile, "agent-config", o.AgentConfigFile,
+		"Location of the config file of the agent, e.g. a mounted ConfigMap, superseding the flags. The intervals, rate limits "+
+			"and log verbosity in the file are reloaded once it is changed, the hub kubeconfig and cluster name take effect only "+
+			"once the agent is restarted. It is distinct from --config, which restarts the agent once its file is changed.")
+	flags.Int64Var(&o.JobTTLSecondsAfterFinished, "job-ttl-seconds-after-finished", o.JobTTLSecondsAfterFinished,
+		"ttlSecondsAfterFinished of the Job manifests of ManifestWorks which do not set it, so that the finished Jobs and their "+
+			"pods are deleted by the TTL controller of the spoke cluster. The Jobs deleted once the ttl expires are Available "+
+			"with the reason "+helper.ResourceCompletedAndExpiredReason+" and are not applied again. It is not injected if it "+
+			"is negative.")
+	flags.DurationVar(&o.ManifestWorkApplyDeadline, "manifestwork-apply-deadline", o.ManifestWorkApplyDeadline,
+		"Deadline of applying the manifests of a ManifestWork in a reconcile. Once it is exceeded, the manifests applied so far "+
+			"are reported with the condition "+constants.WorkProgressing+" and the others are applied by the next reconcile. "+
+			"There is no deadline if it is not positive.")
+	flags.StringToStringVar(&o.ImageRegistryRewrites, "image-registry-rewrites", o.ImageRegistryRewrites,
+		"Registry prefixes of the images of the containers in the manifests rewritten before they are applied, e.g. "+
+			"docker.io=mirror.example.com/dockerhub pulls docker.io/library/nginx from mirror.example.com/dockerhub/library/nginx. "+
+			"A prefix matches whole components of the image, and the longest prefix matched is rewritten.")
+	flags.IntVar(&o.PruneThresholdResources, "prune-threshold-resources", o.PruneThresholdResources,
+		"Max number of the resources removed from a ManifestWork pruned by a reconcile. A larger prune is paused with the "+
+			"condition "+constants.WorkPruneRequiresConfirmation+" until the hub confirms it with the annotation "+
+			constants.PruneConfirmationAnnotationKey+". It is not limited if it is not positive.")
+	flags.IntVar(&o.PruneThresholdPercent, "prune-threshold-percent", o.PruneThresholdPercent,
+		"Max percent of the resources applied by a ManifestWork pruned by a reconcile once they are removed from the "+
+			"ManifestWork. A larger prune is paused the same way as --prune-threshold-resources. It is not limited if it is "+
+			"not positive.")
 }
 
-// RunWorkloadAgent starts the controllers on agent to process work from hub.
-func (o *WorkloadAgentOptions) RunWorkloadAgent(ctx context.Context, controllerContext *controllercmd.ControllerContext) error {
-	// build hub client and informer
-	hubRestConfig, err := clientcmd.BuildConfigFromFlags("" /* leave masterurl as empty */, o.HubKubeconfigFile)
-	if err != nil {
+// Validate verifies the flags
+func (o *WorkloadAgentOptions) Validate() error {
+	if len(o.SpokeClusterName) == 0 {
+		return fmt.Errorf("--spoke-cluster-name is required")
+	}
+	if errs := validation.IsDNS1123Label(o.SpokeClusterName); len(errs) > 0 {
+		return fmt.Errorf("--spoke-cluster-name %q is invalid: %s", o.SpokeClusterName, strings.Join(errs, ", "))
+	}
+	hubKubeconfigFiles := sets.NewString()
+	for _, file := range o.hubKubeconfigFiles() {
+		if hubKubeconfigFiles.Has(file) {
+			return fmt.Errorf("the hub kubeconfig file %q is specified more than once", file)
+		}
+		hubKubeconfigFiles.Insert(file)
+	}
+	if len(o.SpokeContext) != 0 && len(o.SpokeKubeconfigFile) == 0 {
+		return fmt.Errorf("--spoke-context requires --spoke-kubeconfig")
+	}
+
+	switch workapiv1.DeletePropagationPolicyType(o.DefaultDeletePropagationPolicy) {
+	case workapiv1.DeletePropagationPolicyTypeForeground, workapiv1.DeletePropagationPolicyTypeOrphan:
+	default:
+		return fmt.Errorf("--default-delete-propagation-policy must be %s or %s, but got %q",
+			workapiv1.DeletePropagationPolicyTypeForeground, workapiv1.DeletePropagationPolicyTypeOrphan, o.DefaultDeletePropagationPolicy)
+	}
+
+	resyncs := []struct {
+		flag   string
+		resync time.Duration
+	}{
+		{flag: "hub-work-informer-resync", resync: o.HubWorkInformerResync},
+		{flag: "spoke-appliedwork-informer-resync", resync: o.SpokeAppliedWorkInformerResync},
+		{flag: "spoke-kube-informer-resync", resync: o.SpokeKubeInformerResync},
+	}
+	for _, r := range resyncs {
+		if r.resync != 0 && r.resync < minInformerResync {
+			return fmt.Errorf("--%s must be 0 or at least %s, but got %s", r.flag, minInformerResync, r.resync)
+		}
+	}
+
+	for _, namespace := range o.AllowedNamespaces {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return fmt.Errorf("--allowed-namespaces %q is invalid: %s", namespace, strings.Join(errs, ", "))
+		}
+	}
+
+	if _, err := o.forbiddenResources(); err != nil {
 		return err
 	}
-	hubhash := helper.HubHash(hubRestConfig.Host)
 
-	hubWorkClient, err := workclientset.NewForConfig(hubRestConfig)
+	if len(o.LocalAPIAddress) > 0 {
+		if err := helper.ValidateLocalAPIAddress(o.LocalAPIAddress); err != nil {
+			return fmt.Errorf("--local-api-address is invalid: %w", err)
+		}
+	}
+
+	if _, err := helper.NewImageRegistryRewriter(o.ImageRegistryRewrites); err != nil {
+		return fmt.Errorf("--image-registry-rewrites is invalid: %w", err)
+	}
+	if o.PruneThresholdPercent > 100 {
+		return fmt.Errorf("--prune-threshold-percent must be at most 100, but got %d", o.PruneThresholdPercent)
+	}
+	return nil
+}
+
+// forbiddenResources returns the parsed resources of --forbidden-resources.
+func (o *WorkloadAgentOptions) forbiddenResources() ([]schema.GroupResource, error) {
+	return parseForbiddenResources(o.ForbiddenResources)
+}
+
+// parseForbiddenResources parses the resources in the format of resource.group.
+func parseForbiddenResources(values []string) ([]schema.GroupResource, error) {
+	var resources []schema.GroupResource
+	for _, value := range values {
+		resource, err := config.ParseGroupResource(value)
+		if err != nil {
+			return nil, fmt.Errorf("--forbidden-resources is invalid: %w", err)
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// manifestTransformers returns the transformers registered with the options, followed by the image registry
+// rewriter if there are image registry rewrites.
+func (o *WorkloadAgentOptions) manifestTransformers() ([]helper.ManifestTransformer, error) {
+	transformers := append([]helper.ManifestTransformer{}, o.ManifestTransformers...)
+	if len(o.ImageRegistryRewrites) == 0 {
+		return transformers, nil
+	}
+	rewriter, err := helper.NewImageRegistryRewriter(o.ImageRegistryRewrites)
 	if err != nil {
+		return nil, err
+	}
+	return append(transformers, rewriter), nil
+}
+
+// newHubInformers returns the informer factories of ManifestWorks and ConfigMaps/Secrets in the cluster namespace
+// on hub, the factory of ConfigMaps/Secrets is nil if the hub kube client is nil.
+func (o *WorkloadAgentOptions) newHubInformers(
+	hubWorkClient workclientset.Interface, hubKubeClient kubernetes.Interface) (workinformers.SharedInformerFactory, kubeinformers.SharedInformerFactory) {
+	workInformerFactory := newWorkInformerFactory(hubWorkClient, o.HubWorkInformerResync, workinformers.WithNamespace(o.SpokeClusterName))
+	if hubKubeClient == nil {
+		return workInformerFactory, nil
+	}
+	return workInformerFactory, newKubeInformerFactory(hubKubeClient, o.HubWorkInformerResync, kubeinformers.WithNamespace(o.SpokeClusterName))
+}
+
+// newSpokeInformers returns the informer factory of AppliedManifestWorks and the informer of CustomResourceDefinitions
+// on spoke cluster.
+func (o *WorkloadAgentOptions) newSpokeInformers(
+	spokeWorkClient workclientset.Interface, spokeAPIExtensionClient apiextensionsclient.Interface) (workinformers.SharedInformerFactory, cache.SharedIndexInformer, error) {
+	spokeWorkInformerFactory := newWorkInformerFactory(spokeWorkClient, o.SpokeAppliedWorkInformerResync)
+	// Index the AppliedManifestWorks by hub hash, ManifestWork and applied resource uid for the lookups of the controllers.
+	if err := helper.AddAppliedManifestWorkIndexers(spokeWorkInformerFactory.Work().V1().AppliedManifestWorks().Informer()); err != nil {
+		return nil, nil, err
+	}
+	return spokeWorkInformerFactory, newCRDInformer(spokeAPIExtensionClient, o.SpokeKubeInformerResync), nil
+}
+
+// hubKubeconfigFiles returns the kubeconfig files of all the hubs the agent connects to.
+func (o *WorkloadAgentOptions) hubKubeconfigFiles() []string {
+	return append([]string{o.HubKubeconfigFile}, o.AdditionalHubKubeconfigFiles...)
+}
+
+// spokeClients are the clients and informers of the spoke cluster shared by the controllers of all the hubs.
+type spokeClients struct {
+	dynamicClient       dynamic.Interface
+	kubeClient          kubernetes.Interface
+	apiExtensionClient  apiextensionsclient.Interface
+	workClient          workclientset.Interface
+	workInformerFactory workinformers.SharedInformerFactory
+	crdInformer         cache.SharedIndexInformer
+	restMapper          meta.RESTMapper
+	agentID             string
+	// resourceCache is nil if the applied resources are not watched by informers
+	resourceCache *helper.ResourceCache
+	// liveObjects caches the live applied objects looked up by the controllers of all the hubs
+	liveObjects *helper.LiveObjectCache
+	// localAPI is nil if the local API is not served
+	localAPI *helper.LocalAPI
+	// rateLimiters limit the requests of the clients, the rates are changed once the agent config is reloaded
+	rateLimiters []*helper.ReloadableRateLimiter
+	// applyLimits are the limits of applying the ManifestWorks of all the hubs
+	applyLimits *manifestcontroller.ApplyLimits
+	// transformers transform the manifests of the ManifestWorks of all the hubs before they are applied
+	transformers []helper.ManifestTransformer
+	// updateFieldManager is the field manager of the resources updated by the agent with the spoke clients
+	updateFieldManager string
+	// hubReadinessChecks are the readiness checks of the hubs, e.g. the status writes to a hub are paused
+	hubReadinessChecks []healthz.HealthChecker
+	// hubProbes are the probes of the hubs, the probes are only added if the hubs are probed
+	hubProbes *helper.HubProbes
+}
+
+// rateLimited returns a copy of the rest config whose requests are limited by a rate limiter of its own with the qps
+// and burst, the rate limiter is recorded so that its rate can be changed.
+func (s *spokeClients) rateLimited(restConfig *rest.Config, qps float32, burst int) *rest.Config {
+	limiter := helper.NewReloadableRateLimiter(qps, burst)
+	s.rateLimiters = append(s.rateLimiters, limiter)
+	copied := rest.CopyConfig(restConfig)
+	copied.QPS, copied.Burst, copied.RateLimiter = qps, burst, limiter
+	return copied
+}
+
+// RunWorkloadAgent starts the controllers on agent to process work from hub.
+func (o *WorkloadAgentOptions) RunWorkloadAgent(ctx context.Context, controllerContext *controllercmd.ControllerContext) error {
+	// The agent config supersedes the flags
+	var reloader *agentConfigReloader
+	if len(o.AgentConfigFile) > 0 {
+		var err error
+		if reloader, err = o.newAgentConfigReloader(); err != nil {
+			return err
+		}
+	}
+	if err := o.Validate(); err != nil {
 		return err
 	}
-	// Only watch the cluster namespace on hub
-	workInformerFactory := workinformers.NewSharedInformerFactoryWithOptions(hubWorkClient, 5*time.Minute, workinformers.WithNamespace(o.SpokeClusterName))
+
+	// Drop the events identical to one recorded recently, e.g. by a ManifestWork retried over and over.
+	recorder := helper.NewEventDeduplicator(o.EventDedupInterval).Wrap(controllerContext.EventRecorder)
 
 	// load spoke client config and create spoke clients,
 	// the work agent may not running in the spoke/managed cluster.
@@ -76,92 +480,316 @@ func (o *WorkloadAgentOptions) RunWorkloadAgent(ctx context.Context, controllerC
 		return err
 	}
 
-	spokeRestConfig.QPS = o.QPS
-	spokeRestConfig.Burst = o.Burst
-	spokeDynamicClient, err := dynamic.NewForConfig(spokeRestConfig)
+	forbiddenResources, err := o.forbiddenResources()
+	if err != nil {
+		return err
+	}
+	spoke := &spokeClients{
+		applyLimits: manifestcontroller.NewApplyLimits(o.ManifestApplyTimeout, o.MaxAppliedResources, o.MaxManifests,
+			forbiddenResources),
+		hubProbes:          helper.NewHubProbes(),
+		updateFieldManager: helper.UpdateFieldManager(spokeRestConfig),
+	}
+	spoke.transformers, err = o.manifestTransformers()
+	if err != nil {
+		return err
+	}
+	spoke.dynamicClient, err = dynamic.NewForConfig(spoke.rateLimited(spokeRestConfig, o.QPS, o.Burst))
+	if err != nil {
+		return err
+	}
+	spoke.kubeClient, err = kubernetes.NewForConfig(spoke.rateLimited(spokeRestConfig, o.QPS, o.Burst))
+	if err != nil {
+		return err
+	}
+	spoke.apiExtensionClient, err = apiextensionsclient.NewForConfig(spoke.rateLimited(spokeRestConfig, o.QPS, o.Burst))
+	if err != nil {
+		return err
+	}
+	spoke.workClient, err = workclientset.NewForConfig(spoke.rateLimited(spokeRestConfig, o.QPS, o.Burst))
 	if err != nil {
 		return err
 	}
-	spokeKubeClient, err := kubernetes.NewForConfig(spokeRestConfig)
+	spoke.workInformerFactory, spoke.crdInformer, err = o.newSpokeInformers(spoke.workClient, spoke.apiExtensionClient)
 	if err != nil {
 		return err
 	}
-	spokeAPIExtensionClient, err := apiextensionsclient.NewForConfig(spokeRestConfig)
+	restMapper := helper.NewCachedRESTMapper(spoke.kubeClient.Discovery(), o.DiscoveryCacheTTL, o.DiscoveryNegativeCacheTTL)
+	spoke.crdInformer.AddEventHandler(restMapper.InvalidationHandler())
+	spoke.restMapper = restMapper
+	spoke.agentID, err = loadOrCreateAgentID(ctx, spoke.kubeClient, controllerContext.OperatorNamespace)
 	if err != nil {
 		return err
 	}
-	spokeWorkClient, err := workclientset.NewForConfig(spokeRestConfig)
+	spoke.liveObjects = helper.NewLiveObjectCache(spoke.dynamicClient, statuscontroller.ControllerReSyncInterval/liveObjectCacheTTLFraction)
+	go spoke.liveObjects.Run(ctx)
+	if o.ResourceCacheMinResources > 0 {
+		// The informers of the applied resources are shared by the controllers of all the hubs.
+		spoke.resourceCache = helper.NewResourceCache(spoke.dynamicClient, spoke.liveObjects, o.ResourceCacheMinResources, o.ResourceCacheGracePeriod)
+		go spoke.resourceCache.Run(ctx)
+	}
+	if len(o.LocalAPIAddress) > 0 {
+		spoke.localAPI = helper.NewLocalAPI(spoke.workInformerFactory.Work().V1().AppliedManifestWorks().Informer().GetIndexer())
+	}
+
+	// Run a set of controllers for each hub, the AppliedManifestWorks of the hubs are separated by the hub hash.
+	hubHashes := map[string]string{}
+	for _, hubKubeconfigFile := range o.hubKubeconfigFiles() {
+		hubRestConfig, err := clientcmd.BuildConfigFromFlags("" /* leave masterurl as empty */, hubKubeconfigFile)
+		if err != nil {
+			return err
+		}
+		hubhash := helper.HubHash(hubRestConfig.Host)
+		if file, ok := hubHashes[hubhash]; ok {
+			return fmt.Errorf("the hub kubeconfig files %q and %q connect to the same hub %q", file, hubKubeconfigFile, hubRestConfig.Host)
+		}
+		hubHashes[hubhash] = hubKubeconfigFile
+
+		if err := o.runHubControllers(ctx, recorder, hubRestConfig, spoke); err != nil {
+			return fmt.Errorf("unable to run the controllers of the hub in %q: %w", hubKubeconfigFile, err)
+		}
+	}
+
+	// Serve the states of the controllers for debugging if the agent serves debug info
+	if controllerContext.Server != nil {
+		controllerContext.Server.Handler.NonGoRestfulMux.HandleFunc("/debug/works", controllers.DebugHandler)
+		controllerContext.Server.Handler.NonGoRestfulMux.Handle("/debug/hubs", spoke.hubProbes)
+		// The readyz checks of the server are registered once the server is built before the agent is started, so
+		// the checks of the hubs are served with a path of their own.
+		healthz.InstallPathHandler(controllerContext.Server.Handler.NonGoRestfulMux, hubReadyzPath, spoke.hubReadinessChecks...)
+	}
+
+	// Serve the read-only API of the ManifestWorks for the tooling on the managed cluster
+	if spoke.localAPI != nil {
+		listener, err := helper.ListenLocalAPI(o.LocalAPIAddress)
+		if err != nil {
+			return fmt.Errorf("unable to listen on %q for the local API: %w", o.LocalAPIAddress, err)
+		}
+		go func() {
+			if err := helper.ServeLocalAPI(ctx, listener, spoke.localAPI.Handler()); err != nil {
+				klog.Errorf("Failed to serve the local API on %q: %v", o.LocalAPIAddress, err)
+			}
+		}()
+	}
+
+	// Reload the agent config once it is changed
+	if reloader != nil {
+		reloader.rateLimiters, reloader.applyLimits, reloader.hubProbes = spoke.rateLimiters, spoke.applyLimits, spoke.hubProbes
+		if err := reloader.run(ctx.Done()); err != nil {
+			return err
+		}
+	}
+
+	go spoke.workInformerFactory.Start(ctx.Done())
+	go spoke.crdInformer.Run(ctx.Done())
+	<-ctx.Done()
+	return nil
+}
+
+// runHubControllers starts the informers of the hub and the controllers applying the ManifestWorks of the hub with
+// the spoke clients.
+func (o *WorkloadAgentOptions) runHubControllers(
+	ctx context.Context, recorder events.Recorder, hubRestConfig *rest.Config, spoke *spokeClients) error {
+	hubhash := helper.HubHash(hubRestConfig.Host)
+	klog.Infof("Running the controllers of hub %s with hub hash %s", hubRestConfig.Host, hubhash)
+	hubWorkClient, err := workclientset.NewForConfig(hubRestConfig)
 	if err != nil {
 		return err
 	}
-	spokeWorkInformerFactory := workinformers.NewSharedInformerFactory(spokeWorkClient, 5*time.Minute)
-	restMapper, err := apiutil.NewDynamicRESTMapper(spokeRestConfig, apiutil.WithLazyDiscovery)
+	// Pause status writes to the hub when it is unreachable, and probe the hub with a cheap list
+	// request before resuming.
+	hubCircuitBreaker := helper.NewHubCircuitBreaker(hubhash, hubWriteFailureThreshold, hubWriteBackoff, func(ctx context.Context) error {
+		_, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).List(ctx, metav1.ListOptions{Limit: 1})
+		return err
+	})
+	spoke.hubReadinessChecks = append(spoke.hubReadinessChecks, hubCircuitBreaker)
+	hubManifestWorkClient := helper.NewCircuitBreakingManifestWorkClient(
+		hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName), hubCircuitBreaker)
+	// Truncate the status of ManifestWorks to keep it under the max size.
+	hubManifestWorkClient = helper.NewStatusBudgetingManifestWorkClient(
+		hubManifestWorkClient, helper.NewStatusBudgeter(o.MaxStatusSize))
+	hubKubeClient, err := kubernetes.NewForConfig(hubRestConfig)
 	if err != nil {
 		return err
 	}
+	// Fail fast if the cluster namespace on hub is not accessible, the informers would be empty silently otherwise
+	if err := hubPreflightCheck(ctx, hubKubeClient, o.SpokeClusterName); err != nil {
+		return err
+	}
+	// Watch configmaps/secrets in the cluster namespace on hub only if manifest references are enabled
+	var hubReferenceClient kubernetes.Interface
+	if o.EnableManifestReferences {
+		hubReferenceClient = hubKubeClient
+	}
+	// Only watch the cluster namespace on hub
+	workInformerFactory, hubKubeInformers := o.newHubInformers(hubWorkClient, hubReferenceClient)
+	manifestWorkInformer := workInformerFactory.Work().V1().ManifestWorks()
+	if o.HubMetadataOnlyInformer {
+		hubMetadataClient, err := metadata.NewForConfig(hubRestConfig)
+		if err != nil {
+			return err
+		}
+		manifestWorkInformer = newLazyManifestWorkInformer(hubMetadataClient, hubWorkClient.WorkV1(), o.SpokeClusterName, o.HubWorkInformerResync)
+	}
+	appliedManifestWorkInformer := spoke.workInformerFactory.Work().V1().AppliedManifestWorks()
+	if spoke.localAPI != nil {
+		spoke.localAPI.AddHub(hubhash, manifestWorkInformer.Lister().ManifestWorks(o.SpokeClusterName))
+	}
 
+	// Track the baselines of the applied resources to detect the modifications out of band, the baselines are keyed
+	// by the names of the ManifestWorks, so they are tracked for each hub.
+	driftTracker := helper.NewDriftTracker()
+	// Write the status of the ManifestWorks applied to the hub asynchronously.
+	var statusWriter *helper.StatusWriter
+	if o.StatusWriters > 0 {
+		statusWriter = helper.NewStatusWriter(hubManifestWorkClient, manifestWorkInformer.Lister().ManifestWorks(o.SpokeClusterName).Get)
+	}
+	manifestWorkOptions := manifestcontroller.ManifestWorkControllerOptions{
+		HubKubeInformers:               hubKubeInformers,
+		SpokeCRDInformer:               spoke.crdInformer,
+		PropagateProvenance:            o.PropagateProvenance,
+		StartupApplyQPS:                o.StartupApplyQPS,
+		StartupApplyBurst:              o.StartupApplyBurst,
+		Limits:                         spoke.applyLimits,
+		AllowedNamespaces:              o.AllowedNamespaces,
+		AllowClusterScoped:             o.AllowClusterScoped,
+		DefaultDeletePropagationPolicy: workapiv1.DeletePropagationPolicyType(o.DefaultDeletePropagationPolicy),
+		DriftTracker:                   driftTracker,
+		StatusWriter:                   statusWriter,
+		PersistEventFingerprints:       o.PersistEventFingerprints,
+		LiveObjects:                    spoke.liveObjects,
+		ApplyDeadline:                  o.ManifestWorkApplyDeadline,
+		Transformers:                   spoke.transformers,
+		UpdateFieldManager:             spoke.updateFieldManager,
+	}
+	if o.JobTTLSecondsAfterFinished >= 0 {
+		jobTTLSecondsAfterFinished := o.JobTTLSecondsAfterFinished
+		manifestWorkOptions.JobTTLSecondsAfterFinished = &jobTTLSecondsAfterFinished
+	}
 	manifestWorkController := manifestcontroller.NewManifestWorkController(
 		ctx,
-		controllerContext.EventRecorder,
-		spokeDynamicClient,
-		spokeKubeClient,
-		spokeAPIExtensionClient,
-		hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName),
-		workInformerFactory.Work().V1().ManifestWorks(),
-		workInformerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks(o.SpokeClusterName),
-		spokeWorkClient.WorkV1().AppliedManifestWorks(),
-		spokeWorkInformerFactory.Work().V1().AppliedManifestWorks(),
+		recorder,
+		spoke.dynamicClient,
+		spoke.kubeClient,
+		spoke.apiExtensionClient,
+		hubManifestWorkClient,
+		manifestWorkInformer,
+		manifestWorkInformer.Lister().ManifestWorks(o.SpokeClusterName),
+		spoke.workClient.WorkV1().AppliedManifestWorks(),
+		appliedManifestWorkInformer,
 		hubhash,
-		restMapper,
+		spoke.restMapper,
+		manifestWorkOptions,
 	)
 	addFinalizerController := finalizercontroller.NewAddFinalizerController(
-		controllerContext.EventRecorder,
-		hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName),
-		workInformerFactory.Work().V1().ManifestWorks(),
-		workInformerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks(o.SpokeClusterName),
+		recorder,
+		hubManifestWorkClient,
+		manifestWorkInformer,
+		manifestWorkInformer.Lister().ManifestWorks(o.SpokeClusterName),
+		hubhash,
 	)
 	appliedManifestWorkFinalizeController := finalizercontroller.NewAppliedManifestWorkFinalizeController(
-		controllerContext.EventRecorder,
-		spokeDynamicClient,
-		spokeWorkClient.WorkV1().AppliedManifestWorks(),
-		spokeWorkInformerFactory.Work().V1().AppliedManifestWorks(),
+		recorder,
+		spoke.dynamicClient,
+		spoke.workClient.WorkV1().AppliedManifestWorks(),
+		appliedManifestWorkInformer,
+		hubhash, spoke.agentID,
+		o.DiscoverUntrackedResources,
+	)
+	unmanagedAppliedManifestWorkController := finalizercontroller.NewUnmanagedAppliedWorkController(
+		recorder,
+		spoke.workClient.WorkV1().AppliedManifestWorks(),
+		appliedManifestWorkInformer,
+		o.AppliedManifestWorkEvictionGracePeriod,
+		hubhash,
 	)
 	manifestWorkFinalizeController := finalizercontroller.NewManifestWorkFinalizeController(
-		controllerContext.EventRecorder,
-		hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName),
-		workInformerFactory.Work().V1().ManifestWorks(),
-		workInformerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks(o.SpokeClusterName),
-		spokeWorkClient.WorkV1().AppliedManifestWorks(),
-		spokeWorkInformerFactory.Work().V1().AppliedManifestWorks(),
+		recorder,
+		spoke.dynamicClient,
+		hubManifestWorkClient,
+		manifestWorkInformer,
+		manifestWorkInformer.Lister().ManifestWorks(o.SpokeClusterName),
+		spoke.workClient.WorkV1().AppliedManifestWorks(),
+		appliedManifestWorkInformer,
 		hubhash,
 	)
 	appliedManifestWorkController := appliedmanifestcontroller.NewAppliedManifestWorkController(
-		controllerContext.EventRecorder,
-		spokeDynamicClient,
-		hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName),
-		workInformerFactory.Work().V1().ManifestWorks(),
-		workInformerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks(o.SpokeClusterName),
-		spokeWorkClient.WorkV1().AppliedManifestWorks(),
-		spokeWorkInformerFactory.Work().V1().AppliedManifestWorks(),
+		recorder,
+		spoke.dynamicClient,
+		hubManifestWorkClient,
+		manifestWorkInformer,
+		manifestWorkInformer.Lister().ManifestWorks(o.SpokeClusterName),
+		spoke.workClient.WorkV1().AppliedManifestWorks(),
+		appliedManifestWorkInformer,
 		hubhash,
+		appliedmanifestcontroller.PruneThreshold{MaxResources: o.PruneThresholdResources, MaxPercent: o.PruneThresholdPercent},
 	)
+	manifestWorkCacheFreshness, err := helper.NewCacheFreshness(manifestWorkInformer.Informer())
+	if err != nil {
+		return err
+	}
 	availableStatusController := statuscontroller.NewAvailableStatusController(
-		controllerContext.EventRecorder,
-		spokeDynamicClient,
-		hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName),
-		workInformerFactory.Work().V1().ManifestWorks(),
-		workInformerFactory.Work().V1().ManifestWorks().Lister().ManifestWorks(o.SpokeClusterName),
+		recorder,
+		spoke.dynamicClient,
+		hubManifestWorkClient,
+		manifestWorkInformer,
+		manifestWorkInformer.Lister().ManifestWorks(o.SpokeClusterName),
+		spoke.workClient.WorkV1().AppliedManifestWorks(),
+		appliedManifestWorkInformer,
+		hubhash,
+		spoke.restMapper,
+		statuscontroller.AvailableStatusControllerOptions{
+			CacheFreshness:      manifestWorkCacheFreshness,
+			StaleCacheThreshold: o.StaleCacheThreshold,
+			DriftTracker:        driftTracker,
+			ResourceCache:       spoke.resourceCache,
+			LiveObjects:         spoke.liveObjects,
+			NotFoundGracePeriod: o.ResourceCacheNotFoundGracePeriod,
+			StatusWriter:        statusWriter,
+		},
 	)
 
+	if o.EnableGarbageScan {
+		garbageScanController := appliedmanifestcontroller.NewGarbageScanController(
+			recorder,
+			spoke.dynamicClient,
+			spoke.kubeClient.Discovery(),
+			manifestWorkInformer,
+			manifestWorkInformer.Lister().ManifestWorks(o.SpokeClusterName),
+			appliedManifestWorkInformer,
+			hubhash,
+			o.GarbageScanInterval,
+			o.GarbageScanMinAge,
+		)
+		go garbageScanController.Run(ctx, 1)
+	}
+
 	go workInformerFactory.Start(ctx.Done())
-	go spokeWorkInformerFactory.Start(ctx.Done())
+	if o.HubMetadataOnlyInformer {
+		go manifestWorkInformer.Informer().Run(ctx.Done())
+	}
+	if hubKubeInformers != nil {
+		go hubKubeInformers.Start(ctx.Done())
+	}
 	go addFinalizerController.Run(ctx, 1)
 	go appliedManifestWorkFinalizeController.Run(ctx, 1)
+	go unmanagedAppliedManifestWorkController.Run(ctx, 1)
 	go appliedManifestWorkController.Run(ctx, 1)
 	go manifestWorkController.Run(ctx, 1)
+	if statusWriter != nil {
+		go statusWriter.Run(ctx, o.StatusWriters)
+	}
 	go manifestWorkFinalizeController.Run(ctx, 1)
 	go availableStatusController.Run(ctx, 1)
-	<-ctx.Done()
+	if o.HubProbeInterval > 0 {
+		// Record the round trip latency of the hub with a cheap get of the cluster namespace
+		hubProbe := helper.NewHubProbe(hubhash, hubRestConfig.Host, o.HubProbeInterval, hubCircuitBreaker, func(ctx context.Context) error {
+			_, err := hubKubeClient.CoreV1().Namespaces().Get(ctx, o.SpokeClusterName, metav1.GetOptions{})
+			return err
+		})
+		spoke.hubProbes.Add(hubProbe)
+		go hubProbe.Run(ctx)
+	}
 	return nil
 }
 
@@ -171,7 +799,9 @@ func (o *WorkloadAgentOptions) spokeKubeConfig(controllerContext *controllercmd.
 		return controllerContext.KubeConfig, nil
 	}
 
-	spokeRestConfig, err := clientcmd.BuildConfigFromFlags("" /* leave masterurl as empty */, o.SpokeKubeconfigFile)
+	spokeRestConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: o.SpokeKubeconfigFile},
+		&clientcmd.ConfigOverrides{CurrentContext: o.SpokeContext}).ClientConfig()
 	if err != nil {
 		return nil, fmt.Errorf("unable to load spoke kubeconfig from file %q: %w", o.SpokeKubeconfigFile, err)
 	}
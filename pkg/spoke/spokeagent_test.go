@@ -0,0 +1,281 @@
+package spoke
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/controllercmd"
+
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	workclientset "open-cluster-management.io/api/client/work/clientset/versioned"
+	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	"open-cluster-management.io/work/pkg/helper"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name        string
+		mutate      func(o *WorkloadAgentOptions)
+		expectedErr bool
+	}{
+		{
+			name:   "default",
+			mutate: func(o *WorkloadAgentOptions) {},
+		},
+		{
+			name:   "resync disabled",
+			mutate: func(o *WorkloadAgentOptions) { o.HubWorkInformerResync = 0 },
+		},
+		{
+			name:        "no cluster name",
+			mutate:      func(o *WorkloadAgentOptions) { o.SpokeClusterName = "" },
+			expectedErr: true,
+		},
+		{
+			name:        "invalid cluster name",
+			mutate:      func(o *WorkloadAgentOptions) { o.SpokeClusterName = "Cluster_1" },
+			expectedErr: true,
+		},
+		{
+			name:        "spoke context without spoke kubeconfig",
+			mutate:      func(o *WorkloadAgentOptions) { o.SpokeContext = "spoke" },
+			expectedErr: true,
+		},
+		{
+			name: "spoke context with spoke kubeconfig",
+			mutate: func(o *WorkloadAgentOptions) {
+				o.SpokeKubeconfigFile = "/spoke/kubeconfig"
+				o.SpokeContext = "spoke"
+			},
+		},
+		{
+			name:   "additional hub kubeconfig",
+			mutate: func(o *WorkloadAgentOptions) { o.AdditionalHubKubeconfigFiles = []string{"/hub2/kubeconfig"} },
+		},
+		{
+			name: "duplicate hub kubeconfig",
+			mutate: func(o *WorkloadAgentOptions) {
+				o.HubKubeconfigFile = "/hub1/kubeconfig"
+				o.AdditionalHubKubeconfigFiles = []string{"/hub2/kubeconfig", "/hub1/kubeconfig"}
+			},
+			expectedErr: true,
+		},
+		{
+			name:   "orphan by default",
+			mutate: func(o *WorkloadAgentOptions) { o.DefaultDeletePropagationPolicy = "Orphan" },
+		},
+		{
+			name:        "selectively orphan by default",
+			mutate:      func(o *WorkloadAgentOptions) { o.DefaultDeletePropagationPolicy = "SelectivelyOrphan" },
+			expectedErr: true,
+		},
+		{
+			name:        "hub work informer resync too short",
+			mutate:      func(o *WorkloadAgentOptions) { o.HubWorkInformerResync = time.Second },
+			expectedErr: true,
+		},
+		{
+			name:        "spoke appliedwork informer resync too short",
+			mutate:      func(o *WorkloadAgentOptions) { o.SpokeAppliedWorkInformerResync = time.Second },
+			expectedErr: true,
+		},
+		{
+			name:        "spoke kube informer resync negative",
+			mutate:      func(o *WorkloadAgentOptions) { o.SpokeKubeInformerResync = -time.Minute },
+			expectedErr: true,
+		},
+		{
+			name:   "local api on unix socket",
+			mutate: func(o *WorkloadAgentOptions) { o.LocalAPIAddress = "unix:///var/run/work-agent/api.sock" },
+		},
+		{
+			name:        "local api exposed out of the node",
+			mutate:      func(o *WorkloadAgentOptions) { o.LocalAPIAddress = "0.0.0.0:8443" },
+			expectedErr: true,
+		},
+		{
+			name:   "allowed namespaces",
+			mutate: func(o *WorkloadAgentOptions) { o.AllowedNamespaces = []string{"tenant1", "tenant2"} },
+		},
+		{
+			name:        "invalid allowed namespace",
+			mutate:      func(o *WorkloadAgentOptions) { o.AllowedNamespaces = []string{"Tenant_1"} },
+			expectedErr: true,
+		},
+		{
+			name: "image registry rewrites",
+			mutate: func(o *WorkloadAgentOptions) {
+				o.ImageRegistryRewrites = map[string]string{"docker.io": "mirror.example.com"}
+			},
+		},
+		{
+			name:        "image registry rewrite to empty prefix",
+			mutate:      func(o *WorkloadAgentOptions) { o.ImageRegistryRewrites = map[string]string{"docker.io": ""} },
+			expectedErr: true,
+		},
+		{
+			name:        "prune threshold percent over 100",
+			mutate:      func(o *WorkloadAgentOptions) { o.PruneThresholdPercent = 101 },
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := NewWorkloadAgentOptions()
+			o.SpokeClusterName = "cluster1"
+			c.mutate(o)
+			err := o.Validate()
+			if c.expectedErr && err == nil {
+				t.Errorf("expected error, but got nil")
+			}
+			if !c.expectedErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestInformerResync(t *testing.T) {
+	resyncs := map[string]time.Duration{}
+	defer func() {
+		newWorkInformerFactory = workinformers.NewSharedInformerFactoryWithOptions
+		newKubeInformerFactory = kubeinformers.NewSharedInformerFactoryWithOptions
+	}()
+	newWorkInformerFactory = func(client workclientset.Interface, resync time.Duration, options ...workinformers.SharedInformerOption) workinformers.SharedInformerFactory {
+		if len(options) == 0 {
+			resyncs["spokeWork"] = resync
+		} else {
+			resyncs["hubWork"] = resync
+		}
+		return workinformers.NewSharedInformerFactoryWithOptions(client, resync, options...)
+	}
+	newKubeInformerFactory = func(client kubernetes.Interface, resync time.Duration, options ...kubeinformers.SharedInformerOption) kubeinformers.SharedInformerFactory {
+		resyncs["hubKube"] = resync
+		return kubeinformers.NewSharedInformerFactoryWithOptions(client, resync, options...)
+	}
+	defer func(f func(apiextensionsclient.Interface, time.Duration) cache.SharedIndexInformer) {
+		newCRDInformer = f
+	}(newCRDInformer)
+	newCRDInformer = func(client apiextensionsclient.Interface, resync time.Duration) cache.SharedIndexInformer {
+		resyncs["spokeCRD"] = resync
+		return cache.NewSharedIndexInformer(nil, nil, resync, cache.Indexers{})
+	}
+
+	o := NewWorkloadAgentOptions()
+	o.SpokeClusterName = "cluster1"
+	o.HubWorkInformerResync = time.Minute
+	o.SpokeAppliedWorkInformerResync = 2 * time.Minute
+	o.SpokeKubeInformerResync = 0
+
+	_, hubKubeInformers := o.newHubInformers(fakeworkclient.NewSimpleClientset(), nil)
+	if hubKubeInformers != nil {
+		t.Errorf("expected no hub kube informers without hub kube client")
+	}
+	o.newHubInformers(fakeworkclient.NewSimpleClientset(), fakekube.NewSimpleClientset())
+	spokeWorkInformerFactory, _, err := o.newSpokeInformers(fakeworkclient.NewSimpleClientset(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexers := spokeWorkInformerFactory.Work().V1().AppliedManifestWorks().Informer().GetIndexer().GetIndexers()
+	if _, ok := indexers[helper.AppliedManifestWorkByManifestWorkIndex]; !ok {
+		t.Errorf("expected appliedmanifestwork indexers registered, but got %v", indexers)
+	}
+
+	expected := map[string]time.Duration{
+		"hubWork":   time.Minute,
+		"hubKube":   time.Minute,
+		"spokeWork": 2 * time.Minute,
+		"spokeCRD":  0,
+	}
+	for name, resync := range expected {
+		if actual, ok := resyncs[name]; !ok || actual != resync {
+			t.Errorf("expected resync %s of %s informer, but got %v", resync, name, resyncs)
+		}
+	}
+}
+
+func TestSpokeKubeConfig(t *testing.T) {
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: cluster1
+  cluster:
+    server: https://cluster1:6443
+- name: cluster2
+  cluster:
+    server: https://cluster2:6443
+contexts:
+- name: context1
+  context:
+    cluster: cluster1
+- name: context2
+  context:
+    cluster: cluster2
+current-context: context1
+`
+	kubeconfigFile := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := ioutil.WriteFile(kubeconfigFile, []byte(kubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	inClusterConfig := &rest.Config{Host: "https://in-cluster:6443"}
+
+	cases := []struct {
+		name         string
+		kubeconfig   string
+		context      string
+		expectedHost string
+		expectedErr  bool
+	}{
+		{
+			name:         "in cluster",
+			expectedHost: "https://in-cluster:6443",
+		},
+		{
+			name:         "current context",
+			kubeconfig:   kubeconfigFile,
+			expectedHost: "https://cluster1:6443",
+		},
+		{
+			name:         "context",
+			kubeconfig:   kubeconfigFile,
+			context:      "context2",
+			expectedHost: "https://cluster2:6443",
+		},
+		{
+			name:        "context not found",
+			kubeconfig:  kubeconfigFile,
+			context:     "context3",
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := NewWorkloadAgentOptions()
+			o.SpokeKubeconfigFile = c.kubeconfig
+			o.SpokeContext = c.context
+			config, err := o.spokeKubeConfig(&controllercmd.ControllerContext{KubeConfig: inClusterConfig})
+			if c.expectedErr {
+				if err == nil {
+					t.Errorf("expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Host != c.expectedHost {
+				t.Errorf("expected host %q, but got %q", c.expectedHost, config.Host)
+			}
+		})
+	}
+}
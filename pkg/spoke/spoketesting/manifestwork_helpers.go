@@ -31,6 +31,12 @@ func NewFakeSyncContext(t *testing.T, workKey string) *FakeSyncContext {
 	}
 }
 
+// WithRecorder replaces the recorder of the sync context, e.g. with an in memory recorder to assert the events.
+func (f *FakeSyncContext) WithRecorder(recorder events.Recorder) *FakeSyncContext {
+	f.recorder = recorder
+	return f
+}
+
 func (f FakeSyncContext) Queue() workqueue.RateLimitingInterface { return f.queue }
 func (f FakeSyncContext) QueueKey() string                       { return f.workKey }
 func (f FakeSyncContext) Recorder() events.Recorder              { return f.recorder }
@@ -162,6 +168,7 @@ func NewFakeRestMapper() meta.RESTMapper {
 					{Name: "secrets", Namespaced: true, Kind: "Secret"},
 					{Name: "pods", Namespaced: true, Kind: "Pod"},
 					{Name: "newobjects", Namespaced: true, Kind: "NewObject"},
+					{Name: "namespaces", Namespaced: false, Kind: "Namespace"},
 				},
 			},
 		},
@@ -179,6 +186,20 @@ func NewFakeRestMapper() meta.RESTMapper {
 				},
 			},
 		},
+		{
+			Group: metav1.APIGroup{
+				Name: "apiextensions.k8s.io",
+				Versions: []metav1.GroupVersionForDiscovery{
+					{Version: "v1", GroupVersion: "apiextensions.k8s.io/v1"},
+				},
+				PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1", GroupVersion: "apiextensions.k8s.io/v1"},
+			},
+			VersionedResources: map[string][]metav1.APIResource{
+				"v1": {
+					{Name: "customresourcedefinitions", Group: "apiextensions.k8s.io", Namespaced: false, Kind: "CustomResourceDefinition"},
+				},
+			},
+		},
 	}
 	return restmapper.NewDiscoveryRESTMapper(resources)
 }
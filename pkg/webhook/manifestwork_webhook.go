@@ -4,23 +4,54 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
 
 	workv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
 
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/rest"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
 	"k8s.io/klog/v2"
 )
 
 // ManifestLimit is the max size of manifests data which is 50k bytes.
 const ManifestLimit = 50 * 1024
 
+var (
+	manifestWorkManifests = metrics.NewHistogram(
+		&metrics.HistogramOpts{
+			Name:    "work_webhook_manifestwork_manifests",
+			Help:    "Number of manifests of the ManifestWorks validated by the webhook.",
+			Buckets: helper.ManifestCountBuckets,
+		},
+	)
+	tooManyManifests = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name: "work_webhook_manifestworks_too_many_manifests_total",
+			Help: "Number of ManifestWorks rejected by the webhook since they have more manifests than the max.",
+		},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(manifestWorkManifests, tooManyManifests)
+}
+
 // ManifestWorkAdmissionHook will validate the creating/updating manifestwork request.
-type ManifestWorkAdmissionHook struct{}
+type ManifestWorkAdmissionHook struct {
+	// MaxManifests is the max number of manifests of a manifestwork, it is not limited if it is not positive.
+	MaxManifests int
+}
 
 // ValidatingResource is called by generic-admission-server on startup to register the returned REST resource through which the
 // webhook is accessed by the kube apiserver.
@@ -91,6 +122,12 @@ func (a *ManifestWorkAdmissionHook) validateManifestWorkObj(requestObj runtime.R
 		return fmt.Errorf("manifests should not be empty")
 	}
 
+	manifestWorkManifests.Observe(float64(len(work.Spec.Workload.Manifests)))
+	if a.MaxManifests > 0 && len(work.Spec.Workload.Manifests) > a.MaxManifests {
+		tooManyManifests.Inc()
+		return fmt.Errorf("%s", helper.TooManyManifestsMessage(len(work.Spec.Workload.Manifests), a.MaxManifests))
+	}
+
 	totalSize := 0
 	for _, manifest := range work.Spec.Workload.Manifests {
 		totalSize = totalSize + manifest.Size()
@@ -100,14 +137,160 @@ func (a *ManifestWorkAdmissionHook) validateManifestWorkObj(requestObj runtime.R
 		return fmt.Errorf("the size of manifests is %v bytes which exceeds the 50k limit", totalSize)
 	}
 
-	for _, manifest := range work.Spec.Workload.Manifests {
+	// the manifests with the update strategy Patch are patch documents instead of objects
+	patches, err := helper.ManifestPatches(work)
+	if err != nil {
+		return err
+	}
+	if _, err := helper.ManifestServerSideApplies(work); err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for index, manifest := range work.Spec.Workload.Manifests {
+		if _, ok := patches[int32(index)]; ok {
+			if !json.Valid(manifest.Raw) {
+				errs = append(errs, fmt.Errorf("manifests[%d]: the patch document is not valid JSON", index))
+			}
+			continue
+		}
 		err := a.validateManifest(manifest.Raw)
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("manifests[%d]: %w", index, err))
 		}
 	}
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
 
-	return nil
+	allErrs := validateManifestDependencies(work)
+	allErrs = append(allErrs, validateDeleteOption(work)...)
+	return allErrs.ToAggregate()
+}
+
+// validateManifestDependencies validates the dependencies between the manifests of the manifestwork, e.g. they have
+// no cycle, and the dependency deadline.
+func validateManifestDependencies(work *workv1.ManifestWork) field.ErrorList {
+	allErrs := field.ErrorList{}
+	annotationsPath := field.NewPath("metadata", "annotations")
+	if _, err := helper.ManifestDependencies(work); err != nil {
+		allErrs = append(allErrs, field.Invalid(annotationsPath.Key(constants.ManifestDependenciesAnnotationKey),
+			work.Annotations[constants.ManifestDependenciesAnnotationKey], err.Error()))
+	}
+	if _, err := helper.DependencyDeadline(work); err != nil {
+		allErrs = append(allErrs, field.Invalid(annotationsPath.Key(constants.DependencyDeadlineAnnotationKey),
+			work.Annotations[constants.DependencyDeadlineAnnotationKey], err.Error()))
+	}
+	return allErrs
+}
+
+var (
+	// clusterScopedGroupResources are well known cluster scoped resources, orphaning rules for them should not
+	// have namespace set.
+	clusterScopedGroupResources = map[schema.GroupResource]bool{
+		{Group: "", Resource: "namespaces"}:                                                  true,
+		{Group: "", Resource: "persistentvolumes"}:                                           true,
+		{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"}:                       true,
+		{Group: "rbac.authorization.k8s.io", Resource: "clusterrolebindings"}:                true,
+		{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}:               true,
+		{Group: "storage.k8s.io", Resource: "storageclasses"}:                                true,
+		{Group: "admissionregistration.k8s.io", Resource: "validatingwebhookconfigurations"}: true,
+		{Group: "admissionregistration.k8s.io", Resource: "mutatingwebhookconfigurations"}:   true,
+	}
+
+	crdGroupResource = schema.GroupResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}
+
+	resourcePluralRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?s$`)
+)
+
+// validateDeleteOption validates the orphaning rules in the deleteOption of the manifestwork
+func validateDeleteOption(work *workv1.ManifestWork) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if work.Spec.DeleteOption == nil {
+		return allErrs
+	}
+
+	fldPath := field.NewPath("spec", "deleteOption")
+	deleteOption := work.Spec.DeleteOption
+	switch deleteOption.PropagationPolicy {
+	case workv1.DeletePropagationPolicyTypeForeground, workv1.DeletePropagationPolicyTypeOrphan:
+		return allErrs
+	case workv1.DeletePropagationPolicyTypeSelectivelyOrphan:
+	default:
+		return append(allErrs, field.NotSupported(fldPath.Child("propagationPolicy"), deleteOption.PropagationPolicy, []string{
+			string(workv1.DeletePropagationPolicyTypeForeground),
+			string(workv1.DeletePropagationPolicyTypeOrphan),
+			string(workv1.DeletePropagationPolicyTypeSelectivelyOrphan),
+		}))
+	}
+
+	// the orphaning rules with selectors ride on an annotation until the api has the field
+	selectorRules, err := helper.OrphaningSelectorRules(work)
+	if err != nil {
+		annotationPath := field.NewPath("metadata", "annotations").Key(constants.OrphaningSelectorsAnnotationKey)
+		return append(allErrs, field.Invalid(annotationPath, work.Annotations[constants.OrphaningSelectorsAnnotationKey], err.Error()))
+	}
+
+	rulesPath := fldPath.Child("selectivelyOrphans", "orphaningRules")
+	if deleteOption.SelectivelyOrphan == nil || len(deleteOption.SelectivelyOrphan.OrphaningRules) == 0 {
+		if len(selectorRules) > 0 {
+			return allErrs
+		}
+		return append(allErrs, field.Required(rulesPath, "orphaningRules must be set when propagationPolicy is SelectivelyOrphan"))
+	}
+
+	referencedCRDs := referencedCRDNames(work.Spec.Workload.Manifests)
+	for index, rule := range deleteOption.SelectivelyOrphan.OrphaningRules {
+		rulePath := rulesPath.Index(index)
+		if !resourcePluralRegexp.MatchString(rule.Resource) {
+			allErrs = append(allErrs, field.Invalid(rulePath.Child("resource"), rule.Resource, "resource must be a lowercase plural"))
+		}
+		if len(rule.Name) == 0 {
+			allErrs = append(allErrs, field.Required(rulePath.Child("name"), ""))
+		}
+
+		gr := schema.GroupResource{Group: rule.Group, Resource: rule.Resource}
+		if clusterScopedGroupResources[gr] && len(rule.Namespace) != 0 {
+			allErrs = append(allErrs, field.Invalid(rulePath.Child("namespace"), rule.Namespace,
+				fmt.Sprintf("namespace must not be set for cluster scoped resource %s", gr.String())))
+		}
+		if gr == crdGroupResource && referencedCRDs.Has(rule.Name) {
+			allErrs = append(allErrs, field.Forbidden(rulePath,
+				fmt.Sprintf("customresourcedefinition %s is referenced by other manifests and cannot be orphaned", rule.Name)))
+		}
+	}
+
+	return allErrs
+}
+
+// referencedCRDNames returns the names of the CRDs in the manifests which have custom resources in the same manifests
+func referencedCRDNames(manifests []workv1.Manifest) sets.String {
+	crdNames := map[schema.GroupKind]string{}
+	groupKinds := sets.NewString()
+	for _, manifest := range manifests {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+			continue
+		}
+
+		gvk := obj.GroupVersionKind()
+		groupKinds.Insert(gvk.GroupKind().String())
+		if gvk.GroupKind() != (schema.GroupKind{Group: crdGroupResource.Group, Kind: "CustomResourceDefinition"}) {
+			continue
+		}
+
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		crdNames[schema.GroupKind{Group: group, Kind: kind}] = obj.GetName()
+	}
+
+	referenced := sets.NewString()
+	for gk, name := range crdNames {
+		if groupKinds.Has(gk.String()) {
+			referenced.Insert(name)
+		}
+	}
+	return referenced
 }
 
 func (a *ManifestWorkAdmissionHook) validateManifest(manifest []byte) error {
@@ -118,6 +301,14 @@ func (a *ManifestWorkAdmissionHook) validateManifest(manifest []byte) error {
 		return err
 	}
 
+	if unstructuredObj.GetAPIVersion() == "" {
+		return fmt.Errorf("apiVersion must be set in manifest")
+	}
+
+	if unstructuredObj.GetKind() == "" {
+		return fmt.Errorf("kind must be set in manifest")
+	}
+
 	// The object must have name specified, generateName is not allowed in manifestwork
 	if unstructuredObj.GetName() == "" {
 		return fmt.Errorf("name must be set in manifest")
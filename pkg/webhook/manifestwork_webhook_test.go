@@ -2,16 +2,20 @@ package webhook
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"reflect"
 	"testing"
 
+	"open-cluster-management.io/work/pkg/constants"
 	"open-cluster-management.io/work/pkg/spoke/spoketesting"
 
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	workapiv1 "open-cluster-management.io/api/work/v1"
 )
 
 var manifestWorkSchema = metav1.GroupVersionResource{
@@ -93,7 +97,7 @@ func TestManifestWorkValidate(t *testing.T) {
 				Allowed: false,
 				Result: &metav1.Status{
 					Status: metav1.StatusFailure, Code: http.StatusBadRequest, Reason: metav1.StatusReasonBadRequest,
-					Message: "name must be set in manifest",
+					Message: "manifests[1]: name must be set in manifest",
 				},
 			},
 		},
@@ -121,7 +125,7 @@ func TestManifestWorkValidate(t *testing.T) {
 				Allowed: false,
 				Result: &metav1.Status{
 					Status: metav1.StatusFailure, Code: http.StatusBadRequest, Reason: metav1.StatusReasonBadRequest,
-					Message: "generateName must not be set in manifest",
+					Message: "manifests[0]: generateName must not be set in manifest",
 				},
 			},
 		},
@@ -140,7 +144,7 @@ func TestManifestWorkValidate(t *testing.T) {
 				Allowed: false,
 				Result: &metav1.Status{
 					Status: metav1.StatusFailure, Code: http.StatusBadRequest, Reason: metav1.StatusReasonBadRequest,
-					Message: "name must be set in manifest",
+					Message: "manifests[1]: name must be set in manifest",
 				},
 			},
 		},
@@ -166,6 +170,26 @@ func TestManifestWorkValidate(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "validate creating ManifestWork with multiple invalid manifests",
+			request: &admissionv1beta1.AdmissionRequest{
+				Resource:  manifestWorkSchema,
+				Operation: admissionv1beta1.Create,
+				UserInfo:  authenticationv1.UserInfo{Username: "tester"},
+			},
+			manifests: []*unstructured.Unstructured{
+				spoketesting.NewUnstructured("", "Kind", "testns", "test"),
+				spoketesting.NewUnstructured("v1", "Kind", "testns", "test"),
+				spoketesting.NewUnstructured("v1", "Kind", "testns", ""),
+			},
+			expectedResponse: &admissionv1beta1.AdmissionResponse{
+				Allowed: false,
+				Result: &metav1.Status{
+					Status: metav1.StatusFailure, Code: http.StatusBadRequest, Reason: metav1.StatusReasonBadRequest,
+					Message: "[manifests[0]: apiVersion must be set in manifest, manifests[2]: name must be set in manifest]",
+				},
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -180,3 +204,312 @@ func TestManifestWorkValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestManifestWorkValidateDeleteOption(t *testing.T) {
+	crd := spoketesting.NewUnstructuredWithContent("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "foos.test.io",
+		map[string]interface{}{
+			"spec": map[string]interface{}{
+				"group": "test.io",
+				"names": map[string]interface{}{"kind": "Foo", "plural": "foos"},
+			},
+		})
+	cr := spoketesting.NewUnstructured("test.io/v1", "Foo", "ns1", "foo1")
+
+	cases := []struct {
+		name               string
+		manifests          []*unstructured.Unstructured
+		deleteOption       *workapiv1.DeleteOption
+		orphaningSelectors string
+		expectedAllowed    bool
+		expectedMessage    string
+	}{
+		{
+			name:            "orphan all",
+			manifests:       []*unstructured.Unstructured{cr},
+			deleteOption:    &workapiv1.DeleteOption{PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan},
+			expectedAllowed: true,
+		},
+		{
+			name:            "unknown propagation policy",
+			manifests:       []*unstructured.Unstructured{cr},
+			deleteOption:    &workapiv1.DeleteOption{PropagationPolicy: "Unknown"},
+			expectedMessage: "spec.deleteOption.propagationPolicy: Unsupported value: \"Unknown\": supported values: \"Foreground\", \"Orphan\", \"SelectivelyOrphan\"",
+		},
+		{
+			name:      "selectively orphan without rules",
+			manifests: []*unstructured.Unstructured{cr},
+			deleteOption: &workapiv1.DeleteOption{
+				PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+			},
+			expectedMessage: "spec.deleteOption.selectivelyOrphans.orphaningRules: Required value: orphaningRules must be set when propagationPolicy is SelectivelyOrphan",
+		},
+		{
+			name:      "selectively orphan with selectors only",
+			manifests: []*unstructured.Unstructured{cr},
+			deleteOption: &workapiv1.DeleteOption{
+				PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+			},
+			orphaningSelectors: `[{"group":"test.io","resource":"foos","namespace":"*","selector":{"matchLabels":{"keep":"true"}}}]`,
+			expectedAllowed:    true,
+		},
+		{
+			name:               "selector combined with name in one rule",
+			manifests:          []*unstructured.Unstructured{cr},
+			deleteOption:       newSelectivelyOrphan(workapiv1.OrphaningRule{Group: "test.io", Resource: "foos", Namespace: "ns1", Name: "foo1"}),
+			orphaningSelectors: `[{"group":"test.io","resource":"foos","namespace":"ns1","name":"foo1","selector":{"matchLabels":{"keep":"true"}}}]`,
+			expectedMessage: `metadata.annotations[work.open-cluster-management.io/orphaning-selectors]: Invalid value: ` +
+				`"[{\"group\":\"test.io\",\"resource\":\"foos\",\"namespace\":\"ns1\",\"name\":\"foo1\",\"selector\":{\"matchLabels\":{\"keep\":\"true\"}}}]": ` +
+				`invalid orphaning selectors of manifestwork work-0: rule 0 cannot have both name and selector set`,
+		},
+		{
+			name:      "resource is not a lowercase plural",
+			manifests: []*unstructured.Unstructured{cr},
+			deleteOption: newSelectivelyOrphan(workapiv1.OrphaningRule{
+				Group: "test.io", Resource: "Foo", Namespace: "ns1", Name: "foo1",
+			}),
+			expectedMessage: "spec.deleteOption.selectivelyOrphans.orphaningRules[0].resource: Invalid value: \"Foo\": resource must be a lowercase plural",
+		},
+		{
+			name:      "namespace set for cluster scoped resource",
+			manifests: []*unstructured.Unstructured{cr},
+			deleteOption: newSelectivelyOrphan(workapiv1.OrphaningRule{
+				Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Namespace: "ns1", Name: "role1",
+			}),
+			expectedMessage: "spec.deleteOption.selectivelyOrphans.orphaningRules[0].namespace: Invalid value: \"ns1\": namespace must not be set for cluster scoped resource clusterroles.rbac.authorization.k8s.io",
+		},
+		{
+			name:      "orphan crd referenced by other manifests",
+			manifests: []*unstructured.Unstructured{crd, cr},
+			deleteOption: newSelectivelyOrphan(workapiv1.OrphaningRule{
+				Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions", Name: "foos.test.io",
+			}),
+			expectedMessage: "spec.deleteOption.selectivelyOrphans.orphaningRules[0]: Forbidden: customresourcedefinition foos.test.io is referenced by other manifests and cannot be orphaned",
+		},
+		{
+			name:      "orphan crd not referenced by other manifests",
+			manifests: []*unstructured.Unstructured{crd},
+			deleteOption: newSelectivelyOrphan(workapiv1.OrphaningRule{
+				Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions", Name: "foos.test.io",
+			}),
+			expectedAllowed: true,
+		},
+		{
+			name:      "aggregate errors of multiple rules",
+			manifests: []*unstructured.Unstructured{cr},
+			deleteOption: newSelectivelyOrphan(
+				workapiv1.OrphaningRule{Group: "test.io", Resource: "foos", Namespace: "ns1", Name: "foo1"},
+				workapiv1.OrphaningRule{Group: "test.io", Resource: "foo", Namespace: "ns1"},
+			),
+			expectedMessage: "[spec.deleteOption.selectivelyOrphans.orphaningRules[1].resource: Invalid value: \"foo\": resource must be a lowercase plural, " +
+				"spec.deleteOption.selectivelyOrphans.orphaningRules[1].name: Required value]",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, _ := spoketesting.NewManifestWork(0, c.manifests...)
+			work.Spec.DeleteOption = c.deleteOption
+			if len(c.orphaningSelectors) > 0 {
+				work.Annotations = map[string]string{constants.OrphaningSelectorsAnnotationKey: c.orphaningSelectors}
+			}
+			request := &admissionv1beta1.AdmissionRequest{
+				Resource:  manifestWorkSchema,
+				Operation: admissionv1beta1.Create,
+			}
+			request.Object.Raw, _ = json.Marshal(work)
+
+			admissionHook := &ManifestWorkAdmissionHook{}
+			actualResponse := admissionHook.Validate(request)
+			if actualResponse.Allowed != c.expectedAllowed {
+				t.Fatalf("expected allowed %v but got: %#v", c.expectedAllowed, actualResponse.Result)
+			}
+			if c.expectedAllowed {
+				return
+			}
+			if actualResponse.Result.Message != c.expectedMessage {
+				t.Errorf("expected message %q but got %q", c.expectedMessage, actualResponse.Result.Message)
+			}
+		})
+	}
+}
+
+func newSelectivelyOrphan(rules ...workapiv1.OrphaningRule) *workapiv1.DeleteOption {
+	return &workapiv1.DeleteOption{
+		PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+		SelectivelyOrphan: &workapiv1.SelectivelyOrphan{OrphaningRules: rules},
+	}
+}
+
+func TestManifestWorkValidateMaxManifests(t *testing.T) {
+	newManifests := func(count int) []*unstructured.Unstructured {
+		manifests := []*unstructured.Unstructured{}
+		for i := 0; i < count; i++ {
+			manifests = append(manifests, spoketesting.NewUnstructured("v1", "ConfigMap", "testns", fmt.Sprintf("test%d", i)))
+		}
+		return manifests
+	}
+
+	cases := []struct {
+		name            string
+		maxManifests    int
+		manifests       []*unstructured.Unstructured
+		expectedAllowed bool
+		expectedMessage string
+	}{
+		{
+			name:            "under the limit",
+			maxManifests:    3,
+			manifests:       newManifests(2),
+			expectedAllowed: true,
+		},
+		{
+			name:            "exactly at the limit",
+			maxManifests:    3,
+			manifests:       newManifests(3),
+			expectedAllowed: true,
+		},
+		{
+			name:            "one over the limit",
+			maxManifests:    3,
+			manifests:       newManifests(4),
+			expectedMessage: "the manifestwork has 4 manifests which exceeds the limit of 3 manifests",
+		},
+		{
+			name:            "not limited",
+			manifests:       newManifests(4),
+			expectedAllowed: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, _ := spoketesting.NewManifestWork(0, c.manifests...)
+			request := &admissionv1beta1.AdmissionRequest{
+				Resource:  manifestWorkSchema,
+				Operation: admissionv1beta1.Update,
+			}
+			request.Object.Raw, _ = json.Marshal(work)
+
+			admissionHook := &ManifestWorkAdmissionHook{MaxManifests: c.maxManifests}
+			actualResponse := admissionHook.Validate(request)
+			if actualResponse.Allowed != c.expectedAllowed {
+				t.Fatalf("expected allowed %v but got: %#v", c.expectedAllowed, actualResponse.Result)
+			}
+			if !c.expectedAllowed && actualResponse.Result.Message != c.expectedMessage {
+				t.Errorf("expected message %q but got %q", c.expectedMessage, actualResponse.Result.Message)
+			}
+		})
+	}
+}
+
+func TestManifestWorkValidateManifestDependencies(t *testing.T) {
+	cases := []struct {
+		name            string
+		annotations     map[string]string
+		expectedAllowed bool
+	}{
+		{
+			name:            "no dependencies",
+			expectedAllowed: true,
+		},
+		{
+			name: "valid dependencies",
+			annotations: map[string]string{
+				constants.ManifestDependenciesAnnotationKey: `[{"ordinal": 1, "dependsOn": [{"kind": "ConfigMap", "name": "test0"}]}]`,
+				constants.DependencyDeadlineAnnotationKey:   "5m",
+			},
+			expectedAllowed: true,
+		},
+		{
+			name: "cycle",
+			annotations: map[string]string{
+				constants.ManifestDependenciesAnnotationKey: `[{"ordinal": 0, "dependsOn": [{"ordinal": 1}]}, {"ordinal": 1, "dependsOn": [{"ordinal": 0}]}]`,
+			},
+		},
+		{
+			name: "invalid deadline",
+			annotations: map[string]string{
+				constants.DependencyDeadlineAnnotationKey: "-5m",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, _ := spoketesting.NewManifestWork(0,
+				spoketesting.NewUnstructured("v1", "ConfigMap", "testns", "test0"),
+				spoketesting.NewUnstructured("v1", "ConfigMap", "testns", "test1"))
+			work.Annotations = c.annotations
+			request := &admissionv1beta1.AdmissionRequest{
+				Resource:  manifestWorkSchema,
+				Operation: admissionv1beta1.Create,
+			}
+			request.Object.Raw, _ = json.Marshal(work)
+
+			admissionHook := &ManifestWorkAdmissionHook{}
+			actualResponse := admissionHook.Validate(request)
+			if actualResponse.Allowed != c.expectedAllowed {
+				t.Errorf("expected allowed %v but got: %#v", c.expectedAllowed, actualResponse.Result)
+			}
+		})
+	}
+}
+
+func TestManifestWorkValidateManifestPatches(t *testing.T) {
+	cases := []struct {
+		name            string
+		configOptions   string
+		expectedAllowed bool
+	}{
+		{
+			name: "patch document without config option",
+		},
+		{
+			name: "patch document with config option",
+			configOptions: `[{"ordinal": 0, "resourceIdentifier": {"version": "v1", "resource": "configmaps", "namespace": "testns", "name": "test0"},
+				"updateStrategy": {"type": "Patch", "patchType": "MergePatch"}}]`,
+			expectedAllowed: true,
+		},
+		{
+			name: "unsupported patch type",
+			configOptions: `[{"ordinal": 0, "resourceIdentifier": {"version": "v1", "resource": "configmaps", "namespace": "testns", "name": "test0"},
+				"updateStrategy": {"type": "Patch", "patchType": "ApplyPatch"}}]`,
+		},
+		{
+			name: "patch document and server side apply",
+			configOptions: `[{"ordinal": 0, "resourceIdentifier": {"version": "v1", "resource": "configmaps", "namespace": "testns", "name": "test0"},
+				"updateStrategy": {"type": "Patch", "patchType": "MergePatch"}}, {"ordinal": 1, "updateStrategy": {"type": "ServerSideApply"}}]`,
+			expectedAllowed: true,
+		},
+		{
+			name:          "unsupported update strategy",
+			configOptions: `[{"ordinal": 1, "updateStrategy": {"type": "CreateOnly"}}]`,
+		},
+		{
+			name:          "patch without resource identifier",
+			configOptions: `[{"ordinal": 0, "updateStrategy": {"type": "Patch", "patchType": "MergePatch"}}]`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, _ := spoketesting.NewManifestWork(0, spoketesting.NewUnstructured("v1", "ConfigMap", "testns", "test1"))
+			work.Spec.Workload.Manifests = append([]workapiv1.Manifest{{RawExtension: runtime.RawExtension{
+				Raw: []byte(`{"metadata":{"labels":{"patched":"true"}}}`)}}}, work.Spec.Workload.Manifests...)
+			if len(c.configOptions) != 0 {
+				work.Annotations = map[string]string{constants.ManifestConfigOptionsAnnotationKey: c.configOptions}
+			}
+			request := &admissionv1beta1.AdmissionRequest{
+				Resource:  manifestWorkSchema,
+				Operation: admissionv1beta1.Create,
+			}
+			request.Object.Raw, _ = json.Marshal(work)
+
+			admissionHook := &ManifestWorkAdmissionHook{}
+			actualResponse := admissionHook.Validate(request)
+			if actualResponse.Allowed != c.expectedAllowed {
+				t.Errorf("expected allowed %v but got: %#v", c.expectedAllowed, actualResponse.Result)
+			}
+		})
+	}
+}
@@ -56,7 +56,7 @@ var _ = ginkgo.Describe("ManifestWork admission webhook", func() {
 			gomega.Expect(err).To(gomega.HaveOccurred())
 			gomega.Expect(errors.IsBadRequest(err)).Should(gomega.BeTrue())
 			gomega.Expect(err.Error()).Should(gomega.Equal(fmt.Sprintf(
-				"admission webhook \"%s\" denied the request: name must be set in manifest",
+				"admission webhook \"%s\" denied the request: manifests[0]: name must be set in manifest",
 				admissionName,
 			)))
 		})
@@ -87,7 +87,7 @@ var _ = ginkgo.Describe("ManifestWork admission webhook", func() {
 			gomega.Expect(err).To(gomega.HaveOccurred())
 			gomega.Expect(errors.IsBadRequest(err)).Should(gomega.BeTrue())
 			gomega.Expect(err.Error()).Should(gomega.Equal(fmt.Sprintf(
-				"admission webhook \"%s\" denied the request: name must be set in manifest",
+				"admission webhook \"%s\" denied the request: manifests[1]: name must be set in manifest",
 				admissionName,
 			)))
 		})
@@ -0,0 +1,98 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("ManifestWork with API versions not available yet", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	var crdWork, crWork *workapiv1.ManifestWork
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+	})
+
+	ginkgo.AfterEach(func() {
+		for _, work := range []*workapiv1.ManifestWork{crWork, crdWork} {
+			if work == nil {
+				continue
+			}
+			err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+			if !errors.IsNotFound(err) {
+				gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			}
+			util.AssertAppliedManifestWorkDeleted(fmt.Sprintf("%s-%s", hubHash, work.Name), spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		}
+
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should apply the CR once the CRD is installed by another work", func() {
+		ginkgo.By("create the work with the cr")
+		cr, _, err := util.GuestbookCr(o.SpokeClusterName, "guestbook1")
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		crWork = util.NewManifestWork(o.SpokeClusterName, "cr-work", []workapiv1.Manifest{util.ToManifest(cr)})
+		crWork, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), crWork, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		gomega.Eventually(func() error {
+			work, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), crWork.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if len(work.Status.ResourceStatus.Manifests) != 1 {
+				return fmt.Errorf("expected 1 manifest condition, but got %v", work.Status.ResourceStatus.Manifests)
+			}
+			condition := meta.FindStatusCondition(work.Status.ResourceStatus.Manifests[0].Conditions, string(workapiv1.ManifestApplied))
+			if condition == nil || condition.Reason != "APIVersionNotAvailable" {
+				return fmt.Errorf("expected reason APIVersionNotAvailable, but got %v", condition)
+			}
+			return nil
+		}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+
+		ginkgo.By("create the work with the crd")
+		crd, _, err := util.GuestbookCrd()
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		crdWork = util.NewManifestWork(o.SpokeClusterName, "crd-work", []workapiv1.Manifest{util.ToManifest(crd)})
+		crdWork, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), crdWork, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		// the work with the cr converges without touching its spec
+		util.AssertWorkCondition(crWork.Namespace, crWork.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+		work, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), crWork.Name, metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		gomega.Expect(work.Generation).To(gomega.Equal(crWork.Generation))
+	})
+})
@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("ManifestWork with the cap of applied resources", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	var work1, work2 *workapiv1.ManifestWork
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+		o.MaxAppliedResources = 2
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+	})
+
+	ginkgo.AfterEach(func() {
+		for _, work := range []*workapiv1.ManifestWork{work1, work2} {
+			if work == nil {
+				continue
+			}
+			err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+			if !errors.IsNotFound(err) {
+				gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			}
+			util.AssertAppliedManifestWorkDeleted(fmt.Sprintf("%s-%s", hubHash, work.Name), spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		}
+
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should not apply the resources beyond the cap", func() {
+		ginkgo.By("create the work reaching the cap")
+		work1 = util.NewManifestWork(o.SpokeClusterName, "cap-work1", []workapiv1.Manifest{
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm1", map[string]string{"a": "b"}, nil)),
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm2", map[string]string{"a": "b"}, nil)),
+		})
+		var err error
+		work1, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work1, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		util.AssertWorkCondition(work1.Namespace, work1.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue, metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+		configMapGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+		util.AssertAppliedResources(hubHash, work1.Name,
+			[]schema.GroupVersionResource{configMapGVR, configMapGVR},
+			[]string{o.SpokeClusterName, o.SpokeClusterName}, []string{"cm1", "cm2"},
+			spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+
+		ginkgo.By("create the work beyond the cap")
+		work2 = util.NewManifestWork(o.SpokeClusterName, "cap-work2", []workapiv1.Manifest{
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm3", map[string]string{"a": "b"}, nil)),
+		})
+		work2, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work2, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		gomega.Eventually(func() error {
+			work, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work2.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if !meta.IsStatusConditionTrue(work.Status.Conditions, constants.WorkResourceQuotaExceededByAgentPolicy) {
+				return fmt.Errorf("expected condition %s, but got %v", constants.WorkResourceQuotaExceededByAgentPolicy, work.Status.Conditions)
+			}
+			if !meta.IsStatusConditionFalse(work.Status.Conditions, string(workapiv1.WorkApplied)) {
+				return fmt.Errorf("expected work not applied, but got %v", work.Status.Conditions)
+			}
+			return nil
+		}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+
+		_, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm3", metav1.GetOptions{})
+		gomega.Expect(errors.IsNotFound(err)).To(gomega.BeTrue())
+	})
+})
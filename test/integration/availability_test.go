@@ -0,0 +1,92 @@
+package integration
+
+import (
+	"context"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/dynamic"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/pkg/spoke/controllers/statuscontroller"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("Availability of cluster scoped resources", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		statuscontroller.ControllerReSyncInterval = 3 * time.Second
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+	})
+
+	ginkgo.AfterEach(func() {
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should report CRD available only after it is established", func() {
+		crd, gvr, err := util.GuestbookCrd()
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		// namespace set on a cluster scoped resource should be ignored when checking availability
+		crd.SetNamespace(o.SpokeClusterName)
+
+		nsName := "ns-" + utilrand.String(5)
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+
+		work := util.NewManifestWork(o.SpokeClusterName, "", []workapiv1.Manifest{util.ToManifest(crd), util.ToManifest(ns)})
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkAvailable), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue, metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+
+		spokeDynamicClient, err := dynamic.NewForConfig(spokeRestConfig)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		actual, err := spokeDynamicClient.Resource(gvr).Get(context.Background(), crd.GetName(), metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		conditions, _, err := unstructured.NestedSlice(actual.Object, "status", "conditions")
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		established := false
+		for _, condition := range conditions {
+			c := condition.(map[string]interface{})
+			if c["type"] == "Established" && c["status"] == string(metav1.ConditionTrue) {
+				established = true
+			}
+		}
+		gomega.Expect(established).To(gomega.BeTrue())
+
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		gomega.Expect(meta.IsStatusConditionTrue(work.Status.ResourceStatus.Manifests[0].Conditions, string(workapiv1.ManifestAvailable))).To(gomega.BeTrue())
+
+		err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+})
@@ -0,0 +1,100 @@
+package integration
+
+import (
+	"context"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/pkg/spoke/controllers/statuscontroller"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("ManifestWork with run-to-completion payload", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		statuscontroller.ControllerReSyncInterval = 3 * time.Second
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+	})
+
+	ginkgo.AfterEach(func() {
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should mark the work completed and not recreate the completed job", func() {
+		job, _ := util.NewJob(o.SpokeClusterName, "job1")
+
+		work := util.NewManifestWork(o.SpokeClusterName, "", []workapiv1.Manifest{util.ToManifest(job)})
+		work.Annotations = map[string]string{
+			constants.CompletionRulesAnnotationKey: `[{"ordinal":0,"conditionType":"Complete"}]`,
+		}
+		work, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+
+		// there is no job controller in the test environment, so complete the job manually
+		gomega.Eventually(func() error {
+			actual, err := spokeKubeClient.BatchV1().Jobs(o.SpokeClusterName).Get(context.Background(), "job1", metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			actual.Status.Succeeded = 1
+			actual.Status.Conditions = []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Now()},
+			}
+			_, err = spokeKubeClient.BatchV1().Jobs(o.SpokeClusterName).UpdateStatus(context.Background(), actual, metav1.UpdateOptions{})
+			return err
+		}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+
+		gomega.Eventually(func() bool {
+			work, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+			if err != nil {
+				return false
+			}
+			return meta.IsStatusConditionTrue(work.Status.Conditions, constants.WorkCompleted)
+		}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+
+		// the completed job is not recreated after it is deleted
+		err = spokeKubeClient.BatchV1().Jobs(o.SpokeClusterName).Delete(context.Background(), "job1", metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		gomega.Consistently(func() bool {
+			_, err := spokeKubeClient.BatchV1().Jobs(o.SpokeClusterName).Get(context.Background(), "job1", metav1.GetOptions{})
+			return errors.IsNotFound(err)
+		}, 10, eventuallyInterval).Should(gomega.BeTrue())
+
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		gomega.Expect(meta.IsStatusConditionTrue(work.Status.Conditions, constants.WorkCompleted)).To(gomega.BeTrue())
+	})
+})
@@ -0,0 +1,149 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/dynamic"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("Deleting CRDs with custom resources of other ManifestWorks", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	var crdWork, crWork *workapiv1.ManifestWork
+	var spokeDynamicClient dynamic.Interface
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		spokeDynamicClient, err = dynamic.NewForConfig(spokeRestConfig)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+
+		crd, _, err := util.GuestbookCrd()
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		crdWork = util.NewManifestWork(o.SpokeClusterName, "crd-work", []workapiv1.Manifest{util.ToManifest(crd)})
+		crdWork, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), crdWork, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		util.AssertWorkCondition(crdWork.Namespace, crdWork.Name, hubWorkClient, string(workapiv1.WorkAvailable), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+
+		cr, _, err := util.GuestbookCr(o.SpokeClusterName, "guestbook1")
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		crWork = util.NewManifestWork(o.SpokeClusterName, "cr-work", []workapiv1.Manifest{util.ToManifest(cr)})
+		crWork, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), crWork, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		util.AssertWorkCondition(crWork.Namespace, crWork.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+	})
+
+	ginkgo.AfterEach(func() {
+		for _, work := range []*workapiv1.ManifestWork{crWork, crdWork} {
+			err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+			if !errors.IsNotFound(err) {
+				gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			}
+			util.AssertAppliedManifestWorkDeleted(fmt.Sprintf("%s-%s", hubHash, work.Name), spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		}
+
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should hold the CRD deletion until the custom resources of other works are deleted", func() {
+		crd, crdGVR, err := util.GuestbookCrd()
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		_, crGVR, err := util.GuestbookCr(o.SpokeClusterName, "guestbook1")
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		ginkgo.By("delete the work owning the crd")
+		err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), crdWork.Name, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		appliedCRDWorkName := fmt.Sprintf("%s-%s", hubHash, crdWork.Name)
+		appliedCRWorkName := fmt.Sprintf("%s-%s", hubHash, crWork.Name)
+		gomega.Eventually(func() error {
+			appliedWork, err := spokeWorkClient.WorkV1().AppliedManifestWorks().Get(context.Background(), appliedCRDWorkName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			expected := fmt.Sprintf("%s is used by %s", crd.GetName(), appliedCRWorkName)
+			if blocked := appliedWork.Annotations[constants.DeletionBlockedByDependentsAnnotationKey]; blocked != expected {
+				return fmt.Errorf("expected blocked deletion %q, but got %q", expected, blocked)
+			}
+			return nil
+		}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+
+		util.AssertAppliedResourceMetas(appliedCRDWorkName, []workapiv1.AppliedManifestResourceMeta{
+			{Group: crdGVR.Group, Version: crdGVR.Version, Resource: crdGVR.Resource, Name: crd.GetName()},
+		}, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		gomega.Consistently(func() error {
+			_, err := spokeDynamicClient.Resource(crGVR).Namespace(o.SpokeClusterName).Get(context.Background(), "guestbook1", metav1.GetOptions{})
+			return err
+		}, 3, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+
+		ginkgo.By("delete the work owning the cr")
+		err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), crWork.Name, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		util.AssertAppliedManifestWorkDeleted(appliedCRDWorkName, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		util.AssertNonexistenceOfResources([]schema.GroupVersionResource{crdGVR}, []string{""}, []string{crd.GetName()},
+			spokeDynamicClient, eventuallyTimeout, eventuallyInterval)
+	})
+
+	ginkgo.It("should delete the CRD once the deletion is forced", func() {
+		crd, crdGVR, err := util.GuestbookCrd()
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		ginkgo.By("delete the work owning the crd")
+		err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), crdWork.Name, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		appliedCRDWorkName := fmt.Sprintf("%s-%s", hubHash, crdWork.Name)
+		gomega.Eventually(func() bool {
+			appliedWork, err := spokeWorkClient.WorkV1().AppliedManifestWorks().Get(context.Background(), appliedCRDWorkName, metav1.GetOptions{})
+			if err != nil {
+				return false
+			}
+			return len(appliedWork.Annotations[constants.DeletionBlockedByDependentsAnnotationKey]) > 0
+		}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+
+		ginkgo.By("force the deletion")
+		patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:"true"}}}`, constants.ForceDeletionAnnotationKey)
+		_, err = spokeWorkClient.WorkV1().AppliedManifestWorks().Patch(
+			context.Background(), appliedCRDWorkName, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		util.AssertAppliedManifestWorkDeleted(appliedCRDWorkName, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		util.AssertNonexistenceOfResources([]schema.GroupVersionResource{crdGVR}, []string{""}, []string{crd.GetName()},
+			spokeDynamicClient, eventuallyTimeout, eventuallyInterval)
+	})
+})
@@ -0,0 +1,90 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("Garbage scan", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	var work *workapiv1.ManifestWork
+	var err error
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+		o.PropagateProvenance = true
+		o.EnableGarbageScan = true
+		o.GarbageScanInterval = 1 * time.Second
+		o.GarbageScanMinAge = 0
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+	})
+
+	ginkgo.AfterEach(func() {
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should delete the owned resource which is not tracked by the AppliedManifestWork", func() {
+		work = util.NewManifestWork(o.SpokeClusterName, "work-garbage", []workapiv1.Manifest{
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm1", map[string]string{"a": "b"}, nil)),
+		})
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+
+		appliedManifestWork, err := spokeWorkClient.WorkV1().AppliedManifestWorks().Get(
+			context.Background(), fmt.Sprintf("%s-%s", hubHash, work.Name), metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		ginkgo.By("simulate a resource created by the agent which crashed before recording it")
+		leaked := util.NewConfigmap(o.SpokeClusterName, "leaked", map[string]string{"c": "d"}, nil)
+		leaked.Labels = map[string]string{
+			constants.ManifestWorkLabelKey:          work.Name,
+			constants.ManifestWorkNamespaceLabelKey: work.Namespace,
+		}
+		leaked.OwnerReferences = []metav1.OwnerReference{*helper.NewAppliedManifestWorkOwner(appliedManifestWork)}
+		_, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Create(context.Background(), leaked, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		gomega.Eventually(func() bool {
+			_, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "leaked", metav1.GetOptions{})
+			return errors.IsNotFound(err)
+		}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+
+		gomega.Consistently(func() error {
+			_, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm1", metav1.GetOptions{})
+			return err
+		}, 3*time.Second, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+	})
+})
@@ -0,0 +1,125 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"github.com/openshift/library-go/pkg/controller/controllercmd"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/hub"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("Hub cleanup of ManifestWork", func() {
+	var o *spoke.WorkloadAgentOptions
+	var managerOptions *hub.ManagerOptions
+	var agentCancel, managerCancel context.CancelFunc
+
+	var work *workapiv1.ManifestWork
+	var err error
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		managerOptions = hub.NewManagerOptions()
+		managerOptions.CleanupTimeout = 15 * time.Second
+		var ctx context.Context
+		ctx, managerCancel = context.WithCancel(context.Background())
+		go func() {
+			err := managerOptions.RunManager(ctx, &controllercmd.ControllerContext{
+				KubeConfig:    spokeRestConfig,
+				EventRecorder: util.NewIntegrationTestEventRecorder("integration"),
+			})
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		}()
+
+		ctx, agentCancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+
+		work = util.NewManifestWork(o.SpokeClusterName, "work-hub-cleanup", []workapiv1.Manifest{
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm1", map[string]string{"a": "b"}, nil)),
+		})
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+
+		gomega.Eventually(func() bool {
+			work, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+			if err != nil {
+				return false
+			}
+			return helper.HasFinalizer(work, constants.ManifestWorkHubCleanupFinalizer)
+		}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+	})
+
+	ginkgo.AfterEach(func() {
+		if agentCancel != nil {
+			agentCancel()
+		}
+		if managerCancel != nil {
+			managerCancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should delete the work once the agent reports the cleanup", func() {
+		err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		util.AssertWorkDeleted(work.Namespace, work.Name, hubHash, work.Spec.Workload.Manifests, hubWorkClient, spokeKubeClient,
+			eventuallyTimeout, eventuallyInterval)
+	})
+
+	ginkgo.It("should hold the deletion of the work until the cleanup times out if the agent is stopped", func() {
+		agentCancel()
+
+		deletedAt := time.Now()
+		err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		ginkgo.By("the work is held by the finalizers")
+		gomega.Consistently(func() error {
+			work, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if meta.IsStatusConditionTrue(work.Status.Conditions, constants.WorkCleanupCompleted) {
+				return fmt.Errorf("the cleanup is reported while the agent is stopped")
+			}
+			return nil
+		}, 10*time.Second, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+
+		ginkgo.By("the work is deleted once the cleanup times out")
+		gomega.Eventually(func() bool {
+			_, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+			return errors.IsNotFound(err)
+		}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+		gomega.Expect(time.Since(deletedAt) >= managerOptions.CleanupTimeout).To(gomega.BeTrue())
+
+		// the applied resources are left on the managed cluster until the agent is back
+		_, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm1", metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+})
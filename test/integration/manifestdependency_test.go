@@ -0,0 +1,127 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/pkg/spoke/controllers/manifestcontroller"
+	"open-cluster-management.io/work/pkg/spoke/controllers/statuscontroller"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("ManifestWork with manifest dependencies", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+	var configMapNamespace string
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+		configMapNamespace = "ns-" + utilrand.String(5)
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		statuscontroller.ControllerReSyncInterval = 3 * time.Second
+		manifestcontroller.DependencyRequeueTime = 1 * time.Second
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+	})
+
+	ginkgo.AfterEach(func() {
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		err = spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), configMapNamespace, metav1.DeleteOptions{})
+		if !errors.IsNotFound(err) {
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		}
+	})
+
+	ginkgo.It("should apply the deployment only after the configmap it depends on is available", func() {
+		deployment, _, err := util.NewDeployment(o.SpokeClusterName, "deploy1", "sa")
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		// the namespace of the configmap does not exist yet, so the configmap can not be applied
+		configMap := util.NewConfigmap(configMapNamespace, "cm1", map[string]string{"a": "b"}, nil)
+
+		work := util.NewManifestWork(o.SpokeClusterName, "", []workapiv1.Manifest{util.ToManifest(deployment), util.ToManifest(configMap)})
+		work.Annotations = map[string]string{
+			constants.ManifestDependenciesAnnotationKey: `[{"ordinal": 0, "dependsOn": [{"kind": "ConfigMap", "name": "cm1"}]}]`,
+		}
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		ginkgo.By("the deployment waits for the configmap")
+		gomega.Eventually(func() error {
+			work, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if len(work.Status.ResourceStatus.Manifests) != 2 {
+				return fmt.Errorf("expected 2 manifests in status, but got %d", len(work.Status.ResourceStatus.Manifests))
+			}
+			applied := meta.FindStatusCondition(work.Status.ResourceStatus.Manifests[0].Conditions, string(workapiv1.ManifestApplied))
+			if applied == nil || applied.Status != metav1.ConditionFalse || applied.Reason != "WaitingForDependency" {
+				return fmt.Errorf("expected the deployment waiting for the configmap, but got %v", applied)
+			}
+			return nil
+		}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+
+		_, err = spokeKubeClient.AppsV1().Deployments(o.SpokeClusterName).Get(context.Background(), "deploy1", metav1.GetOptions{})
+		gomega.Expect(errors.IsNotFound(err)).To(gomega.BeTrue())
+
+		ginkgo.By("the deployment is applied once the configmap is available")
+		ns := &corev1.Namespace{}
+		ns.Name = configMapNamespace
+		_, err = spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue, metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+		gomega.Eventually(func() error {
+			_, err := spokeKubeClient.AppsV1().Deployments(o.SpokeClusterName).Get(context.Background(), "deploy1", metav1.GetOptions{})
+			return err
+		}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+
+		err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should reject a manifestwork whose manifests depend on each other", func() {
+		work := util.NewManifestWork(o.SpokeClusterName, "", []workapiv1.Manifest{
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm1", map[string]string{"a": "b"}, nil)),
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm2", map[string]string{"a": "b"}, nil)),
+		})
+		work.Annotations = map[string]string{
+			constants.ManifestDependenciesAnnotationKey: `[{"ordinal": 0, "dependsOn": [{"ordinal": 1}]}, {"ordinal": 1, "dependsOn": [{"ordinal": 0}]}]`,
+		}
+		work, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionFalse,
+			[]metav1.ConditionStatus{metav1.ConditionFalse, metav1.ConditionFalse}, eventuallyTimeout, eventuallyInterval)
+
+		err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+})
@@ -0,0 +1,147 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("Applying manifests as patches", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	var work *workapiv1.ManifestWork
+	var err error
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+	})
+
+	ginkgo.AfterEach(func() {
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should patch the labels of a pre-existing configmap and revert them once the work is deleted", func() {
+		cm := util.NewConfigmap(o.SpokeClusterName, "cm1", map[string]string{"a": "b"}, nil)
+		_, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Create(context.Background(), cm, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		patch := workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: []byte(`{"metadata":{"labels":{"patched":"true"}}}`)}}
+		work = util.NewManifestWork(o.SpokeClusterName, "work-patch", []workapiv1.Manifest{patch})
+		work.Annotations = map[string]string{
+			constants.ManifestConfigOptionsAnnotationKey: fmt.Sprintf(`[{"ordinal": 0,
+				"resourceIdentifier": {"version": "v1", "resource": "configmaps", "namespace": %q, "name": "cm1"},
+				"updateStrategy": {"type": "Patch", "patchType": "MergePatch", "revertPatch": {"metadata":{"labels":{"patched":null}}}}}]`,
+				o.SpokeClusterName),
+		}
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+		// the patched configmap is available once it exists
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkAvailable), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+
+		gomega.Eventually(func() error {
+			cm, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm1", metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if cm.Labels["patched"] != "true" {
+				return fmt.Errorf("expected configmap patched, but got labels %v", cm.Labels)
+			}
+			if cm.Data["a"] != "b" || len(cm.OwnerReferences) != 0 {
+				return fmt.Errorf("expected configmap not owned with data kept, but got %v", cm)
+			}
+			return nil
+		}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+
+		err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		gomega.Eventually(func() bool {
+			_, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+			return errors.IsNotFound(err)
+		}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+
+		// the configmap is not deleted with the work, only the patch is reverted
+		cm, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm1", metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		gomega.Expect(cm.Labels).ToNot(gomega.HaveKey("patched"))
+		gomega.Expect(cm.Data).To(gomega.HaveKeyWithValue("a", "b"))
+	})
+
+	ginkgo.It("should revert the patch once it is dropped from the work", func() {
+		cm := util.NewConfigmap(o.SpokeClusterName, "cm1", map[string]string{"a": "b"}, nil)
+		_, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Create(context.Background(), cm, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		patch := workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: []byte(`{"metadata":{"labels":{"patched":"true"}}}`)}}
+		work = util.NewManifestWork(o.SpokeClusterName, "work-patch-dropped", []workapiv1.Manifest{patch})
+		work.Annotations = map[string]string{
+			constants.ManifestConfigOptionsAnnotationKey: fmt.Sprintf(`[{"ordinal": 0,
+				"resourceIdentifier": {"version": "v1", "resource": "configmaps", "namespace": %q, "name": "cm1"},
+				"updateStrategy": {"type": "Patch", "patchType": "MergePatch", "revertPatch": {"metadata":{"labels":{"patched":null}}}}}]`,
+				o.SpokeClusterName),
+		}
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+
+		// replace the patch with a configmap owned by the work
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		work.Annotations = nil
+		work.Spec.Workload.Manifests = []workapiv1.Manifest{
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm2", map[string]string{"c": "d"}, nil)),
+		}
+		_, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Update(context.Background(), work, metav1.UpdateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		gomega.Eventually(func() error {
+			cm, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm1", metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if _, ok := cm.Labels["patched"]; ok {
+				return fmt.Errorf("expected the patch of configmap reverted, but got labels %v", cm.Labels)
+			}
+			return nil
+		}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+		// the patched configmap is not pruned with the patch
+		gomega.Consistently(func() error {
+			_, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm1", metav1.GetOptions{})
+			return err
+		}, 3, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+	})
+})
@@ -0,0 +1,124 @@
+package integration
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/pkg/spoke/controllers/statuscontroller"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("ManifestWork with manifests referencing hub ConfigMaps", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+		o.EnableManifestReferences = true
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		statuscontroller.ControllerReSyncInterval = 3 * time.Second
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+	})
+
+	ginkgo.AfterEach(func() {
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	// gzippedConfigMap returns the gzipped JSON of a configmap whose data is too large to be inlined in a manifestwork
+	// together with other manifests.
+	gzippedConfigMap := func(name, value string) []byte {
+		data, err := json.Marshal(util.NewConfigmap(o.SpokeClusterName, name, map[string]string{"data": value}, nil))
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		_, err = writer.Write(data)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		gomega.Expect(writer.Close()).ToNot(gomega.HaveOccurred())
+		return buf.Bytes()
+	}
+
+	assertConfigMapData := func(name, value string) {
+		gomega.Eventually(func() bool {
+			cm, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				return false
+			}
+			return cm.Data["data"] == value
+		}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+	}
+
+	ginkgo.It("should apply the referenced manifest and re-apply it once the referenced configmap changes", func() {
+		largeValue := strings.Repeat("a", 900*1024)
+
+		source := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: o.SpokeClusterName, Name: "manifests"},
+			BinaryData: map[string][]byte{"cm.json.gz": gzippedConfigMap("large", largeValue)},
+		}
+		source, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Create(context.Background(), source, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		reference := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": workapiv1.GroupVersion.String(),
+			"kind":       "ManifestReference",
+			"spec": map[string]interface{}{
+				"kind": "ConfigMap",
+				"name": "manifests",
+				"key":  "cm.json.gz",
+			},
+		}}
+		manifests := []workapiv1.Manifest{
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "small", map[string]string{"data": "small"}, nil)),
+			util.ToManifest(reference),
+		}
+		work := util.NewManifestWork(o.SpokeClusterName, "", manifests)
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue, metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+		assertConfigMapData("small", "small")
+		assertConfigMapData("large", largeValue)
+
+		// the source reference is recorded in the manifest condition
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		gomega.Expect(work.Status.ResourceStatus.Manifests[1].ResourceMeta.Name).To(gomega.Equal("large"))
+		gomega.Expect(work.Status.ResourceStatus.Manifests[1].Conditions[0].Message).To(gomega.ContainSubstring("from ConfigMap"))
+
+		// change the referenced configmap
+		updatedValue := strings.Repeat("b", 900*1024)
+		source.BinaryData["cm.json.gz"] = gzippedConfigMap("large", updatedValue)
+		_, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Update(context.Background(), source, metav1.UpdateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		assertConfigMapData("large", updatedValue)
+	})
+})
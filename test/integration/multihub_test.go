@@ -0,0 +1,135 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	workclientset "open-cluster-management.io/api/client/work/clientset/versioned"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("ManifestWorks from multiple hubs", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	// the second hub, the first hub is the test environment of the suite
+	var hub2Env *envtest.Environment
+	var hub2WorkClient workclientset.Interface
+	var hub2Hash string
+	var spokeDynamicClient dynamic.Interface
+
+	var work1, work2 *workapiv1.ManifestWork
+
+	ginkgo.BeforeEach(func() {
+		hub2Env = &envtest.Environment{
+			ErrorIfCRDPathMissing: true,
+			CRDDirectoryPaths: []string{
+				filepath.Join(".", "deploy", "webhook"),
+			},
+		}
+		hub2Config, err := hub2Env.Start()
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		hub2KubeconfigFileName := path.Join(tempDir, "kubeconfig-hub2")
+		err = util.CreateKubeconfigFile(hub2Config, hub2KubeconfigFileName)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		hub2Hash = helper.HubHash(hub2Config.Host)
+		hub2WorkClient, err = workclientset.NewForConfig(hub2Config)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		hub2KubeClient, err := kubernetes.NewForConfig(hub2Config)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		spokeDynamicClient, err = dynamic.NewForConfig(spokeRestConfig)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.AdditionalHubKubeconfigFiles = []string{hub2KubeconfigFileName}
+		o.SpokeClusterName = utilrand.String(5)
+
+		// the cluster namespace is the same on the spoke and both hubs
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err = spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		_, err = hub2KubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+	})
+
+	ginkgo.AfterEach(func() {
+		if work1 != nil {
+			err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work1.Name, metav1.DeleteOptions{})
+			if !errors.IsNotFound(err) {
+				gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			}
+			util.AssertAppliedManifestWorkDeleted(fmt.Sprintf("%s-%s", hubHash, work1.Name), spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		}
+
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		err = hub2Env.Stop()
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should apply the works of both hubs to the same cluster namespace", func() {
+		ginkgo.By("create works with the same name on both hubs")
+		work1 = util.NewManifestWork(o.SpokeClusterName, "multihub-work", []workapiv1.Manifest{
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm1", map[string]string{"a": "b"}, nil)),
+		})
+		var err error
+		work1, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work1, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		work2 = util.NewManifestWork(o.SpokeClusterName, "multihub-work", []workapiv1.Manifest{
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm2", map[string]string{"a": "b"}, nil)),
+		})
+		work2, err = hub2WorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work2, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		ginkgo.By("the status of each work is reported to its own hub")
+		util.AssertWorkCondition(work1.Namespace, work1.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+		util.AssertWorkCondition(work2.Namespace, work2.Name, hub2WorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+
+		ginkgo.By("the applied resources are tracked by the appliedmanifestwork of each hub")
+		configMapGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+		util.AssertAppliedResources(hubHash, work1.Name, []schema.GroupVersionResource{configMapGVR},
+			[]string{o.SpokeClusterName}, []string{"cm1"}, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		util.AssertAppliedResources(hub2Hash, work2.Name, []schema.GroupVersionResource{configMapGVR},
+			[]string{o.SpokeClusterName}, []string{"cm2"}, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+
+		ginkgo.By("delete the work on the second hub")
+		err = hub2WorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work2.Name, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		util.AssertAppliedManifestWorkDeleted(fmt.Sprintf("%s-%s", hub2Hash, work2.Name), spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		util.AssertNonexistenceOfResources([]schema.GroupVersionResource{configMapGVR}, []string{o.SpokeClusterName}, []string{"cm2"},
+			spokeDynamicClient, eventuallyTimeout, eventuallyInterval)
+
+		ginkgo.By("the work of the first hub is not affected")
+		util.AssertAppliedResources(hubHash, work1.Name, []schema.GroupVersionResource{configMapGVR},
+			[]string{o.SpokeClusterName}, []string{"cm1"}, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+	})
+})
@@ -0,0 +1,185 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/dynamic"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/helper"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/pkg/spoke/controllers/finalizercontroller"
+	"open-cluster-management.io/work/pkg/spoke/controllers/statuscontroller"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("ManifestWorks with the hub partitioned", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	// the agent connects to the hub through the partition, the specs read and write the hub directly
+	var partition *util.HubPartition
+	var partitionHubHash string
+	var heartbeatInterval time.Duration
+
+	var work *workapiv1.ManifestWork
+	var appliedManifestWorkName string
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		partition, err = util.NewHubPartition(spokeRestConfig)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		partitionKubeconfigFileName := path.Join(tempDir, "kubeconfig-partition-"+utilrand.String(5))
+		err = util.CreateKubeconfigFile(partition.RestConfig(), partitionKubeconfigFileName)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		partitionHubHash = helper.HubHash(partition.RestConfig().Host)
+
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = partitionKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+		o.StaleCacheThreshold = 2 * time.Second
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err = spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		statuscontroller.ControllerReSyncInterval = 3 * time.Second
+		heartbeatInterval = finalizercontroller.HeartbeatInterval
+		finalizercontroller.HeartbeatInterval = 2 * time.Second
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+
+		work = util.NewManifestWork(o.SpokeClusterName, "", []workapiv1.Manifest{
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm1", map[string]string{"a": "b"}, nil)),
+		})
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		appliedManifestWorkName = fmt.Sprintf("%s-%s", partitionHubHash, work.Name)
+
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkAvailable), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+	})
+
+	ginkgo.AfterEach(func() {
+		partition.Heal()
+		err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+		if !errors.IsNotFound(err) {
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		}
+		util.AssertAppliedManifestWorkDeleted(appliedManifestWorkName, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+
+		if cancel != nil {
+			cancel()
+		}
+		partition.Close()
+		finalizercontroller.HeartbeatInterval = heartbeatInterval
+		err = spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should not evict the appliedmanifestwork of a partitioned hub within the eviction grace period", func() {
+		// the agent of another hub evicts the appliedmanifestworks whose heartbeat is stale beyond the grace period
+		evictionGracePeriod := 6 * time.Second
+		o2 := spoke.NewWorkloadAgentOptions()
+		o2.HubKubeconfigFile = hubKubeconfigFileName
+		o2.SpokeClusterName = utilrand.String(5)
+		o2.AppliedManifestWorkEvictionGracePeriod = evictionGracePeriod
+		ns := &corev1.Namespace{}
+		ns.Name = o2.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		defer func() {
+			err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o2.SpokeClusterName, metav1.DeleteOptions{})
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		}()
+		ctx2, cancel2 := context.WithCancel(context.Background())
+		defer cancel2()
+		go startWorkAgent(ctx2, o2)
+
+		ginkgo.By("partition the hub")
+		partition.Inject(util.HubFault{Mode: util.HubFaultDrop})
+		util.AssertNoStatusUpdates(work.Namespace, work.Name, hubWorkClient, int(2*evictionGracePeriod/time.Second), eventuallyInterval)
+
+		ginkgo.By("the heartbeat of the appliedmanifestwork does not depend on the hub")
+		_, err = spokeWorkClient.WorkV1().AppliedManifestWorks().Get(context.Background(), appliedManifestWorkName, metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		util.AssertExistenceOfConfigMaps(work.Spec.Workload.Manifests, spokeKubeClient, eventuallyTimeout, eventuallyInterval)
+
+		ginkgo.By("update the work on the hub and heal the partition")
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		newManifests := append(work.Spec.Workload.Manifests,
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm2", map[string]string{"c": "d"}, nil)))
+		work.Spec.Workload.Manifests = newManifests
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Update(context.Background(), work, metav1.UpdateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		partition.Heal()
+
+		util.AssertConvergedAfterHeal(partition, func() error {
+			actual, err := hubWorkClient.WorkV1().ManifestWorks(work.Namespace).Get(context.Background(), work.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			applied := meta.FindStatusCondition(actual.Status.Conditions, string(workapiv1.WorkApplied))
+			if applied == nil || applied.Status != metav1.ConditionTrue || applied.ObservedGeneration != actual.Generation {
+				return fmt.Errorf("work %s/%s should be applied at generation %d, but got %v", work.Namespace, work.Name, actual.Generation, applied)
+			}
+			return nil
+		}, eventuallyTimeout, eventuallyInterval)
+		util.AssertExistenceOfConfigMaps(newManifests, spokeKubeClient, eventuallyTimeout, eventuallyInterval)
+
+		ginkgo.By("the appliedmanifestwork is evicted once its agent is gone beyond the grace period")
+		cancel()
+		cancel = nil
+		util.AssertAppliedManifestWorkDeleted(appliedManifestWorkName, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		spokeDynamicClient, err := dynamic.NewForConfig(spokeRestConfig)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		configMapGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+		util.AssertNonexistenceOfResources([]schema.GroupVersionResource{configMapGVR, configMapGVR},
+			[]string{o.SpokeClusterName, o.SpokeClusterName}, []string{"cm1", "cm2"}, spokeDynamicClient, eventuallyTimeout, eventuallyInterval)
+	})
+
+	ginkgo.It("should not report resources unavailable while the cache of the hub is stale", func() {
+		ginkgo.By("partition the reads of the hub, the status is still written")
+		partition.Inject(util.HubFault{Mode: util.HubFaultServerError, Match: util.HubReadRequests})
+		util.AssertNoStatusUpdates(work.Namespace, work.Name, hubWorkClient, int(2*o.StaleCacheThreshold/time.Second), eventuallyInterval)
+
+		ginkgo.By("delete the applied resource")
+		err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Delete(context.Background(), "cm1", metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkAvailable), metav1.ConditionUnknown,
+			[]metav1.ConditionStatus{metav1.ConditionUnknown}, eventuallyTimeout, eventuallyInterval)
+		actual, err := hubWorkClient.WorkV1().ManifestWorks(work.Namespace).Get(context.Background(), work.Name, metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		available := meta.FindStatusCondition(actual.Status.Conditions, string(workapiv1.WorkAvailable))
+		gomega.Expect(available.Reason).To(gomega.Equal("StaleCache"))
+
+		ginkgo.By("heal the partition")
+		partition.Heal()
+		util.AssertConvergedAfterHeal(partition, func() error {
+			actual, err := hubWorkClient.WorkV1().ManifestWorks(work.Namespace).Get(context.Background(), work.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if !meta.IsStatusConditionFalse(actual.Status.Conditions, string(workapiv1.WorkAvailable)) {
+				return fmt.Errorf("work %s/%s should be unavailable, but got %v", work.Namespace, work.Name, actual.Status.Conditions)
+			}
+			return nil
+		}, eventuallyTimeout, eventuallyInterval)
+	})
+})
@@ -0,0 +1,312 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/rest"
+
+	"github.com/openshift/library-go/pkg/controller/controllercmd"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/pkg/spoke/controllers/statuscontroller"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("Pruning resources removed from ManifestWork", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	var work *workapiv1.ManifestWork
+	var manifests []workapiv1.Manifest
+	var appliedManifestWorkName string
+
+	var err error
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		statuscontroller.ControllerReSyncInterval = 3 * time.Second
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+
+		manifests = []workapiv1.Manifest{
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm1", map[string]string{"a": "b"}, nil)),
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm2", map[string]string{"c": "d"}, nil)),
+		}
+	})
+
+	ginkgo.JustBeforeEach(func() {
+		work = util.NewManifestWork(o.SpokeClusterName, "", manifests)
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		appliedManifestWorkName = fmt.Sprintf("%s-%s", hubHash, work.Name)
+
+		util.AssertExistenceOfConfigMaps(manifests, spokeKubeClient, eventuallyTimeout, eventuallyInterval)
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue, metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+	})
+
+	ginkgo.AfterEach(func() {
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	updateManifests := func(newManifests []workapiv1.Manifest, deleteOption *workapiv1.DeleteOption) {
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		work.Spec.Workload.Manifests = newManifests
+		work.Spec.DeleteOption = deleteOption
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Update(context.Background(), work, metav1.UpdateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	}
+
+	assertNotApplied := func(name string) {
+		gomega.Eventually(func() bool {
+			appliedManifestWork, err := spokeWorkClient.WorkV1().AppliedManifestWorks().Get(context.Background(), appliedManifestWorkName, metav1.GetOptions{})
+			if err != nil {
+				return false
+			}
+
+			for _, appliedResource := range appliedManifestWork.Status.AppliedResources {
+				if appliedResource.Name == name {
+					return false
+				}
+			}
+
+			return true
+		}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+	}
+
+	ginkgo.It("should delete the resource of a dropped manifest", func() {
+		updateManifests(manifests[:1], nil)
+
+		assertNotApplied("cm2")
+		_, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm2", metav1.GetOptions{})
+		gomega.Expect(errors.IsNotFound(err)).To(gomega.BeTrue())
+
+		_, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm1", metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should delete the resource of a renamed manifest once the new resource is adopted", func() {
+		newManifests := []workapiv1.Manifest{
+			manifests[0],
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm3", map[string]string{"c": "d"}, nil)),
+		}
+		updateManifests(newManifests, nil)
+
+		util.AssertExistenceOfConfigMaps(newManifests, spokeKubeClient, eventuallyTimeout, eventuallyInterval)
+		configMapGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+		util.AssertAppliedResources(hubHash, work.Name, []schema.GroupVersionResource{configMapGVR, configMapGVR},
+			[]string{o.SpokeClusterName, o.SpokeClusterName}, []string{"cm1", "cm3"}, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		_, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm2", metav1.GetOptions{})
+		gomega.Expect(errors.IsNotFound(err)).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should orphan the resource of a dropped manifest matching the orphaning rules", func() {
+		updateManifests(manifests[:1], &workapiv1.DeleteOption{
+			PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+			SelectivelyOrphan: &workapiv1.SelectivelyOrphan{
+				OrphaningRules: []workapiv1.OrphaningRule{
+					{Group: "", Resource: "configmaps", Namespace: o.SpokeClusterName, Name: "cm2"},
+				},
+			},
+		})
+
+		assertNotApplied("cm2")
+		gomega.Eventually(func() error {
+			cm, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm2", metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if len(cm.OwnerReferences) != 0 {
+				return fmt.Errorf("expected no owner references, but got %v", cm.OwnerReferences)
+			}
+			return nil
+		}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should orphan the resource of a dropped manifest with the Orphan policy", func() {
+		updateManifests(manifests[:1], &workapiv1.DeleteOption{
+			PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan,
+		})
+
+		assertNotApplied("cm2")
+		gomega.Eventually(func() error {
+			cm, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm2", metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if len(cm.OwnerReferences) != 0 {
+				return fmt.Errorf("expected no owner references, but got %v", cm.OwnerReferences)
+			}
+			return nil
+		}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+		gomega.Consistently(func() error {
+			_, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm2", metav1.GetOptions{})
+			return err
+		}, 3, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+
+		// the resources left are orphaned the same way once the whole manifestwork is deleted
+		err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		gomega.Eventually(func() bool {
+			_, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+			return errors.IsNotFound(err)
+		}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+		_, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm1", metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+})
+
+var _ = ginkgo.Describe("Pruning resources removed from ManifestWork without the permission to delete", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	var work *workapiv1.ManifestWork
+	var manifests []workapiv1.Manifest
+	var executor string
+
+	var err error
+
+	// the rules of the executor, it is allowed to do everything but deleting resources
+	executorRules := func(verbs ...string) []rbacv1.PolicyRule {
+		return []rbacv1.PolicyRule{
+			{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: verbs},
+		}
+	}
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+		executor = fmt.Sprintf("executor-%s", o.SpokeClusterName)
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		clusterRole := &rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: executor},
+			Rules:      executorRules("get", "list", "watch", "create", "update", "patch"),
+		}
+		_, err = spokeKubeClient.RbacV1().ClusterRoles().Create(context.Background(), clusterRole, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		clusterRoleBinding := &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: executor},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: executor},
+			Subjects:   []rbacv1.Subject{{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: executor}},
+		}
+		_, err = spokeKubeClient.RbacV1().ClusterRoleBindings().Create(context.Background(), clusterRoleBinding, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		// the agent applies the manifests on the spoke as the executor
+		executorConfig := rest.CopyConfig(spokeRestConfig)
+		executorConfig.Impersonate = rest.ImpersonationConfig{UserName: executor}
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go func() {
+			err := o.RunWorkloadAgent(ctx, &controllercmd.ControllerContext{
+				KubeConfig:    executorConfig,
+				EventRecorder: util.NewIntegrationTestEventRecorder("integration"),
+			})
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		}()
+
+		manifests = []workapiv1.Manifest{
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm1", map[string]string{"a": "b"}, nil)),
+			util.ToManifest(util.NewConfigmap(o.SpokeClusterName, "cm2", map[string]string{"c": "d"}, nil)),
+		}
+
+		work = util.NewManifestWork(o.SpokeClusterName, "", manifests)
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		util.AssertExistenceOfConfigMaps(manifests, spokeKubeClient, eventuallyTimeout, eventuallyInterval)
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue, metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+	})
+
+	ginkgo.AfterEach(func() {
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.RbacV1().ClusterRoleBindings().Delete(context.Background(), executor, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		err = spokeKubeClient.RbacV1().ClusterRoles().Delete(context.Background(), executor, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		err = spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should keep the resource of a dropped manifest until the executor is allowed to delete it", func() {
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		work.Spec.Workload.Manifests = manifests[:1]
+		_, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Update(context.Background(), work, metav1.UpdateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		// the prune is blocked, the resource is kept and tracked
+		gomega.Eventually(func() bool {
+			work, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+			if err != nil {
+				return false
+			}
+			return meta.IsStatusConditionTrue(work.Status.Conditions, constants.WorkObsoleteResourcesPending)
+		}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+		configMapGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+		util.AssertAppliedResources(hubHash, work.Name, []schema.GroupVersionResource{configMapGVR, configMapGVR},
+			[]string{o.SpokeClusterName, o.SpokeClusterName}, []string{"cm1", "cm2"}, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		_, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm2", metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		// the resource is pruned once the executor is allowed to delete it
+		clusterRole, err := spokeKubeClient.RbacV1().ClusterRoles().Get(context.Background(), executor, metav1.GetOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		clusterRole.Rules = executorRules("get", "list", "watch", "create", "update", "patch", "delete")
+		_, err = spokeKubeClient.RbacV1().ClusterRoles().Update(context.Background(), clusterRole, metav1.UpdateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		gomega.Eventually(func() bool {
+			_, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), "cm2", metav1.GetOptions{})
+			return errors.IsNotFound(err)
+		}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+		util.AssertAppliedResources(hubHash, work.Name, []schema.GroupVersionResource{configMapGVR},
+			[]string{o.SpokeClusterName}, []string{"cm1"}, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+		gomega.Eventually(func() bool {
+			work, err := hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+			if err != nil {
+				return false
+			}
+			return meta.FindStatusCondition(work.Status.Conditions, constants.WorkObsoleteResourcesPending) == nil
+		}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+	})
+})
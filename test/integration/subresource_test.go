@@ -0,0 +1,92 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/dynamic"
+
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
+	"open-cluster-management.io/work/pkg/spoke"
+	"open-cluster-management.io/work/test/integration/util"
+)
+
+var _ = ginkgo.Describe("Applying manifests to subresources", func() {
+	var o *spoke.WorkloadAgentOptions
+	var cancel context.CancelFunc
+
+	var work *workapiv1.ManifestWork
+	var err error
+
+	ginkgo.BeforeEach(func() {
+		o = spoke.NewWorkloadAgentOptions()
+		o.HubKubeconfigFile = hubKubeconfigFileName
+		o.SpokeClusterName = utilrand.String(5)
+
+		ns := &corev1.Namespace{}
+		ns.Name = o.SpokeClusterName
+		_, err := spokeKubeClient.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		go startWorkAgent(ctx, o)
+	})
+
+	ginkgo.AfterEach(func() {
+		if cancel != nil {
+			cancel()
+		}
+		err := spokeKubeClient.CoreV1().Namespaces().Delete(context.Background(), o.SpokeClusterName, metav1.DeleteOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.It("should scale a pre-existing deployment", func() {
+		var spokeDynamicClient dynamic.Interface
+		spokeDynamicClient, err = dynamic.NewForConfig(spokeRestConfig)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		deploy, gvr, err := util.NewDeployment(o.SpokeClusterName, "deploy1", "sa")
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		_, err = spokeDynamicClient.Resource(gvr).Namespace(o.SpokeClusterName).Create(context.Background(), deploy, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		scale := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"namespace": o.SpokeClusterName, "name": "deploy1"},
+			"spec":       map[string]interface{}{"replicas": int64(3)},
+		}}
+		work = util.NewManifestWork(o.SpokeClusterName, "work-scale", []workapiv1.Manifest{util.ToManifest(scale)})
+		work.Annotations = map[string]string{
+			constants.ManifestSubresourcesAnnotationKey: `[{"ordinal": 0, "subresource": "scale"}]`,
+		}
+		work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Create(context.Background(), work, metav1.CreateOptions{})
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+		util.AssertWorkCondition(work.Namespace, work.Name, hubWorkClient, string(workapiv1.WorkApplied), metav1.ConditionTrue,
+			[]metav1.ConditionStatus{metav1.ConditionTrue}, eventuallyTimeout, eventuallyInterval)
+
+		gomega.Eventually(func() error {
+			deploy, err := spokeKubeClient.AppsV1().Deployments(o.SpokeClusterName).Get(context.Background(), "deploy1", metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if deploy.Spec.Replicas == nil || *deploy.Spec.Replicas != 3 {
+				return fmt.Errorf("expected 3 replicas, but got %v", deploy.Spec.Replicas)
+			}
+			if len(deploy.OwnerReferences) != 0 {
+				return fmt.Errorf("expected deployment not owned by the work, but got %v", deploy.OwnerReferences)
+			}
+			return nil
+		}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+	})
+})
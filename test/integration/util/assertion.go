@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"time"
 
 	"github.com/onsi/gomega"
 
@@ -181,40 +182,104 @@ func AssertAppliedResources(hubHash, workName string, gvrs []schema.GroupVersion
 		})
 	}
 
-	sort.SliceStable(appliedResources, func(i, j int) bool {
+	appliedManifestWorkName := fmt.Sprintf("%s-%s", hubHash, workName)
+	AssertAppliedResourceMetas(appliedManifestWorkName, appliedResources, workClient, eventuallyTimeout, eventuallyInterval)
+}
+
+// check if the applied resources of the appliedmanifestwork are the expected ones regardless of the order, the uid
+// of an applied resource is only compared if it is set in the expected one
+func AssertAppliedResourceMetas(appliedManifestWorkName string, expectedMetas []workapiv1.AppliedManifestResourceMeta, workClient workclientset.Interface, eventuallyTimeout, eventuallyInterval int) {
+	expected := sortedAppliedResources(expectedMetas)
+
+	gomega.Eventually(func() error {
+		appliedManifestWork, err := workClient.WorkV1().AppliedManifestWorks().Get(context.Background(), appliedManifestWorkName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		actual := sortedAppliedResources(appliedManifestWork.Status.AppliedResources)
+		if len(actual) != len(expected) {
+			return fmt.Errorf("applied resources should be %v, but got %v", expected, actual)
+		}
+		for i := range expected {
+			actualMeta := actual[i]
+			if len(expected[i].UID) == 0 {
+				actualMeta.UID = ""
+			}
+			if actualMeta != expected[i] {
+				return fmt.Errorf("applied resources should be %v, but got %v", expected, actual)
+			}
+		}
+
+		return nil
+	}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+}
+
+func sortedAppliedResources(resources []workapiv1.AppliedManifestResourceMeta) []workapiv1.AppliedManifestResourceMeta {
+	sorted := append([]workapiv1.AppliedManifestResourceMeta{}, resources...)
+	sort.SliceStable(sorted, func(i, j int) bool {
 		switch {
-		case appliedResources[i].Group != appliedResources[j].Group:
-			return appliedResources[i].Group < appliedResources[j].Group
-		case appliedResources[i].Version != appliedResources[j].Version:
-			return appliedResources[i].Version < appliedResources[j].Version
-		case appliedResources[i].Resource != appliedResources[j].Resource:
-			return appliedResources[i].Resource < appliedResources[j].Resource
-		case appliedResources[i].Namespace != appliedResources[j].Namespace:
-			return appliedResources[i].Namespace < appliedResources[j].Namespace
+		case sorted[i].Group != sorted[j].Group:
+			return sorted[i].Group < sorted[j].Group
+		case sorted[i].Version != sorted[j].Version:
+			return sorted[i].Version < sorted[j].Version
+		case sorted[i].Resource != sorted[j].Resource:
+			return sorted[i].Resource < sorted[j].Resource
+		case sorted[i].Namespace != sorted[j].Namespace:
+			return sorted[i].Namespace < sorted[j].Namespace
 		default:
-			return appliedResources[i].Name < appliedResources[j].Name
+			return sorted[i].Name < sorted[j].Name
 		}
 	})
+	return sorted
+}
 
-	gomega.Eventually(func() bool {
-		appliedManifestWorkName := fmt.Sprintf("%s-%s", hubHash, workName)
-		appliedManifestWork, err := workClient.WorkV1().AppliedManifestWorks().Get(context.Background(), appliedManifestWorkName, metav1.GetOptions{})
+// check if the finalizer is removed from the resource with GVR, namespace and name, a deleted resource has no
+// finalizer
+func AssertFinalizerRemoved(gvr schema.GroupVersionResource, namespace, name, finalizer string, dynamicClient dynamic.Interface, eventuallyTimeout, eventuallyInterval int) {
+	gomega.Eventually(func() error {
+		obj, err := GetResource(namespace, name, gvr, dynamicClient)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
 		if err != nil {
-			return false
+			return err
 		}
 
-		// remove uid from each AppliedManifestResourceMeta
-		var actualAppliedResources []workapiv1.AppliedManifestResourceMeta
-		for _, appliedResource := range appliedManifestWork.Status.AppliedResources {
-			actualAppliedResources = append(actualAppliedResources, workapiv1.AppliedManifestResourceMeta{
-				Group:     appliedResource.Group,
-				Version:   appliedResource.Version,
-				Resource:  appliedResource.Resource,
-				Namespace: appliedResource.Namespace,
-				Name:      appliedResource.Name,
-			})
+		for _, f := range obj.GetFinalizers() {
+			if f == finalizer {
+				return fmt.Errorf("finalizer %s should be removed from %v %s/%s", finalizer, gvr, namespace, name)
+			}
 		}
+		return nil
+	}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+}
 
-		return reflect.DeepEqual(actualAppliedResources, appliedResources)
-	}, eventuallyTimeout, eventuallyInterval).Should(gomega.BeTrue())
+// check if the status of the work is not updated for the duration, e.g. while the hub is partitioned from the agent
+func AssertNoStatusUpdates(namespace, name string, workClient workclientset.Interface, duration, interval int) {
+	work, err := workClient.WorkV1().ManifestWorks(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+	gomega.Consistently(func() error {
+		actual, err := workClient.WorkV1().ManifestWorks(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(actual.Status, work.Status) {
+			return fmt.Errorf("status of work %s/%s should not be updated, but got %v", namespace, name, actual.Status)
+		}
+		return nil
+	}, duration, interval).ShouldNot(gomega.HaveOccurred())
+}
+
+// check if converged returns no error within the seconds since the partition is healed
+func AssertConvergedAfterHeal(partition *HubPartition, converged func() error, seconds, eventuallyInterval int) {
+	healedAt := partition.HealedAt()
+	gomega.Expect(healedAt.IsZero()).To(gomega.BeFalse(), "the partition should be healed")
+
+	remaining := time.Duration(seconds)*time.Second - time.Since(healedAt)
+	if remaining < 0 {
+		remaining = 0
+	}
+	gomega.Eventually(converged, remaining, time.Duration(eventuallyInterval)*time.Second).ShouldNot(gomega.HaveOccurred())
 }
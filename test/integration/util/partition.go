@@ -0,0 +1,183 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// HubFaultMode is how the requests to the hub fail during a partition.
+type HubFaultMode string
+
+const (
+	// HubFaultDrop closes the connection of the request without a response.
+	HubFaultDrop HubFaultMode = "Drop"
+	// HubFaultDelay forwards the request to the hub after the delay of the fault.
+	HubFaultDelay HubFaultMode = "Delay"
+	// HubFaultServerError responds to the request with an internal error.
+	HubFaultServerError HubFaultMode = "ServerError"
+)
+
+// HubFault is a fault injected into the requests to the hub.
+type HubFault struct {
+	Mode HubFaultMode
+	// Delay is how long the requests are delayed with HubFaultDelay.
+	Delay time.Duration
+	// Match selects the requests the fault is injected into, all requests are selected if it is nil.
+	Match func(req *http.Request) bool
+}
+
+// HubReadRequests selects the get, list and watch requests, so that a partition only breaks the informers of the
+// agent while the status is still written to the hub.
+func HubReadRequests(req *http.Request) bool {
+	return req.Method == http.MethodGet
+}
+
+// HubPartition is a reverse proxy in front of the hub apiserver, which injects faults into the requests of the
+// agent connecting to the hub with the kubeconfig of the proxy, so that specs can simulate network partitions
+// between the agent and the hub. The specs read and write the hub with the clients of the hub directly.
+type HubPartition struct {
+	server *httptest.Server
+	proxy  *httputil.ReverseProxy
+
+	lock  sync.Mutex
+	fault *HubFault
+	// inFlight is cancelled once a fault is injected, so that the requests in flight, e.g. the watches of the
+	// informers, are broken like the connections of a real partition.
+	inFlight context.Context
+	cancel   context.CancelFunc
+	healedAt time.Time
+}
+
+// NewHubPartition starts a HubPartition forwarding the requests to the hub with the transport of the hub config.
+// The partition must be closed once the spec is done.
+func NewHubPartition(hubConfig *rest.Config) (*HubPartition, error) {
+	transport, err := rest.TransportFor(hubConfig)
+	if err != nil {
+		return nil, err
+	}
+	hubURL, _, err := rest.DefaultServerURL(hubConfig.Host, hubConfig.APIPath, metav1.SchemeGroupVersion, true)
+	if err != nil {
+		return nil, err
+	}
+	target := &url.URL{Scheme: hubURL.Scheme, Host: hubURL.Host}
+
+	p := &HubPartition{proxy: httputil.NewSingleHostReverseProxy(target)}
+	p.proxy.Transport = transport
+	// flush immediately, the watch events are streamed
+	p.proxy.FlushInterval = -1
+	p.inFlight, p.cancel = context.WithCancel(context.Background())
+	p.server = httptest.NewServer(p)
+	return p, nil
+}
+
+// RestConfig returns the config connecting to the hub through the partition, the agent authenticates with the
+// hub config of the partition.
+func (p *HubPartition) RestConfig() *rest.Config {
+	return &rest.Config{Host: p.server.URL}
+}
+
+// Inject injects the fault into the requests to the hub until the partition is healed. The requests in flight
+// are broken.
+func (p *HubPartition) Inject(fault HubFault) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.fault = &fault
+	p.cancel()
+	p.inFlight, p.cancel = context.WithCancel(context.Background())
+}
+
+// Heal stops injecting faults into the requests to the hub.
+func (p *HubPartition) Heal() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.fault = nil
+	p.healedAt = time.Now()
+}
+
+// HealedAt returns the last time the partition was healed, it is zero if the partition was never healed.
+func (p *HubPartition) HealedAt() time.Time {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.healedAt
+}
+
+// Close stops the proxy and closes the connections of the agent.
+func (p *HubPartition) Close() {
+	p.lock.Lock()
+	p.cancel()
+	p.lock.Unlock()
+
+	p.server.CloseClientConnections()
+	p.server.Close()
+}
+
+func (p *HubPartition) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p.lock.Lock()
+	fault, inFlight := p.fault, p.inFlight
+	p.lock.Unlock()
+
+	if fault != nil && (fault.Match == nil || fault.Match(req)) {
+		switch fault.Mode {
+		case HubFaultDrop:
+			dropConnection(w)
+			return
+		case HubFaultServerError:
+			writeServerError(w)
+			return
+		case HubFaultDelay:
+			select {
+			case <-time.After(fault.Delay):
+			case <-req.Context().Done():
+				return
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-inFlight.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	p.proxy.ServeHTTP(w, req.WithContext(ctx))
+}
+
+func dropConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
+
+func writeServerError(w http.ResponseWriter) {
+	status := metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure,
+		Code:     http.StatusInternalServerError,
+		Reason:   metav1.StatusReasonInternalError,
+		Message:  "fault injected by the hub partition",
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestHubPartition(t *testing.T) {
+	gomega.RegisterTestingT(t)
+
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/watch" {
+			// stream until the request is cancelled
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-req.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer hub.Close()
+
+	partition, err := NewHubPartition(&rest.Config{Host: hub.URL})
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	defer partition.Close()
+
+	hubURL := partition.RestConfig().Host
+	get := func() (int, error) {
+		resp, err := http.Get(hubURL)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	post := func() (int, error) {
+		resp, err := http.Post(hubURL, "text/plain", strings.NewReader("status"))
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	code, err := get()
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	gomega.Expect(code).To(gomega.Equal(http.StatusOK))
+
+	// the requests in flight are broken by a fault
+	watch, err := http.Get(hubURL + "/watch")
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	defer watch.Body.Close()
+	broken := make(chan error, 1)
+	go func() {
+		_, err := ioutil.ReadAll(watch.Body)
+		broken <- err
+	}()
+
+	partition.Inject(HubFault{Mode: HubFaultServerError, Match: HubReadRequests})
+	gomega.Eventually(broken, 5).Should(gomega.Receive(gomega.HaveOccurred()))
+	code, err = get()
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	gomega.Expect(code).To(gomega.Equal(http.StatusInternalServerError))
+	code, err = post()
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	gomega.Expect(code).To(gomega.Equal(http.StatusOK))
+
+	partition.Inject(HubFault{Mode: HubFaultDrop})
+	_, err = get()
+	gomega.Expect(err).To(gomega.HaveOccurred())
+	_, err = post()
+	gomega.Expect(err).To(gomega.HaveOccurred())
+
+	partition.Inject(HubFault{Mode: HubFaultDelay, Delay: 200 * time.Millisecond})
+	start := time.Now()
+	code, err = get()
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	gomega.Expect(code).To(gomega.Equal(http.StatusOK))
+	gomega.Expect(time.Since(start)).To(gomega.BeNumerically(">=", 200*time.Millisecond))
+
+	gomega.Expect(partition.HealedAt().IsZero()).To(gomega.BeTrue())
+	partition.Heal()
+	gomega.Expect(partition.HealedAt().IsZero()).To(gomega.BeFalse())
+	code, err = get()
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	gomega.Expect(code).To(gomega.Equal(http.StatusOK))
+}
@@ -2,9 +2,11 @@ package util
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/onsi/gomega"
 
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -86,6 +88,36 @@ const (
 		}
 	}`
 
+	crdJsonTemplate = `{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind": "CustomResourceDefinition",
+		"metadata": {
+			"name": "%[3]s.%[1]s"
+		},
+		"spec": {
+			"group": "%[1]s",
+			"names": {
+				"kind": "%[2]s",
+				"listKind": "%[2]sList",
+				"plural": "%[3]s"
+			},
+			"scope": "Namespaced",
+			"versions": [
+				{
+					"name": "v1",
+					"schema": {
+						"openAPIV3Schema": {
+							"type": "object",
+							"x-kubernetes-preserve-unknown-fields": true
+						}
+					},
+					"served": true,
+					"storage": true
+				}
+			]
+		}
+	}`
+
 	deploymentJson = `{
 		"apiVersion": "apps/v1",
 		"kind": "Deployment",
@@ -141,6 +173,18 @@ var (
 		Resource: "serviceaccounts",
 	}
 
+	jobGVK = schema.GroupVersionKind{
+		Group:   "batch",
+		Version: "v1",
+		Kind:    "Job",
+	}
+
+	jobGVR = schema.GroupVersionResource{
+		Group:    "batch",
+		Version:  "v1",
+		Resource: "jobs",
+	}
+
 	roleGVK = schema.GroupVersionKind{
 		Group:   "rbac.authorization.k8s.io",
 		Version: "v1",
@@ -168,6 +212,7 @@ var (
 
 func init() {
 	_ = corev1.AddToScheme(scheme)
+	_ = batchv1.AddToScheme(scheme)
 	_ = rbacv1.AddToScheme(scheme)
 }
 
@@ -189,6 +234,14 @@ func GuestbookCr(namespace, name string) (cr *unstructured.Unstructured, gvr sch
 	return cr, gvr, nil
 }
 
+// NewCRD returns a namespaced crd with the given group, kind and plural, which serves the version v1 with any
+// content.
+func NewCRD(group, kind, plural string) (crd *unstructured.Unstructured, gvr schema.GroupVersionResource, err error) {
+	crd, err = loadResourceFromJSON(fmt.Sprintf(crdJsonTemplate, group, kind, plural))
+	gvr = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	return crd, gvr, err
+}
+
 func NewDeployment(namespace, name, sa string) (u *unstructured.Unstructured, gvr schema.GroupVersionResource, err error) {
 	u, err = loadResourceFromJSON(deploymentJson)
 	if err != nil {
@@ -213,6 +266,26 @@ func toUnstructured(obj runtime.Object, gvk schema.GroupVersionKind, scheme *run
 	return u
 }
 
+// NewJob returns a job which runs to completion immediately.
+func NewJob(namespace, name string) (*unstructured.Unstructured, schema.GroupVersionResource) {
+	obj := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{{Name: "job", Image: "busybox", Command: []string{"true"}}},
+				},
+			},
+		},
+	}
+
+	return toUnstructured(obj, jobGVK, scheme), jobGVR
+}
+
 func NewServiceAccount(namespace, name string) (*unstructured.Unstructured, schema.GroupVersionResource) {
 	obj := &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
@@ -0,0 +1,73 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+
+	fakeworkclient "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+func TestBuilders(t *testing.T) {
+	gomega.RegisterTestingT(t)
+
+	crd, gvr, err := NewCRD("my.domain", "Foo", "foos")
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	gomega.Expect(gvr.Resource).To(gomega.Equal("customresourcedefinitions"))
+	gomega.Expect(crd.GetName()).To(gomega.Equal("foos.my.domain"))
+	gomega.Expect(crd.GetKind()).To(gomega.Equal("CustomResourceDefinition"))
+
+	job, gvr := NewJob("ns1", "job1")
+	gomega.Expect(gvr).To(gomega.Equal(schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}))
+	gomega.Expect(job.GetAPIVersion()).To(gomega.Equal("batch/v1"))
+	gomega.Expect(job.GetKind()).To(gomega.Equal("Job"))
+	gomega.Expect(job.GetNamespace()).To(gomega.Equal("ns1"))
+	gomega.Expect(job.GetName()).To(gomega.Equal("job1"))
+
+	deploy, gvr, err := NewDeployment("ns1", "deploy1", "sa")
+	gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	gomega.Expect(gvr.Resource).To(gomega.Equal("deployments"))
+	gomega.Expect(deploy.GetNamespace()).To(gomega.Equal("ns1"))
+}
+
+func TestAssertResources(t *testing.T) {
+	gomega.RegisterTestingT(t)
+
+	job, jobGVR := NewJob("ns1", "job1")
+	job.SetFinalizers([]string{"other"})
+	dynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), job)
+
+	AssertExistenceOfResources([]schema.GroupVersionResource{jobGVR}, []string{"ns1"}, []string{"job1"}, dynamicClient, 1, 1)
+	AssertNonexistenceOfResources([]schema.GroupVersionResource{jobGVR}, []string{"ns1"}, []string{"job2"}, dynamicClient, 1, 1)
+	AssertFinalizerRemoved(jobGVR, "ns1", "job1", "test", dynamicClient, 1, 1)
+	AssertFinalizerRemoved(jobGVR, "ns1", "job2", "test", dynamicClient, 1, 1)
+}
+
+func TestAssertAppliedResourceMetas(t *testing.T) {
+	gomega.RegisterTestingT(t)
+
+	appliedWork := &workapiv1.AppliedManifestWork{
+		ObjectMeta: metav1.ObjectMeta{Name: "hash-work"},
+		Status: workapiv1.AppliedManifestWorkStatus{
+			AppliedResources: []workapiv1.AppliedManifestResourceMeta{
+				{Group: "batch", Version: "v1", Resource: "jobs", Namespace: "ns1", Name: "job1", UID: "job1"},
+				{Version: "v1", Resource: "configmaps", Namespace: "ns1", Name: "cm1", UID: "cm1"},
+			},
+		},
+	}
+	workClient := fakeworkclient.NewSimpleClientset(appliedWork)
+
+	AssertAppliedResourceMetas("hash-work", []workapiv1.AppliedManifestResourceMeta{
+		{Version: "v1", Resource: "configmaps", Namespace: "ns1", Name: "cm1", UID: "cm1"},
+		{Group: "batch", Version: "v1", Resource: "jobs", Namespace: "ns1", Name: "job1"},
+	}, workClient, 1, 1)
+	AssertAppliedResources("hash", "work",
+		[]schema.GroupVersionResource{{Version: "v1", Resource: "configmaps"}, {Group: "batch", Version: "v1", Resource: "jobs"}},
+		[]string{"ns1", "ns1"}, []string{"cm1", "job1"}, workClient, 1, 1)
+}
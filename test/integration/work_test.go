@@ -14,10 +14,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	utilrand "k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/client-go/dynamic"
 
 	workapiv1 "open-cluster-management.io/api/work/v1"
+	"open-cluster-management.io/work/pkg/constants"
 	"open-cluster-management.io/work/pkg/spoke"
 	"open-cluster-management.io/work/pkg/spoke/controllers/statuscontroller"
 	"open-cluster-management.io/work/test/integration/util"
@@ -896,6 +898,80 @@ var _ = ginkgo.Describe("ManifestWork", func() {
 			gomega.Expect(err).ToNot(gomega.HaveOccurred())
 		})
 
+		ginkgo.It("Selectively Orphan deletion of the manifestwork by label", func() {
+			// label the configmaps on the spoke cluster, the selectors are evaluated against the live resources
+			for _, name := range []string{"cm1", "cm2"} {
+				_, err = spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Patch(context.Background(), name,
+					types.MergePatchType, []byte(`{"metadata":{"labels":{"keep":"true"}}}`), metav1.PatchOptions{})
+				gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			}
+
+			work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+			work.Annotations = map[string]string{
+				constants.OrphaningSelectorsAnnotationKey: `[{"group":"","resource":"configmaps","namespace":"*","selector":{"matchLabels":{"keep":"true"}}}]`,
+			}
+			work.Spec.DeleteOption = &workapiv1.DeleteOption{
+				PropagationPolicy: workapiv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+			}
+
+			work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Update(context.Background(), work, metav1.UpdateOptions{})
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+			// Delete the work
+			err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+			util.AssertAppliedManifestWorkDeleted(appliedManifestWorkName, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+
+			// Both of the labeled configmaps should be kept without the owner of the appliedmanifestwork
+			gomega.Consistently(func() error {
+				for _, name := range []string{"cm1", "cm2"} {
+					cm, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), name, metav1.GetOptions{})
+					if err != nil {
+						return err
+					}
+					if len(cm.OwnerReferences) != 0 {
+						return fmt.Errorf("configmap %s is still owned by %v", name, cm.OwnerReferences)
+					}
+				}
+				return nil
+			}, 3*time.Second, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+		})
+
+		ginkgo.It("Orphaned resources should survive the garbage collection of the appliedmanifestwork", func() {
+			work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+			work.Spec.DeleteOption = &workapiv1.DeleteOption{
+				PropagationPolicy: workapiv1.DeletePropagationPolicyTypeOrphan,
+			}
+
+			work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Update(context.Background(), work, metav1.UpdateOptions{})
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+			// Delete the work right away, the owner may not be removed from the configmaps by the apply yet
+			err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Delete(context.Background(), work.Name, metav1.DeleteOptions{})
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+			util.AssertAppliedManifestWorkDeleted(appliedManifestWorkName, spokeWorkClient, eventuallyTimeout, eventuallyInterval)
+
+			// Ensure configmaps are kept without the owner of the appliedmanifestwork
+			gomega.Consistently(func() error {
+				for _, name := range []string{"cm1", "cm2"} {
+					cm, err := spokeKubeClient.CoreV1().ConfigMaps(o.SpokeClusterName).Get(context.Background(), name, metav1.GetOptions{})
+					if err != nil {
+						return err
+					}
+					if len(cm.OwnerReferences) != 0 {
+						return fmt.Errorf("Owner reference are not correctly updated, current ownerrefs are %v", cm.OwnerReferences)
+					}
+				}
+				return nil
+			}, eventuallyTimeout, eventuallyInterval).ShouldNot(gomega.HaveOccurred())
+		})
+
 		ginkgo.It("Clean the resource when orphan deletion option is removed", func() {
 			work, err = hubWorkClient.WorkV1().ManifestWorks(o.SpokeClusterName).Get(context.Background(), work.Name, metav1.GetOptions{})
 			gomega.Expect(err).ToNot(gomega.HaveOccurred())